@@ -25,6 +25,10 @@ type Boltnd struct {
 	lnd       *lndclient.GrpcLndServices
 	rpcServer *rpcserver.Server
 
+	// outbox persists undeliverable onion messages, and is nil if our
+	// outbox is not enabled.
+	outbox *onionmsg.BoltOutboxStore
+
 	cfg *Config
 }
 
@@ -51,9 +55,36 @@ func NewBoltnd(opts ...ConfigOption) (*Boltnd, error) {
 		cfg: cfg,
 	}
 
+	// Apply our message types and outbox first so that any messenger
+	// options provided by the caller take precedence.
+	messengerOpts := []onionmsg.MessengerOption{
+		onionmsg.OptionOnionMessageType(
+			cfg.OnionMessageTypes[0], cfg.OnionMessageTypes[1:]...,
+		),
+	}
+
+	if cfg.OutboxPath != "" {
+		outbox, err := onionmsg.NewBoltOutboxStore(cfg.OutboxPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not open outbox: %v", err)
+		}
+
+		impl.outbox = outbox
+		messengerOpts = append(
+			messengerOpts, onionmsg.OptionOutbox(
+				outbox, cfg.OutboxTTL,
+			),
+		)
+	}
+
+	messengerOpts = append(messengerOpts, cfg.MessengerOptions...)
+
 	var err error
-	impl.rpcServer, err = rpcserver.NewServer(impl.requestShutdown)
+	impl.rpcServer, err = rpcserver.NewServer(
+		impl.requestShutdown, messengerOpts...,
+	)
 	if err != nil {
+		impl.closeOutbox()
 		return nil, fmt.Errorf("could not create rpcserver: %v", err)
 	}
 
@@ -116,9 +147,22 @@ func (b *Boltnd) Stop() error {
 		b.lnd.Close()
 	}
 
+	b.closeOutbox()
+
 	return nil
 }
 
+// closeOutbox closes our outbox database, if it is enabled.
+func (b *Boltnd) closeOutbox() {
+	if b.outbox == nil {
+		return
+	}
+
+	if err := b.outbox.Close(); err != nil {
+		log.Errorf("Could not close outbox: %v", err)
+	}
+}
+
 // requestShutdown calls our graceful shutdown closure, if supplied.
 func (b *Boltnd) requestShutdown(err error) {
 	if b.cfg.RequestShutdown == nil {
@@ -1,4 +1,4 @@
-package offers
+package codec
 
 import (
 	"errors"
@@ -6,19 +6,17 @@ import (
 	"strings"
 )
 
-const (
-	charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
-	offerHRP = "lno"
-)
+// charset is the set of characters used for bech32 encoding.
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
 
 var (
-	// ErrIncorrectSplit is returned when an offer string contains "+"
-	// joins that are incorrectly placed - either consecutive, or starting/
-	// ending the offers string
+	// ErrIncorrectSplit is returned when a string contains "+" joins that
+	// are incorrectly placed - either consecutive, or starting/ending the
+	// string.
 	ErrIncorrectSplit = errors.New("consecutive, prefix or suffix + invalid")
 
-	// ErrNotInCharset is returned when a character in an offer string is
-	// not part of our charset.
+	// ErrNotInCharset is returned when a character in a string is not
+	// part of our charset.
 	ErrNotInCharset = errors.New("invalid character, not in charset")
 )
 
@@ -77,7 +75,7 @@ func decodeBech32(bech string) (string, []byte, error) {
 	decoded, err := toBytes(data)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed converting data to bytes: "+
-			"%v", err)
+			"%w", err)
 	}
 
 	// We return the full decoded data body because we are not expecting a
@@ -113,25 +111,44 @@ func toBytes(chars string) ([]byte, error) {
 	return decoded, nil
 }
 
-// stripOffer strips out any allowed "+" characters, validating that they are
-// surrounded by bech32 characters. The offer string with the "+" characters
-// removed is returned.
-func stripOffer(offer string) (string, error) {
-	parts := strings.Split(offer, "+")
+// toChars converts each 5-bit element of a byte slice to the corresponding
+// character in 'charset'.
+func toChars(data []byte) (string, error) {
+	var chars strings.Builder
+	chars.Grow(len(data))
 
-	for i, part := range parts {
-		// If any of our parts are empty, we either had consecutive or
-		// starting / ending "+" in our string.
-		if part == "" {
-			return "", ErrIncorrectSplit
+	for _, b := range data {
+		if int(b) >= len(charset) {
+			return "", fmt.Errorf("%w: %v", ErrNotInCharset, b)
 		}
 
+		chars.WriteByte(charset[b])
+	}
+
+	return chars.String(), nil
+}
+
+// stripJoins strips out any allowed "+" characters, validating that they are
+// surrounded by bech32 characters. The string with the "+" characters removed
+// is returned.
+func stripJoins(str string) (string, error) {
+	parts := strings.Split(str, "+")
+
+	for i, part := range parts {
 		// We should allow whitespace following a "+" character. Trim
 		// all whitespace so that we can check that except for spaces,
 		// all other characters are bech32. We replace the entry in our
 		// slice to strip out these paces.
 		parts[i] = strings.TrimSpace(part)
 
+		// If any of our parts are empty, we either had consecutive or
+		// starting / ending "+" in our string. We check this after
+		// trimming whitespace so that a "+" followed only by whitespace
+		// is also rejected.
+		if parts[i] == "" {
+			return "", ErrIncorrectSplit
+		}
+
 		// Check that each split is in our charset.
 		if err := checkASCII(parts[i]); err != nil {
 			return "", fmt.Errorf("%w: part: %v", err, part)
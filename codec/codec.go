@@ -0,0 +1,108 @@
+// Package codec implements the string encoding used for bolt 12 offers,
+// invoice requests and invoices: bech32 without a checksum, optionally split
+// into parts that are joined with "+" characters.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+const (
+	// OfferHRP is the human readable prefix for bolt 12 offers.
+	OfferHRP = "lno"
+
+	// InvoiceRequestHRP is the human readable prefix for bolt 12 invoice
+	// requests.
+	InvoiceRequestHRP = "lnr"
+
+	// InvoiceHRP is the human readable prefix for bolt 12 invoices.
+	InvoiceHRP = "lni"
+)
+
+var (
+	// ErrNoHRP is returned when we attempt to encode a string without a
+	// human readable prefix.
+	ErrNoHRP = errors.New("human readable prefix required")
+
+	// ErrHRPNotLowercase is returned when we attempt to encode a string
+	// with a human readable prefix that contains uppercase characters.
+	ErrHRPNotLowercase = errors.New("human readable prefix must be " +
+		"lowercase")
+
+	// ErrBadHRP is returned when a decoded string does not have the human
+	// readable prefix that we expect.
+	ErrBadHRP = errors.New("incorrect bech32 hrp")
+)
+
+// Encode encodes the data provided as a bech32 string with the human readable
+// prefix provided. The string does not include a checksum.
+func Encode(hrp string, data []byte) (string, error) {
+	if hrp == "" {
+		return "", ErrNoHRP
+	}
+
+	if err := checkASCII(hrp); err != nil {
+		return "", fmt.Errorf("hrp: %w", err)
+	}
+
+	if strings.ToLower(hrp) != hrp {
+		return "", fmt.Errorf("%w: %v", ErrHRPNotLowercase, hrp)
+	}
+
+	converted, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", fmt.Errorf("convert bits: %w", err)
+	}
+
+	chars, err := toChars(converted)
+	if err != nil {
+		return "", err
+	}
+
+	return hrp + "1" + chars, nil
+}
+
+// Decode decodes a bech32 string that does not include a checksum, returning
+// its human readable prefix and data. Any "+" characters that are used to
+// join parts of the string (and whitespace following them) are stripped
+// before decoding.
+func Decode(str string) (string, []byte, error) {
+	// First, strip any joining characters / spare whitespace from the
+	// string.
+	cleanStr, err := stripJoins(str)
+	if err != nil {
+		return "", nil, fmt.Errorf("strip joins: %w", err)
+	}
+
+	hrp, data, err := decodeBech32(cleanStr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	decoded, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return "", nil, fmt.Errorf("convert bits: %w", err)
+	}
+
+	return hrp, decoded, nil
+}
+
+// DecodeHRP decodes a bech32 string that does not include a checksum,
+// failing if it does not have the human readable prefix provided.
+func DecodeHRP(str, expectedHRP string) ([]byte, error) {
+	hrp, data, err := Decode(str)
+	if err != nil {
+		return nil, err
+	}
+
+	if hrp != expectedHRP {
+		return nil, fmt.Errorf("%w: expected: %v, got: %v", ErrBadHRP,
+			expectedHRP, hrp)
+	}
+
+	return data, nil
+}
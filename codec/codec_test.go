@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEncodeDecode tests round trip encoding of strings with each of our
+// bolt 12 human readable prefixes.
+func TestEncodeDecode(t *testing.T) {
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0xff}
+
+	for _, hrp := range []string{
+		OfferHRP, InvoiceRequestHRP, InvoiceHRP,
+	} {
+		hrp := hrp
+
+		t.Run(hrp, func(t *testing.T) {
+			encoded, err := Encode(hrp, data)
+			require.NoError(t, err, "encode")
+
+			decodedHRP, decoded, err := Decode(encoded)
+			require.NoError(t, err, "decode")
+			require.Equal(t, hrp, decodedHRP)
+			require.Equal(t, data, decoded)
+
+			decoded, err = DecodeHRP(encoded, hrp)
+			require.NoError(t, err, "decode hrp")
+			require.Equal(t, data, decoded)
+		})
+	}
+}
+
+// TestEncode tests validation of the human readable prefix used when we
+// encode strings.
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		hrp  string
+		err  error
+	}{
+		{
+			name: "valid hrp",
+			hrp:  OfferHRP,
+		},
+		{
+			name: "no hrp",
+			err:  ErrNoHRP,
+		},
+		{
+			name: "uppercase hrp",
+			hrp:  "LNO",
+			err:  ErrHRPNotLowercase,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := Encode(testCase.hrp, []byte{1, 2, 3, 4})
+			require.True(t, errors.Is(err, testCase.err))
+		})
+	}
+}
+
+// TestDecode tests decoding of strings, including strings that are split
+// into parts with "+" joins.
+func TestDecode(t *testing.T) {
+	encoded, err := Encode(InvoiceHRP, []byte{1, 2, 3, 4, 5, 6})
+	require.NoError(t, err, "encode")
+
+	tests := []struct {
+		name string
+		str  string
+		hrp  string
+		err  error
+	}{
+		{
+			name: "valid string",
+			str:  encoded,
+			hrp:  InvoiceHRP,
+		},
+		{
+			name: "uppercase string",
+			str:  strings.ToUpper(encoded),
+			hrp:  InvoiceHRP,
+		},
+		{
+			name: "joined string",
+			str: encoded[:6] + "+ " + encoded[6:10] + "+" +
+				encoded[10:],
+			hrp: InvoiceHRP,
+		},
+		{
+			name: "consecutive joins",
+			str:  encoded[:6] + "++" + encoded[6:],
+			err:  ErrIncorrectSplit,
+		},
+		{
+			name: "trailing join",
+			str:  encoded + "+",
+			err:  ErrIncorrectSplit,
+		},
+		{
+			name: "not in charset",
+			str:  encoded + "b",
+			err:  ErrNotInCharset,
+		},
+		{
+			name: "wrong hrp",
+			str:  encoded,
+			hrp:  OfferHRP,
+			err:  ErrBadHRP,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			data, err := DecodeHRP(testCase.str, testCase.hrp)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err != nil {
+				return
+			}
+
+			require.Equal(t, []byte{1, 2, 3, 4, 5, 6}, data)
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/onionmsg"
 	"github.com/lightninglabs/lndclient"
 	"github.com/lightningnetwork/lnd"
 	"github.com/lightningnetwork/lnd/build"
@@ -21,6 +23,10 @@ const (
 	// DefaultLNDWait is the default amount of time we backoff between
 	// lnd connection attempts.
 	DefaultLNDWait = time.Second * 10
+
+	// DefaultOutboxTTL is the default amount of time that we retry
+	// delivery of onion messages in our outbox before dropping them.
+	DefaultOutboxTTL = time.Hour * 24
 )
 
 // MinimumLNDVersion is the minimum lnd version and set of build tags required.
@@ -58,6 +64,36 @@ type Config struct {
 	// LNDWait is the amount of time to wait between retries to connect to
 	// lnd's grpc server.
 	LNDWait time.Duration
+
+	// OnionMessageTypes is the set of custom message types that we accept
+	// onion messages with. The first type is used to send onion messages.
+	// Note that lnd must be configured to deliver each of these types as
+	// custom messages using its protocol.custom-message option, which is
+	// checked on startup when lnd's config is provided by OptionLNDConfig.
+	// If the first type is below lnd's custom message range, we also send
+	// a real, empty onion message to one of our peers on startup to check
+	// that lnd is configured to deliver it.
+	OnionMessageTypes []uint32
+
+	// MessengerOptions is an optional set of functional options that are
+	// applied to our onion messenger, for example to add interceptors
+	// for incoming onion messages.
+	MessengerOptions []onionmsg.MessengerOption
+
+	// OutboxPath is an optional path to a database that onion messages
+	// are persisted in when their destination is unreachable, so that
+	// their delivery can be retried, including across restarts. If this
+	// value is empty, sends to unreachable destinations fail.
+	OutboxPath string
+
+	// OutboxTTL is the amount of time that we retry delivery of messages
+	// in our outbox before dropping them.
+	OutboxTTL time.Duration
+
+	// lndCustomMessages is the set of message types that lnd is configured
+	// to handle as custom messages using its protocol.custom-message
+	// option. This value is nil if lnd's configuration is not known.
+	lndCustomMessages map[uint32]struct{}
 }
 
 // DefaultConfig returns a default config.
@@ -82,6 +118,10 @@ func DefaultConfig() *Config {
 		},
 		LNDRetires: DefaultLNDRetries,
 		LNDWait:    DefaultLNDWait,
+		OutboxTTL:  DefaultOutboxTTL,
+		OnionMessageTypes: []uint32{
+			lnwire.OnionMessageType,
+		},
 	}
 }
 
@@ -106,6 +146,28 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("wait: %v must be > 0", c.LNDWait)
 	}
 
+	if c.OutboxPath != "" && c.OutboxTTL <= 0 {
+		return fmt.Errorf("outbox ttl: %v must be > 0", c.OutboxTTL)
+	}
+
+	if len(c.OnionMessageTypes) == 0 {
+		return errors.New("at least one onion message type required")
+	}
+
+	// If we know lnd's configuration, check that it will deliver each of
+	// our onion message types that are outside of the custom range.
+	if c.lndCustomMessages != nil {
+		for _, msgType := range c.OnionMessageTypes {
+			if msgType >= onionmsg.CustomTypeStart {
+				continue
+			}
+
+			if _, ok := c.lndCustomMessages[msgType]; !ok {
+				return onionmsg.OverrideError(msgType)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -149,6 +211,14 @@ func OptionLNDConfig(cfg *lnd.Config) ConfigOption {
 				"regtest supported")
 		}
 
+		c.lndCustomMessages = make(map[uint32]struct{})
+		if cfg.ProtocolOptions != nil {
+			overrides := cfg.ProtocolOptions.CustomMessageOverrides()
+			for _, msgType := range overrides {
+				c.lndCustomMessages[uint32(msgType)] = struct{}{}
+			}
+		}
+
 		return nil
 	}
 }
@@ -182,6 +252,37 @@ func OptionSetupLogger(setup func(string, LogRegistration)) ConfigOption {
 	}
 }
 
+// OptionOnionMessageTypes sets the custom message types that we accept onion
+// messages with. The first type provided is used to send onion messages. If
+// that type is below lnd's custom message range, a real onion message is sent
+// to one of our peers on startup to check lnd's custom message override.
+func OptionOnionMessageTypes(msgTypes ...uint32) ConfigOption {
+	return func(c *Config) error {
+		c.OnionMessageTypes = msgTypes
+		return nil
+	}
+}
+
+// OptionMessenger adds functional options that are applied to our onion
+// messenger.
+func OptionMessenger(opts ...onionmsg.MessengerOption) ConfigOption {
+	return func(c *Config) error {
+		c.MessengerOptions = append(c.MessengerOptions, opts...)
+		return nil
+	}
+}
+
+// OptionOutbox persists onion messages that can't be delivered in a database
+// at the path provided, retrying their delivery until the ttl provided has
+// passed.
+func OptionOutbox(path string, ttl time.Duration) ConfigOption {
+	return func(c *Config) error {
+		c.OutboxPath = path
+		c.OutboxTTL = ttl
+		return nil
+	}
+}
+
 // OptionRequestShutdown provides a closure that will gracefully shutdown the
 // calling code if boltnd exits with an error.
 func OptionRequestShutdown(s func()) ConfigOption {
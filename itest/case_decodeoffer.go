@@ -74,3 +74,35 @@ func DecodeOfferTestCase(ht *lntest.HarnessTest) {
 	require.Equal(ht.T, "Offer by rusty's node", resp.Offer.Description)
 	require.Equal(ht.T, nodeIDStr, resp.Offer.NodeId, "node id")
 }
+
+// DecodeOffersTestCase tests batch decoding of offer strings.
+func DecodeOffersTestCase(ht *lntest.HarnessTest) {
+	offersTest := setupForBolt12(ht)
+	defer offersTest.cleanup()
+
+	ctxb := context.Background()
+	ctxt, cancel := context.WithTimeout(ctxb, defaultTimeout)
+	defer cancel()
+
+	// Decode a batch containing valid offers and an invalid one, and
+	// assert that we get a result for each entry in the order requested.
+	req := &offersrpc.DecodeOffersRequest{
+		Offers: []string{
+			offerStr, "lno1invalid", signedOfferStr,
+		},
+	}
+
+	resp, err := offersTest.aliceOffers.DecodeOffers(ctxt, req)
+	require.NoError(ht.T, err, "offers decode")
+	require.Len(ht.T, resp.Results, len(req.Offers), "result count")
+
+	require.Empty(ht.T, resp.Results[0].Error, "offer error")
+	require.Equal(ht.T, nodeIDStr, resp.Results[0].Offer.NodeId, "node id")
+
+	require.NotEmpty(ht.T, resp.Results[1].Error, "invalid offer error")
+	require.Nil(ht.T, resp.Results[1].Offer, "invalid offer")
+
+	require.Empty(ht.T, resp.Results[2].Error, "signed offer error")
+	require.Equal(ht.T, "Offer by rusty's node",
+		resp.Results[2].Offer.Description)
+}
@@ -16,7 +16,7 @@ func OnionMsgForwardTestCase(ht *lntest.HarnessTest) {
 
 	// Spin up a third node immediately because we will need a three-hop
 	// network for this test.
-	carol := ht.NewNode("carol", []string{onionMsgProtocolOverride})
+	carol := ht.NewNode("carol", onionMsgArgs())
 	carolB12, cleanup := bolt12Client(ht.T, carol)
 	defer cleanup()
 
@@ -98,7 +98,7 @@ func OnionMessageTestCase(ht *lntest.HarnessTest) {
 
 	// Now, we will spin up a new node, carol to test sending messages to
 	// peers that we are not currently connected to.
-	carol := ht.NewNode("carol", []string{onionMsgProtocolOverride})
+	carol := ht.NewNode("carol", onionMsgArgs())
 
 	// Connect Alice and Carol so that Carol can sync the graph from Alice.
 	ht.ConnectNodesPerm(ht.Alice, carol)
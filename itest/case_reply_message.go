@@ -18,8 +18,8 @@ func ReplyMessageTestCase(ht *lntest.HarnessTest) {
 
 	// Setup our network with the following topology:
 	// Alice -- Bob -- Carol -- Dave
-	carol := ht.NewNode("carol", []string{onionMsgProtocolOverride})
-	dave := ht.NewNode("dave", []string{onionMsgProtocolOverride})
+	carol := ht.NewNode("carol", onionMsgArgs())
+	dave := ht.NewNode("dave", onionMsgArgs())
 
 	// We'll also need a bolt 12 client for dave, because he's going to be
 	// receiving our onion messages.
@@ -12,9 +12,20 @@ import (
 
 const (
 	onionMsgProtocolOverride = "--protocol.custom-message=513"
+	onionMsgFeatureOverride  = "--protocol.custom-nodeann=39"
 	defaultTimeout           = time.Second * 30
 )
 
+// onionMsgArgs returns the extra args that nodes require to handle onion
+// messages externally and advertise that they support onion messages, so
+// that they'll be selected for multi-hop paths.
+func onionMsgArgs() []string {
+	return []string{
+		onionMsgProtocolOverride,
+		onionMsgFeatureOverride,
+	}
+}
+
 type bolt12TestSetup struct {
 	aliceOffers offersrpc.OffersClient
 	bobOffers   offersrpc.OffersClient
@@ -27,13 +38,8 @@ type bolt12TestSetup struct {
 func setupForBolt12(ht *lntest.HarnessTest) *bolt12TestSetup {
 	// Update both nodes extra args to allow external handling of onion
 	// messages and restart them so that the args some into effect.
-	ht.RestartNodeWithExtraArgs(ht.Alice, []string{
-		onionMsgProtocolOverride,
-	})
-
-	ht.RestartNodeWithExtraArgs(ht.Bob, []string{
-		onionMsgProtocolOverride,
-	})
+	ht.RestartNodeWithExtraArgs(ht.Alice, onionMsgArgs())
+	ht.RestartNodeWithExtraArgs(ht.Bob, onionMsgArgs())
 
 	// Next, connect to each node's offers subserver.
 	aliceClient, aliceClean := bolt12Client(ht.T, ht.Alice)
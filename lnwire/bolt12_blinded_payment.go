@@ -0,0 +1,283 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+var (
+	// ErrNoPaymentPaths is returned when an invoice's path records are
+	// present, but do not contain any blinded paths.
+	ErrNoPaymentPaths = errors.New("invoice paths record empty")
+
+	// ErrPayInfoMismatch is returned when an invoice does not have
+	// exactly one blinded pay info entry for each of its payment paths.
+	ErrPayInfoMismatch = errors.New("invoice requires blinded pay info " +
+		"for each payment path")
+)
+
+// BlindedPayInfo describes the aggregate fees and cltv delta required to pay
+// an invoice along one of its blinded payment paths, along with the htlc
+// limits and features that apply to the path.
+type BlindedPayInfo struct {
+	// FeeBaseMsat is the base fee charged for payment along the path.
+	FeeBaseMsat uint32
+
+	// FeeProportionalMillionths is the proportional fee charged for
+	// payment along the path.
+	FeeProportionalMillionths uint32
+
+	// CLTVExpiryDelta is the total cltv expiry delta of the path.
+	CLTVExpiryDelta uint16
+
+	// HTLCMinimum is the minimum htlc that the path can carry.
+	HTLCMinimum lndwire.MilliSatoshi
+
+	// HTLCMaximum is the maximum htlc that the path can carry.
+	HTLCMaximum lndwire.MilliSatoshi
+
+	// Features is the set of features required for payment along the
+	// path.
+	Features *lndwire.FeatureVector
+}
+
+// size returns the encoded size of a blinded pay info entry.
+func (b *BlindedPayInfo) size() uint64 {
+	// 4 byte base fee + 4 byte proportional fee + 2 byte cltv delta + 8
+	// byte htlc minimum + 8 byte htlc maximum + 2 byte features length.
+	size := uint64(4 + 4 + 2 + 8 + 8 + 2)
+
+	if b.Features != nil {
+		size += uint64(b.Features.SerializeSize())
+	}
+
+	return size
+}
+
+// encodeBlindedPayInfo encodes a blinded pay info entry.
+func encodeBlindedPayInfo(w io.Writer, b *BlindedPayInfo,
+	buf *[8]byte) error {
+
+	if err := tlv.EUint32(w, &b.FeeBaseMsat, buf); err != nil {
+		return fmt.Errorf("encode base fee: %w", err)
+	}
+
+	err := tlv.EUint32(w, &b.FeeProportionalMillionths, buf)
+	if err != nil {
+		return fmt.Errorf("encode proportional fee: %w", err)
+	}
+
+	if err := tlv.EUint16(w, &b.CLTVExpiryDelta, buf); err != nil {
+		return fmt.Errorf("encode cltv delta: %w", err)
+	}
+
+	htlcMin := uint64(b.HTLCMinimum)
+	if err := tlv.EUint64(w, &htlcMin, buf); err != nil {
+		return fmt.Errorf("encode htlc minimum: %w", err)
+	}
+
+	htlcMax := uint64(b.HTLCMaximum)
+	if err := tlv.EUint64(w, &htlcMax, buf); err != nil {
+		return fmt.Errorf("encode htlc maximum: %w", err)
+	}
+
+	features := lndwire.NewRawFeatureVector()
+	if b.Features != nil {
+		features = b.Features.RawFeatureVector
+	}
+
+	// Our raw feature vector is encoded with its uint16 length prefix.
+	if err := features.Encode(w); err != nil {
+		return fmt.Errorf("encode features: %w", err)
+	}
+
+	return nil
+}
+
+// decodeBlindedPayInfo decodes a blinded pay info entry.
+func decodeBlindedPayInfo(r io.Reader, b *BlindedPayInfo,
+	buf *[8]byte) error {
+
+	if err := tlv.DUint32(r, &b.FeeBaseMsat, buf, 4); err != nil {
+		return fmt.Errorf("decode base fee: %w", err)
+	}
+
+	err := tlv.DUint32(r, &b.FeeProportionalMillionths, buf, 4)
+	if err != nil {
+		return fmt.Errorf("decode proportional fee: %w", err)
+	}
+
+	if err := tlv.DUint16(r, &b.CLTVExpiryDelta, buf, 2); err != nil {
+		return fmt.Errorf("decode cltv delta: %w", err)
+	}
+
+	var htlcMin, htlcMax uint64
+	if err := tlv.DUint64(r, &htlcMin, buf, 8); err != nil {
+		return fmt.Errorf("decode htlc minimum: %w", err)
+	}
+
+	if err := tlv.DUint64(r, &htlcMax, buf, 8); err != nil {
+		return fmt.Errorf("decode htlc maximum: %w", err)
+	}
+
+	b.HTLCMinimum = lndwire.MilliSatoshi(htlcMin)
+	b.HTLCMaximum = lndwire.MilliSatoshi(htlcMax)
+
+	features := lndwire.NewRawFeatureVector()
+	if err := features.Decode(r); err != nil {
+		return fmt.Errorf("decode features: %w", err)
+	}
+
+	b.Features = lndwire.NewFeatureVector(features, lndwire.Features)
+
+	return nil
+}
+
+// pathsRecord produces a tlv record for a set of blinded payment paths, which
+// are encoded back to back without a count prefix.
+func pathsRecord(tlvType tlv.Type, paths *[]*ReplyPath) tlv.Record {
+	size := func() uint64 {
+		var size uint64
+		for _, path := range *paths {
+			size += path.size()
+		}
+
+		return size
+	}
+
+	return tlv.MakeDynamicRecord(
+		tlvType, paths, size, encodePaths, decodePaths,
+	)
+}
+
+// encodePaths encodes a set of blinded payment paths.
+func encodePaths(w io.Writer, val interface{}, buf *[8]byte) error {
+	if p, ok := val.(*[]*ReplyPath); ok {
+		for i, path := range *p {
+			if err := encodeReplyPath(w, path, buf); err != nil {
+				return fmt.Errorf("path %v: %w", i, err)
+			}
+		}
+
+		return nil
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "*[]*ReplyPath")
+}
+
+// decodePaths decodes a set of blinded payment paths, reading paths until
+// the full length of the record has been consumed.
+func decodePaths(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if p, ok := val.(*[]*ReplyPath); ok {
+		if l == 0 {
+			return ErrNoPaymentPaths
+		}
+
+		record, err := readRecord(r, l)
+		if err != nil {
+			return fmt.Errorf("read paths: %w", err)
+		}
+
+		reader := bytes.NewReader(record)
+		for reader.Len() > 0 {
+			path := &ReplyPath{}
+			err = decodeReplyPath(
+				reader, path, buf, uint64(reader.Len()),
+			)
+			if err != nil {
+				return fmt.Errorf("path %v: %w", len(*p), err)
+			}
+
+			*p = append(*p, path)
+		}
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "*[]*ReplyPath", l, l)
+}
+
+// payInfoRecord produces a tlv record for a set of blinded pay info entries,
+// which are encoded back to back without a count prefix.
+func payInfoRecord(tlvType tlv.Type, payInfo *[]*BlindedPayInfo) tlv.Record {
+	size := func() uint64 {
+		var size uint64
+		for _, info := range *payInfo {
+			size += info.size()
+		}
+
+		return size
+	}
+
+	return tlv.MakeDynamicRecord(
+		tlvType, payInfo, size, encodePayInfo, decodePayInfo,
+	)
+}
+
+// encodePayInfo encodes a set of blinded pay info entries.
+func encodePayInfo(w io.Writer, val interface{}, buf *[8]byte) error {
+	if p, ok := val.(*[]*BlindedPayInfo); ok {
+		for i, info := range *p {
+			err := encodeBlindedPayInfo(w, info, buf)
+			if err != nil {
+				return fmt.Errorf("pay info %v: %w", i, err)
+			}
+		}
+
+		return nil
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "*[]*BlindedPayInfo")
+}
+
+// decodePayInfo decodes a set of blinded pay info entries, reading entries
+// until the full length of the record has been consumed.
+func decodePayInfo(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if p, ok := val.(*[]*BlindedPayInfo); ok {
+		record, err := readRecord(r, l)
+		if err != nil {
+			return fmt.Errorf("read pay info: %w", err)
+		}
+
+		reader := bytes.NewReader(record)
+		for reader.Len() > 0 {
+			info := &BlindedPayInfo{}
+			err = decodeBlindedPayInfo(reader, info, buf)
+			if err != nil {
+				return fmt.Errorf("pay info %v: %w", len(*p),
+					err)
+			}
+
+			*p = append(*p, info)
+		}
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "*[]*BlindedPayInfo", l, l)
+}
+
+// readRecord reads a record's value of length l. The value is read through a
+// limited reader so that we don't allocate l bytes up front for a length that
+// the record's encoding does not actually contain.
+func readRecord(r io.Reader, l uint64) ([]byte, error) {
+	record, err := io.ReadAll(io.LimitReader(r, int64(l)))
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(record)) != l {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return record, nil
+}
@@ -0,0 +1,205 @@
+package lnwire
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// maxCurrencyExponent is the largest minor unit exponent of the
+	// currencies that we support. Amounts are normalized to this
+	// precision so that they can be compared across currencies.
+	maxCurrencyExponent = 4
+
+	// MaxCurrencyMajorAmount is the largest amount, in a currency's major
+	// unit, that we accept for currency offers. The bound in minor units
+	// depends on the currency's exponent, and is chosen so that any
+	// accepted amount can be normalized without overflow.
+	MaxCurrencyMajorAmount uint64 = 1_000_000_000_000_000
+)
+
+var (
+	// ErrUnknownCurrency is returned when a currency code is not an
+	// active ISO-4217 code with a defined minor unit.
+	ErrUnknownCurrency = errors.New("unknown ISO-4217 currency code")
+
+	// ErrCurrencyAmountRequired is returned when an offer sets a currency
+	// but no amount.
+	ErrCurrencyAmountRequired = errors.New("amount required for currency " +
+		"offer")
+
+	// ErrCurrencyAmountRange is returned when a currency amount exceeds
+	// the bound for its currency.
+	ErrCurrencyAmountRange = errors.New("currency amount out of range")
+
+	// ErrAmountDenomination is returned when an offer sets both a
+	// millisatoshi and a currency amount.
+	ErrAmountDenomination = errors.New("offer amount must be set in " +
+		"either millisatoshis or a currency, not both")
+
+	// ErrCurrencyExponent is returned when a currency amount's exponent
+	// does not match the minor unit of its currency.
+	ErrCurrencyExponent = errors.New("currency exponent mismatch")
+)
+
+// iso4217Exponents maps active ISO-4217 currency codes to the exponent of
+// their minor unit (eg, 2 for USD, where 100 cents = 1 dollar). Codes that
+// have no minor unit defined (precious metals, testing and fund codes) are
+// not included, since amounts cannot be expressed in them.
+var iso4217Exponents = map[string]uint8{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2,
+	"AUD": 2, "AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2,
+	"BHD": 3, "BIF": 0, "BMD": 2, "BND": 2, "BOB": 2, "BOV": 2, "BRL": 2,
+	"BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2,
+	"CHE": 2, "CHF": 2, "CHW": 2, "CLF": 4, "CLP": 0, "CNY": 2, "COP": 2,
+	"COU": 2, "CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2,
+	"DOP": 2, "DZD": 2, "EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2,
+	"FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2, "GMD": 2, "GNF": 0,
+	"GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2,
+	"ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2, "JOD": 3,
+	"JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0,
+	"KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2,
+	"LSL": 2, "LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2,
+	"MNT": 2, "MOP": 2, "MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2,
+	"MXV": 2, "MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2,
+	"NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2, "PGK": 2, "PHP": 2,
+	"PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2, "RUB": 2,
+	"RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2,
+	"SYP": 2, "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2,
+	"TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2,
+	"USN": 2, "UYI": 0, "UYU": 2, "UYW": 4, "UZS": 2, "VED": 2, "VES": 2,
+	"VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XCG": 2, "XOF": 0,
+	"XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2, "ZWL": 2,
+}
+
+// CurrencyExponent returns the minor unit exponent for an ISO-4217 currency
+// code.
+func CurrencyExponent(code string) (uint8, error) {
+	exponent, ok := iso4217Exponents[code]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+
+	return exponent, nil
+}
+
+// CurrencyAmount is an amount expressed in the minor unit of an ISO-4217
+// currency.
+type CurrencyAmount struct {
+	// Code is the ISO-4217 currency code.
+	Code string
+
+	// Minor is the amount expressed in the currency's minor unit.
+	Minor uint64
+
+	// Exponent is the exponent of the currency's minor unit.
+	Exponent uint8
+}
+
+// NewCurrencyAmount creates a currency amount for the code and minor unit
+// amount provided, failing if the code is unknown or the amount is not valid
+// for the currency.
+func NewCurrencyAmount(code string, minor uint64) (*CurrencyAmount, error) {
+	exponent, err := CurrencyExponent(code)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := &CurrencyAmount{
+		Code:     code,
+		Minor:    minor,
+		Exponent: exponent,
+	}
+
+	if err := amount.Validate(); err != nil {
+		return nil, err
+	}
+
+	return amount, nil
+}
+
+// MaxCurrencyMinor returns the largest amount that we accept for a currency,
+// expressed in the minor unit with the exponent provided.
+func MaxCurrencyMinor(exponent uint8) uint64 {
+	return MaxCurrencyMajorAmount * uint64(math.Pow10(int(exponent)))
+}
+
+// Validate checks that a currency amount has a known code with a matching
+// exponent, and a non-zero amount that is within the bound for its currency.
+func (c *CurrencyAmount) Validate() error {
+	exponent, err := CurrencyExponent(c.Code)
+	if err != nil {
+		return err
+	}
+
+	if c.Exponent != exponent {
+		return fmt.Errorf("%w: %v has exponent %v, not %v",
+			ErrCurrencyExponent, c.Code, exponent, c.Exponent)
+	}
+
+	if c.Minor == 0 {
+		return fmt.Errorf("%w: %v", ErrCurrencyAmountRequired, c.Code)
+	}
+
+	if max := MaxCurrencyMinor(c.Exponent); c.Minor > max {
+		return fmt.Errorf("%w: %v exceeds %v %v",
+			ErrCurrencyAmountRange, c, MaxCurrencyMajorAmount,
+			c.Code)
+	}
+
+	return nil
+}
+
+// Normalized returns the amount expressed in units of 10^-4 of the
+// currency's major unit, so that amounts can be treated uniformly
+// regardless of the currency's exponent.
+func (c *CurrencyAmount) Normalized() (uint64, error) {
+	if c.Exponent > maxCurrencyExponent {
+		return 0, fmt.Errorf("%w: %v", ErrCurrencyExponent, c.Exponent)
+	}
+
+	scale := uint64(math.Pow10(maxCurrencyExponent - int(c.Exponent)))
+
+	if c.Minor > math.MaxUint64/scale {
+		return 0, fmt.Errorf("%w: %v %v", ErrCurrencyAmountRange,
+			c.Minor, c.Code)
+	}
+
+	return c.Minor * scale, nil
+}
+
+// String returns the amount expressed in the currency's major unit, eg
+// "12.34 USD".
+func (c *CurrencyAmount) String() string {
+	if c.Exponent == 0 {
+		return fmt.Sprintf("%d %v", c.Minor, c.Code)
+	}
+
+	scale := uint64(math.Pow10(int(c.Exponent)))
+	fraction := fmt.Sprintf("%0*d", int(c.Exponent), c.Minor%scale)
+
+	return fmt.Sprintf("%d.%v %v", c.Minor/scale, fraction, c.Code)
+}
+
+// decodeCurrencyAmount decodes a currency code from a tlv record, validating
+// that it is a known ISO-4217 code, and returns it with the minor unit amount
+// provided. The amount is not validated, so that offers can be decoded before
+// they are validated.
+func decodeCurrencyAmount(currency []byte, minor uint64) (*CurrencyAmount,
+	error) {
+
+	code := string(currency)
+
+	exponent, err := CurrencyExponent(code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CurrencyAmount{
+		Code:     code,
+		Minor:    minor,
+		Exponent: exponent,
+	}, nil
+}
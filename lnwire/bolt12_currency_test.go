@@ -0,0 +1,240 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCurrencyAmount tests validation and normalization of currency amounts.
+func TestCurrencyAmount(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		minor      uint64
+		normalized uint64
+		str        string
+		err        error
+	}{
+		{
+			name:       "two decimal currency",
+			code:       "USD",
+			minor:      1234,
+			normalized: 123400,
+			str:        "12.34 USD",
+		},
+		{
+			name:       "zero decimal currency",
+			code:       "JPY",
+			minor:      500,
+			normalized: 5000000,
+			str:        "500 JPY",
+		},
+		{
+			name:       "three decimal currency",
+			code:       "KWD",
+			minor:      1005,
+			normalized: 10050,
+			str:        "1.005 KWD",
+		},
+		{
+			name:       "four decimal currency, max amount",
+			code:       "CLF",
+			minor:      MaxCurrencyMajorAmount * 10000,
+			normalized: MaxCurrencyMajorAmount * 10000,
+			str:        "1000000000000000.0000 CLF",
+		},
+		{
+			name:  "zero decimal currency, above max amount",
+			code:  "JPY",
+			minor: MaxCurrencyMajorAmount + 1,
+			err:   ErrCurrencyAmountRange,
+		},
+		{
+			name:  "two decimal currency, above max amount",
+			code:  "USD",
+			minor: MaxCurrencyMajorAmount*100 + 1,
+			err:   ErrCurrencyAmountRange,
+		},
+		{
+			name:  "zero amount",
+			code:  "USD",
+			minor: 0,
+			err:   ErrCurrencyAmountRequired,
+		},
+		{
+			name:  "unknown currency",
+			code:  "ABC",
+			minor: 1,
+			err:   ErrUnknownCurrency,
+		},
+		{
+			name:  "lower case code",
+			code:  "usd",
+			minor: 1,
+			err:   ErrUnknownCurrency,
+		},
+		{
+			name:  "no minor unit",
+			code:  "XAU",
+			minor: 1,
+			err:   ErrUnknownCurrency,
+		},
+		{
+			name:  "amount overflows",
+			code:  "JPY",
+			minor: math.MaxUint64 / 1000,
+			err:   ErrCurrencyAmountRange,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			amount, err := NewCurrencyAmount(
+				testCase.code, testCase.minor,
+			)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err != nil {
+				return
+			}
+
+			normalized, err := amount.Normalized()
+			require.NoError(t, err)
+			require.Equal(t, testCase.normalized, normalized)
+			require.Equal(t, testCase.str, amount.String())
+		})
+	}
+}
+
+// TestOfferCurrency tests validation of currency offers.
+func TestOfferCurrency(t *testing.T) {
+	nodeID := testutils.GetPubkeys(t, 1)[0]
+
+	tests := []struct {
+		name  string
+		offer *Offer
+		err   error
+	}{
+		{
+			name: "valid currency offer",
+			offer: &Offer{
+				NodeID:      nodeID,
+				Description: "offer",
+				CurrencyAmount: &CurrencyAmount{
+					Code:     "EUR",
+					Minor:    100,
+					Exponent: 2,
+				},
+			},
+		},
+		{
+			name: "currency without amount",
+			offer: &Offer{
+				NodeID:      nodeID,
+				Description: "offer",
+				CurrencyAmount: &CurrencyAmount{
+					Code:     "EUR",
+					Exponent: 2,
+				},
+			},
+			err: ErrCurrencyAmountRequired,
+		},
+		{
+			name: "impossible amount",
+			offer: &Offer{
+				NodeID:      nodeID,
+				Description: "offer",
+				CurrencyAmount: &CurrencyAmount{
+					Code:  "ISK",
+					Minor: math.MaxInt64,
+				},
+			},
+			err: ErrCurrencyAmountRange,
+		},
+		{
+			name: "wrong exponent",
+			offer: &Offer{
+				NodeID:      nodeID,
+				Description: "offer",
+				CurrencyAmount: &CurrencyAmount{
+					Code:     "USD",
+					Minor:    100,
+					Exponent: 0,
+				},
+			},
+			err: ErrCurrencyExponent,
+		},
+		{
+			name: "millisatoshi and currency amount",
+			offer: &Offer{
+				NodeID:        nodeID,
+				Description:   "offer",
+				MinimumAmount: 100,
+				CurrencyAmount: &CurrencyAmount{
+					Code:     "USD",
+					Minor:    100,
+					Exponent: 2,
+				},
+			},
+			err: ErrAmountDenomination,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.offer.Validate()
+			require.True(t, errors.Is(err, testCase.err), err)
+		})
+	}
+
+	// We should fail to encode or decode offers with unknown currencies.
+	_, err := EncodeOffer(&Offer{
+		CurrencyAmount: &CurrencyAmount{Code: "BTC", Minor: 1},
+	})
+	require.True(t, errors.Is(err, ErrUnknownCurrency), err)
+
+	currency := []byte("BTC")
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(currencyType, &currency),
+	)
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, stream.Encode(buf))
+
+	_, err = DecodeOffer(buf.Bytes())
+	require.True(t, errors.Is(err, ErrUnknownCurrency), err)
+
+	_, err = DecodeOfferCompat(buf.Bytes())
+	require.True(t, errors.Is(err, ErrUnknownCurrency), err)
+
+	// A currency offer's amount is decoded in the currency's minor unit,
+	// and not as millisatoshis.
+	encoded, err := EncodeOffer(&Offer{
+		CurrencyAmount: &CurrencyAmount{
+			Code:     "USD",
+			Minor:    250,
+			Exponent: 2,
+		},
+	})
+	require.NoError(t, err)
+
+	decoded, err := DecodeOffer(encoded)
+	require.NoError(t, err)
+	require.Zero(t, decoded.MinimumAmount)
+	require.Equal(t, &CurrencyAmount{
+		Code:     "USD",
+		Minor:    250,
+		Exponent: 2,
+	}, decoded.CurrencyAmount)
+}
@@ -0,0 +1,111 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// ErrUnknownRequiredFeature is returned when a feature vector requires a
+// feature that we do not understand.
+var ErrUnknownRequiredFeature = errors.New("unknown required feature")
+
+// encodeFeaturesRecord creates a tlv record with the type provided, encoding
+// the feature vector provided as a byte vector. If the vector provided is nil
+// or empty the record returned will be nil.
+func encodeFeaturesRecord(recordType tlv.Type,
+	features *lnwire.FeatureVector) (*tlv.Record, error) {
+
+	if features == nil || features.IsEmpty() {
+		return nil, nil
+	}
+
+	featureBytes, err := EncodeFeatures(features)
+	if err != nil {
+		return nil, err
+	}
+
+	record := tlv.MakePrimitiveRecord(recordType, &featureBytes)
+	return &record, nil
+}
+
+// decodeFeaturesRecord decodes the features record provided. If it is not
+// present, an empty feature vector will be returned for easy use.
+func decodeFeaturesRecord(decodedFeatures []byte,
+	found bool) (*lnwire.FeatureVector, error) {
+
+	if !found {
+		return lnwire.EmptyFeatureVector(), nil
+	}
+
+	return DecodeFeatures(decodedFeatures)
+}
+
+// EncodeFeatures encodes a feature vector as the big endian bit field that
+// bolt 12 uses for its feature records. The encoding does not include a length
+// prefix, because this is provided by the record's tlv length.
+func EncodeFeatures(features *lnwire.FeatureVector) ([]byte, error) {
+	if features == nil {
+		return nil, nil
+	}
+
+	w := new(bytes.Buffer)
+	if err := features.EncodeBase256(w); err != nil {
+		return nil, fmt.Errorf("encode features: %w", err)
+	}
+
+	return w.Bytes(), nil
+}
+
+// DecodeFeatures decodes a bolt 12 feature bit field, returning a feature
+// vector that uses lnd's feature names.
+func DecodeFeatures(b []byte) (*lnwire.FeatureVector, error) {
+	rawFeatures := lnwire.NewRawFeatureVector()
+
+	err := rawFeatures.DecodeBase256(bytes.NewReader(b), len(b))
+	if err != nil {
+		return nil, fmt.Errorf("raw features decode: %w", err)
+	}
+
+	return lnwire.NewFeatureVector(rawFeatures, lnwire.Features), nil
+}
+
+// ValidateFeatures checks that a feature vector does not set both the required
+// and optional bit for a feature, and that it does not require any features
+// other than the known set provided. Unknown optional features are allowed,
+// following the "it's ok to be odd" rule. Knowing either bit of a feature pair
+// is sufficient to support it.
+func ValidateFeatures(features *lnwire.FeatureVector,
+	known ...lnwire.FeatureBit) error {
+
+	if features == nil {
+		return nil
+	}
+
+	if err := features.ValidatePairs(); err != nil {
+		return err
+	}
+
+	knownFeatures := lnwire.NewRawFeatureVector()
+	for _, bit := range known {
+		knownFeatures.Set(bit)
+	}
+
+	for bit := range features.Features() {
+		if !bit.IsRequired() {
+			continue
+		}
+
+		if knownFeatures.IsSet(bit) || knownFeatures.IsSet(bit^1) {
+			continue
+		}
+
+		return fmt.Errorf("%w: %v", ErrUnknownRequiredFeature,
+			features.Name(bit))
+	}
+
+	return nil
+}
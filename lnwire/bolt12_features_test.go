@@ -0,0 +1,120 @@
+package lnwire
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeatureEncoding tests encoding of bolt 12 feature bit fields, which do
+// not include a length prefix.
+func TestFeatureEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		features *lnwire.FeatureVector
+		encoded  []byte
+	}{
+		{
+			name:     "empty",
+			features: lnwire.EmptyFeatureVector(),
+			encoded:  nil,
+		},
+		{
+			name: "single byte",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(1),
+				lnwire.Features,
+			),
+			encoded: []byte{0x02},
+		},
+		{
+			name: "multiple bytes",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(0, 9),
+				lnwire.Features,
+			),
+			encoded: []byte{0x02, 0x01},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			encoded, err := EncodeFeatures(testCase.features)
+			require.NoError(t, err, "encode")
+			require.Equal(t, testCase.encoded, encoded)
+
+			decoded, err := DecodeFeatures(encoded)
+			require.NoError(t, err, "decode")
+			require.Equal(t, testCase.features, decoded)
+		})
+	}
+}
+
+// TestValidateFeatures tests validation of required and optional features.
+func TestValidateFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		features *lnwire.FeatureVector
+		known    []lnwire.FeatureBit
+		err      error
+	}{
+		{
+			name: "nil features",
+		},
+		{
+			name: "unknown optional",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(101),
+				lnwire.Features,
+			),
+		},
+		{
+			name: "unknown required",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(100),
+				lnwire.Features,
+			),
+			err: ErrUnknownRequiredFeature,
+		},
+		{
+			name: "known required",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(100),
+				lnwire.Features,
+			),
+			known: []lnwire.FeatureBit{100},
+		},
+		{
+			name: "known by optional bit",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(100),
+				lnwire.Features,
+			),
+			known: []lnwire.FeatureBit{101},
+		},
+		{
+			name: "both bits set",
+			features: lnwire.NewFeatureVector(
+				lnwire.NewRawFeatureVector(100, 101),
+				lnwire.Features,
+			),
+			known: []lnwire.FeatureBit{100},
+			err:   lnwire.ErrFeaturePairExists,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := ValidateFeatures(
+				testCase.features, testCase.known...,
+			)
+			require.True(t, errors.Is(err, testCase.err))
+		})
+	}
+}
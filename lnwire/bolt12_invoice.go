@@ -30,6 +30,14 @@ const (
 	// invoice.
 	invFeatType tlv.Type = 12
 
+	// invPathsType is a record containing the blinded paths that the
+	// invoice can be paid along.
+	invPathsType tlv.Type = 16
+
+	// invBlindedPayType is a record containing the fees, cltv delta and
+	// htlc limits for each of the invoice's blinded paths.
+	invBlindedPayType tlv.Type = 18
+
 	// invNodeIDType is a record for the node's public key.
 	invNodeIDType tlv.Type = 30
 
@@ -98,6 +106,15 @@ type Invoice struct {
 	// Features is the set of features the invoice requires.
 	Features *lndwire.FeatureVector
 
+	// Paths is an optional set of blinded paths that the invoice can be
+	// paid along. Payment paths share their encoding with the blinded
+	// reply paths used for onion messages.
+	Paths []*ReplyPath
+
+	// PayInfo contains the payment parameters for each of the invoice's
+	// blinded paths, and must be set for each entry in Paths.
+	PayInfo []*BlindedPayInfo
+
 	// NodeID is the node ID for the recipient.
 	NodeID *btcec.PublicKey
 
@@ -161,6 +178,11 @@ func (i *Invoice) Validate() error {
 		return ErrDescriptionRequried
 	}
 
+	if len(i.PayInfo) != len(i.Paths) {
+		return fmt.Errorf("%w: %v paths, %v pay info",
+			ErrPayInfoMismatch, len(i.Paths), len(i.PayInfo))
+	}
+
 	// Check that our signature is a valid signature of the merkle root for
 	// the offer.
 	if i.Signature != nil {
@@ -178,6 +200,13 @@ func (i *Invoice) Validate() error {
 	return nil
 }
 
+// CalculateMerkleRoot calculates the tlv merkle root of the invoice's
+// populated fields. This can be used to set the invoice's merkle root before
+// signing it.
+func (i *Invoice) CalculateMerkleRoot() (lntypes.Hash, error) {
+	return calculateMerkleRoot(i)
+}
+
 // records returns a set of tlv records for all the non-nil invoice fields.
 func (i *Invoice) records() ([]tlv.Record, error) {
 	var records []tlv.Record
@@ -206,6 +235,13 @@ func (i *Invoice) records() ([]tlv.Record, error) {
 	}
 
 	if i.Description != "" {
+		err := validateString(
+			"description", i.Description, MaxDescriptionLength,
+		)
+		if err != nil {
+			return nil, err
+		}
+
 		description := []byte(i.Description)
 
 		record := tlv.MakePrimitiveRecord(
@@ -214,7 +250,7 @@ func (i *Invoice) records() ([]tlv.Record, error) {
 		records = append(records, record)
 	}
 
-	featuresRecord, err := encodeFetauresRecord(invFeatType, i.Features)
+	featuresRecord, err := encodeFeaturesRecord(invFeatType, i.Features)
 	if err != nil {
 		return nil, err
 	}
@@ -223,6 +259,15 @@ func (i *Invoice) records() ([]tlv.Record, error) {
 		records = append(records, *featuresRecord)
 	}
 
+	if len(i.Paths) != 0 {
+		records = append(records, pathsRecord(invPathsType, &i.Paths))
+	}
+
+	if len(i.PayInfo) != 0 {
+		record := payInfoRecord(invBlindedPayType, &i.PayInfo)
+		records = append(records, record)
+	}
+
 	if i.NodeID != nil {
 		record := tlv.MakePrimitiveRecord(invNodeIDType, &i.NodeID)
 		records = append(records, record)
@@ -239,6 +284,13 @@ func (i *Invoice) records() ([]tlv.Record, error) {
 	}
 
 	if i.PayerNote != "" {
+		err := validateString(
+			"payer note", i.PayerNote, MaxPayerNoteLength,
+		)
+		if err != nil {
+			return nil, err
+		}
+
 		note := []byte(i.PayerNote)
 
 		record := tlv.MakePrimitiveRecord(invPayerNoteType, &note)
@@ -308,7 +360,7 @@ func EncodeInvoice(i *Invoice) ([]byte, error) {
 }
 
 // DecodeInvoice decodes a bolt12 invoice tlv stream.
-func DecodeInvoice(b []byte) (*Invoice, error) {
+func DecodeInvoice(b []byte, opts ...DecodeOption) (*Invoice, error) {
 	var (
 		i                                = &Invoice{}
 		chainHash, offerID, payHash      [32]byte
@@ -324,6 +376,8 @@ func DecodeInvoice(b []byte) (*Invoice, error) {
 		tu64Record(invAmountType, &amount),
 		tlv.MakePrimitiveRecord(invDescType, &description),
 		tlv.MakePrimitiveRecord(invFeatType, &features),
+		pathsRecord(invPathsType, &i.Paths),
+		payInfoRecord(invBlindedPayType, &i.PayInfo),
 		tlv.MakePrimitiveRecord(invNodeIDType, &i.NodeID),
 		tu64Record(invQuantityType, &i.Quantity),
 		tlv.MakePrimitiveRecord(invPayerKeyType, &i.PayerKey),
@@ -336,6 +390,11 @@ func DecodeInvoice(b []byte) (*Invoice, error) {
 		tlv.MakePrimitiveRecord(invSigType, &signature),
 	}
 
+	err := newDecodeOptions(opts).checkStream(b, records)
+	if err != nil {
+		return nil, err
+	}
+
 	stream, err := tlv.NewStream(records...)
 	if err != nil {
 		return nil, fmt.Errorf("new stream: %w", err)
@@ -344,7 +403,8 @@ func DecodeInvoice(b []byte) (*Invoice, error) {
 	r := bytes.NewReader(b)
 	tlvMap, err := stream.DecodeWithParsedTypes(r)
 	if err != nil {
-		return nil, fmt.Errorf("decode stream: %w", err)
+		return nil, fmt.Errorf("decode stream: %w",
+			annotateDecodeErr(b, records, err))
 	}
 
 	if _, ok := tlvMap[invChainType]; ok {
@@ -373,10 +433,24 @@ func DecodeInvoice(b []byte) (*Invoice, error) {
 
 	if _, ok := tlvMap[invDescType]; ok {
 		i.Description = string(description)
+
+		err := validateString(
+			"description", i.Description, MaxDescriptionLength,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if _, ok := tlvMap[invPayerNoteType]; ok {
 		i.PayerNote = string(payerNote)
+
+		err := validateString(
+			"payer note", i.PayerNote, MaxPayerNoteLength,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if _, ok := tlvMap[invCreatedAtType]; ok {
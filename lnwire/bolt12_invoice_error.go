@@ -0,0 +1,166 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+const (
+	// invErrFieldType is a record for the tlv type of the field that
+	// caused an invoice error.
+	invErrFieldType tlv.Type = 1
+
+	// invErrSuggestedType is a record for a suggested value for the
+	// erroneous field.
+	invErrSuggestedType tlv.Type = 3
+
+	// invErrErrorType is a record for the explanation of the error.
+	invErrErrorType tlv.Type = 5
+)
+
+var (
+	// ErrNoErrorString is returned when an invoice error does not contain
+	// an explanation of the error.
+	ErrNoErrorString = errors.New("invoice error requires error string")
+
+	// ErrSuggestedWithoutField is returned when an invoice error contains a
+	// suggested value without identifying the field that it is for.
+	ErrSuggestedWithoutField = errors.New("suggested value requires " +
+		"erroneous field")
+)
+
+// InvoiceError represents a bolt 12 invoice error, which is sent in response
+// to an invoice request or invoice that could not be handled.
+type InvoiceError struct {
+	// ErroneousField is an optional tlv type of the field in the invoice
+	// request or invoice that caused the error.
+	ErroneousField *tlv.Type
+
+	// SuggestedValue is an optional suggested value for the erroneous
+	// field, which may only be set if ErroneousField is set.
+	SuggestedValue []byte
+
+	// Error is an explanation of the error.
+	Error string
+}
+
+// Validate performs validation on an invoice error as described in the
+// specification.
+func (i *InvoiceError) Validate() error {
+	if i.Error == "" {
+		return ErrNoErrorString
+	}
+
+	if len(i.SuggestedValue) != 0 && i.ErroneousField == nil {
+		return ErrSuggestedWithoutField
+	}
+
+	return validateString("error", i.Error, MaxInvoiceErrorLength)
+}
+
+// records returns a set of tlv records for all the non-nil fields in an
+// invoice error.
+func (i *InvoiceError) records() ([]tlv.Record, error) {
+	var records []tlv.Record
+
+	if i.ErroneousField != nil {
+		field := uint64(*i.ErroneousField)
+
+		record := tu64Record(invErrFieldType, &field)
+		records = append(records, record)
+	}
+
+	if len(i.SuggestedValue) != 0 {
+		record := tlv.MakePrimitiveRecord(
+			invErrSuggestedType, &i.SuggestedValue,
+		)
+		records = append(records, record)
+	}
+
+	if i.Error != "" {
+		err := validateString("error", i.Error, MaxInvoiceErrorLength)
+		if err != nil {
+			return nil, err
+		}
+
+		errStr := []byte(i.Error)
+
+		record := tlv.MakePrimitiveRecord(invErrErrorType, &errStr)
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// EncodeInvoiceError encodes a bolt12 invoice error as a tlv stream.
+func EncodeInvoiceError(i *InvoiceError) ([]byte, error) {
+	records, err := i.records()
+	if err != nil {
+		return nil, fmt.Errorf("%w: invoice error records", err)
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, fmt.Errorf("new stream: %w", err)
+	}
+
+	b := new(bytes.Buffer)
+	if err := stream.Encode(b); err != nil {
+		return nil, fmt.Errorf("encode stream: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// DecodeInvoiceError decodes a bolt12 invoice error tlv stream.
+func DecodeInvoiceError(b []byte,
+	opts ...DecodeOption) (*InvoiceError, error) {
+
+	var (
+		i      = &InvoiceError{}
+		field  uint64
+		errStr []byte
+	)
+
+	records := []tlv.Record{
+		tu64Record(invErrFieldType, &field),
+		tlv.MakePrimitiveRecord(invErrSuggestedType, &i.SuggestedValue),
+		tlv.MakePrimitiveRecord(invErrErrorType, &errStr),
+	}
+
+	err := newDecodeOptions(opts).checkStream(b, records)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, fmt.Errorf("new stream: %w", err)
+	}
+
+	r := bytes.NewReader(b)
+	tlvMap, err := stream.DecodeWithParsedTypes(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode stream: %w",
+			annotateDecodeErr(b, records, err))
+	}
+
+	if _, ok := tlvMap[invErrFieldType]; ok {
+		fieldType := tlv.Type(field)
+		i.ErroneousField = &fieldType
+	}
+
+	if _, ok := tlvMap[invErrErrorType]; ok {
+		i.Error = string(errStr)
+
+		err := validateString("error", i.Error, MaxInvoiceErrorLength)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return i, nil
+}
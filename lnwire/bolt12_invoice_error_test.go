@@ -0,0 +1,116 @@
+package lnwire
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestInvoiceErrorEncoding tests encoding and decoding of bolt 12 invoice
+// errors.
+func TestInvoiceErrorEncoding(t *testing.T) {
+	field := invReqAmountType
+
+	tests := []struct {
+		name    string
+		encoded *InvoiceError
+	}{
+		{
+			name: "error only",
+			encoded: &InvoiceError{
+				Error: "amount too low",
+			},
+		},
+		{
+			name: "erroneous field",
+			encoded: &InvoiceError{
+				ErroneousField: &field,
+				Error:          "amount too low",
+			},
+		},
+		{
+			name: "all fields",
+			encoded: &InvoiceError{
+				ErroneousField: &field,
+				SuggestedValue: []byte{1, 2, 3},
+				Error:          "amount too low",
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			encodedBytes, err := EncodeInvoiceError(
+				testCase.encoded,
+			)
+			require.NoError(t, err, "encode")
+
+			decoded, err := DecodeInvoiceError(encodedBytes)
+			require.NoError(t, err, "decode")
+
+			require.Equal(t, testCase.encoded, decoded)
+		})
+	}
+}
+
+// TestInvoiceErrorValidation tests validation of bolt 12 invoice errors.
+func TestInvoiceErrorValidation(t *testing.T) {
+	field := invReqAmountType
+
+	tests := []struct {
+		name         string
+		invoiceError *InvoiceError
+		err          error
+	}{
+		{
+			name:         "no error string",
+			invoiceError: &InvoiceError{},
+			err:          ErrNoErrorString,
+		},
+		{
+			name: "suggested value without field",
+			invoiceError: &InvoiceError{
+				SuggestedValue: []byte{1},
+				Error:          "error",
+			},
+			err: ErrSuggestedWithoutField,
+		},
+		{
+			name: "error string too long",
+			invoiceError: &InvoiceError{
+				Error: strings.Repeat(
+					"a", MaxInvoiceErrorLength+1,
+				),
+			},
+			err: ErrStringTooLong,
+		},
+		{
+			name: "invalid utf-8",
+			invoiceError: &InvoiceError{
+				Error: string([]byte{0xff}),
+			},
+			err: ErrInvalidUTF8,
+		},
+		{
+			name: "valid",
+			invoiceError: &InvoiceError{
+				ErroneousField: &field,
+				SuggestedValue: []byte{1},
+				Error:          "error",
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.invoiceError.Validate()
+			require.True(t, errors.Is(err, testCase.err))
+		})
+	}
+}
@@ -106,7 +106,12 @@ type InvoiceRequest struct {
 	// PayerNote is a note from the sender.
 	PayerNote string
 
-	// PayerInfo is arbitrary information included by the sender.
+	// PayerInfo is arbitrary information included by the sender. The
+	// draft that we implement has no separate invreq_metadata record, so
+	// this record serves as the request's metadata: it is echoed in the
+	// invoice, and the offers package fills it with a random nonce and
+	// mac (see offers.NewPayerInfo) that also randomizes the request's
+	// merkle root.
 	PayerInfo []byte
 
 	// Signature is an optional signature on the tlv merkle root of the
@@ -124,12 +129,22 @@ var _ tlvTree = (*InvoiceRequest)(nil)
 
 // NewInvoiceRequest returns a new invoice request for the offer provided. This
 // function does not produce a signature for the invoice, but it does calculate
-// its tlv merkle root.
+// its tlv merkle root. Payer info is optional, and may be used by the sender
+// to authenticate invoices that are returned for the request.
 func NewInvoiceRequest(offer *Offer, amount lnwire.MilliSatoshi,
-	quantity uint64, payerKey *btcec.PublicKey, payerNote string) (
-	*InvoiceRequest, error) {
+	quantity uint64, payerKey *btcec.PublicKey, payerNote string,
+	payerInfo []byte) (*InvoiceRequest, error) {
+
+	// If the offer is denominated in a currency, its minimum amount
+	// can't be compared to our millisatoshi amount without conversion,
+	// so we only check that the currency amount is valid.
+	switch {
+	case offer.CurrencyAmount != nil:
+		if err := offer.CurrencyAmount.Validate(); err != nil {
+			return nil, err
+		}
 
-	if amount < offer.MinimumAmount {
+	case amount < offer.MinimumAmount:
 		return nil, fmt.Errorf("%w: %v < %v", ErrBelowMinAmount, amount,
 			offer.MinimumAmount)
 	}
@@ -172,14 +187,11 @@ func NewInvoiceRequest(offer *Offer, amount lnwire.MilliSatoshi,
 		Quantity:  quantity,
 		PayerKey:  payerKey,
 		PayerNote: payerNote,
+		PayerInfo: payerInfo,
 	}
 
-	records, err := request.records()
-	if err != nil {
-		return nil, fmt.Errorf("records: %w", err)
-	}
-
-	request.MerkleRoot, err = MerkleRoot(records)
+	var err error
+	request.MerkleRoot, err = request.CalculateMerkleRoot()
 	if err != nil {
 		return nil, fmt.Errorf("merkle root: %v", err)
 	}
@@ -193,6 +205,13 @@ func (i *InvoiceRequest) SignatureDigest() chainhash.Hash {
 	return signatureDigest(invoiceRequestTag, signatureTag, i.MerkleRoot)
 }
 
+// CalculateMerkleRoot calculates the tlv merkle root of the invoice request's
+// populated fields. This can be used to set the request's merkle root after
+// modifying its fields, before signing it.
+func (i *InvoiceRequest) CalculateMerkleRoot() (lntypes.Hash, error) {
+	return calculateMerkleRoot(i)
+}
+
 // Validate performs validation on an invoice request as described in the
 // specification.
 func (i *InvoiceRequest) Validate() error {
@@ -251,7 +270,7 @@ func (i *InvoiceRequest) records() ([]tlv.Record, error) {
 		records = append(records, record)
 	}
 
-	featuresRecord, err := encodeFetauresRecord(
+	featuresRecord, err := encodeFeaturesRecord(
 		invReqFeaturesType, i.Features,
 	)
 	if err != nil {
@@ -282,6 +301,13 @@ func (i *InvoiceRequest) records() ([]tlv.Record, error) {
 	}
 
 	if i.PayerNote != "" {
+		err := validateString(
+			"payer note", i.PayerNote, MaxPayerNoteLength,
+		)
+		if err != nil {
+			return nil, err
+		}
+
 		note := []byte(i.PayerNote)
 
 		record := tlv.MakePrimitiveRecord(invReqPayerNoteType, &note)
@@ -328,7 +354,9 @@ func EncodeInvoiceRequest(i *InvoiceRequest) ([]byte, error) {
 }
 
 // DecodeInvoiceRequest decodes a bolt12 invoice request tlv stream.
-func DecodeInvoiceRequest(b []byte) (*InvoiceRequest, error) {
+func DecodeInvoiceRequest(b []byte,
+	opts ...DecodeOption) (*InvoiceRequest, error) {
+
 	var (
 		i                            = &InvoiceRequest{}
 		chainHash, offerID, payerKey [32]byte
@@ -349,6 +377,11 @@ func DecodeInvoiceRequest(b []byte) (*InvoiceRequest, error) {
 		tlv.MakePrimitiveRecord(invReqSignatureType, &signature),
 	}
 
+	err := newDecodeOptions(opts).checkStream(b, records)
+	if err != nil {
+		return nil, err
+	}
+
 	stream, err := tlv.NewStream(records...)
 	if err != nil {
 		return nil, fmt.Errorf("new stream: %w", err)
@@ -357,7 +390,8 @@ func DecodeInvoiceRequest(b []byte) (*InvoiceRequest, error) {
 	r := bytes.NewReader(b)
 	tlvMap, err := stream.DecodeWithParsedTypes(r)
 	if err != nil {
-		return nil, fmt.Errorf("decode stream: %w", err)
+		return nil, fmt.Errorf("decode stream: %w",
+			annotateDecodeErr(b, records, err))
 	}
 
 	if _, ok := tlvMap[invReqChainType]; ok {
@@ -398,6 +432,13 @@ func DecodeInvoiceRequest(b []byte) (*InvoiceRequest, error) {
 
 	if _, ok := tlvMap[invReqPayerNoteType]; ok {
 		i.PayerNote = string(payerNote)
+
+		err := validateString(
+			"payer note", i.PayerNote, MaxPayerNoteLength,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if _, ok := tlvMap[invReqSignatureType]; ok {
@@ -112,6 +112,25 @@ func TestInvoiceRequestEncoding(t *testing.T) {
 				Signature: &sig,
 			},
 		},
+		{
+			name: "all fields",
+			encoded: &InvoiceRequest{
+				Chainhash: hash,
+				OfferID:   hash,
+				Amount:    lnwire.MilliSatoshi(1000),
+				Features: lnwire.NewFeatureVector(
+					lnwire.NewRawFeatureVector(
+						lnwire.AMPOptional,
+					),
+					lnwire.Features,
+				),
+				Quantity:  2,
+				PayerKey:  xOnlyPubkey,
+				PayerNote: "note",
+				PayerInfo: []byte{1, 2, 3},
+				Signature: &sig,
+			},
+		},
 	}
 
 	for _, testCase := range tests {
@@ -123,10 +142,9 @@ func TestInvoiceRequestEncoding(t *testing.T) {
 			// it for each test case), so that we can use
 			// require.Equal to compare it to the decoded invoice
 			// (which has its merkle root calculated on decode).
-			records, err := testCase.encoded.records()
-			require.NoError(t, err, "records")
-
-			testCase.encoded.MerkleRoot, err = MerkleRoot(records)
+			var err error
+			testCase.encoded.MerkleRoot, err =
+				testCase.encoded.CalculateMerkleRoot()
 			require.NoError(t, err, "merkle root")
 
 			encodedBytes, err := EncodeInvoiceRequest(
@@ -296,6 +314,19 @@ func TestNewInvoiceRequest(t *testing.T) {
 		err       error
 		expected  *InvoiceRequest
 	}{
+		{
+			name: "invalid currency offer",
+			offer: &Offer{
+				CurrencyAmount: &CurrencyAmount{
+					Code:     "USD",
+					Exponent: 2,
+				},
+				Description: "offer description",
+				NodeID:      pubkeys[0],
+			},
+			amount: 100,
+			err:    ErrCurrencyAmountRequired,
+		},
 		{
 			name:   "amount too small",
 			offer:  offer,
@@ -349,7 +380,7 @@ func TestNewInvoiceRequest(t *testing.T) {
 			actual, err := NewInvoiceRequest(
 				testCase.offer, testCase.amount,
 				testCase.quantity, testCase.payerKey,
-				testCase.payerNote,
+				testCase.payerNote, nil,
 			)
 			require.True(t, errors.Is(err, testCase.err))
 			require.Equal(t, testCase.expected, actual)
@@ -17,11 +17,64 @@ import (
 // where a field is not included.
 func TestInvoiceEncoding(t *testing.T) {
 	var (
-		pubkey = testutils.GetPubkeys(t, 1)[0]
+		pubkeys = testutils.GetPubkeys(t, 3)
+		pubkey  = pubkeys[0]
 
 		hash lntypes.Hash
 
 		sig [64]byte
+
+		paths = []*ReplyPath{
+			{
+				FirstNodeID:   pubkeys[1],
+				BlindingPoint: pubkeys[2],
+				Hops: []*BlindedHop{
+					{
+						BlindedNodeID: pubkeys[1],
+						EncryptedData: []byte{1, 2},
+					},
+					{
+						BlindedNodeID: pubkeys[2],
+						EncryptedData: []byte{3},
+					},
+				},
+			},
+			{
+				FirstNodeID:   pubkeys[2],
+				BlindingPoint: pubkeys[1],
+				Hops: []*BlindedHop{
+					{
+						BlindedNodeID: pubkeys[2],
+						EncryptedData: []byte{4},
+					},
+				},
+			},
+		}
+
+		payInfo = []*BlindedPayInfo{
+			{
+				FeeBaseMsat:               1000,
+				FeeProportionalMillionths: 10,
+				CLTVExpiryDelta:           144,
+				HTLCMinimum:               1,
+				HTLCMaximum:               100000,
+				Features: lnwire.NewFeatureVector(
+					lnwire.NewRawFeatureVector(),
+					lnwire.Features,
+				),
+			},
+			{
+				FeeBaseMsat:     2000,
+				CLTVExpiryDelta: 40,
+				HTLCMaximum:     5000,
+				Features: lnwire.NewFeatureVector(
+					lnwire.NewRawFeatureVector(
+						lnwire.AMPOptional,
+					),
+					lnwire.Features,
+				),
+			},
+		}
 	)
 
 	copy(hash[:], []byte{1, 2, 3})
@@ -78,6 +131,18 @@ func TestInvoiceEncoding(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "payment paths",
+			encoded: &Invoice{
+				Paths: paths,
+			},
+		},
+		{
+			name: "blinded pay info",
+			encoded: &Invoice{
+				PayInfo: payInfo,
+			},
+		},
 		{
 			name: "node id",
 			encoded: &Invoice{
@@ -143,6 +208,35 @@ func TestInvoiceEncoding(t *testing.T) {
 				Signature: &sig,
 			},
 		},
+		{
+			name: "all fields",
+			encoded: &Invoice{
+				Chainhash:   hash,
+				OfferID:     hash,
+				Amount:      lnwire.MilliSatoshi(1000),
+				Description: "inv description",
+				Features: lnwire.NewFeatureVector(
+					lnwire.NewRawFeatureVector(
+						lnwire.AMPOptional,
+					),
+					lnwire.Features,
+				),
+				Paths:     paths,
+				PayInfo:   payInfo,
+				NodeID:    pubkey,
+				Quantity:  2,
+				PayerKey:  pubkey,
+				PayerNote: "note",
+				CreatedAt: time.Date(
+					2022, 01, 01, 0, 0, 0, 0, time.Local,
+				),
+				PaymentHash:    hash,
+				RelativeExpiry: time.Second * 20,
+				CLTVExpiry:     10,
+				PayerInfo:      []byte{1, 2, 3},
+				Signature:      &sig,
+			},
+		},
 	}
 
 	for _, testCase := range tests {
@@ -154,10 +248,9 @@ func TestInvoiceEncoding(t *testing.T) {
 			// for each test case), so that we can use require.Equal
 			// to compare it to the decoded invoice (which has its
 			// merkle root calculated on decode).
-			records, err := testCase.encoded.records()
-			require.NoError(t, err, "records")
-
-			testCase.encoded.MerkleRoot, err = MerkleRoot(records)
+			var err error
+			testCase.encoded.MerkleRoot, err =
+				testCase.encoded.CalculateMerkleRoot()
 			require.NoError(t, err, "merkle root")
 
 			encodedBytes, err := EncodeInvoice(testCase.encoded)
@@ -255,6 +348,23 @@ func TestInvoiceValidation(t *testing.T) {
 			},
 			err: ErrDescriptionRequried,
 		},
+		{
+			name: "pay info mismatch",
+			invoice: &Invoice{
+				Amount:      lnwire.MilliSatoshi(1),
+				PaymentHash: hash,
+				CreatedAt:   created,
+				NodeID:      pubkey,
+				Description: "invoice",
+				Paths: []*ReplyPath{
+					{
+						FirstNodeID:   pubkey,
+						BlindingPoint: pubkey,
+					},
+				},
+			},
+			err: ErrPayInfoMismatch,
+		},
 		{
 			name: "invalid signature",
 			invoice: &Invoice{
@@ -0,0 +1,417 @@
+package lnwire
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/lightningnetwork/lnd/lntypes"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+)
+
+// offerJSON is the json representation of an offer. Fields that are not set
+// in the offer are omitted. Hashes, keys and signatures are hex encoded, node
+// IDs are expressed as 33 byte compressed pubkeys and features are expressed
+// as a sorted list of the feature bits that are set. The minimum amount is
+// expressed in millisatoshis, or in the minor unit of the offer's currency
+// if one is set.
+type offerJSON struct {
+	Chainhash   string       `json:"chain_hash,omitempty"`
+	Metadata    string       `json:"metadata,omitempty"`
+	Currency    string       `json:"currency,omitempty"`
+	MinAmount   uint64       `json:"min_amount,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Features    []uint16     `json:"features,omitempty"`
+	Expiry      int64        `json:"expiry_unix_seconds,omitempty"`
+	Paths       []*ReplyPath `json:"paths,omitempty"`
+	Issuer      string       `json:"issuer,omitempty"`
+	QuantityMin uint64       `json:"min_quantity,omitempty"`
+	QuantityMax uint64       `json:"max_quantity,omitempty"`
+	NodeID      string       `json:"node_id,omitempty"`
+	Signature   string       `json:"signature,omitempty"`
+	MerkleRoot  string       `json:"merkle_root,omitempty"`
+}
+
+// MarshalJSON produces the json representation of an offer.
+func (o Offer) MarshalJSON() ([]byte, error) {
+	offer := &offerJSON{
+		Chainhash:   hashToJSON(o.Chainhash),
+		Metadata:    hex.EncodeToString(o.Metadata),
+		MinAmount:   uint64(o.MinimumAmount),
+		Description: o.Description,
+		Features:    featuresToJSON(o.Features),
+		Paths:       o.Paths,
+		Issuer:      o.Issuer,
+		QuantityMin: o.QuantityMin,
+		QuantityMax: o.QuantityMax,
+		NodeID:      pubkeyToJSON(o.NodeID),
+		Signature:   sigToJSON(o.Signature),
+		MerkleRoot:  hashToJSON(o.MerkleRoot),
+	}
+
+	if o.CurrencyAmount != nil {
+		offer.Currency = o.CurrencyAmount.Code
+		offer.MinAmount = o.CurrencyAmount.Minor
+	}
+
+	if !o.Expiry.IsZero() {
+		offer.Expiry = o.Expiry.Unix()
+	}
+
+	return json.Marshal(offer)
+}
+
+// UnmarshalJSON populates an offer from its json representation.
+func (o *Offer) UnmarshalJSON(b []byte) error {
+	var offer offerJSON
+	if err := json.Unmarshal(b, &offer); err != nil {
+		return err
+	}
+
+	var err error
+	decoded := Offer{
+		Description: offer.Description,
+		Features:    featuresFromJSON(offer.Features),
+		Paths:       offer.Paths,
+		Issuer:      offer.Issuer,
+		QuantityMin: offer.QuantityMin,
+		QuantityMax: offer.QuantityMax,
+	}
+
+	if offer.Currency != "" {
+		decoded.CurrencyAmount, err = decodeCurrencyAmount(
+			[]byte(offer.Currency), offer.MinAmount,
+		)
+		if err != nil {
+			return err
+		}
+	} else {
+		decoded.MinimumAmount = lndwire.MilliSatoshi(offer.MinAmount)
+	}
+
+	if offer.Expiry != 0 {
+		decoded.Expiry = time.Unix(offer.Expiry, 0)
+	}
+
+	if offer.Metadata != "" {
+		decoded.Metadata, err = hex.DecodeString(offer.Metadata)
+		if err != nil {
+			return fmt.Errorf("metadata: %w", err)
+		}
+	}
+
+	decoded.Chainhash, err = hashFromJSON("chain hash", offer.Chainhash)
+	if err != nil {
+		return err
+	}
+
+	decoded.NodeID, err = pubkeyFromJSON("node id", offer.NodeID)
+	if err != nil {
+		return err
+	}
+
+	decoded.Signature, err = sigFromJSON(offer.Signature)
+	if err != nil {
+		return err
+	}
+
+	decoded.MerkleRoot, err = hashFromJSON("merkle root", offer.MerkleRoot)
+	if err != nil {
+		return err
+	}
+
+	*o = decoded
+
+	return nil
+}
+
+// invoiceJSON is the json representation of an invoice, using the same
+// conventions as offerJSON. Relative expiry is expressed in seconds, and
+// blinded paths use the json representation of reply paths.
+type invoiceJSON struct {
+	Chainhash      string            `json:"chain_hash,omitempty"`
+	OfferID        string            `json:"offer_id,omitempty"`
+	Amount         uint64            `json:"amount_msat,omitempty"`
+	Description    string            `json:"description,omitempty"`
+	Features       []uint16          `json:"features,omitempty"`
+	NodeID         string            `json:"node_id,omitempty"`
+	Quantity       uint64            `json:"quantity,omitempty"`
+	PayerKey       string            `json:"payer_key,omitempty"`
+	PayerNote      string            `json:"payer_note,omitempty"`
+	CreatedAt      int64             `json:"created_at_unix_seconds,omitempty"`
+	PaymentHash    string            `json:"payment_hash,omitempty"`
+	RelativeExpiry uint64            `json:"relative_expiry_seconds,omitempty"`
+	CLTVExpiry     uint64            `json:"min_final_cltv_expiry,omitempty"`
+	Paths          []*ReplyPath      `json:"paths,omitempty"`
+	PayInfo        []*BlindedPayInfo `json:"blindedpay,omitempty"`
+	PayerInfo      string            `json:"payer_info,omitempty"`
+	Signature      string            `json:"signature,omitempty"`
+	MerkleRoot     string            `json:"merkle_root,omitempty"`
+}
+
+// MarshalJSON produces the json representation of an invoice.
+func (i Invoice) MarshalJSON() ([]byte, error) {
+	invoice := &invoiceJSON{
+		Chainhash:      hashToJSON(i.Chainhash),
+		OfferID:        hashToJSON(i.OfferID),
+		Amount:         uint64(i.Amount),
+		Description:    i.Description,
+		Features:       featuresToJSON(i.Features),
+		NodeID:         pubkeyToJSON(i.NodeID),
+		Quantity:       i.Quantity,
+		PayerKey:       pubkeyToJSON(i.PayerKey),
+		PayerNote:      i.PayerNote,
+		PaymentHash:    hashToJSON(i.PaymentHash),
+		RelativeExpiry: uint64(i.RelativeExpiry.Seconds()),
+		CLTVExpiry:     i.CLTVExpiry,
+		Paths:          i.Paths,
+		PayInfo:        i.PayInfo,
+		PayerInfo:      hex.EncodeToString(i.PayerInfo),
+		Signature:      sigToJSON(i.Signature),
+		MerkleRoot:     hashToJSON(i.MerkleRoot),
+	}
+
+	if !i.CreatedAt.IsZero() {
+		invoice.CreatedAt = i.CreatedAt.Unix()
+	}
+
+	return json.Marshal(invoice)
+}
+
+// UnmarshalJSON populates an invoice from its json representation.
+func (i *Invoice) UnmarshalJSON(b []byte) error {
+	var invoice invoiceJSON
+	if err := json.Unmarshal(b, &invoice); err != nil {
+		return err
+	}
+
+	var err error
+	decoded := Invoice{
+		Amount:      lndwire.MilliSatoshi(invoice.Amount),
+		Description: invoice.Description,
+		Features:    featuresFromJSON(invoice.Features),
+		Quantity:    invoice.Quantity,
+		PayerNote:   invoice.PayerNote,
+		RelativeExpiry: time.Duration(invoice.RelativeExpiry) *
+			time.Second,
+		CLTVExpiry: invoice.CLTVExpiry,
+		Paths:      invoice.Paths,
+		PayInfo:    invoice.PayInfo,
+	}
+
+	if invoice.CreatedAt != 0 {
+		decoded.CreatedAt = time.Unix(invoice.CreatedAt, 0)
+	}
+
+	if invoice.PayerInfo != "" {
+		decoded.PayerInfo, err = hex.DecodeString(invoice.PayerInfo)
+		if err != nil {
+			return fmt.Errorf("payer info: %w", err)
+		}
+	}
+
+	decoded.Chainhash, err = hashFromJSON("chain hash", invoice.Chainhash)
+	if err != nil {
+		return err
+	}
+
+	decoded.OfferID, err = hashFromJSON("offer id", invoice.OfferID)
+	if err != nil {
+		return err
+	}
+
+	decoded.PaymentHash, err = hashFromJSON(
+		"payment hash", invoice.PaymentHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.MerkleRoot, err = hashFromJSON(
+		"merkle root", invoice.MerkleRoot,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.NodeID, err = pubkeyFromJSON("node id", invoice.NodeID)
+	if err != nil {
+		return err
+	}
+
+	decoded.PayerKey, err = pubkeyFromJSON("payer key", invoice.PayerKey)
+	if err != nil {
+		return err
+	}
+
+	decoded.Signature, err = sigFromJSON(invoice.Signature)
+	if err != nil {
+		return err
+	}
+
+	*i = decoded
+
+	return nil
+}
+
+// blindedPayInfoJSON is the json representation of the payment parameters
+// for a blinded path, with features expressed as a sorted list of the feature
+// bits that are set.
+type blindedPayInfoJSON struct {
+	FeeBaseMsat               uint32               `json:"fee_base_msat"`
+	FeeProportionalMillionths uint32               `json:"fee_proportional_millionths"`
+	CLTVExpiryDelta           uint16               `json:"cltv_expiry_delta"`
+	HTLCMinimum               lndwire.MilliSatoshi `json:"htlc_minimum_msat"`
+	HTLCMaximum               lndwire.MilliSatoshi `json:"htlc_maximum_msat"`
+	Features                  []uint16             `json:"features,omitempty"`
+}
+
+// MarshalJSON produces the json representation of blinded pay info.
+func (b BlindedPayInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&blindedPayInfoJSON{
+		FeeBaseMsat:               b.FeeBaseMsat,
+		FeeProportionalMillionths: b.FeeProportionalMillionths,
+		CLTVExpiryDelta:           b.CLTVExpiryDelta,
+		HTLCMinimum:               b.HTLCMinimum,
+		HTLCMaximum:               b.HTLCMaximum,
+		Features:                  featuresToJSON(b.Features),
+	})
+}
+
+// UnmarshalJSON populates blinded pay info from its json representation.
+func (b *BlindedPayInfo) UnmarshalJSON(data []byte) error {
+	var payInfo blindedPayInfoJSON
+	if err := json.Unmarshal(data, &payInfo); err != nil {
+		return err
+	}
+
+	*b = BlindedPayInfo{
+		FeeBaseMsat:               payInfo.FeeBaseMsat,
+		FeeProportionalMillionths: payInfo.FeeProportionalMillionths,
+		CLTVExpiryDelta:           payInfo.CLTVExpiryDelta,
+		HTLCMinimum:               payInfo.HTLCMinimum,
+		HTLCMaximum:               payInfo.HTLCMaximum,
+		Features:                  featuresFromJSON(payInfo.Features),
+	}
+
+	return nil
+}
+
+// hashToJSON hex encodes a hash, returning an empty string if it is not set.
+func hashToJSON(hash lntypes.Hash) string {
+	if hash == lntypes.ZeroHash {
+		return ""
+	}
+
+	return hash.String()
+}
+
+// hashFromJSON decodes a hex encoded hash, returning the zero hash if the
+// string is empty.
+func hashFromJSON(field, hashStr string) (lntypes.Hash, error) {
+	if hashStr == "" {
+		return lntypes.ZeroHash, nil
+	}
+
+	hash, err := lntypes.MakeHashFromStr(hashStr)
+	if err != nil {
+		return lntypes.ZeroHash, fmt.Errorf("%v: %w", field, err)
+	}
+
+	return hash, nil
+}
+
+// pubkeyToJSON hex encodes a compressed pubkey, returning an empty string if
+// the key is nil.
+func pubkeyToJSON(pubkey *btcec.PublicKey) string {
+	if pubkey == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(pubkey.SerializeCompressed())
+}
+
+// pubkeyFromJSON decodes a hex encoded compressed pubkey, returning nil if the
+// string is empty.
+func pubkeyFromJSON(field, pubkeyStr string) (*btcec.PublicKey, error) {
+	if pubkeyStr == "" {
+		return nil, nil
+	}
+
+	pubkeyBytes, err := hex.DecodeString(pubkeyStr)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", field, err)
+	}
+
+	pubkey, err := btcec.ParsePubKey(pubkeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", field, err)
+	}
+
+	return pubkey, nil
+}
+
+// sigToJSON hex encodes a signature, returning an empty string if it is nil.
+func sigToJSON(sig *[64]byte) string {
+	if sig == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(sig[:])
+}
+
+// sigFromJSON decodes a hex encoded signature, returning nil if the string is
+// empty.
+func sigFromJSON(sigStr string) (*[64]byte, error) {
+	if sigStr == "" {
+		return nil, nil
+	}
+
+	sigBytes, err := hex.DecodeString(sigStr)
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+
+	if len(sigBytes) != 64 {
+		return nil, fmt.Errorf("signature: %w: %v bytes",
+			ErrInvalidSig, len(sigBytes))
+	}
+
+	var sig [64]byte
+	copy(sig[:], sigBytes)
+
+	return &sig, nil
+}
+
+// featuresToJSON returns the sorted set of feature bits that are set in a
+// feature vector.
+func featuresToJSON(features *lndwire.FeatureVector) []uint16 {
+	if features == nil {
+		return nil
+	}
+
+	var bits []uint16
+	for bit := range features.Features() {
+		bits = append(bits, uint16(bit))
+	}
+
+	sort.Slice(bits, func(i, j int) bool {
+		return bits[i] < bits[j]
+	})
+
+	return bits
+}
+
+// featuresFromJSON creates a feature vector from a set of feature bits. An
+// empty vector is returned if no bits are set, matching our tlv decoding.
+func featuresFromJSON(bits []uint16) *lndwire.FeatureVector {
+	rawFeatures := lndwire.NewRawFeatureVector()
+	for _, bit := range bits {
+		rawFeatures.Set(lndwire.FeatureBit(bit))
+	}
+
+	return lndwire.NewFeatureVector(rawFeatures, lndwire.Features)
+}
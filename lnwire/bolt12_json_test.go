@@ -0,0 +1,164 @@
+package lnwire
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/lntypes"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOfferJSON tests json encoding of offers.
+func TestOfferJSON(t *testing.T) {
+	pubkey := testutils.GetPubkeys(t, 1)[0]
+	sig := [64]byte{1, 2, 3}
+
+	offer := &Offer{
+		Chainhash:     lntypes.Hash{1},
+		Metadata:      []byte{5, 6},
+		MinimumAmount: 1000,
+		Description:   "description",
+		Features: lndwire.NewFeatureVector(
+			lndwire.NewRawFeatureVector(
+				lndwire.TLVOnionPayloadRequired,
+			),
+			lndwire.Features,
+		),
+		Expiry: time.Unix(900, 0),
+		Paths: []*ReplyPath{
+			{
+				FirstNodeID:   pubkey,
+				BlindingPoint: pubkey,
+				Hops: []*BlindedHop{
+					{
+						BlindedNodeID: pubkey,
+						EncryptedData: []byte{7},
+					},
+				},
+			},
+		},
+		Issuer:      "issuer",
+		QuantityMin: 1,
+		QuantityMax: 2,
+		NodeID:      pubkey,
+		Signature:   &sig,
+		MerkleRoot:  lntypes.Hash{2},
+	}
+
+	offerJSON, err := json.Marshal(offer)
+	require.NoError(t, err, "marshal")
+
+	decoded := &Offer{}
+	require.NoError(t, json.Unmarshal(offerJSON, decoded), "unmarshal")
+	require.Equal(t, offer, decoded)
+
+	// Currency offers express their amount in the currency's minor unit.
+	currencyOffer := &Offer{
+		CurrencyAmount: &CurrencyAmount{
+			Code:     "JPY",
+			Minor:    500,
+			Exponent: 0,
+		},
+		Description: "a",
+		Features:    lndwire.EmptyFeatureVector(),
+	}
+
+	currencyJSON, err := json.Marshal(currencyOffer)
+	require.NoError(t, err, "marshal currency")
+	require.Equal(
+		t, `{"currency":"JPY","min_amount":500,"description":"a"}`,
+		string(currencyJSON),
+	)
+
+	decoded = &Offer{}
+	require.NoError(t, json.Unmarshal(currencyJSON, decoded))
+	require.Equal(t, currencyOffer, decoded)
+
+	// Check that unset fields are omitted from our json, and that an empty
+	// offer is decoded with an empty feature vector.
+	emptyJSON, err := json.Marshal(&Offer{Description: "a"})
+	require.NoError(t, err, "marshal empty")
+	require.Equal(t, `{"description":"a"}`, string(emptyJSON))
+
+	require.NoError(t, json.Unmarshal(emptyJSON, decoded), "unmarshal")
+	require.True(t, decoded.Features.IsEmpty())
+
+	// Invalid values should fail.
+	for _, invalid := range []string{
+		`{"node_id":"02"}`,
+		`{"chain_hash":"zz"}`,
+		`{"signature":"0102"}`,
+		`{"currency":"BTC","min_amount":1}`,
+	} {
+		require.Error(t, json.Unmarshal([]byte(invalid), decoded),
+			invalid)
+	}
+}
+
+// TestInvoiceJSON tests json encoding of invoices.
+func TestInvoiceJSON(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 2)
+	sig := [64]byte{1, 2, 3}
+
+	path := &ReplyPath{
+		FirstNodeID:   pubkeys[0],
+		BlindingPoint: pubkeys[1],
+		Hops: []*BlindedHop{
+			{
+				BlindedNodeID: pubkeys[1],
+				EncryptedData: []byte{7, 8},
+			},
+		},
+	}
+
+	payInfo := &BlindedPayInfo{
+		FeeBaseMsat:               1,
+		FeeProportionalMillionths: 2,
+		CLTVExpiryDelta:           3,
+		HTLCMinimum:               4,
+		HTLCMaximum:               5,
+		Features: lndwire.NewFeatureVector(
+			lndwire.NewRawFeatureVector(lndwire.AMPOptional),
+			lndwire.Features,
+		),
+	}
+
+	invoice := &Invoice{
+		Chainhash:   lntypes.Hash{1},
+		OfferID:     lntypes.Hash{2},
+		Amount:      1000,
+		Description: "description",
+		Features: lndwire.NewFeatureVector(
+			lndwire.NewRawFeatureVector(), lndwire.Features,
+		),
+		NodeID:         pubkeys[0],
+		Quantity:       3,
+		PayerKey:       pubkeys[1],
+		PayerNote:      "note",
+		CreatedAt:      time.Unix(100, 0),
+		PaymentHash:    lntypes.Hash{3},
+		RelativeExpiry: time.Hour,
+		CLTVExpiry:     40,
+		Paths:          []*ReplyPath{path},
+		PayInfo:        []*BlindedPayInfo{payInfo},
+		PayerInfo:      []byte{4, 5, 6},
+		Signature:      &sig,
+		MerkleRoot:     lntypes.Hash{4},
+	}
+
+	invoiceJSON, err := json.Marshal(invoice)
+	require.NoError(t, err, "marshal")
+	require.Contains(t, string(invoiceJSON), `"paths":`)
+	require.Contains(t, string(invoiceJSON), `"blindedpay":`)
+
+	decoded := &Invoice{}
+	require.NoError(t, json.Unmarshal(invoiceJSON, decoded), "unmarshal")
+	require.Equal(t, invoice, decoded)
+
+	require.Error(t, json.Unmarshal(
+		[]byte(`{"payer_info":"zz"}`), decoded,
+	))
+}
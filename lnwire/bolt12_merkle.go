@@ -77,8 +77,36 @@ type TLVBranch struct {
 //
 // Note that this function assumes that left <= right
 func (t *TLVBranch) TaggedHash() chainhash.Hash {
-	msg := append(t.left[:], t.right[:]...)
-	return *chainhash.TaggedHash(BranchTag, msg)
+	return *chainhash.TaggedHash(BranchTag, t.left[:], t.right[:])
+}
+
+// LeafHash returns the tagged hash of the leaf for an encoded tlv record:
+// H("LnLeaf", tlv).
+func LeafHash(tlv []byte) chainhash.Hash {
+	return *chainhash.TaggedHash(TLVTag, tlv)
+}
+
+// NonceHash returns the tagged hash of the nonce leaf for an encoded tlv
+// record, where allTLVs is the concatenation of all the non-signature
+// records in the stream: H("LnAll" || all_tlvs, tlv).
+func NonceHash(allTLVs, tlv []byte) chainhash.Hash {
+	return *chainhash.TaggedHash(nonceTag(allTLVs), tlv)
+}
+
+// BranchHash returns the tagged hash of a branch that combines two nodes in
+// the tree, ordering the two hashes so that the lesser hash comes first:
+// H("LnBranch", lesser || greater).
+func BranchHash(a, b chainhash.Hash) chainhash.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+
+	branch := &TLVBranch{
+		left:  a,
+		right: b,
+	}
+
+	return branch.TaggedHash()
 }
 
 // TLVLeaf represents a leaf in our offer merkle tree.
@@ -241,13 +269,63 @@ func createLeafPair(tlv, allTLVs []byte) (*TLVLeaf, *TLVLeaf) {
 	}
 
 	nonceLeaf := &TLVLeaf{
-		Tag:   append(NonceTag, allTLVs...),
+		Tag:   nonceTag(allTLVs),
 		Value: tlv,
 	}
 
 	return tlvLeaf, nonceLeaf
 }
 
+// nonceTag returns the tag used for nonce leaves in a tree containing the
+// concatenated tlvs provided. We copy the tag into a new slice rather than
+// appending to NonceTag directly, which may share its backing array across
+// calls if it has spare capacity.
+func nonceTag(allTLVs []byte) []byte {
+	tag := make([]byte, 0, len(NonceTag)+len(allTLVs))
+	tag = append(tag, NonceTag...)
+
+	return append(tag, allTLVs...)
+}
+
+// StreamMerkleRoot calculates the tlv merkle root for a raw tlv stream,
+// using the records exactly as they were encoded by the sender. This can be
+// used to calculate the root of a stream that contains records that we can't
+// decode.
+func StreamMerkleRoot(streamBytes []byte) (lntypes.Hash, error) {
+	if err := checkRecordLengths(streamBytes); err != nil {
+		return lntypes.ZeroHash, err
+	}
+
+	var (
+		r       = bytes.NewReader(streamBytes)
+		records []tlv.Record
+		b       [8]byte
+	)
+
+	for r.Len() > 0 {
+		tlvType, err := tlv.ReadVarInt(r, &b)
+		if err != nil {
+			return lntypes.ZeroHash, fmt.Errorf("read type: %w", err)
+		}
+
+		length, err := tlv.ReadVarInt(r, &b)
+		if err != nil {
+			return lntypes.ZeroHash, fmt.Errorf("read length: %w",
+				err)
+		}
+
+		value := make([]byte, length)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return lntypes.ZeroHash, fmt.Errorf("read value: %w",
+				err)
+		}
+
+		records = append(records, rawRecord(tlv.Type(tlvType), value))
+	}
+
+	return MerkleRoot(records)
+}
+
 // isSignatureTLV returns a boolean indicating whether a TLV contains a
 // signature.
 func isSignatureTLV(record tlv.Record) bool {
@@ -295,24 +373,25 @@ func unknownRecordsFromParsed(parsedTypes map[tlv.Type][]byte) []tlv.Record {
 			continue
 		}
 
-		// Create a record that just writes whatever bytes we have for
-		// the TLV value. Any encoding specifics will be included in
-		// these bytes, because we've just read them straight out of the
-		// tlv stream.
-		encode := func(w io.Writer, _ interface{}, _ *[8]byte) error {
-			_, err := w.Write(tlvBytes)
-			return err
-		}
+		records = append(records, rawRecord(tlvType, tlvBytes))
+	}
 
-		// Create a static record with encoding capabilities, we don't
-		// include a decode function because we don't need one to
-		// calculate our merkle root.
-		record := tlv.MakeStaticRecord(
-			tlvType, tlvBytes, uint64(len(tlvBytes)), encode, nil,
-		)
+	return records
+}
 
-		records = append(records, record)
+// rawRecord creates a record that just writes whatever bytes we have for the
+// TLV value. Any encoding specifics will be included in these bytes, because
+// they have been read straight out of a tlv stream.
+func rawRecord(tlvType tlv.Type, tlvBytes []byte) tlv.Record {
+	encode := func(w io.Writer, _ interface{}, _ *[8]byte) error {
+		_, err := w.Write(tlvBytes)
+		return err
 	}
 
-	return records
+	// Create a static record with encoding capabilities, we don't include
+	// a decode function because we don't need one to calculate our merkle
+	// root.
+	return tlv.MakeStaticRecord(
+		tlvType, tlvBytes, uint64(len(tlvBytes)), encode, nil,
+	)
 }
@@ -524,3 +524,86 @@ func TestMerkleRoot(t *testing.T) {
 		})
 	}
 }
+
+// TestTaggedHashes tests that our exported tagged hash helpers match the
+// hashes produced by the nodes in our tree.
+func TestTaggedHashes(t *testing.T) {
+	var (
+		tlv1    = []byte{1, 1, 1}
+		tlv2    = []byte{2, 1, 2}
+		allTLVs = append(append([]byte{}, tlv1...), tlv2...)
+	)
+
+	tlvLeaf, nonceLeaf := createLeafPair(tlv1, allTLVs)
+	require.Equal(t, tlvLeaf.TaggedHash(), LeafHash(tlv1))
+	require.Equal(t, nonceLeaf.TaggedHash(), NonceHash(allTLVs, tlv1))
+
+	// Our branch hash should be the same regardless of the order that
+	// its nodes are provided in.
+	leafHash, nonceHash := LeafHash(tlv1), NonceHash(allTLVs, tlv1)
+	left, right := orderNodes(tlvLeaf, nonceLeaf)
+
+	branch := &TLVBranch{
+		left:  left,
+		right: right,
+	}
+
+	require.Equal(t, branch.TaggedHash(), BranchHash(leafHash, nonceHash))
+	require.Equal(t, branch.TaggedHash(), BranchHash(nonceHash, leafHash))
+
+	// Creating a nonce leaf for a different stream should not alter the
+	// tag of the nonce leaf that we've already created.
+	_, otherNonce := createLeafPair(tlv2, tlv2)
+	require.Equal(t, append([]byte("LnAll"), allTLVs...), nonceLeaf.Tag)
+	require.Equal(t, append([]byte("LnAll"), tlv2...), otherNonce.Tag)
+}
+
+// TestStreamMerkleRoot tests calculation of merkle roots directly from a raw
+// tlv stream.
+func TestStreamMerkleRoot(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		merkle string
+		err    error
+	}{
+		{
+			name:   "single tlv",
+			stream: "010203e8",
+			merkle: "aa0aa0f694c85492ac459c1de9831a37682985f5e840ecc9b1e28eece7dc5236",
+		},
+		{
+			name:   "two tlvs",
+			stream: "010203e802080000010000020003",
+			merkle: "013b756ed73554cbc4dd3d90f363cb7cba6d8a279465a21c464e582b173ff502",
+		},
+		{
+			name:   "truncated stream",
+			stream: "010303e8",
+			err:    ErrRecordTooLarge,
+		},
+		{
+			name: "no tlvs",
+			err:  ErrNoTLVs,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			stream, err := hex.DecodeString(testCase.stream)
+			require.NoError(t, err)
+
+			root, err := StreamMerkleRoot(stream)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err != nil {
+				return
+			}
+
+			actual := hex.EncodeToString(root[:])
+			require.Equal(t, testCase.merkle, actual)
+		})
+	}
+}
@@ -18,6 +18,14 @@ const (
 	// an offer is for.
 	chainType tlv.Type = 2
 
+	// offerMetadataType is a record type for opaque metadata that the
+	// offer's creator includes for its own use.
+	offerMetadataType tlv.Type = 4
+
+	// currencyType is a record type for the ISO-4217 currency code that an
+	// offer's amount is expressed in.
+	currencyType tlv.Type = 6
+
 	// amountType is a record type specifying the minimum amount for an
 	// offer.
 	amountType tlv.Type = 8
@@ -31,6 +39,10 @@ const (
 	// expiryType is a record type for offer expiry time.
 	expiryType tlv.Type = 14
 
+	// offerPathsType is a record type for the blinded paths that can be
+	// used to reach the offer's creator.
+	offerPathsType tlv.Type = 16
+
 	// issuerType is a record type for identifying the issuer of an offer.
 	issuerType tlv.Type = 20
 
@@ -51,8 +63,8 @@ const (
 
 var (
 	// ErrNodeIDRequired is returned when a node pubkey is not provided
-	// for an offer. Note that when blinded paths are supported, we can
-	// relax this requirement.
+	// for an offer. Offers may omit their node pubkey if they provide
+	// blinded paths to reach their creator instead.
 	ErrNodeIDRequired = errors.New("node pubkey required for offer")
 
 	// ErrQuantityRange is returned when we get an min/max quantity range
@@ -70,9 +82,21 @@ type Offer struct {
 	// for.
 	Chainhash lntypes.Hash
 
-	// MinimumAmount is an optional minimum amount for the offer.
+	// Metadata is optional opaque data that the offer's creator includes
+	// for its own use.
+	Metadata []byte
+
+	// MinimumAmount is an optional minimum amount for the offer in
+	// millisatoshis. It must not be set for offers that are denominated
+	// in a currency.
 	MinimumAmount lnwire.MilliSatoshi
 
+	// CurrencyAmount is the minimum amount for offers that are
+	// denominated in an ISO-4217 currency rather than millisatoshis. It is
+	// held separately from MinimumAmount so that currency amounts can't be
+	// mistaken for millisatoshis.
+	CurrencyAmount *CurrencyAmount
+
 	// Description is an optional description of the offer.
 	Description string
 
@@ -83,6 +107,10 @@ type Offer struct {
 	// Expiry is an optional expiry time of the offer.
 	Expiry time.Time
 
+	// Paths is an optional set of blinded paths to the offer's creator,
+	// which may be provided instead of a node ID.
+	Paths []*ReplyPath
+
 	// Issuer identifies the issuing party.
 	Issuer string
 
@@ -122,12 +150,47 @@ func (o *Offer) records() ([]tlv.Record, error) {
 		records = append(records, record)
 	}
 
-	if o.MinimumAmount != 0 {
-		amountMin := uint64(o.MinimumAmount)
+	if len(o.Metadata) != 0 {
+		record := tlv.MakePrimitiveRecord(
+			offerMetadataType, &o.Metadata,
+		)
+		records = append(records, record)
+	}
+
+	amountMin := uint64(o.MinimumAmount)
+
+	if o.CurrencyAmount != nil {
+		if o.MinimumAmount != 0 {
+			return nil, ErrAmountDenomination
+		}
+
+		_, err := CurrencyExponent(o.CurrencyAmount.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		currencyBytes := []byte(o.CurrencyAmount.Code)
+
+		currencyRecord := tlv.MakePrimitiveRecord(
+			currencyType, &currencyBytes,
+		)
+		records = append(records, currencyRecord)
+
+		amountMin = o.CurrencyAmount.Minor
+	}
+
+	if amountMin != 0 {
 		records = append(records, tu64Record(amountType, &amountMin))
 	}
 
 	if o.Description != "" {
+		err := validateString(
+			"description", o.Description, MaxDescriptionLength,
+		)
+		if err != nil {
+			return nil, err
+		}
+
 		descriptionBytes := []byte(o.Description)
 
 		descriptionRecord := tlv.MakePrimitiveRecord(
@@ -137,7 +200,7 @@ func (o *Offer) records() ([]tlv.Record, error) {
 		records = append(records, descriptionRecord)
 	}
 
-	featuresRecord, err := encodeFetauresRecord(featuresType, o.Features)
+	featuresRecord, err := encodeFeaturesRecord(featuresType, o.Features)
 	if err != nil {
 		return nil, fmt.Errorf("encode features: %w", err)
 	}
@@ -154,7 +217,16 @@ func (o *Offer) records() ([]tlv.Record, error) {
 		)
 	}
 
+	if len(o.Paths) != 0 {
+		records = append(records, pathsRecord(offerPathsType, &o.Paths))
+	}
+
 	if o.Issuer != "" {
+		err := validateString("issuer", o.Issuer, MaxIssuerLength)
+		if err != nil {
+			return nil, err
+		}
+
 		issuerBytes := []byte(o.Issuer)
 
 		issuerRecord := tlv.MakePrimitiveRecord(issuerType, &issuerBytes)
@@ -196,13 +268,9 @@ func (o *Offer) records() ([]tlv.Record, error) {
 
 // Validate performs the validation outlined in the specification for offers.
 func (o *Offer) Validate() error {
-	// At present, we only support offers that contain node IDs because
-	// support for blinded paths has not been added.
-	//
 	// The spec notes "if it sets a node ID ... otherwise MUST provide at
 	// least one blinded path".
-	// TODO - expand validation once blinded paths are added.
-	if o.NodeID == nil {
+	if o.NodeID == nil && len(o.Paths) == 0 {
 		return ErrNodeIDRequired
 	}
 
@@ -210,6 +278,16 @@ func (o *Offer) Validate() error {
 		return ErrDescriptionRequried
 	}
 
+	if o.CurrencyAmount != nil {
+		if o.MinimumAmount != 0 {
+			return ErrAmountDenomination
+		}
+
+		if err := o.CurrencyAmount.Validate(); err != nil {
+			return err
+		}
+	}
+
 	var (
 		minQuantitySet = o.QuantityMin != 0
 		maxQuantitySet = o.QuantityMax != 0
@@ -224,13 +302,7 @@ func (o *Offer) Validate() error {
 	// Check that our signature is a valid signature of the merkle root for
 	// the offer.
 	if o.Signature != nil {
-		sigDigest := signatureDigest(
-			offerTag, signatureTag, o.MerkleRoot,
-		)
-
-		if err := validateSignature(
-			*o.Signature, o.NodeID, sigDigest[:],
-		); err != nil {
+		if err := o.VerifySignature(); err != nil {
 			return err
 		}
 	}
@@ -238,6 +310,28 @@ func (o *Offer) Validate() error {
 	return nil
 }
 
+// VerifySignature checks that the offer's signature is a valid signature of
+// its merkle root by the offer's node ID.
+func (o *Offer) VerifySignature() error {
+	if o.Signature == nil {
+		return fmt.Errorf("%w: no signature", ErrInvalidSig)
+	}
+
+	if o.NodeID == nil {
+		return ErrNodeIDRequired
+	}
+
+	sigDigest := signatureDigest(offerTag, signatureTag, o.MerkleRoot)
+
+	return validateSignature(*o.Signature, o.NodeID, sigDigest[:])
+}
+
+// CalculateMerkleRoot calculates the tlv merkle root of the offer's populated
+// fields. This can be used to set the offer's merkle root before signing it.
+func (o *Offer) CalculateMerkleRoot() (lntypes.Hash, error) {
+	return calculateMerkleRoot(o)
+}
+
 // EncodeOffer encodes an offer.
 func EncodeOffer(offer *Offer) ([]byte, error) {
 	records, err := offer.records()
@@ -259,23 +353,27 @@ func EncodeOffer(offer *Offer) ([]byte, error) {
 }
 
 // DecodeOffer decodes a bolt 12 offer TLV stream.
-func DecodeOffer(offerBytes []byte) (*Offer, error) {
+func DecodeOffer(offerBytes []byte, opts ...DecodeOption) (*Offer, error) {
 	offer := &Offer{}
 
 	var (
 		amountMin                     uint64
 		expirySeconds                 uint64
 		features, description, issuer []byte
+		currency                      []byte
 		chainHash, nodeID             [32]byte
 		signature                     [64]byte
 	)
 
 	records := []tlv.Record{
 		tlv.MakePrimitiveRecord(chainType, &chainHash),
+		tlv.MakePrimitiveRecord(offerMetadataType, &offer.Metadata),
+		tlv.MakePrimitiveRecord(currencyType, &currency),
 		tu64Record(amountType, &amountMin),
 		tlv.MakePrimitiveRecord(descriptionType, &description),
 		tlv.MakePrimitiveRecord(featuresType, &features),
 		tu64Record(expiryType, &expirySeconds),
+		pathsRecord(offerPathsType, &offer.Paths),
 		tlv.MakePrimitiveRecord(issuerType, &issuer),
 		tu64Record(quantityMinType, &offer.QuantityMin),
 		tu64Record(quantityMaxType, &offer.QuantityMax),
@@ -283,6 +381,11 @@ func DecodeOffer(offerBytes []byte) (*Offer, error) {
 		tlv.MakePrimitiveRecord(signatureType, &signature),
 	}
 
+	err := newDecodeOptions(opts).checkStream(offerBytes, records)
+	if err != nil {
+		return nil, err
+	}
+
 	stream, err := tlv.NewStream(records...)
 	if err != nil {
 		return nil, fmt.Errorf("offer decode stream: %w", err)
@@ -291,7 +394,8 @@ func DecodeOffer(offerBytes []byte) (*Offer, error) {
 	r := bytes.NewReader(offerBytes)
 	tlvMap, err := stream.DecodeWithParsedTypes(r)
 	if err != nil {
-		return nil, fmt.Errorf("offer decode: %w", err)
+		return nil, fmt.Errorf("offer decode: %w",
+			annotateDecodeErr(offerBytes, records, err))
 	}
 
 	// Add typed values to our offer that were decoded using intermediate
@@ -303,7 +407,18 @@ func DecodeOffer(offerBytes []byte) (*Offer, error) {
 		}
 	}
 
-	if _, ok := tlvMap[amountType]; ok {
+	// Offers that set a currency express their amount in the currency's
+	// minor unit, so we only treat the amount as millisatoshis if there
+	// is no currency.
+	_, haveAmount := tlvMap[amountType]
+	if _, ok := tlvMap[currencyType]; ok {
+		offer.CurrencyAmount, err = decodeCurrencyAmount(
+			currency, amountMin,
+		)
+		if err != nil {
+			return nil, err
+		}
+	} else if haveAmount {
 		offer.MinimumAmount = lnwire.MilliSatoshi(amountMin)
 	}
 
@@ -322,10 +437,22 @@ func DecodeOffer(offerBytes []byte) (*Offer, error) {
 
 	if _, ok := tlvMap[descriptionType]; ok {
 		offer.Description = string(description)
+
+		err := validateString(
+			"description", offer.Description, MaxDescriptionLength,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if _, ok := tlvMap[issuerType]; ok {
 		offer.Issuer = string(issuer)
+
+		err := validateString("issuer", offer.Issuer, MaxIssuerLength)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if _, ok := tlvMap[nodeIDType]; ok {
@@ -0,0 +1,197 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// The following record types are used by other bolt 12 implementations (eg,
+// CLN and LDK), which have moved on to a later numbering of the offer tlv
+// stream. Types that have not changed between versions, such as metadata and
+// blinded paths, re-use the record types we use for our own encoding.
+const (
+	// compatIssuerType is the record type used for the issuer of an offer.
+	compatIssuerType tlv.Type = 18
+
+	// compatQuantityMaxType is the record type used for the maximum
+	// quantity of an offer. Minimum quantity is no longer included in
+	// offers.
+	compatQuantityMaxType tlv.Type = 20
+
+	// compatNodeIDType is the record type used for the node's ID, which
+	// is encoded as a 33 byte compressed pubkey.
+	compatNodeIDType tlv.Type = 22
+)
+
+var (
+	// ErrInvalidChains is returned when a chains record is not a non-empty
+	// list of 32 byte chain hashes.
+	ErrInvalidChains = errors.New("chains must be a list of 32 byte hashes")
+
+	// ErrInvalidNodeIDLength is returned when a node ID is neither a 32
+	// byte x-only pubkey or a 33 byte compressed pubkey.
+	ErrInvalidNodeIDLength = errors.New("node ID must be 32 or 33 bytes")
+)
+
+// DecodeOfferCompat decodes a bolt 12 offer TLV stream that uses the record
+// numbering of other bolt 12 implementations. It tolerates the following
+// differences from our own encoding:
+//   - The chains record may contain a list of chain hashes, in which case the
+//     first chain is used for the offer.
+//   - The node ID may be encoded as a 33 byte compressed pubkey or a 32 byte
+//     x-only pubkey, and may be omitted if the offer provides blinded paths.
+//
+// Since the records in the stream don't match the records we would produce
+// for the offer, the merkle root is calculated over the raw stream so that
+// the signature provided by the sender can be validated.
+func DecodeOfferCompat(offerBytes []byte) (*Offer, error) {
+	offer := &Offer{}
+
+	var (
+		amountMin                     uint64
+		expirySeconds                 uint64
+		features, description, issuer []byte
+		chains, nodeID, currency      []byte
+		signature                     [64]byte
+	)
+
+	records := []tlv.Record{
+		tlv.MakePrimitiveRecord(chainType, &chains),
+		tlv.MakePrimitiveRecord(offerMetadataType, &offer.Metadata),
+		tlv.MakePrimitiveRecord(currencyType, &currency),
+		tu64Record(amountType, &amountMin),
+		tlv.MakePrimitiveRecord(descriptionType, &description),
+		tlv.MakePrimitiveRecord(featuresType, &features),
+		tu64Record(expiryType, &expirySeconds),
+		pathsRecord(offerPathsType, &offer.Paths),
+		tlv.MakePrimitiveRecord(compatIssuerType, &issuer),
+		tu64Record(compatQuantityMaxType, &offer.QuantityMax),
+		tlv.MakePrimitiveRecord(compatNodeIDType, &nodeID),
+		tlv.MakePrimitiveRecord(signatureType, &signature),
+	}
+
+	if err := checkRecordLengths(offerBytes); err != nil {
+		return nil, err
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, fmt.Errorf("offer decode stream: %w", err)
+	}
+
+	r := bytes.NewReader(offerBytes)
+	tlvMap, err := stream.DecodeWithParsedTypes(r)
+	if err != nil {
+		return nil, fmt.Errorf("offer decode: %w",
+			annotateDecodeErr(offerBytes, records, err))
+	}
+
+	if _, ok := tlvMap[chainType]; ok {
+		if len(chains) == 0 || len(chains)%32 != 0 {
+			return nil, fmt.Errorf("%w: got %v bytes",
+				ErrInvalidChains, len(chains))
+		}
+
+		offer.Chainhash, err = lntypes.MakeHash(chains[:32])
+		if err != nil {
+			return nil, fmt.Errorf("chain hash: %w", err)
+		}
+	}
+
+	// Offers that set a currency express their amount in the currency's
+	// minor unit, so we only treat the amount as millisatoshis if there
+	// is no currency.
+	_, haveAmount := tlvMap[amountType]
+	if _, ok := tlvMap[currencyType]; ok {
+		offer.CurrencyAmount, err = decodeCurrencyAmount(
+			currency, amountMin,
+		)
+		if err != nil {
+			return nil, err
+		}
+	} else if haveAmount {
+		offer.MinimumAmount = lnwire.MilliSatoshi(amountMin)
+	}
+
+	if _, ok := tlvMap[expiryType]; ok {
+		offer.Expiry = time.Unix(int64(expirySeconds), 0)
+	}
+
+	_, found := tlvMap[featuresType]
+	offer.Features, err = decodeFeaturesRecord(features, found)
+	if err != nil {
+		return nil, fmt.Errorf("decode features: %w", err)
+	}
+
+	if _, ok := tlvMap[descriptionType]; ok {
+		offer.Description = string(description)
+
+		err := validateString(
+			"description", offer.Description, MaxDescriptionLength,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := tlvMap[compatIssuerType]; ok {
+		offer.Issuer = string(issuer)
+
+		err := validateString("issuer", offer.Issuer, MaxIssuerLength)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := tlvMap[compatNodeIDType]; ok {
+		offer.NodeID, err = parseCompatNodeID(nodeID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := tlvMap[signatureType]; ok {
+		offer.Signature = &signature
+	}
+
+	offer.MerkleRoot, err = StreamMerkleRoot(offerBytes)
+	if err != nil {
+		return nil, fmt.Errorf("merkle root: %w", err)
+	}
+
+	return offer, nil
+}
+
+// parseCompatNodeID parses a node ID that is either a 33 byte compressed
+// pubkey or a 32 byte x-only pubkey.
+func parseCompatNodeID(nodeID []byte) (*btcec.PublicKey, error) {
+	var (
+		pubkey *btcec.PublicKey
+		err    error
+	)
+
+	switch len(nodeID) {
+	case btcec.PubKeyBytesLenCompressed:
+		pubkey, err = btcec.ParsePubKey(nodeID)
+
+	case schnorr.PubKeyBytesLen:
+		pubkey, err = schnorr.ParsePubKey(nodeID)
+
+	default:
+		return nil, fmt.Errorf("%w: got %v bytes",
+			ErrInvalidNodeIDLength, len(nodeID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey: %w", err)
+	}
+
+	return pubkey, nil
+}
@@ -0,0 +1,208 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeOfferCompat tests decoding of offers that use the encoding of
+// other bolt 12 implementations.
+func TestDecodeOfferCompat(t *testing.T) {
+	privkey := testutils.GetPrivkeys(t, 1)[0]
+	pubkey := privkey.PubKey()
+
+	var (
+		chains      = append(bytes.Repeat([]byte{1}, 32), make([]byte, 32)...)
+		badChains   = make([]byte, 33)
+		description = []byte("description")
+		issuer      = []byte("issuer")
+		compressed  = pubkey.SerializeCompressed()
+		xOnly       = schnorr.SerializePubKey(pubkey)
+		badNodeID   = make([]byte, 31)
+		quantityMax = uint64(5)
+		metadata    = []byte{9, 9, 9}
+
+		paths = []*ReplyPath{
+			{
+				FirstNodeID:   pubkey,
+				BlindingPoint: pubkey,
+				Hops: []*BlindedHop{
+					{
+						BlindedNodeID: pubkey,
+						EncryptedData: []byte{1, 2},
+					},
+				},
+			},
+		}
+	)
+
+	// encodeStream encodes the set of records provided, adding a valid
+	// signature over the records if sign is true.
+	encodeStream := func(t *testing.T, sign bool,
+		records ...tlv.Record) []byte {
+
+		if sign {
+			root, err := MerkleRoot(records)
+			require.NoError(t, err, "merkle root")
+
+			digest := signatureDigest(offerTag, signatureTag, root)
+			sig, err := schnorr.Sign(privkey, digest[:])
+			require.NoError(t, err, "sign")
+
+			var sigBytes [64]byte
+			copy(sigBytes[:], sig.Serialize())
+
+			records = append(records, tlv.MakePrimitiveRecord(
+				signatureType, &sigBytes,
+			))
+		}
+
+		stream, err := tlv.NewStream(records...)
+		require.NoError(t, err, "stream")
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, stream.Encode(buf), "encode")
+
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		records []tlv.Record
+		sign    bool
+		noNode  bool
+
+		// metadata and paths are the metadata and blinded paths
+		// that we expect our offer to contain.
+		metadata []byte
+		paths    []*ReplyPath
+
+		err error
+	}{
+		{
+			name: "compressed node id, multiple chains",
+			records: []tlv.Record{
+				tlv.MakePrimitiveRecord(chainType, &chains),
+				tlv.MakePrimitiveRecord(
+					descriptionType, &description,
+				),
+				tlv.MakePrimitiveRecord(
+					compatIssuerType, &issuer,
+				),
+				tu64Record(compatQuantityMaxType, &quantityMax),
+				tlv.MakePrimitiveRecord(
+					compatNodeIDType, &compressed,
+				),
+			},
+			sign: true,
+		},
+		{
+			name: "x-only node id",
+			records: []tlv.Record{
+				tlv.MakePrimitiveRecord(
+					descriptionType, &description,
+				),
+				tlv.MakePrimitiveRecord(
+					compatNodeIDType, &xOnly,
+				),
+			},
+			sign: true,
+		},
+		{
+			name: "metadata and blinded paths",
+			records: []tlv.Record{
+				tlv.MakePrimitiveRecord(
+					offerMetadataType, &metadata,
+				),
+				tlv.MakePrimitiveRecord(
+					descriptionType, &description,
+				),
+				pathsRecord(offerPathsType, &paths),
+				tlv.MakePrimitiveRecord(
+					compatNodeIDType, &compressed,
+				),
+			},
+			sign:     true,
+			metadata: metadata,
+			paths:    paths,
+		},
+		{
+			name: "blinded paths without node id",
+			records: []tlv.Record{
+				tlv.MakePrimitiveRecord(
+					descriptionType, &description,
+				),
+				pathsRecord(offerPathsType, &paths),
+			},
+			noNode: true,
+			paths:  paths,
+		},
+		{
+			name: "invalid node id length",
+			records: []tlv.Record{
+				tlv.MakePrimitiveRecord(
+					descriptionType, &description,
+				),
+				tlv.MakePrimitiveRecord(
+					compatNodeIDType, &badNodeID,
+				),
+			},
+			err: ErrInvalidNodeIDLength,
+		},
+		{
+			name: "invalid chains",
+			records: []tlv.Record{
+				tlv.MakePrimitiveRecord(chainType, &badChains),
+				tlv.MakePrimitiveRecord(
+					descriptionType, &description,
+				),
+				tlv.MakePrimitiveRecord(
+					compatNodeIDType, &compressed,
+				),
+			},
+			err: ErrInvalidChains,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			encoded := encodeStream(
+				t, testCase.sign, testCase.records...,
+			)
+
+			offer, err := DecodeOfferCompat(encoded)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err != nil {
+				return
+			}
+
+			require.Equal(t, string(description), offer.Description)
+			require.Equal(t, testCase.metadata, offer.Metadata)
+			require.Equal(t, testCase.paths, offer.Paths)
+
+			if testCase.noNode {
+				require.Nil(t, offer.NodeID)
+			} else {
+				require.Equal(
+					t, xOnly,
+					schnorr.SerializePubKey(offer.NodeID),
+				)
+			}
+
+			// Our signature was produced over the records as the
+			// sender encoded them, so a successful validation shows
+			// that our merkle root was calculated over the raw
+			// stream.
+			require.NoError(t, offer.Validate(), "validate")
+		})
+	}
+}
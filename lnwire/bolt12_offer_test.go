@@ -27,6 +27,17 @@ func TestOfferEncoding(t *testing.T) {
 	nodeID, err := schnorr.ParsePubKey(schnorr.SerializePubKey(pubkey))
 	require.NoError(t, err, "xonly pubkey")
 
+	path := &ReplyPath{
+		FirstNodeID:   pubkey,
+		BlindingPoint: pubkey,
+		Hops: []*BlindedHop{
+			{
+				BlindedNodeID: pubkey,
+				EncryptedData: []byte{1},
+			},
+		},
+	}
+
 	tests := []struct {
 		name  string
 		offer *Offer
@@ -37,6 +48,16 @@ func TestOfferEncoding(t *testing.T) {
 				Chainhash: chainHash,
 			},
 		},
+		{
+			name: "currency",
+			offer: &Offer{
+				CurrencyAmount: &CurrencyAmount{
+					Code:     "USD",
+					Minor:    100,
+					Exponent: 2,
+				},
+			},
+		},
 		{
 			name: "min amount - zeros truncated",
 			offer: &Offer{
@@ -89,6 +110,18 @@ func TestOfferEncoding(t *testing.T) {
 				QuantityMax: 3,
 			},
 		},
+		{
+			name: "metadata",
+			offer: &Offer{
+				Metadata: []byte{1, 2, 3},
+			},
+		},
+		{
+			name: "blinded paths",
+			offer: &Offer{
+				Paths: []*ReplyPath{path},
+			},
+		},
 		{
 			name: "node ID",
 			offer: &Offer{
@@ -171,6 +204,16 @@ func TestOfferValidation(t *testing.T) {
 	nodePrivKey := privkey[0]
 	nodePubkey := nodePrivKey.PubKey()
 
+	path := &ReplyPath{
+		FirstNodeID:   nodePubkey,
+		BlindingPoint: nodePubkey,
+		Hops: []*BlindedHop{
+			{
+				BlindedNodeID: nodePubkey,
+			},
+		},
+	}
+
 	// Create a mock merkle root and sign it.
 	rootBytes := [32]byte{1, 2, 3}
 	root, err := lntypes.MakeHash(rootBytes[:])
@@ -211,6 +254,13 @@ func TestOfferValidation(t *testing.T) {
 			},
 			err: ErrDescriptionRequried,
 		},
+		{
+			name: "valid - blinded path without node ID",
+			offer: &Offer{
+				Description: " ",
+				Paths:       []*ReplyPath{path},
+			},
+		},
 		{
 			name: "min > max",
 			offer: &Offer{
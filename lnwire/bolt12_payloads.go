@@ -0,0 +1,121 @@
+package lnwire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+var (
+	// ErrWrongPayloadType is returned when we try to parse a final hop
+	// payload that does not have the tlv type we expect.
+	ErrWrongPayloadType = errors.New("unexpected final hop payload type")
+
+	// ErrMissingPayload is returned when we try to parse a nil final hop
+	// payload.
+	ErrMissingPayload = errors.New("final hop payload missing")
+)
+
+// NewInvoiceRequestPayload encodes an invoice request as a final hop payload.
+func NewInvoiceRequestPayload(i *InvoiceRequest) (*FinalHopPayload, error) {
+	value, err := EncodeInvoiceRequest(i)
+	if err != nil {
+		return nil, fmt.Errorf("encode invoice request: %w", err)
+	}
+
+	return &FinalHopPayload{
+		TLVType: InvoiceRequestNamespaceType,
+		Value:   value,
+	}, nil
+}
+
+// NewInvoicePayload encodes an invoice as a final hop payload.
+func NewInvoicePayload(i *Invoice) (*FinalHopPayload, error) {
+	value, err := EncodeInvoice(i)
+	if err != nil {
+		return nil, fmt.Errorf("encode invoice: %w", err)
+	}
+
+	return &FinalHopPayload{
+		TLVType: InvoiceNamespaceType,
+		Value:   value,
+	}, nil
+}
+
+// NewInvoiceErrorPayload encodes an invoice error as a final hop payload.
+func NewInvoiceErrorPayload(i *InvoiceError) (*FinalHopPayload, error) {
+	value, err := EncodeInvoiceError(i)
+	if err != nil {
+		return nil, fmt.Errorf("encode invoice error: %w", err)
+	}
+
+	return &FinalHopPayload{
+		TLVType: InvoiceErrorNamespaceType,
+		Value:   value,
+	}, nil
+}
+
+// ParseInvoiceRequestPayload decodes the invoice request contained in a final
+// hop payload.
+func ParseInvoiceRequestPayload(f *FinalHopPayload,
+	opts ...DecodeOption) (*InvoiceRequest, error) {
+
+	if err := checkPayloadType(f, InvoiceRequestNamespaceType); err != nil {
+		return nil, err
+	}
+
+	return DecodeInvoiceRequest(f.Value, opts...)
+}
+
+// ParseInvoicePayload decodes the invoice contained in a final hop payload.
+func ParseInvoicePayload(f *FinalHopPayload,
+	opts ...DecodeOption) (*Invoice, error) {
+
+	if err := checkPayloadType(f, InvoiceNamespaceType); err != nil {
+		return nil, err
+	}
+
+	return DecodeInvoice(f.Value, opts...)
+}
+
+// ParseInvoiceErrorPayload decodes the invoice error contained in a final hop
+// payload.
+func ParseInvoiceErrorPayload(f *FinalHopPayload,
+	opts ...DecodeOption) (*InvoiceError, error) {
+
+	if err := checkPayloadType(f, InvoiceErrorNamespaceType); err != nil {
+		return nil, err
+	}
+
+	return DecodeInvoiceError(f.Value, opts...)
+}
+
+// FindFinalHopPayload returns the payload with the tlv type provided from a
+// set of final hop payloads, or nil if it is not present.
+func FindFinalHopPayload(payloads []*FinalHopPayload,
+	tlvType tlv.Type) *FinalHopPayload {
+
+	for _, payload := range payloads {
+		if payload.TLVType == tlvType {
+			return payload
+		}
+	}
+
+	return nil
+}
+
+// checkPayloadType returns an error if a final hop payload is nil or does not
+// have the tlv type provided.
+func checkPayloadType(f *FinalHopPayload, tlvType tlv.Type) error {
+	if f == nil {
+		return fmt.Errorf("%w: %v", ErrMissingPayload, tlvType)
+	}
+
+	if f.TLVType != tlvType {
+		return fmt.Errorf("%w: expected: %v, got: %v",
+			ErrWrongPayloadType, tlvType, f.TLVType)
+	}
+
+	return nil
+}
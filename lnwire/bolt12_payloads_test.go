@@ -0,0 +1,73 @@
+package lnwire
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBolt12Payloads tests round trip encoding of bolt 12 messages as final
+// hop payloads in an onion message.
+func TestBolt12Payloads(t *testing.T) {
+	hash := lntypes.Hash{1, 2, 3}
+
+	request, err := NewInvoiceRequestPayload(&InvoiceRequest{
+		Chainhash: hash,
+	})
+	require.NoError(t, err, "invoice request")
+	require.Equal(t, InvoiceRequestNamespaceType, request.TLVType)
+
+	invoice, err := NewInvoicePayload(&Invoice{
+		PaymentHash: hash,
+	})
+	require.NoError(t, err, "invoice")
+	require.Equal(t, InvoiceNamespaceType, invoice.TLVType)
+
+	invoiceError, err := NewInvoiceErrorPayload(&InvoiceError{
+		Error: "error",
+	})
+	require.NoError(t, err, "invoice error")
+	require.Equal(t, InvoiceErrorNamespaceType, invoiceError.TLVType)
+
+	encoded, err := EncodeOnionMessagePayload(&OnionMessagePayload{
+		FinalHopPayloads: []*FinalHopPayload{
+			request, invoice, invoiceError,
+		},
+	})
+	require.NoError(t, err, "encode payload")
+
+	payload, err := DecodeOnionMessagePayload(encoded)
+	require.NoError(t, err, "decode payload")
+	payloads := payload.FinalHopPayloads
+
+	decodedRequest, err := ParseInvoiceRequestPayload(
+		FindFinalHopPayload(payloads, InvoiceRequestNamespaceType),
+	)
+	require.NoError(t, err, "parse invoice request")
+	require.Equal(t, hash, decodedRequest.Chainhash)
+
+	decodedInvoice, err := ParseInvoicePayload(
+		FindFinalHopPayload(payloads, InvoiceNamespaceType),
+	)
+	require.NoError(t, err, "parse invoice")
+	require.Equal(t, hash, decodedInvoice.PaymentHash)
+
+	decodedError, err := ParseInvoiceErrorPayload(
+		FindFinalHopPayload(payloads, InvoiceErrorNamespaceType),
+	)
+	require.NoError(t, err, "parse invoice error")
+	require.Equal(t, "error", decodedError.Error)
+
+	// Parsing a payload as the wrong type should fail, and payloads that
+	// aren't present should not be found.
+	_, err = ParseInvoicePayload(request)
+	require.True(t, errors.Is(err, ErrWrongPayloadType))
+
+	probe := FindFinalHopPayload(payloads, ProbeType)
+	require.Nil(t, probe)
+
+	_, err = ParseInvoiceErrorPayload(probe)
+	require.True(t, errors.Is(err, ErrMissingPayload))
+}
@@ -1,56 +1,56 @@
 package lnwire
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/lightningnetwork/lnd/lntypes"
-	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/tlv"
 )
 
-// encodeFetauresRecord creates a tlv record with the type provided, encoding
-// the feature vector provided as a byte vector. If the vector provided is nil
-// or empty the record returned will be nil.
-func encodeFetauresRecord(recordType tlv.Type,
-	features *lnwire.FeatureVector) (*tlv.Record, error) {
+const (
+	// MaxDescriptionLength is the maximum length, in bytes, that we allow
+	// for descriptions in offers and invoices.
+	MaxDescriptionLength = 640
 
-	if features == nil {
-		return nil, nil
-	}
-
-	if features.IsEmpty() {
-		return nil, nil
-	}
+	// MaxIssuerLength is the maximum length, in bytes, that we allow for
+	// the issuer of an offer.
+	MaxIssuerLength = 256
 
-	w := new(bytes.Buffer)
+	// MaxPayerNoteLength is the maximum length, in bytes, that we allow for
+	// payer notes in invoice requests and invoices.
+	MaxPayerNoteLength = 512
 
-	if err := features.Encode(w); err != nil {
-		return nil, fmt.Errorf("encode features: %w", err)
-	}
-
-	featureBytes := w.Bytes()
+	// MaxInvoiceErrorLength is the maximum length, in bytes, that we allow
+	// for the error string in invoice errors.
+	MaxInvoiceErrorLength = 512
+)
 
-	record := tlv.MakePrimitiveRecord(recordType, &featureBytes)
-	return &record, nil
-}
+var (
+	// ErrInvalidUTF8 is returned when a string field does not contain
+	// valid utf-8.
+	ErrInvalidUTF8 = errors.New("string is not valid utf-8")
 
-// decodeFeaturesRecord decodes the features record provided. If it is not
-// present, an empty feature vector will be returned for easy use.
-func decodeFeaturesRecord(decodedFeatures []byte,
-	found bool) (*lnwire.FeatureVector, error) {
+	// ErrStringTooLong is returned when a string field exceeds the
+	// maximum length that we allow.
+	ErrStringTooLong = errors.New("string exceeds maximum length")
+)
 
-	// Create an empty raw feature vector.
-	rawFeatures := lnwire.NewRawFeatureVector()
+// validateString checks that a string field contains valid utf-8 and does not
+// exceed the maximum length provided. The field name is included in errors
+// to provide context for the caller.
+func validateString(field, value string, maxLen int) error {
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("%v: %w", field, ErrInvalidUTF8)
+	}
 
-	// If we decoded a value for our feature vector, decode it.
-	if found {
-		err := rawFeatures.Decode(bytes.NewReader(decodedFeatures))
-		if err != nil {
-			return nil, fmt.Errorf("raw features decode: %w", err)
-		}
+	if len(value) > maxLen {
+		return fmt.Errorf("%v: %w: %v > %v", field, ErrStringTooLong,
+			len(value), maxLen)
 	}
-	return lnwire.NewFeatureVector(rawFeatures, lnwire.Features), nil
+
+	return nil
 }
 
 // tlvTree is an interface implemented by bolt 12 artifacts that can be
@@ -80,10 +80,16 @@ func decodeMerkleRoot(recordProducer tlvTree,
 		return lntypes.Hash{}, fmt.Errorf("merkle root: %w", err)
 	}
 
-	hash, err := lntypes.MakeHash(root[:])
+	return root, nil
+}
+
+// calculateMerkleRoot produces a tlv merkle tree root for the populated
+// fields of a bolt 12 artifact.
+func calculateMerkleRoot(tree tlvTree) (lntypes.Hash, error) {
+	records, err := tree.records()
 	if err != nil {
-		return lntypes.Hash{}, fmt.Errorf("make hash: %w", err)
+		return lntypes.ZeroHash, fmt.Errorf("get records: %w", err)
 	}
 
-	return hash, nil
+	return MerkleRoot(records)
 }
@@ -0,0 +1,118 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStringFieldValidation tests utf-8 and length validation of the string
+// fields in our bolt 12 messages on encode and decode.
+func TestStringFieldValidation(t *testing.T) {
+	var (
+		invalidUTF8 = string([]byte{0xff, 0xfe, 0xfd})
+		longDesc    = strings.Repeat("a", MaxDescriptionLength+1)
+		longIssuer  = strings.Repeat("b", MaxIssuerLength+1)
+		longNote    = strings.Repeat("c", MaxPayerNoteLength+1)
+	)
+
+	tests := []struct {
+		name   string
+		encode func() error
+		err    error
+	}{
+		{
+			name: "offer description invalid utf-8",
+			encode: func() error {
+				_, err := EncodeOffer(&Offer{
+					Description: invalidUTF8,
+				})
+				return err
+			},
+			err: ErrInvalidUTF8,
+		},
+		{
+			name: "offer description too long",
+			encode: func() error {
+				_, err := EncodeOffer(&Offer{
+					Description: longDesc,
+				})
+				return err
+			},
+			err: ErrStringTooLong,
+		},
+		{
+			name: "offer issuer too long",
+			encode: func() error {
+				_, err := EncodeOffer(&Offer{
+					Issuer: longIssuer,
+				})
+				return err
+			},
+			err: ErrStringTooLong,
+		},
+		{
+			name: "invoice request payer note invalid utf-8",
+			encode: func() error {
+				_, err := EncodeInvoiceRequest(&InvoiceRequest{
+					PayerNote: invalidUTF8,
+				})
+				return err
+			},
+			err: ErrInvalidUTF8,
+		},
+		{
+			name: "invoice payer note too long",
+			encode: func() error {
+				_, err := EncodeInvoice(&Invoice{
+					PayerNote: longNote,
+				})
+				return err
+			},
+			err: ErrStringTooLong,
+		},
+		{
+			name: "valid strings",
+			encode: func() error {
+				_, err := EncodeOffer(&Offer{
+					Description: "☕ coffee",
+					Issuer:      "café",
+				})
+				return err
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.encode()
+			require.True(t, errors.Is(err, testCase.err), err)
+		})
+	}
+}
+
+// TestDecodeInvalidStrings tests that we fail to decode bolt 12 messages that
+// contain invalid string fields.
+func TestDecodeInvalidStrings(t *testing.T) {
+	invalidUTF8 := []byte{0xff, 0xfe, 0xfd}
+
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(descriptionType, &invalidUTF8),
+	)
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, stream.Encode(buf))
+
+	_, err = DecodeOffer(buf.Bytes())
+	require.True(t, errors.Is(err, ErrInvalidUTF8), err)
+
+	_, err = DecodeInvoice(buf.Bytes())
+	require.True(t, errors.Is(err, ErrInvalidUTF8), err)
+}
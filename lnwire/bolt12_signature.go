@@ -35,20 +35,30 @@ var (
 	ErrInvalidSig = errors.New("invalid signature")
 )
 
-// signatureDigest returns the tagged merkle root that is used for offer
-// signatures.
-func signatureDigest(messageTag, fieldTag []byte,
-	root lntypes.Hash) chainhash.Hash {
+// OfferSignatureTag returns the tag that is used to produce the tagged hash of
+// an offer's merkle root that is signed.
+func OfferSignatureTag() []byte {
+	return signatureTagBytes(offerTag, signatureTag)
+}
 
-	// The tag has the following format:
-	// lightning || message tag || field tag
+// signatureTagBytes returns the tag used for signatures with the format:
+// lightning || message tag || field tag.
+func signatureTagBytes(messageTag, fieldTag []byte) []byte {
 	tags := [][]byte{
 		lightningTag, messageTag, fieldTag,
 	}
 
+	return bytes.Join(tags, []byte{})
+}
+
+// signatureDigest returns the tagged merkle root that is used for offer
+// signatures.
+func signatureDigest(messageTag, fieldTag []byte,
+	root lntypes.Hash) chainhash.Hash {
+
 	// Create a tagged hash with the merkle root.
 	digest := chainhash.TaggedHash(
-		bytes.Join(tags, []byte{}), root[:],
+		signatureTagBytes(messageTag, fieldTag), root[:],
 	)
 
 	return *digest
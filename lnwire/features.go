@@ -0,0 +1,25 @@
+package lnwire
+
+import (
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+)
+
+const (
+	// OnionMessagesRequired is the feature bit that a node sets in its
+	// node announcement to indicate that it requires onion message
+	// support.
+	OnionMessagesRequired lndwire.FeatureBit = 38
+
+	// OnionMessagesOptional is the feature bit that a node sets in its
+	// node announcement to indicate that it supports onion messages.
+	OnionMessagesOptional lndwire.FeatureBit = 39
+)
+
+// SupportsOnionMessages returns a boolean indicating whether a set of node
+// features advertises onion message support.
+func SupportsOnionMessages(features []lndwire.FeatureBit) bool {
+	featureVec := lndwire.NewRawFeatureVector(features...)
+
+	return featureVec.IsSet(OnionMessagesRequired) ||
+		featureVec.IsSet(OnionMessagesOptional)
+}
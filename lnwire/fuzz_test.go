@@ -0,0 +1,119 @@
+package lnwire
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzPubkeys returns a set of deterministic public keys to use when
+// creating seed inputs for our fuzz targets.
+func fuzzPubkeys(n int) []*btcec.PublicKey {
+	pubkeys := make([]*btcec.PublicKey, n)
+	for i := range pubkeys {
+		_, pubkeys[i] = btcec.PrivKeyFromBytes([]byte{byte(i + 1)})
+	}
+
+	return pubkeys
+}
+
+// FuzzOnionMessageDecode tests that decoding arbitrary onion messages does
+// not panic, and that messages which decode are re-encoded to the same bytes.
+func FuzzOnionMessageDecode(f *testing.F) {
+	pubkey := fuzzPubkeys(1)[0]
+
+	for _, size := range []int{StandardOnionBlobSize, LargeOnionBlobSize} {
+		buf := new(bytes.Buffer)
+		msg := NewOnionMessage(pubkey, make([]byte, size))
+		require.NoError(f, msg.Encode(buf, 0))
+
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg := &OnionMessage{}
+		if err := msg.Decode(bytes.NewReader(data), 0); err != nil {
+			return
+		}
+
+		buf := new(bytes.Buffer)
+		require.NoError(t, msg.Encode(buf, 0))
+		require.Equal(t, data[:buf.Len()], buf.Bytes())
+	})
+}
+
+// FuzzOnionMessagePayloadDecode tests that decoding arbitrary onion message
+// payloads does not panic.
+func FuzzOnionMessagePayloadDecode(f *testing.F) {
+	pubkeys := fuzzPubkeys(3)
+
+	payload, err := EncodeOnionMessagePayload(&OnionMessagePayload{
+		ReplyPath: &ReplyPath{
+			FirstNodeID:   pubkeys[0],
+			BlindingPoint: pubkeys[1],
+			Hops: []*BlindedHop{
+				{
+					BlindedNodeID: pubkeys[2],
+					EncryptedData: []byte{1, 2, 3},
+				},
+			},
+		},
+		EncryptedData: []byte{4, 5, 6},
+		FinalHopPayloads: []*FinalHopPayload{
+			{
+				TLVType: InvoiceNamespaceType,
+				Value:   []byte{7, 8, 9},
+			},
+		},
+	})
+	require.NoError(f, err)
+	f.Add(payload)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = DecodeOnionMessagePayload(data)
+		_, _ = DecodeOnionMessagePayload(data, OptionStrictTLV())
+		_, _ = DecodeReplyPath(data)
+	})
+}
+
+// FuzzBlindedRouteDataDecode tests that decoding arbitrary blinded route data
+// does not panic.
+func FuzzBlindedRouteDataDecode(f *testing.F) {
+	pubkeys := fuzzPubkeys(2)
+
+	data, err := EncodeBlindedRouteData(&BlindedRouteData{
+		Padding:              []byte{0, 0},
+		NextNodeID:           pubkeys[0],
+		NextBlindingOverride: pubkeys[1],
+	})
+	require.NoError(f, err)
+	f.Add(data)
+
+	data, err = EncodeBlindedRouteData(&BlindedRouteData{
+		PathID: []byte{1, 2, 3},
+	})
+	require.NoError(f, err)
+	f.Add(data)
+
+	data, err = EncodeBlindedRouteData(&BlindedRouteData{
+		NextNodeID: pubkeys[0],
+		PaymentRelay: &PaymentRelay{
+			CLTVExpiryDelta: 40,
+			FeeBaseMsat:     1000,
+		},
+		PaymentConstraints: &PaymentConstraints{
+			MaxCLTVExpiry: 800000,
+		},
+		AllowedFeatures: lndwire.EmptyFeatureVector(),
+	})
+	require.NoError(f, err)
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = DecodeBlindedRouteData(data)
+		_, _ = DecodeBlindedRouteData(data, OptionStrictTLV())
+	})
+}
@@ -0,0 +1,133 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+const (
+	// MaxOnionBlobSize is the maximum size of an onion message's onion
+	// blob, which holds a large onion packet.
+	MaxOnionBlobSize = LargeOnionBlobSize
+
+	// MaxPayloadSize is the maximum size of an onion message payload,
+	// which must fit in the routing info of an onion blob.
+	MaxPayloadSize = LargeRoutingInfoSize
+
+	// MaxEncryptedDataSize is the maximum size of the encrypted data for
+	// a hop in a blinded route, which is carried in the hop's payload.
+	MaxEncryptedDataSize = MaxPayloadSize
+)
+
+var (
+	// ErrOnionBlobTooLarge is returned when an onion message's onion blob
+	// exceeds MaxOnionBlobSize.
+	ErrOnionBlobTooLarge = errors.New("onion blob too large")
+
+	// ErrPayloadTooLarge is returned when an onion message payload exceeds
+	// MaxPayloadSize.
+	ErrPayloadTooLarge = errors.New("onion message payload too large")
+
+	// ErrEncryptedDataTooLarge is returned when a blinded hop's encrypted
+	// data exceeds MaxEncryptedDataSize.
+	ErrEncryptedDataTooLarge = errors.New("encrypted data too large")
+
+	// ErrRecordTooLarge is returned when a tlv record's length is larger
+	// than the remaining bytes in the stream that contains it.
+	ErrRecordTooLarge = errors.New("tlv record length exceeds stream")
+)
+
+// checkSize returns the error provided if a size exceeds its maximum.
+func checkSize(err error, size, max int) error {
+	if size > max {
+		return fmt.Errorf("%w: %v bytes exceeds %v", err, size, max)
+	}
+
+	return nil
+}
+
+// checkRecordLengths checks that each record in a tlv stream has a length
+// that fits in the stream. Our tlv decoding allocates a record's full length
+// before reading its value, so we check lengths upfront to prevent a small
+// stream from triggering large allocations.
+func checkRecordLengths(b []byte) error {
+	return walkStream(b, func(streamRecord) error {
+		return nil
+	})
+}
+
+// streamRecord describes a single record in an encoded tlv stream.
+type streamRecord struct {
+	// Type is the record's tlv type.
+	Type tlv.Type
+
+	// Offset is the offset of the start of the record in the stream.
+	Offset int
+
+	// Length is the record's declared length.
+	Length uint64
+
+	// Value is the record's value.
+	Value []byte
+}
+
+// walkStream iterates through the records in a tlv stream, calling the
+// function provided with each record. An error is returned if a record's
+// length exceeds the bytes remaining in the stream. Errors returned by the
+// visit function are annotated with the record that they occurred for.
+func walkStream(b []byte, visit func(streamRecord) error) error {
+	var (
+		r   = bytes.NewReader(b)
+		buf [8]byte
+	)
+
+	for {
+		offset := len(b) - r.Len()
+
+		t, err := tlv.ReadVarInt(r, &buf)
+		switch {
+		case err == io.EOF:
+			return nil
+
+		case err != nil:
+			return fmt.Errorf("read type at offset %v: %w", offset,
+				err)
+		}
+
+		record := streamRecord{
+			Type:   tlv.Type(t),
+			Offset: offset,
+		}
+
+		record.Length, err = tlv.ReadVarInt(r, &buf)
+		if err != nil {
+			return newStreamError(
+				record, fmt.Errorf("read length: %w", err),
+			)
+		}
+
+		if record.Length > uint64(r.Len()) {
+			return newStreamError(
+				record, fmt.Errorf("%w: %v bytes with %v "+
+					"remaining", ErrRecordTooLarge,
+					record.Length, r.Len()),
+			)
+		}
+
+		start := len(b) - r.Len()
+		record.Value = b[start : start+int(record.Length)]
+
+		if err := visit(record); err != nil {
+			return newStreamError(record, err)
+		}
+
+		_, err = r.Seek(int64(record.Length), io.SeekCurrent)
+		if err != nil {
+			return newStreamError(record, err)
+		}
+	}
+}
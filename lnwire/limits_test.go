@@ -0,0 +1,175 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeSizeLimits tests that our decoders enforce size limits on the
+// values that they decode.
+func TestDecodeSizeLimits(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 2)
+
+	// Create a record with a length that is far larger than the stream
+	// that contains it, which would produce a huge allocation if we
+	// decoded it directly.
+	hugeRecord := func(tlvType byte) []byte {
+		return []byte{
+			tlvType, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0xff, 0xff,
+		}
+	}
+
+	// Create an onion message with a blob that exceeds our maximum size.
+	largeOnion := new(bytes.Buffer)
+	err := NewOnionMessage(
+		pubkeys[0], make([]byte, MaxOnionBlobSize+1),
+	).Encode(largeOnion, 0)
+	require.True(t, errors.Is(err, ErrOnionBlobTooLarge))
+
+	largeOnion.Reset()
+	largeOnion.Write(pubkeys[0].SerializeCompressed())
+	largeOnion.Write([]byte{0xff, 0xff})
+
+	// Create a reply path with a hop that has too much encrypted data
+	// for its hop to carry.
+	largePath, err := EncodeReplyPath(&ReplyPath{
+		FirstNodeID:   pubkeys[0],
+		BlindingPoint: pubkeys[1],
+		Hops: []*BlindedHop{
+			{
+				BlindedNodeID: pubkeys[1],
+				EncryptedData: make(
+					[]byte, MaxEncryptedDataSize+1,
+				),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// Create reply paths that declare more hops and more encrypted data
+	// than their encoding contains.
+	pathHeader := func(hopCount byte) []byte {
+		header := append(
+			pubkeys[0].SerializeCompressed(),
+			pubkeys[1].SerializeCompressed()...,
+		)
+
+		return append(header, hopCount)
+	}
+
+	hop := append(pubkeys[1].SerializeCompressed(), 0x01, 0x00, 1, 2, 3)
+	excessHops := append(pathHeader(255), hop...)
+	excessHopData := append(pathHeader(1), hop...)
+
+	tests := []struct {
+		name   string
+		decode func() error
+		err    error
+	}{
+		{
+			name: "onion blob too large",
+			decode: func() error {
+				return (&OnionMessage{}).Decode(largeOnion, 0)
+			},
+			err: ErrOnionBlobTooLarge,
+		},
+		{
+			name: "payload too large",
+			decode: func() error {
+				_, err := DecodeOnionMessagePayload(
+					make([]byte, MaxPayloadSize+1),
+				)
+				return err
+			},
+			err: ErrPayloadTooLarge,
+		},
+		{
+			name: "payload record too large",
+			decode: func() error {
+				_, err := DecodeOnionMessagePayload(
+					hugeRecord(byte(encryptedDataTLVType)),
+				)
+				return err
+			},
+			err: ErrRecordTooLarge,
+		},
+		{
+			name: "reply path encrypted data too large",
+			decode: func() error {
+				_, err := DecodeReplyPath(largePath)
+				return err
+			},
+			err: ErrEncryptedDataTooLarge,
+		},
+		{
+			name: "reply path hop count exceeds record",
+			decode: func() error {
+				_, err := DecodeReplyPath(excessHops)
+				return err
+			},
+			err: ErrRecordTooLarge,
+		},
+		{
+			name: "reply path hop data exceeds record",
+			decode: func() error {
+				_, err := DecodeReplyPath(excessHopData)
+				return err
+			},
+			err: ErrRecordTooLarge,
+		},
+		{
+			name: "route data too large",
+			decode: func() error {
+				_, err := DecodeBlindedRouteData(
+					make([]byte, MaxEncryptedDataSize+1),
+				)
+				return err
+			},
+			err: ErrEncryptedDataTooLarge,
+		},
+		{
+			name: "route data record too large",
+			decode: func() error {
+				_, err := DecodeBlindedRouteData(
+					hugeRecord(byte(pathIDType)),
+				)
+				return err
+			},
+			err: ErrRecordTooLarge,
+		},
+		{
+			name: "offer record too large",
+			decode: func() error {
+				_, err := DecodeOffer(
+					hugeRecord(byte(descriptionType)),
+				)
+				return err
+			},
+			err: ErrRecordTooLarge,
+		},
+		{
+			name: "compat offer record too large",
+			decode: func() error {
+				_, err := DecodeOfferCompat(
+					hugeRecord(byte(descriptionType)),
+				)
+				return err
+			},
+			err: ErrRecordTooLarge,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.decode()
+			require.True(t, errors.Is(err, testCase.err), err)
+		})
+	}
+}
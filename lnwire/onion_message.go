@@ -2,6 +2,7 @@ package lnwire
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
@@ -12,6 +13,34 @@ import (
 // OnionMessageType is the protocol message type used for onion messages in lnd.
 const OnionMessageType = 513
 
+const (
+	// onionOverheadSize is the size of the fields in an onion packet that
+	// surround its routing info: a 1 byte version, 33 byte ephemeral key
+	// and 32 byte hmac.
+	onionOverheadSize = 1 + 33 + 32
+
+	// StandardRoutingInfoSize is the size of the routing info in a
+	// standard onion packet.
+	StandardRoutingInfoSize = 1300
+
+	// LargeRoutingInfoSize is the size of the routing info in a large
+	// onion packet, which may be used for onion messages that don't fit
+	// in a standard packet.
+	LargeRoutingInfoSize = 32768
+
+	// StandardOnionBlobSize is the size of an onion message blob that
+	// holds a standard onion packet.
+	StandardOnionBlobSize = onionOverheadSize + StandardRoutingInfoSize
+
+	// LargeOnionBlobSize is the size of an onion message blob that holds
+	// a large onion packet.
+	LargeOnionBlobSize = onionOverheadSize + LargeRoutingInfoSize
+)
+
+// ErrInvalidOnionBlobSize is returned when an onion message's blob is not one
+// of the onion packet sizes that we support.
+var ErrInvalidOnionBlobSize = errors.New("invalid onion blob size")
+
 // OnionMessage represents an onion message used to communicate with peers.
 type OnionMessage struct {
 	// BlindingPoint is the route blinding ephemeral pubkey to be used for
@@ -33,6 +62,39 @@ func NewOnionMessage(blindingPoint *btcec.PublicKey, onion []byte) *OnionMessage
 	}
 }
 
+// ValidateOnionBlobSize checks that an onion blob has the size of a standard
+// or large onion packet.
+func ValidateOnionBlobSize(size int) error {
+	err := checkSize(ErrOnionBlobTooLarge, size, MaxOnionBlobSize)
+	if err != nil {
+		return err
+	}
+
+	if size != StandardOnionBlobSize && size != LargeOnionBlobSize {
+		return fmt.Errorf("%w: %v bytes, expected %v or %v",
+			ErrInvalidOnionBlobSize, size, StandardOnionBlobSize,
+			LargeOnionBlobSize)
+	}
+
+	return nil
+}
+
+// IsLarge returns a boolean indicating whether an onion message holds a large
+// onion packet.
+func (o *OnionMessage) IsLarge() bool {
+	return len(o.OnionBlob) == LargeOnionBlobSize
+}
+
+// RoutingInfoSize returns the size of the routing info in the onion packet
+// that an onion message holds.
+func (o *OnionMessage) RoutingInfoSize() int {
+	if o.IsLarge() {
+		return LargeRoutingInfoSize
+	}
+
+	return StandardRoutingInfoSize
+}
+
 // A compile time check to ensure OnionMessage implements the lnwire.Message
 // interface in lnd.
 var _ lndwire.Message = (*OnionMessage)(nil)
@@ -58,6 +120,12 @@ func (o *OnionMessage) Decode(r io.Reader, _ uint32) error {
 		return fmt.Errorf("decode onion len: %w", err)
 	}
 
+	// Validate our length before we allocate our blob so that we don't
+	// allocate for a length that we won't accept.
+	if err := ValidateOnionBlobSize(int(onionLen)); err != nil {
+		return err
+	}
+
 	o.OnionBlob = make([]byte, onionLen)
 	if err := lndwire.ReadElement(r, o.OnionBlob); err != nil {
 		return fmt.Errorf("read onion blob: %w", err)
@@ -76,6 +144,10 @@ func (o *OnionMessage) Encode(w *bytes.Buffer, _ uint32) error {
 	}
 
 	onionLen := len(o.OnionBlob)
+	if err := ValidateOnionBlobSize(onionLen); err != nil {
+		return err
+	}
+
 	if err := lndwire.WriteElement(w, uint16(onionLen)); err != nil {
 		return fmt.Errorf("encode onion len: %w", err)
 	}
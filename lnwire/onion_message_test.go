@@ -2,25 +2,99 @@ package lnwire
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/gijswijs/boltnd/testutils"
 	"github.com/stretchr/testify/require"
 )
 
-// TestOnionMessageEncode tests encoding and decoding of onion messages.
+// TestOnionMessageEncode tests encoding and decoding of onion messages with
+// both standard and large onion packets.
 func TestOnionMessageEncode(t *testing.T) {
 	pubkeys := testutils.GetPubkeys(t, 1)
-	blob := []byte{1, 2, 3}
 
-	expected := NewOnionMessage(pubkeys[0], blob)
+	tests := []struct {
+		name        string
+		blobSize    int
+		large       bool
+		routingSize int
+		err         error
+	}{
+		{
+			name:        "standard onion",
+			blobSize:    StandardOnionBlobSize,
+			routingSize: StandardRoutingInfoSize,
+		},
+		{
+			name:        "large onion",
+			blobSize:    LargeOnionBlobSize,
+			large:       true,
+			routingSize: LargeRoutingInfoSize,
+		},
+		{
+			name:     "empty onion",
+			blobSize: 0,
+			err:      ErrInvalidOnionBlobSize,
+		},
+		{
+			name:     "between sizes",
+			blobSize: StandardOnionBlobSize + 1,
+			err:      ErrInvalidOnionBlobSize,
+		},
+		{
+			name:     "too large",
+			blobSize: LargeOnionBlobSize + 1,
+			err:      ErrOnionBlobTooLarge,
+		},
+	}
 
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			blob := make([]byte, testCase.blobSize)
+			for i := range blob {
+				blob[i] = byte(i)
+			}
+
+			expected := NewOnionMessage(pubkeys[0], blob)
+
+			buf := new(bytes.Buffer)
+			err := expected.Encode(buf, 0)
+			require.True(t, errors.Is(err, testCase.err))
+
+			if testCase.err != nil {
+				return
+			}
+
+			require.Equal(t, testCase.large, expected.IsLarge())
+			require.Equal(
+				t, testCase.routingSize,
+				expected.RoutingInfoSize(),
+			)
+
+			actual := &OnionMessage{}
+			err = actual.Decode(buf, 0)
+			require.NoError(t, err, "onion decode")
+			require.Equal(t, expected, actual, "message comparison")
+		})
+	}
+}
+
+// TestOnionMessageDecodeSize tests that we reject onion messages that have a
+// blob length that does not match a supported onion packet size.
+func TestOnionMessageDecodeSize(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 1)
+
+	// Write an onion message with a length prefix for a blob that sits
+	// between our standard and large sizes, padded with enough bytes that
+	// we would succeed if we read the blob.
 	buf := new(bytes.Buffer)
-	err := expected.Encode(buf, 0)
-	require.NoError(t, err, "encode onion")
+	buf.Write(pubkeys[0].SerializeCompressed())
+	buf.Write([]byte{0x05, 0x57})
+	buf.Write(make([]byte, 0x0557))
 
-	actual := &OnionMessage{}
-	err = actual.Decode(buf, 0)
-	require.NoError(t, err, "onion decode")
-	require.Equal(t, expected, actual, "message comparison")
+	err := (&OnionMessage{}).Decode(buf, 0)
+	require.True(t, errors.Is(err, ErrInvalidOnionBlobSize))
 }
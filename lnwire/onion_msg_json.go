@@ -0,0 +1,367 @@
+package lnwire
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// onionMessagePayloadJSON is the json representation of an onion message
+// payload. Byte fields are hex encoded, and unknown records are keyed by their
+// tlv type.
+type onionMessagePayloadJSON struct {
+	ReplyPath        *ReplyPath         `json:"reply_path,omitempty"`
+	EncryptedData    string             `json:"encrypted_data,omitempty"`
+	FinalHopPayloads []*FinalHopPayload `json:"final_payloads,omitempty"`
+	UnknownRecords   map[uint64]string  `json:"unknown_records,omitempty"`
+}
+
+// MarshalJSON produces the json representation of an onion message payload.
+func (o OnionMessagePayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&onionMessagePayloadJSON{
+		ReplyPath:        o.ReplyPath,
+		EncryptedData:    hex.EncodeToString(o.EncryptedData),
+		FinalHopPayloads: o.FinalHopPayloads,
+		UnknownRecords:   unknownRecordsToJSON(o.UnknownRecords),
+	})
+}
+
+// UnmarshalJSON populates an onion message payload from its json
+// representation.
+func (o *OnionMessagePayload) UnmarshalJSON(b []byte) error {
+	var payload onionMessagePayloadJSON
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return err
+	}
+
+	var err error
+	decoded := OnionMessagePayload{
+		ReplyPath:        payload.ReplyPath,
+		FinalHopPayloads: payload.FinalHopPayloads,
+	}
+
+	decoded.EncryptedData, err = bytesFromJSON(
+		"encrypted data", payload.EncryptedData,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.UnknownRecords, err = unknownRecordsFromJSON(
+		payload.UnknownRecords,
+	)
+	if err != nil {
+		return err
+	}
+
+	*o = decoded
+
+	return nil
+}
+
+// finalHopPayloadJSON is the json representation of a final hop payload.
+type finalHopPayloadJSON struct {
+	TLVType uint64 `json:"tlv_type"`
+	Value   string `json:"value,omitempty"`
+}
+
+// MarshalJSON produces the json representation of a final hop payload.
+func (f FinalHopPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&finalHopPayloadJSON{
+		TLVType: uint64(f.TLVType),
+		Value:   hex.EncodeToString(f.Value),
+	})
+}
+
+// UnmarshalJSON populates a final hop payload from its json representation.
+func (f *FinalHopPayload) UnmarshalJSON(b []byte) error {
+	var payload finalHopPayloadJSON
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return err
+	}
+
+	value, err := bytesFromJSON("value", payload.Value)
+	if err != nil {
+		return err
+	}
+
+	*f = FinalHopPayload{
+		TLVType: tlv.Type(payload.TLVType),
+		Value:   value,
+	}
+
+	return nil
+}
+
+// replyPathJSON is the json representation of a reply path, with keys
+// expressed as 33 byte compressed pubkeys.
+type replyPathJSON struct {
+	FirstNodeID   string        `json:"first_node_id,omitempty"`
+	BlindingPoint string        `json:"blinding_point,omitempty"`
+	Hops          []*BlindedHop `json:"hops,omitempty"`
+}
+
+// MarshalJSON produces the json representation of a reply path.
+func (r ReplyPath) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&replyPathJSON{
+		FirstNodeID:   pubkeyToJSON(r.FirstNodeID),
+		BlindingPoint: pubkeyToJSON(r.BlindingPoint),
+		Hops:          r.Hops,
+	})
+}
+
+// UnmarshalJSON populates a reply path from its json representation.
+func (r *ReplyPath) UnmarshalJSON(b []byte) error {
+	var path replyPathJSON
+	if err := json.Unmarshal(b, &path); err != nil {
+		return err
+	}
+
+	var err error
+	decoded := ReplyPath{
+		Hops: path.Hops,
+	}
+
+	decoded.FirstNodeID, err = pubkeyFromJSON(
+		"first node id", path.FirstNodeID,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.BlindingPoint, err = pubkeyFromJSON(
+		"blinding point", path.BlindingPoint,
+	)
+	if err != nil {
+		return err
+	}
+
+	*r = decoded
+
+	return nil
+}
+
+// blindedHopJSON is the json representation of a blinded hop.
+type blindedHopJSON struct {
+	BlindedNodeID string `json:"blinded_node_id,omitempty"`
+	EncryptedData string `json:"encrypted_data,omitempty"`
+}
+
+// MarshalJSON produces the json representation of a blinded hop.
+func (b BlindedHop) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&blindedHopJSON{
+		BlindedNodeID: pubkeyToJSON(b.BlindedNodeID),
+		EncryptedData: hex.EncodeToString(b.EncryptedData),
+	})
+}
+
+// UnmarshalJSON populates a blinded hop from its json representation.
+func (b *BlindedHop) UnmarshalJSON(data []byte) error {
+	var hop blindedHopJSON
+	if err := json.Unmarshal(data, &hop); err != nil {
+		return err
+	}
+
+	var err error
+	decoded := BlindedHop{}
+
+	decoded.BlindedNodeID, err = pubkeyFromJSON(
+		"blinded node id", hop.BlindedNodeID,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.EncryptedData, err = bytesFromJSON(
+		"encrypted data", hop.EncryptedData,
+	)
+	if err != nil {
+		return err
+	}
+
+	*b = decoded
+
+	return nil
+}
+
+// relayJSON is the json representation of a payment relay.
+type relayJSON struct {
+	CLTVExpiryDelta           uint16 `json:"cltv_expiry_delta"`
+	FeeProportionalMillionths uint32 `json:"fee_proportional_millionths"`
+	FeeBaseMsat               uint32 `json:"fee_base_msat"`
+}
+
+// constraintsJSON is the json representation of payment constraints.
+type constraintsJSON struct {
+	MaxCLTVExpiry uint32               `json:"max_cltv_expiry"`
+	HTLCMinimum   lndwire.MilliSatoshi `json:"htlc_minimum_msat"`
+}
+
+// blindedRouteDataJSON is the json representation of blinded route data. The
+// short channel id is expressed in its integer form. Allowed features are a
+// pointer so that an empty set is distinguished from an absent record.
+type blindedRouteDataJSON struct {
+	Padding     string            `json:"padding,omitempty"`
+	NextSCID    *uint64           `json:"next_scid,omitempty"`
+	NextNodeID  string            `json:"next_node_id,omitempty"`
+	PathID      string            `json:"path_id,omitempty"`
+	Override    string            `json:"next_blinding_override,omitempty"`
+	Relay       *relayJSON        `json:"payment_relay,omitempty"`
+	Constraints *constraintsJSON  `json:"payment_constraints,omitempty"`
+	Features    *[]uint16         `json:"allowed_features,omitempty"`
+	Unknown     map[uint64]string `json:"unknown_records,omitempty"`
+}
+
+// MarshalJSON produces the json representation of blinded route data.
+func (b BlindedRouteData) MarshalJSON() ([]byte, error) {
+	data := &blindedRouteDataJSON{
+		Padding:    hex.EncodeToString(b.Padding),
+		NextNodeID: pubkeyToJSON(b.NextNodeID),
+		PathID:     hex.EncodeToString(b.PathID),
+		Override:   pubkeyToJSON(b.NextBlindingOverride),
+		Unknown:    unknownRecordsToJSON(b.UnknownRecords),
+	}
+
+	if b.NextSCID != nil {
+		scid := b.NextSCID.ToUint64()
+		data.NextSCID = &scid
+	}
+
+	if b.PaymentRelay != nil {
+		relay := relayJSON(*b.PaymentRelay)
+		data.Relay = &relay
+	}
+
+	if b.PaymentConstraints != nil {
+		constraints := constraintsJSON(*b.PaymentConstraints)
+		data.Constraints = &constraints
+	}
+
+	if b.AllowedFeatures != nil {
+		features := featuresToJSON(b.AllowedFeatures)
+		if features == nil {
+			features = []uint16{}
+		}
+		data.Features = &features
+	}
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON populates blinded route data from its json representation.
+func (b *BlindedRouteData) UnmarshalJSON(data []byte) error {
+	var routeData blindedRouteDataJSON
+	if err := json.Unmarshal(data, &routeData); err != nil {
+		return err
+	}
+
+	var err error
+	decoded := BlindedRouteData{}
+
+	if routeData.NextSCID != nil {
+		scid := lndwire.NewShortChanIDFromInt(*routeData.NextSCID)
+		decoded.NextSCID = &scid
+	}
+
+	if routeData.Relay != nil {
+		relay := PaymentRelay(*routeData.Relay)
+		decoded.PaymentRelay = &relay
+	}
+
+	if routeData.Constraints != nil {
+		constraints := PaymentConstraints(*routeData.Constraints)
+		decoded.PaymentConstraints = &constraints
+	}
+
+	if routeData.Features != nil {
+		decoded.AllowedFeatures = featuresFromJSON(*routeData.Features)
+	}
+
+	decoded.Padding, err = bytesFromJSON("padding", routeData.Padding)
+	if err != nil {
+		return err
+	}
+
+	decoded.PathID, err = bytesFromJSON("path id", routeData.PathID)
+	if err != nil {
+		return err
+	}
+
+	decoded.NextNodeID, err = pubkeyFromJSON(
+		"next node id", routeData.NextNodeID,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.NextBlindingOverride, err = pubkeyFromJSON(
+		"next blinding override", routeData.Override,
+	)
+	if err != nil {
+		return err
+	}
+
+	decoded.UnknownRecords, err = unknownRecordsFromJSON(
+		routeData.Unknown,
+	)
+	if err != nil {
+		return err
+	}
+
+	*b = decoded
+
+	return nil
+}
+
+// bytesFromJSON decodes a hex encoded byte field, returning nil if the string
+// is empty.
+func bytesFromJSON(field, hexStr string) ([]byte, error) {
+	if hexStr == "" {
+		return nil, nil
+	}
+
+	b, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", field, err)
+	}
+
+	return b, nil
+}
+
+// unknownRecordsToJSON hex encodes the values of a set of unknown records,
+// keyed by their tlv type.
+func unknownRecordsToJSON(records tlv.TypeMap) map[uint64]string {
+	if len(records) == 0 {
+		return nil
+	}
+
+	encoded := make(map[uint64]string, len(records))
+	for tlvType, value := range records {
+		encoded[uint64(tlvType)] = hex.EncodeToString(value)
+	}
+
+	return encoded
+}
+
+// unknownRecordsFromJSON decodes a set of hex encoded unknown records.
+func unknownRecordsFromJSON(records map[uint64]string) (tlv.TypeMap, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	decoded := make(tlv.TypeMap, len(records))
+	for tlvType, value := range records {
+		b, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("unknown record %v: %w", tlvType,
+				err)
+		}
+
+		decoded[tlv.Type(tlvType)] = b
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,136 @@
+package lnwire
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gijswijs/boltnd/testutils"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOnionMessagePayloadJSON tests json encoding of onion message payloads,
+// including their reply paths and final hop payloads.
+func TestOnionMessagePayloadJSON(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 3)
+
+	payload := &OnionMessagePayload{
+		ReplyPath: &ReplyPath{
+			FirstNodeID:   pubkeys[0],
+			BlindingPoint: pubkeys[1],
+			Hops: []*BlindedHop{
+				{
+					BlindedNodeID: pubkeys[2],
+					EncryptedData: []byte{1, 2, 3},
+				},
+			},
+		},
+		EncryptedData: []byte{4, 5},
+		FinalHopPayloads: []*FinalHopPayload{
+			{
+				TLVType: InvoiceNamespaceType,
+				Value:   []byte{6},
+			},
+		},
+		UnknownRecords: tlv.TypeMap{
+			101: {7, 8},
+		},
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	require.NoError(t, err, "marshal")
+
+	decoded := &OnionMessagePayload{}
+	require.NoError(t, json.Unmarshal(payloadJSON, decoded), "unmarshal")
+	require.Equal(t, payload, decoded)
+
+	// Check that our byte fields are hex encoded and that unset fields
+	// are omitted.
+	hopJSON, err := json.Marshal(&BlindedHop{
+		EncryptedData: []byte{0xab, 0xcd},
+	})
+	require.NoError(t, err, "marshal hop")
+	require.Equal(t, `{"encrypted_data":"abcd"}`, string(hopJSON))
+
+	finalJSON, err := json.Marshal(&FinalHopPayload{
+		TLVType: InvoiceErrorNamespaceType,
+		Value:   []byte{1},
+	})
+	require.NoError(t, err, "marshal final payload")
+	require.Equal(t, `{"tlv_type":68,"value":"01"}`, string(finalJSON))
+
+	// Invalid values should fail.
+	for _, invalid := range []string{
+		`{"encrypted_data":"zz"}`,
+		`{"reply_path":{"first_node_id":"02"}}`,
+		`{"reply_path":{"hops":[{"encrypted_data":"0"}]}}`,
+		`{"final_payloads":[{"tlv_type":64,"value":"zz"}]}`,
+		`{"unknown_records":{"101":"zz"}}`,
+	} {
+		require.Error(t, json.Unmarshal([]byte(invalid), decoded),
+			invalid)
+	}
+}
+
+// TestBlindedRouteDataJSON tests json encoding of blinded route data.
+func TestBlindedRouteDataJSON(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 2)
+
+	data := &BlindedRouteData{
+		Padding: []byte{0, 0},
+		NextSCID: &lndwire.ShortChannelID{
+			BlockHeight: 700000,
+			TxIndex:     1,
+			TxPosition:  2,
+		},
+		NextNodeID:           pubkeys[0],
+		PathID:               []byte{1, 2, 3},
+		NextBlindingOverride: pubkeys[1],
+		PaymentRelay: &PaymentRelay{
+			CLTVExpiryDelta:           40,
+			FeeProportionalMillionths: 100,
+			FeeBaseMsat:               1000,
+		},
+		PaymentConstraints: &PaymentConstraints{
+			MaxCLTVExpiry: 800000,
+			HTLCMinimum:   1500,
+		},
+		AllowedFeatures: lndwire.EmptyFeatureVector(),
+		UnknownRecords: tlv.TypeMap{
+			5: {4},
+		},
+	}
+
+	dataJSON, err := json.Marshal(data)
+	require.NoError(t, err, "marshal")
+
+	decoded := &BlindedRouteData{}
+	require.NoError(t, json.Unmarshal(dataJSON, decoded), "unmarshal")
+	require.Equal(t, data, decoded)
+
+	// A zero short channel id is still included when it is set.
+	scidJSON, err := json.Marshal(&BlindedRouteData{
+		NextSCID: &lndwire.ShortChannelID{},
+	})
+	require.NoError(t, err, "marshal scid")
+	require.Equal(t, `{"next_scid":0}`, string(scidJSON))
+
+	// An empty set of allowed features is included when it is set.
+	featuresJSON, err := json.Marshal(&BlindedRouteData{
+		AllowedFeatures: lndwire.EmptyFeatureVector(),
+	})
+	require.NoError(t, err, "marshal features")
+	require.Equal(t, `{"allowed_features":[]}`, string(featuresJSON))
+
+	for _, invalid := range []string{
+		`{"padding":"zz"}`,
+		`{"path_id":"zz"}`,
+		`{"next_node_id":"02"}`,
+		`{"next_blinding_override":"02"}`,
+		`{"unknown_records":{"5":"zz"}}`,
+	} {
+		require.Error(t, json.Unmarshal([]byte(invalid), decoded),
+			invalid)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sort"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	sphinx "github.com/lightningnetwork/lightning-onion"
 	"github.com/lightningnetwork/lnd/tlv"
 )
 
@@ -30,6 +32,24 @@ const (
 	// InvoiceNamespaceType is a record containing the sub-namespace of
 	// tlvs that describe an invoice.
 	InvoiceNamespaceType tlv.Type = 66
+
+	// InvoiceErrorNamespaceType is a record containing the sub-namespace
+	// of tlvs that describe an error with an invoice request or invoice.
+	InvoiceErrorNamespaceType tlv.Type = 68
+
+	// ProbeType is a record used to probe whether a destination is
+	// reachable by onion message. Recipients that support probes echo the
+	// record's value back over the reply path included in the message.
+	// This is an odd type in the experimental range, so it is ignored by
+	// recipients that don't understand it.
+	ProbeType tlv.Type = 65537
+
+	// SenderAuthType is a record that authenticates the sender of an
+	// onion message, containing the sender's public key and a signature
+	// over the message's other final hop payloads. This is an odd type in
+	// the experimental range, so it is ignored by recipients that don't
+	// understand it.
+	SenderAuthType tlv.Type = 65539
 )
 
 var (
@@ -41,8 +61,31 @@ var (
 	// ErrNoHops is returned when we handle a reply path that does not
 	// have any hops (this makes no sense).
 	ErrNoHops = errors.New("reply path requires hops")
+
+	// ErrTooManyReplyHops is returned when we handle a reply path that has
+	// more hops than can be included in an onion.
+	ErrTooManyReplyHops = errors.New("reply path has too many hops")
+
+	// ErrInvalidReplyPath is returned when a reply path is missing one of
+	// its public keys, or has hop data that is too long to encode.
+	ErrInvalidReplyPath = errors.New("invalid reply path")
+
+	// ErrIntermediateFinalFields is returned when an intermediate hop's
+	// payload contains fields that are only allowed for the final hop.
+	ErrIntermediateFinalFields = errors.New("intermediate hop payload " +
+		"has final hop fields")
+
+	// ErrNoEncryptedData is returned when an intermediate hop's payload
+	// does not contain encrypted data, which it needs to forward the
+	// message.
+	ErrNoEncryptedData = errors.New("intermediate hop payload requires " +
+		"encrypted data")
 )
 
+// MaxReplyPathHops is the maximum number of hops that we allow in a reply
+// path, which is limited by the number of hops that fit in an onion.
+const MaxReplyPathHops = sphinx.NumMaxHops
+
 // OnionMessagePayload contains the contents of an onion message payload.
 type OnionMessagePayload struct {
 	// ReplyPath contains a blinded path that can be used to respond to an
@@ -54,6 +97,55 @@ type OnionMessagePayload struct {
 
 	// FinalHopPayloads contains any tlvs with type > 64 that
 	FinalHopPayloads []*FinalHopPayload
+
+	// UnknownRecords contains odd tlvs below the final hop payload range
+	// that we did not recognize when decoding the payload. These records
+	// are re-emitted when the payload is encoded, so that we don't strip
+	// forward-compatible fields.
+	UnknownRecords tlv.TypeMap
+}
+
+// isKnownPayloadType returns a boolean indicating whether a tlv type is
+// understood by our onion message payload encoding, including the final hop
+// payload range.
+func isKnownPayloadType(tlvType tlv.Type) bool {
+	return tlvType == replyPathType || tlvType == encryptedDataTLVType ||
+		tlvType >= finalHopPayloadStart
+}
+
+// ValidateIntermediate checks that a payload is valid for an intermediate hop
+// in an onion message's path. Intermediate hops must have encrypted data that
+// tells them where to forward the message, and may not have a reply path or
+// final hop payloads.
+func (o *OnionMessagePayload) ValidateIntermediate() error {
+	if o.ReplyPath != nil {
+		return fmt.Errorf("%w: reply path", ErrIntermediateFinalFields)
+	}
+
+	if len(o.FinalHopPayloads) != 0 {
+		return fmt.Errorf("%w: %v final hop payloads",
+			ErrIntermediateFinalFields, len(o.FinalHopPayloads))
+	}
+
+	if len(o.EncryptedData) == 0 {
+		return ErrNoEncryptedData
+	}
+
+	return nil
+}
+
+// EncodeIntermediatePayload encodes the payload for an intermediate hop in an
+// onion message's path, which only contains the hop's encrypted data.
+func EncodeIntermediatePayload(encryptedData []byte) ([]byte, error) {
+	payload := &OnionMessagePayload{
+		EncryptedData: encryptedData,
+	}
+
+	if err := payload.ValidateIntermediate(); err != nil {
+		return nil, err
+	}
+
+	return EncodeOnionMessagePayload(payload)
 }
 
 // EncodeOnionMessagePayload encodes an onion message's final payload.
@@ -85,6 +177,12 @@ func EncodeOnionMessagePayload(o *OnionMessagePayload) ([]byte, error) {
 		records = append(records, record)
 	}
 
+	unknown, err := unknownRecords(o.UnknownRecords, isKnownPayloadType)
+	if err != nil {
+		return nil, err
+	}
+	records = append(records, unknown...)
+
 	// Sort our records just in case the final hop payload records were
 	// provided in the incorrect order.
 	tlv.SortRecords(records)
@@ -103,7 +201,14 @@ func EncodeOnionMessagePayload(o *OnionMessagePayload) ([]byte, error) {
 }
 
 // DecodeOnionMessagePayload decodes an onion message's payload.
-func DecodeOnionMessagePayload(o []byte) (*OnionMessagePayload, error) {
+func DecodeOnionMessagePayload(o []byte,
+	opts ...DecodeOption) (*OnionMessagePayload, error) {
+
+	err := checkSize(ErrPayloadTooLarge, len(o), MaxPayloadSize)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
 		onionPayload = &OnionMessagePayload{
 			// Create a non-nil entry so that we can directly
@@ -118,6 +223,10 @@ func DecodeOnionMessagePayload(o []byte) (*OnionMessagePayload, error) {
 		invoiceRequestPayload = &FinalHopPayload{
 			TLVType: InvoiceRequestNamespaceType,
 		}
+
+		invoiceErrorPayload = &FinalHopPayload{
+			TLVType: InvoiceErrorNamespaceType,
+		}
 	)
 
 	records := []tlv.Record{
@@ -137,6 +246,14 @@ func DecodeOnionMessagePayload(o []byte) (*OnionMessagePayload, error) {
 		// here, or decoding will fail. We decode directly into a final
 		// hop payload, so that we can just add it if present later.
 		tlv.MakePrimitiveRecord(InvoiceNamespaceType, &invoicePayload.Value),
+		tlv.MakePrimitiveRecord(
+			InvoiceErrorNamespaceType, &invoiceErrorPayload.Value,
+		),
+	}
+
+	err = newDecodeOptions(opts).checkStream(o, records)
+	if err != nil {
+		return nil, err
 	}
 
 	stream, err := tlv.NewStream(records...)
@@ -147,7 +264,8 @@ func DecodeOnionMessagePayload(o []byte) (*OnionMessagePayload, error) {
 	r := bytes.NewReader(o)
 	tlvMap, err := stream.DecodeWithParsedTypes(r)
 	if err != nil {
-		return nil, fmt.Errorf("decode stream: %w", err)
+		return nil, fmt.Errorf("decode stream: %w",
+			annotateDecodeErr(o, records, err))
 	}
 
 	// If our reply path wasn't populated, replace it with a nil entry.
@@ -155,6 +273,14 @@ func DecodeOnionMessagePayload(o []byte) (*OnionMessagePayload, error) {
 		onionPayload.ReplyPath = nil
 	}
 
+	// Retain any odd tlvs below the final hop range that we don't
+	// recognize, final hop payloads are handled separately below.
+	onionPayload.UnknownRecords = parseUnknownRecords(
+		tlvMap, func(tlvType tlv.Type) bool {
+			return tlvType < finalHopPayloadStart
+		},
+	)
+
 	// Once we're decoded our message, we want to also include any tlvs
 	// that are intended for the final hop's payload which we may not have
 	// recognized. We'll just directly read these out and allow higher
@@ -198,6 +324,12 @@ func DecodeOnionMessagePayload(o []byte) (*OnionMessagePayload, error) {
 		)
 	}
 
+	if _, ok := tlvMap[InvoiceErrorNamespaceType]; ok {
+		onionPayload.FinalHopPayloads = append(
+			onionPayload.FinalHopPayloads, invoiceErrorPayload,
+		)
+	}
+
 	// Iteration through maps occurs in random order - sort final hop
 	// payloads in ascending order to make this decoding function
 	// deterministic.
@@ -255,6 +387,90 @@ type ReplyPath struct {
 	Hops []*BlindedHop
 }
 
+// Validate checks that a reply path has a valid number of hops, and that all
+// of its public keys are set. Hops are not required to have encrypted data or
+// distinct blinded node ids, because paths that end in dummy hops repeat the
+// recipient and may not carry data for their final hop.
+func (r *ReplyPath) Validate() error {
+	if len(r.Hops) == 0 {
+		return ErrNoHops
+	}
+
+	if len(r.Hops) > MaxReplyPathHops {
+		return fmt.Errorf("%w: %v > %v", ErrTooManyReplyHops,
+			len(r.Hops), MaxReplyPathHops)
+	}
+
+	if r.FirstNodeID == nil {
+		return fmt.Errorf("%w: first node id required",
+			ErrInvalidReplyPath)
+	}
+
+	if r.BlindingPoint == nil {
+		return fmt.Errorf("%w: blinding point required",
+			ErrInvalidReplyPath)
+	}
+
+	for i, hop := range r.Hops {
+		if hop == nil || hop.BlindedNodeID == nil {
+			return fmt.Errorf("%w: hop %v blinded node id "+
+				"required", ErrInvalidReplyPath, i)
+		}
+
+		if len(hop.EncryptedData) > math.MaxUint16 {
+			return fmt.Errorf("%w: hop %v encrypted data length "+
+				"%v", ErrInvalidReplyPath, i,
+				len(hop.EncryptedData))
+		}
+	}
+
+	return nil
+}
+
+// EncodeReplyPath validates and encodes a reply path, so that it can be
+// stored or transferred outside of an onion message.
+func EncodeReplyPath(r *ReplyPath) ([]byte, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		w   = new(bytes.Buffer)
+		buf [8]byte
+	)
+
+	if err := encodeReplyPath(w, r, &buf); err != nil {
+		return nil, err
+	}
+
+	return w.Bytes(), nil
+}
+
+// DecodeReplyPath decodes and validates a reply path that was encoded with
+// EncodeReplyPath.
+func DecodeReplyPath(b []byte) (*ReplyPath, error) {
+	var (
+		r    = bytes.NewReader(b)
+		path = &ReplyPath{}
+		buf  [8]byte
+	)
+
+	if err := decodeReplyPath(r, path, &buf, uint64(len(b))); err != nil {
+		return nil, err
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: %v trailing bytes",
+			ErrInvalidReplyPath, r.Len())
+	}
+
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
 // record produces a tlv record for a reply path.
 func (r *ReplyPath) record() tlv.Record {
 	return tlv.MakeDynamicRecord(
@@ -279,6 +495,12 @@ func (r *ReplyPath) size() uint64 {
 // encodeReplyPath encodes a reply path tlv.
 func encodeReplyPath(w io.Writer, val interface{}, buf *[8]byte) error {
 	if p, ok := val.(*ReplyPath); ok {
+		// Validate our path before we encode it, so that we don't
+		// overflow our hop count or data lengths.
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
 		if err := tlv.EPubKey(w, &p.FirstNodeID, buf); err != nil {
 			return fmt.Errorf("encode first node id: %w", err)
 		}
@@ -288,10 +510,6 @@ func encodeReplyPath(w io.Writer, val interface{}, buf *[8]byte) error {
 		}
 
 		hopCount := uint8(len(p.Hops))
-		if hopCount == 0 {
-			return ErrNoHops
-		}
-
 		if err := tlv.EUint8(w, &hopCount, buf); err != nil {
 			return fmt.Errorf("encode hop count: %w", err)
 		}
@@ -308,11 +526,26 @@ func encodeReplyPath(w io.Writer, val interface{}, buf *[8]byte) error {
 	return tlv.NewTypeForEncodingErr(val, "*ReplyPath")
 }
 
-// decodeReplyPath decodes a reply path tlv.
+// replyPathHeaderSize is the size of the fields that precede the hops in an
+// encoded reply path: a 33 byte first node id, 33 byte blinding point and
+// 1 byte hop count.
+const replyPathHeaderSize = 33 + 33 + 1
+
+// decodeReplyPath decodes a reply path tlv. The length provided is the number
+// of bytes available for the path, which may be followed by other paths when
+// it is used to decode a set of payment paths. We check the hop count and
+// each hop's length against the bytes remaining before we read them, so that
+// we never read past the path's record or allocate for lengths that could
+// not possibly be contained in it.
 func decodeReplyPath(r io.Reader, val interface{}, buf *[8]byte,
 	l uint64) error {
 
 	if p, ok := val.(*ReplyPath); ok && l > 35 {
+		if l < replyPathHeaderSize {
+			return fmt.Errorf("%w: %v bytes is less than header",
+				ErrInvalidReplyPath, l)
+		}
+
 		err := tlv.DPubKey(r, &p.FirstNodeID, buf, 33)
 		if err != nil {
 			return fmt.Errorf("decode first id: %w", err)
@@ -332,12 +565,22 @@ func decodeReplyPath(r io.Reader, val interface{}, buf *[8]byte,
 			return ErrNoHops
 		}
 
+		// Each hop has at least a blinded node id and data length, so
+		// we can reject hop counts that don't fit in our record.
+		remaining := l - replyPathHeaderSize
+		if uint64(hopCount)*blindedHopHeaderSize > remaining {
+			return fmt.Errorf("%w: %v hops with %v bytes remaining",
+				ErrRecordTooLarge, hopCount, remaining)
+		}
+
 		for i := 0; i < int(hopCount); i++ {
 			hop := &BlindedHop{}
-			if err := decodeBlindedHop(r, hop, buf); err != nil {
+			err := decodeBlindedHop(r, hop, buf, remaining)
+			if err != nil {
 				return fmt.Errorf("decode hop: %w", err)
 			}
 
+			remaining -= hop.size()
 			p.Hops = append(p.Hops, hop)
 		}
 
@@ -354,13 +597,18 @@ type BlindedHop struct {
 	BlindedNodeID *btcec.PublicKey
 
 	// EncryptedData is the encrypted data to be included for the node.
+	// This may be empty or contain only padding, for example for the
+	// dummy hops that a recipient appends to the end of its path.
 	EncryptedData []byte
 }
 
+// blindedHopHeaderSize is the size of the fields that precede a blinded hop's
+// encrypted data: a 33 byte blinded node id and 2 byte data length.
+const blindedHopHeaderSize = 33 + 2
+
 // size returns the encoded size of a blinded hop.
 func (b *BlindedHop) size() uint64 {
-	// 33 byte pubkey + 2 bytes uint16 length + var bytes.
-	return uint64(33 + 2 + len(b.EncryptedData))
+	return uint64(blindedHopHeaderSize + len(b.EncryptedData))
 }
 
 // encodeBlindedHop encodes a blinded hop tlv.
@@ -385,9 +633,18 @@ func encodeBlindedHop(w io.Writer, val interface{}, buf *[8]byte) error {
 	return tlv.NewTypeForEncodingErr(val, "*BlindedHop")
 }
 
-// decodeBlindedHop decodes a blinded hop tlv.
-func decodeBlindedHop(r io.Reader, val interface{}, buf *[8]byte) error {
+// decodeBlindedHop decodes a blinded hop tlv, where l is the maximum number of
+// bytes that the hop may occupy. The hop's data length is checked against
+// this limit before we allocate for its encrypted data.
+func decodeBlindedHop(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
 	if b, ok := val.(*BlindedHop); ok {
+		if l < blindedHopHeaderSize {
+			return fmt.Errorf("%w: %v bytes is less than hop "+
+				"header", ErrRecordTooLarge, l)
+		}
+
 		err := tlv.DPubKey(r, &b.BlindedNodeID, buf, 33)
 		if err != nil {
 			return fmt.Errorf("decode blinded id: %w", err)
@@ -399,6 +656,20 @@ func decodeBlindedHop(r io.Reader, val interface{}, buf *[8]byte) error {
 			return fmt.Errorf("decode data len: %w", err)
 		}
 
+		err = checkSize(
+			ErrEncryptedDataTooLarge, int(dataLen),
+			MaxEncryptedDataSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		if uint64(dataLen) > l-blindedHopHeaderSize {
+			return fmt.Errorf("%w: encrypted data %v bytes with %v "+
+				"remaining", ErrRecordTooLarge, dataLen,
+				l-blindedHopHeaderSize)
+		}
+
 		err = tlv.DVarBytes(r, &b.EncryptedData, buf, uint64(dataLen))
 		if err != nil {
 			return fmt.Errorf("decode data: %w", err)
@@ -34,7 +34,7 @@ func TestOnionPayloadEncoding(t *testing.T) {
 }
 
 // TestOnionPayloadFinalHop tests decoding of onion messages that have final
-// hop payload tlvs that our code is not familiar with, and filtering out of
+// hop payload tlvs that our code is not familiar with, and retention of
 // unknown out-of-range values.
 func TestOnionPayloadFinalHop(t *testing.T) {
 	pubkeys := testutils.GetPubkeys(t, 2)
@@ -59,18 +59,18 @@ func TestOnionPayloadFinalHop(t *testing.T) {
 
 	// Create another unknown record, but this one is not in the range of
 	// records reserved for the final hop.
-	filteredRecordType := finalHopPayloadStart - 1
-	filteredRecordValue := []byte{3, 2, 1}
+	unknownRecordType := finalHopPayloadStart - 1
+	unknownRecordValue := []byte{3, 2, 1}
 
-	// Manually encode our onion payload, because our encoding won't
-	// include the record we want to test filtering out on decode.
+	// Manually encode our onion payload, so that we can test decoding of
+	// a record that our encoding does not understand.
 	records := []tlv.Record{
 		encoded.ReplyPath.record(),
 		tlv.MakePrimitiveRecord(
 			encryptedDataTLVType, &encoded.EncryptedData,
 		),
 		tlv.MakePrimitiveRecord(
-			filteredRecordType, &filteredRecordValue,
+			unknownRecordType, &unknownRecordValue,
 		),
 		tlv.MakePrimitiveRecord(
 			finalHopPayload.TLVType, &finalHopPayload.Value,
@@ -87,9 +87,29 @@ func TestOnionPayloadFinalHop(t *testing.T) {
 	require.NoError(t, err, "decode")
 
 	// Assert that our final decoded payload contains the final hop
-	// payload, but not the out-of-range, unknown value odd tlv.
+	// payload, and retains the out-of-range, unknown odd tlv separately.
+	encoded.UnknownRecords = tlv.TypeMap{
+		unknownRecordType: unknownRecordValue,
+	}
 	require.Equal(t, encoded, decoded, "payloads")
 
+	// Re-encoding our decoded payload should re-emit the unknown record.
+	reencoded, err := EncodeOnionMessagePayload(decoded)
+	require.NoError(t, err, "re-encode")
+	require.Equal(t, b.Bytes(), reencoded)
+
+	// Unknown records must be odd, and must not be in the final hop range.
+	for _, invalid := range []tlv.Type{
+		finalHopPayloadStart - 2, finalHopPayloadStart + 1,
+	} {
+		_, err := EncodeOnionMessagePayload(&OnionMessagePayload{
+			UnknownRecords: tlv.TypeMap{
+				invalid: {1},
+			},
+		})
+		require.True(t, errors.Is(err, ErrInvalidUnknownRecord))
+	}
+
 	// Add a tlv that is outside of the final hop range to our final hop
 	// payloads and assert that we fail to encode the payload.
 	outOfRange := &FinalHopPayload{
@@ -133,10 +153,13 @@ func TestReplyPathEncoding(t *testing.T) {
 
 		hops = mockHops(t)
 
-		// For reuse encoding/decoding.
-		b [8]byte
+		tooManyHops = make([]*BlindedHop, MaxReplyPathHops+1)
 	)
 
+	for i := range tooManyHops {
+		tooManyHops[i] = hops[0]
+	}
+
 	tests := []struct {
 		name    string
 		err     error
@@ -170,14 +193,55 @@ func TestReplyPathEncoding(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "too many hops",
+			encoded: &ReplyPath{
+				FirstNodeID:   pubkeys[0],
+				BlindingPoint: pubkeys[1],
+				Hops:          tooManyHops,
+			},
+			err: ErrTooManyReplyHops,
+		},
+		{
+			name: "no first node id",
+			encoded: &ReplyPath{
+				BlindingPoint: pubkeys[1],
+				Hops: []*BlindedHop{
+					hops[0],
+				},
+			},
+			err: ErrInvalidReplyPath,
+		},
+		{
+			name: "no blinding point",
+			encoded: &ReplyPath{
+				FirstNodeID: pubkeys[0],
+				Hops: []*BlindedHop{
+					hops[0],
+				},
+			},
+			err: ErrInvalidReplyPath,
+		},
+		{
+			name: "no blinded node id",
+			encoded: &ReplyPath{
+				FirstNodeID:   pubkeys[0],
+				BlindingPoint: pubkeys[1],
+				Hops: []*BlindedHop{
+					{
+						EncryptedData: []byte{1},
+					},
+				},
+			},
+			err: ErrInvalidReplyPath,
+		},
 	}
 
 	for _, testCase := range tests {
 		testCase := testCase
 
 		t.Run(testCase.name, func(t *testing.T) {
-			w := new(bytes.Buffer)
-			err := encodeReplyPath(w, testCase.encoded, &b)
+			encodedBytes, err := EncodeReplyPath(testCase.encoded)
 			require.True(t, errors.Is(err, testCase.err))
 
 			// If we expect an error on encoding, just kill the test here.
@@ -185,21 +249,62 @@ func TestReplyPathEncoding(t *testing.T) {
 				return
 			}
 
-			encodedBytes := w.Bytes()
-			encodedBytesLen := len(encodedBytes)
-
-			decoded := &ReplyPath{}
-			r := bytes.NewReader(encodedBytes)
-
-			err = decodeReplyPath(
-				r, decoded, &b, uint64(encodedBytesLen),
-			)
+			decoded, err := DecodeReplyPath(encodedBytes)
 			require.NoError(t, err)
 			require.Equal(t, testCase.encoded, decoded)
+
+			// Trailing bytes after our path are rejected.
+			_, err = DecodeReplyPath(append(encodedBytes, 1))
+			require.True(t, errors.Is(err, ErrInvalidReplyPath))
 		})
 	}
 }
 
+// TestReplyPathDummyHops tests that reply paths which end in dummy hops,
+// repeating the recipient's blinded node id with empty or padded data,
+// round trip through encoding and validation.
+func TestReplyPathDummyHops(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 3)
+
+	path := &ReplyPath{
+		FirstNodeID:   pubkeys[0],
+		BlindingPoint: pubkeys[1],
+		Hops: []*BlindedHop{
+			{
+				BlindedNodeID: pubkeys[0],
+				EncryptedData: []byte{1, 2, 3},
+			},
+			{
+				BlindedNodeID: pubkeys[2],
+				EncryptedData: make([]byte, 3),
+			},
+			{
+				BlindedNodeID: pubkeys[2],
+				EncryptedData: []byte{},
+			},
+		},
+	}
+	require.NoError(t, path.Validate())
+
+	encoded, err := EncodeReplyPath(path)
+	require.NoError(t, err)
+
+	decoded, err := DecodeReplyPath(encoded)
+	require.NoError(t, err)
+	require.Equal(t, path, decoded)
+
+	// The same path should survive being carried in an onion message
+	// payload.
+	payload, err := EncodeOnionMessagePayload(&OnionMessagePayload{
+		ReplyPath: path,
+	})
+	require.NoError(t, err)
+
+	decodedPayload, err := DecodeOnionMessagePayload(payload)
+	require.NoError(t, err)
+	require.Equal(t, path, decodedPayload.ReplyPath)
+}
+
 // TestBlindedHopEncoding tests encoding and decoding of individual blinded
 // hops.
 func TestBlindedHopEncoding(t *testing.T) {
@@ -222,8 +327,90 @@ func TestBlindedHopEncoding(t *testing.T) {
 
 	r := bytes.NewReader(encodedBytes)
 	decodedHop := &BlindedHop{}
-	err = decodeBlindedHop(r, decodedHop, &b)
+	err = decodeBlindedHop(
+		r, decodedHop, &b, uint64(len(encodedBytes)),
+	)
 	require.NoError(t, err, "decode")
 
 	require.Equal(t, encodedHop, decodedHop, "hops differ")
+
+	// Decoding with fewer bytes available than the hop's data length
+	// should fail before we read the data.
+	r = bytes.NewReader(encodedBytes)
+	err = decodeBlindedHop(
+		r, &BlindedHop{}, &b, uint64(len(encodedBytes)-1),
+	)
+	require.True(t, errors.Is(err, ErrRecordTooLarge))
+}
+
+// TestIntermediatePayload tests validation and encoding of payloads for
+// intermediate hops.
+func TestIntermediatePayload(t *testing.T) {
+	hops := mockHops(t)
+
+	tests := []struct {
+		name    string
+		payload *OnionMessagePayload
+		err     error
+	}{
+		{
+			name: "valid",
+			payload: &OnionMessagePayload{
+				EncryptedData: []byte{1, 2, 3},
+			},
+		},
+		{
+			name:    "no encrypted data",
+			payload: &OnionMessagePayload{},
+			err:     ErrNoEncryptedData,
+		},
+		{
+			name: "reply path",
+			payload: &OnionMessagePayload{
+				EncryptedData: []byte{1, 2, 3},
+				ReplyPath: &ReplyPath{
+					FirstNodeID:   hops[0].BlindedNodeID,
+					BlindingPoint: hops[1].BlindedNodeID,
+					Hops:          hops,
+				},
+			},
+			err: ErrIntermediateFinalFields,
+		},
+		{
+			name: "final hop payloads",
+			payload: &OnionMessagePayload{
+				EncryptedData: []byte{1, 2, 3},
+				FinalHopPayloads: []*FinalHopPayload{
+					{
+						TLVType: InvoiceNamespaceType,
+						Value:   []byte{4},
+					},
+				},
+			},
+			err: ErrIntermediateFinalFields,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.payload.ValidateIntermediate()
+			require.True(t, errors.Is(err, testCase.err))
+		})
+	}
+
+	// Assert that our intermediate payload round trips with only its
+	// encrypted data.
+	encoded, err := EncodeIntermediatePayload([]byte{1, 2, 3})
+	require.NoError(t, err, "encode")
+
+	decoded, err := DecodeOnionMessagePayload(encoded)
+	require.NoError(t, err, "decode")
+	require.Equal(t, &OnionMessagePayload{
+		EncryptedData: []byte{1, 2, 3},
+	}, decoded)
+
+	_, err = EncodeIntermediatePayload(nil)
+	require.True(t, errors.Is(err, ErrNoEncryptedData))
 }
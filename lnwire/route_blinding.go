@@ -2,35 +2,167 @@ package lnwire
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/tlv"
 )
 
 const (
+	// paddingType is a record type for padding that is used to obscure
+	// the length of blinded route data.
+	paddingType tlv.Type = 1
+
+	// nextSCIDType is a record type for the short channel id of the
+	// channel to the next hop.
+	nextSCIDType tlv.Type = 2
+
 	// nextNodeType is a record type for the unblinded next node ID.
 	nextNodeType tlv.Type = 4
 
+	// pathIDType is a record type for the path ID that the recipient of a
+	// blinded route included for itself.
+	pathIDType tlv.Type = 6
+
 	// nextBlindingOverride is a record type containing a blinding override.
 	nextBlindingOverride tlv.Type = 8
 )
 
+var (
+	// ErrOverrideWithoutNext is returned when blinded route data contains
+	// a next blinding override without identifying the next hop that the
+	// override is for.
+	ErrOverrideWithoutNext = errors.New("next blinding override " +
+		"requires next node id or short channel id")
+
+	// ErrPathIDNotFinal is returned when we try to encode blinded route
+	// data for an intermediate hop that contains a path ID, which is only
+	// included for the final hop in a route.
+	ErrPathIDNotFinal = errors.New("path id only allowed for final hop")
+
+	// ErrAmbiguousNextHop is returned when we try to encode blinded route
+	// data that identifies the next hop by both node id and short channel
+	// id.
+	ErrAmbiguousNextHop = errors.New("next hop must be identified by " +
+		"either next node id or short channel id, not both")
+)
+
 // BlindedRouteData holds the fields that we encrypt in route blinding blobs.
 type BlindedRouteData struct {
+	// Padding is optional padding that is used to ensure that all hops in
+	// a blinded route have data of the same length. The contents of the
+	// padding are ignored.
+	Padding []byte
+
+	// NextSCID is the short channel id of the channel to the next hop in
+	// the route, which can be used to identify the next hop in place of
+	// its node id.
+	NextSCID *lndwire.ShortChannelID
+
 	// NextNodeID is the unblinded node id of the next hop in the route.
 	NextNodeID *btcec.PublicKey
 
+	// PathID is an optional identifier that the creator of a blinded
+	// route includes in the data for its own final hop, allowing it to
+	// identify messages that are sent over the route.
+	PathID []byte
+
 	// NextBlindingOverride is an optional blinding override used to switch
 	// out ephemeral keys.
 	NextBlindingOverride *btcec.PublicKey
+
+	// PaymentRelay holds the fees and cltv delta that a hop charges for
+	// relaying payments in a blinded payment route. It is not used by
+	// onion messages.
+	PaymentRelay *PaymentRelay
+
+	// PaymentConstraints holds the constraints that a hop places on
+	// payments in a blinded payment route. It is not used by onion
+	// messages.
+	PaymentConstraints *PaymentConstraints
+
+	// AllowedFeatures is the set of features that payments in a blinded
+	// payment route may use. A non-nil vector is encoded even if it is
+	// empty, because the presence of the record is meaningful.
+	AllowedFeatures *lndwire.FeatureVector
+
+	// UnknownRecords contains odd tlvs that we did not recognize when
+	// decoding the data. These records are re-emitted when the data is
+	// encoded, so that we don't strip forward-compatible fields.
+	UnknownRecords tlv.TypeMap
+}
+
+// isKnownRouteDataType returns a boolean indicating whether a tlv type is
+// understood by our blinded route data encoding.
+func isKnownRouteDataType(tlvType tlv.Type) bool {
+	switch tlvType {
+	case paddingType, nextSCIDType, nextNodeType, pathIDType,
+		nextBlindingOverride, paymentRelayType, paymentConstraintsType,
+		allowedFeaturesType:
+
+		return true
+
+	default:
+		return false
+	}
 }
 
-// EncodeBlindedRouteData encodes a blinded route tlv stream.
+// Validate checks that blinded route data is well formed. A next blinding
+// override switches the blinding point used by the next hop, so it must
+// appear alongside a next node id or short channel id.
+func (b *BlindedRouteData) Validate() error {
+	if b.NextBlindingOverride != nil && !b.hasNextHop() {
+		return ErrOverrideWithoutNext
+	}
+
+	return nil
+}
+
+// hasNextHop returns a boolean indicating whether the data identifies a next
+// hop, which is the case for all but the final hop in a route.
+func (b *BlindedRouteData) hasNextHop() bool {
+	return b.NextNodeID != nil || b.NextSCID != nil
+}
+
+// EncodeBlindedRouteData encodes a blinded route tlv stream. Path IDs may only
+// be included in data for the final hop in a route. This is not enforced when
+// decoding, so that recipients can surface the more specific error of a relay
+// hop containing a path ID.
+//
+// The next hop may be identified by node id or short channel id, but not both.
+// Readers that receive both use the node id, so we accept this when decoding.
 func EncodeBlindedRouteData(data *BlindedRouteData) ([]byte, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	if data.NextNodeID != nil && data.NextSCID != nil {
+		return nil, ErrAmbiguousNextHop
+	}
+
+	if data.PathID != nil && data.hasNextHop() {
+		return nil, ErrPathIDNotFinal
+	}
+
 	w := new(bytes.Buffer)
 
 	var records []tlv.Record
 
+	if data.Padding != nil {
+		paddingRecord := tlv.MakePrimitiveRecord(
+			paddingType, &data.Padding,
+		)
+		records = append(records, paddingRecord)
+	}
+
+	if data.NextSCID != nil {
+		scid := data.NextSCID.ToUint64()
+		scidRecord := tlv.MakePrimitiveRecord(nextSCIDType, &scid)
+		records = append(records, scidRecord)
+	}
+
 	if data.NextNodeID != nil {
 		nodeIDRecord := tlv.MakePrimitiveRecord(
 			nextNodeType, &data.NextNodeID,
@@ -38,6 +170,13 @@ func EncodeBlindedRouteData(data *BlindedRouteData) ([]byte, error) {
 		records = append(records, nodeIDRecord)
 	}
 
+	if data.PathID != nil {
+		pathIDRecord := tlv.MakePrimitiveRecord(
+			pathIDType, &data.PathID,
+		)
+		records = append(records, pathIDRecord)
+	}
+
 	if data.NextBlindingOverride != nil {
 		overrideRecord := tlv.MakePrimitiveRecord(
 			nextBlindingOverride, &data.NextBlindingOverride,
@@ -45,6 +184,40 @@ func EncodeBlindedRouteData(data *BlindedRouteData) ([]byte, error) {
 		records = append(records, overrideRecord)
 	}
 
+	if data.PaymentRelay != nil {
+		records = append(records, paymentRelayRecord(data.PaymentRelay))
+	}
+
+	if data.PaymentConstraints != nil {
+		records = append(
+			records, paymentConstraintsRecord(
+				data.PaymentConstraints,
+			),
+		)
+	}
+
+	if data.AllowedFeatures != nil {
+		features, err := EncodeFeatures(data.AllowedFeatures)
+		if err != nil {
+			return nil, err
+		}
+
+		featuresRecord := tlv.MakePrimitiveRecord(
+			allowedFeaturesType, &features,
+		)
+		records = append(records, featuresRecord)
+	}
+
+	unknown, err := unknownRecords(
+		data.UnknownRecords, isKnownRouteDataType,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	records = append(records, unknown...)
+	tlv.SortRecords(records)
+
 	stream, err := tlv.NewStream(records...)
 	if err != nil {
 		return nil, err
@@ -58,16 +231,42 @@ func EncodeBlindedRouteData(data *BlindedRouteData) ([]byte, error) {
 }
 
 // DecodeBlindedRouteData decodes a blinded route tlv stream.
-func DecodeBlindedRouteData(data []byte) (*BlindedRouteData, error) {
+func DecodeBlindedRouteData(data []byte,
+	opts ...DecodeOption) (*BlindedRouteData, error) {
+
+	err := checkSize(
+		ErrEncryptedDataTooLarge, len(data), MaxEncryptedDataSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	r := bytes.NewReader(data)
 
-	var routeData = &BlindedRouteData{}
+	var (
+		routeData   = &BlindedRouteData{}
+		scid        uint64
+		relay       PaymentRelay
+		constraints PaymentConstraints
+		features    []byte
+	)
 
 	records := []tlv.Record{
+		tlv.MakePrimitiveRecord(paddingType, &routeData.Padding),
+		tlv.MakePrimitiveRecord(nextSCIDType, &scid),
 		tlv.MakePrimitiveRecord(nextNodeType, &routeData.NextNodeID),
+		tlv.MakePrimitiveRecord(pathIDType, &routeData.PathID),
 		tlv.MakePrimitiveRecord(
 			nextBlindingOverride, &routeData.NextBlindingOverride,
 		),
+		paymentRelayRecord(&relay),
+		paymentConstraintsRecord(&constraints),
+		tlv.MakePrimitiveRecord(allowedFeaturesType, &features),
+	}
+
+	err = newDecodeOptions(opts).checkStream(data, records)
+	if err != nil {
+		return nil, err
 	}
 
 	stream, err := tlv.NewStream(records...)
@@ -75,7 +274,48 @@ func DecodeBlindedRouteData(data []byte) (*BlindedRouteData, error) {
 		return nil, err
 	}
 
-	if err := stream.Decode(r); err != nil {
+	tlvMap, err := stream.DecodeWithParsedTypes(r)
+	if err != nil {
+		return nil, annotateDecodeErr(data, records, err)
+	}
+
+	if _, ok := tlvMap[nextSCIDType]; ok {
+		nextSCID := lndwire.NewShortChanIDFromInt(scid)
+		routeData.NextSCID = &nextSCID
+	}
+
+	if _, ok := tlvMap[paymentRelayType]; ok {
+		routeData.PaymentRelay = &relay
+	}
+
+	if _, ok := tlvMap[paymentConstraintsType]; ok {
+		routeData.PaymentConstraints = &constraints
+	}
+
+	if _, ok := tlvMap[allowedFeaturesType]; ok {
+		routeData.AllowedFeatures, err = DecodeFeatures(features)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Even records are required to be understood, so we fail if the data
+	// contains any that we don't know rather than retaining records that
+	// we couldn't re-encode.
+	for tlvType, tlvBytes := range tlvMap {
+		if tlvBytes != nil && tlvType%2 == 0 {
+			return nil, fmt.Errorf("%w: type %v", ErrTLVUnknownEven,
+				tlvType)
+		}
+	}
+
+	routeData.UnknownRecords = parseUnknownRecords(
+		tlvMap, func(tlv.Type) bool {
+			return true
+		},
+	)
+
+	if err := routeData.Validate(); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,200 @@
+package lnwire
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+const (
+	// paymentRelayType is a record type for the fees and cltv delta that a
+	// hop in a blinded payment route charges for relaying payments.
+	paymentRelayType tlv.Type = 10
+
+	// paymentConstraintsType is a record type for the constraints that a
+	// hop in a blinded payment route places on the payments it relays.
+	paymentConstraintsType tlv.Type = 12
+
+	// allowedFeaturesType is a record type for the features that may be
+	// used by payments over a blinded payment route.
+	allowedFeaturesType tlv.Type = 14
+
+	// paymentRelayMinSize is the encoded size of a payment relay record
+	// with a zero base fee, which is omitted by its truncated encoding:
+	// a 2 byte cltv delta and a 4 byte proportional fee.
+	paymentRelayMinSize = 2 + 4
+
+	// paymentConstraintsMinSize is the encoded size of a payment
+	// constraints record with a zero htlc minimum, which is omitted by its
+	// truncated encoding: a 4 byte max cltv expiry.
+	paymentConstraintsMinSize = 4
+)
+
+// ErrInvalidPaymentRecord is returned when a blinded payment record has a
+// length that cannot hold its fields.
+var ErrInvalidPaymentRecord = errors.New("invalid blinded payment record " +
+	"length")
+
+// PaymentRelay describes the fees and cltv delta that a hop in a blinded
+// payment route charges for relaying payments to the next hop.
+type PaymentRelay struct {
+	// CLTVExpiryDelta is the cltv delta that the hop requires.
+	CLTVExpiryDelta uint16
+
+	// FeeProportionalMillionths is the proportional fee that the hop
+	// charges.
+	FeeProportionalMillionths uint32
+
+	// FeeBaseMsat is the base fee that the hop charges.
+	FeeBaseMsat uint32
+}
+
+// PaymentConstraints describes the constraints that a hop in a blinded
+// payment route places on the payments that it relays.
+type PaymentConstraints struct {
+	// MaxCLTVExpiry is the maximum expiry that a payment may have when it
+	// reaches the hop, after which the route is considered expired.
+	MaxCLTVExpiry uint32
+
+	// HTLCMinimum is the minimum htlc that the hop will relay.
+	HTLCMinimum lndwire.MilliSatoshi
+}
+
+// paymentRelayRecord creates a tlv record for a payment relay, which holds a
+// truncated base fee.
+func paymentRelayRecord(relay *PaymentRelay) tlv.Record {
+	return tlv.MakeDynamicRecord(
+		paymentRelayType, relay, func() uint64 {
+			return paymentRelayMinSize +
+				tlv.SizeTUint32(relay.FeeBaseMsat)
+		}, encodePaymentRelay, decodePaymentRelay,
+	)
+}
+
+// encodePaymentRelay encodes a payment relay record.
+func encodePaymentRelay(w io.Writer, val interface{}, buf *[8]byte) error {
+	relay, ok := val.(*PaymentRelay)
+	if !ok {
+		return tlv.NewTypeForEncodingErr(val, "payment relay")
+	}
+
+	if err := tlv.EUint16(w, &relay.CLTVExpiryDelta, buf); err != nil {
+		return fmt.Errorf("encode cltv delta: %w", err)
+	}
+
+	err := tlv.EUint32(w, &relay.FeeProportionalMillionths, buf)
+	if err != nil {
+		return fmt.Errorf("encode proportional fee: %w", err)
+	}
+
+	if err := tlv.ETUint32T(w, relay.FeeBaseMsat, buf); err != nil {
+		return fmt.Errorf("encode base fee: %w", err)
+	}
+
+	return nil
+}
+
+// decodePaymentRelay decodes a payment relay record.
+func decodePaymentRelay(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	relay, ok := val.(*PaymentRelay)
+	if !ok {
+		return tlv.NewTypeForDecodingErr(
+			val, "payment relay", l, paymentRelayMinSize,
+		)
+	}
+
+	if l < paymentRelayMinSize || l > paymentRelayMinSize+4 {
+		return fmt.Errorf("%w: payment relay %v bytes",
+			ErrInvalidPaymentRecord, l)
+	}
+
+	err := tlv.DUint16(r, &relay.CLTVExpiryDelta, buf, 2)
+	if err != nil {
+		return fmt.Errorf("decode cltv delta: %w", err)
+	}
+
+	err = tlv.DUint32(r, &relay.FeeProportionalMillionths, buf, 4)
+	if err != nil {
+		return fmt.Errorf("decode proportional fee: %w", err)
+	}
+
+	err = tlv.DTUint32(
+		r, &relay.FeeBaseMsat, buf, l-paymentRelayMinSize,
+	)
+	if err != nil {
+		return fmt.Errorf("decode base fee: %w", err)
+	}
+
+	return nil
+}
+
+// paymentConstraintsRecord creates a tlv record for payment constraints,
+// which hold a truncated htlc minimum.
+func paymentConstraintsRecord(constraints *PaymentConstraints) tlv.Record {
+	return tlv.MakeDynamicRecord(
+		paymentConstraintsType, constraints, func() uint64 {
+			return paymentConstraintsMinSize + tlv.SizeTUint64(
+				uint64(constraints.HTLCMinimum),
+			)
+		}, encodePaymentConstraints, decodePaymentConstraints,
+	)
+}
+
+// encodePaymentConstraints encodes a payment constraints record.
+func encodePaymentConstraints(w io.Writer, val interface{},
+	buf *[8]byte) error {
+
+	constraints, ok := val.(*PaymentConstraints)
+	if !ok {
+		return tlv.NewTypeForEncodingErr(val, "payment constraints")
+	}
+
+	err := tlv.EUint32(w, &constraints.MaxCLTVExpiry, buf)
+	if err != nil {
+		return fmt.Errorf("encode max cltv expiry: %w", err)
+	}
+
+	err = tlv.ETUint64T(w, uint64(constraints.HTLCMinimum), buf)
+	if err != nil {
+		return fmt.Errorf("encode htlc minimum: %w", err)
+	}
+
+	return nil
+}
+
+// decodePaymentConstraints decodes a payment constraints record.
+func decodePaymentConstraints(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	constraints, ok := val.(*PaymentConstraints)
+	if !ok {
+		return tlv.NewTypeForDecodingErr(
+			val, "payment constraints", l,
+			paymentConstraintsMinSize,
+		)
+	}
+
+	if l < paymentConstraintsMinSize || l > paymentConstraintsMinSize+8 {
+		return fmt.Errorf("%w: payment constraints %v bytes",
+			ErrInvalidPaymentRecord, l)
+	}
+
+	err := tlv.DUint32(r, &constraints.MaxCLTVExpiry, buf, 4)
+	if err != nil {
+		return fmt.Errorf("decode max cltv expiry: %w", err)
+	}
+
+	var htlcMin uint64
+	err = tlv.DTUint64(r, &htlcMin, buf, l-paymentConstraintsMinSize)
+	if err != nil {
+		return fmt.Errorf("decode htlc minimum: %w", err)
+	}
+	constraints.HTLCMinimum = lndwire.MilliSatoshi(htlcMin)
+
+	return nil
+}
@@ -1,9 +1,12 @@
 package lnwire
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/gijswijs/boltnd/testutils"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,12 +25,86 @@ func TestRouteBlindingEncoding(t *testing.T) {
 				NextNodeID: pubkeys[0],
 			},
 		},
+		{
+			name: "short channel id",
+			data: &BlindedRouteData{
+				NextSCID: &lndwire.ShortChannelID{
+					BlockHeight: 100,
+					TxIndex:     2,
+					TxPosition:  1,
+				},
+			},
+		},
 		{
 			name: "blinding override",
 			data: &BlindedRouteData{
+				NextNodeID:           pubkeys[0],
 				NextBlindingOverride: pubkeys[0],
 			},
 		},
+		{
+			name: "blinding override - short channel id",
+			data: &BlindedRouteData{
+				NextSCID: &lndwire.ShortChannelID{
+					BlockHeight: 100,
+				},
+				NextBlindingOverride: pubkeys[0],
+			},
+		},
+		{
+			name: "padding",
+			data: &BlindedRouteData{
+				Padding:    []byte{0, 0, 0},
+				NextNodeID: pubkeys[0],
+			},
+		},
+		{
+			// Other implementations may pad with arbitrary
+			// contents, which we decode without error.
+			name: "padding - arbitrary contents",
+			data: &BlindedRouteData{
+				Padding: []byte{1, 2, 3},
+				PathID:  []byte{4, 5, 6},
+			},
+		},
+		{
+			name: "path id",
+			data: &BlindedRouteData{
+				PathID: []byte{1, 2, 3},
+			},
+		},
+		{
+			name: "payment records",
+			data: &BlindedRouteData{
+				NextSCID: &lndwire.ShortChannelID{
+					TxIndex: 1,
+				},
+				PaymentRelay: &PaymentRelay{
+					CLTVExpiryDelta:           36,
+					FeeProportionalMillionths: 150,
+					FeeBaseMsat:               10000,
+				},
+				PaymentConstraints: &PaymentConstraints{
+					MaxCLTVExpiry: 748005,
+					HTLCMinimum:   1500,
+				},
+				AllowedFeatures: lndwire.EmptyFeatureVector(),
+			},
+		},
+		{
+			// Zero base fees and htlc minimums are omitted by
+			// their truncated encodings.
+			name: "payment records - truncated zero values",
+			data: &BlindedRouteData{
+				PathID: []byte{1},
+				PaymentRelay: &PaymentRelay{
+					CLTVExpiryDelta: 144,
+				},
+				PaymentConstraints: &PaymentConstraints{
+					MaxCLTVExpiry: 747777,
+				},
+			},
+		},
 	}
 
 	for _, testCase := range tests {
@@ -44,3 +121,223 @@ func TestRouteBlindingEncoding(t *testing.T) {
 		})
 	}
 }
+
+// TestBlindingOverrideWithoutNext tests that we do not encode or decode
+// blinded route data that contains a next blinding override without a next
+// hop.
+func TestBlindingOverrideWithoutNext(t *testing.T) {
+	pubkey := testutils.GetPubkeys(t, 1)[0]
+
+	data := &BlindedRouteData{
+		NextBlindingOverride: pubkey,
+	}
+
+	_, err := EncodeBlindedRouteData(data)
+	require.ErrorIs(t, err, ErrOverrideWithoutNext)
+
+	// Encode the override record ourselves to test decoding data created
+	// by other implementations.
+	stream, err := tlv.NewStream(tlv.MakePrimitiveRecord(
+		nextBlindingOverride, &data.NextBlindingOverride,
+	))
+	require.NoError(t, err)
+
+	b := new(bytes.Buffer)
+	require.NoError(t, stream.Encode(b))
+
+	_, err = DecodeBlindedRouteData(b.Bytes())
+	require.ErrorIs(t, err, ErrOverrideWithoutNext)
+}
+
+// TestPathIDFinalHop tests that we only encode path IDs in the data for final
+// hops, but still decode them for intermediate hops so that the recipient can
+// reject the data.
+func TestPathIDFinalHop(t *testing.T) {
+	pubkey := testutils.GetPubkeys(t, 1)[0]
+
+	data := &BlindedRouteData{
+		NextNodeID: pubkey,
+		PathID:     []byte{1, 2, 3},
+	}
+
+	_, err := EncodeBlindedRouteData(data)
+	require.ErrorIs(t, err, ErrPathIDNotFinal)
+
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(nextNodeType, &data.NextNodeID),
+		tlv.MakePrimitiveRecord(pathIDType, &data.PathID),
+	)
+	require.NoError(t, err)
+
+	b := new(bytes.Buffer)
+	require.NoError(t, stream.Encode(b))
+
+	decoded, err := DecodeBlindedRouteData(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+}
+
+// TestNextHopExclusive tests that we only encode one of the next node id and
+// short channel id for a hop, but decode data from other implementations that
+// includes both.
+func TestNextHopExclusive(t *testing.T) {
+	pubkey := testutils.GetPubkeys(t, 1)[0]
+
+	scid := lndwire.ShortChannelID{
+		BlockHeight: 700000,
+		TxIndex:     1,
+		TxPosition:  2,
+	}
+
+	data := &BlindedRouteData{
+		NextSCID:   &scid,
+		NextNodeID: pubkey,
+	}
+
+	_, err := EncodeBlindedRouteData(data)
+	require.ErrorIs(t, err, ErrAmbiguousNextHop)
+
+	scidInt := scid.ToUint64()
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(nextSCIDType, &scidInt),
+		tlv.MakePrimitiveRecord(nextNodeType, &data.NextNodeID),
+	)
+	require.NoError(t, err)
+
+	b := new(bytes.Buffer)
+	require.NoError(t, stream.Encode(b))
+
+	decoded, err := DecodeBlindedRouteData(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, data, decoded)
+
+	// Data that only contains a short channel id is encoded as an eight
+	// byte record.
+	encoded, err := EncodeBlindedRouteData(&BlindedRouteData{
+		NextSCID: &scid,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x02, 0x08, 0x0a, 0xae, 0x60, 0x00, 0x00, 0x01, 0x00, 0x02,
+	}, encoded)
+}
+
+// TestRouteDataUnknownRecords tests that we retain unknown odd records when
+// decoding blinded route data, and re-emit them on encode, while rejecting
+// unknown even records.
+func TestRouteDataUnknownRecords(t *testing.T) {
+	var (
+		pubkey = testutils.GetPubkeys(t, 1)[0]
+
+		unknownType  tlv.Type = 5
+		unknownValue          = []byte{1, 2, 3}
+		emptyType    tlv.Type = 7
+		emptyValue            = []byte{}
+	)
+
+	// Encode our data with records that our encoding does not understand,
+	// including one with an empty value.
+	stream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(nextNodeType, &pubkey),
+		tlv.MakePrimitiveRecord(unknownType, &unknownValue),
+		tlv.MakePrimitiveRecord(emptyType, &emptyValue),
+	)
+	require.NoError(t, err)
+
+	b := new(bytes.Buffer)
+	require.NoError(t, stream.Encode(b))
+
+	decoded, err := DecodeBlindedRouteData(b.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, &BlindedRouteData{
+		NextNodeID: pubkey,
+		UnknownRecords: tlv.TypeMap{
+			unknownType: unknownValue,
+			emptyType:   emptyValue,
+		},
+	}, decoded)
+
+	reencoded, err := EncodeBlindedRouteData(decoded)
+	require.NoError(t, err)
+	require.Equal(t, b.Bytes(), reencoded)
+
+	// Data that contains an unknown even record should fail to decode,
+	// because we are required to understand it.
+	evenType := tlv.Type(16)
+	stream, err = tlv.NewStream(
+		tlv.MakePrimitiveRecord(nextNodeType, &pubkey),
+		tlv.MakePrimitiveRecord(evenType, &unknownValue),
+	)
+	require.NoError(t, err)
+
+	b.Reset()
+	require.NoError(t, stream.Encode(b))
+
+	_, err = DecodeBlindedRouteData(b.Bytes())
+	require.ErrorIs(t, err, ErrTLVUnknownEven)
+
+	// Unknown records must be odd, and must not be a type that we know.
+	for _, invalid := range []tlv.Type{paddingType, 10} {
+		_, err := EncodeBlindedRouteData(&BlindedRouteData{
+			UnknownRecords: tlv.TypeMap{
+				invalid: {1},
+			},
+		})
+		require.ErrorIs(t, err, ErrInvalidUnknownRecord)
+	}
+}
+
+// TestPaymentRecordLengths tests decoding of payment relay and constraints
+// records that are too short or too long to hold their fields.
+func TestPaymentRecordLengths(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{
+			name: "payment relay minimum",
+			data: []byte{10, 6, 0, 1, 0, 0, 0, 2},
+		},
+		{
+			name: "payment relay too short",
+			data: []byte{10, 5, 0, 1, 0, 0, 0},
+			err:  ErrInvalidPaymentRecord,
+		},
+		{
+			name: "payment relay too long",
+			data: []byte{10, 11, 0, 1, 0, 0, 0, 2, 1, 0, 0, 0, 0},
+			err:  ErrInvalidPaymentRecord,
+		},
+		{
+			name: "payment relay base fee not minimal",
+			data: []byte{10, 7, 0, 1, 0, 0, 0, 2, 0},
+			err:  tlv.ErrTUintNotMinimal,
+		},
+		{
+			name: "payment constraints minimum",
+			data: []byte{12, 4, 0, 0, 0, 1},
+		},
+		{
+			name: "payment constraints too short",
+			data: []byte{12, 3, 0, 0, 0},
+			err:  ErrInvalidPaymentRecord,
+		},
+		{
+			name: "payment constraints too long",
+			data: []byte{
+				12, 13, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			err: ErrInvalidPaymentRecord,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := DecodeBlindedRouteData(testCase.data)
+			require.ErrorIs(t, err, testCase.err)
+		})
+	}
+}
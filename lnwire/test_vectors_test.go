@@ -0,0 +1,508 @@
+package lnwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// onionMessageTestJSON holds the specification's test vectors for
+	// onion messages sent over a blinded route.
+	onionMessageTestJSON = "blinded-onion-message-onion-test.json"
+
+	// routeBlindingTestJSON holds the specification's test vectors for
+	// blinded payment routes.
+	routeBlindingTestJSON = "route-blinding-test.json"
+
+	// unknownTagPrefix is the prefix used for odd records that are not
+	// defined by the specification in the test vectors' tlv fields.
+	unknownTagPrefix = "unknown_tag_"
+)
+
+// vectorTLVs holds the tlv fields that the test vectors list for blinded
+// route data, keyed by field name.
+type vectorTLVs map[string]json.RawMessage
+
+// vectorBlindedHop is a hop in a blinded route in the test vectors.
+type vectorBlindedHop struct {
+	BlindedNodeID string `json:"blinded_node_id"`
+	EncryptedData string `json:"encrypted_data"`
+	RecipientData string `json:"encrypted_recipient_data"`
+}
+
+// vectorRoute is a blinded route in the test vectors. The onion message and
+// payment vectors use different names for the route's fields.
+type vectorRoute struct {
+	FirstNodeID        string              `json:"first_node_id"`
+	FirstPathKey       string              `json:"first_path_key"`
+	IntroductionNodeID string              `json:"introduction_node_id"`
+	Blinding           string              `json:"blinding"`
+	Hops               []*vectorBlindedHop `json:"hops"`
+}
+
+// vectorRouteDataHop holds the blinded route data that is created for a hop
+// in the test vectors.
+type vectorRouteDataHop struct {
+	Alias            string     `json:"alias"`
+	TLVs             vectorTLVs `json:"tlvs"`
+	EncryptedDataTLV string     `json:"encrypted_data_tlv"`
+	EncodedTLVs      string     `json:"encoded_tlvs"`
+}
+
+// vectorDecryptHop holds the onion message that a hop in the onion message
+// test vectors receives.
+type vectorDecryptHop struct {
+	Alias        string `json:"alias"`
+	PrivKey      string `json:"privkey"`
+	OnionMessage string `json:"onion_message"`
+	NextNodeID   string `json:"next_node_id"`
+	TLVs         struct {
+		UnknownTag1   string `json:"unknown_tag_1"`
+		RecipientData string `json:"encrypted_recipient_data"`
+	} `json:"tlvs"`
+}
+
+// vectorUnblindHop holds the values that a hop in the payment test vectors
+// produces when it unblinds its route data.
+type vectorUnblindHop struct {
+	Alias           string `json:"alias"`
+	NodePrivKey     string `json:"node_privkey"`
+	EphemeralPubkey string `json:"ephemeral_pubkey"`
+	DecryptedData   string `json:"decrypted_data"`
+	NextEphemeral   string `json:"next_ephemeral_pubkey"`
+	NextOverride    string `json:"next_ephemeral_pubkey_override"`
+}
+
+// onionMessageVector is the set of onion message test vectors.
+type onionMessageVector struct {
+	Generate struct {
+		Hops []*vectorRouteDataHop `json:"hops"`
+	} `json:"generate"`
+
+	Route vectorRoute `json:"route"`
+
+	Decrypt struct {
+		Hops []*vectorDecryptHop `json:"hops"`
+	} `json:"decrypt"`
+}
+
+// routeBlindingVector is the set of blinded payment route test vectors.
+type routeBlindingVector struct {
+	Generate struct {
+		Hops []*vectorRouteDataHop `json:"hops"`
+	} `json:"generate"`
+
+	Route vectorRoute `json:"route"`
+
+	Unblind struct {
+		Hops []*vectorUnblindHop `json:"hops"`
+	} `json:"unblind"`
+}
+
+// readVector reads the json test vector file provided into the value
+// provided.
+func readVector(t *testing.T, file string, vector interface{}) {
+	vectorBytes, err := os.ReadFile(file)
+	require.NoError(t, err, "read file")
+
+	require.NoError(t, json.Unmarshal(vectorBytes, vector), "unmarshal")
+}
+
+// vectorHex decodes a hex string from the test vectors.
+func vectorHex(t *testing.T, hexStr string) []byte {
+	b, err := hex.DecodeString(hexStr)
+	require.NoError(t, err, "hex: %v", hexStr)
+
+	return b
+}
+
+// vectorPubkey decodes a hex encoded pubkey from the test vectors.
+func vectorPubkey(t *testing.T, hexStr string) *btcec.PublicKey {
+	pubkey, err := btcec.ParsePubKey(vectorHex(t, hexStr))
+	require.NoError(t, err, "pubkey: %v", hexStr)
+
+	return pubkey
+}
+
+// vectorRouter creates and starts a sphinx router for the hex encoded private
+// key provided. The router is stopped when the test completes.
+func vectorRouter(t *testing.T, privKeyHex string) *sphinx.Router {
+	privkey, _ := btcec.PrivKeyFromBytes(vectorHex(t, privKeyHex))
+
+	router := sphinx.NewRouter(
+		&sphinx.PrivKeyECDH{PrivKey: privkey},
+		sphinx.NewMemoryReplayLog(),
+	)
+	require.NoError(t, router.Start(), "start router")
+	t.Cleanup(router.Stop)
+
+	return router
+}
+
+// vectorReplyPath creates a reply path from a blinded route in the test
+// vectors.
+func vectorReplyPath(t *testing.T, introNode, blinding string,
+	hops []*vectorBlindedHop) *ReplyPath {
+
+	path := &ReplyPath{
+		FirstNodeID:   vectorPubkey(t, introNode),
+		BlindingPoint: vectorPubkey(t, blinding),
+	}
+
+	for _, hop := range hops {
+		encrypted := hop.EncryptedData
+		if encrypted == "" {
+			encrypted = hop.RecipientData
+		}
+
+		path.Hops = append(path.Hops, &BlindedHop{
+			BlindedNodeID: vectorPubkey(t, hop.BlindedNodeID),
+			EncryptedData: vectorHex(t, encrypted),
+		})
+	}
+
+	return path
+}
+
+// requireRouteDataTLVs checks that decoded blinded route data matches the
+// tlv fields that the test vectors list for it.
+func requireRouteDataTLVs(t *testing.T, tlvs vectorTLVs,
+	data *BlindedRouteData) {
+
+	var unknown tlv.TypeMap
+
+	for field, value := range tlvs {
+		var str string
+
+		switch field {
+		case "payment_relay":
+			var relay relayJSON
+			require.NoError(t, json.Unmarshal(value, &relay))
+			require.Equal(
+				t, PaymentRelay(relay), *data.PaymentRelay,
+			)
+
+			continue
+
+		case "payment_constraints":
+			var constraints constraintsJSON
+			require.NoError(t, json.Unmarshal(value, &constraints))
+			require.Equal(
+				t, PaymentConstraints(constraints),
+				*data.PaymentConstraints,
+			)
+
+			continue
+
+		case "allowed_features":
+			var features struct {
+				Features []uint16 `json:"features"`
+			}
+			require.NoError(t, json.Unmarshal(value, &features))
+			require.NotNil(t, data.AllowedFeatures)
+			require.ElementsMatch(
+				t, features.Features,
+				featuresToJSON(data.AllowedFeatures),
+			)
+
+			continue
+		}
+
+		require.NoError(t, json.Unmarshal(value, &str), field)
+
+		switch {
+		case field == "padding":
+			require.Equal(t, vectorHex(t, str), data.Padding)
+
+		case field == "short_channel_id":
+			var scid lndwire.ShortChannelID
+			_, err := fmt.Sscanf(
+				str, "%dx%dx%d", &scid.BlockHeight,
+				&scid.TxIndex, &scid.TxPosition,
+			)
+			require.NoError(t, err, "scid: %v", str)
+			require.Equal(t, &scid, data.NextSCID)
+
+		case field == "next_node_id":
+			require.Equal(t, vectorPubkey(t, str), data.NextNodeID)
+
+		case field == "path_id":
+			require.Equal(t, vectorHex(t, str), data.PathID)
+
+		case field == "next_blinding_override",
+			field == "next_path_key_override":
+
+			require.Equal(
+				t, vectorPubkey(t, str),
+				data.NextBlindingOverride,
+			)
+
+		// The secret used to create an override is included to
+		// generate the vectors, and is not part of the encoding.
+		case field == "path_key_override_secret":
+
+		case strings.HasPrefix(field, unknownTagPrefix):
+			tlvType, err := strconv.ParseUint(
+				strings.TrimPrefix(field, unknownTagPrefix),
+				10, 64,
+			)
+			require.NoError(t, err, field)
+
+			if unknown == nil {
+				unknown = make(tlv.TypeMap)
+			}
+			unknown[tlv.Type(tlvType)] = vectorHex(t, str)
+
+		default:
+			t.Fatalf("unexpected tlv field: %v", field)
+		}
+	}
+
+	require.Equal(t, unknown, data.UnknownRecords)
+}
+
+// requireRouteDataVector decodes the encoded blinded route data provided,
+// checks it against the vector's tlv fields and checks that it re-encodes to
+// the same bytes.
+func requireRouteDataVector(t *testing.T, tlvs vectorTLVs,
+	encoded []byte) {
+
+	for _, opts := range [][]DecodeOption{nil, {OptionStrictTLV()}} {
+		data, err := DecodeBlindedRouteData(encoded, opts...)
+		require.NoError(t, err, "decode")
+
+		requireRouteDataTLVs(t, tlvs, data)
+
+		reencoded, err := EncodeBlindedRouteData(data)
+		require.NoError(t, err, "encode")
+		require.Equal(t, encoded, reencoded)
+	}
+}
+
+// requireReplyPathVector checks that a blinded route from the test vectors
+// round trips through our reply path encoding.
+func requireReplyPathVector(t *testing.T, path *ReplyPath) {
+	encoded, err := EncodeReplyPath(path)
+	require.NoError(t, err, "encode")
+
+	decoded, err := DecodeReplyPath(encoded)
+	require.NoError(t, err, "decode")
+	require.Equal(t, path, decoded)
+}
+
+// TestOnionMessageVectors tests our encoding against the specification's
+// onion message test vectors. Each hop's blinded route data must decode to
+// the fields that the vectors list and re-encode byte-for-byte, and peeling
+// the onion message at each hop must produce the message that is sent to the
+// next hop.
+func TestOnionMessageVectors(t *testing.T) {
+	var vector onionMessageVector
+	readVector(t, onionMessageTestJSON, &vector)
+
+	generate := vector.Generate.Hops
+	for _, hop := range generate {
+		hop := hop
+
+		t.Run("route data "+hop.Alias, func(t *testing.T) {
+			requireRouteDataVector(
+				t, hop.TLVs, vectorHex(t, hop.EncryptedDataTLV),
+			)
+		})
+	}
+
+	t.Run("route", func(t *testing.T) {
+		requireReplyPathVector(t, vectorReplyPath(
+			t, vector.Route.FirstNodeID, vector.Route.FirstPathKey,
+			vector.Route.Hops,
+		))
+	})
+
+	decrypt := vector.Decrypt.Hops
+	require.Len(t, decrypt, len(generate))
+
+	for i, hop := range decrypt {
+		i, hop := i, hop
+
+		t.Run("decrypt "+hop.Alias, func(t *testing.T) {
+			msgBytes := vectorHex(t, hop.OnionMessage)
+			require.EqualValues(
+				t, OnionMessageType,
+				binary.BigEndian.Uint16(msgBytes[:2]),
+			)
+
+			msg := &OnionMessage{}
+			err := msg.Decode(bytes.NewReader(msgBytes[2:]), 0)
+			require.NoError(t, err, "decode message")
+
+			encoded := new(bytes.Buffer)
+			require.NoError(t, msg.Encode(encoded, 0))
+			require.Equal(t, msgBytes[2:], encoded.Bytes())
+
+			router := vectorRouter(t, hop.PrivKey)
+
+			packet := &sphinx.OnionPacket{}
+			err = packet.Decode(bytes.NewReader(msg.OnionBlob))
+			require.NoError(t, err, "decode packet")
+
+			processed, err := router.ProcessOnionPacket(
+				packet, nil, 0,
+				sphinx.WithBlindingPoint(msg.BlindingPoint),
+			)
+			require.NoError(t, err, "process packet")
+
+			payload, err := DecodeOnionMessagePayload(
+				processed.Payload.Payload,
+			)
+			require.NoError(t, err, "decode payload")
+
+			reencoded, err := EncodeOnionMessagePayload(payload)
+			require.NoError(t, err, "encode payload")
+			require.Equal(
+				t, processed.Payload.Payload, reencoded,
+			)
+
+			// The final hop's payload holds the message for the
+			// recipient, which is sent in an odd record. We check
+			// the encrypted data before decrypting it, because
+			// it is decrypted in place.
+			final := hop.NextNodeID == ""
+			if final {
+				require.EqualValues(
+					t, sphinx.ExitNode, processed.Action,
+				)
+				require.Equal(
+					t, vectorHex(t, hop.TLVs.RecipientData),
+					payload.EncryptedData,
+				)
+				require.Equal(t, tlv.TypeMap{
+					1: vectorHex(t, hop.TLVs.UnknownTag1),
+				}, payload.UnknownRecords)
+			}
+
+			routeDataBytes, err := router.DecryptBlindedHopData(
+				msg.BlindingPoint, payload.EncryptedData,
+			)
+			require.NoError(t, err, "decrypt route data")
+			require.Equal(
+				t, vectorHex(t, generate[i].EncryptedDataTLV),
+				routeDataBytes,
+			)
+
+			routeData, err := DecodeBlindedRouteData(routeDataBytes)
+			require.NoError(t, err, "decode route data")
+
+			if final {
+				return
+			}
+
+			require.EqualValues(
+				t, sphinx.MoreHops, processed.Action,
+			)
+			require.Equal(
+				t, vectorPubkey(t, hop.NextNodeID),
+				routeData.NextNodeID,
+			)
+
+			nextBlinding := routeData.NextBlindingOverride
+			if nextBlinding == nil {
+				nextBlinding, err = router.NextEphemeral(
+					msg.BlindingPoint,
+				)
+				require.NoError(t, err, "next ephemeral")
+			}
+
+			nextPacket := new(bytes.Buffer)
+			err = processed.NextPacket.Encode(nextPacket)
+			require.NoError(t, err, "encode next packet")
+
+			nextMsg := NewOnionMessage(
+				nextBlinding, nextPacket.Bytes(),
+			)
+			nextBytes := new(bytes.Buffer)
+			require.NoError(t, nextMsg.Encode(nextBytes, 0))
+
+			expected := vectorHex(t, decrypt[i+1].OnionMessage)
+			require.Equal(t, expected[2:], nextBytes.Bytes())
+		})
+	}
+}
+
+// TestRouteBlindingVectors tests our encoding against the specification's
+// blinded payment route test vectors, which include the payment relay,
+// payment constraints and allowed features records that other
+// implementations include in blinded payment paths.
+func TestRouteBlindingVectors(t *testing.T) {
+	var vector routeBlindingVector
+	readVector(t, routeBlindingTestJSON, &vector)
+
+	for _, hop := range vector.Generate.Hops {
+		hop := hop
+
+		t.Run("route data "+hop.Alias, func(t *testing.T) {
+			requireRouteDataVector(
+				t, hop.TLVs, vectorHex(t, hop.EncodedTLVs),
+			)
+		})
+	}
+
+	t.Run("route", func(t *testing.T) {
+		requireReplyPathVector(t, vectorReplyPath(
+			t, vector.Route.IntroductionNodeID,
+			vector.Route.Blinding, vector.Route.Hops,
+		))
+	})
+
+	unblind := vector.Unblind.Hops
+	require.Len(t, unblind, len(vector.Route.Hops))
+
+	for i, hop := range unblind {
+		i, hop := i, hop
+
+		t.Run("unblind "+hop.Alias, func(t *testing.T) {
+			router := vectorRouter(t, hop.NodePrivKey)
+			ephemeral := vectorPubkey(t, hop.EphemeralPubkey)
+
+			decrypted, err := router.DecryptBlindedHopData(
+				ephemeral, vectorHex(
+					t, vector.Route.Hops[i].EncryptedData,
+				),
+			)
+			require.NoError(t, err, "decrypt")
+			require.Equal(
+				t, vectorHex(t, hop.DecryptedData), decrypted,
+			)
+
+			data, err := DecodeBlindedRouteData(decrypted)
+			require.NoError(t, err, "decode")
+
+			next, err := router.NextEphemeral(ephemeral)
+			require.NoError(t, err, "next ephemeral")
+			require.Equal(
+				t, vectorPubkey(t, hop.NextEphemeral), next,
+			)
+
+			if hop.NextOverride == "" {
+				require.Nil(t, data.NextBlindingOverride)
+				return
+			}
+
+			require.Equal(
+				t, vectorPubkey(t, hop.NextOverride),
+				data.NextBlindingOverride,
+			)
+		})
+	}
+}
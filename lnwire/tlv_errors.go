@@ -0,0 +1,92 @@
+package lnwire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// TLVStreamError is returned when a tlv stream fails validation or decoding,
+// identifying the record that caused the failure so that differences between
+// implementations' encodings can be tracked down.
+type TLVStreamError struct {
+	// Type is the offending record type.
+	Type tlv.Type
+
+	// Offset is the byte offset of the start of the offending record in
+	// the stream.
+	Offset int
+
+	// Length is the declared length of the offending record.
+	Length uint64
+
+	// Err is the underlying error.
+	Err error
+}
+
+// newStreamError creates a stream error for the record provided.
+func newStreamError(record streamRecord, err error) *TLVStreamError {
+	return &TLVStreamError{
+		Type:   record.Type,
+		Offset: record.Offset,
+		Length: record.Length,
+		Err:    err,
+	}
+}
+
+// Error returns the string representation of a stream error.
+func (e *TLVStreamError) Error() string {
+	return fmt.Sprintf("tlv type %v at offset %v (length %v): %v", e.Type,
+		e.Offset, e.Length, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TLVStreamError) Unwrap() error {
+	return e.Err
+}
+
+// annotateDecodeErr attempts to identify the record in a tlv stream that
+// caused a decoding error, since lnd's tlv decoding does not report which
+// record failed. We walk the stream looking for the first record that is out
+// of order or can't be decoded on its own by the records provided, and return
+// a TLVStreamError for it. If we can't identify the record, the original error
+// is returned.
+func annotateDecodeErr(b []byte, records []tlv.Record, err error) error {
+	var streamErr *TLVStreamError
+	if errors.As(err, &streamErr) {
+		return err
+	}
+
+	known := make(map[tlv.Type]tlv.Record, len(records))
+	for _, record := range records {
+		known[record.Type()] = record
+	}
+
+	var (
+		prev  tlv.Type
+		first = true
+	)
+
+	walkErr := walkStream(b, func(record streamRecord) error {
+		if !first && record.Type <= prev {
+			return err
+		}
+		prev, first = record.Type, false
+
+		knownRecord, ok := known[record.Type]
+		if !ok {
+			return nil
+		}
+
+		return knownRecord.Decode(
+			bytes.NewReader(record.Value), record.Length,
+		)
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	return err
+}
@@ -0,0 +1,95 @@
+package lnwire
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodeErrorAnnotation tests that decoding failures identify the type,
+// offset and length of the record that could not be decoded.
+func TestDecodeErrorAnnotation(t *testing.T) {
+	pubkey := testutils.GetPubkeys(t, 1)[0].SerializeCompressed()
+
+	// Create a node id record that we can place in streams, and a
+	// padding record that precedes it.
+	nodeID := append([]byte{byte(nextNodeType), 33}, pubkey...)
+	padding := []byte{byte(paddingType), 2, 0, 0}
+
+	tests := []struct {
+		name   string
+		decode func() error
+		tlv    tlv.Type
+		offset int
+		length uint64
+	}{
+		{
+			name: "invalid record value",
+			decode: func() error {
+				_, err := DecodeBlindedRouteData(append(
+					padding, byte(nextNodeType), 3, 1, 2,
+					3,
+				))
+				return err
+			},
+			tlv:    nextNodeType,
+			offset: 4,
+			length: 3,
+		},
+		{
+			name: "records out of order",
+			decode: func() error {
+				_, err := DecodeBlindedRouteData(append(
+					nodeID, padding...,
+				))
+				return err
+			},
+			tlv:    paddingType,
+			offset: 35,
+			length: 2,
+		},
+		{
+			name: "truncated record",
+			decode: func() error {
+				_, err := DecodeInvoiceError([]byte{5, 3, 'a'})
+				return err
+			},
+			tlv:    5,
+			offset: 0,
+			length: 3,
+		},
+		{
+			name: "invalid reply path",
+			decode: func() error {
+				_, err := DecodeOnionMessagePayload([]byte{
+					byte(replyPathType), 36,
+					1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12,
+					13, 14, 15, 16, 17, 18, 19, 20, 21, 22,
+					23, 24, 25, 26, 27, 28, 29, 30, 31, 32,
+					33, 34, 35, 36,
+				})
+				return err
+			},
+			tlv:    replyPathType,
+			offset: 0,
+			length: 36,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := testCase.decode()
+
+			var streamErr *TLVStreamError
+			require.True(t, errors.As(err, &streamErr), err)
+			require.Equal(t, testCase.tlv, streamErr.Type)
+			require.Equal(t, testCase.offset, streamErr.Offset)
+			require.Equal(t, testCase.length, streamErr.Length)
+		})
+	}
+}
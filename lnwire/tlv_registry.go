@@ -0,0 +1,123 @@
+package lnwire
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// BuiltinOwner is the owner of the tlv types that are claimed by our own
+// implementation of the specification.
+const BuiltinOwner = "boltnd"
+
+var (
+	// ErrTypeClaimed is returned when we try to claim a tlv type that has
+	// already been claimed by another owner.
+	ErrTypeClaimed = errors.New("tlv type already claimed")
+
+	// ErrTypeReserved is returned when we try to claim a tlv type that is
+	// outside of the final hop payload range.
+	ErrTypeReserved = errors.New("tlv type reserved")
+
+	// ErrTypeNotClaimed is returned when we try to release a tlv type that
+	// has not been claimed by the owner provided.
+	ErrTypeNotClaimed = errors.New("tlv type not claimed")
+
+	// ErrNoOwner is returned when we try to claim a tlv type without
+	// identifying its owner.
+	ErrNoOwner = errors.New("tlv type owner required")
+)
+
+// builtinTypes is the set of final hop payload types that we consume
+// ourselves, and so claim for our own use. The bolt12 namespaces are not
+// handled by our messenger, so they are left for a single application (such
+// as an offers implementation) to claim.
+var builtinTypes = []tlv.Type{
+	ProbeType,
+	SenderAuthType,
+}
+
+// TypeRegistry tracks the owners of final hop payload tlv types, so that
+// multiple applications that share a node don't receive each other's
+// payloads. Each type may only be claimed by a single owner at a time.
+type TypeRegistry struct {
+	// claims maps each claimed tlv type to its owner.
+	claims map[tlv.Type]string
+
+	lock sync.Mutex
+}
+
+// NewTypeRegistry creates a tlv type registry with the types that we use for
+// our built in functionality already claimed by BuiltinOwner.
+func NewTypeRegistry() *TypeRegistry {
+	registry := &TypeRegistry{
+		claims: make(map[tlv.Type]string),
+	}
+
+	for _, tlvType := range builtinTypes {
+		registry.claims[tlvType] = BuiltinOwner
+	}
+
+	return registry
+}
+
+// Claim claims a tlv type for the owner provided. Claims must be within the
+// final hop payload range, and will fail if the type has been claimed by
+// another owner. Repeated claims by the same owner succeed.
+func (r *TypeRegistry) Claim(tlvType tlv.Type, owner string) error {
+	if owner == "" {
+		return ErrNoOwner
+	}
+
+	if tlvType < finalHopPayloadStart {
+		return fmt.Errorf("%w: %v below final hop payload range",
+			ErrTypeReserved, tlvType)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	current, ok := r.claims[tlvType]
+	if ok && current != owner {
+		return fmt.Errorf("%w: %v claimed by %v", ErrTypeClaimed,
+			tlvType, current)
+	}
+
+	r.claims[tlvType] = owner
+
+	return nil
+}
+
+// Release releases an owner's claim on a tlv type. Types claimed by
+// BuiltinOwner can't be released.
+func (r *TypeRegistry) Release(tlvType tlv.Type, owner string) error {
+	if owner == BuiltinOwner {
+		return fmt.Errorf("%w: %v is built in", ErrTypeReserved,
+			tlvType)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if current, ok := r.claims[tlvType]; !ok || current != owner {
+		return fmt.Errorf("%w: %v by %v", ErrTypeNotClaimed, tlvType,
+			owner)
+	}
+
+	delete(r.claims, tlvType)
+
+	return nil
+}
+
+// Owner returns the owner of a tlv type, and a boolean indicating whether the
+// type has been claimed.
+func (r *TypeRegistry) Owner(tlvType tlv.Type) (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	owner, ok := r.claims[tlvType]
+
+	return owner, ok
+}
@@ -0,0 +1,136 @@
+package lnwire
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTypeRegistry tests claiming and releasing tlv types in our registry.
+func TestTypeRegistry(t *testing.T) {
+	const (
+		app1 = "app 1"
+		app2 = "app 2"
+
+		appType tlv.Type = 101
+	)
+
+	tests := []struct {
+		name     string
+		register func(r *TypeRegistry) error
+		err      error
+	}{
+		{
+			name: "claim",
+			register: func(r *TypeRegistry) error {
+				return r.Claim(appType, app1)
+			},
+		},
+		{
+			name: "repeat claim by owner",
+			register: func(r *TypeRegistry) error {
+				if err := r.Claim(appType, app1); err != nil {
+					return err
+				}
+
+				return r.Claim(appType, app1)
+			},
+		},
+		{
+			name: "claim collision",
+			register: func(r *TypeRegistry) error {
+				if err := r.Claim(appType, app1); err != nil {
+					return err
+				}
+
+				return r.Claim(appType, app2)
+			},
+			err: ErrTypeClaimed,
+		},
+		{
+			name: "claim after release",
+			register: func(r *TypeRegistry) error {
+				if err := r.Claim(appType, app1); err != nil {
+					return err
+				}
+
+				if err := r.Release(appType, app1); err != nil {
+					return err
+				}
+
+				return r.Claim(appType, app2)
+			},
+		},
+		{
+			name: "claim built in type",
+			register: func(r *TypeRegistry) error {
+				return r.Claim(SenderAuthType, app1)
+			},
+			err: ErrTypeClaimed,
+		},
+		{
+			name: "claim bolt12 namespace",
+			register: func(r *TypeRegistry) error {
+				err := r.Claim(InvoiceNamespaceType, app1)
+				if err != nil {
+					return err
+				}
+
+				return r.Claim(InvoiceNamespaceType, app2)
+			},
+			err: ErrTypeClaimed,
+		},
+		{
+			name: "claim reserved range",
+			register: func(r *TypeRegistry) error {
+				return r.Claim(finalHopPayloadStart-1, app1)
+			},
+			err: ErrTypeReserved,
+		},
+		{
+			name: "claim without owner",
+			register: func(r *TypeRegistry) error {
+				return r.Claim(appType, "")
+			},
+			err: ErrNoOwner,
+		},
+		{
+			name: "release by other owner",
+			register: func(r *TypeRegistry) error {
+				if err := r.Claim(appType, app1); err != nil {
+					return err
+				}
+
+				return r.Release(appType, app2)
+			},
+			err: ErrTypeNotClaimed,
+		},
+		{
+			name: "release built in type",
+			register: func(r *TypeRegistry) error {
+				return r.Release(ProbeType, BuiltinOwner)
+			},
+			err: ErrTypeReserved,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			registry := NewTypeRegistry()
+
+			err := testCase.register(registry)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			// Our built in types should always remain claimed.
+			for _, tlvType := range builtinTypes {
+				owner, ok := registry.Owner(tlvType)
+				require.True(t, ok)
+				require.Equal(t, BuiltinOwner, owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package lnwire
+
+import (
+	"errors"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+var (
+	// ErrTLVNotAscending is returned in strict mode when a tlv stream's
+	// records are not in ascending type order.
+	ErrTLVNotAscending = errors.New("tlv records not in ascending order")
+
+	// ErrTLVDuplicate is returned in strict mode when a tlv stream
+	// contains more than one record of the same type.
+	ErrTLVDuplicate = errors.New("duplicate tlv record")
+
+	// ErrTLVUnknownEven is returned in strict mode when a tlv stream
+	// contains an even record that we don't understand. Even records are
+	// required to be understood by the recipient.
+	ErrTLVUnknownEven = errors.New("unknown even tlv record")
+)
+
+// decodeOptions holds the options that apply to our decoders.
+type decodeOptions struct {
+	// strict indicates that we should enforce canonical tlv encoding.
+	strict bool
+}
+
+// DecodeOption is a functional option for lnwire decoders.
+type DecodeOption func(*decodeOptions)
+
+// OptionStrictTLV enables strict validation of the tlv streams that we
+// decode, for use in spec-conformance testing. In strict mode, we fail if
+// records are out of order, duplicated, or have an even type that the decoder
+// does not understand, returning a TLVStreamError that identifies the
+// offending type. By default, we rely on lnd's tlv decoding, which rejects
+// unordered and duplicate records without identifying them, and retains
+// unknown even records.
+func OptionStrictTLV() DecodeOption {
+	return func(o *decodeOptions) {
+		o.strict = true
+	}
+}
+
+// newDecodeOptions applies the options provided to our default options.
+func newDecodeOptions(opts []DecodeOption) *decodeOptions {
+	options := &decodeOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options
+}
+
+// checkStream validates a tlv stream that is decoded with the records
+// provided. We always check that the stream's record lengths are within
+// bounds, and additionally enforce canonical encoding in strict mode.
+func (o *decodeOptions) checkStream(b []byte, records []tlv.Record) error {
+	if !o.strict {
+		return checkRecordLengths(b)
+	}
+
+	known := make(map[tlv.Type]struct{}, len(records))
+	for _, record := range records {
+		known[record.Type()] = struct{}{}
+	}
+
+	return validateCanonicalStream(b, func(tlvType tlv.Type) bool {
+		_, ok := known[tlvType]
+		return ok
+	})
+}
+
+// validateCanonicalStream checks that a tlv stream has strictly ascending
+// record types, and that all of its even records are understood by the
+// known function provided.
+func validateCanonicalStream(b []byte, known func(tlv.Type) bool) error {
+	var (
+		prev  tlv.Type
+		first = true
+	)
+
+	return walkStream(b, func(record streamRecord) error {
+		tlvType := record.Type
+
+		switch {
+		case first:
+
+		case tlvType == prev:
+			return ErrTLVDuplicate
+
+		case tlvType < prev:
+			return ErrTLVNotAscending
+		}
+
+		if tlvType%2 == 0 && !known(tlvType) {
+			return ErrTLVUnknownEven
+		}
+
+		prev, first = tlvType, false
+
+		return nil
+	})
+}
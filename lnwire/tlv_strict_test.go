@@ -0,0 +1,117 @@
+package lnwire
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStrictTLV tests decoding of tlv streams with and without strict
+// validation enabled.
+func TestStrictTLV(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded []byte
+		strict  bool
+		err     error
+
+		// errType is the type that we expect a strict validation
+		// failure to identify.
+		errType tlv.Type
+	}{
+		{
+			name:    "valid stream",
+			encoded: []byte{1, 1, 2, 5, 1, 'a'},
+			strict:  true,
+		},
+		{
+			name:    "unknown odd",
+			encoded: []byte{5, 1, 'a', 7, 0},
+			strict:  true,
+		},
+		{
+			name:    "not ascending",
+			encoded: []byte{5, 1, 'a', 3, 1, 1},
+			strict:  true,
+			err:     ErrTLVNotAscending,
+			errType: 3,
+		},
+		{
+			name:    "duplicate",
+			encoded: []byte{5, 1, 'a', 5, 1, 'b'},
+			strict:  true,
+			err:     ErrTLVDuplicate,
+			errType: 5,
+		},
+		{
+			name:    "unknown even",
+			encoded: []byte{5, 1, 'a', 6, 0},
+			strict:  true,
+			err:     ErrTLVUnknownEven,
+			errType: 6,
+		},
+		{
+			name:    "unknown even - not strict",
+			encoded: []byte{5, 1, 'a', 6, 0},
+		},
+		{
+			name:    "truncated value",
+			encoded: []byte{5, 3, 'a'},
+			strict:  true,
+			err:     ErrRecordTooLarge,
+			errType: 5,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			var opts []DecodeOption
+			if testCase.strict {
+				opts = append(opts, OptionStrictTLV())
+			}
+
+			_, err := DecodeInvoiceError(testCase.encoded, opts...)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err == nil {
+				return
+			}
+
+			var streamErr *TLVStreamError
+			require.True(t, errors.As(err, &streamErr))
+			require.Equal(t, testCase.errType, streamErr.Type)
+		})
+	}
+}
+
+// TestStrictTLVOnionPayload tests that strict validation of onion message
+// payloads accepts all of the final hop payloads that we understand.
+func TestStrictTLVOnionPayload(t *testing.T) {
+	payload := &OnionMessagePayload{
+		FinalHopPayloads: []*FinalHopPayload{
+			{
+				TLVType: InvoiceRequestNamespaceType,
+				Value:   []byte{1},
+			},
+			{
+				TLVType: InvoiceNamespaceType,
+				Value:   []byte{2},
+			},
+			{
+				TLVType: InvoiceErrorNamespaceType,
+				Value:   []byte{3},
+			},
+		},
+	}
+
+	encoded, err := EncodeOnionMessagePayload(payload)
+	require.NoError(t, err)
+
+	decoded, err := DecodeOnionMessagePayload(encoded, OptionStrictTLV())
+	require.NoError(t, err)
+	require.Equal(t, payload.FinalHopPayloads, decoded.FinalHopPayloads)
+}
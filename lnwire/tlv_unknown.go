@@ -0,0 +1,64 @@
+package lnwire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// ErrInvalidUnknownRecord is returned when we try to encode an unknown record
+// that is even, or that has a type that our encoding already understands.
+var ErrInvalidUnknownRecord = errors.New("invalid unknown record")
+
+// parseUnknownRecords returns the records in a decoded stream's parsed types
+// that were not recognized by our decoding, so that they can be re-emitted
+// when the stream is encoded. Records that we recognized have a nil value in
+// the parsed types, while unknown records have a non-nil (possibly empty)
+// value. Types that the filter provided returns false for are skipped. If no
+// unknown records are found, a nil map is returned.
+func parseUnknownRecords(parsedTypes tlv.TypeMap,
+	include func(tlv.Type) bool) tlv.TypeMap {
+
+	var unknown tlv.TypeMap
+
+	for tlvType, tlvBytes := range parsedTypes {
+		if tlvBytes == nil || !include(tlvType) {
+			continue
+		}
+
+		if unknown == nil {
+			unknown = make(tlv.TypeMap)
+		}
+
+		unknown[tlvType] = tlvBytes
+	}
+
+	return unknown
+}
+
+// unknownRecords validates a set of unknown records and returns raw records
+// that re-emit their values. Unknown records must be odd, so that recipients
+// that do not understand them can ignore them, and must not have a type that
+// the known function provided identifies as understood by our encoding.
+func unknownRecords(unknown tlv.TypeMap,
+	known func(tlv.Type) bool) ([]tlv.Record, error) {
+
+	records := make([]tlv.Record, 0, len(unknown))
+
+	for tlvType, tlvBytes := range unknown {
+		if tlvType%2 == 0 {
+			return nil, fmt.Errorf("%w: even type %v",
+				ErrInvalidUnknownRecord, tlvType)
+		}
+
+		if known(tlvType) {
+			return nil, fmt.Errorf("%w: known type %v",
+				ErrInvalidUnknownRecord, tlvType)
+		}
+
+		records = append(records, rawRecord(tlvType, tlvBytes))
+	}
+
+	return records, nil
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/gijswijs/boltnd/codec"
 	"github.com/gijswijs/boltnd/lnwire"
 )
 
@@ -15,34 +15,50 @@ var (
 
 	// ErrBadHRP is returned when an offer string has the wrong bech32
 	// human readable prefix.
-	ErrBadHRP = fmt.Errorf("incorrect bech32 hrp, should be: %v", offerHRP)
+	ErrBadHRP = fmt.Errorf("incorrect bech32 hrp, should be: %v",
+		codec.OfferHRP)
 )
 
+// EncodeOfferStr encodes an offer as a bech32 offer string.
+func EncodeOfferStr(offer *lnwire.Offer) (string, error) {
+	offerBytes, err := lnwire.EncodeOffer(offer)
+	if err != nil {
+		return "", fmt.Errorf("could not encode offer: %w", err)
+	}
+
+	return codec.Encode(codec.OfferHRP, offerBytes)
+}
+
 // DecodeOfferStr decodes a bech32 encoded offer string, returning our offer
 // type with the information contained in the offer.
 func DecodeOfferStr(offerStr string) (*lnwire.Offer, error) {
-	// First, strip any joining characters / spare whitespace from the
-	// offer.
-	cleanOffer, err := stripOffer(offerStr)
-	if err != nil {
-		return nil, fmt.Errorf("strip offer: %w", err)
-	}
+	return decodeOfferStr(offerStr, func(b []byte) (*lnwire.Offer, error) {
+		return lnwire.DecodeOffer(b)
+	})
+}
+
+// DecodeOfferStrCompat decodes a bech32 encoded offer string that was
+// produced by another bolt 12 implementation, tolerating the differences in
+// encoding outlined in lnwire.DecodeOfferCompat.
+func DecodeOfferStrCompat(offerStr string) (*lnwire.Offer, error) {
+	return decodeOfferStr(offerStr, lnwire.DecodeOfferCompat)
+}
+
+// decodeOfferStr decodes and validates a bech32 encoded offer string, using
+// the decode function provided to decode the offer's tlv stream.
+func decodeOfferStr(offerStr string,
+	decode func([]byte) (*lnwire.Offer, error)) (*lnwire.Offer, error) {
 
-	hrp, data, err := decodeBech32(cleanOffer)
+	hrp, offerBytes, err := codec.Decode(offerStr)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrInvalidOfferStr, err)
 	}
 
-	if hrp != offerHRP {
+	if hrp != codec.OfferHRP {
 		return nil, fmt.Errorf("%w: got: %v", ErrBadHRP, hrp)
 	}
 
-	offerBytes, err := bech32.ConvertBits(data, 5, 8, false)
-	if err != nil {
-		return nil, fmt.Errorf("convert bits: %w", err)
-	}
-
-	offer, err := lnwire.DecodeOffer(offerBytes)
+	offer, err := decode(offerBytes)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode offer: %w", err)
 	}
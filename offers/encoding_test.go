@@ -1,14 +1,23 @@
 package offers
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
+	"strings"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gijswijs/boltnd/codec"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
 	"github.com/stretchr/testify/require"
 )
 
-const formatStringTestJson = "format-string-test.json"
+const (
+	formatStringTestJson       = "format-string-test.json"
+	formatStringCompatTestJson = "format-string-compat-test.json"
+)
 
 type offerFormatTestVector struct {
 	Comment string `json:"comment"`
@@ -17,38 +26,9 @@ type offerFormatTestVector struct {
 }
 
 // TestOfferStringEncoding tests decoding of the test vectors for offer strings.
-// Note that the following test cases are missing:
-//
-// {
-// "comment": "A complete string is valid",
-// "valid": true,
-// "string": "lno1pqps7sjqpgtyzm3qv4uxzmtsd3jjqer9wd3hy6tsw35k7msjzfpy7nz5yqcnygrfdej82um5wf5k2uckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd5xvxg"
-// },
-// {
-// "comment": "Uppercase is valid",
-// "valid": true,
-// "string": "LNO1PQPS7SJQPGTYZM3QV4UXZMTSD3JJQER9WD3HY6TSW35K7MSJZFPY7NZ5YQCNYGRFDEJ82UM5WF5K2UCKYYPWA3EYT44H6TXTXQUQH7LZ5DJGE4AFGFJN7K4RGRKUAG0JSD5XVXG"
-// },
-// {
-// "comment": "+ can join anywhere",
-// "valid": true,
-// "string": "l+no1pqps7sjqpgtyzm3qv4uxzmtsd3jjqer9wd3hy6tsw35k7msjzfpy7nz5yqcnygrfdej82um5wf5k2uckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd5xvxg"
-// },
-// {
-// "comment": "Multiple + can join",
-// "valid": true,
-// "string": "lno1pqps7sjqpgt+yzm3qv4uxzmtsd3jjqer9wd3hy6tsw3+5k7msjzfpy7nz5yqcn+ygrfdej82um5wf5k2uckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd+5xvxg"
-// },
-// {
-// "comment": "+ can be followed by whitespace",
-// "valid": true,
-// "string": "lno1pqps7sjqpgt+ yzm3qv4uxzmtsd3jjqer9wd3hy6tsw3+  5k7msjzfpy7nz5yqcn+\nygrfdej82um5wf5k2uckyypwa3eyt44h6txtxquqh7lz5djge4afgfjn7k4rgrkuag0jsd+\r\n 5xvxg"
-// },
-// {
-// "comment": "+ can be followed by whitespace, UPPERCASE",
-// "valid": true,
-// "string": "LNO1PQPS7SJQPGT+ YZM3QV4UXZMTSD3JJQER9WD3HY6TSW3+  5K7MSJZFPY7NZ5YQCN+\nYGRFDEJ82UM5WF5K2UCKYYPWA3EYT44H6TXTXQUQH7LZ5DJGE4AFGFJN7K4RGRKUAG0JSD+\r\n 5XVXG"
-// },
+// Note that the valid test vectors in the specification use the later offer
+// encoding of other implementations, so they are covered by
+// TestOfferStringCompat.
 func TestOfferStringEncoding(t *testing.T) {
 	vectorBytes, err := ioutil.ReadFile(formatStringTestJson)
 	require.NoError(t, err, "read file")
@@ -66,3 +46,121 @@ func TestOfferStringEncoding(t *testing.T) {
 		})
 	}
 }
+
+// TestOfferStringCompat tests decoding of offer strings in compatibility mode,
+// using the valid offer strings from the specification (which are encoded by
+// other implementations) along with our set of invalid strings.
+func TestOfferStringCompat(t *testing.T) {
+	var testCases []*offerFormatTestVector
+
+	for _, file := range []string{
+		formatStringTestJson, formatStringCompatTestJson,
+	} {
+		vectorBytes, err := ioutil.ReadFile(file)
+		require.NoError(t, err, "read file")
+
+		var vectors []*offerFormatTestVector
+		require.NoError(t, json.Unmarshal(vectorBytes, &vectors))
+
+		testCases = append(testCases, vectors...)
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+
+		t.Run(testCase.Comment, func(t *testing.T) {
+			offer, err := DecodeOfferStrCompat(testCase.String)
+			require.Equal(t, testCase.Valid, err == nil,
+				"error check: %v", err)
+
+			if !testCase.Valid {
+				return
+			}
+
+			require.Equal(t, "An example description",
+				offer.Description)
+			require.Equal(t, "BOLT 12 industries", offer.Issuer)
+			require.NotNil(t, offer.NodeID)
+		})
+	}
+
+	// Our regular decoding should fail for offers that use a different
+	// encoding.
+	vectorBytes, err := ioutil.ReadFile(formatStringCompatTestJson)
+	require.NoError(t, err, "read file")
+
+	var compatVectors []*offerFormatTestVector
+	require.NoError(t, json.Unmarshal(vectorBytes, &compatVectors))
+
+	for _, vector := range compatVectors {
+		_, err := DecodeOfferStr(vector.String)
+		require.Error(t, err, vector.Comment)
+	}
+}
+
+// TestOfferStringCompatVectors tests decoding of offer strings from the
+// specification's offer test vectors, which are produced by other bolt 12
+// implementations and use records that our own encoding doesn't.
+func TestOfferStringCompatVectors(t *testing.T) {
+	// nodeID is the issuer ID used by the specification's test vectors.
+	nodeID := "02eec7245d6b7d2ccb30380bfbe2a3648cd7a942653f5aa340edcea" +
+		"1f283686619"
+
+	tests := []struct {
+		name        string
+		offerStr    string
+		description string
+		metadata    []byte
+	}{
+		{
+			name: "with metadata",
+			offerStr: "lno1qsgqqqqqqqqqqqqqqqqqqqqqqqqqqzsv23jhxap" +
+				"qwejkxar0wfe3vggzamrjghtt05kvkvpcp0a79gmy3n" +
+				"t6jsn98ad2xs8de6sl9qmgvcvs",
+			description: "Test vectors",
+			metadata:    make([]byte, 16),
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			offer, err := DecodeOfferStrCompat(testCase.offerStr)
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.description, offer.Description)
+			require.Equal(t, testCase.metadata, offer.Metadata)
+			require.Equal(t, nodeID, hex.EncodeToString(
+				offer.NodeID.SerializeCompressed(),
+			))
+		})
+	}
+}
+
+// TestEncodeOfferStr tests round trip encoding of offer strings.
+func TestEncodeOfferStr(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 1)
+
+	offer := &lnwire.Offer{
+		Description: "offer",
+		NodeID:      pubkeys[0],
+	}
+
+	offerStr, err := EncodeOfferStr(offer)
+	require.NoError(t, err, "encode")
+	require.True(t, strings.HasPrefix(offerStr, codec.OfferHRP+"1"))
+
+	decoded, err := DecodeOfferStr(offerStr)
+	require.NoError(t, err, "decode")
+
+	root, err := offer.CalculateMerkleRoot()
+	require.NoError(t, err, "merkle root")
+
+	require.Equal(t, offer.Description, decoded.Description)
+	require.Equal(
+		t, schnorr.SerializePubKey(offer.NodeID),
+		schnorr.SerializePubKey(decoded.NodeID),
+	)
+	require.Equal(t, root, decoded.MerkleRoot)
+}
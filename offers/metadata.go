@@ -0,0 +1,155 @@
+package offers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightningnetwork/lnd/lntypes"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+)
+
+const (
+	// payerInfoNonceLength is the length of the random nonce that is
+	// included in the payer info we generate.
+	payerInfoNonceLength = 16
+
+	// payerInfoLength is the total length of the payer info we generate:
+	// nonce || hmac.
+	payerInfoLength = payerInfoNonceLength + sha256.Size
+)
+
+var (
+	// ErrInvalidPayerInfo is returned when payer info was not generated
+	// by us for the offer and payer key provided.
+	ErrInvalidPayerInfo = errors.New("payer info not generated by our " +
+		"secret")
+
+	// ErrPayerInfoMismatch is returned when an invoice does not echo the
+	// payer info of the invoice request it is in response to.
+	ErrPayerInfoMismatch = errors.New("invoice payer info does not match " +
+		"request")
+
+	// ErrPayerKeyMismatch is returned when an invoice does not contain the
+	// payer key of the invoice request it is in response to.
+	ErrPayerKeyMismatch = errors.New("invoice payer key does not match " +
+		"request")
+
+	// ErrOfferIDMismatch is returned when an invoice is not for the offer
+	// of the invoice request it is in response to.
+	ErrOfferIDMismatch = errors.New("invoice offer id does not match " +
+		"request")
+)
+
+// NewPayerInfo generates payer info for an outgoing invoice request, created
+// from a random nonce and a hmac over the nonce, offer ID and payer key using
+// the secret provided. This allows us to authenticate invoices as responses
+// to our own requests (which echo our payer info) without storing every
+// request that we make.
+func NewPayerInfo(secret [32]byte, offerID lntypes.Hash,
+	payerKey *btcec.PublicKey) ([]byte, error) {
+
+	if payerKey == nil {
+		return nil, lnwire.ErrPayerKeyRequired
+	}
+
+	var nonce [payerInfoNonceLength]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("payer info nonce: %w", err)
+	}
+
+	mac := payerInfoMAC(secret, nonce[:], offerID, payerKey)
+
+	return append(nonce[:], mac...), nil
+}
+
+// VerifyPayerInfo checks that the payer info provided was generated by
+// NewPayerInfo with our secret for the offer ID and payer key provided.
+func VerifyPayerInfo(secret [32]byte, payerInfo []byte, offerID lntypes.Hash,
+	payerKey *btcec.PublicKey) error {
+
+	if payerKey == nil {
+		return lnwire.ErrPayerKeyRequired
+	}
+
+	if len(payerInfo) != payerInfoLength {
+		return fmt.Errorf("%w: length %v != %v", ErrInvalidPayerInfo,
+			len(payerInfo), payerInfoLength)
+	}
+
+	nonce := payerInfo[:payerInfoNonceLength]
+	mac := payerInfoMAC(secret, nonce, offerID, payerKey)
+
+	if !hmac.Equal(mac, payerInfo[payerInfoNonceLength:]) {
+		return ErrInvalidPayerInfo
+	}
+
+	return nil
+}
+
+// VerifyInvoicePayerInfo checks that an invoice is in response to an invoice
+// request that we created with payer info generated from our secret.
+func VerifyInvoicePayerInfo(secret [32]byte, invoice *lnwire.Invoice) error {
+	return VerifyPayerInfo(
+		secret, invoice.PayerInfo, invoice.OfferID, invoice.PayerKey,
+	)
+}
+
+// VerifyInvoiceForRequest checks that an invoice is bound to the invoice
+// request provided, echoing its offer ID, payer key and payer info. This
+// check should be used by the issuer before responding to a request with an
+// invoice.
+func VerifyInvoiceForRequest(request *lnwire.InvoiceRequest,
+	invoice *lnwire.Invoice) error {
+
+	if request.OfferID != invoice.OfferID {
+		return fmt.Errorf("%w: %v != %v", ErrOfferIDMismatch,
+			invoice.OfferID, request.OfferID)
+	}
+
+	if request.PayerKey == nil || invoice.PayerKey == nil ||
+		!request.PayerKey.IsEqual(invoice.PayerKey) {
+
+		return ErrPayerKeyMismatch
+	}
+
+	if !bytes.Equal(request.PayerInfo, invoice.PayerInfo) {
+		return ErrPayerInfoMismatch
+	}
+
+	return nil
+}
+
+// payerInfoMAC returns hmac-sha256(secret, nonce || offer id || payer key).
+func payerInfoMAC(secret [32]byte, nonce []byte, offerID lntypes.Hash,
+	payerKey *btcec.PublicKey) []byte {
+
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write(nonce)
+	mac.Write(offerID[:])
+	mac.Write(payerKey.SerializeCompressed())
+
+	return mac.Sum(nil)
+}
+
+// NewInvoiceRequest creates an invoice request for the offer provided with
+// payer info generated from our secret, so that invoices returned for the
+// request can be authenticated with VerifyInvoicePayerInfo.
+func NewInvoiceRequest(secret [32]byte, offer *lnwire.Offer,
+	amount lndwire.MilliSatoshi, quantity uint64, payerKey *btcec.PublicKey,
+	payerNote string) (*lnwire.InvoiceRequest, error) {
+
+	payerInfo, err := NewPayerInfo(secret, offer.MerkleRoot, payerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return lnwire.NewInvoiceRequest(
+		offer, amount, quantity, payerKey, payerNote, payerInfo,
+	)
+}
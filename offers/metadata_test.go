@@ -0,0 +1,179 @@
+package offers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPayerInfo tests generation and verification of payer info.
+func TestPayerInfo(t *testing.T) {
+	var (
+		secret      = [32]byte{1}
+		otherSecret = [32]byte{2}
+		offerID     = lntypes.Hash{3}
+		otherOffer  = lntypes.Hash{4}
+		pubkeys     = testutils.GetPubkeys(t, 2)
+	)
+
+	payerInfo, err := NewPayerInfo(secret, offerID, pubkeys[0])
+	require.NoError(t, err, "new payer info")
+	require.Len(t, payerInfo, payerInfoLength)
+
+	// Payer info should be unique per request.
+	otherInfo, err := NewPayerInfo(secret, offerID, pubkeys[0])
+	require.NoError(t, err, "new payer info")
+	require.NotEqual(t, payerInfo, otherInfo)
+
+	tests := []struct {
+		name    string
+		secret  [32]byte
+		invoice *lnwire.Invoice
+		err     error
+	}{
+		{
+			name:   "valid",
+			secret: secret,
+			invoice: &lnwire.Invoice{
+				OfferID:   offerID,
+				PayerKey:  pubkeys[0],
+				PayerInfo: payerInfo,
+			},
+		},
+		{
+			name:   "different secret",
+			secret: otherSecret,
+			invoice: &lnwire.Invoice{
+				OfferID:   offerID,
+				PayerKey:  pubkeys[0],
+				PayerInfo: payerInfo,
+			},
+			err: ErrInvalidPayerInfo,
+		},
+		{
+			name:   "different offer",
+			secret: secret,
+			invoice: &lnwire.Invoice{
+				OfferID:   otherOffer,
+				PayerKey:  pubkeys[0],
+				PayerInfo: payerInfo,
+			},
+			err: ErrInvalidPayerInfo,
+		},
+		{
+			name:   "different payer key",
+			secret: secret,
+			invoice: &lnwire.Invoice{
+				OfferID:   offerID,
+				PayerKey:  pubkeys[1],
+				PayerInfo: payerInfo,
+			},
+			err: ErrInvalidPayerInfo,
+		},
+		{
+			name:   "truncated payer info",
+			secret: secret,
+			invoice: &lnwire.Invoice{
+				OfferID:   offerID,
+				PayerKey:  pubkeys[0],
+				PayerInfo: payerInfo[1:],
+			},
+			err: ErrInvalidPayerInfo,
+		},
+		{
+			name:   "no payer key",
+			secret: secret,
+			invoice: &lnwire.Invoice{
+				OfferID:   offerID,
+				PayerInfo: payerInfo,
+			},
+			err: lnwire.ErrPayerKeyRequired,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := VerifyInvoicePayerInfo(
+				testCase.secret, testCase.invoice,
+			)
+			require.True(t, errors.Is(err, testCase.err), err)
+		})
+	}
+}
+
+// TestVerifyInvoiceForRequest tests binding of invoices to the invoice request
+// they are in response to.
+func TestVerifyInvoiceForRequest(t *testing.T) {
+	var (
+		secret  = [32]byte{1}
+		pubkeys = testutils.GetPubkeys(t, 2)
+		offer   = &lnwire.Offer{
+			MerkleRoot: lntypes.Hash{1},
+		}
+	)
+
+	request, err := NewInvoiceRequest(
+		secret, offer, 1000, 0, pubkeys[0], "note",
+	)
+	require.NoError(t, err, "new request")
+
+	require.NoError(t, VerifyPayerInfo(
+		secret, request.PayerInfo, offer.MerkleRoot, pubkeys[0],
+	))
+
+	tests := []struct {
+		name    string
+		invoice *lnwire.Invoice
+		err     error
+	}{
+		{
+			name: "bound to request",
+			invoice: &lnwire.Invoice{
+				OfferID:   offer.MerkleRoot,
+				PayerKey:  pubkeys[0],
+				PayerInfo: request.PayerInfo,
+			},
+		},
+		{
+			name: "wrong offer",
+			invoice: &lnwire.Invoice{
+				OfferID:   lntypes.Hash{2},
+				PayerKey:  pubkeys[0],
+				PayerInfo: request.PayerInfo,
+			},
+			err: ErrOfferIDMismatch,
+		},
+		{
+			name: "wrong payer key",
+			invoice: &lnwire.Invoice{
+				OfferID:   offer.MerkleRoot,
+				PayerKey:  pubkeys[1],
+				PayerInfo: request.PayerInfo,
+			},
+			err: ErrPayerKeyMismatch,
+		},
+		{
+			name: "payer info not echoed",
+			invoice: &lnwire.Invoice{
+				OfferID:  offer.MerkleRoot,
+				PayerKey: pubkeys[0],
+			},
+			err: ErrPayerInfoMismatch,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			err := VerifyInvoiceForRequest(request, testCase.invoice)
+			require.True(t, errors.Is(err, testCase.err), err)
+		})
+	}
+}
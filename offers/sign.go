@@ -0,0 +1,81 @@
+package offers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/onionmsg"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnrpc/signrpc"
+)
+
+// OfferSigner is an interface describing the lnd dependencies required to
+// sign offers.
+type OfferSigner interface {
+	// SignMessage signs a message with the key specified in the key
+	// locator.
+	SignMessage(ctx context.Context, msg []byte,
+		locator keychain.KeyLocator,
+		opts ...lndclient.SignMessageOption) ([]byte, error)
+}
+
+// SignOffer produces a bip340 signature over the merkle root of the offer
+// using our node's identity key, setting the offer's merkle root and
+// signature. The offer's node ID must be set to our node's public key.
+func SignOffer(ctx context.Context, signer OfferSigner,
+	offer *lnwire.Offer) error {
+
+	if offer.NodeID == nil {
+		return lnwire.ErrNodeIDRequired
+	}
+
+	// Clear any existing signature so that we calculate our merkle root
+	// over the offer's non-signature fields.
+	offer.Signature = nil
+
+	root, err := offer.CalculateMerkleRoot()
+	if err != nil {
+		return fmt.Errorf("merkle root: %w", err)
+	}
+
+	// Lnd will produce a signature over the tagged hash of our merkle
+	// root, which is the digest that the specification requires.
+	sig, err := signer.SignMessage(
+		ctx, root[:], onionmsg.NodeKeyLocator,
+		lndclient.SignSchnorr(nil),
+		signTag(lnwire.OfferSignatureTag()),
+	)
+	if err != nil {
+		return fmt.Errorf("sign offer: %w", err)
+	}
+
+	if len(sig) != 64 {
+		return fmt.Errorf("%w: %v bytes", lnwire.ErrInvalidSig,
+			len(sig))
+	}
+
+	var signature [64]byte
+	copy(signature[:], sig)
+
+	offer.MerkleRoot = root
+	offer.Signature = &signature
+
+	// Verify the signature that we've received so that we don't hand out
+	// offers with signatures that don't match the node ID provided.
+	if err := offer.VerifySignature(); err != nil {
+		offer.Signature = nil
+		return err
+	}
+
+	return nil
+}
+
+// signTag returns an option that sets the tag that lnd should use to produce
+// a tagged hash of the message for schnorr signatures.
+func signTag(tag []byte) lndclient.SignMessageOption {
+	return func(req *signrpc.SignMessageReq) {
+		req.Tag = tag
+	}
+}
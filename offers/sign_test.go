@@ -0,0 +1,105 @@
+package offers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/onionmsg"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSignOffer tests signing of offers with our node's key.
+func TestSignOffer(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 2)
+	nodeKey, otherKey := privkeys[0], privkeys[1]
+
+	newOffer := func() *lnwire.Offer {
+		return &lnwire.Offer{
+			Description: "offer",
+			NodeID:      nodeKey.PubKey(),
+		}
+	}
+
+	root, err := newOffer().CalculateMerkleRoot()
+	require.NoError(t, err, "merkle root")
+
+	// Produce signatures as lnd would, using a tagged hash of our merkle
+	// root.
+	digest := chainhash.TaggedHash(lnwire.OfferSignatureTag(), root[:])
+
+	sign := func(t *testing.T, privkey *btcec.PrivateKey) []byte {
+		sig, err := schnorr.Sign(privkey, digest[:])
+		require.NoError(t, err, "sign")
+
+		return sig.Serialize()
+	}
+
+	errMock := errors.New("mock")
+
+	tests := []struct {
+		name   string
+		offer  *lnwire.Offer
+		sig    []byte
+		sigErr error
+		err    error
+	}{
+		{
+			name:  "no node id",
+			offer: &lnwire.Offer{Description: "offer"},
+			err:   lnwire.ErrNodeIDRequired,
+		},
+		{
+			name:   "signer error",
+			offer:  newOffer(),
+			sigErr: errMock,
+			err:    errMock,
+		},
+		{
+			name:  "signature from other key",
+			offer: newOffer(),
+			sig:   sign(t, otherKey),
+			err:   lnwire.ErrInvalidSig,
+		},
+		{
+			name:  "valid signature",
+			offer: newOffer(),
+			sig:   sign(t, nodeKey),
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			lnd := testutils.NewMockLnd()
+			defer lnd.Mock.AssertExpectations(t)
+
+			if testCase.offer.NodeID != nil {
+				testutils.MockSignMessage(
+					lnd.Mock, root[:],
+					onionmsg.NodeKeyLocator, testCase.sig,
+					testCase.sigErr,
+				)
+			}
+
+			err := SignOffer(
+				context.Background(), lnd, testCase.offer,
+			)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err != nil {
+				require.Nil(t, testCase.offer.Signature)
+				return
+			}
+
+			require.Equal(t, root, testCase.offer.MerkleRoot)
+			require.NoError(t, testCase.offer.Validate())
+		})
+	}
+}
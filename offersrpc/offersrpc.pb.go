@@ -44,6 +44,10 @@ type SendOnionMessageRequest struct {
 	// to make a direct p2p connection to the node to deliver onion messages.
 	// This option will leak the IP of your LND node, so it is opt-in.
 	DirectConnect bool `protobuf:"varint,5,opt,name=direct_connect,json=directConnect,proto3" json:"direct_connect,omitempty"`
+	// If a direct connection had to be made to deliver the message, this
+	// option will disconnect from the node once the message has been sent.
+	// Connections made with this option set are never permanent.
+	DisconnectAfterSend bool `protobuf:"varint,6,opt,name=disconnect_after_send,json=disconnectAfterSend,proto3" json:"disconnect_after_send,omitempty"`
 }
 
 func (x *SendOnionMessageRequest) Reset() {
@@ -113,6 +117,13 @@ func (x *SendOnionMessageRequest) GetDirectConnect() bool {
 	return false
 }
 
+func (x *SendOnionMessageRequest) GetDisconnectAfterSend() bool {
+	if x != nil {
+		return x.DisconnectAfterSend
+	}
+	return false
+}
+
 type BlindedPath struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -283,6 +294,9 @@ type DecodeOfferRequest struct {
 
 	// The encoded offer string to be decoded.
 	Offer string `protobuf:"bytes,1,opt,name=offer,proto3" json:"offer,omitempty"`
+	// Decode the offer using the encoding of other bolt 12 implementations
+	// rather than our own.
+	CompatMode bool `protobuf:"varint,2,opt,name=compat_mode,json=compatMode,proto3" json:"compat_mode,omitempty"`
 }
 
 func (x *DecodeOfferRequest) Reset() {
@@ -324,6 +338,13 @@ func (x *DecodeOfferRequest) GetOffer() string {
 	return ""
 }
 
+func (x *DecodeOfferRequest) GetCompatMode() bool {
+	if x != nil {
+		return x.CompatMode
+	}
+	return false
+}
+
 type DecodeOfferResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -372,6 +393,171 @@ func (x *DecodeOfferResponse) GetOffer() *Offer {
 	return nil
 }
 
+type DecodeOffersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The set of encoded offer strings to be decoded.
+	Offers []string `protobuf:"bytes,1,rep,name=offers,proto3" json:"offers,omitempty"`
+	// Decode the offers using the encoding of other bolt 12 implementations
+	// rather than our own.
+	CompatMode bool `protobuf:"varint,2,opt,name=compat_mode,json=compatMode,proto3" json:"compat_mode,omitempty"`
+}
+
+func (x *DecodeOffersRequest) Reset() {
+	*x = DecodeOffersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_offersrpc_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DecodeOffersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeOffersRequest) ProtoMessage() {}
+
+func (x *DecodeOffersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_offersrpc_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeOffersRequest.ProtoReflect.Descriptor instead.
+func (*DecodeOffersRequest) Descriptor() ([]byte, []int) {
+	return file_offersrpc_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DecodeOffersRequest) GetOffers() []string {
+	if x != nil {
+		return x.Offers
+	}
+	return nil
+}
+
+func (x *DecodeOffersRequest) GetCompatMode() bool {
+	if x != nil {
+		return x.CompatMode
+	}
+	return false
+}
+
+type DecodeOffersResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The result of decoding each offer, in the same order as the offer
+	// strings provided in the request.
+	Results []*DecodeOfferResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *DecodeOffersResponse) Reset() {
+	*x = DecodeOffersResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_offersrpc_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DecodeOffersResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeOffersResponse) ProtoMessage() {}
+
+func (x *DecodeOffersResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_offersrpc_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeOffersResponse.ProtoReflect.Descriptor instead.
+func (*DecodeOffersResponse) Descriptor() ([]byte, []int) {
+	return file_offersrpc_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DecodeOffersResponse) GetResults() []*DecodeOfferResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type DecodeOfferResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The decoded offer, set if the offer string was successfully decoded.
+	Offer *Offer `protobuf:"bytes,1,opt,name=offer,proto3" json:"offer,omitempty"`
+	// A description of the error that occurred decoding the offer string,
+	// set if the offer could not be decoded.
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *DecodeOfferResult) Reset() {
+	*x = DecodeOfferResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_offersrpc_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DecodeOfferResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeOfferResult) ProtoMessage() {}
+
+func (x *DecodeOfferResult) ProtoReflect() protoreflect.Message {
+	mi := &file_offersrpc_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeOfferResult.ProtoReflect.Descriptor instead.
+func (*DecodeOfferResult) Descriptor() ([]byte, []int) {
+	return file_offersrpc_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DecodeOfferResult) GetOffer() *Offer {
+	if x != nil {
+		return x.Offer
+	}
+	return nil
+}
+
+func (x *DecodeOfferResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 type Offer struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -398,12 +584,19 @@ type Offer struct {
 	// The 64 byte bip340 hex-encoded signature for the offer, generated using
 	// node_id's corresponding private key.
 	Signature string `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Indicates whether the offer's signature is a valid signature of the
+	// offer by node_id. This field is false if the offer is not signed.
+	SignatureValid bool `protobuf:"varint,10,opt,name=signature_valid,json=signatureValid,proto3" json:"signature_valid,omitempty"`
+	// The ISO-4217 currency code that the offer is denominated in. If set,
+	// min_amount_msat is expressed in the minor unit of the currency rather
+	// than millisatoshis.
+	Currency string `protobuf:"bytes,11,opt,name=currency,proto3" json:"currency,omitempty"`
 }
 
 func (x *Offer) Reset() {
 	*x = Offer{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_offersrpc_proto_msgTypes[6]
+		mi := &file_offersrpc_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -416,7 +609,7 @@ func (x *Offer) String() string {
 func (*Offer) ProtoMessage() {}
 
 func (x *Offer) ProtoReflect() protoreflect.Message {
-	mi := &file_offersrpc_proto_msgTypes[6]
+	mi := &file_offersrpc_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -429,7 +622,7 @@ func (x *Offer) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Offer.ProtoReflect.Descriptor instead.
 func (*Offer) Descriptor() ([]byte, []int) {
-	return file_offersrpc_proto_rawDescGZIP(), []int{6}
+	return file_offersrpc_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Offer) GetMinAmountMsat() uint64 {
@@ -495,6 +688,20 @@ func (x *Offer) GetSignature() string {
 	return ""
 }
 
+func (x *Offer) GetSignatureValid() bool {
+	if x != nil {
+		return x.SignatureValid
+	}
+	return false
+}
+
+func (x *Offer) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
 type SubscribeOnionPayloadRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -511,7 +718,7 @@ type SubscribeOnionPayloadRequest struct {
 func (x *SubscribeOnionPayloadRequest) Reset() {
 	*x = SubscribeOnionPayloadRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_offersrpc_proto_msgTypes[7]
+		mi := &file_offersrpc_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -524,7 +731,7 @@ func (x *SubscribeOnionPayloadRequest) String() string {
 func (*SubscribeOnionPayloadRequest) ProtoMessage() {}
 
 func (x *SubscribeOnionPayloadRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_offersrpc_proto_msgTypes[7]
+	mi := &file_offersrpc_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -537,7 +744,7 @@ func (x *SubscribeOnionPayloadRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SubscribeOnionPayloadRequest.ProtoReflect.Descriptor instead.
 func (*SubscribeOnionPayloadRequest) Descriptor() ([]byte, []int) {
-	return file_offersrpc_proto_rawDescGZIP(), []int{7}
+	return file_offersrpc_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *SubscribeOnionPayloadRequest) GetTlvType() uint64 {
@@ -563,7 +770,7 @@ type SubscribeOnionPayloadResponse struct {
 func (x *SubscribeOnionPayloadResponse) Reset() {
 	*x = SubscribeOnionPayloadResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_offersrpc_proto_msgTypes[8]
+		mi := &file_offersrpc_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -576,7 +783,7 @@ func (x *SubscribeOnionPayloadResponse) String() string {
 func (*SubscribeOnionPayloadResponse) ProtoMessage() {}
 
 func (x *SubscribeOnionPayloadResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_offersrpc_proto_msgTypes[8]
+	mi := &file_offersrpc_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -589,7 +796,7 @@ func (x *SubscribeOnionPayloadResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SubscribeOnionPayloadResponse.ProtoReflect.Descriptor instead.
 func (*SubscribeOnionPayloadResponse) Descriptor() ([]byte, []int) {
-	return file_offersrpc_proto_rawDescGZIP(), []int{8}
+	return file_offersrpc_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *SubscribeOnionPayloadResponse) GetValue() []byte {
@@ -613,12 +820,20 @@ type GenerateBlindedRouteRequest struct {
 
 	// The set of protocol features that the nodes along the route should have.
 	Features []uint64 `protobuf:"varint,1,rep,packed,name=features,proto3" json:"features,omitempty"`
+	// The number of hops that the route should contain before it reaches
+	// our node. Hops are selected from our channel peers and the public
+	// graph. If not set, a single hop route is produced.
+	NumHops uint32 `protobuf:"varint,2,opt,name=num_hops,json=numHops,proto3" json:"num_hops,omitempty"`
+	// The number of dummy hops to add to the end of the route. Dummy hops
+	// repeat our own node so that the length of the route does not reveal
+	// our distance from the introduction node.
+	NumDummyHops uint32 `protobuf:"varint,3,opt,name=num_dummy_hops,json=numDummyHops,proto3" json:"num_dummy_hops,omitempty"`
 }
 
 func (x *GenerateBlindedRouteRequest) Reset() {
 	*x = GenerateBlindedRouteRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_offersrpc_proto_msgTypes[9]
+		mi := &file_offersrpc_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -631,7 +846,7 @@ func (x *GenerateBlindedRouteRequest) String() string {
 func (*GenerateBlindedRouteRequest) ProtoMessage() {}
 
 func (x *GenerateBlindedRouteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_offersrpc_proto_msgTypes[9]
+	mi := &file_offersrpc_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -644,7 +859,7 @@ func (x *GenerateBlindedRouteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GenerateBlindedRouteRequest.ProtoReflect.Descriptor instead.
 func (*GenerateBlindedRouteRequest) Descriptor() ([]byte, []int) {
-	return file_offersrpc_proto_rawDescGZIP(), []int{9}
+	return file_offersrpc_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *GenerateBlindedRouteRequest) GetFeatures() []uint64 {
@@ -654,6 +869,20 @@ func (x *GenerateBlindedRouteRequest) GetFeatures() []uint64 {
 	return nil
 }
 
+func (x *GenerateBlindedRouteRequest) GetNumHops() uint32 {
+	if x != nil {
+		return x.NumHops
+	}
+	return 0
+}
+
+func (x *GenerateBlindedRouteRequest) GetNumDummyHops() uint32 {
+	if x != nil {
+		return x.NumDummyHops
+	}
+	return 0
+}
+
 type GenerateBlindedRouteResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -666,7 +895,7 @@ type GenerateBlindedRouteResponse struct {
 func (x *GenerateBlindedRouteResponse) Reset() {
 	*x = GenerateBlindedRouteResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_offersrpc_proto_msgTypes[10]
+		mi := &file_offersrpc_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -679,7 +908,7 @@ func (x *GenerateBlindedRouteResponse) String() string {
 func (*GenerateBlindedRouteResponse) ProtoMessage() {}
 
 func (x *GenerateBlindedRouteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_offersrpc_proto_msgTypes[10]
+	mi := &file_offersrpc_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -692,7 +921,7 @@ func (x *GenerateBlindedRouteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GenerateBlindedRouteResponse.ProtoReflect.Descriptor instead.
 func (*GenerateBlindedRouteResponse) Descriptor() ([]byte, []int) {
-	return file_offersrpc_proto_rawDescGZIP(), []int{10}
+	return file_offersrpc_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *GenerateBlindedRouteResponse) GetRoute() *BlindedPath {
@@ -702,11 +931,138 @@ func (x *GenerateBlindedRouteResponse) GetRoute() *BlindedPath {
 	return nil
 }
 
+type SendOnionMessageWithReplyRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The onion message to send. A reply path to our node is generated for
+	// the message, so the message must not have a reply path set.
+	Message *SendOnionMessageRequest `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	// The number of hops that the generated reply path should contain
+	// before it reaches our node.
+	NumHops uint32 `protobuf:"varint,2,opt,name=num_hops,json=numHops,proto3" json:"num_hops,omitempty"`
+	// The number of dummy hops to add to the end of the generated reply
+	// path.
+	NumDummyHops uint32 `protobuf:"varint,3,opt,name=num_dummy_hops,json=numDummyHops,proto3" json:"num_dummy_hops,omitempty"`
+}
+
+func (x *SendOnionMessageWithReplyRequest) Reset() {
+	*x = SendOnionMessageWithReplyRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_offersrpc_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SendOnionMessageWithReplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendOnionMessageWithReplyRequest) ProtoMessage() {}
+
+func (x *SendOnionMessageWithReplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_offersrpc_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendOnionMessageWithReplyRequest.ProtoReflect.Descriptor instead.
+func (*SendOnionMessageWithReplyRequest) Descriptor() ([]byte, []int) {
+	return file_offersrpc_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SendOnionMessageWithReplyRequest) GetMessage() *SendOnionMessageRequest {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *SendOnionMessageWithReplyRequest) GetNumHops() uint32 {
+	if x != nil {
+		return x.NumHops
+	}
+	return 0
+}
+
+func (x *SendOnionMessageWithReplyRequest) GetNumDummyHops() uint32 {
+	if x != nil {
+		return x.NumDummyHops
+	}
+	return 0
+}
+
+type SendOnionMessageWithReplyResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// A map of TLV type to encoded value for the final hop payloads that
+	// were included in the reply.
+	FinalPayloads map[uint64][]byte `protobuf:"bytes,1,rep,name=final_payloads,json=finalPayloads,proto3" json:"final_payloads,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// An optional reply path included by the sender of the reply.
+	ReplyPath *BlindedPath `protobuf:"bytes,2,opt,name=reply_path,json=replyPath,proto3" json:"reply_path,omitempty"`
+}
+
+func (x *SendOnionMessageWithReplyResponse) Reset() {
+	*x = SendOnionMessageWithReplyResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_offersrpc_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SendOnionMessageWithReplyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendOnionMessageWithReplyResponse) ProtoMessage() {}
+
+func (x *SendOnionMessageWithReplyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_offersrpc_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendOnionMessageWithReplyResponse.ProtoReflect.Descriptor instead.
+func (*SendOnionMessageWithReplyResponse) Descriptor() ([]byte, []int) {
+	return file_offersrpc_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SendOnionMessageWithReplyResponse) GetFinalPayloads() map[uint64][]byte {
+	if x != nil {
+		return x.FinalPayloads
+	}
+	return nil
+}
+
+func (x *SendOnionMessageWithReplyResponse) GetReplyPath() *BlindedPath {
+	if x != nil {
+		return x.ReplyPath
+	}
+	return nil
+}
+
 var File_offersrpc_proto protoreflect.FileDescriptor
 
 var file_offersrpc_proto_rawDesc = []byte{
 	0x0a, 0x0f, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x12, 0x09, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x22, 0xf8, 0x02, 0x0a,
+	0x6f, 0x12, 0x09, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x22, 0xac, 0x03, 0x0a,
 	0x17, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
 	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x75, 0x62, 0x6b,
 	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x70, 0x75, 0x62, 0x6b, 0x65, 0x79,
@@ -726,100 +1082,168 @@ var file_offersrpc_proto_rawDesc = []byte{
 	0x61, 0x74, 0x68, 0x52, 0x09, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x50, 0x61, 0x74, 0x68, 0x12, 0x25,
 	0x0a, 0x0e, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x5f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
 	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x43, 0x6f,
-	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x1a, 0x40, 0x0a, 0x12, 0x46, 0x69, 0x6e, 0x61, 0x6c, 0x50, 0x61,
-	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
-	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x8c, 0x01, 0x0a, 0x0b, 0x42, 0x6c, 0x69, 0x6e,
-	0x64, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x12, 0x2b, 0x0a, 0x11, 0x69, 0x6e, 0x74, 0x72, 0x6f,
-	0x64, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0c, 0x52, 0x10, 0x69, 0x6e, 0x74, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e,
-	0x4e, 0x6f, 0x64, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x62, 0x6c, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67,
-	0x5f, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x62, 0x6c,
-	0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x29, 0x0a, 0x04, 0x68,
-	0x6f, 0x70, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6f, 0x66, 0x66, 0x65,
-	0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x48, 0x6f, 0x70,
-	0x52, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x22, 0x5b, 0x0a, 0x0a, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65,
-	0x64, 0x48, 0x6f, 0x70, 0x12, 0x26, 0x0a, 0x0f, 0x62, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x5f,
-	0x6e, 0x6f, 0x64, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x62,
-	0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x64, 0x12, 0x25, 0x0a, 0x0e,
-	0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x44,
-	0x61, 0x74, 0x61, 0x22, 0x1a, 0x0a, 0x18, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e,
-	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
-	0x2a, 0x0a, 0x12, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x22, 0x3d, 0x0a, 0x13, 0x44,
-	0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x12, 0x26, 0x0a, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x10, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x4f, 0x66,
-	0x66, 0x65, 0x72, 0x52, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x22, 0xb2, 0x02, 0x0a, 0x05, 0x4f,
-	0x66, 0x66, 0x65, 0x72, 0x12, 0x26, 0x0a, 0x0f, 0x6d, 0x69, 0x6e, 0x5f, 0x61, 0x6d, 0x6f, 0x75,
-	0x6e, 0x74, 0x5f, 0x6d, 0x73, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d, 0x6d,
-	0x69, 0x6e, 0x41, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x4d, 0x73, 0x61, 0x74, 0x12, 0x20, 0x0a, 0x0b,
-	0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a,
-	0x0a, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x65, 0x78,
-	0x70, 0x69, 0x72, 0x79, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64,
-	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x11, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x55,
-	0x6e, 0x69, 0x78, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x73,
-	0x73, 0x75, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x69, 0x73, 0x73, 0x75,
-	0x65, 0x72, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x69, 0x6e, 0x5f, 0x71, 0x75, 0x61, 0x6e, 0x74, 0x69,
-	0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x69, 0x6e, 0x51, 0x75, 0x61,
-	0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x61, 0x78, 0x5f, 0x71, 0x75, 0x61,
-	0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x61, 0x78,
-	0x51, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x17, 0x0a, 0x07, 0x6e, 0x6f, 0x64, 0x65,
-	0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49,
-	0x64, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x09,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22,
-	0x39, 0x0a, 0x1c, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f,
-	0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
-	0x19, 0x0a, 0x08, 0x74, 0x6c, 0x76, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x07, 0x74, 0x6c, 0x76, 0x54, 0x79, 0x70, 0x65, 0x22, 0x6c, 0x0a, 0x1d, 0x53, 0x75,
-	0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c,
-	0x6f, 0x61, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x12, 0x35, 0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x32, 0x0a, 0x15, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x6e, 0x64, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x13, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x41, 0x66, 0x74, 0x65, 0x72, 0x53, 0x65, 0x6e, 0x64, 0x1a, 0x40, 0x0a, 0x12, 0x46, 0x69, 0x6e,
+	0x61, 0x6c, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x8c, 0x01, 0x0a, 0x0b,
+	0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x12, 0x2b, 0x0a, 0x11, 0x69,
+	0x6e, 0x74, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6e, 0x6f, 0x64, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x10, 0x69, 0x6e, 0x74, 0x72, 0x6f, 0x64, 0x75, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x62, 0x6c, 0x69, 0x6e,
+	0x64, 0x69, 0x6e, 0x67, 0x5f, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x0d, 0x62, 0x6c, 0x69, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x12,
+	0x29, 0x0a, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e,
+	0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65,
+	0x64, 0x48, 0x6f, 0x70, 0x52, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x22, 0x5b, 0x0a, 0x0a, 0x42, 0x6c,
+	0x69, 0x6e, 0x64, 0x65, 0x64, 0x48, 0x6f, 0x70, 0x12, 0x26, 0x0a, 0x0f, 0x62, 0x6c, 0x69, 0x6e,
+	0x64, 0x65, 0x64, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x0d, 0x62, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x64,
+	0x12, 0x25, 0x0a, 0x0e, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x64, 0x61,
+	0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70,
+	0x74, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x22, 0x1a, 0x0a, 0x18, 0x53, 0x65, 0x6e, 0x64, 0x4f,
+	0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x4b, 0x0a, 0x12, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66,
+	0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6f, 0x66, 0x66,
+	0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x12,
+	0x1f, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x4d, 0x6f, 0x64, 0x65,
+	0x22, 0x3d, 0x0a, 0x13, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72,
+	0x70, 0x63, 0x2e, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x22,
+	0x4e, 0x0a, 0x13, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x12, 0x1f,
+	0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x22,
+	0x4e, 0x0a, 0x14, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72,
+	0x73, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x22,
+	0x51, 0x0a, 0x11, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x12, 0x26, 0x0a, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e,
+	0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x05, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x22, 0xf7, 0x02, 0x0a, 0x05, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x12, 0x26, 0x0a, 0x0f,
+	0x6d, 0x69, 0x6e, 0x5f, 0x61, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x5f, 0x6d, 0x73, 0x61, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d, 0x6d, 0x69, 0x6e, 0x41, 0x6d, 0x6f, 0x75, 0x6e, 0x74,
+	0x4d, 0x73, 0x61, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x5f, 0x75, 0x6e, 0x69,
+	0x78, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x11, 0x65, 0x78, 0x70, 0x69, 0x72, 0x79, 0x55, 0x6e, 0x69, 0x78, 0x53, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x73, 0x73, 0x75, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x69, 0x73, 0x73, 0x75, 0x65, 0x72, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x69,
+	0x6e, 0x5f, 0x71, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x0b, 0x6d, 0x69, 0x6e, 0x51, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x21, 0x0a,
+	0x0c, 0x6d, 0x61, 0x78, 0x5f, 0x71, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x51, 0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79,
+	0x12, 0x17, 0x0a, 0x07, 0x6e, 0x6f, 0x64, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x6e, 0x6f, 0x64, 0x65, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x5f, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0e, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x56, 0x61, 0x6c, 0x69, 0x64,
+	0x12, 0x1a, 0x0a, 0x08, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x0b, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x22, 0x39, 0x0a, 0x1c,
+	0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08,
+	0x74, 0x6c, 0x76, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07,
+	0x74, 0x6c, 0x76, 0x54, 0x79, 0x70, 0x65, 0x22, 0x6c, 0x0a, 0x1d, 0x53, 0x75, 0x62, 0x73, 0x63,
+	0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x35,
+	0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x42,
+	0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x52, 0x09, 0x72, 0x65, 0x70, 0x6c,
+	0x79, 0x50, 0x61, 0x74, 0x68, 0x22, 0x7a, 0x0a, 0x1b, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74,
+	0x65, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73,
+	0x12, 0x19, 0x0a, 0x08, 0x6e, 0x75, 0x6d, 0x5f, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x07, 0x6e, 0x75, 0x6d, 0x48, 0x6f, 0x70, 0x73, 0x12, 0x24, 0x0a, 0x0e, 0x6e,
+	0x75, 0x6d, 0x5f, 0x64, 0x75, 0x6d, 0x6d, 0x79, 0x5f, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x44, 0x75, 0x6d, 0x6d, 0x79, 0x48, 0x6f, 0x70,
+	0x73, 0x22, 0x4c, 0x0a, 0x1c, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x69,
+	0x6e, 0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x2c, 0x0a, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x42, 0x6c, 0x69,
+	0x6e, 0x64, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x22,
+	0xa1, 0x01, 0x0a, 0x20, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x57, 0x69, 0x74, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x3c, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70,
+	0x63, 0x2e, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x6e, 0x75, 0x6d, 0x5f, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x6e, 0x75, 0x6d, 0x48, 0x6f, 0x70, 0x73, 0x12, 0x24, 0x0a,
+	0x0e, 0x6e, 0x75, 0x6d, 0x5f, 0x64, 0x75, 0x6d, 0x6d, 0x79, 0x5f, 0x68, 0x6f, 0x70, 0x73, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x44, 0x75, 0x6d, 0x6d, 0x79, 0x48,
+	0x6f, 0x70, 0x73, 0x22, 0x84, 0x02, 0x0a, 0x21, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f,
+	0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x57, 0x69, 0x74, 0x68, 0x52, 0x65, 0x70, 0x6c,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x66, 0x0a, 0x0e, 0x66, 0x69, 0x6e,
+	0x61, 0x6c, 0x5f, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x3f, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x65,
+	0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x57, 0x69,
+	0x74, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x2e,
+	0x46, 0x69, 0x6e, 0x61, 0x6c, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x0d, 0x66, 0x69, 0x6e, 0x61, 0x6c, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x73, 0x12, 0x35, 0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18,
 	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70,
 	0x63, 0x2e, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x52, 0x09, 0x72,
-	0x65, 0x70, 0x6c, 0x79, 0x50, 0x61, 0x74, 0x68, 0x22, 0x39, 0x0a, 0x1b, 0x47, 0x65, 0x6e, 0x65,
+	0x65, 0x70, 0x6c, 0x79, 0x50, 0x61, 0x74, 0x68, 0x1a, 0x40, 0x0a, 0x12, 0x46, 0x69, 0x6e, 0x61,
+	0x6c, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x32, 0xd3, 0x04, 0x0a, 0x06, 0x4f,
+	0x66, 0x66, 0x65, 0x72, 0x73, 0x12, 0x5b, 0x0a, 0x10, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69,
+	0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x22, 0x2e, 0x6f, 0x66, 0x66, 0x65,
+	0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e,
+	0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e,
+	0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x4c, 0x0a, 0x0b, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65,
+	0x72, 0x12, 0x1d, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x65,
+	0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1e, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x65, 0x63,
+	0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x4f, 0x0a, 0x0c, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x73,
+	0x12, 0x1e, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x65, 0x63,
+	0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1f, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x65, 0x63,
+	0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x6c, 0x0a, 0x15, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e,
+	0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x27, 0x2e, 0x6f, 0x66, 0x66,
+	0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65,
+	0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e,
+	0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12,
+	0x67, 0x0a, 0x14, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x69, 0x6e, 0x64,
+	0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x26, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73,
+	0x72, 0x70, 0x63, 0x2e, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x69, 0x6e,
+	0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x27, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x47, 0x65, 0x6e, 0x65,
 	0x72, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75,
-	0x72, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75,
-	0x72, 0x65, 0x73, 0x22, 0x4c, 0x0a, 0x1c, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x42,
-	0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x05, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x42,
-	0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x50, 0x61, 0x74, 0x68, 0x52, 0x05, 0x72, 0x6f, 0x75, 0x74,
-	0x65, 0x32, 0x8a, 0x03, 0x0a, 0x06, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x12, 0x5b, 0x0a, 0x10,
-	0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x12, 0x22, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x65, 0x6e,
-	0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63,
-	0x2e, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4c, 0x0a, 0x0b, 0x44, 0x65, 0x63,
-	0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x12, 0x1d, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72,
-	0x73, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73,
-	0x72, 0x70, 0x63, 0x2e, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x4f, 0x66, 0x66, 0x65, 0x72, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x6c, 0x0a, 0x15, 0x53, 0x75, 0x62, 0x73, 0x63,
-	0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
-	0x12, 0x27, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x75, 0x62,
-	0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f,
-	0x61, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x6f, 0x66, 0x66, 0x65,
-	0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x4f,
-	0x6e, 0x69, 0x6f, 0x6e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x67, 0x0a, 0x14, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74,
-	0x65, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x26, 0x2e,
-	0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61,
-	0x74, 0x65, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65, 0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x27, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70,
-	0x63, 0x2e, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x69, 0x6e, 0x64, 0x65,
-	0x64, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x26,
-	0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x69, 0x6a,
-	0x73, 0x77, 0x69, 0x6a, 0x73, 0x2f, 0x62, 0x6f, 0x6c, 0x74, 0x6e, 0x64, 0x2f, 0x6f, 0x66, 0x66,
-	0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x76, 0x0a, 0x19, 0x53, 0x65, 0x6e, 0x64,
+	0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x57, 0x69, 0x74, 0x68,
+	0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x2b, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70,
+	0x63, 0x2e, 0x53, 0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x57, 0x69, 0x74, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x2c, 0x2e, 0x6f, 0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x2e, 0x53,
+	0x65, 0x6e, 0x64, 0x4f, 0x6e, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x57,
+	0x69, 0x74, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67,
+	0x69, 0x6a, 0x73, 0x77, 0x69, 0x6a, 0x73, 0x2f, 0x62, 0x6f, 0x6c, 0x74, 0x6e, 0x64, 0x2f, 0x6f,
+	0x66, 0x66, 0x65, 0x72, 0x73, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -834,42 +1258,57 @@ func file_offersrpc_proto_rawDescGZIP() []byte {
 	return file_offersrpc_proto_rawDescData
 }
 
-var file_offersrpc_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_offersrpc_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_offersrpc_proto_goTypes = []interface{}{
-	(*SendOnionMessageRequest)(nil),       // 0: offersrpc.SendOnionMessageRequest
-	(*BlindedPath)(nil),                   // 1: offersrpc.BlindedPath
-	(*BlindedHop)(nil),                    // 2: offersrpc.BlindedHop
-	(*SendOnionMessageResponse)(nil),      // 3: offersrpc.SendOnionMessageResponse
-	(*DecodeOfferRequest)(nil),            // 4: offersrpc.DecodeOfferRequest
-	(*DecodeOfferResponse)(nil),           // 5: offersrpc.DecodeOfferResponse
-	(*Offer)(nil),                         // 6: offersrpc.Offer
-	(*SubscribeOnionPayloadRequest)(nil),  // 7: offersrpc.SubscribeOnionPayloadRequest
-	(*SubscribeOnionPayloadResponse)(nil), // 8: offersrpc.SubscribeOnionPayloadResponse
-	(*GenerateBlindedRouteRequest)(nil),   // 9: offersrpc.GenerateBlindedRouteRequest
-	(*GenerateBlindedRouteResponse)(nil),  // 10: offersrpc.GenerateBlindedRouteResponse
-	nil,                                   // 11: offersrpc.SendOnionMessageRequest.FinalPayloadsEntry
+	(*SendOnionMessageRequest)(nil),           // 0: offersrpc.SendOnionMessageRequest
+	(*BlindedPath)(nil),                       // 1: offersrpc.BlindedPath
+	(*BlindedHop)(nil),                        // 2: offersrpc.BlindedHop
+	(*SendOnionMessageResponse)(nil),          // 3: offersrpc.SendOnionMessageResponse
+	(*DecodeOfferRequest)(nil),                // 4: offersrpc.DecodeOfferRequest
+	(*DecodeOfferResponse)(nil),               // 5: offersrpc.DecodeOfferResponse
+	(*DecodeOffersRequest)(nil),               // 6: offersrpc.DecodeOffersRequest
+	(*DecodeOffersResponse)(nil),              // 7: offersrpc.DecodeOffersResponse
+	(*DecodeOfferResult)(nil),                 // 8: offersrpc.DecodeOfferResult
+	(*Offer)(nil),                             // 9: offersrpc.Offer
+	(*SubscribeOnionPayloadRequest)(nil),      // 10: offersrpc.SubscribeOnionPayloadRequest
+	(*SubscribeOnionPayloadResponse)(nil),     // 11: offersrpc.SubscribeOnionPayloadResponse
+	(*GenerateBlindedRouteRequest)(nil),       // 12: offersrpc.GenerateBlindedRouteRequest
+	(*GenerateBlindedRouteResponse)(nil),      // 13: offersrpc.GenerateBlindedRouteResponse
+	(*SendOnionMessageWithReplyRequest)(nil),  // 14: offersrpc.SendOnionMessageWithReplyRequest
+	(*SendOnionMessageWithReplyResponse)(nil), // 15: offersrpc.SendOnionMessageWithReplyResponse
+	nil, // 16: offersrpc.SendOnionMessageRequest.FinalPayloadsEntry
+	nil, // 17: offersrpc.SendOnionMessageWithReplyResponse.FinalPayloadsEntry
 }
 var file_offersrpc_proto_depIdxs = []int32{
 	1,  // 0: offersrpc.SendOnionMessageRequest.blinded_destination:type_name -> offersrpc.BlindedPath
-	11, // 1: offersrpc.SendOnionMessageRequest.final_payloads:type_name -> offersrpc.SendOnionMessageRequest.FinalPayloadsEntry
+	16, // 1: offersrpc.SendOnionMessageRequest.final_payloads:type_name -> offersrpc.SendOnionMessageRequest.FinalPayloadsEntry
 	1,  // 2: offersrpc.SendOnionMessageRequest.reply_path:type_name -> offersrpc.BlindedPath
 	2,  // 3: offersrpc.BlindedPath.hops:type_name -> offersrpc.BlindedHop
-	6,  // 4: offersrpc.DecodeOfferResponse.offer:type_name -> offersrpc.Offer
-	1,  // 5: offersrpc.SubscribeOnionPayloadResponse.reply_path:type_name -> offersrpc.BlindedPath
-	1,  // 6: offersrpc.GenerateBlindedRouteResponse.route:type_name -> offersrpc.BlindedPath
-	0,  // 7: offersrpc.Offers.SendOnionMessage:input_type -> offersrpc.SendOnionMessageRequest
-	4,  // 8: offersrpc.Offers.DecodeOffer:input_type -> offersrpc.DecodeOfferRequest
-	7,  // 9: offersrpc.Offers.SubscribeOnionPayload:input_type -> offersrpc.SubscribeOnionPayloadRequest
-	9,  // 10: offersrpc.Offers.GenerateBlindedRoute:input_type -> offersrpc.GenerateBlindedRouteRequest
-	3,  // 11: offersrpc.Offers.SendOnionMessage:output_type -> offersrpc.SendOnionMessageResponse
-	5,  // 12: offersrpc.Offers.DecodeOffer:output_type -> offersrpc.DecodeOfferResponse
-	8,  // 13: offersrpc.Offers.SubscribeOnionPayload:output_type -> offersrpc.SubscribeOnionPayloadResponse
-	10, // 14: offersrpc.Offers.GenerateBlindedRoute:output_type -> offersrpc.GenerateBlindedRouteResponse
-	11, // [11:15] is the sub-list for method output_type
-	7,  // [7:11] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	9,  // 4: offersrpc.DecodeOfferResponse.offer:type_name -> offersrpc.Offer
+	8,  // 5: offersrpc.DecodeOffersResponse.results:type_name -> offersrpc.DecodeOfferResult
+	9,  // 6: offersrpc.DecodeOfferResult.offer:type_name -> offersrpc.Offer
+	1,  // 7: offersrpc.SubscribeOnionPayloadResponse.reply_path:type_name -> offersrpc.BlindedPath
+	1,  // 8: offersrpc.GenerateBlindedRouteResponse.route:type_name -> offersrpc.BlindedPath
+	0,  // 9: offersrpc.SendOnionMessageWithReplyRequest.message:type_name -> offersrpc.SendOnionMessageRequest
+	17, // 10: offersrpc.SendOnionMessageWithReplyResponse.final_payloads:type_name -> offersrpc.SendOnionMessageWithReplyResponse.FinalPayloadsEntry
+	1,  // 11: offersrpc.SendOnionMessageWithReplyResponse.reply_path:type_name -> offersrpc.BlindedPath
+	0,  // 12: offersrpc.Offers.SendOnionMessage:input_type -> offersrpc.SendOnionMessageRequest
+	4,  // 13: offersrpc.Offers.DecodeOffer:input_type -> offersrpc.DecodeOfferRequest
+	6,  // 14: offersrpc.Offers.DecodeOffers:input_type -> offersrpc.DecodeOffersRequest
+	10, // 15: offersrpc.Offers.SubscribeOnionPayload:input_type -> offersrpc.SubscribeOnionPayloadRequest
+	12, // 16: offersrpc.Offers.GenerateBlindedRoute:input_type -> offersrpc.GenerateBlindedRouteRequest
+	14, // 17: offersrpc.Offers.SendOnionMessageWithReply:input_type -> offersrpc.SendOnionMessageWithReplyRequest
+	3,  // 18: offersrpc.Offers.SendOnionMessage:output_type -> offersrpc.SendOnionMessageResponse
+	5,  // 19: offersrpc.Offers.DecodeOffer:output_type -> offersrpc.DecodeOfferResponse
+	7,  // 20: offersrpc.Offers.DecodeOffers:output_type -> offersrpc.DecodeOffersResponse
+	11, // 21: offersrpc.Offers.SubscribeOnionPayload:output_type -> offersrpc.SubscribeOnionPayloadResponse
+	13, // 22: offersrpc.Offers.GenerateBlindedRoute:output_type -> offersrpc.GenerateBlindedRouteResponse
+	15, // 23: offersrpc.Offers.SendOnionMessageWithReply:output_type -> offersrpc.SendOnionMessageWithReplyResponse
+	18, // [18:24] is the sub-list for method output_type
+	12, // [12:18] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_offersrpc_proto_init() }
@@ -951,7 +1390,7 @@ func file_offersrpc_proto_init() {
 			}
 		}
 		file_offersrpc_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Offer); i {
+			switch v := v.(*DecodeOffersRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -963,7 +1402,7 @@ func file_offersrpc_proto_init() {
 			}
 		}
 		file_offersrpc_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SubscribeOnionPayloadRequest); i {
+			switch v := v.(*DecodeOffersResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -975,7 +1414,7 @@ func file_offersrpc_proto_init() {
 			}
 		}
 		file_offersrpc_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SubscribeOnionPayloadResponse); i {
+			switch v := v.(*DecodeOfferResult); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -987,7 +1426,7 @@ func file_offersrpc_proto_init() {
 			}
 		}
 		file_offersrpc_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GenerateBlindedRouteRequest); i {
+			switch v := v.(*Offer); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -999,6 +1438,42 @@ func file_offersrpc_proto_init() {
 			}
 		}
 		file_offersrpc_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubscribeOnionPayloadRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_offersrpc_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubscribeOnionPayloadResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_offersrpc_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GenerateBlindedRouteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_offersrpc_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*GenerateBlindedRouteResponse); i {
 			case 0:
 				return &v.state
@@ -1010,6 +1485,30 @@ func file_offersrpc_proto_init() {
 				return nil
 			}
 		}
+		file_offersrpc_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SendOnionMessageWithReplyRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_offersrpc_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SendOnionMessageWithReplyResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -1017,7 +1516,7 @@ func file_offersrpc_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_offersrpc_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -20,8 +20,10 @@ const _ = grpc.SupportPackageIsVersion7
 type OffersClient interface {
 	SendOnionMessage(ctx context.Context, in *SendOnionMessageRequest, opts ...grpc.CallOption) (*SendOnionMessageResponse, error)
 	DecodeOffer(ctx context.Context, in *DecodeOfferRequest, opts ...grpc.CallOption) (*DecodeOfferResponse, error)
+	DecodeOffers(ctx context.Context, in *DecodeOffersRequest, opts ...grpc.CallOption) (*DecodeOffersResponse, error)
 	SubscribeOnionPayload(ctx context.Context, in *SubscribeOnionPayloadRequest, opts ...grpc.CallOption) (Offers_SubscribeOnionPayloadClient, error)
 	GenerateBlindedRoute(ctx context.Context, in *GenerateBlindedRouteRequest, opts ...grpc.CallOption) (*GenerateBlindedRouteResponse, error)
+	SendOnionMessageWithReply(ctx context.Context, in *SendOnionMessageWithReplyRequest, opts ...grpc.CallOption) (*SendOnionMessageWithReplyResponse, error)
 }
 
 type offersClient struct {
@@ -50,6 +52,15 @@ func (c *offersClient) DecodeOffer(ctx context.Context, in *DecodeOfferRequest,
 	return out, nil
 }
 
+func (c *offersClient) DecodeOffers(ctx context.Context, in *DecodeOffersRequest, opts ...grpc.CallOption) (*DecodeOffersResponse, error) {
+	out := new(DecodeOffersResponse)
+	err := c.cc.Invoke(ctx, "/offersrpc.Offers/DecodeOffers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *offersClient) SubscribeOnionPayload(ctx context.Context, in *SubscribeOnionPayloadRequest, opts ...grpc.CallOption) (Offers_SubscribeOnionPayloadClient, error) {
 	stream, err := c.cc.NewStream(ctx, &Offers_ServiceDesc.Streams[0], "/offersrpc.Offers/SubscribeOnionPayload", opts...)
 	if err != nil {
@@ -91,14 +102,25 @@ func (c *offersClient) GenerateBlindedRoute(ctx context.Context, in *GenerateBli
 	return out, nil
 }
 
+func (c *offersClient) SendOnionMessageWithReply(ctx context.Context, in *SendOnionMessageWithReplyRequest, opts ...grpc.CallOption) (*SendOnionMessageWithReplyResponse, error) {
+	out := new(SendOnionMessageWithReplyResponse)
+	err := c.cc.Invoke(ctx, "/offersrpc.Offers/SendOnionMessageWithReply", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // OffersServer is the server API for Offers service.
 // All implementations must embed UnimplementedOffersServer
 // for forward compatibility
 type OffersServer interface {
 	SendOnionMessage(context.Context, *SendOnionMessageRequest) (*SendOnionMessageResponse, error)
 	DecodeOffer(context.Context, *DecodeOfferRequest) (*DecodeOfferResponse, error)
+	DecodeOffers(context.Context, *DecodeOffersRequest) (*DecodeOffersResponse, error)
 	SubscribeOnionPayload(*SubscribeOnionPayloadRequest, Offers_SubscribeOnionPayloadServer) error
 	GenerateBlindedRoute(context.Context, *GenerateBlindedRouteRequest) (*GenerateBlindedRouteResponse, error)
+	SendOnionMessageWithReply(context.Context, *SendOnionMessageWithReplyRequest) (*SendOnionMessageWithReplyResponse, error)
 	mustEmbedUnimplementedOffersServer()
 }
 
@@ -112,12 +134,18 @@ func (UnimplementedOffersServer) SendOnionMessage(context.Context, *SendOnionMes
 func (UnimplementedOffersServer) DecodeOffer(context.Context, *DecodeOfferRequest) (*DecodeOfferResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DecodeOffer not implemented")
 }
+func (UnimplementedOffersServer) DecodeOffers(context.Context, *DecodeOffersRequest) (*DecodeOffersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DecodeOffers not implemented")
+}
 func (UnimplementedOffersServer) SubscribeOnionPayload(*SubscribeOnionPayloadRequest, Offers_SubscribeOnionPayloadServer) error {
 	return status.Errorf(codes.Unimplemented, "method SubscribeOnionPayload not implemented")
 }
 func (UnimplementedOffersServer) GenerateBlindedRoute(context.Context, *GenerateBlindedRouteRequest) (*GenerateBlindedRouteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GenerateBlindedRoute not implemented")
 }
+func (UnimplementedOffersServer) SendOnionMessageWithReply(context.Context, *SendOnionMessageWithReplyRequest) (*SendOnionMessageWithReplyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendOnionMessageWithReply not implemented")
+}
 func (UnimplementedOffersServer) mustEmbedUnimplementedOffersServer() {}
 
 // UnsafeOffersServer may be embedded to opt out of forward compatibility for this service.
@@ -167,6 +195,24 @@ func _Offers_DecodeOffer_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Offers_DecodeOffers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecodeOffersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OffersServer).DecodeOffers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/offersrpc.Offers/DecodeOffers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OffersServer).DecodeOffers(ctx, req.(*DecodeOffersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Offers_SubscribeOnionPayload_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SubscribeOnionPayloadRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -206,6 +252,24 @@ func _Offers_GenerateBlindedRoute_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Offers_SendOnionMessageWithReply_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendOnionMessageWithReplyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OffersServer).SendOnionMessageWithReply(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/offersrpc.Offers/SendOnionMessageWithReply",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OffersServer).SendOnionMessageWithReply(ctx, req.(*SendOnionMessageWithReplyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Offers_ServiceDesc is the grpc.ServiceDesc for Offers service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -221,10 +285,18 @@ var Offers_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DecodeOffer",
 			Handler:    _Offers_DecodeOffer_Handler,
 		},
+		{
+			MethodName: "DecodeOffers",
+			Handler:    _Offers_DecodeOffers_Handler,
+		},
 		{
 			MethodName: "GenerateBlindedRoute",
 			Handler:    _Offers_GenerateBlindedRoute_Handler,
 		},
+		{
+			MethodName: "SendOnionMessageWithReply",
+			Handler:    _Offers_SendOnionMessageWithReply_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
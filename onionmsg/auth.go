@@ -0,0 +1,333 @@
+package onionmsg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnrpc/signrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// senderAuthLength is the length of a sender authentication record: a
+// compressed public key followed by a bip340 signature.
+const senderAuthLength = btcec.PubKeyBytesLenCompressed + schnorr.SignatureSize
+
+// senderAuthTag is the tag used to produce the tagged hash of the final hop
+// payloads that a sender signs.
+var senderAuthTag = []byte("boltnd/onionmsg/sender_auth")
+
+const (
+	// senderAuthRecipientType is the type used for the recipient's node key
+	// in the message that a sender signs. It is below the final hop payload
+	// range so that it can't collide with a payload.
+	senderAuthRecipientType tlv.Type = 0
+
+	// senderAuthReplyPathType is the type used for the encoded reply path
+	// in the message that a sender signs.
+	senderAuthReplyPathType tlv.Type = 2
+)
+
+var (
+	// ErrSenderAuth is returned when an onion message carries a sender
+	// authentication record that is invalid.
+	ErrSenderAuth = errors.New("invalid sender authentication")
+
+	// ErrNoSenderAuth is returned when we require sender authentication
+	// and receive an onion message that does not include it.
+	ErrNoSenderAuth = errors.New("sender authentication required")
+)
+
+// NodeKeyLocator is the key locator for our node's identity key.
+var NodeKeyLocator = keychain.KeyLocator{
+	Family: keychain.KeyFamilyNodeKey,
+	Index:  0,
+}
+
+// LndPayloadSigner signs final hop payloads with our node's identity key
+// using lnd's signer.
+type LndPayloadSigner struct {
+	signer LndMessageSigner
+	pubkey *btcec.PublicKey
+}
+
+// Compile time assertion that LndPayloadSigner implements PayloadSigner.
+var _ PayloadSigner = (*LndPayloadSigner)(nil)
+
+// NewLndPayloadSigner creates a payload signer that uses lnd to sign with the
+// node key provided.
+func NewLndPayloadSigner(signer LndMessageSigner,
+	pubkey *btcec.PublicKey) *LndPayloadSigner {
+
+	return &LndPayloadSigner{
+		signer: signer,
+		pubkey: pubkey,
+	}
+}
+
+// PubKey returns our node's public key.
+func (l *LndPayloadSigner) PubKey() *btcec.PublicKey {
+	return l.pubkey
+}
+
+// Sign signs the tagged hash of the message provided with our node key.
+func (l *LndPayloadSigner) Sign(ctx context.Context, tag,
+	msg []byte) ([]byte, error) {
+
+	return l.signer.SignMessage(
+		ctx, msg, NodeKeyLocator, lndclient.SignSchnorr(nil),
+		func(req *signrpc.SignMessageReq) {
+			req.Tag = tag
+		},
+	)
+}
+
+// PrivKeyPayloadSigner signs final hop payloads with a private key that is
+// held in memory.
+type PrivKeyPayloadSigner struct {
+	PrivKey *btcec.PrivateKey
+}
+
+// Compile time assertion that PrivKeyPayloadSigner implements PayloadSigner.
+var _ PayloadSigner = (*PrivKeyPayloadSigner)(nil)
+
+// PubKey returns the public key of our private key.
+func (p *PrivKeyPayloadSigner) PubKey() *btcec.PublicKey {
+	return p.PrivKey.PubKey()
+}
+
+// Sign signs the tagged hash of the message provided with our private key.
+func (p *PrivKeyPayloadSigner) Sign(_ context.Context, tag,
+	msg []byte) ([]byte, error) {
+
+	digest := chainhash.TaggedHash(tag, msg)
+
+	sig, err := schnorr.Sign(p.PrivKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return sig.Serialize(), nil
+}
+
+// senderAuthMessage serializes the recipient, reply path and final hop
+// payloads of an onion message, excluding any sender authentication record,
+// for signing. The recipient is optional, because senders that deliver to a
+// blinded destination don't know the recipient's node key. Records are
+// serialized in ascending order of tlv type as type || length || value so
+// that the message does not depend on the order that payloads are provided
+// in.
+func senderAuthMessage(recipient *btcec.PublicKey, replyPath *lnwire.ReplyPath,
+	payloads []*lnwire.FinalHopPayload) ([]byte, error) {
+
+	records := make([]*lnwire.FinalHopPayload, 0, len(payloads)+2)
+	if recipient != nil {
+		records = append(records, &lnwire.FinalHopPayload{
+			TLVType: senderAuthRecipientType,
+			Value:   recipient.SerializeCompressed(),
+		})
+	}
+
+	if replyPath != nil {
+		encoded, err := lnwire.EncodeReplyPath(replyPath)
+		if err != nil {
+			return nil, fmt.Errorf("reply path: %w", err)
+		}
+
+		records = append(records, &lnwire.FinalHopPayload{
+			TLVType: senderAuthReplyPathType,
+			Value:   encoded,
+		})
+	}
+
+	for _, payload := range payloads {
+		if payload.TLVType != lnwire.SenderAuthType {
+			records = append(records, payload)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].TLVType < records[j].TLVType
+	})
+
+	var (
+		w   bytes.Buffer
+		buf [8]byte
+	)
+
+	for _, record := range records {
+		err := tlv.WriteVarInt(&w, uint64(record.TLVType), &buf)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tlv.WriteVarInt(&w, uint64(len(record.Value)), &buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := w.Write(record.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return w.Bytes(), nil
+}
+
+// signPayloads returns a copy of the request provided with a sender
+// authentication record added to its final hop payloads. The signature
+// covers the request's reply path and, if we are sending to an unblinded
+// destination, the recipient's node key.
+func signPayloads(ctx context.Context, signer PayloadSigner,
+	req *SendMessageRequest) (*SendMessageRequest, error) {
+
+	var recipient *btcec.PublicKey
+	if req.BlindedDestination == nil {
+		recipient = req.Peer
+	}
+
+	msg, err := senderAuthMessage(
+		recipient, req.ReplyPath, req.FinalPayloads,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("auth message: %w", err)
+	}
+
+	sig, err := signer.Sign(ctx, senderAuthTag, msg)
+	if err != nil {
+		return nil, fmt.Errorf("sign payloads: %w", err)
+	}
+
+	if len(sig) != schnorr.SignatureSize {
+		return nil, fmt.Errorf("%w: signature length %v",
+			lnwire.ErrInvalidSig, len(sig))
+	}
+
+	value := make([]byte, 0, senderAuthLength)
+	value = append(value, signer.PubKey().SerializeCompressed()...)
+	value = append(value, sig...)
+
+	reqCopy := *req
+	reqCopy.FinalPayloads = append(
+		append([]*lnwire.FinalHopPayload(nil), req.FinalPayloads...),
+		&lnwire.FinalHopPayload{
+			TLVType: lnwire.SenderAuthType,
+			Value:   value,
+		},
+	)
+
+	return &reqCopy, nil
+}
+
+// AuthenticatedSender returns the public key of the sender of an onion
+// message, verifying the sender authentication record included in its final
+// hop payloads against its reply path and payloads. A nil public key is
+// returned if the payloads don't include a sender authentication record.
+//
+// Senders that know the recipient's node key include it in their signature,
+// so that the message can't be replayed to other nodes. If a recipient is
+// provided, we accept signatures made for that recipient, or signatures that
+// don't name a recipient, which are produced by senders that deliver to a
+// blinded destination. Those messages may be replayed by any node that
+// receives them, but only with their original reply path.
+func AuthenticatedSender(recipient *btcec.PublicKey,
+	payload *lnwire.OnionMessagePayload) (*btcec.PublicKey, error) {
+
+	var auth *lnwire.FinalHopPayload
+	for _, finalPayload := range payload.FinalHopPayloads {
+		if finalPayload.TLVType == lnwire.SenderAuthType {
+			auth = finalPayload
+			break
+		}
+	}
+
+	if auth == nil {
+		return nil, nil
+	}
+
+	if len(auth.Value) != senderAuthLength {
+		return nil, fmt.Errorf("%w: record length %v", ErrSenderAuth,
+			len(auth.Value))
+	}
+
+	sender, err := btcec.ParsePubKey(
+		auth.Value[:btcec.PubKeyBytesLenCompressed],
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: sender: %v", ErrSenderAuth, err)
+	}
+
+	sig, err := schnorr.ParseSignature(
+		auth.Value[btcec.PubKeyBytesLenCompressed:],
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrSenderAuth, err)
+	}
+
+	verify := func(recipient *btcec.PublicKey) (bool, error) {
+		msg, err := senderAuthMessage(
+			recipient, payload.ReplyPath, payload.FinalHopPayloads,
+		)
+		if err != nil {
+			return false, fmt.Errorf("auth message: %w", err)
+		}
+
+		digest := chainhash.TaggedHash(senderAuthTag, msg)
+
+		return sig.Verify(digest[:], sender), nil
+	}
+
+	if recipient != nil {
+		ok, err := verify(recipient)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return sender, nil
+		}
+	}
+
+	ok, err := verify(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: signature does not match sender: "+
+			"%x", ErrSenderAuth, sender.SerializeCompressed())
+	}
+
+	return sender, nil
+}
+
+// SenderAuthInterceptor returns a dispatch interceptor that verifies the
+// sender authentication records in onion messages addressed to our node,
+// dropping messages that carry an invalid record or a record that was signed
+// for another recipient. If required is true, messages that do not carry a
+// record are also dropped.
+func SenderAuthInterceptor(nodeKey *btcec.PublicKey,
+	required bool) DispatchInterceptor {
+
+	return func(_ route.Vertex, payload *lnwire.OnionMessagePayload) error {
+		sender, err := AuthenticatedSender(nodeKey, payload)
+		if err != nil {
+			return err
+		}
+
+		if sender == nil && required {
+			return ErrNoSenderAuth
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,262 @@
+package onionmsg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuthenticatedSender tests signing and verification of sender
+// authentication records.
+func TestAuthenticatedSender(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		privkey  = privkeys[0]
+		signer   = &PrivKeyPayloadSigner{PrivKey: privkey}
+
+		recipient = privkeys[1].PubKey()
+		other     = privkeys[2].PubKey()
+
+		replyPath = &lnwire.ReplyPath{
+			FirstNodeID:   privkey.PubKey(),
+			BlindingPoint: other,
+			Hops: []*lnwire.BlindedHop{
+				{
+					BlindedNodeID: other,
+					EncryptedData: []byte{1},
+				},
+			},
+		}
+
+		payloads = []*lnwire.FinalHopPayload{
+			{
+				TLVType: 101,
+				Value:   []byte{1, 2, 3},
+			},
+			{
+				TLVType: 100,
+				Value:   []byte{4, 5},
+			},
+		}
+	)
+
+	// Payloads without an authentication record have no sender.
+	unsigned := &lnwire.OnionMessagePayload{
+		FinalHopPayloads: payloads,
+	}
+	sender, err := AuthenticatedSender(recipient, unsigned)
+	require.NoError(t, err)
+	require.Nil(t, sender)
+
+	req := NewSendMessageRequest(recipient, nil, replyPath, payloads, false)
+	signed, err := signPayloads(context.Background(), signer, req)
+	require.NoError(t, err)
+	require.Len(t, signed.FinalPayloads, 3)
+	require.Len(t, req.FinalPayloads, 2, "request modified")
+
+	received := &lnwire.OnionMessagePayload{
+		ReplyPath:        replyPath,
+		FinalHopPayloads: signed.FinalPayloads,
+	}
+	sender, err = AuthenticatedSender(recipient, received)
+	require.NoError(t, err)
+	require.True(t, privkey.PubKey().IsEqual(sender))
+
+	// Our record should verify regardless of the order of our payloads.
+	_, err = AuthenticatedSender(recipient, &lnwire.OnionMessagePayload{
+		ReplyPath: replyPath,
+		FinalHopPayloads: []*lnwire.FinalHopPayload{
+			signed.FinalPayloads[2], signed.FinalPayloads[1],
+			signed.FinalPayloads[0],
+		},
+	})
+	require.NoError(t, err)
+
+	// Tampering with a payload should invalidate our record.
+	_, err = AuthenticatedSender(recipient, &lnwire.OnionMessagePayload{
+		ReplyPath: replyPath,
+		FinalHopPayloads: []*lnwire.FinalHopPayload{
+			{
+				TLVType: 101,
+				Value:   []byte{1, 2, 4},
+			},
+			signed.FinalPayloads[1], signed.FinalPayloads[2],
+		},
+	})
+	require.ErrorIs(t, err, ErrSenderAuth)
+
+	// Replacing or removing the reply path should invalidate our record,
+	// so that replies can't be redirected.
+	_, err = AuthenticatedSender(recipient, &lnwire.OnionMessagePayload{
+		ReplyPath: &lnwire.ReplyPath{
+			FirstNodeID:   other,
+			BlindingPoint: other,
+			Hops:          replyPath.Hops,
+		},
+		FinalHopPayloads: signed.FinalPayloads,
+	})
+	require.ErrorIs(t, err, ErrSenderAuth)
+
+	_, err = AuthenticatedSender(recipient, &lnwire.OnionMessagePayload{
+		FinalHopPayloads: signed.FinalPayloads,
+	})
+	require.ErrorIs(t, err, ErrSenderAuth)
+
+	// A recipient that replays our message to another node should not be
+	// able to pass it off as ours.
+	_, err = AuthenticatedSender(other, received)
+	require.ErrorIs(t, err, ErrSenderAuth)
+
+	_, err = AuthenticatedSender(nil, received)
+	require.ErrorIs(t, err, ErrSenderAuth)
+
+	// Messages to a blinded destination don't name their recipient, and
+	// are accepted by any node.
+	blindedReq := NewSendMessageRequest(
+		nil, replyPath, nil, payloads, false,
+	)
+	signed, err = signPayloads(context.Background(), signer, blindedReq)
+	require.NoError(t, err)
+
+	blinded := &lnwire.OnionMessagePayload{
+		FinalHopPayloads: signed.FinalPayloads,
+	}
+	sender, err = AuthenticatedSender(recipient, blinded)
+	require.NoError(t, err)
+	require.True(t, privkey.PubKey().IsEqual(sender))
+
+	// Records that are the wrong length are invalid.
+	_, err = AuthenticatedSender(recipient, &lnwire.OnionMessagePayload{
+		FinalHopPayloads: []*lnwire.FinalHopPayload{
+			{
+				TLVType: lnwire.SenderAuthType,
+				Value:   []byte{1},
+			},
+		},
+	})
+	require.ErrorIs(t, err, ErrSenderAuth)
+}
+
+// TestLndPayloadSigner tests signing of payloads with lnd's signer.
+func TestLndPayloadSigner(t *testing.T) {
+	var (
+		privkey = testutils.GetPrivkeys(t, 1)[0]
+		ctxb    = context.Background()
+
+		payloads = []*lnwire.FinalHopPayload{
+			{
+				TLVType: 101,
+				Value:   []byte{1, 2, 3},
+			},
+		}
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	msg, err := senderAuthMessage(privkey.PubKey(), nil, payloads)
+	require.NoError(t, err)
+
+	// Produce the signature that we expect lnd to return for our
+	// payloads.
+	sig, err := (&PrivKeyPayloadSigner{PrivKey: privkey}).Sign(
+		ctxb, senderAuthTag, msg,
+	)
+	require.NoError(t, err)
+
+	signer := NewLndPayloadSigner(lnd, privkey.PubKey())
+	req := NewSendMessageRequest(
+		privkey.PubKey(), nil, nil, payloads, false,
+	)
+
+	testutils.MockSignMessage(lnd.Mock, msg, NodeKeyLocator, sig, nil)
+	signed, err := signPayloads(ctxb, signer, req)
+	require.NoError(t, err)
+
+	sender, err := AuthenticatedSender(
+		privkey.PubKey(), &lnwire.OnionMessagePayload{
+			FinalHopPayloads: signed.FinalPayloads,
+		},
+	)
+	require.NoError(t, err)
+	require.True(t, privkey.PubKey().IsEqual(sender))
+
+	// We should fail if lnd returns a signature with the wrong length.
+	testutils.MockSignMessage(lnd.Mock, msg, NodeKeyLocator, sig[1:], nil)
+	_, err = signPayloads(ctxb, signer, req)
+	require.ErrorIs(t, err, lnwire.ErrInvalidSig)
+}
+
+// TestSenderAuthentication tests delivery of authenticated onion messages
+// between messengers.
+func TestSenderAuthentication(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		tlvType  = tlv.Type(101)
+	)
+
+	network := NewMemoryNetwork()
+
+	signedSender := newMemoryMessenger(
+		t, network, privkeys[0], OptionPayloadSigner(
+			&PrivKeyPayloadSigner{PrivKey: privkeys[0]},
+		),
+	)
+	unsignedSender := newMemoryMessenger(t, network, privkeys[1])
+
+	receiver := newMemoryMessenger(
+		t, network, privkeys[2], OptionDispatchInterceptors(
+			SenderAuthInterceptor(privkeys[2].PubKey(), true),
+		),
+	)
+
+	handled := make(chan []byte, 1)
+	_, err := receiver.RegisterHandler(tlvType, func(_ *lnwire.ReplyPath,
+		_, value []byte) error {
+
+		handled <- value
+		return nil
+	})
+	require.NoError(t, err)
+
+	send := func(messenger *Messenger, value []byte) {
+		req := NewSendMessageRequest(
+			privkeys[2].PubKey(), nil, nil,
+			[]*lnwire.FinalHopPayload{
+				{
+					TLVType: tlvType,
+					Value:   value,
+				},
+			}, true,
+		)
+		require.NoError(t, messenger.SendMessage(
+			context.Background(), req,
+		))
+	}
+
+	// Our unsigned message should be dropped by our receiver, and our
+	// signed message delivered.
+	send(unsignedSender, []byte{1})
+	send(signedSender, []byte{2})
+
+	select {
+	case value := <-handled:
+		require.Equal(t, []byte{2}, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not delivered")
+	}
+
+	require.Eventually(t, func() bool {
+		stats := receiver.PeerStats()
+		unsigned := stats[route.NewVertex(privkeys[1].PubKey())]
+
+		return unsigned.Failed[FailurePolicyDrop] == 1
+	}, defaultTimeout, time.Millisecond*10)
+}
@@ -0,0 +1,31 @@
+package onionmsg
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jitteredBackoff returns the amount of time to back off for on the attempt
+// provided (zero indexed). Our backoff doubles on each attempt up to the
+// maximum provided, and we randomly pick a value in the upper half of that
+// backoff so that senders that started at the same time don't poll in
+// lockstep.
+func jitteredBackoff(initial, maxBackoff time.Duration,
+	attempt int) time.Duration {
+
+	backoff := initial
+	for i := 0; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
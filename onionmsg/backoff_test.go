@@ -0,0 +1,111 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJitteredBackoff tests that our backoff grows exponentially up to its
+// maximum, and that jitter keeps it within the upper half of each backoff.
+func TestJitteredBackoff(t *testing.T) {
+	var (
+		initial    = time.Second
+		maxBackoff = time.Second * 5
+	)
+
+	tests := []struct {
+		attempt int
+		backoff time.Duration
+	}{
+		{
+			attempt: 0,
+			backoff: time.Second,
+		},
+		{
+			attempt: 1,
+			backoff: time.Second * 2,
+		},
+		{
+			attempt: 2,
+			backoff: time.Second * 4,
+		},
+		{
+			attempt: 3,
+			backoff: maxBackoff,
+		},
+		{
+			attempt: 100,
+			backoff: maxBackoff,
+		},
+	}
+
+	for _, testCase := range tests {
+		for i := 0; i < 10; i++ {
+			backoff := jitteredBackoff(
+				initial, maxBackoff, testCase.attempt,
+			)
+			require.GreaterOrEqual(t, backoff, testCase.backoff/2)
+			require.LessOrEqual(t, backoff, testCase.backoff)
+		}
+	}
+
+	require.Zero(t, jitteredBackoff(0, 0, 3))
+}
+
+// TestPeerLookupDeadline tests that we stop waiting for a peer to connect
+// once our lookup deadline has passed, even if we have lookup attempts left.
+func TestPeerLookupDeadline(t *testing.T) {
+	var (
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: testutils.GetPrivkeys(t, 1)[0],
+		}
+
+		peer     = testutils.GetPubkeys(t, 1)[0]
+		vertex   = route.NewVertex(peer)
+		nodeAddr = "host:port"
+
+		nodeInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Addresses: []string{
+					nodeAddr,
+				},
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	messenger, err := NewOnionMessenger(
+		lnd, nodeKeyECDH, nil, OptionLookupPeerBackoff(
+			time.Hour, time.Hour, time.Millisecond*10,
+		),
+	)
+	require.NoError(t, err, "new messenger")
+
+	// We expect to look up our peer once before connecting and poll for
+	// it once after, then time out while backing off.
+	testutils.MockListPeers(lnd.Mock, nil, nil)
+	testutils.MockGetNodeInfo(lnd.Mock, vertex, false, nodeInfo, nil)
+	mockPollPeers(lnd.Mock)
+	testutils.MockConnect(lnd.Mock, vertex, nodeAddr, true, nil)
+	testutils.MockListPeers(lnd.Mock, nil, nil)
+
+	_, err = messenger.lookupAndConnect(
+		context.Background(), peer, true, func(SendProgress) {},
+	)
+	require.True(t, errors.Is(err, ErrNoConnection))
+}
@@ -0,0 +1,388 @@
+package onionmsg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// defaultBulkConcurrency is the default number of messages that we send at
+// once in a bulk send.
+const defaultBulkConcurrency = 8
+
+// CoalesceRequests combines send requests that are addressed to the same
+// destination into a single request, so that their final hop payloads are
+// delivered in one onion rather than paying the overhead of a separate onion
+// per message. Requests are only combined if they have the same reply path
+// and delivery options, and their final hop payloads use distinct tlv types.
+// Combined requests expire at the earliest of their expiries. The requests
+// provided are not modified, and the order in which requests are first seen
+// is preserved.
+func CoalesceRequests(reqs []*SendMessageRequest) ([]*SendMessageRequest,
+	error) {
+
+	var batches []*SendMessageRequest
+
+	for i, req := range reqs {
+		if err := req.Validate(); err != nil {
+			return nil, fmt.Errorf("request %v: %w", i, err)
+		}
+
+		added := false
+		for _, batch := range batches {
+			ok, err := canCoalesce(batch, req)
+			if err != nil {
+				return nil, fmt.Errorf("request %v: %w", i, err)
+			}
+
+			if !ok {
+				continue
+			}
+
+			batch.FinalPayloads = append(
+				batch.FinalPayloads, req.FinalPayloads...,
+			)
+
+			if !req.Expiry.IsZero() && (batch.Expiry.IsZero() ||
+				req.Expiry.Before(batch.Expiry)) {
+
+				batch.Expiry = req.Expiry
+			}
+
+			added = true
+			break
+		}
+
+		if added {
+			continue
+		}
+
+		// Copy our request and its payloads so that appending to the
+		// batch does not modify the caller's request.
+		batch := *req
+		batch.FinalPayloads = append(
+			[]*lnwire.FinalHopPayload(nil), req.FinalPayloads...,
+		)
+		batches = append(batches, &batch)
+	}
+
+	return batches, nil
+}
+
+// canCoalesce returns a boolean indicating whether a request can be added to
+// a batch of requests.
+func canCoalesce(batch, req *SendMessageRequest) (bool, error) {
+	// We can't tell whether two progress callbacks are the same, so we
+	// don't combine requests that have callbacks.
+	if batch.OnProgress != nil || req.OnProgress != nil {
+		return false, nil
+	}
+
+	if !pubkeyEqual(batch.Peer, req.Peer) ||
+		batch.DirectConnect != req.DirectConnect ||
+		batch.DisconnectAfterSend != req.DisconnectAfterSend {
+
+		return false, nil
+	}
+
+	if !pubkeysEqual(batch.AvoidNodes, req.AvoidNodes) ||
+		!pubkeysEqual(batch.Route, req.Route) {
+
+		return false, nil
+	}
+
+	sameDest, err := replyPathEqual(
+		batch.BlindedDestination, req.BlindedDestination,
+	)
+	if err != nil || !sameDest {
+		return false, err
+	}
+
+	sameReply, err := replyPathEqual(batch.ReplyPath, req.ReplyPath)
+	if err != nil || !sameReply {
+		return false, err
+	}
+
+	// We can't include the same tlv type twice in a single payload.
+	types := make(map[tlv.Type]struct{}, len(batch.FinalPayloads))
+	for _, payload := range batch.FinalPayloads {
+		types[payload.TLVType] = struct{}{}
+	}
+
+	for _, payload := range req.FinalPayloads {
+		if _, ok := types[payload.TLVType]; ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// pubkeyEqual returns a boolean indicating whether two optional pubkeys are
+// equal.
+func pubkeyEqual(a, b *btcec.PublicKey) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.IsEqual(b)
+}
+
+// pubkeysEqual returns a boolean indicating whether two lists of pubkeys
+// contain the same pubkeys in the same order.
+func pubkeysEqual(a, b []*btcec.PublicKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, pubkey := range a {
+		if !pubkeyEqual(pubkey, b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// replyPathEqual returns a boolean indicating whether two optional blinded
+// paths are equal, comparing their encodings.
+func replyPathEqual(a, b *lnwire.ReplyPath) (bool, error) {
+	if a == nil || b == nil {
+		return a == b, nil
+	}
+
+	aBytes, err := lnwire.EncodeOnionMessagePayload(
+		&lnwire.OnionMessagePayload{
+			ReplyPath: a,
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("encode path: %w", err)
+	}
+
+	bBytes, err := lnwire.EncodeOnionMessagePayload(
+		&lnwire.OnionMessagePayload{
+			ReplyPath: b,
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("encode path: %w", err)
+	}
+
+	return bytes.Equal(aBytes, bBytes), nil
+}
+
+// SendBatch coalesces the send requests provided into as few onions as
+// possible using CoalesceRequests, and sends each of the combined requests.
+// A failure to send one combined request does not prevent sending the
+// others, and all failures are returned.
+func (m *Messenger) SendBatch(ctx context.Context,
+	reqs []*SendMessageRequest) error {
+
+	batches, err := CoalesceRequests(reqs)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Sending %v onion messages in %v onions", len(reqs),
+		len(batches))
+
+	var sendErrs []error
+	for _, batch := range batches {
+		if err := m.SendMessage(ctx, batch); err != nil {
+			sendErrs = append(sendErrs, err)
+		}
+	}
+
+	return errors.Join(sendErrs...)
+}
+
+// BulkSendOptions contains options for sending onion messages in bulk.
+type BulkSendOptions struct {
+	// Concurrency is the maximum number of messages that are sent at
+	// once. If zero, a default concurrency is used.
+	Concurrency int
+
+	// Interval is the minimum amount of time between starting each send.
+	// If zero, sends are only paced by the messenger's send limits.
+	Interval time.Duration
+}
+
+// SendMessages sends a set of onion messages, for example to fan out a
+// notification to many recipients. Messages are sent with bounded
+// concurrency, and sends may be paced by the interval provided to spread
+// their load on our peers. Path lookups are shared between all of the
+// messages in the set, so messages to the same destination only look up a
+// path once. Each message is delivered as with SendMessage, and an error is
+// returned for each request in the order provided, which is nil if the
+// message was sent (or queued in our outbox). Requests that are not sent
+// because the context provided is cancelled or we shut down fail with the
+// reason that sending stopped.
+func (m *Messenger) SendMessages(ctx context.Context,
+	reqs []*SendMessageRequest, opts BulkSendOptions) []error {
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	var (
+		errs       = make([]error, len(reqs))
+		pathFinder = newSharedPathFinder(m.pathFinder)
+		sem        = make(chan struct{}, concurrency)
+		wg         sync.WaitGroup
+	)
+
+	started := 0
+	for i, req := range reqs {
+		if err := req.Validate(); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		var pace time.Duration
+		if started > 0 {
+			pace = opts.Interval
+		}
+
+		// If we can't start this send, we fail all of our remaining
+		// requests with the reason that we stopped.
+		if err := m.bulkSlot(ctx, sem, pace); err != nil {
+			for j := i; j < len(reqs); j++ {
+				errs[j] = err
+			}
+
+			break
+		}
+		started++
+
+		wg.Add(1)
+		go func(i int, req *SendMessageRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errs[i] = m.sendOrQueue(ctx, req, pathFinder)
+		}(i, req)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// bulkSlot blocks for the pacing interval provided, if any, and then until
+// we can acquire a slot in the semaphore provided. An error is returned if
+// the context provided is cancelled or we shut down.
+func (m *Messenger) bulkSlot(ctx context.Context, sem chan struct{},
+	pace time.Duration) error {
+
+	// Check our context first so that we don't start any sends once it
+	// has been cancelled, since select does not prioritize its cases.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if pace > 0 {
+		timer := time.NewTimer(pace)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-m.quit:
+			return ErrShuttingDown
+		}
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case <-m.quit:
+		return ErrShuttingDown
+	}
+}
+
+// sharedPath is the result of a path lookup that is shared between sends.
+type sharedPath struct {
+	path []*btcec.PublicKey
+	err  error
+
+	// done is closed once our lookup has completed.
+	done chan struct{}
+}
+
+// sharedPathFinder wraps a path finder and shares the result of each path
+// lookup between all of its callers, so that sends to the same destination
+// look up a path once. Concurrent lookups for the same destination wait for
+// the first lookup to complete.
+type sharedPathFinder struct {
+	PathFinder
+
+	// paths holds our lookups, keyed by target and nodes to avoid, and
+	// must be accessed under lock.
+	paths map[string]*sharedPath
+	lock  sync.Mutex
+}
+
+// newSharedPathFinder creates a path finder that shares the lookups made via
+// the path finder provided.
+func newSharedPathFinder(finder PathFinder) *sharedPathFinder {
+	return &sharedPathFinder{
+		PathFinder: finder,
+		paths:      make(map[string]*sharedPath),
+	}
+}
+
+// FindPath returns a path to the target provided, looking it up if we have
+// not previously looked up a path for the same target and nodes to avoid.
+func (s *sharedPathFinder) FindPath(ctx context.Context,
+	target *btcec.PublicKey, avoid []*btcec.PublicKey) ([]*btcec.PublicKey,
+	error) {
+
+	key := make([]byte, 0, (len(avoid)+1)*btcec.PubKeyBytesLenCompressed)
+	key = append(key, target.SerializeCompressed()...)
+	for _, node := range avoid {
+		key = append(key, node.SerializeCompressed()...)
+	}
+
+	s.lock.Lock()
+	shared, ok := s.paths[string(key)]
+	if !ok {
+		shared = &sharedPath{
+			done: make(chan struct{}),
+		}
+		s.paths[string(key)] = shared
+	}
+	s.lock.Unlock()
+
+	if !ok {
+		shared.path, shared.err = s.PathFinder.FindPath(
+			ctx, target, avoid,
+		)
+		close(shared.done)
+	}
+
+	select {
+	case <-shared.done:
+		return shared.path, shared.err
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
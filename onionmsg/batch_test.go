@@ -0,0 +1,287 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCoalesceRequests tests combining of send requests into batches.
+func TestCoalesceRequests(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 3)
+
+	var (
+		payload1 = &lnwire.FinalHopPayload{TLVType: 101}
+		payload2 = &lnwire.FinalHopPayload{TLVType: 103}
+
+		replyPath = &lnwire.ReplyPath{
+			FirstNodeID:   pubkeys[1],
+			BlindingPoint: pubkeys[2],
+			Hops: []*lnwire.BlindedHop{
+				{
+					BlindedNodeID: pubkeys[2],
+					EncryptedData: []byte{1},
+				},
+			},
+		}
+
+		expiry1 = time.Unix(100, 0)
+		expiry2 = time.Unix(200, 0)
+	)
+
+	payloads := func(
+		p ...*lnwire.FinalHopPayload) []*lnwire.FinalHopPayload {
+
+		return p
+	}
+
+	request := func(payload *lnwire.FinalHopPayload) *SendMessageRequest {
+		return &SendMessageRequest{
+			Peer:          pubkeys[0],
+			FinalPayloads: payloads(payload),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		reqs     func() []*SendMessageRequest
+		expected []*SendMessageRequest
+		err      error
+	}{
+		{
+			name: "same destination combined",
+			reqs: func() []*SendMessageRequest {
+				req1 := request(payload1)
+				req1.Expiry = expiry2
+
+				req2 := request(payload2)
+				req2.Expiry = expiry1
+
+				return []*SendMessageRequest{req1, req2}
+			},
+			expected: []*SendMessageRequest{
+				{
+					Peer: pubkeys[0],
+					FinalPayloads: payloads(
+						payload1, payload2,
+					),
+					Expiry: expiry1,
+				},
+			},
+		},
+		{
+			name: "duplicate payload types not combined",
+			reqs: func() []*SendMessageRequest {
+				return []*SendMessageRequest{
+					request(payload1), request(payload1),
+				}
+			},
+			expected: []*SendMessageRequest{
+				request(payload1), request(payload1),
+			},
+		},
+		{
+			name: "different destinations not combined",
+			reqs: func() []*SendMessageRequest {
+				req2 := request(payload2)
+				req2.Peer = pubkeys[1]
+
+				return []*SendMessageRequest{
+					request(payload1), req2,
+					request(payload2),
+				}
+			},
+			expected: []*SendMessageRequest{
+				{
+					Peer: pubkeys[0],
+					FinalPayloads: payloads(
+						payload1, payload2,
+					),
+				},
+				{
+					Peer:          pubkeys[1],
+					FinalPayloads: payloads(payload2),
+				},
+			},
+		},
+		{
+			name: "different reply paths not combined",
+			reqs: func() []*SendMessageRequest {
+				req2 := request(payload2)
+				req2.ReplyPath = replyPath
+
+				return []*SendMessageRequest{
+					request(payload1), req2,
+				}
+			},
+			expected: []*SendMessageRequest{
+				request(payload1),
+				{
+					Peer:          pubkeys[0],
+					ReplyPath:     replyPath,
+					FinalPayloads: payloads(payload2),
+				},
+			},
+		},
+		{
+			name: "invalid request",
+			reqs: func() []*SendMessageRequest {
+				return []*SendMessageRequest{{}}
+			},
+			err: ErrNoDest,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			reqs := testCase.reqs()
+			batches, err := CoalesceRequests(reqs)
+			require.True(t, errors.Is(err, testCase.err))
+			require.Equal(t, testCase.expected, batches)
+
+			// Our original requests should not be modified.
+			for _, req := range reqs {
+				require.LessOrEqual(
+					t, len(req.FinalPayloads), 1,
+				)
+			}
+		})
+	}
+}
+
+// countingPathFinder wraps a path finder and counts the lookups made for each
+// target.
+type countingPathFinder struct {
+	PathFinder
+
+	lookups map[route.Vertex]int
+	lock    sync.Mutex
+}
+
+// FindPath counts our lookup and finds a path with our path finder.
+func (c *countingPathFinder) FindPath(ctx context.Context,
+	target *btcec.PublicKey, avoid []*btcec.PublicKey) ([]*btcec.PublicKey,
+	error) {
+
+	c.lock.Lock()
+	c.lookups[route.NewVertex(target)]++
+	c.lock.Unlock()
+
+	return c.PathFinder.FindPath(ctx, target, avoid)
+}
+
+// TestSendMessages tests sending onion messages in bulk over an in-memory
+// network.
+func TestSendMessages(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+		tlvType  = tlv.Type(101)
+		network  = NewMemoryNetwork()
+	)
+
+	node := network.AddNode(alice)
+	finder := &countingPathFinder{
+		PathFinder: NewQueryRoutesPathFinder(node),
+		lookups:    make(map[route.Vertex]int),
+	}
+
+	sender, err := NewTransportMessenger(
+		node, node, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionPathFinder(finder),
+	)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, sender.Start(), "start messenger")
+	t.Cleanup(func() {
+		require.NoError(t, sender.Stop(), "stop messenger")
+	})
+
+	handled := make(chan []byte, 10)
+	handler := func(_ *lnwire.ReplyPath, _, value []byte) error {
+		handled <- value
+		return nil
+	}
+
+	for _, privkey := range privkeys[1:] {
+		receiver := newMemoryMessenger(t, network, privkey)
+		_, err := receiver.RegisterHandler(tlvType, handler)
+		require.NoError(t, err, "register handler")
+	}
+
+	_, err = network.AddChannel(alice, bob)
+	require.NoError(t, err)
+
+	_, err = network.AddChannel(bob, carol)
+	require.NoError(t, err)
+
+	// Create a set of requests that sends multiple messages to each of
+	// bob and carol, including an invalid request with no destination.
+	var reqs []*SendMessageRequest
+	for i := 0; i < 6; i++ {
+		dest := privkeys[1+i%2].PubKey()
+
+		reqs = append(reqs, NewSendMessageRequest(
+			dest, nil, nil, []*lnwire.FinalHopPayload{
+				{
+					TLVType: tlvType,
+					Value:   []byte{byte(i)},
+				},
+			}, false,
+		))
+	}
+	reqs = append(reqs, NewSendMessageRequest(nil, nil, nil, nil, false))
+
+	errs := sender.SendMessages(
+		context.Background(), reqs, BulkSendOptions{
+			Concurrency: 2,
+			Interval:    time.Millisecond,
+		},
+	)
+	require.Len(t, errs, len(reqs))
+	require.ErrorIs(t, errs[len(reqs)-1], ErrNoDest)
+
+	for i, err := range errs[:len(reqs)-1] {
+		require.NoError(t, err, "request %v", i)
+	}
+
+	received := make(map[byte]bool)
+	for i := 0; i < 6; i++ {
+		select {
+		case value := <-handled:
+			received[value[0]] = true
+
+		case <-time.After(defaultTimeout):
+			t.Fatal("message not delivered")
+		}
+	}
+	require.Len(t, received, 6)
+
+	// We expect a single path lookup for each of our destinations.
+	require.Equal(t, map[route.Vertex]int{
+		bob:   1,
+		carol: 1,
+	}, finder.lookups)
+
+	// Once our context is cancelled, we don't send any messages.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errs = sender.SendMessages(ctx, reqs[:2], BulkSendOptions{})
+	for _, err := range errs {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+}
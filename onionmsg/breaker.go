@@ -0,0 +1,144 @@
+package onionmsg
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+const (
+	// breakerThresholdDefault is the default number of consecutive
+	// failures that we allow from a peer before we stop accepting onion
+	// messages from it.
+	breakerThresholdDefault = 20
+
+	// breakerCoolDownDefault is the default amount of time that we stop
+	// accepting onion messages from a peer for once its breaker trips.
+	breakerCoolDownDefault = time.Minute * 10
+)
+
+// breakerState tracks the failures of a single peer.
+type breakerState struct {
+	// failures is the number of consecutive failures for the peer.
+	failures int
+
+	// trippedAt is the time that the peer's breaker tripped, or the zero
+	// time if it has not tripped.
+	trippedAt time.Time
+}
+
+// peerBreaker is a circuit breaker that tracks the malformed messages and
+// failed forwards that each of our peers sends us, and temporarily stops
+// accepting onion messages from peers that hit our failure threshold.
+type peerBreaker struct {
+	// threshold is the number of consecutive failures that trips a
+	// peer's breaker. If zero, the breaker is disabled.
+	threshold int
+
+	// coolDown is the amount of time that a peer's breaker stays tripped
+	// before we reset it.
+	coolDown time.Duration
+
+	now func() time.Time
+
+	// peers tracks the state of each peer that has failed since its last
+	// success, and must be accessed under lock.
+	peers map[route.Vertex]*breakerState
+	lock  sync.Mutex
+}
+
+// newPeerBreaker creates a circuit breaker that trips after the number of
+// consecutive failures provided, resetting after the cool down.
+func newPeerBreaker(threshold int, coolDown time.Duration,
+	now func() time.Time) *peerBreaker {
+
+	return &peerBreaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       now,
+		peers:     make(map[route.Vertex]*breakerState),
+	}
+}
+
+// allow returns a boolean indicating whether we should accept onion messages
+// from the peer provided. Breakers that have been tripped for longer than our
+// cool down are reset.
+func (b *peerBreaker) allow(peer route.Vertex) bool {
+	if b.threshold == 0 {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	state, ok := b.peers[peer]
+	if !ok || state.trippedAt.IsZero() {
+		return true
+	}
+
+	if b.now().Sub(state.trippedAt) < b.coolDown {
+		return false
+	}
+
+	log.Infof("Resetting onion message breaker for peer: %v", peer)
+	delete(b.peers, peer)
+
+	return true
+}
+
+// record updates a peer's breaker with the outcome of handling an onion
+// message that it sent us. Malformed messages and failed forwards count
+// towards tripping the breaker, and successfully handled messages reset it.
+// Other failures (such as handler errors, policy drops and our forwarding
+// queue being full) are not a fault of the peer and are ignored.
+func (b *peerBreaker) record(peer route.Vertex, err error) {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if err == nil {
+		delete(b.peers, peer)
+		return
+	}
+
+	switch FailureKindOf(err) {
+	case FailureDecode, FailureBlinding, FailurePayload:
+
+	// A full forwarding queue is a matter of our own load, so we only
+	// count forwards that failed for other reasons.
+	case FailureForward:
+		if errors.Is(err, ErrForwardQueueFull) {
+			return
+		}
+
+	default:
+		return
+	}
+
+	state, ok := b.peers[peer]
+	if !ok {
+		state = &breakerState{}
+		b.peers[peer] = state
+	}
+
+	// If the breaker has already tripped, messages that were accepted
+	// before it tripped don't extend its cool down.
+	if !state.trippedAt.IsZero() {
+		return
+	}
+
+	state.failures++
+	if state.failures < b.threshold {
+		return
+	}
+
+	state.trippedAt = b.now()
+	log.Warnf("Onion message breaker tripped for peer: %v after %v "+
+		"failures, dropping messages for: %v", peer, state.failures,
+		b.coolDown)
+}
@@ -0,0 +1,120 @@
+package onionmsg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPeerBreaker tests tripping and resetting of our per-peer breaker.
+func TestPeerBreaker(t *testing.T) {
+	var (
+		peer     = route.Vertex{1}
+		coolDown = time.Minute
+
+		errDecode = newProcessingError(
+			FailureDecode, peer, errors.New("decode"),
+		)
+		errForward = newProcessingError(
+			FailureForward, peer, errors.New("forward"),
+		)
+		errFull = newProcessingError(
+			FailureForward, peer, ErrForwardQueueFull,
+		)
+		errHandler = newProcessingError(
+			FailureHandler, peer, errors.New("handler"),
+		)
+	)
+
+	tests := []struct {
+		name      string
+		threshold int
+
+		// outcomes is the set of results that we record for our
+		// peer, in order.
+		outcomes []error
+
+		// elapsed is the time that passes after our outcomes are
+		// recorded.
+		elapsed time.Duration
+
+		allowed bool
+	}{
+		{
+			name:      "below threshold",
+			threshold: 3,
+			outcomes:  []error{errDecode, errForward},
+			allowed:   true,
+		},
+		{
+			name:      "threshold reached",
+			threshold: 3,
+			outcomes:  []error{errDecode, errForward, errDecode},
+			allowed:   false,
+		},
+		{
+			name:      "handler errors ignored",
+			threshold: 2,
+			outcomes:  []error{errDecode, errHandler, errHandler},
+			allowed:   true,
+		},
+		{
+			name:      "queue full ignored",
+			threshold: 2,
+			outcomes:  []error{errDecode, errFull, errFull},
+			allowed:   true,
+		},
+		{
+			name:      "success resets failures",
+			threshold: 2,
+			outcomes:  []error{errDecode, nil, errDecode},
+			allowed:   true,
+		},
+		{
+			name:      "cooling down",
+			threshold: 1,
+			outcomes:  []error{errDecode},
+			elapsed:   coolDown / 2,
+			allowed:   false,
+		},
+		{
+			name:      "cool down passed",
+			threshold: 1,
+			outcomes:  []error{errDecode},
+			elapsed:   coolDown,
+			allowed:   true,
+		},
+		{
+			name:      "breaker disabled",
+			threshold: 0,
+			outcomes:  []error{errDecode, errDecode},
+			allowed:   true,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			now := time.Unix(1000, 0)
+			breaker := newPeerBreaker(
+				testCase.threshold, coolDown, func() time.Time {
+					return now
+				},
+			)
+
+			for _, outcome := range testCase.outcomes {
+				breaker.record(peer, outcome)
+			}
+
+			now = now.Add(testCase.elapsed)
+			require.Equal(t, testCase.allowed, breaker.allow(peer))
+
+			// Other peers should never be affected.
+			require.True(t, breaker.allow(route.Vertex{2}))
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package onionmsg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// connManager keeps connections open to the peers that we frequently send
+// direct-connect messages to, so that sends to them don't repeatedly pay the
+// latency of connecting and handshaking. Connections that we would otherwise
+// close after a send are held open once a peer has been sent the threshold
+// of messages within our window, and closed once we have not sent to the peer
+// for a full window.
+type connManager struct {
+	window    time.Duration
+	threshold int
+	maxPeers  int
+	now       func() time.Time
+
+	// sends holds the times of our recent direct sends to each peer, and
+	// kept is the set of peers that we are holding connections open to.
+	// Both must be accessed under lock.
+	sends map[route.Vertex][]time.Time
+	kept  map[route.Vertex]struct{}
+	lock  sync.Mutex
+}
+
+// newConnManager creates a connection manager that holds connections open
+// to at most max peers that have been sent threshold messages within the
+// window provided.
+func newConnManager(window time.Duration, threshold, maxPeers int,
+	now func() time.Time) *connManager {
+
+	return &connManager{
+		window:    window,
+		threshold: threshold,
+		maxPeers:  maxPeers,
+		now:       now,
+		sends:     make(map[route.Vertex][]time.Time),
+		kept:      make(map[route.Vertex]struct{}),
+	}
+}
+
+// recordSend records a direct send to a peer, returning a boolean indicating
+// whether we send to the peer frequently enough to keep its connection open.
+// This function is a no-op if the manager is nil.
+func (c *connManager) recordSend(peer route.Vertex) bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	sends := append(c.recentSends(peer, now), now)
+	c.sends[peer] = sends
+
+	return len(sends) >= c.threshold
+}
+
+// recentSends returns the sends to a peer that fall within our window. This
+// function must be called under lock.
+func (c *connManager) recentSends(peer route.Vertex,
+	now time.Time) []time.Time {
+
+	cutoff := now.Add(-c.window)
+
+	sends := c.sends[peer]
+	for len(sends) > 0 && !sends[0].After(cutoff) {
+		sends = sends[1:]
+	}
+
+	return sends
+}
+
+// keep adds a peer to the set of peers that we hold connections open to,
+// returning false if we are already holding the maximum number of
+// connections. This function is a no-op if the manager is nil.
+func (c *connManager) keep(peer route.Vertex) bool {
+	if c == nil {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.kept[peer]; ok {
+		return true
+	}
+
+	if len(c.kept) >= c.maxPeers {
+		return false
+	}
+
+	c.kept[peer] = struct{}{}
+
+	return true
+}
+
+// release removes the peers that we have not sent to within our window from
+// the set of peers that we hold connections open to, returning them so that
+// their connections can be closed. Send history that has fallen out of our
+// window is also pruned.
+func (c *connManager) release() []route.Vertex {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	for peer := range c.sends {
+		sends := c.recentSends(peer, now)
+		if len(sends) == 0 {
+			delete(c.sends, peer)
+			continue
+		}
+
+		c.sends[peer] = sends
+	}
+
+	var idle []route.Vertex
+	for peer := range c.kept {
+		if _, ok := c.sends[peer]; ok {
+			continue
+		}
+
+		delete(c.kept, peer)
+		idle = append(idle, peer)
+	}
+
+	return idle
+}
+
+// manageConnections periodically closes the connections that our connection
+// manager is holding open to peers that we no longer send to frequently.
+func (m *Messenger) manageConnections() {
+	defer m.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ticker := time.NewTicker(m.connManager.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.closeIdleConnections(ctx)
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// closeIdleConnections closes the connections that our connection manager
+// has released.
+func (m *Messenger) closeIdleConnections(ctx context.Context) {
+	for _, peer := range m.connManager.release() {
+		// Don't close connections that were made permanent by a
+		// later send.
+		if m.isPermanentPeer(peer) {
+			continue
+		}
+
+		pubkey, err := btcec.ParsePubKey(peer[:])
+		if err != nil {
+			log.Errorf("Idle peer: %v: %v", peer, err)
+			continue
+		}
+
+		log.Debugf("Closing idle connection to: %v", peer)
+		m.disconnect(ctx, pubkey)
+	}
+}
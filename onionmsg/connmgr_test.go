@@ -0,0 +1,149 @@
+package onionmsg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConnManager tests tracking of the peers that we send to frequently.
+func TestConnManager(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 2)
+		peer1   = route.NewVertex(pubkeys[0])
+		peer2   = route.NewVertex(pubkeys[1])
+
+		now = time.Unix(1000, 0)
+	)
+
+	conns := newConnManager(time.Minute, 2, 1, func() time.Time {
+		return now
+	})
+
+	// A single send does not make our peer frequent, but a second send
+	// within our window does.
+	require.False(t, conns.recordSend(peer1))
+	require.True(t, conns.recordSend(peer1))
+	require.True(t, conns.keep(peer1))
+
+	// Once our sends fall out of our window, our peer is no longer
+	// frequent.
+	now = now.Add(time.Minute)
+	require.False(t, conns.recordSend(peer1))
+
+	// We're already holding one connection, so we can't keep another
+	// peer, but we can keep our existing peer.
+	require.False(t, conns.recordSend(peer2))
+	require.True(t, conns.recordSend(peer2))
+	require.False(t, conns.keep(peer2))
+	require.True(t, conns.keep(peer1))
+
+	// Our peer still has a send within our window, so is not released.
+	require.Empty(t, conns.release())
+
+	// Once we haven't sent to our peer for a full window, it is released
+	// and all of our history is pruned.
+	now = now.Add(time.Minute)
+	require.Equal(t, []route.Vertex{peer1}, conns.release())
+	require.Empty(t, conns.sends)
+	require.Empty(t, conns.kept)
+	require.True(t, conns.keep(peer2))
+
+	// Our connection manager is optional, so a nil manager never keeps
+	// connections.
+	var nilConns *connManager
+	require.False(t, nilConns.recordSend(peer1))
+	require.False(t, nilConns.keep(peer1))
+}
+
+// TestConnectionManager tests that the messenger holds transient connections
+// open to peers that it sends to frequently, and closes them once they are
+// idle.
+func TestConnectionManager(t *testing.T) {
+	var (
+		privkeys    = testutils.GetPrivkeys(t, 1)
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
+
+		peer     = testutils.GetPubkeys(t, 1)[0]
+		vertex   = route.NewVertex(peer)
+		nodeAddr = "host:port"
+		peerList = []lndclient.Peer{
+			{
+				Pubkey: vertex,
+			},
+		}
+
+		nodeInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Addresses: []string{
+					nodeAddr,
+				},
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+
+		ctxb = context.Background()
+		now  = time.Unix(1000, 0)
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	messenger, err := NewOnionMessenger(
+		lnd, nodeKeyECDH, nil,
+		OptionConnectionManager(time.Minute, 2, 1),
+	)
+	require.NoError(t, err, "new messenger")
+
+	messenger.connManager.now = func() time.Time {
+		return now
+	}
+
+	mockConnect := func() {
+		testutils.MockQueryRoutes(
+			lnd.Mock, queryRoutesRequest(peer),
+			&lndclient.QueryRoutesResponse{}, nil,
+		)
+		testutils.MockListPeers(lnd.Mock, nil, nil)
+		testutils.MockGetNodeInfo(lnd.Mock, vertex, false, nodeInfo, nil)
+		mockPollPeers(lnd.Mock)
+		testutils.MockConnect(lnd.Mock, vertex, nodeAddr, false, nil)
+		testutils.MockListPeers(lnd.Mock, peerList, nil)
+		testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	}
+
+	req := NewSendMessageRequest(peer, nil, nil, nil, true)
+	req.DisconnectAfterSend = true
+
+	// Our first send does not make our peer frequent, so we disconnect
+	// after sending.
+	mockConnect()
+	testutils.MockDisconnect(lnd.Mock, vertex, nil)
+	require.NoError(t, messenger.SendMessage(ctxb, req))
+
+	// Our second send within our window makes our peer frequent, so we
+	// hold the connection open.
+	mockConnect()
+	require.NoError(t, messenger.SendMessage(ctxb, req))
+
+	// We still have a recent send to our peer, so we don't close our
+	// connection.
+	messenger.closeIdleConnections(ctxb)
+
+	// Once our peer has been idle for a full window, we disconnect.
+	now = now.Add(time.Minute)
+	testutils.MockDisconnect(lnd.Mock, vertex, nil)
+	messenger.closeIdleConnections(ctxb)
+}
@@ -0,0 +1,80 @@
+package onionmsg
+
+import (
+	"context"
+	"time"
+)
+
+// drainTimeoutDefault is the default maximum amount of time that we wait for
+// in-flight work to complete when the messenger is stopped.
+const drainTimeoutDefault = time.Second * 10
+
+// trackSend registers an in-flight send so that it is drained when we stop,
+// failing with ErrShuttingDown if we are no longer accepting new sends. The
+// context returned is cancelled if we tear down before the send completes,
+// and the done function returned must be called once it has completed.
+func (m *Messenger) trackSend(ctx context.Context) (context.Context, func(),
+	error) {
+
+	m.drainLock.RLock()
+	defer m.drainLock.RUnlock()
+
+	select {
+	case <-m.draining:
+		return nil, nil, ErrShuttingDown
+
+	default:
+	}
+
+	m.sendWg.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-m.quit:
+			cancel()
+
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		cancel()
+		m.sendWg.Done()
+	}, nil
+}
+
+// startDrain signals that we should stop accepting new work. Any sends that
+// are already in-flight will continue to be tracked.
+func (m *Messenger) startDrain() {
+	m.drainLock.Lock()
+	defer m.drainLock.Unlock()
+
+	close(m.draining)
+}
+
+// drain waits for our in-flight handler invocations, forwards and sends to
+// complete, up to our drain timeout. Our handler workers are waited on first,
+// because they may queue messages for forwarding, and our forwarding goroutine
+// exits once its queue is empty and our workers have exited.
+func (m *Messenger) drain() {
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+
+		m.handlerWg.Wait()
+		close(m.handlersDrained)
+
+		<-m.forwardsDrained
+		m.sendWg.Wait()
+	}()
+
+	select {
+	case <-drained:
+		log.Info("Onion messenger drained in-flight messages")
+
+	case <-time.After(m.drainTimeout):
+		log.Warnf("Onion messenger not drained after: %v, tearing "+
+			"down", m.drainTimeout)
+	}
+}
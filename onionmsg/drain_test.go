@@ -0,0 +1,157 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStopDrain tests that stopping the messenger stops accepting new work,
+// but completes the handling of messages that we've already accepted until
+// our drain timeout.
+func TestStopDrain(t *testing.T) {
+	tests := []struct {
+		name         string
+		drainTimeout time.Duration
+		release      bool
+		handled      int
+	}{
+		{
+			name:         "in-flight messages drained",
+			drainTimeout: defaultTimeout,
+			release:      true,
+			handled:      2,
+		},
+		{
+			name:         "drain timeout",
+			drainTimeout: time.Millisecond * 50,
+			handled:      0,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			testStopDrain(
+				t, testCase.drainTimeout, testCase.release,
+				testCase.handled,
+			)
+		})
+	}
+}
+
+// testStopDrain queues two messages behind a blocking handler, stops the
+// messenger and asserts that the number of messages expected are handled
+// before it has stopped.
+func testStopDrain(t *testing.T, drainTimeout time.Duration, release bool,
+	expectedHandled int) {
+
+	var (
+		privkey          = testutils.GetPrivkeys(t, 1)[0]
+		tlvType tlv.Type = 100
+
+		entered  = make(chan struct{}, 2)
+		released = make(chan struct{})
+		handled  = make(chan struct{}, 2)
+
+		msgChan = make(chan lndclient.CustomMessage)
+		errChan = make(chan error)
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	testutils.MockSubscribeCustomMessages(
+		lnd.Mock, msgChan, errChan, nil,
+	)
+
+	// Use a single handler worker so that our second message is queued
+	// behind the first.
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkey}, nil,
+		OptionHandlerWorkers(1), OptionDrainTimeout(drainTimeout),
+	)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, messenger.Start(), "start messenger")
+
+	// Our handler blocks until we release it, or until the messenger is
+	// torn down.
+	handler := func(*lnwire.ReplyPath, []byte, []byte) error {
+		entered <- struct{}{}
+
+		select {
+		case <-released:
+			handled <- struct{}{}
+
+		case <-messenger.quit:
+		}
+
+		return nil
+	}
+
+	_, err = messenger.RegisterHandler(tlvType, handler)
+	require.NoError(t, err, "register handler")
+
+	sendMsg(t, msgChan, onionToSelf(t, privkey, tlvType, []byte{1}))
+	sendMsg(t, msgChan, onionToSelf(t, privkey, tlvType, []byte{2}))
+
+	select {
+	case <-entered:
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not handled")
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- messenger.Stop()
+	}()
+
+	// Once we've started draining, we should not accept new sends.
+	require.Eventually(t, func() bool {
+		select {
+		case <-messenger.draining:
+			return true
+
+		default:
+			return false
+		}
+	}, defaultTimeout, time.Millisecond*10)
+
+	req := NewSendMessageRequest(
+		testutils.GetPubkeys(t, 1)[0], nil, nil, nil, false,
+	)
+	err = messenger.SendMessage(context.Background(), req)
+	require.True(t, errors.Is(err, ErrShuttingDown))
+
+	// We should not finish stopping while our message is in-flight.
+	if release {
+		select {
+		case <-stopped:
+			t.Fatal("stopped before drain")
+
+		case <-time.After(time.Millisecond * 50):
+		}
+
+		released <- struct{}{}
+		released <- struct{}{}
+	}
+
+	select {
+	case err := <-stopped:
+		require.NoError(t, err, "stop messenger")
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("messenger not stopped")
+	}
+
+	require.Len(t, handled, expectedHandled)
+}
@@ -0,0 +1,156 @@
+package onionmsg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// FailureKind classifies the cause of a failure to process an incoming onion
+// message.
+type FailureKind uint8
+
+const (
+	// FailureUnknown is used for errors that have not been classified.
+	FailureUnknown FailureKind = iota
+
+	// FailureDecode indicates that the onion message, its onion packet or
+	// its payload could not be decoded.
+	FailureDecode
+
+	// FailureBlinding indicates that the onion packet or its encrypted
+	// data could not be decrypted using the message's blinding point.
+	FailureBlinding
+
+	// FailureReplay indicates that the onion message has already been
+	// processed by our node.
+	FailureReplay
+
+	// FailurePolicyDrop indicates that the onion message was valid, but
+	// was dropped by our policy (eg, interceptors or path ID checks).
+	FailurePolicyDrop
+
+	// FailureForward indicates that we could not forward the onion
+	// message to the next node in its route.
+	FailureForward
+
+	// FailureHandler indicates that a handler for one of the message's
+	// final hop payloads failed.
+	FailureHandler
+
+	// FailurePayload indicates that the onion message's onion was valid,
+	// but the payload that it carried for our node could not be parsed.
+	FailurePayload
+)
+
+// String returns the string representation of a failure kind.
+func (f FailureKind) String() string {
+	switch f {
+	case FailureUnknown:
+		return "unknown"
+
+	case FailureDecode:
+		return "decode failure"
+
+	case FailureBlinding:
+		return "bad blinding"
+
+	case FailureReplay:
+		return "replay"
+
+	case FailurePolicyDrop:
+		return "policy drop"
+
+	case FailureForward:
+		return "forward failure"
+
+	case FailureHandler:
+		return "handler failure"
+
+	case FailurePayload:
+		return "unparseable payload"
+
+	default:
+		return fmt.Sprintf("unknown failure kind: %d", f)
+	}
+}
+
+// ProcessingError is returned when we fail to process an incoming onion
+// message. It classifies the failure and records the context in which it
+// occurred, wrapping the underlying error so that our sentinel errors can
+// still be matched with errors.Is.
+type ProcessingError struct {
+	// Kind is the class of failure that occurred.
+	Kind FailureKind
+
+	// Peer is the peer that delivered the onion message to us.
+	Peer route.Vertex
+
+	// TLVType is the final hop tlv type that the failure relates to, if
+	// any.
+	TLVType *tlv.Type
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the string representation of a processing error. The peer is
+// not included, because callers that log our errors already include it.
+func (e *ProcessingError) Error() string {
+	if e.TLVType != nil {
+		return fmt.Sprintf("%v (tlv: %v): %v", e.Kind, *e.TLVType,
+			e.Err)
+	}
+
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProcessingError) Unwrap() error {
+	return e.Err
+}
+
+// newProcessingError classifies an error that occurred while processing a
+// message from the peer provided. If the error has already been classified
+// (for example, by processing of a dummy hop that we forwarded to ourselves),
+// it is returned unchanged so that the innermost classification is kept.
+func newProcessingError(kind FailureKind, peer route.Vertex,
+	err error) error {
+
+	var procErr *ProcessingError
+	if errors.As(err, &procErr) {
+		return err
+	}
+
+	return &ProcessingError{
+		Kind: kind,
+		Peer: peer,
+		Err:  err,
+	}
+}
+
+// newTLVProcessingError classifies an error that occurred while processing
+// the final hop tlv type provided.
+func newTLVProcessingError(kind FailureKind, peer route.Vertex,
+	tlvType tlv.Type, err error) error {
+
+	return &ProcessingError{
+		Kind:    kind,
+		Peer:    peer,
+		TLVType: &tlvType,
+		Err:     err,
+	}
+}
+
+// FailureKindOf returns the kind of failure that caused the error provided,
+// or FailureUnknown if it is not a processing error.
+func FailureKindOf(err error) FailureKind {
+	var procErr *ProcessingError
+	if errors.As(err, &procErr) {
+		return procErr.Kind
+	}
+
+	return FailureUnknown
+}
@@ -0,0 +1,90 @@
+package onionmsg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProcessingError tests classification of onion message processing
+// errors.
+func TestProcessingError(t *testing.T) {
+	var (
+		peer    = route.Vertex{1}
+		tlvType = tlv.Type(101)
+		mockErr = errors.New("mock err")
+
+		decodeErr  = newProcessingError(FailureDecode, peer, mockErr)
+		handlerErr = newTLVProcessingError(
+			FailureHandler, peer, tlvType, mockErr,
+		)
+		forwardWrap = fmt.Errorf("forward: %w", decodeErr)
+	)
+
+	tests := []struct {
+		name    string
+		err     error
+		kind    FailureKind
+		tlvType *tlv.Type
+	}{
+		{
+			name: "unclassified error",
+			err:  mockErr,
+			kind: FailureUnknown,
+		},
+		{
+			name: "nil error",
+			kind: FailureUnknown,
+		},
+		{
+			name: "classified error",
+			err:  decodeErr,
+			kind: FailureDecode,
+		},
+		{
+			name:    "tlv error",
+			err:     handlerErr,
+			kind:    FailureHandler,
+			tlvType: &tlvType,
+		},
+		{
+			// Errors that are already classified keep their
+			// original kind when they're reclassified, so that
+			// dummy hop failures are not reported as forwards.
+			name: "reclassified error",
+			err: newProcessingError(
+				FailureForward, peer, forwardWrap,
+			),
+			kind: FailureDecode,
+		},
+		{
+			name:    "joined errors",
+			err:     errors.Join(handlerErr, decodeErr),
+			kind:    FailureHandler,
+			tlvType: &tlvType,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			kind := FailureKindOf(testCase.err)
+			require.Equal(t, testCase.kind, kind)
+
+			var procErr *ProcessingError
+			if !errors.As(testCase.err, &procErr) {
+				return
+			}
+
+			// Our underlying error should still be matched.
+			require.True(t, errors.Is(testCase.err, mockErr))
+			require.Equal(t, peer, procErr.Peer)
+			require.Equal(t, testCase.tlvType, procErr.TLVType)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package onionmsg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// graphCacheMaxSize is the number of entries that we hold in each of our
+// graph caches before we prune expired entries. If a cache is still full
+// after pruning, we don't cache new results until entries expire.
+const graphCacheMaxSize = 1000
+
+// nodeKey identifies a cached node lookup.
+type nodeKey struct {
+	node            route.Vertex
+	includeChannels bool
+}
+
+// cachedNode is a cached node lookup.
+type cachedNode struct {
+	info    *lndclient.NodeInfo
+	expires time.Time
+}
+
+// routeKey identifies a cached route query. We only cache queries that set
+// a destination and optional last hop, since these are the only fields that
+// we use to find onion message paths.
+type routeKey struct {
+	dest    route.Vertex
+	lastHop route.Vertex
+}
+
+// cachedRoute is a cached route query, which may be a cached failure to find
+// a route.
+type cachedRoute struct {
+	resp    *lndclient.QueryRoutesResponse
+	err     error
+	expires time.Time
+}
+
+// graphCache wraps our graph and caches the results of graph lookups and
+// route queries for a short period, so that sending many messages to the same
+// destination does not repeatedly query the graph.
+// Cached responses are shared between callers, and must not be modified.
+type graphCache struct {
+	Graph
+
+	ttl time.Duration
+	now func() time.Time
+
+	// nodes and routes hold our cached lookups, and must be accessed
+	// under lock.
+	nodes  map[nodeKey]*cachedNode
+	routes map[routeKey]*cachedRoute
+	lock   sync.Mutex
+}
+
+// newGraphCache creates a cache that holds the results of lookups made via
+// the graph provided for the ttl provided.
+func newGraphCache(graph Graph, ttl time.Duration,
+	now func() time.Time) *graphCache {
+
+	return &graphCache{
+		Graph:  graph,
+		ttl:    ttl,
+		now:    now,
+		nodes:  make(map[nodeKey]*cachedNode),
+		routes: make(map[routeKey]*cachedRoute),
+	}
+}
+
+// GetNodeInfo looks up a node in the graph, returning a cached result if we
+// have recently looked it up. Only successful lookups are cached.
+func (g *graphCache) GetNodeInfo(ctx context.Context, pubkey route.Vertex,
+	includeChannels bool) (*lndclient.NodeInfo, error) {
+
+	key := nodeKey{
+		node:            pubkey,
+		includeChannels: includeChannels,
+	}
+
+	g.lock.Lock()
+	cached, ok := g.nodes[key]
+	g.lock.Unlock()
+
+	if ok && g.now().Before(cached.expires) {
+		return cached.info, nil
+	}
+
+	info, err := g.Graph.GetNodeInfo(ctx, pubkey, includeChannels)
+	if err != nil {
+		return nil, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if len(g.nodes) >= graphCacheMaxSize {
+		g.prune()
+	}
+
+	if len(g.nodes) < graphCacheMaxSize {
+		g.nodes[key] = &cachedNode{
+			info:    info,
+			expires: g.now().Add(g.ttl),
+		}
+	}
+
+	return info, nil
+}
+
+// QueryRoutes queries lnd for a route, returning a cached result if we have
+// recently queried for the same route. Successful queries and queries that
+// did not find a route are cached, and queries that set fields other than the
+// destination and last hop are not.
+func (g *graphCache) QueryRoutes(ctx context.Context,
+	req lndclient.QueryRoutesRequest) (*lndclient.QueryRoutesResponse,
+	error) {
+
+	if req.Source != nil || req.MaxCltv != nil || len(req.RouteHints) != 0 {
+		return g.Graph.QueryRoutes(ctx, req)
+	}
+
+	key := routeKey{
+		dest: req.PubKey,
+	}
+	if req.LastHop != nil {
+		key.lastHop = *req.LastHop
+	}
+
+	g.lock.Lock()
+	cached, ok := g.routes[key]
+	g.lock.Unlock()
+
+	if ok && g.now().Before(cached.expires) {
+		return cached.resp, cached.err
+	}
+
+	resp, err := g.Graph.QueryRoutes(ctx, req)
+	if err != nil && err != lndclient.ErrNoRouteFound {
+		return nil, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if len(g.routes) >= graphCacheMaxSize {
+		g.prune()
+	}
+
+	if len(g.routes) < graphCacheMaxSize {
+		g.routes[key] = &cachedRoute{
+			resp:    resp,
+			err:     err,
+			expires: g.now().Add(g.ttl),
+		}
+	}
+
+	return resp, err
+}
+
+// invalidate removes all cached lookups for a node, and all cached routes
+// that are to or via the node.
+func (g *graphCache) invalidate(node route.Vertex) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for key := range g.nodes {
+		if key.node == node {
+			delete(g.nodes, key)
+		}
+	}
+
+	for key, cached := range g.routes {
+		if key.dest == node || key.lastHop == node ||
+			routeUsesNode(cached.resp, node) {
+
+			delete(g.routes, key)
+		}
+	}
+}
+
+// flush removes all of our cached lookups.
+func (g *graphCache) flush() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.nodes = make(map[nodeKey]*cachedNode)
+	g.routes = make(map[routeKey]*cachedRoute)
+}
+
+// prune removes expired entries from our caches. This function must be
+// called under lock.
+func (g *graphCache) prune() {
+	now := g.now()
+
+	for key, cached := range g.nodes {
+		if !now.Before(cached.expires) {
+			delete(g.nodes, key)
+		}
+	}
+
+	for key, cached := range g.routes {
+		if !now.Before(cached.expires) {
+			delete(g.routes, key)
+		}
+	}
+}
+
+// routeUsesNode returns a boolean indicating whether a route includes the
+// node provided as one of its hops.
+func routeUsesNode(resp *lndclient.QueryRoutesResponse,
+	node route.Vertex) bool {
+
+	if resp == nil {
+		return false
+	}
+
+	for _, hop := range resp.Hops {
+		if hop.PubKey != nil && *hop.PubKey == node {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InvalidateGraphCache removes any cached graph lookups and routes for the
+// node provided, or all cached lookups if no node is provided. This can be
+// used to force fresh lookups when we learn of changes to the graph. It has
+// no effect if graph caching is not enabled.
+func (m *Messenger) InvalidateGraphCache(node *route.Vertex) {
+	if m.graphCache == nil {
+		return
+	}
+
+	if node == nil {
+		m.graphCache.flush()
+		return
+	}
+
+	m.graphCache.invalidate(*node)
+}
@@ -0,0 +1,134 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGraphCacheNodes tests caching and expiry of node lookups.
+func TestGraphCacheNodes(t *testing.T) {
+	var (
+		node    = route.Vertex{1}
+		info    = &lndclient.NodeInfo{ChannelCount: 1}
+		mockErr = errors.New("mock err")
+		ctxb    = context.Background()
+		now     = time.Unix(1000, 0)
+		ttl     = time.Minute
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	cache := newGraphCache(lnd, ttl, func() time.Time {
+		return now
+	})
+
+	// Failed lookups should not be cached.
+	testutils.MockGetNodeInfo(lnd.Mock, node, false, nil, mockErr)
+	_, err := cache.GetNodeInfo(ctxb, node, false)
+	require.True(t, errors.Is(err, mockErr))
+
+	// Our first successful lookup should go to lnd, and our second should
+	// be served from our cache.
+	testutils.MockGetNodeInfo(lnd.Mock, node, false, info, nil)
+	for i := 0; i < 2; i++ {
+		resp, err := cache.GetNodeInfo(ctxb, node, false)
+		require.NoError(t, err)
+		require.Equal(t, info, resp)
+	}
+
+	// Lookups that include channels are cached separately.
+	testutils.MockGetNodeInfo(lnd.Mock, node, true, info, nil)
+	_, err = cache.GetNodeInfo(ctxb, node, true)
+	require.NoError(t, err)
+
+	// Once our entry has expired, we should query lnd again.
+	now = now.Add(ttl)
+	testutils.MockGetNodeInfo(lnd.Mock, node, false, info, nil)
+	_, err = cache.GetNodeInfo(ctxb, node, false)
+	require.NoError(t, err)
+
+	// Once invalidated, we should query lnd again.
+	cache.invalidate(node)
+	testutils.MockGetNodeInfo(lnd.Mock, node, false, info, nil)
+	_, err = cache.GetNodeInfo(ctxb, node, false)
+	require.NoError(t, err)
+}
+
+// TestGraphCacheRoutes tests caching and invalidation of route queries.
+func TestGraphCacheRoutes(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 3)
+		dest    = route.NewVertex(pubkeys[0])
+		relay   = route.NewVertex(pubkeys[1])
+		other   = route.NewVertex(pubkeys[2])
+		ctxb    = context.Background()
+		now     = time.Unix(1000, 0)
+
+		resp = &lndclient.QueryRoutesResponse{
+			Hops: []*lndclient.Hop{
+				{
+					PubKey: &relay,
+				},
+				{
+					PubKey: &dest,
+				},
+			},
+		}
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	cache := newGraphCache(lnd, time.Minute, func() time.Time {
+		return now
+	})
+
+	// Successful queries should be cached.
+	req := queryRoutesRequest(pubkeys[0])
+	testutils.MockQueryRoutes(lnd.Mock, req, resp, nil)
+	for i := 0; i < 2; i++ {
+		routes, err := cache.QueryRoutes(ctxb, req)
+		require.NoError(t, err)
+		require.Equal(t, resp, routes)
+	}
+
+	// Queries that don't find a route should also be cached.
+	noRoute := queryRoutesRequest(pubkeys[2])
+	testutils.MockQueryRoutes(
+		lnd.Mock, noRoute, &lndclient.QueryRoutesResponse{},
+		lndclient.ErrNoRouteFound,
+	)
+	for i := 0; i < 2; i++ {
+		_, err := cache.QueryRoutes(ctxb, noRoute)
+		require.Equal(t, lndclient.ErrNoRouteFound, err)
+	}
+
+	// Queries that set fields that we don't cache on should always go
+	// to lnd.
+	var maxCltv uint32 = 10
+	uncached := queryRoutesRequest(pubkeys[0])
+	uncached.MaxCltv = &maxCltv
+	for i := 0; i < 2; i++ {
+		testutils.MockQueryRoutes(lnd.Mock, uncached, resp, nil)
+		_, err := cache.QueryRoutes(ctxb, uncached)
+		require.NoError(t, err)
+	}
+
+	// Invalidating a node that our route relays through should remove
+	// it from our cache, but leave unrelated routes.
+	cache.invalidate(relay)
+	require.NotContains(t, cache.routes, routeKey{dest: dest})
+	require.Contains(t, cache.routes, routeKey{dest: other})
+
+	// Flushing our cache should remove all routes.
+	cache.flush()
+	require.Empty(t, cache.routes)
+}
@@ -0,0 +1,103 @@
+package onionmsg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// ErrIntercepted is returned when an interceptor rejects an incoming onion
+// message.
+var ErrIntercepted = errors.New("onion message rejected by interceptor")
+
+// IncomingInterceptor is the function signature for interceptors that are
+// called for each incoming onion message before it is decrypted. Returning a
+// non-nil error drops the message.
+type IncomingInterceptor func(msg lndclient.CustomMessage) error
+
+// DispatchInterceptor is the function signature for interceptors that are
+// called for each onion message addressed to our node, before it is delivered
+// as a reply or dispatched to our handlers. It is provided with the peer that
+// delivered the message and its decoded payload. Returning a non-nil error
+// drops the message.
+type DispatchInterceptor func(peer route.Vertex,
+	payload *lnwire.OnionMessagePayload) error
+
+// OptionIncomingInterceptors adds interceptors that are called for incoming
+// onion messages before they are decrypted. Interceptors are called in the
+// order that they are provided, after any that have already been added, and
+// the first interceptor to fail drops the message.
+func OptionIncomingInterceptors(
+	interceptors ...IncomingInterceptor) MessengerOption {
+
+	return func(m *Messenger) error {
+		for i, interceptor := range interceptors {
+			if interceptor == nil {
+				return fmt.Errorf("%w: incoming interceptor "+
+					"%v is nil", ErrInvalidOption, i)
+			}
+		}
+
+		m.incomingInterceptors = append(
+			m.incomingInterceptors, interceptors...,
+		)
+
+		return nil
+	}
+}
+
+// OptionDispatchInterceptors adds interceptors that are called for onion
+// messages addressed to our node before they are dispatched. Interceptors
+// are called in the order that they are provided, after any that have already
+// been added, and the first interceptor to fail drops the message.
+func OptionDispatchInterceptors(
+	interceptors ...DispatchInterceptor) MessengerOption {
+
+	return func(m *Messenger) error {
+		for i, interceptor := range interceptors {
+			if interceptor == nil {
+				return fmt.Errorf("%w: dispatch interceptor "+
+					"%v is nil", ErrInvalidOption, i)
+			}
+		}
+
+		m.dispatchInterceptors = append(
+			m.dispatchInterceptors, interceptors...,
+		)
+
+		return nil
+	}
+}
+
+// interceptIncoming runs an incoming message through our chain of incoming
+// interceptors, failing with ErrIntercepted if any of them reject it.
+func interceptIncoming(interceptors []IncomingInterceptor,
+	msg lndclient.CustomMessage) error {
+
+	for i, interceptor := range interceptors {
+		if err := interceptor(msg); err != nil {
+			return fmt.Errorf("%w: incoming interceptor %v: %v",
+				ErrIntercepted, i, err)
+		}
+	}
+
+	return nil
+}
+
+// interceptDispatch runs a message addressed to our node through our chain of
+// dispatch interceptors, failing with ErrIntercepted if any of them reject it.
+func interceptDispatch(interceptors []DispatchInterceptor, peer route.Vertex,
+	payload *lnwire.OnionMessagePayload) error {
+
+	for i, interceptor := range interceptors {
+		if err := interceptor(peer, payload); err != nil {
+			return fmt.Errorf("%w: dispatch interceptor %v: %v",
+				ErrIntercepted, i, err)
+		}
+	}
+
+	return nil
+}
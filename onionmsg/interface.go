@@ -2,10 +2,12 @@ package onionmsg
 
 import (
 	"context"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/lightninglabs/lndclient"
 	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/lightningnetwork/lnd/tlv"
 )
@@ -13,29 +15,54 @@ import (
 // LndOnionMsg is an interface describing the lnd dependencies that the onionmsg
 // package required.
 type LndOnionMsg interface {
+	Transport
+	Graph
+
+	// GetInfo returns information about the lnd node.
+	GetInfo(ctx context.Context) (*lndclient.Info, error)
+}
+
+// Transport is an interface describing the peer to peer connectivity that the
+// messenger uses to exchange onion messages with its peers. It is implemented
+// by lndclient, and may be implemented by other daemons that embed the
+// messenger.
+type Transport interface {
 	// SendCustomMessage sends a custom message to a peer.
 	SendCustomMessage(ctx context.Context, msg lndclient.CustomMessage) error
 
-	// SubscribeCustomMessages subscribes to custom messages received by
-	// lnd.
+	// SubscribeCustomMessages subscribes to custom messages received from
+	// our peers.
 	SubscribeCustomMessages(ctx context.Context) (
 		<-chan lndclient.CustomMessage, <-chan error, error)
 
-	// GetNodeInfo looks up a node in the public ln graph.
-	GetNodeInfo(ctx context.Context, pubkey route.Vertex,
-		includeChannels bool) (*lndclient.NodeInfo, error)
-
-	// ListPeers returns lnd's current set of peers.
+	// ListPeers returns our current set of peers.
 	ListPeers(ctx context.Context) ([]lndclient.Peer, error)
 
 	// Connect makes a connection to the peer provided.
 	Connect(ctx context.Context, peer route.Vertex, host string,
 		permanent bool) error
 
-	// GetInfo returns information about the lnd node.
-	GetInfo(ctx context.Context) (*lndclient.Info, error)
+	// Disconnect disconnects from the peer provided.
+	Disconnect(ctx context.Context, peer route.Vertex) error
 
-	// QueryRoutes queries lnd for a route to a destination peer.
+	// SubscribePeerEvents subscribes to peer online and offline events.
+	SubscribePeerEvents(ctx context.Context) (<-chan *lnrpc.PeerEvent,
+		<-chan error, error)
+}
+
+// Graph is an interface describing the channel graph lookups that the
+// messenger uses to find nodes and paths to them. It is implemented by
+// lndclient.
+type Graph interface {
+	// GetNodeInfo looks up a node in the public ln graph.
+	GetNodeInfo(ctx context.Context, pubkey route.Vertex,
+		includeChannels bool) (*lndclient.NodeInfo, error)
+
+	// GetChanInfo looks up a channel in the public ln graph.
+	GetChanInfo(ctx context.Context, chanID uint64) (
+		*lndclient.ChannelEdge, error)
+
+	// QueryRoutes queries for a route to a destination peer.
 	QueryRoutes(ctx context.Context, req lndclient.QueryRoutesRequest) (
 		*lndclient.QueryRoutesResponse, error)
 }
@@ -51,6 +78,78 @@ type LndOnionSigner interface {
 		keyLocator *keychain.KeyLocator) ([32]byte, error)
 }
 
+// LndMessageSigner is an interface describing the lnd dependencies required
+// to sign messages with our node key.
+type LndMessageSigner interface {
+	// SignMessage signs a message with the key specified in the key
+	// locator.
+	SignMessage(ctx context.Context, msg []byte,
+		locator keychain.KeyLocator,
+		opts ...lndclient.SignMessageOption) ([]byte, error)
+}
+
+// PayloadSigner is an interface implemented by signers that authenticate
+// the final hop payloads of the onion messages that we send.
+type PayloadSigner interface {
+	// PubKey returns the public key that payloads are signed with.
+	PubKey() *btcec.PublicKey
+
+	// Sign produces a bip340 signature over the tagged hash of the
+	// message provided, using the tag provided.
+	Sign(ctx context.Context, tag, msg []byte) ([]byte, error)
+}
+
+// PathFinder is an interface implemented by strategies that select the path
+// used to relay onion messages to a target node.
+type PathFinder interface {
+	// FindPath returns a path from our node to the target provided,
+	// ending with the target, that does not relay via any of the nodes
+	// to avoid. A nil path is returned if no suitable path is found.
+	FindPath(ctx context.Context, target *btcec.PublicKey,
+		avoid []*btcec.PublicKey) ([]*btcec.PublicKey, error)
+}
+
+// SessionKeySource is an interface implemented by objects that provide the
+// ephemeral keys used to construct the onions for the messages that we send.
+type SessionKeySource interface {
+	// SessionKeys returns the session key used to construct the onion
+	// for a message sent along the path provided, and the blinding key
+	// used to blind the route that it is sent along.
+	SessionKeys(req *SendMessageRequest, path []*btcec.PublicKey) (
+		*btcec.PrivateKey, *btcec.PrivateKey, error)
+}
+
+// MetricsCollector is an interface implemented by integrators that want to
+// record telemetry for the messenger. Implementations are called from the
+// messenger's processing goroutines, so they must be safe for concurrent use
+// and should not block.
+type MetricsCollector interface {
+	// MessageSent is called when we send an onion message that we
+	// created to the first hop in its route, with the outcome of the
+	// send.
+	MessageSent(peer route.Vertex, err error)
+
+	// MessageReceived is called when a peer sends us an onion message.
+	MessageReceived(peer route.Vertex)
+
+	// MessageForwarded is called when we relay an onion message to a
+	// peer on behalf of others, with the outcome of the send.
+	MessageForwarded(peer route.Vertex, err error)
+
+	// MessageDropped is called when we drop an onion message that a peer
+	// sent us.
+	MessageDropped(peer route.Vertex, reason DropReason)
+
+	// HandlerLatency is called each time a handler for final hop
+	// payloads of the tlv type provided returns, with the time it took
+	// and its error.
+	HandlerLatency(tlvType tlv.Type, latency time.Duration, err error)
+
+	// QueueDepth is called when we add a message to our inbound or
+	// forwarding queues, with the number of messages in each queue.
+	QueueDepth(inbound, forward int)
+}
+
 // OnionMessenger is an interface implemented by objects that can send and
 // receive onion messages.
 type OnionMessenger interface {
@@ -64,13 +163,71 @@ type OnionMessenger interface {
 	// optional TLVs for the target peer can be included in final payloads.
 	SendMessage(ctx context.Context, req *SendMessageRequest) error
 
+	// SendMessageWithReply sends an onion message including a reply path
+	// to our node, and blocks until a reply is received over the path or
+	// the context provided is cancelled. The reply path contains the
+	// number of hops and dummy hops provided.
+	SendMessageWithReply(ctx context.Context, req *SendMessageRequest,
+		hops, dummyHops uint8) (*Reply, error)
+
+	// NewPathID creates a path ID for inclusion in a blinded path to our
+	// node. Messages received over blinded paths that contain path IDs
+	// that were not created by our node are rejected.
+	NewPathID() ([]byte, error)
+
 	// RegisterHandler adds a handler onion message payloads delivered to
-	// our node for the tlv type provided.
-	// Note: this function will fail if the messenger has not been started.
-	RegisterHandler(tlvType tlv.Type, handler OnionMessageHandler) error
-
-	// DeregisterHandler removes a handler for onion message payloads for
-	// the tlv type provided.
-	// Note: this function will fail if the messenger has not been started.
-	DeregisterHandler(tlvType tlv.Type) error
+	// our node for the tlv type provided, returning an ID that identifies
+	// the handler. Multiple handlers may be registered for a single tlv
+	// type.
+	// Handlers may be (de)registered before the messenger is started, and
+	// take effect once it starts.
+	RegisterHandler(tlvType tlv.Type, handler OnionMessageHandler) (
+		HandlerID, error)
+
+	// RegisterReplyingHandler adds a handler for onion message payloads
+	// delivered to our node for the tlv type provided, which receives
+	// each payload with a helper to reply to the message that carried
+	// it. The ID returned identifies the handler for deregistration.
+	RegisterReplyingHandler(tlvType tlv.Type, handler ReplyingHandler) (
+		HandlerID, error)
+
+	// DeregisterHandler removes the handler with the ID provided for onion
+	// message payloads for the tlv type provided.
+	// Handlers may be (de)registered before the messenger is started, and
+	// take effect once it starts.
+	DeregisterHandler(tlvType tlv.Type, id HandlerID) error
+
+	// RegisterWildcardHandler adds a catch-all handler that receives all
+	// onion message payloads delivered to our node, regardless of tlv
+	// type.
+	// Handlers may be (de)registered before the messenger is started, and
+	// take effect once it starts.
+	RegisterWildcardHandler(handler WildcardHandler) error
+
+	// DeregisterWildcardHandler removes our catch-all handler.
+	// Handlers may be (de)registered before the messenger is started, and
+	// take effect once it starts.
+	DeregisterWildcardHandler() error
+
+	// RegisterHandlerContext adds a handler for onion message payloads
+	// for the tlv type provided that is automatically deregistered when
+	// the context provided is cancelled.
+	RegisterHandlerContext(ctx context.Context, tlvType tlv.Type,
+		handler OnionMessageHandler) (HandlerID, error)
+
+	// DeregisterAll removes all of the handlers that have been registered
+	// with the messenger, including our catch-all handler.
+	DeregisterAll() error
+
+	// PeerStats returns counters for the onion messages that we have
+	// received from, forwarded to and dropped for each of our peers.
+	PeerStats() map[route.Vertex]PeerStats
+
+	// DropStats returns counters for each of the reasons that we have
+	// discarded incoming onion messages.
+	DropStats() DropStats
+
+	// HandlerStats returns delivery counts, error counts and latency for
+	// the handlers registered for each tlv type.
+	HandlerStats() map[tlv.Type]HandlerStats
 }
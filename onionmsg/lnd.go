@@ -0,0 +1,88 @@
+package onionmsg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// LndClient wraps lndclient's lightning client to provide the lnd apis that
+// the onion messenger requires but lndclient does not expose.
+type LndClient struct {
+	lndclient.LightningClient
+}
+
+// Compile time check that LndClient implements LndOnionMsg.
+var _ LndOnionMsg = (*LndClient)(nil)
+
+// NewLndClient creates a lnd client for use by the onion messenger.
+func NewLndClient(client lndclient.LightningClient) *LndClient {
+	return &LndClient{
+		LightningClient: client,
+	}
+}
+
+// Disconnect disconnects from the peer provided using lnd's raw rpc client.
+func (l *LndClient) Disconnect(ctx context.Context, peer route.Vertex) error {
+	rpcCtx, timeout, client := l.RawClientWithMacAuth(ctx)
+
+	rpcCtx, cancel := context.WithTimeout(rpcCtx, timeout)
+	defer cancel()
+
+	_, err := client.DisconnectPeer(rpcCtx, &lnrpc.DisconnectPeerRequest{
+		PubKey: peer.String(),
+	})
+
+	return err
+}
+
+// SubscribePeerEvents subscribes to lnd's peer events using lnd's raw rpc
+// client. The subscription is cancelled when the context provided is
+// cancelled, and both channels are closed when the subscription exits.
+func (l *LndClient) SubscribePeerEvents(ctx context.Context) (
+	<-chan *lnrpc.PeerEvent, <-chan error, error) {
+
+	// We don't apply our rpc timeout, because this is a long-lived stream
+	// that is bounded by the context provided.
+	rpcCtx, _, client := l.RawClientWithMacAuth(ctx)
+
+	stream, err := client.SubscribePeerEvents(
+		rpcCtx, &lnrpc.PeerEventSubscription{},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		// Buffer our error channel by 1 so that we don't block on exit
+		// if the consumer is no longer reading from it.
+		errChan   = make(chan error, 1)
+		eventChan = make(chan *lnrpc.PeerEvent)
+	)
+
+	go func() {
+		defer func() {
+			close(errChan)
+			close(eventChan)
+		}()
+
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				errChan <- fmt.Errorf("receive failed: %w", err)
+				return
+			}
+
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return eventChan, errChan, nil
+}
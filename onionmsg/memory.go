@@ -0,0 +1,598 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memorySubscriptionBuffer is the number of messages and events that we
+// buffer for each in-memory subscription before delivery blocks. This is
+// also the number of messages that we hold for a node that is not yet
+// subscribed to custom messages.
+const memorySubscriptionBuffer = 100
+
+var (
+	// ErrUnknownMemoryNode is returned when we try to reach a node that
+	// has not been added to an in-memory network.
+	ErrUnknownMemoryNode = errors.New("node not in memory network")
+
+	// ErrMemoryNotConnected is returned when we try to send a message to
+	// a node that we are not connected to in an in-memory network.
+	ErrMemoryNotConnected = errors.New("not connected to peer")
+
+	// ErrMemoryBacklogFull is returned when we send a message to a node
+	// that is not subscribed to custom messages, and already has a full
+	// backlog of messages waiting for a subscriber.
+	ErrMemoryBacklogFull = errors.New("peer message backlog full")
+
+	// ErrUnknownMemoryChannel is returned when we look up a channel that
+	// has not been added to an in-memory network.
+	ErrUnknownMemoryChannel = errors.New("channel not in memory network")
+)
+
+// MemoryNetwork wires a set of nodes together in memory, so that messengers
+// can exchange onion messages without lnd. Each node in the network provides
+// an in-process Transport and Graph that can be used to create a messenger
+// with NewTransportMessenger. Nodes can be connected directly, or with
+// channels that form a graph that nodes use to find multi-hop paths. Every
+// node in the network advertises support for onion messages.
+type MemoryNetwork struct {
+	// nodes and channels describe our network, and must be accessed
+	// under lock.
+	nodes      map[route.Vertex]*MemoryNode
+	channels   map[uint64]*lndclient.ChannelEdge
+	nextChanID uint64
+	lock       sync.Mutex
+}
+
+// NewMemoryNetwork creates an empty in-memory network.
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		nodes:      make(map[route.Vertex]*MemoryNode),
+		channels:   make(map[uint64]*lndclient.ChannelEdge),
+		nextChanID: 1,
+	}
+}
+
+// AddNode adds a node to the network, returning the node that provides its
+// transport and graph. If the node has already been added, the existing node
+// is returned.
+func (n *MemoryNetwork) AddNode(pubkey route.Vertex) *MemoryNode {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if node, ok := n.nodes[pubkey]; ok {
+		return node
+	}
+
+	node := &MemoryNode{
+		network: n,
+		pubkey:  pubkey,
+		peers:   make(map[route.Vertex]struct{}),
+	}
+
+	n.nodes[pubkey] = node
+
+	return node
+}
+
+// AddChannel adds a channel between two nodes in the network to our graph,
+// and connects the nodes if they are not already connected. The short
+// channel id assigned to the channel is returned.
+func (n *MemoryNetwork) AddChannel(node1, node2 route.Vertex) (
+	lndwire.ShortChannelID, error) {
+
+	n.lock.Lock()
+	if _, ok := n.nodes[node1]; !ok {
+		n.lock.Unlock()
+		return lndwire.ShortChannelID{}, fmt.Errorf("%w: %v",
+			ErrUnknownMemoryNode, node1)
+	}
+
+	chanID := n.nextChanID
+	n.nextChanID++
+
+	n.channels[chanID] = &lndclient.ChannelEdge{
+		ChannelID: chanID,
+		Node1:     node1,
+		Node2:     node2,
+	}
+	n.lock.Unlock()
+
+	if err := n.connect(node1, node2); err != nil {
+		n.lock.Lock()
+		delete(n.channels, chanID)
+		n.lock.Unlock()
+
+		return lndwire.ShortChannelID{}, err
+	}
+
+	return lndwire.NewShortChanIDFromInt(chanID), nil
+}
+
+// connect connects two nodes in the network, notifying both nodes' peer
+// event subscribers if they were not already connected.
+func (n *MemoryNetwork) connect(node1, node2 route.Vertex) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	first, ok := n.nodes[node1]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownMemoryNode, node1)
+	}
+
+	second, ok := n.nodes[node2]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownMemoryNode, node2)
+	}
+
+	if _, ok := first.peers[node2]; ok {
+		return nil
+	}
+
+	first.peers[node2] = struct{}{}
+	second.peers[node1] = struct{}{}
+
+	first.notifyPeer(node2, lnrpc.PeerEvent_PEER_ONLINE)
+	second.notifyPeer(node1, lnrpc.PeerEvent_PEER_ONLINE)
+
+	return nil
+}
+
+// disconnect disconnects two nodes in the network, notifying both nodes'
+// peer event subscribers if they were connected.
+func (n *MemoryNetwork) disconnect(node1, node2 route.Vertex) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	first, ok := n.nodes[node1]
+	if !ok {
+		return
+	}
+
+	if _, ok := first.peers[node2]; !ok {
+		return
+	}
+
+	second := n.nodes[node2]
+
+	delete(first.peers, node2)
+	delete(second.peers, node1)
+
+	first.notifyPeer(node2, lnrpc.PeerEvent_PEER_OFFLINE)
+	second.notifyPeer(node1, lnrpc.PeerEvent_PEER_OFFLINE)
+}
+
+// nodeChannels returns the channels in our graph that the node provided is a
+// party to, ordered by channel id so that path finding is deterministic. This
+// function must be called under lock.
+func (n *MemoryNetwork) nodeChannels(node route.Vertex) []memoryChannel {
+	var channels []memoryChannel
+	for _, channel := range n.channels {
+		switch node {
+		case channel.Node1:
+			channels = append(channels, memoryChannel{
+				channel: channel,
+				peer:    channel.Node2,
+			})
+
+		case channel.Node2:
+			channels = append(channels, memoryChannel{
+				channel: channel,
+				peer:    channel.Node1,
+			})
+		}
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].channel.ChannelID <
+			channels[j].channel.ChannelID
+	})
+
+	return channels
+}
+
+// memoryChannel is a channel in a memory network's graph, along with the peer
+// on the other side of the channel.
+type memoryChannel struct {
+	channel *lndclient.ChannelEdge
+	peer    route.Vertex
+}
+
+// memoryMsgSub is a subscription to the custom messages received by a node.
+type memoryMsgSub struct {
+	ctx  context.Context
+	msgs chan lndclient.CustomMessage
+}
+
+// memoryPeerSub is a subscription to the peer events of a node.
+type memoryPeerSub struct {
+	ctx    context.Context
+	events chan *lnrpc.PeerEvent
+}
+
+// MemoryNode is a node in an in-memory network, which implements Transport
+// and Graph for a messenger.
+type MemoryNode struct {
+	network *MemoryNetwork
+	pubkey  route.Vertex
+
+	// peers and our subscriptions are protected by our network's lock.
+	peers    map[route.Vertex]struct{}
+	msgSubs  []*memoryMsgSub
+	peerSubs []*memoryPeerSub
+
+	// backlog holds the messages that were sent to us while we had no
+	// custom message subscribers, which are delivered to our next
+	// subscriber. This prevents messages from being lost while a
+	// messenger (re)subscribes. It is protected by our network's lock.
+	backlog []lndclient.CustomMessage
+}
+
+// Compile time check that MemoryNode implements Transport and Graph.
+var (
+	_ Transport = (*MemoryNode)(nil)
+	_ Graph     = (*MemoryNode)(nil)
+)
+
+// memoryAddress returns the address that we advertise for a node in a memory
+// network.
+func memoryAddress(node route.Vertex) string {
+	return fmt.Sprintf("memory:%v", node)
+}
+
+// notifyPeer delivers a peer event to the node's subscribers without
+// blocking, since it is called under our network's lock. Events are dropped
+// for subscribers whose buffer is full. This function must be called under
+// lock.
+func (m *MemoryNode) notifyPeer(peer route.Vertex,
+	eventType lnrpc.PeerEvent_EventType) {
+
+	event := &lnrpc.PeerEvent{
+		PubKey: peer.String(),
+		Type:   eventType,
+	}
+
+	for _, sub := range m.peerSubs {
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+// SendCustomMessage delivers a custom message to a peer that we are connected
+// to, blocking until all of the peer's subscribers have received it. If the
+// peer has no subscribers, the message is held until it subscribes.
+func (m *MemoryNode) SendCustomMessage(ctx context.Context,
+	msg lndclient.CustomMessage) error {
+
+	received := lndclient.CustomMessage{
+		Peer:    m.pubkey,
+		MsgType: msg.MsgType,
+		Data:    msg.Data,
+	}
+
+	m.network.lock.Lock()
+	if _, ok := m.peers[msg.Peer]; !ok {
+		m.network.lock.Unlock()
+		return fmt.Errorf("%w: %v", ErrMemoryNotConnected, msg.Peer)
+	}
+
+	peer := m.network.nodes[msg.Peer]
+	if len(peer.msgSubs) == 0 {
+		defer m.network.lock.Unlock()
+
+		if len(peer.backlog) >= memorySubscriptionBuffer {
+			return fmt.Errorf("%w: %v", ErrMemoryBacklogFull,
+				msg.Peer)
+		}
+
+		peer.backlog = append(peer.backlog, received)
+		return nil
+	}
+
+	subs := append([]*memoryMsgSub(nil), peer.msgSubs...)
+	m.network.lock.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.msgs <- received:
+
+		// If the subscriber has gone away, we don't need to deliver
+		// the message to it.
+		case <-sub.ctx.Done():
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// SubscribeCustomMessages subscribes to the custom messages that our peers
+// send us, until the context provided is cancelled. Any messages that were
+// sent to us while we had no subscribers are delivered to the subscription.
+func (m *MemoryNode) SubscribeCustomMessages(ctx context.Context) (
+	<-chan lndclient.CustomMessage, <-chan error, error) {
+
+	sub := &memoryMsgSub{
+		ctx: ctx,
+		msgs: make(
+			chan lndclient.CustomMessage, memorySubscriptionBuffer,
+		),
+	}
+
+	m.network.lock.Lock()
+	m.msgSubs = append(m.msgSubs, sub)
+
+	// Our backlog is never larger than our buffer, so we can deliver it
+	// without blocking.
+	for _, msg := range m.backlog {
+		sub.msgs <- msg
+	}
+	m.backlog = nil
+	m.network.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.network.lock.Lock()
+		defer m.network.lock.Unlock()
+
+		for i, existing := range m.msgSubs {
+			if existing == sub {
+				m.msgSubs = append(
+					m.msgSubs[:i], m.msgSubs[i+1:]...,
+				)
+				break
+			}
+		}
+	}()
+
+	return sub.msgs, make(chan error), nil
+}
+
+// ListPeers returns the nodes that we are currently connected to.
+func (m *MemoryNode) ListPeers(_ context.Context) ([]lndclient.Peer, error) {
+	m.network.lock.Lock()
+	defer m.network.lock.Unlock()
+
+	peers := make([]lndclient.Peer, 0, len(m.peers))
+	for peer := range m.peers {
+		peers = append(peers, lndclient.Peer{
+			Pubkey:  peer,
+			Address: memoryAddress(peer),
+		})
+	}
+
+	return peers, nil
+}
+
+// Connect connects us to a node in our network. The host and permanence of
+// the connection are ignored, since in-memory connections are only removed
+// by disconnecting.
+func (m *MemoryNode) Connect(_ context.Context, peer route.Vertex, _ string,
+	_ bool) error {
+
+	return m.network.connect(m.pubkey, peer)
+}
+
+// Disconnect disconnects us from a node in our network.
+func (m *MemoryNode) Disconnect(_ context.Context, peer route.Vertex) error {
+	m.network.disconnect(m.pubkey, peer)
+	return nil
+}
+
+// SubscribePeerEvents subscribes to our peers connecting and disconnecting,
+// until the context provided is cancelled.
+func (m *MemoryNode) SubscribePeerEvents(ctx context.Context) (
+	<-chan *lnrpc.PeerEvent, <-chan error, error) {
+
+	sub := &memoryPeerSub{
+		ctx:    ctx,
+		events: make(chan *lnrpc.PeerEvent, memorySubscriptionBuffer),
+	}
+
+	m.network.lock.Lock()
+	m.peerSubs = append(m.peerSubs, sub)
+	m.network.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		m.network.lock.Lock()
+		defer m.network.lock.Unlock()
+
+		for i, existing := range m.peerSubs {
+			if existing == sub {
+				m.peerSubs = append(
+					m.peerSubs[:i], m.peerSubs[i+1:]...,
+				)
+				break
+			}
+		}
+	}()
+
+	return sub.events, make(chan error), nil
+}
+
+// GetNodeInfo looks up a node in our network. A not found error is returned
+// for nodes that are not in the network, matching lnd's behavior.
+func (m *MemoryNode) GetNodeInfo(_ context.Context, pubkey route.Vertex,
+	includeChannels bool) (*lndclient.NodeInfo, error) {
+
+	m.network.lock.Lock()
+	defer m.network.lock.Unlock()
+
+	if _, ok := m.network.nodes[pubkey]; !ok {
+		return nil, status.Errorf(codes.NotFound, "%v: %v",
+			ErrUnknownMemoryNode, pubkey)
+	}
+
+	channels := m.network.nodeChannels(pubkey)
+	info := &lndclient.NodeInfo{
+		Node: &lndclient.Node{
+			PubKey:    pubkey,
+			Addresses: []string{memoryAddress(pubkey)},
+			Features: []lndwire.FeatureBit{
+				lnwire.OnionMessagesOptional,
+			},
+		},
+		ChannelCount: len(channels),
+	}
+
+	if includeChannels {
+		for _, channel := range channels {
+			info.Channels = append(info.Channels, *channel.channel)
+		}
+	}
+
+	return info, nil
+}
+
+// GetChanInfo looks up a channel in our network's graph.
+func (m *MemoryNode) GetChanInfo(_ context.Context, chanID uint64) (
+	*lndclient.ChannelEdge, error) {
+
+	m.network.lock.Lock()
+	defer m.network.lock.Unlock()
+
+	channel, ok := m.network.channels[chanID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownMemoryChannel,
+			chanID)
+	}
+
+	edge := *channel
+	return &edge, nil
+}
+
+// QueryRoutes finds the shortest route over the channels in our network's
+// graph to the destination provided, optionally restricting the last hop
+// before the destination. Requests for amounts, fees and route hints are
+// ignored. If no route is found, lndclient.ErrNoRouteFound is returned.
+func (m *MemoryNode) QueryRoutes(_ context.Context,
+	req lndclient.QueryRoutesRequest) (*lndclient.QueryRoutesResponse,
+	error) {
+
+	source := m.pubkey
+	if req.Source != nil {
+		source = *req.Source
+	}
+
+	m.network.lock.Lock()
+	defer m.network.lock.Unlock()
+
+	// If our last hop is restricted, we find a route to the last hop that
+	// does not pass through our destination, then add the final channel.
+	target := req.PubKey
+	if req.LastHop != nil {
+		if *req.LastHop == req.PubKey {
+			return nil, lndclient.ErrNoRouteFound
+		}
+
+		target = *req.LastHop
+	}
+
+	hops := m.network.shortestPath(source, target, req.PubKey)
+	if hops == nil {
+		return nil, lndclient.ErrNoRouteFound
+	}
+
+	if req.LastHop != nil {
+		var final *memoryChannel
+		for _, channel := range m.network.nodeChannels(target) {
+			if channel.peer == req.PubKey {
+				channel := channel
+				final = &channel
+				break
+			}
+		}
+
+		if final == nil {
+			return nil, lndclient.ErrNoRouteFound
+		}
+
+		hops = append(hops, final)
+	}
+
+	resp := &lndclient.QueryRoutesResponse{}
+	for _, hop := range hops {
+		pubkey := hop.peer
+		resp.Hops = append(resp.Hops, &lndclient.Hop{
+			ChannelID: hop.channel.ChannelID,
+			PubKey:    &pubkey,
+		})
+	}
+
+	return resp, nil
+}
+
+// shortestPath performs a breadth first search over our graph to find the
+// shortest path from the source to the target, returning the channel and
+// peer for each hop. The node to exclude is not used as an intermediate hop.
+// A nil path is returned if no path is found, or the source is the target.
+// This function must be called under lock.
+func (n *MemoryNetwork) shortestPath(source, target,
+	exclude route.Vertex) []*memoryChannel {
+
+	if source == target {
+		return nil
+	}
+
+	var (
+		parents = map[route.Vertex]*memoryChannel{}
+		from    = map[route.Vertex]route.Vertex{}
+		visited = map[route.Vertex]struct{}{source: {}}
+		queue   = []route.Vertex{source}
+	)
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if node == target {
+			break
+		}
+
+		if node != source && node == exclude {
+			continue
+		}
+
+		for _, channel := range n.nodeChannels(node) {
+			if _, ok := visited[channel.peer]; ok {
+				continue
+			}
+
+			channel := channel
+			visited[channel.peer] = struct{}{}
+			parents[channel.peer] = &channel
+			from[channel.peer] = node
+			queue = append(queue, channel.peer)
+		}
+	}
+
+	if _, ok := parents[target]; !ok {
+		return nil
+	}
+
+	var path []*memoryChannel
+	for node := target; node != source; node = from[node] {
+		path = append([]*memoryChannel{parents[node]}, path...)
+	}
+
+	return path
+}
@@ -0,0 +1,458 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// newMemoryMessenger adds a node to the network provided and creates a
+// started messenger that uses it as its transport and graph, applying the
+// options provided.
+func newMemoryMessenger(t *testing.T, network *MemoryNetwork,
+	privkey *btcec.PrivateKey, opts ...MessengerOption) *Messenger {
+
+	node := network.AddNode(route.NewVertex(privkey.PubKey()))
+
+	messenger, err := NewTransportMessenger(
+		node, node, &sphinx.PrivKeyECDH{PrivKey: privkey}, nil,
+		opts...,
+	)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, messenger.Start(), "start messenger")
+
+	t.Cleanup(func() {
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	})
+
+	return messenger
+}
+
+// TestMemoryNetwork tests sending onion messages between messengers that are
+// wired together with an in-memory network.
+func TestMemoryNetwork(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		tlvType  = tlv.Type(101)
+		payload  = []byte{1, 2, 3}
+	)
+
+	tests := []struct {
+		name string
+
+		// setup connects our nodes, returning whether we should send
+		// with a direct connection.
+		setup func(t *testing.T, network *MemoryNetwork,
+			alice, bob, carol route.Vertex) bool
+	}{
+		{
+			name: "direct connection",
+			setup: func(t *testing.T, _ *MemoryNetwork, _, _,
+				_ route.Vertex) bool {
+
+				return true
+			},
+		},
+		{
+			name: "multi-hop path",
+			setup: func(t *testing.T, network *MemoryNetwork,
+				alice, bob, carol route.Vertex) bool {
+
+				_, err := network.AddChannel(alice, bob)
+				require.NoError(t, err)
+
+				_, err = network.AddChannel(bob, carol)
+				require.NoError(t, err)
+
+				return false
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			network := NewMemoryNetwork()
+
+			alice := newMemoryMessenger(t, network, privkeys[0])
+			newMemoryMessenger(t, network, privkeys[1])
+			carol := newMemoryMessenger(t, network, privkeys[2])
+
+			handled := make(chan []byte, 1)
+			handler := func(_ *lnwire.ReplyPath, _,
+				value []byte) error {
+
+				handled <- value
+				return nil
+			}
+
+			_, err := carol.RegisterHandler(tlvType, handler)
+			require.NoError(t, err, "register handler")
+
+			direct := testCase.setup(
+				t, network,
+				route.NewVertex(privkeys[0].PubKey()),
+				route.NewVertex(privkeys[1].PubKey()),
+				route.NewVertex(privkeys[2].PubKey()),
+			)
+
+			req := NewSendMessageRequest(
+				privkeys[2].PubKey(), nil, nil,
+				[]*lnwire.FinalHopPayload{
+					{
+						TLVType: tlvType,
+						Value:   payload,
+					},
+				}, direct,
+			)
+			require.NoError(t, alice.SendMessage(
+				context.Background(), req,
+			))
+
+			select {
+			case value := <-handled:
+				require.Equal(t, payload, value)
+
+			case <-time.After(defaultTimeout):
+				t.Fatal("message not delivered")
+			}
+		})
+	}
+}
+
+// TestMemoryQueryRoutes tests finding routes over an in-memory network's
+// graph.
+func TestMemoryQueryRoutes(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 3)
+		alice   = route.NewVertex(pubkeys[0])
+		bob     = route.NewVertex(pubkeys[1])
+		carol   = route.NewVertex(pubkeys[2])
+		ctx     = context.Background()
+	)
+
+	network := NewMemoryNetwork()
+	node := network.AddNode(alice)
+	network.AddNode(bob)
+	network.AddNode(carol)
+
+	// Before we have any channels, there is no route to carol.
+	_, err := node.QueryRoutes(ctx, lndclient.QueryRoutesRequest{
+		PubKey: carol,
+	})
+	require.ErrorIs(t, err, lndclient.ErrNoRouteFound)
+
+	// Add channels so that carol can be reached directly, or via bob.
+	_, err = network.AddChannel(alice, bob)
+	require.NoError(t, err)
+
+	_, err = network.AddChannel(bob, carol)
+	require.NoError(t, err)
+
+	direct, err := network.AddChannel(alice, carol)
+	require.NoError(t, err)
+
+	resp, err := node.QueryRoutes(ctx, lndclient.QueryRoutesRequest{
+		PubKey: carol,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Hops, 1)
+	require.Equal(t, carol, *resp.Hops[0].PubKey)
+	require.Equal(t, direct.ToUint64(), resp.Hops[0].ChannelID)
+
+	// Restricting our last hop to bob routes via bob.
+	resp, err = node.QueryRoutes(ctx, lndclient.QueryRoutesRequest{
+		PubKey:  carol,
+		LastHop: &bob,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Hops, 2)
+	require.Equal(t, bob, *resp.Hops[0].PubKey)
+	require.Equal(t, carol, *resp.Hops[1].PubKey)
+
+	// Our channel can be resolved by its short channel id.
+	edge, err := node.GetChanInfo(ctx, direct.ToUint64())
+	require.NoError(t, err)
+	require.Equal(t, alice, edge.Node1)
+	require.Equal(t, carol, edge.Node2)
+}
+
+// TestSendProgress tests reporting of progress for sends that are delivered
+// along a multi-hop path and with a direct connection.
+func TestSendProgress(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 3)
+
+	tests := []struct {
+		name     string
+		channels bool
+		expected []SendProgress
+	}{
+		{
+			name:     "multi-hop path",
+			channels: true,
+			expected: []SendProgress{
+				ProgressResolvingRoute,
+				ProgressSent,
+			},
+		},
+		{
+			name: "direct connection",
+			expected: []SendProgress{
+				ProgressResolvingRoute,
+				ProgressConnecting,
+				ProgressConnected,
+				ProgressSent,
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				network = NewMemoryNetwork()
+				alice   = route.NewVertex(privkeys[0].PubKey())
+				bob     = route.NewVertex(privkeys[1].PubKey())
+				carol   = route.NewVertex(privkeys[2].PubKey())
+			)
+
+			sender := newMemoryMessenger(t, network, privkeys[0])
+			newMemoryMessenger(t, network, privkeys[1])
+			newMemoryMessenger(t, network, privkeys[2])
+
+			if testCase.channels {
+				_, err := network.AddChannel(alice, bob)
+				require.NoError(t, err)
+
+				_, err = network.AddChannel(bob, carol)
+				require.NoError(t, err)
+			}
+
+			var progress []SendProgress
+			req := NewSendMessageRequest(
+				privkeys[2].PubKey(), nil, nil, nil, true,
+			)
+			req.OnProgress = func(p SendProgress) {
+				progress = append(progress, p)
+			}
+
+			require.NoError(t, sender.SendMessage(
+				context.Background(), req,
+			))
+			require.Equal(t, testCase.expected, progress)
+		})
+	}
+}
+
+// TestRegisterBeforeStart tests that handlers registered before a messenger
+// is started receive the messages that it handles once started.
+func TestRegisterBeforeStart(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 2)
+		tlvType  = tlv.Type(101)
+		payload  = []byte{1, 2, 3}
+		network  = NewMemoryNetwork()
+	)
+
+	sender := newMemoryMessenger(t, network, privkeys[0])
+
+	node := network.AddNode(route.NewVertex(privkeys[1].PubKey()))
+	receiver, err := NewTransportMessenger(
+		node, node, &sphinx.PrivKeyECDH{PrivKey: privkeys[1]}, nil,
+	)
+	require.NoError(t, err, "new messenger")
+
+	handled := make(chan []byte, 1)
+	_, err = receiver.RegisterHandler(tlvType, func(_ *lnwire.ReplyPath,
+		_, value []byte) error {
+
+		handled <- value
+		return nil
+	})
+	require.NoError(t, err, "register before start")
+
+	require.NoError(t, receiver.Start(), "start messenger")
+	t.Cleanup(func() {
+		require.NoError(t, receiver.Stop(), "stop messenger")
+	})
+
+	req := NewSendMessageRequest(
+		privkeys[1].PubKey(), nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: tlvType,
+				Value:   payload,
+			},
+		}, true,
+	)
+	require.NoError(t, sender.SendMessage(context.Background(), req))
+
+	select {
+	case value := <-handled:
+		require.Equal(t, payload, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not delivered")
+	}
+}
+
+// TestMessengerHandlerStats tests that we track deliveries to the handlers
+// registered for each tlv type, and reset a type's stats once its handlers
+// are removed.
+func TestMessengerHandlerStats(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 2)
+		tlvType  = tlv.Type(101)
+		network  = NewMemoryNetwork()
+		errFail  = errors.New("handler failed")
+	)
+
+	sender := newMemoryMessenger(t, network, privkeys[0])
+	receiver := newMemoryMessenger(t, network, privkeys[1])
+
+	handled := make(chan struct{}, 2)
+	id, err := receiver.RegisterHandler(tlvType, func(_ *lnwire.ReplyPath,
+		_, value []byte) error {
+
+		defer func() {
+			handled <- struct{}{}
+		}()
+
+		if value[0] == 1 {
+			return errFail
+		}
+
+		return nil
+	})
+	require.NoError(t, err, "register handler")
+
+	// Before we've received any messages, our type has empty stats.
+	require.Equal(t, map[tlv.Type]HandlerStats{
+		tlvType: {},
+	}, receiver.HandlerStats())
+
+	for _, value := range []byte{0, 1} {
+		req := NewSendMessageRequest(
+			privkeys[1].PubKey(), nil, nil,
+			[]*lnwire.FinalHopPayload{
+				{
+					TLVType: tlvType,
+					Value:   []byte{value},
+				},
+			}, true,
+		)
+		require.NoError(t, sender.SendMessage(
+			context.Background(), req,
+		))
+
+		select {
+		case <-handled:
+		case <-time.After(defaultTimeout):
+			t.Fatal("message not delivered")
+		}
+	}
+
+	// Our stats are recorded once our handler has returned, so we wait
+	// for both deliveries to be recorded.
+	require.Eventually(t, func() bool {
+		return receiver.HandlerStats()[tlvType].Deliveries == 2
+	}, defaultTimeout, time.Millisecond*10)
+
+	stats := receiver.HandlerStats()[tlvType]
+	require.EqualValues(t, 1, stats.Errors)
+	require.GreaterOrEqual(t, stats.TotalLatency, stats.MaxLatency)
+
+	// Once we remove our handler, we no longer have stats for its type.
+	require.NoError(t, receiver.DeregisterHandler(tlvType, id))
+	require.Empty(t, receiver.HandlerStats())
+}
+
+// TestExplicitRoute tests sending an onion message along an explicit route,
+// without looking up a path to its destination.
+func TestExplicitRoute(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+		tlvType  = tlv.Type(101)
+		payload  = []byte{1, 2, 3}
+		network  = NewMemoryNetwork()
+	)
+
+	node := network.AddNode(alice)
+	finder := &countingPathFinder{
+		PathFinder: NewQueryRoutesPathFinder(node),
+		lookups:    make(map[route.Vertex]int),
+	}
+
+	sender, err := NewTransportMessenger(
+		node, node, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionPathFinder(finder),
+	)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, sender.Start(), "start messenger")
+	t.Cleanup(func() {
+		require.NoError(t, sender.Stop(), "stop messenger")
+	})
+
+	newMemoryMessenger(t, network, privkeys[1])
+	receiver := newMemoryMessenger(t, network, privkeys[2])
+
+	handled := make(chan []byte, 1)
+	_, err = receiver.RegisterHandler(tlvType, func(_ *lnwire.ReplyPath,
+		_, value []byte) error {
+
+		handled <- value
+		return nil
+	})
+	require.NoError(t, err, "register handler")
+
+	_, err = network.AddChannel(alice, bob)
+	require.NoError(t, err)
+
+	_, err = network.AddChannel(bob, carol)
+	require.NoError(t, err)
+
+	req := NewSendMessageRequest(
+		privkeys[2].PubKey(), nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: tlvType,
+				Value:   payload,
+			},
+		}, false,
+	)
+	req.Route = []*btcec.PublicKey{
+		privkeys[1].PubKey(), privkeys[2].PubKey(),
+	}
+	require.NoError(t, sender.SendMessage(context.Background(), req))
+
+	select {
+	case value := <-handled:
+		require.Equal(t, payload, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not delivered")
+	}
+
+	// We should have sent along our route without looking up a path.
+	require.Empty(t, finder.lookups)
+
+	// A route that we can't send along fails without falling back to
+	// another path.
+	req.Route = []*btcec.PublicKey{privkeys[2].PubKey()}
+	err = sender.SendMessage(context.Background(), req)
+	require.ErrorIs(t, err, ErrMemoryNotConnected)
+	require.Empty(t, finder.lookups)
+}
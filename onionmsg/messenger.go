@@ -14,14 +14,65 @@ import (
 	"github.com/gijswijs/boltnd/routes"
 	"github.com/lightninglabs/lndclient"
 	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/lnrpc"
 	lndwire "github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/lightningnetwork/lnd/tlv"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
-	lookupPeerBackoffDefault  = time.Second * 1
-	lookupPeerAttemptsDefault = 5
+	// lookupPeerBackoffDefault is the default initial amount of time
+	// that we back off for when waiting for a peer to connect. This value
+	// is doubled for each attempt, up to lookupPeerMaxBackoffDefault.
+	lookupPeerBackoffDefault    = time.Millisecond * 500
+	lookupPeerMaxBackoffDefault = time.Second * 8
+
+	// lookupPeerTimeoutDefault is the default overall amount of time that
+	// we wait for a peer to connect.
+	lookupPeerTimeoutDefault = time.Second * 30
+
+	// resolveSCIDTimeout is the amount of time that we allow for looking
+	// up the channel that a relay hop identifies its next node by.
+	resolveSCIDTimeout = time.Second * 10
+
+	// lookupPeerAttemptsDefault is the default maximum number of times
+	// that we lookup a peer after connecting to it.
+	lookupPeerAttemptsDefault = 8
+
+	// handlerWorkersDefault is the default number of goroutines that we
+	// use to process incoming onion messages.
+	handlerWorkersDefault = 4
+
+	// resubscribeBackoffDefault is the initial amount of time that we
+	// back off for before resubscribing to lnd's custom message stream.
+	// This value is doubled for each consecutive failure.
+	resubscribeBackoffDefault = time.Second
+
+	// resubscribeAttemptsDefault is the number of consecutive times that
+	// we try to resubscribe to lnd's custom message stream before
+	// exiting with an error.
+	resubscribeAttemptsDefault = 5
+
+	// resubscribeHealthyDefault is the amount of time that a resubscribed
+	// custom message stream must stay up before we consider it healthy
+	// and reset our count of consecutive failures.
+	resubscribeHealthyDefault = time.Minute
+
+	// inboundQueueSizeDefault is the default number of incoming onion
+	// messages that we queue for processing by our handler workers.
+	inboundQueueSizeDefault = 100
+
+	// forwardQueueSizeDefault is the default number of onion messages
+	// that we buffer for forwarding to the next node in their route.
+	forwardQueueSizeDefault = 100
+
+	// maxPathAttempts is the maximum number of candidate paths that we
+	// try to send a message along before we fall back to sending it
+	// directly to its target.
+	maxPathAttempts = 3
 )
 
 var (
@@ -33,14 +84,19 @@ var (
 	// that isn't currently registered.
 	ErrHandlerNotFound = errors.New("handler not found")
 
-	// ErrHandlerRegistered is returned when we try to register a handler
-	// for a type that already exists.
+	// ErrHandlerRegistered is returned when we try to register a wildcard
+	// handler when one already exists.
 	ErrHandlerRegistered = errors.New("handler already registered")
 
 	// ErrNoAddresses is returned when we can't find a node's address in the
 	// public graph.
 	ErrNoAddresses = errors.New("no advertised addresses")
 
+	// ErrPeerNoOnionSupport is returned when we try to directly connect to
+	// a peer that does not advertise support for onion messages.
+	ErrPeerNoOnionSupport = errors.New("peer does not support onion " +
+		"messages")
+
 	// ErrNoConnection is returned if we don't successfully connect to our
 	// peer within our set number of retries.
 	ErrNoConnection = errors.New("peer not connected within wait period")
@@ -53,6 +109,17 @@ var (
 	// message chain contains fields that are reserved for the last hop.
 	ErrFinalPayload = errors.New("intermediate hop has final hop payloads")
 
+	// ErrRelayReplyPath is returned if an intermediate hop in an onion
+	// message chain has a reply path, which is only allowed for the final
+	// hop.
+	ErrRelayReplyPath = errors.New("intermediate hop has reply path")
+
+	// ErrRelayPathID is returned if the encrypted data for an intermediate
+	// hop in an onion message chain contains a path ID, which is only
+	// allowed for the final hop.
+	ErrRelayPathID = errors.New("intermediate hop encrypted data has " +
+		"path id")
+
 	// ErrBadMessage is returned when we can't process an onion message.
 	ErrBadMessage = errors.New("onion message processing failed")
 
@@ -71,9 +138,15 @@ var (
 	// message but no next onion is provided.
 	ErrNoForwardingOnion = errors.New("no next onion provided to forward")
 
-	// ErrNoNextNodeID is returned when we require a next node id in our
-	// encrypted data blob and one was not provided.
-	ErrNoNextNodeID = errors.New("next node ID required")
+	// ErrNoNextNodeID is returned when we require a next node in our
+	// encrypted data blob and neither a node id nor a short channel id
+	// was provided.
+	ErrNoNextNodeID = errors.New("next node ID or short channel ID " +
+		"required")
+
+	// ErrSCIDNotOurs is returned when a hop identifies the next node by a
+	// short channel id for a channel that our node is not a party to.
+	ErrSCIDNotOurs = errors.New("short channel id not for our channel")
 
 	// ErrBothDest is returned when a message request sets more than one
 	// destination type.
@@ -88,6 +161,33 @@ var (
 	// to a blinded route with no hops.
 	ErrNoBlindedHops = errors.New("at least one blinded hop required")
 
+	// ErrInvalidRoute is returned when a message request specifies an
+	// explicit route that can't be used to deliver the message.
+	ErrInvalidRoute = errors.New("invalid explicit route")
+
+	// ErrForwardQueueFull is returned when we can't queue an onion message
+	// for forwarding because our forwarding queue is full.
+	ErrForwardQueueFull = errors.New("forwarding queue full")
+
+	// ErrForwardingDisabled is returned when we receive an onion message
+	// that should be relayed to another node, but we are running in
+	// endpoint-only mode.
+	ErrForwardingDisabled = errors.New("onion message forwarding " +
+		"disabled")
+
+	// ErrDuplicatePayload is returned when a send request includes more
+	// than one final hop payload with the same tlv type.
+	ErrDuplicatePayload = errors.New("duplicate final hop payload type")
+
+	// ErrMessageExpired is returned when we try to send an onion message
+	// after its expiry has passed.
+	ErrMessageExpired = errors.New("onion message expired")
+
+	// ErrSelfDestination is returned when we try to send an onion message
+	// to a blinded destination that terminates at our own node.
+	ErrSelfDestination = errors.New("blinded destination terminates at " +
+		"our node")
+
 	// ErrShuttingDown is returned when the messenger exits.
 	ErrShuttingDown = errors.New("messenger shutting down")
 
@@ -95,11 +195,60 @@ var (
 	ErrLNDShutdown = errors.New("lnd shutting down")
 )
 
+// DropPolicy determines which message is dropped when our queue of incoming
+// onion messages is full.
+type DropPolicy uint8
+
+const (
+	// DropNewest drops newly received onion messages when our inbound
+	// queue is full.
+	DropNewest DropPolicy = iota
+
+	// DropOldest drops the oldest message in our inbound queue to make
+	// space for newly received onion messages when the queue is full.
+	DropOldest
+)
+
+// String returns the string representation of a drop policy.
+func (d DropPolicy) String() string {
+	switch d {
+	case DropNewest:
+		return "drop newest"
+
+	case DropOldest:
+		return "drop oldest"
+
+	default:
+		return fmt.Sprintf("unknown drop policy: %d", d)
+	}
+}
+
 // OnionMessageHandler is the function signature for handlers used to manage
 // final hop payloads included in onion messages. It takes the reply path,
 // encrypted data and value of the final hop's tlv as arguments.
 type OnionMessageHandler func(*lnwire.ReplyPath, []byte, []byte) error
 
+// WildcardHandler is the function signature for catch-all handlers that
+// receive every final hop payload included in onion messages, regardless of
+// tlv type. It takes the tlv type of the payload, followed by the same
+// arguments as OnionMessageHandler.
+type WildcardHandler func(tlv.Type, *lnwire.ReplyPath, []byte, []byte) error
+
+// HandlerID uniquely identifies a handler that has been registered with the
+// messenger, so that multiple handlers can be registered for the same tlv
+// type and removed individually.
+type HandlerID uint64
+
+// typedHandler is a handler that has been registered for a tlv type.
+type typedHandler struct {
+	id      HandlerID
+	handler OnionMessageHandler
+
+	// internal is set for handlers that the messenger registers itself,
+	// which are not removed by DeregisterAll.
+	internal bool
+}
+
 // registerHandler coordinates the (de)registration of handlers for tlv
 // namespaces in the reserved final hop payload range.
 type registerHandler struct {
@@ -107,23 +256,44 @@ type registerHandler struct {
 	// be within the final hop payload range (>=64).
 	tlvType tlv.Type
 
+	// id is the unique identifier of the handler being (de)registered.
+	id HandlerID
+
 	// handler is the handler to register, this may be nil on
 	// de-registration.
 	handler OnionMessageHandler
 
+	// wildcard is set when we are (de)registering our catch-all handler
+	// rather than a handler for a specific tlv type, in which case tlvType
+	// and handler are not set.
+	wildcard bool
+
+	// wildcardHandler is the catch-all handler to register, this may be
+	// nil on de-registration.
+	wildcardHandler WildcardHandler
+
 	// deregister is set to true when we are removing a handler.
 	deregister bool
 
+	// all is set when we are removing all registered handlers, in which
+	// case no other fields are set.
+	all bool
+
+	// internal is set when the messenger is registering a handler for
+	// its own use.
+	internal bool
+
 	// errChan is used to communicate errors back to the caller. On
 	// completion of (de)registration, we expect this channel to be closed.
 	errChan chan error
 }
 
-func newRegisterHandler(tlvType tlv.Type, handler OnionMessageHandler,
-	dergister bool) *registerHandler {
+func newRegisterHandler(tlvType tlv.Type, id HandlerID,
+	handler OnionMessageHandler, dergister bool) *registerHandler {
 
 	return &registerHandler{
 		tlvType:    tlvType,
+		id:         id,
 		handler:    handler,
 		deregister: dergister,
 		// Buffer the channel by 1 so that we are not blocked on the
@@ -132,13 +302,57 @@ func newRegisterHandler(tlvType tlv.Type, handler OnionMessageHandler,
 	}
 }
 
+// newRegisterWildcardHandler creates a request to (de)register our catch-all
+// handler.
+func newRegisterWildcardHandler(handler WildcardHandler,
+	deregister bool) *registerHandler {
+
+	return &registerHandler{
+		wildcard:        true,
+		wildcardHandler: handler,
+		deregister:      deregister,
+		errChan:         make(chan error, 1),
+	}
+}
+
+// newDeregisterAll creates a request to deregister all of our handlers.
+func newDeregisterAll() *registerHandler {
+	return &registerHandler{
+		deregister: true,
+		all:        true,
+		errChan:    make(chan error, 1),
+	}
+}
+
+// String returns a description of the handler being (de)registered.
+func (r *registerHandler) String() string {
+	switch {
+	case r.all:
+		return "all"
+
+	case r.wildcard:
+		return "wildcard"
+	}
+
+	return fmt.Sprintf("%v (id: %v)", r.tlvType, r.id)
+}
+
 // Messenger houses the functionality to send and receive onion messages.
 type Messenger struct {
 	started int32 // to be used atomically
 	stopped int32 // to be used atomically
 
-	// lnd provides the lnd apis required for onion messaging.
-	lnd LndOnionMsg
+	dropped       uint64 // to be used atomically
+	outboxID      uint64 // to be used atomically
+	nextHandlerID uint64 // to be used atomically
+
+	// transport sends and receives onion messages to and from our peers,
+	// and manages our connections to them.
+	transport Transport
+
+	// graph provides the channel graph lookups required to connect to
+	// nodes and find paths to them.
+	graph Graph
 
 	// router provides onion routing capabilities for the messenger.
 	router *sphinx.Router
@@ -146,17 +360,202 @@ type Messenger struct {
 	// nodeKeyECDH provides ecdh operations with our node key.
 	nodeKeyECDH sphinx.SingleKeyECDH
 
-	// lookupPeerBackoff is the amount of time that we back off for when
-	// waiting to connect to a peer.
-	lookupPeerBackoff time.Duration
+	// onionMsgType is the custom message type that we use to send and
+	// receive onion messages.
+	onionMsgType uint32
 
-	// lookupPeerAttempts is the number of times we try to lookup our peer
-	// once connected.
+	// acceptMsgTypes is an optional set of additional custom message
+	// types that we accept onion messages with.
+	acceptMsgTypes []uint32
+
+	// lookupPeerBackoff is the initial amount of time that we back off
+	// for when waiting to connect to a peer. Our backoff is doubled
+	// (with jitter) for each lookup, up to lookupPeerMaxBackoff.
+	lookupPeerBackoff    time.Duration
+	lookupPeerMaxBackoff time.Duration
+
+	// lookupPeerTimeout is the overall amount of time that we wait for a
+	// peer to connect.
+	lookupPeerTimeout time.Duration
+
+	// lookupPeerAttempts is the maximum number of times we try to lookup
+	// our peer once connected.
 	lookupPeerAttempts int
 
-	// onionMsgHandlers contains a set of handlers for onion message final
-	// hop payloads.
-	onionMsgHandlers map[tlv.Type]OnionMessageHandler
+	// resubscribeBackoff is the initial amount of time that we back off
+	// for before resubscribing to custom messages after a failure.
+	resubscribeBackoff time.Duration
+
+	// resubscribeAttempts is the number of consecutive times that we try
+	// to resubscribe to custom messages before exiting with an error.
+	resubscribeAttempts int
+
+	// resubscribeHealthy is the amount of time that a resubscribed stream
+	// must stay up before we reset our count of consecutive failures.
+	resubscribeHealthy time.Duration
+
+	// handlerWorkers is the number of goroutines that process incoming
+	// onion messages concurrently.
+	handlerWorkers int
+
+	// inboundQueueSize is the number of incoming onion messages that we
+	// queue for our handler workers before applying our drop policy.
+	inboundQueueSize int
+
+	// forwardQueueSize is the number of onion messages that we buffer for
+	// forwarding.
+	forwardQueueSize int
+
+	// replayWindow and replayCacheSize bound the amount of time and the
+	// number of onion packets that we remember to detect replays.
+	replayWindow    time.Duration
+	replayCacheSize int
+
+	// dropPolicy determines which message we drop when our inbound queue
+	// is full.
+	dropPolicy DropPolicy
+
+	// incoming is a bounded queue used to hand incoming onion messages
+	// off to our pool of handler workers, so that we can continue to
+	// consume messages from lnd while all of our workers are busy
+	// without unbounded memory use.
+	incoming chan lndclient.CustomMessage
+
+	// forwardQueue holds onion messages that are queued for forwarding
+	// to the next node in their route. Forwarding is handled by its own
+	// goroutine so that it does not block processing of incoming
+	// messages.
+	forwardQueue chan forwardRequest
+
+	// globalSendLimit and globalSendBurst limit the rate at which we send
+	// custom messages to all of our peers, and peerSendLimit and
+	// peerSendBurst limit the rate at which we send to each peer. Limits
+	// of rate.Inf disable rate limiting.
+	globalSendLimit rate.Limit
+	globalSendBurst int
+	peerSendLimit   rate.Limit
+	peerSendBurst   int
+
+	// sendLimiter paces the custom messages that we send to lnd.
+	sendLimiter *sendLimiter
+
+	// breakerThreshold and breakerCoolDown configure our breaker, which
+	// stops accepting onion messages from peers that repeatedly send us
+	// malformed messages or messages that we fail to forward.
+	breakerThreshold int
+	breakerCoolDown  time.Duration
+	breaker          *peerBreaker
+
+	// stats tracks relay activity for each of our peers.
+	stats *peerStats
+
+	// metrics is the collector that we report telemetry to.
+	metrics MetricsCollector
+
+	// sessionKeys provides the keys that we use to construct onions.
+	sessionKeys SessionKeySource
+
+	// payloadSigner is an optional signer used to authenticate the final
+	// hop payloads of the messages that we send. If nil, payloads are not
+	// signed.
+	payloadSigner PayloadSigner
+
+	// pathFinder selects the paths that we use to send onion messages. If
+	// not set by our options, a query routes path finder is used, which
+	// randomly selects from pathCandidates paths if it is more than one.
+	pathFinder     PathFinder
+	pathCandidates int
+
+	// graphCacheTTL is the amount of time that we cache graph lookups and
+	// route queries for. If zero, lookups are not cached.
+	graphCacheTTL time.Duration
+	graphCache    *graphCache
+
+	// maxHops is the maximum number of hops that we allow in the routes
+	// for the messages that we send and in the reply paths that we
+	// generate.
+	maxHops int
+
+	// endpointOnly indicates that we only process onion messages that are
+	// addressed to our node, and refuse to relay messages for others.
+	endpointOnly bool
+
+	// probeResponder indicates that we reply to the probes that we
+	// receive.
+	probeResponder bool
+
+	// outbox is an optional store used to persist messages that could
+	// not be delivered because their destination was unreachable. If
+	// nil, store-and-forward delivery is disabled.
+	outbox OutboxStore
+
+	// outboxTTL is the amount of time that we retry delivery of messages
+	// in our outbox before dropping them.
+	outboxTTL time.Duration
+
+	// outboxRetryInterval is the interval at which we retry delivery of
+	// messages in our outbox.
+	outboxRetryInterval time.Duration
+
+	// permanentPeers is the set of peers that we have made permanent
+	// connections to. We track this so that re-dialing a peer for a
+	// transient send does not downgrade a connection that was previously
+	// permanent. This set must be accessed under peersLock.
+	permanentPeers map[route.Vertex]struct{}
+	peersLock      sync.Mutex
+
+	// connManager is an optional manager that holds connections open to
+	// the peers that we frequently send direct-connect messages to. If
+	// nil, transient connections are closed after each send.
+	connManager *connManager
+
+	// typeRegistry is an optional registry that the tlv types of our
+	// handlers are claimed in. If nil, multiple handlers may be
+	// registered for each type.
+	typeRegistry *lnwire.TypeRegistry
+
+	// replyPaths is an optional generator used to create reply paths for
+	// messages that expect a reply. If nil, these messages can't be sent.
+	replyPaths routes.Generator
+
+	// pendingReplies maps the path IDs of the reply paths that we are
+	// waiting for replies on to a channel for delivering the reply. This
+	// map must be accessed under repliesLock.
+	pendingReplies map[string]chan *Reply
+	repliesLock    sync.Mutex
+
+	// pathIDKey is the secret that we use to authenticate the path IDs
+	// that we include in our blinded paths. It is derived from our node
+	// key on first use, and must be accessed under pathIDLock.
+	pathIDKey  []byte
+	pathIDLock sync.Mutex
+
+	// routerLock serializes access to our router, which does not support
+	// concurrent processing of onion packets.
+	routerLock sync.Mutex
+
+	// onionMsgHandlers contains the handlers for each onion message final
+	// hop payload type, in the order that they were registered. This map
+	// is written by our main event loop and read by our handler workers,
+	// so must be accessed under handlerLock.
+	onionMsgHandlers map[tlv.Type][]*typedHandler
+
+	// handlerStats holds the stats for each tlv type that we have
+	// handlers registered for in onionMsgHandlers, and must be accessed
+	// under handlerLock.
+	handlerStats map[tlv.Type]*handlerStats
+
+	// wildcardHandler is an optional catch-all handler that receives
+	// every final hop payload. It must be accessed under handlerLock.
+	wildcardHandler WildcardHandler
+	handlerLock     sync.RWMutex
+
+	// incomingInterceptors and dispatchInterceptors are chains of
+	// interceptors that incoming onion messages are passed through
+	// before decryption and before dispatch to our handlers. They are
+	// set by functional options and not modified after construction.
+	incomingInterceptors []IncomingInterceptor
+	dispatchInterceptors []DispatchInterceptor
 
 	// handlerRegistration is a channel used to coordinate message handler
 	// registration (and de-registration).
@@ -166,28 +565,133 @@ type Messenger struct {
 	// signal to calling code that it should gracefully exit.
 	requestShutdown func(err error)
 
+	// drainTimeout is the maximum amount of time that we wait for
+	// in-flight work to complete when we are stopped.
+	drainTimeout time.Duration
+
+	// draining is closed when we start to shut down, signalling that we
+	// should stop accepting new work and complete the work that is
+	// already in-flight. drainLock serializes closing this channel with
+	// the tracking of in-flight sends in sendWg.
+	draining  chan struct{}
+	drainLock sync.RWMutex
+	sendWg    sync.WaitGroup
+
+	// intakeDone is closed once our main loop will no longer queue
+	// incoming messages for our handler workers.
+	intakeDone chan struct{}
+
+	// handlerWg tracks our handler workers, and handlersDrained is closed
+	// once they have all exited so that our forwarding goroutine knows
+	// that no more messages will be queued for forwarding.
+	handlerWg       sync.WaitGroup
+	handlersDrained chan struct{}
+
+	// forwardsDrained is closed when our forwarding goroutine exits.
+	forwardsDrained chan struct{}
+
 	wg   sync.WaitGroup
 	quit chan struct{}
 }
 
-// NewOnionMessenger creates a new onion messenger.
+// NewOnionMessenger creates a new onion messenger that uses lnd as its
+// transport and graph, applying the functional options provided over our
+// default configuration.
 func NewOnionMessenger(lnd LndOnionMsg,
-	nodeKeyECDH sphinx.SingleKeyECDH,
-	shutdown func(error)) *Messenger {
+	nodeKeyECDH sphinx.SingleKeyECDH, shutdown func(error),
+	opts ...MessengerOption) (*Messenger, error) {
 
-	return &Messenger{
-		lnd: lnd,
-		router: sphinx.NewRouter(
-			nodeKeyECDH, sphinx.NewMemoryReplayLog(),
-		),
-		nodeKeyECDH:         nodeKeyECDH,
-		lookupPeerBackoff:   lookupPeerBackoffDefault,
-		lookupPeerAttempts:  lookupPeerAttemptsDefault,
-		onionMsgHandlers:    make(map[tlv.Type]OnionMessageHandler),
-		handlerRegistration: make(chan *registerHandler),
-		requestShutdown:     shutdown,
-		quit:                make(chan struct{}),
+	return NewTransportMessenger(lnd, lnd, nodeKeyECDH, shutdown, opts...)
+}
+
+// NewTransportMessenger creates a new onion messenger that exchanges onion
+// messages over the transport provided and finds nodes using the graph
+// provided, applying the functional options provided over our default
+// configuration. This allows the messenger to be used without lnd. The
+// transport, graph and node key provided are not used until the messenger is
+// started, so they may be connected to their backend after creation.
+func NewTransportMessenger(transport Transport, graph Graph,
+	nodeKeyECDH sphinx.SingleKeyECDH, shutdown func(error),
+	opts ...MessengerOption) (*Messenger, error) {
+
+	m := &Messenger{
+		transport:            transport,
+		graph:                graph,
+		nodeKeyECDH:          nodeKeyECDH,
+		onionMsgType:         lnwire.OnionMessageType,
+		lookupPeerBackoff:    lookupPeerBackoffDefault,
+		lookupPeerMaxBackoff: lookupPeerMaxBackoffDefault,
+		lookupPeerTimeout:    lookupPeerTimeoutDefault,
+		lookupPeerAttempts:   lookupPeerAttemptsDefault,
+		resubscribeBackoff:   resubscribeBackoffDefault,
+		resubscribeAttempts:  resubscribeAttemptsDefault,
+		resubscribeHealthy:   resubscribeHealthyDefault,
+		handlerWorkers:       handlerWorkersDefault,
+		inboundQueueSize:     inboundQueueSizeDefault,
+		forwardQueueSize:     forwardQueueSizeDefault,
+		dropPolicy:           DropNewest,
+		globalSendLimit:      rate.Inf,
+		peerSendLimit:        rate.Inf,
+		breakerThreshold:     breakerThresholdDefault,
+		breakerCoolDown:      breakerCoolDownDefault,
+		stats:                newPeerStats(),
+		metrics:              &noopMetrics{},
+		sessionKeys:          &randomKeySource{},
+		maxHops:              sphinx.NumMaxHops,
+		replayWindow:         replayWindowDefault,
+		replayCacheSize:      replayCacheSizeDefault,
+		outboxRetryInterval:  outboxRetryIntervalDefault,
+		permanentPeers:       make(map[route.Vertex]struct{}),
+		pendingReplies:       make(map[string]chan *Reply),
+		onionMsgHandlers:     make(map[tlv.Type][]*typedHandler),
+		handlerStats:         make(map[tlv.Type]*handlerStats),
+		handlerRegistration:  make(chan *registerHandler),
+		requestShutdown:      shutdown,
+		drainTimeout:         drainTimeoutDefault,
+		draining:             make(chan struct{}),
+		intakeDone:           make(chan struct{}),
+		handlersDrained:      make(chan struct{}),
+		forwardsDrained:      make(chan struct{}),
+		quit:                 make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wrap our graph in our graph cache before we create any components
+	// that use it.
+	if m.graphCacheTTL > 0 {
+		m.graphCache = newGraphCache(
+			m.graph, m.graphCacheTTL, time.Now,
+		)
+		m.graph = m.graphCache
+	}
+
+	switch {
+	case m.pathFinder != nil:
+
+	case m.pathCandidates > 1:
+		m.pathFinder = NewRandomizedPathFinder(
+			m.graph, m.pathCandidates,
+		)
+
+	default:
+		m.pathFinder = NewQueryRoutesPathFinder(m.graph)
 	}
+
+	m.forwardQueue = make(chan forwardRequest, m.forwardQueueSize)
+	m.breaker = newPeerBreaker(
+		m.breakerThreshold, m.breakerCoolDown, time.Now,
+	)
+	m.sendLimiter = newSendLimiter(
+		m.globalSendLimit, m.globalSendBurst, m.peerSendLimit,
+		m.peerSendBurst,
+	)
+
+	return m, nil
 }
 
 // Start the messenger, running all goroutines required.
@@ -197,10 +701,49 @@ func (m *Messenger) Start() error {
 	}
 
 	log.Info("Starting onion messenger")
+
+	// We only create our onion router on start, because it requires our
+	// node's pubkey which callers may not know when the messenger is
+	// created.
+	m.router = m.newRouter()
 	if err := m.router.Start(); err != nil {
 		return fmt.Errorf("could not start router: %w", err)
 	}
 
+	if m.probeResponder {
+		id := HandlerID(atomic.AddUint64(&m.nextHandlerID, 1))
+		request := newRegisterHandler(
+			lnwire.ProbeType, id, m.respondToProbe, false,
+		)
+		request.internal = true
+
+		if err := m.registerHandler(request); err != nil {
+			return fmt.Errorf("could not register probe "+
+				"responder: %w", err)
+		}
+	}
+
+	m.incoming = make(chan lndclient.CustomMessage, m.inboundQueueSize)
+	for i := 0; i < m.handlerWorkers; i++ {
+		m.wg.Add(1)
+		m.handlerWg.Add(1)
+		go m.handleIncoming()
+	}
+
+	m.wg.Add(1)
+	go m.forwardMessages()
+
+	if m.connManager != nil {
+		m.wg.Add(1)
+		go m.manageConnections()
+	}
+
+	if m.outbox != nil {
+		if err := m.startOutbox(); err != nil {
+			return fmt.Errorf("could not start outbox: %w", err)
+		}
+	}
+
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
@@ -214,7 +757,20 @@ func (m *Messenger) Start() error {
 	return nil
 }
 
-// Stop shuts down the messenger and waits for all goroutines to exit.
+// newRouter creates an onion router for our node key that rejects replayed
+// onion packets.
+func (m *Messenger) newRouter() *sphinx.Router {
+	return sphinx.NewRouter(
+		m.nodeKeyECDH, newReplayCache(
+			m.replayWindow, m.replayCacheSize, time.Now,
+		),
+	)
+}
+
+// Stop shuts down the messenger and waits for all goroutines to exit. We
+// first stop accepting new work, and give the sends, forwards and handler
+// invocations that are already in-flight until our drain timeout to complete
+// so that messages we have accepted are not lost.
 func (m *Messenger) Stop() error {
 	if !atomic.CompareAndSwapInt32(&m.stopped, 0, 1) {
 		return fmt.Errorf("messenger already stopped")
@@ -223,6 +779,11 @@ func (m *Messenger) Stop() error {
 	log.Info("Stopping onion messenger")
 	defer log.Info("Onion messenger stopped")
 
+	m.startDrain()
+	if m.hasStarted() {
+		m.drain()
+	}
+
 	// Signal our goroutines to quit and wait for them to exit.
 	close(m.quit)
 	m.wg.Wait()
@@ -230,7 +791,9 @@ func (m *Messenger) Stop() error {
 	// Shutdown our onion router. We do this after shutting down goroutines
 	// so that any errors due to a stopped router don't error-out before we
 	// can cleanly shut down.
-	m.router.Stop()
+	if m.router != nil {
+		m.router.Stop()
+	}
 
 	return nil
 }
@@ -245,21 +808,90 @@ func (m *Messenger) hasStarted() bool {
 var _ OnionMessenger = (*Messenger)(nil)
 
 // RegisterHandler connects the handler provided to a tlv type in the final
-// hop payload range in onion messages. This function would block if the
-// messenger is not yet started, so we fail any calls before startup.
+// hop payload range in onion messages. Multiple handlers may be registered
+// for the same tlv type, each of which will receive a copy of the payload.
+// The ID returned identifies the handler for de-registration. Handlers may be
+// registered before the messenger is started, and are used once it starts.
 func (m *Messenger) RegisterHandler(tlvType tlv.Type,
-	handler OnionMessageHandler) error {
+	handler OnionMessageHandler) (HandlerID, error) {
+
+	id := HandlerID(atomic.AddUint64(&m.nextHandlerID, 1))
+
+	request := newRegisterHandler(tlvType, id, handler, false)
+	if err := m.handleRegistration(request, "register"); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Deregister removes the handler with the ID provided for a specific tlv type.
+func (m *Messenger) DeregisterHandler(tlvType tlv.Type, id HandlerID) error {
+	request := newRegisterHandler(tlvType, id, nil, true)
+	return m.handleRegistration(request, "deregister")
+}
 
-	request := newRegisterHandler(tlvType, handler, false)
+// RegisterWildcardHandler connects a catch-all handler that receives every
+// final hop payload in onion messages addressed to our node, in addition to
+// any handlers registered for specific tlv types. Only one wildcard handler
+// may be registered at a time. The handler may be registered before the
+// messenger is started.
+func (m *Messenger) RegisterWildcardHandler(handler WildcardHandler) error {
+	request := newRegisterWildcardHandler(handler, false)
 	return m.handleRegistration(request, "register")
 }
 
-// Deregister removes the handler for a specific tlv type.
-func (m *Messenger) DeregisterHandler(tlvType tlv.Type) error {
-	request := newRegisterHandler(tlvType, nil, true)
+// DeregisterWildcardHandler removes our catch-all handler.
+func (m *Messenger) DeregisterWildcardHandler() error {
+	request := newRegisterWildcardHandler(nil, true)
 	return m.handleRegistration(request, "deregister")
 }
 
+// RegisterHandlerContext connects the handler provided to a tlv type in the
+// same way as RegisterHandler, and deregisters the handler when the context
+// provided is cancelled. This allows callers to bind the lifetime of a
+// handler to a request or stream, without needing to deregister it on every
+// exit path.
+func (m *Messenger) RegisterHandlerContext(ctx context.Context,
+	tlvType tlv.Type, handler OnionMessageHandler) (HandlerID, error) {
+
+	id, err := m.RegisterHandler(tlvType, handler)
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+
+		// All of our handlers are dropped on shutdown, so there's no
+		// need to deregister.
+		case <-m.quit:
+			return
+		}
+
+		// The handler may already have been removed by a call to
+		// DeregisterHandler or DeregisterAll, or we may have shut
+		// down in the meantime.
+		err := m.DeregisterHandler(tlvType, id)
+		if err != nil && !errors.Is(err, ErrHandlerNotFound) &&
+			!errors.Is(err, ErrShuttingDown) {
+
+			log.Errorf("Could not deregister handler %v (id: %v) "+
+				"on context cancel: %v", tlvType, id, err)
+		}
+	}()
+
+	return id, nil
+}
+
+// DeregisterAll removes all of the handlers that have been registered with
+// the messenger, including our wildcard handler. Handlers that the messenger
+// registers for its own use, such as our probe responder, are not removed.
+func (m *Messenger) DeregisterAll() error {
+	return m.handleRegistration(newDeregisterAll(), "deregister")
+}
+
 // handleRegistration manages handoff and response receipt with the main event
 // loop for (de)registration of handlers. An action string is provided to add
 // context to our logging (ie, indicate whether we're registering or
@@ -267,13 +899,31 @@ func (m *Messenger) DeregisterHandler(tlvType tlv.Type) error {
 func (m *Messenger) handleRegistration(request *registerHandler,
 	action string) error {
 
-	if err := lnwire.ValidateFinalPayload(request.tlvType); err != nil {
-		return fmt.Errorf("invalid payload: %w", err)
+	// Wildcard handlers aren't tied to a tlv type, so we only need to
+	// validate the type for regular handlers.
+	if !request.wildcard && !request.all {
+		err := lnwire.ValidateFinalPayload(request.tlvType)
+		if err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+	}
+
+	if atomic.LoadInt32(&m.stopped) == 1 {
+		return fmt.Errorf("%w: can't %v handler: %v",
+			ErrShuttingDown, action, request)
 	}
 
+	// If we haven't started yet, our main event loop isn't running to
+	// serialize registrations, so we apply the registration directly.
+	// Registered handlers will be used once we start to process
+	// messages.
 	if !m.hasStarted() {
-		return fmt.Errorf("%w: can't %v handler: %v", ErrNotStarted,
-			action, request.tlvType)
+		if err := m.registerHandler(request); err != nil {
+			return fmt.Errorf("%w: %v failed: %v", err, action,
+				request)
+		}
+
+		return nil
 	}
 
 	// Deliver the registration to the main event loop.
@@ -282,7 +932,7 @@ func (m *Messenger) handleRegistration(request *registerHandler,
 
 	case <-m.quit:
 		return fmt.Errorf("%w: could not %v: %v",
-			ErrShuttingDown, action, request.tlvType)
+			ErrShuttingDown, action, request)
 	}
 
 	// Wait for a response from the main loop, or exit if we're shutting
@@ -296,11 +946,52 @@ func (m *Messenger) handleRegistration(request *registerHandler,
 		}
 
 		return fmt.Errorf("%w: %v failed: %v", err, action,
-			request.tlvType)
+			request)
 
 	case <-m.quit:
 		return fmt.Errorf("%w: no %v response  %v",
-			ErrShuttingDown, action, request.tlvType)
+			ErrShuttingDown, action, request)
+	}
+}
+
+// SendProgress describes a stage of sending an onion message.
+type SendProgress uint8
+
+const (
+	// ProgressResolvingRoute indicates that we are looking for a path to
+	// the message's target.
+	ProgressResolvingRoute SendProgress = iota
+
+	// ProgressConnecting indicates that we could not deliver the message
+	// along a multi-hop path, and are making a direct connection to the
+	// target.
+	ProgressConnecting
+
+	// ProgressConnected indicates that our direct connection to the
+	// target is online.
+	ProgressConnected
+
+	// ProgressSent indicates that the message has been sent.
+	ProgressSent
+)
+
+// String returns the string representation of a send's progress.
+func (s SendProgress) String() string {
+	switch s {
+	case ProgressResolvingRoute:
+		return "resolving route"
+
+	case ProgressConnecting:
+		return "connecting"
+
+	case ProgressConnected:
+		return "connected"
+
+	case ProgressSent:
+		return "sent"
+
+	default:
+		return fmt.Sprintf("unknown send progress: %d", s)
 	}
 }
 
@@ -326,6 +1017,50 @@ type SendMessageRequest struct {
 	// DirectConnect indicates whether we should make a direct p2p
 	// connection to the target node.
 	DirectConnect bool
+
+	// AvoidNodes is an optional set of nodes that should not be used as
+	// intermediate hops when relaying the message to its target.
+	AvoidNodes []*btcec.PublicKey
+
+	// Route is an optional explicit route to send the message along,
+	// starting with the first hop and ending with the target node (or the
+	// introduction node of the blinded destination). If set, we don't
+	// look for a path or fall back to a direct send, and the message is
+	// sent along the route as given. This field and avoid nodes are
+	// mutually exclusive.
+	Route []*btcec.PublicKey
+
+	// DisconnectAfterSend indicates that we should disconnect from the
+	// target node after sending the message if we had to make a direct
+	// connection to deliver it. Connections made for these messages are
+	// never permanent, so that sending to many recipients does not leave
+	// us with idle connections.
+	DisconnectAfterSend bool
+
+	// Expiry is an optional deadline after which the message is no longer
+	// useful to its recipient, and should be discarded rather than
+	// delivered. If zero, the message does not expire.
+	Expiry time.Time
+
+	// OnProgress is an optional callback that is called as the send
+	// progresses, so that callers can report the status of sends that
+	// take a while (such as those that require a direct connection). It
+	// is called synchronously from the send, so it should not block.
+	// Callbacks are not persisted with messages that are queued in our
+	// outbox, and requests with callbacks are not coalesced.
+	OnProgress func(SendProgress)
+}
+
+// report calls the request's progress callback, if it has one.
+func (s *SendMessageRequest) report(progress SendProgress) {
+	if s.OnProgress != nil {
+		s.OnProgress(progress)
+	}
+}
+
+// expired returns a boolean indicating whether a request's expiry has passed.
+func (s *SendMessageRequest) expired(now time.Time) bool {
+	return !s.Expiry.IsZero() && !now.Before(s.Expiry)
 }
 
 // targetPeer returns the peer that we need to find a route to for an onion
@@ -357,6 +1092,28 @@ func (s *SendMessageRequest) Validate() error {
 		return ErrNoBlindedHops
 	}
 
+	if len(s.Route) != 0 {
+		if len(s.AvoidNodes) != 0 {
+			return fmt.Errorf("%w: avoid nodes can't be set",
+				ErrInvalidRoute)
+		}
+
+		if !pubkeyEqual(s.Route[len(s.Route)-1], s.targetPeer()) {
+			return fmt.Errorf("%w: route does not end at target",
+				ErrInvalidRoute)
+		}
+	}
+
+	types := make(map[tlv.Type]struct{}, len(s.FinalPayloads))
+	for _, payload := range s.FinalPayloads {
+		if _, ok := types[payload.TLVType]; ok {
+			return fmt.Errorf("%w: %v", ErrDuplicatePayload,
+				payload.TLVType)
+		}
+
+		types[payload.TLVType] = struct{}{}
+	}
+
 	return nil
 }
 
@@ -376,336 +1133,1341 @@ func NewSendMessageRequest(destination *btcec.PublicKey, blindedDestination,
 
 // SendMessage sends an onion message to the peer provided. The message can
 // optionally include a reply path for the recipient to use for replies and
-// payloads for the final hop. If we cannot find a path to the peer and the
-// direct connect param is true, we will make a direct connection to the peer
-// to send the message.
+// payloads for the final hop. We first try to deliver the message along a
+// multi-hop path to the peer, trying other candidate paths if sending fails,
+// and fall back to sending the message directly to the peer if no path is
+// found or sending along each of our paths fails. If we are not
+// already connected to the peer and the direct connect param is true, we will
+// make a direct p2p connection to the peer to send the message.
+//
+// If an outbox is enabled and the destination is unreachable, the message is
+// queued for later delivery and no error is returned. Messages with an expiry
+// are not sent, or retried from our outbox, once their expiry has passed.
 func (m *Messenger) SendMessage(ctx context.Context,
 	req *SendMessageRequest) error {
 
-	sessionKey, err := btcec.NewPrivateKey()
-	if err != nil {
-		return fmt.Errorf("could not get session key: %w", err)
+	return m.sendOrQueue(ctx, req, m.pathFinder)
+}
+
+// sendOrQueue makes a single attempt to deliver an onion message using the
+// path finder provided, queueing the message in our outbox if it is enabled
+// and the destination is unreachable.
+func (m *Messenger) sendOrQueue(ctx context.Context, req *SendMessageRequest,
+	pathFinder PathFinder) error {
+
+	err := m.sendMessage(ctx, req, pathFinder)
+	if err == nil || m.outbox == nil || !unreachable(err) {
+		return err
+	}
+
+	// There's no point in queueing a message that has expired while we
+	// were trying to deliver it.
+	if req.expired(time.Now()) {
+		return fmt.Errorf("%w: %v", ErrMessageExpired, err)
+	}
+
+	log.Infof("Onion message undeliverable, queuing: %v", err)
+
+	if err := m.queueMessage(req); err != nil {
+		return fmt.Errorf("could not queue message: %w", err)
 	}
 
-	blindingKey, err := btcec.NewPrivateKey()
+	return nil
+}
+
+// sendMessage makes a single attempt to deliver an onion message, using the
+// path finder provided to find a multi-hop path to its target.
+func (m *Messenger) sendMessage(ctx context.Context, req *SendMessageRequest,
+	pathFinder PathFinder) error {
+
+	ctx, done, err := m.trackSend(ctx)
 	if err != nil {
-		return fmt.Errorf("could not get blinding key: %w", err)
+		return err
 	}
+	defer done()
+
+	// If our message has an expiry, we don't try to deliver it after the
+	// expiry has passed, and bound our delivery attempt by it so that we
+	// don't deliver it late after waiting on peers.
+	if !req.Expiry.IsZero() {
+		if req.expired(time.Now()) {
+			return fmt.Errorf("%w: at %v", ErrMessageExpired,
+				req.Expiry)
+		}
 
-	// Select a path for the onion message and directly connect to the peer
-	// if requested.
-	var (
-		path   []*btcec.PublicKey
-		target = req.targetPeer()
-	)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, req.Expiry)
+		defer cancel()
+	}
 
-	if !req.DirectConnect {
-		path, err = multiHopPath(ctx, m.lnd, target)
+	// If we sign our payloads, we add our authentication record to a
+	// copy of our request so that the caller's request is not modified.
+	if m.payloadSigner != nil && len(req.FinalPayloads) != 0 {
+		req, err = signPayloads(ctx, m.payloadSigner, req)
 		if err != nil {
-			return fmt.Errorf("could not find path to %v: %w",
-				target, err)
-		}
-	} else {
-		if err := m.lookupAndConnect(ctx, target); err != nil {
-			return fmt.Errorf("lookup and connect: %w", err)
+			return err
 		}
 
-		path = []*btcec.PublicKey{
-			target,
+		if err := req.Validate(); err != nil {
+			return err
 		}
 	}
 
-	// Check whether we have a path to the target peer (this will always
-	// pass for direct connect, but may fail for multi-hop if no route was
-	// found).
-	if len(path) == 0 {
-		return fmt.Errorf("%w: %v", ErrNoPath, target)
+	// If we are the introduction node for the blinded destination, we
+	// can't route to ourselves so we skip over our own hop(s) in the
+	// blinded route. We copy our request so that we don't mutate the
+	// caller's destination.
+	if req.BlindedDestination != nil {
+		dest, err := m.skipOwnIntroduction(req.BlindedDestination)
+		if err != nil {
+			return fmt.Errorf("blinded destination: %w", err)
+		}
+
+		reqCopy := *req
+		reqCopy.BlindedDestination = dest
+		req = &reqCopy
 	}
 
-	log.Infof("Onion message to: %x to be delivered via: %x along: %v hops",
-		target.SerializeCompressed(),
-		path[0].SerializeCompressed(), len(path))
+	// If we have an explicit route, we send along it as given. We check
+	// its target again in case we skipped over our own node in the
+	// blinded destination, since the route no longer reaches it.
+	if len(req.Route) != 0 {
+		last := req.Route[len(req.Route)-1]
+		if !pubkeyEqual(last, req.targetPeer()) {
+			return fmt.Errorf("%w: route does not end at target",
+				ErrInvalidRoute)
+		}
 
-	// Create a request to produce a blinded path and generate a blinded
-	pathRequest := routes.NewBlindedRouteRequest(
-		sessionKey, blindingKey, path, req.ReplyPath,
-		req.BlindedDestination, req.FinalPayloads,
-	)
+		if err := m.sendAlongPath(ctx, req, req.Route); err != nil {
+			return fmt.Errorf("explicit route: %w", err)
+		}
 
-	pathResponse, err := routes.CreateBlindedRoute(pathRequest)
-	if err != nil {
-		return fmt.Errorf("create blinded route: %w", err)
+		req.report(ProgressSent)
+		return nil
 	}
 
-	// Finally, convert this onion message to a custom message so that we
-	// can sent it via lnd's custom message API.
-	msg, err := customOnionMessage(
-		pathResponse.FirstNode, pathResponse.OnionMessage,
+	var (
+		target   = req.targetPeer()
+		sendErrs []error
 	)
-	if err != nil {
-		return fmt.Errorf("could not create custom message: %w", err)
-	}
 
-	return m.lnd.SendCustomMessage(ctx, *msg)
-}
+	// First, try to deliver our message along a multi-hop path to the
+	// target peer. If sending along a path fails, we avoid its first hop
+	// and look for another candidate path, up to maxPathAttempts paths.
+	// We don't fail on errors here, because we still want to try our
+	// fallback.
+	req.report(ProgressResolvingRoute)
+	avoid := req.AvoidNodes
+	for attempt := 0; attempt < maxPathAttempts; attempt++ {
+		path, err := pathFinder.FindPath(ctx, target, avoid)
+		if err != nil {
+			sendErrs = append(sendErrs, fmt.Errorf("could not "+
+				"find path to %v: %w", target, err))
 
-// lookupAndConnect checks whether we have a connection with a peer, and  looks
-// it up in the graph and makes a connection if we're not already connected.
-func (m *Messenger) lookupAndConnect(ctx context.Context,
-	peer *btcec.PublicKey) error {
+			break
+		}
 
-	// If we're already peered with the node, exit early.
-	isPeer, err := m.findPeer(ctx, peer)
-	if err != nil {
-		return fmt.Errorf("find peer: %w", err)
-	}
+		if len(path) == 0 {
+			break
+		}
 
-	if isPeer {
-		return nil
-	}
+		err = m.sendAlongPath(ctx, req, path)
+		if err == nil {
+			req.report(ProgressSent)
+			return nil
+		}
 
-	vertex := route.NewVertex(peer)
-	info, err := m.lnd.GetNodeInfo(ctx, vertex, false)
-	if err != nil {
-		return fmt.Errorf("could not lookup node: %w", err)
-	}
+		log.Warnf("Onion message to: %x along: %v hops failed: %v",
+			target.SerializeCompressed(), len(path), err)
 
-	if len(info.Addresses) == 0 {
-		return fmt.Errorf("%w: %v", ErrNoAddresses, peer)
-	}
+		// Our cached path may be stale, so we look it up again on our
+		// next attempt.
+		targetVertex := route.NewVertex(target)
+		m.InvalidateGraphCache(&targetVertex)
 
-	// Make a permanent connection to the peer so that they don't get
-	// pruned because we don't have a channel with them.
-	err = m.lnd.Connect(ctx, vertex, info.Addresses[0], true)
-	if err != nil {
-		return fmt.Errorf("could not connect to peer: %w", err)
+		sendErrs = append(sendErrs, fmt.Errorf("multi-hop: %w", err))
+
+		// If our path was direct to the target, our fallback will
+		// retry it, so there's no other path to try.
+		if pubkeyEqual(path[0], target) {
+			break
+		}
+
+		// Our send fails when we can't deliver the message to the
+		// first hop, so we avoid it when looking for our next path.
+		avoid = append(
+			append([]*btcec.PublicKey(nil), avoid...), path[0],
+		)
 	}
 
-	// It takes some time for our peer to connect, so we
-	for i := 0; i < m.lookupPeerAttempts; i++ {
-		isPeer, err := m.findPeer(ctx, peer)
-		if err != nil {
-			return fmt.Errorf("find peer: %v", err)
+	// If we could not deliver our message along a multi-hop path, fall
+	// back to sending it directly to the target peer.
+	isPeer, transient, err := m.directPeer(
+		ctx, target, req.DirectConnect, !req.DisconnectAfterSend,
+		req.report,
+	)
+	switch {
+	case err != nil:
+		sendErrs = append(sendErrs, fmt.Errorf("direct: %w", err))
+
+	case isPeer:
+		err := m.sendAlongPath(ctx, req, []*btcec.PublicKey{target})
+
+		// If we connected to the peer just to deliver this message,
+		// we disconnect regardless of the outcome, unless we send to
+		// the peer frequently enough for our connection manager to
+		// hold the connection open. We don't fail our send if we
+		// can't disconnect, since the message may have been
+		// delivered.
+		vertex := route.NewVertex(target)
+		frequent := req.DirectConnect &&
+			m.connManager.recordSend(vertex)
+
+		if transient && req.DisconnectAfterSend &&
+			!(frequent && m.connManager.keep(vertex)) {
+
+			m.disconnect(ctx, target)
 		}
 
-		if isPeer {
+		if err == nil {
+			req.report(ProgressSent)
 			return nil
 		}
 
-		// If we're not yet peered with the node, back off (or exit
-		// if ctx is canceled).
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
+		sendErrs = append(sendErrs, fmt.Errorf("direct: %w", err))
+	}
 
-		case <-time.After(m.lookupPeerBackoff):
-			continue
-		}
+	// If we didn't have any paths to try, we fail with no path.
+	if len(sendErrs) == 0 {
+		return fmt.Errorf("%w: %v", ErrNoPath, target)
 	}
 
-	return ErrNoConnection
+	return fmt.Errorf("could not send to: %x: %w",
+		target.SerializeCompressed(), errors.Join(sendErrs...))
 }
 
-// findPeer looks for a peer's pubkey in our list of online peers.
-func (m *Messenger) findPeer(ctx context.Context, peer *btcec.PublicKey) (bool,
-	error) {
+// skipOwnIntroduction advances a blinded destination past any leading hops
+// that belong to our own node, by decrypting the data for our hop to find the
+// next node in the route and its blinding point. The destination is returned
+// unchanged if our node is not its introduction node.
+func (m *Messenger) skipOwnIntroduction(dest *lnwire.ReplyPath) (
+	*lnwire.ReplyPath, error) {
 
-	peers, err := m.lnd.ListPeers(ctx)
-	if err != nil {
-		return false, fmt.Errorf("list peers: %w", err)
-	}
+	var (
+		ourPubkey = m.nodeKeyECDH.PubKey().SerializeCompressed()
+		decrypt   = decryptBlobFunc(m.nodeKeyECDH)
+	)
 
-	// If we're already peers, we can just return early.
-	for _, currentPeer := range peers {
+	for bytes.Equal(dest.FirstNodeID.SerializeCompressed(), ourPubkey) {
+		// If our hop is the last one in the route, the message is
+		// addressed to ourselves.
+		if len(dest.Hops) < 2 {
+			return nil, ErrSelfDestination
+		}
+
+		data, err := decrypt(
+			dest.BlindingPoint, &lnwire.OnionMessagePayload{
+				EncryptedData: dest.Hops[0].EncryptedData,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("introduction node: %w", err)
+		}
+
+		nextNode, err := m.nextNodeID(data)
+		if err != nil {
+			return nil, fmt.Errorf("introduction node: %w", err)
+		}
+
+		nextBlinding := data.NextBlindingOverride
+		if nextBlinding == nil {
+			nextBlinding, err = sphinx.NextEphemeral(
+				m.nodeKeyECDH, dest.BlindingPoint,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("next ephemeral: %w",
+					err)
+			}
+		}
+
+		log.Infof("Skipping our node as introduction node, sending "+
+			"to: %x", nextNode.SerializeCompressed())
+
+		dest = &lnwire.ReplyPath{
+			FirstNodeID:   nextNode,
+			BlindingPoint: nextBlinding,
+			Hops:          dest.Hops[1:],
+		}
+	}
+
+	return dest, nil
+}
+
+// directPeer returns a boolean indicating whether we are directly connected
+// to the target peer. If connect is true, we will make a connection to the
+// peer if we are not already connected, and the second boolean returned
+// indicates whether we made a new non-permanent connection. The progress of
+// any connection we make is reported to the function provided.
+func (m *Messenger) directPeer(ctx context.Context, target *btcec.PublicKey,
+	connect, permanent bool, report func(SendProgress)) (bool, bool,
+	error) {
+
+	if !connect {
+		isPeer, err := m.findPeer(ctx, target)
+		if err != nil {
+			return false, false, fmt.Errorf("find peer: %w", err)
+		}
+
+		return isPeer, false, nil
+	}
+
+	transient, err := m.lookupAndConnect(ctx, target, permanent, report)
+	if err != nil {
+		return false, false, fmt.Errorf("lookup and connect: %w", err)
+	}
+
+	return true, transient, nil
+}
+
+// disconnect disconnects from a peer that we connected to for the purpose of
+// sending a message. Failures are logged rather than returned because the
+// connection is no longer required.
+func (m *Messenger) disconnect(ctx context.Context, peer *btcec.PublicKey) {
+	err := m.transport.Disconnect(ctx, route.NewVertex(peer))
+	if err != nil {
+		log.Warnf("Could not disconnect from: %x: %v",
+			peer.SerializeCompressed(), err)
+
+		return
+	}
+
+	log.Debugf("Disconnected from: %x after sending onion message",
+		peer.SerializeCompressed())
+}
+
+// sendAlongPath creates an onion message along the path provided and sends it
+// to the first hop in the path.
+func (m *Messenger) sendAlongPath(ctx context.Context, req *SendMessageRequest,
+	path []*btcec.PublicKey) error {
+
+	msg, err := m.pathMessage(req, path)
+	if err != nil {
+		return err
+	}
+
+	err = m.sendCustomMessage(ctx, *msg)
+	m.metrics.MessageSent(msg.Peer, err)
+
+	return err
+}
+
+// pathMessage creates an onion message along the path provided, returning the
+// custom message that delivers it to the first hop in the path.
+func (m *Messenger) pathMessage(req *SendMessageRequest,
+	path []*btcec.PublicKey) (*lndclient.CustomMessage, error) {
+
+	// Check our route length before we do any work to build the onion.
+	// If we're sending to a blinded destination, our path ends at its
+	// introduction node which is the first hop in the blinded route.
+	hops := len(path)
+	if req.BlindedDestination != nil {
+		hops += len(req.BlindedDestination.Hops) - 1
+	}
+
+	if err := m.validateHopCount(hops); err != nil {
+		return nil, err
+	}
+
+	sessionKey, blindingKey, err := m.sessionKeys.SessionKeys(req, path)
+	if err != nil {
+		return nil, fmt.Errorf("could not get session keys: %w", err)
+	}
+
+	log.Infof("Onion message to: %x to be delivered via: %x along: %v hops",
+		req.targetPeer().SerializeCompressed(),
+		path[0].SerializeCompressed(), len(path))
+
+	// Create a request to produce a blinded path and generate a blinded
+	pathRequest := routes.NewBlindedRouteRequest(
+		sessionKey, blindingKey, path, req.ReplyPath,
+		req.BlindedDestination, req.FinalPayloads,
+	)
+
+	pathResponse, err := routes.CreateBlindedRoute(pathRequest)
+	if err != nil {
+		return nil, fmt.Errorf("create blinded route: %w", err)
+	}
+
+	// Finally, convert this onion message to a custom message so that we
+	// can sent it via lnd's custom message API.
+	msg, err := customOnionMessage(
+		pathResponse.FirstNode, m.onionMsgType,
+		pathResponse.OnionMessage,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create custom message: %w",
+			err)
+	}
+
+	return msg, nil
+}
+
+// validateHopCount checks that a route with the number of hops provided does
+// not exceed our maximum route length.
+func (m *Messenger) validateHopCount(hops int) error {
+	if hops > m.maxHops {
+		return fmt.Errorf("%w: %v hops, maximum %v",
+			routes.ErrTooManyHops, hops, m.maxHops)
+	}
+
+	return nil
+}
+
+// lookupAndConnect checks whether we have a connection with a peer, and  looks
+// it up in the graph and makes a connection if we're not already connected.
+// The boolean returned indicates whether we made a new non-permanent
+// connection to the peer, which may be disconnected once it is no longer
+// required. If permanent is false, lnd will not maintain the connection
+// unless we have previously connected to the peer permanently, in which case
+// we restore the permanent connection. The progress of our connection is
+// reported to the function provided.
+func (m *Messenger) lookupAndConnect(ctx context.Context,
+	peer *btcec.PublicKey, permanent bool,
+	report func(SendProgress)) (bool, error) {
+
+	// If we're already peered with the node, exit early.
+	isPeer, err := m.findPeer(ctx, peer)
+	if err != nil {
+		return false, fmt.Errorf("find peer: %w", err)
+	}
+
+	if isPeer {
+		return false, nil
+	}
+
+	vertex := route.NewVertex(peer)
+	info, err := m.graph.GetNodeInfo(ctx, vertex, false)
+	if err != nil {
+		return false, fmt.Errorf("could not lookup node: %w", err)
+	}
+
+	if len(info.Addresses) == 0 {
+		return false, fmt.Errorf("%w: %v", ErrNoAddresses, peer)
+	}
+
+	// There's no point in connecting to a peer that won't be able to
+	// handle our onion message, since it will just be dropped.
+	if !lnwire.SupportsOnionMessages(info.Features) {
+		return false, fmt.Errorf("%w: %x", ErrPeerNoOnionSupport,
+			peer.SerializeCompressed())
+	}
+
+	// Subscribe to peer events before we connect so that we can't miss
+	// our peer's online event. If we can't subscribe, we fall back to
+	// polling for the peer.
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, errs, err := m.transport.SubscribePeerEvents(subCtx)
+	if err != nil {
+		log.Warnf("Could not subscribe to peer events, polling for "+
+			"peer: %v", err)
+
+		events, errs = nil, nil
+	}
+
+	// Unless we're making a transient connection, make a permanent
+	// connection to the peer so that they don't get pruned because we
+	// don't have a channel with them. If we previously connected to the
+	// peer permanently, we restore that connection rather than
+	// downgrading it.
+	permanent = permanent || m.isPermanentPeer(vertex)
+
+	report(ProgressConnecting)
+	err = m.transport.Connect(ctx, vertex, info.Addresses[0], permanent)
+	if err != nil {
+		return false, fmt.Errorf("could not connect to peer: %w", err)
+	}
+
+	if permanent {
+		m.peersLock.Lock()
+		m.permanentPeers[vertex] = struct{}{}
+		m.peersLock.Unlock()
+	}
+
+	// It takes some time for our peer to connect, so we wait for it
+	// until our lookup deadline passes.
+	deadline := time.After(m.lookupPeerTimeout)
+	if err := m.waitForPeer(ctx, peer, events, errs, deadline); err != nil {
+		return false, err
+	}
+
+	report(ProgressConnected)
+
+	return !permanent, nil
+}
+
+// waitForPeer waits for a peer that we have dialed to come online using the
+// peer event subscription provided. If the subscription is nil or fails, we
+// fall back to polling for the peer.
+func (m *Messenger) waitForPeer(ctx context.Context, peer *btcec.PublicKey,
+	events <-chan *lnrpc.PeerEvent, errs <-chan error,
+	deadline <-chan time.Time) error {
+
+	if events == nil {
+		return m.pollForPeer(ctx, peer, deadline)
+	}
+
+	// Our peer may have come online before our subscription was
+	// established, so we check our peer list once before waiting.
+	isPeer, err := m.findPeer(ctx, peer)
+	if err != nil {
+		return fmt.Errorf("find peer: %v", err)
+	}
+
+	if isPeer {
+		return nil
+	}
+
+	target := route.NewVertex(peer).String()
+	for {
+		select {
+		case event, ok := <-events:
+			// If our events channel is closed, our error channel
+			// will deliver the reason.
+			if !ok {
+				events = nil
+				continue
+			}
+
+			if event.PubKey == target &&
+				event.Type == lnrpc.PeerEvent_PEER_ONLINE {
+
+				return nil
+			}
+
+		case err := <-errs:
+			log.Warnf("Peer event subscription failed, polling "+
+				"for peer: %v", err)
+
+			return m.pollForPeer(ctx, peer, deadline)
+
+		case <-deadline:
+			return ErrNoConnection
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// pollForPeer polls lnd's peer list with an exponential backoff until the
+// peer provided is connected, our lookup attempts are exhausted or the
+// deadline provided passes.
+func (m *Messenger) pollForPeer(ctx context.Context, peer *btcec.PublicKey,
+	deadline <-chan time.Time) error {
+
+	for i := 0; i < m.lookupPeerAttempts; i++ {
+		isPeer, err := m.findPeer(ctx, peer)
+		if err != nil {
+			return fmt.Errorf("find peer: %v", err)
+		}
+
+		if isPeer {
+			return nil
+		}
+
+		// If we're not yet peered with the node, back off (or exit
+		// if ctx is canceled or we've reached our deadline).
+		backoff := jitteredBackoff(
+			m.lookupPeerBackoff, m.lookupPeerMaxBackoff, i,
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-deadline:
+			return ErrNoConnection
+
+		case <-time.After(backoff):
+			continue
+		}
+	}
+
+	return ErrNoConnection
+}
+
+// isPermanentPeer returns a boolean indicating whether we have previously
+// made a permanent connection to the peer.
+func (m *Messenger) isPermanentPeer(peer route.Vertex) bool {
+	m.peersLock.Lock()
+	defer m.peersLock.Unlock()
+
+	_, ok := m.permanentPeers[peer]
+
+	return ok
+}
+
+// findPeer looks for a peer's pubkey in our list of online peers.
+func (m *Messenger) findPeer(ctx context.Context, peer *btcec.PublicKey) (bool,
+	error) {
+
+	peers, err := m.transport.ListPeers(ctx)
+	if err != nil {
+		return false, fmt.Errorf("list peers: %w", err)
+	}
+
+	// If we're already peers, we can just return early.
+	for _, currentPeer := range peers {
 		if currentPeer.Pubkey == route.NewVertex(peer) {
 			return true, nil
 		}
-	}
+	}
+
+	return false, nil
+}
+
+// queryRoutesRequest creates a query routes request for finding onion message
+// multi-hop paths.
+func queryRoutesRequest(peer *btcec.PublicKey) lndclient.QueryRoutesRequest {
+	return lndclient.QueryRoutesRequest{
+		PubKey: route.NewVertex(peer),
+		// We disable mission control because we don't care about
+		// the liquidity in these channels, just that they exist.
+		UseMissionControl: false,
+		// We set our query amount to 1 sat, because we just want to
+		// be able to route along _any_ channel. This may fall under
+		// some channels minimum msat (that we could route), but many
+		// nodes operate with default parameters so it shouldn't be
+		// _too_ problematic.
+		AmtMsat: lndwire.MilliSatoshi(1000),
+		// Set a very large fee limit because we won't actually pay
+		// any fees for onion messages and want to include the maximum
+		// set of channels possible.
+		FeeLimitMsat: lndwire.MaxMilliSatoshi,
+	}
+}
+
+// multiHopPath finds a path from our node to the target that can be used
+// to relay onion messages. If no path is found, a nil path will be returned.
+// Since query routes does not allow us to exclude nodes, paths that use any of
+// the nodes to avoid as intermediate hops, or intermediate hops that do not
+// advertise onion message support, are discarded and a nil path is returned.
+//
+// TODO: Add a PathFinder that walks the graph, query routes is a lazy drop-in
+// solution to get onion messaging paths based on the channel graph.
+func multiHopPath(ctx context.Context, graph Graph, peer *btcec.PublicKey,
+	avoid []*btcec.PublicKey) ([]*btcec.PublicKey, error) {
+
+	return queryPath(ctx, graph, peer, nil, avoid)
+}
+
+// queryPath finds a path to the target using query routes, optionally
+// restricting the last hop before the target, and discards paths that are
+// not suitable for relaying onion messages as described in multiHopPath.
+func queryPath(ctx context.Context, graph Graph, peer *btcec.PublicKey,
+	lastHop *route.Vertex, avoid []*btcec.PublicKey) ([]*btcec.PublicKey,
+	error) {
+
+	req := queryRoutesRequest(peer)
+	req.LastHop = lastHop
+
+	resp, err := graph.QueryRoutes(ctx, req)
+	switch err {
+	// If we can't find any routes, return a nil path.
+	case lndclient.ErrNoRouteFound:
+		return nil, nil
+
+	case nil:
+		path := make([]*btcec.PublicKey, len(resp.Hops))
+
+		for i, hop := range resp.Hops {
+			if hop.PubKey == nil {
+				return nil, ErrNilPubkeyInRoute
+			}
+
+			path[i], err = btcec.ParsePubKey(hop.PubKey[:])
+			if err != nil {
+				return nil, fmt.Errorf("hop: %v parse "+
+					"pubkey: %w", i, err)
+			}
+		}
+
+		if hop := avoidedHop(path, avoid); hop != nil {
+			log.Infof("Path to: %x discarded, relays via avoided "+
+				"node: %x", peer.SerializeCompressed(),
+				hop.SerializeCompressed())
+
+			return nil, nil
+		}
+
+		// Routing through nodes that don't support onion messages
+		// means that our message will be dropped, so we discard any
+		// paths that don't support relaying.
+		hop, err := unsupportedHop(ctx, graph, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if hop != nil {
+			log.Infof("Path to: %x discarded, hop: %x does not "+
+				"support onion messages",
+				peer.SerializeCompressed(),
+				hop.SerializeCompressed())
+
+			return nil, nil
+		}
+
+		return path, nil
+
+	default:
+		return nil, fmt.Errorf("query routes failed: %w", err)
+	}
+}
+
+// unsupportedHop returns the first intermediate hop in a path that does not
+// advertise onion message support, or nil if all hops support relaying onion
+// messages. The final hop in the path is our target, so it is not considered.
+func unsupportedHop(ctx context.Context, graph Graph,
+	path []*btcec.PublicKey) (*btcec.PublicKey, error) {
+
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	for _, hop := range path[:len(path)-1] {
+		ok, err := supportsOnionMessages(ctx, graph, hop)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return hop, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// supportsOnionMessages looks up a node in the graph and returns a boolean
+// indicating whether it advertises onion message support. Nodes that are not
+// found in the graph are considered to not support onion messages.
+func supportsOnionMessages(ctx context.Context, graph Graph,
+	node *btcec.PublicKey) (bool, error) {
+
+	nodeInfo, err := graph.GetNodeInfo(
+		ctx, route.NewVertex(node), false,
+	)
+	if err != nil {
+		status, ok := status.FromError(err)
+		if ok && status.Code() == codes.NotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("get node: %x: %w",
+			node.SerializeCompressed(), err)
+	}
+
+	if nodeInfo.Node == nil {
+		return false, nil
+	}
+
+	return lnwire.SupportsOnionMessages(nodeInfo.Features), nil
+}
+
+// avoidedHop returns the first intermediate hop in a path that is in the set of
+// nodes to avoid, or nil if the path does not use any of them. The final hop
+// in the path is our target, so it is not considered.
+func avoidedHop(path, avoid []*btcec.PublicKey) *btcec.PublicKey {
+	if len(avoid) == 0 || len(path) == 0 {
+		return nil
+	}
+
+	avoidSet := make(map[route.Vertex]struct{}, len(avoid))
+	for _, node := range avoid {
+		avoidSet[route.NewVertex(node)] = struct{}{}
+	}
+
+	for _, hop := range path[:len(path)-1] {
+		if _, ok := avoidSet[route.NewVertex(hop)]; ok {
+			return hop
+		}
+	}
+
+	return nil
+}
+
+// manageOnionMessages consumes onion messages from lnd's custom message
+// stream and handles them. If our subscription to lnd fails, we will try to
+// resubscribe with backoff, only exiting with an error if we fail to
+// re-establish a working subscription after repeated attempts.
+func (m *Messenger) manageOnionMessages(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Signal that we'll no longer queue incoming messages when we exit
+	// or start draining, whichever happens first.
+	var stopIntake sync.Once
+	defer stopIntake.Do(func() {
+		close(m.intakeDone)
+	})
+
+	msgChan, errChan, cancelSub, err := m.subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Wrap our cancel in a closure so that we cancel the latest
+	// subscription on exit.
+	defer func() {
+		cancelSub()
+	}()
+
+	var (
+		// failures is the number of consecutive times that our
+		// subscription has failed without delivering a message or
+		// staying up for our healthy interval.
+		failures int
+
+		// resubscribe is set when our subscription has failed and
+		// fires when we should try to resubscribe.
+		resubscribe <-chan time.Time
+
+		// healthy is set when we have resubscribed, and fires once
+		// the new subscription has stayed up long enough for us to
+		// consider it healthy. This prevents a quiet node that sees
+		// occasional stream errors from accumulating failures across
+		// successful resubscriptions.
+		healthy <-chan time.Time
+
+		// draining is set to nil once we have stopped consuming
+		// messages so that we only do so once.
+		draining = m.draining
+	)
+
+	for {
+		var streamErr error
+
+		select {
+		// Handling incoming requests to add/remove final payload tlv
+		// handlers.
+		case request := <-m.handlerRegistration:
+			err := m.registerHandler(request)
+			// Return an error on failure, or close the error
+			// channel to indicate that we've successfully
+			// completed.
+			if err != nil {
+				request.errChan <- err
+			} else {
+				close(request.errChan)
+			}
+
+		case msg, ok := <-msgChan:
+			// If our message channel has been closed, the stream
+			// has exited.
+			if !ok {
+				streamErr = fmt.Errorf("%w: messages",
+					ErrLNDShutdown)
+
+				break
+			}
+
+			// A message has been delivered, so our subscription is
+			// healthy.
+			failures, healthy = 0, nil
+
+			// Skip over all non-onion messages.
+			if !m.acceptsMsgType(msg.MsgType) {
+				continue
+			}
+
+			m.stats.received(msg.Peer)
+			m.metrics.MessageReceived(msg.Peer)
+
+			// Drop messages from peers that have tripped our
+			// breaker, so that they don't use up our queue.
+			if !m.breaker.allow(msg.Peer) {
+				log.Debugf("Breaker tripped, dropping onion "+
+					"message from: %v", msg.Peer)
+				m.stats.breakerDropped(msg.Peer)
+				m.metrics.MessageDropped(
+					msg.Peer, DropReasonBreaker,
+				)
+
+				continue
+			}
+
+			// Hand the message off to our worker pool so that a
+			// slow handler or forward does not block consumption
+			// of messages from lnd.
+			m.enqueueIncoming(msg)
+			m.metrics.QueueDepth(
+				len(m.incoming), len(m.forwardQueue),
+			)
+
+		case err, ok := <-errChan:
+			// If our error channel has been closed, the stream
+			// has exited.
+			if !ok {
+				streamErr = fmt.Errorf("%w: message errors",
+					ErrLNDShutdown)
+
+				break
+			}
+
+			streamErr = fmt.Errorf("message subscription failed: %w",
+				err)
+
+		// When we start draining, we stop consuming new messages from
+		// lnd but continue to serve handler (de)registrations until
+		// we quit.
+		case <-draining:
+			draining, resubscribe, healthy = nil, nil, nil
+			cancelSub()
+			msgChan, errChan = nil, nil
+
+			stopIntake.Do(func() {
+				close(m.intakeDone)
+			})
+
+		case <-resubscribe:
+			resubscribe = nil
+
+			log.Infof("Resubscribing to custom messages, attempt: "+
+				"%v", failures)
+
+			msgChan, errChan, cancelSub, err = m.subscribe(ctx)
+			if err != nil {
+				streamErr = fmt.Errorf("resubscribe: %w", err)
+				break
+			}
+
+			healthy = time.After(m.resubscribeHealthy)
+
+		case <-healthy:
+			healthy = nil
+
+			log.Debugf("Custom message subscription healthy after "+
+				"%v failures", failures)
+
+			failures = 0
+
+		case <-m.quit:
+			return ErrShuttingDown
+		}
+
+		if streamErr == nil {
+			continue
+		}
+
+		// If our subscription has failed, cancel it and stop
+		// listening on its channels.
+		cancelSub()
+		msgChan, errChan, healthy = nil, nil, nil
+
+		failures++
+		if failures > m.resubscribeAttempts {
+			return streamErr
+		}
+
+		backoff := m.resubscribeBackoff * time.Duration(1<<(failures-1))
+		log.Errorf("Custom message subscription failed: %v, "+
+			"resubscribing in: %v", streamErr, backoff)
+
+		resubscribe = time.After(backoff)
+	}
+}
+
+// acceptsMsgType returns a boolean indicating whether we accept onion
+// messages with the custom message type provided.
+func (m *Messenger) acceptsMsgType(msgType uint32) bool {
+	if msgType == m.onionMsgType {
+		return true
+	}
+
+	for _, accept := range m.acceptMsgTypes {
+		if msgType == accept {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subscribe subscribes to lnd's custom message stream, returning a cancel
+// function that will terminate the subscription.
+func (m *Messenger) subscribe(ctx context.Context) (
+	<-chan lndclient.CustomMessage, <-chan error, func(), error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	msgChan, errChan, err := m.transport.SubscribeCustomMessages(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, func() {}, err
+	}
+
+	return msgChan, errChan, cancel, nil
+}
+
+// enqueueIncoming adds an incoming onion message to our inbound queue without
+// blocking, applying our drop policy if the queue is full. This function
+// must only be called from our main event loop, since it relies on being the
+// only writer to the queue.
+func (m *Messenger) enqueueIncoming(msg lndclient.CustomMessage) {
+	select {
+	case m.incoming <- msg:
+		return
+
+	default:
+	}
+
+	// If we want to keep the newest messages, remove the oldest message
+	// from our queue (if our workers have not emptied it in the meantime)
+	// so that we have space for the incoming one.
+	if m.dropPolicy == DropOldest {
+		select {
+		case oldest := <-m.incoming:
+			m.dropIncoming(oldest)
+
+		default:
+		}
+
+		// Since we're the only writer to the queue, we're guaranteed
+		// to have space now.
+		m.incoming <- msg
+
+		return
+	}
+
+	m.dropIncoming(msg)
+}
+
+// dropIncoming records that an incoming onion message has been dropped.
+func (m *Messenger) dropIncoming(msg lndclient.CustomMessage) {
+	dropped := atomic.AddUint64(&m.dropped, 1)
+	m.stats.queueDropped(msg.Peer)
+	m.metrics.MessageDropped(msg.Peer, DropReasonQueueFull)
+
+	log.Warnf("Inbound queue full (%v), dropped onion message from: %v, "+
+		"total dropped: %v", m.dropPolicy, msg.Peer, dropped)
+}
+
+// DroppedMessages returns the number of incoming onion messages that have
+// been dropped because our inbound queue was full.
+func (m *Messenger) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+// handleIncoming consumes onion messages that have been handed off by our
+// main event loop and processes them. Multiple instances of this function
+// are run to process messages concurrently.
+func (m *Messenger) handleIncoming() {
+	defer m.wg.Done()
+	defer m.handlerWg.Done()
+
+	// Just log failures for individual onion messages, since we don't
+	// want one malformed message to send us down.
+	handle := func(msg lndclient.CustomMessage) {
+		err := m.handleMessage(msg)
+		m.breaker.record(msg.Peer, err)
+		m.stats.processed(msg.Peer, err)
+
+		if err != nil {
+			m.metrics.MessageDropped(msg.Peer, DropReasonFailed)
+			logMessageErr(msg, err)
+		}
+	}
+
+	for {
+		select {
+		case msg := <-m.incoming:
+			handle(msg)
+
+		// Once our main loop has stopped queueing messages, we
+		// process any messages remaining in our queue and exit.
+		case <-m.intakeDone:
+			for {
+				select {
+				case msg := <-m.incoming:
+					handle(msg)
+
+				case <-m.quit:
+					return
+
+				default:
+					return
+				}
+			}
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// decodePayload decodes an onion message payload with our default decoding
+// options.
+func decodePayload(b []byte) (*lnwire.OnionMessagePayload, error) {
+	return lnwire.DecodeOnionMessagePayload(b)
+}
+
+// handleMessage processes a single incoming onion message.
+func (m *Messenger) handleMessage(msg lndclient.CustomMessage) error {
+	handlers, stats, wildcard := m.handlerSnapshot()
+
+	// Forwards are attributed to the peer that sent us the message, so
+	// that failures to forward it onward count towards its breaker.
+	forward := func(data *lnwire.BlindedRouteData,
+		blinding *btcec.PublicKey, packet *sphinx.OnionPacket) error {
+
+		return m.forwardMessage(msg.Peer, data, blinding, packet)
+	}
+
+	kit := &onionMessageKit{
+		processOnion:    m.processOnion,
+		decodePayload:   decodePayload,
+		handlers:        timedHandlers(handlers, stats, m.metrics),
+		wildcard:        wildcard,
+		decryptDataBlob: decryptBlobFunc(m.nodeKeyECDH),
+		forwardMessage:  forward,
+		verifyPathID:    m.verifyPathID,
+
+		incomingInterceptors: m.incomingInterceptors,
+		dispatchInterceptors: m.dispatchInterceptors,
+
+		unhandled: func(tlv.Type) {
+			m.stats.unhandled(msg.Peer)
+			m.metrics.MessageDropped(msg.Peer, DropReasonNoHandler)
+		},
+	}
+
+	// We only need to check incoming messages for replies if we're
+	// waiting on any.
+	if m.awaitingReplies() {
+		kit.handleReply = m.deliverReply
+	}
+
+	return handleOnionMessage(msg, kit)
+}
+
+// logMessageErr logs the failure to handle an individual onion message.
+func logMessageErr(msg lndclient.CustomMessage, err error) {
+	switch FailureKindOf(err) {
+	// Don't error out on invalid messages (it allows peers to send us
+	// junk to shut us down), just log.
+	case FailureDecode, FailureBlinding, FailurePayload:
+		log.Errorf("Processing failed for onion packet from: %v: %v",
+			msg.Peer, err)
+
+	// Replays are expected if peers re-send messages, so we only note
+	// that we dropped them.
+	case FailureReplay:
+		log.Debugf("Dropped replayed onion message from: %v",
+			msg.Peer)
+
+	// Messages rejected by our interceptors, or that we decline to relay
+	// in endpoint-only mode, are dropped by operator policy so they are
+	// not processing failures. Other policy drops are still of interest,
+	// because they may indicate that a peer is probing our paths.
+	case FailurePolicyDrop:
+		if errors.Is(err, ErrIntercepted) ||
+			errors.Is(err, ErrForwardingDisabled) {
+
+			log.Debugf("Dropped onion message from: %v: %v",
+				msg.Peer, err)
+
+			return
+		}
 
-	return false, nil
+		log.Errorf("Processing failed for onion packet from: %v: %v",
+			msg.Peer, err)
+
+	// Log any other errors, since a single bad message should not shut
+	// us down.
+	default:
+		log.Errorf("Onion message from: %v failed: %v", msg.Peer, err)
+	}
 }
 
-// queryRoutesRequest creates a query routes request for finding onion message
-// multi-hop paths.
-func queryRoutesRequest(peer *btcec.PublicKey) lndclient.QueryRoutesRequest {
-	return lndclient.QueryRoutesRequest{
-		PubKey: route.NewVertex(peer),
-		// We disable mission control because we don't care about
-		// the liquidity in these channels, just that they exist.
-		UseMissionControl: false,
-		// We set our query amount to 1 sat, because we just want to
-		// be able to route along _any_ channel. This may fall under
-		// some channels minimum msat (that we could route), but many
-		// nodes operate with default parameters so it shouldn't be
-		// _too_ problematic.
-		AmtMsat: lndwire.MilliSatoshi(1000),
-		// Set a very large fee limit because we won't actually pay
-		// any fees for onion messages and want to include the maximum
-		// set of channels possible.
-		FeeLimitMsat: lndwire.MaxMilliSatoshi,
+// handlerSnapshot returns a copy of our current set of handlers, so that
+// messages can be handled without holding our handler lock.
+func (m *Messenger) handlerSnapshot() (map[tlv.Type][]OnionMessageHandler,
+	map[tlv.Type]*handlerStats, WildcardHandler) {
+
+	m.handlerLock.RLock()
+	defer m.handlerLock.RUnlock()
+
+	var (
+		handlers = make(
+			map[tlv.Type][]OnionMessageHandler,
+			len(m.onionMsgHandlers),
+		)
+		stats = make(
+			map[tlv.Type]*handlerStats, len(m.handlerStats),
+		)
+	)
+	for tlvType, registered := range m.onionMsgHandlers {
+		typeHandlers := make([]OnionMessageHandler, len(registered))
+		for i, entry := range registered {
+			typeHandlers[i] = entry.handler
+		}
+
+		handlers[tlvType] = typeHandlers
+		stats[tlvType] = m.handlerStats[tlvType]
 	}
+
+	return handlers, stats, m.wildcardHandler
 }
 
-// multiHopPath finds a path from our node to the target that can be used
-// to relay onion messages. If no path is found, a nil path will be returned.
-//
-// TODO: Replace use of query routes with a graph walk, this is a lazy drop-in
-// solution to get onion messaging paths based on the channel graph.
-func multiHopPath(ctx context.Context, lnd LndOnionMsg, peer *btcec.PublicKey) (
-	[]*btcec.PublicKey, error) {
+// registerHandler adds and removes handlers from the messenger.
+func (m *Messenger) registerHandler(request *registerHandler) error {
+	m.handlerLock.Lock()
+	defer m.handlerLock.Unlock()
 
-	resp, err := lnd.QueryRoutes(ctx, queryRoutesRequest(peer))
-	switch err {
-	// If we can't find any routes, return a nil path.
-	case lndclient.ErrNoRouteFound:
-		return nil, nil
+	switch {
+	case request.all:
+		m.deregisterAll()
+		return nil
 
-	case nil:
-		path := make([]*btcec.PublicKey, len(resp.Hops))
+	case request.wildcard:
+		return m.registerWildcardHandler(request)
+	}
 
-		for i, hop := range resp.Hops {
-			if hop.PubKey == nil {
-				return nil, ErrNilPubkeyInRoute
-			}
+	registered := m.onionMsgHandlers[request.tlvType]
 
-			path[i], err = btcec.ParsePubKey(hop.PubKey[:])
-			if err != nil {
-				return nil, fmt.Errorf("hop: %v parse "+
-					"pubkey: %w", i, err)
-			}
+	// If we're registering, add the handler after any others that are
+	// registered for this type and return with a nil error.
+	if !request.deregister {
+		if err := m.claimType(request); err != nil {
+			return err
 		}
 
-		return path, nil
+		m.onionMsgHandlers[request.tlvType] = append(
+			registered, &typedHandler{
+				id:       request.id,
+				handler:  request.handler,
+				internal: request.internal,
+			},
+		)
 
-	default:
-		return nil, fmt.Errorf("query routes failed: %w", err)
+		if _, ok := m.handlerStats[request.tlvType]; !ok {
+			m.handlerStats[request.tlvType] = &handlerStats{}
+		}
+
+		return nil
 	}
-}
 
-// manageOnionMessages consumes onion messages from lnd's custom message
-// stream and handles them.
-func (m *Messenger) manageOnionMessages(ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	// If we're deregistering, fail if we don't have a handler with the
+	// ID provided. Otherwise remove the handler and return without error.
+	for i, entry := range registered {
+		if entry.id != request.id {
+			continue
+		}
 
-	msgChan, errChan, err := m.lnd.SubscribeCustomMessages(ctx)
-	if err != nil {
-		return err
-	}
+		// Copy the remaining handlers into a new slice, because
+		// snapshots of the existing slice may be in use.
+		remaining := make([]*typedHandler, 0, len(registered)-1)
+		remaining = append(remaining, registered[:i]...)
+		remaining = append(remaining, registered[i+1:]...)
 
-	for {
-		select {
-		// Handling incoming requests to add/remove final payload tlv
-		// handlers.
-		case request := <-m.handlerRegistration:
-			err := m.registerHandler(request)
-			// Return an error on failure, or close the error
-			// channel to indicate that we've successfully
-			// completed.
-			if err != nil {
-				request.errChan <- err
-			} else {
-				close(request.errChan)
-			}
+		m.releaseType(request.tlvType, entry)
 
-		case msg, ok := <-msgChan:
-			// If our message channel has been closed, the stream
-			// has exited.
-			if !ok {
-				return fmt.Errorf("%w: messages", ErrLNDShutdown)
-			}
+		if len(remaining) == 0 {
+			delete(m.onionMsgHandlers, request.tlvType)
+			delete(m.handlerStats, request.tlvType)
+		} else {
+			m.onionMsgHandlers[request.tlvType] = remaining
+		}
 
-			// Skip over all non-onion messages.
-			if msg.MsgType != lnwire.OnionMessageType {
-				continue
-			}
+		return nil
+	}
 
-			// Just log failures for individual onion messages,
-			// since we don't want one malformed message to send
-			// us down.
-			err := handleOnionMessage(
-				msg, &onionMessageKit{
-					processOnion:  m.processOnion,
-					decodePayload: lnwire.DecodeOnionMessagePayload,
-					handlers:      m.onionMsgHandlers,
-					decryptDataBlob: decryptBlobFunc(
-						m.nodeKeyECDH,
-					),
-					forwardMessage: m.forwardMessage,
-				},
-			)
-			if err == nil {
+	return fmt.Errorf("%w: %v", ErrHandlerNotFound, request)
+}
+
+// deregisterAll removes all of our handlers apart from those that we have
+// registered internally. This function must be called under handlerLock.
+func (m *Messenger) deregisterAll() {
+	handlers := make(map[tlv.Type][]*typedHandler)
+	for tlvType, registered := range m.onionMsgHandlers {
+		for _, entry := range registered {
+			if !entry.internal {
+				m.releaseType(tlvType, entry)
 				continue
 			}
 
-			// Try to unwrap our error to match it against our
-			// various typed errors. If the error is not wrapped,
-			// Unwrap will return nil, in which case we match
-			// against the original error.
-			upwrappedErr := errors.Unwrap(err)
-			if upwrappedErr == nil {
-				upwrappedErr = err
-			}
+			handlers[tlvType] = append(handlers[tlvType], entry)
+		}
+	}
 
-			// Handle the non-nil error accordingly, we've already
-			// managed the nil case above.
-			switch upwrappedErr {
-			// Don't error out on invalid messages (it allows peers
-			// to send us junk to shut us down), just log.
-			// TODO: possibly penalize bad messages in future?
-			case ErrBadMessage, ErrBadOnionMsg, ErrBadOnionBlob:
-				log.Errorf("Processing failed for onion "+
-					"packet from: %v: %v", msg.Peer, err)
-
-			// Log any other errors, since a single bad message
-			// should not shut us down.
-			default:
-				log.Errorf("Onion message from: %v failed: %v",
-					msg.Peer, err)
-			}
+	// We only keep the stats of types that still have handlers.
+	for tlvType := range m.handlerStats {
+		if _, ok := handlers[tlvType]; !ok {
+			delete(m.handlerStats, tlvType)
+		}
+	}
 
-		case err, ok := <-errChan:
-			// If our error channel has been closed, the stream
-			// has exited.
-			if !ok {
-				return fmt.Errorf("%w: message errors",
-					ErrLNDShutdown)
-			}
+	// We replace our map rather than modifying the existing slices,
+	// because snapshots of them may be in use.
+	m.onionMsgHandlers = handlers
+	m.wildcardHandler = nil
+}
 
-			return fmt.Errorf("message subscription failed: %w",
-				err)
+// claimType claims the tlv type of a handler that is being registered in our
+// type registry, if we have one. Handlers that we register internally are
+// for our built in types, so they don't need to be claimed.
+func (m *Messenger) claimType(request *registerHandler) error {
+	if m.typeRegistry == nil || request.internal {
+		return nil
+	}
 
-		case <-m.quit:
-			return ErrShuttingDown
-		}
+	return m.typeRegistry.Claim(
+		request.tlvType, m.handlerOwner(request.id),
+	)
+}
+
+// releaseType releases the claim that a handler has on its tlv type in our
+// type registry, if we have one.
+func (m *Messenger) releaseType(tlvType tlv.Type, entry *typedHandler) {
+	if m.typeRegistry == nil || entry.internal {
+		return
+	}
+
+	err := m.typeRegistry.Release(tlvType, m.handlerOwner(entry.id))
+	if err != nil {
+		log.Errorf("Could not release tlv type %v for handler %v: %v",
+			tlvType, entry.id, err)
 	}
 }
 
-// registerHandler adds and removes handlers from the messenger.
-func (m *Messenger) registerHandler(request *registerHandler) error {
-	_, ok := m.onionMsgHandlers[request.tlvType]
+// handlerOwner returns the owner that a handler's claims are registered
+// under in our type registry. We include our messenger in the owner, because
+// handler IDs are only unique within a messenger and registries may be
+// shared.
+func (m *Messenger) handlerOwner(id HandlerID) string {
+	return fmt.Sprintf("messenger %p handler %v", m, id)
+}
 
-	// If we're deregistering, fail if we don't have a handler for the
-	// tlv type. Otherwise remove the handler and return without error.
+// registerWildcardHandler adds and removes our catch-all handler. This
+// function must be called under handlerLock.
+func (m *Messenger) registerWildcardHandler(request *registerHandler) error {
 	if request.deregister {
-		if !ok {
+		if m.wildcardHandler == nil {
 			return fmt.Errorf("%w: %v", ErrHandlerNotFound,
-				request.tlvType)
+				request)
 		}
 
-		delete(m.onionMsgHandlers, request.tlvType)
+		m.wildcardHandler = nil
 		return nil
 	}
 
-	// If we're registering a type that already has a handler, fail
-	// registration.
-	if ok {
-		return fmt.Errorf("%w: %v", ErrHandlerRegistered,
-			request.tlvType)
+	if m.wildcardHandler != nil {
+		return fmt.Errorf("%w: %v", ErrHandlerRegistered, request)
 	}
 
-	// Otherwise, just add the handler and return with a nil error.
-	m.onionMsgHandlers[request.tlvType] = request.handler
+	m.wildcardHandler = request.wildcardHandler
 
 	return nil
 }
@@ -728,10 +2490,16 @@ func (m *Messenger) processOnion(data []byte) (*btcec.PublicKey,
 		return nil, nil, fmt.Errorf("%w:%v", ErrBadOnionBlob, err)
 	}
 
+	m.routerLock.Lock()
 	processed, err := m.router.ProcessOnionPacket(
 		onionPkt, nil, 0,
 		sphinx.WithBlindingPoint(onionMsg.BlindingPoint),
 	)
+	m.routerLock.Unlock()
+	if errors.Is(err, sphinx.ErrReplayedPacket) {
+		return nil, nil, ErrReplayedMessage
+	}
+
 	if err != nil {
 		return nil, nil, fmt.Errorf("process packet: %w", err)
 	}
@@ -739,12 +2507,28 @@ func (m *Messenger) processOnion(data []byte) (*btcec.PublicKey,
 	return onionMsg.BlindingPoint, processed, nil
 }
 
-// forwardMessage forwards an onion packet to the next node provided.
-func (m *Messenger) forwardMessage(data *lnwire.BlindedRouteData,
-	blindingPoint *btcec.PublicKey, onionPacket *sphinx.OnionPacket) error {
+// forwardRequest is an onion message that is queued for forwarding.
+type forwardRequest struct {
+	// msg is the onion message to send to the next node.
+	msg lndclient.CustomMessage
 
-	if data.NextNodeID == nil {
-		return ErrNoNextNodeID
+	// from is the peer that sent us the onion message, which failures
+	// to forward the message are attributed to.
+	from route.Vertex
+}
+
+// forwardMessage queues an onion packet that we received from the peer
+// provided for forwarding to the next node. If our forwarding queue is full,
+// the message is dropped rather than blocking processing of incoming
+// messages. Packets that are addressed to our own node (dummy hops) are
+// processed directly.
+func (m *Messenger) forwardMessage(from route.Vertex,
+	data *lnwire.BlindedRouteData, blindingPoint *btcec.PublicKey,
+	onionPacket *sphinx.OnionPacket) error {
+
+	nextNode, err := m.nextNodeID(data)
+	if err != nil {
+		return err
 	}
 
 	nextBlinding, err := sphinx.NextEphemeral(m.nodeKeyECDH, blindingPoint)
@@ -774,22 +2558,197 @@ func (m *Messenger) forwardMessage(data *lnwire.BlindedRouteData,
 	}
 
 	customMsg := lndclient.CustomMessage{
-		Peer:    route.NewVertex(data.NextNodeID),
-		MsgType: lnwire.OnionMessageType,
+		Peer:    route.NewVertex(nextNode),
+		MsgType: m.onionMsgType,
 		Data:    buf.Bytes(),
 	}
 
+	// If the next node is our own node, this is a dummy hop in a blinded
+	// route to us, so we process the next packet ourselves rather than
+	// sending it to lnd. We process it as if it was sent by our
+	// original peer, so that any failures are attributed to it.
+	if nextNode.IsEqual(m.nodeKeyECDH.PubKey()) {
+		log.Debugf("Processing dummy hop onion message, next "+
+			"blinding: %x", nextBlinding.SerializeCompressed())
+
+		customMsg.Peer = from
+
+		return m.handleMessage(customMsg)
+	}
+
+	if m.endpointOnly {
+		return fmt.Errorf("%w: dropping message for: %v",
+			ErrForwardingDisabled, customMsg.Peer)
+	}
+
 	log.Infof("Forwarding onion message to: %v, next blinding: %x",
 		customMsg.Peer, nextBlinding.SerializeCompressed())
 
-	err = m.lnd.SendCustomMessage(context.Background(), customMsg)
-	if err != nil {
-		return fmt.Errorf("could not send message: %w", err)
+	fwd := forwardRequest{
+		msg:  customMsg,
+		from: from,
+	}
+
+	select {
+	case m.forwardQueue <- fwd:
+		m.metrics.QueueDepth(len(m.incoming), len(m.forwardQueue))
+		return nil
+
+	default:
+		return fmt.Errorf("%w: dropping message for: %v",
+			ErrForwardQueueFull, customMsg.Peer)
+	}
+}
+
+// forwardMessages consumes onion messages from our forwarding queue and sends
+// them to the next node in their route.
+func (m *Messenger) forwardMessages() {
+	defer m.wg.Done()
+	defer close(m.forwardsDrained)
+
+	// Cancel any in-flight sends when we shut down.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-m.quit:
+			cancel()
+
+		case <-ctx.Done():
+		}
+	}()
+
+	forward := func(fwd forwardRequest) {
+		msg := fwd.msg
+
+		err := m.forwardCustomMessage(ctx, msg)
+		m.stats.forwarded(msg.Peer, err)
+		m.metrics.MessageForwarded(msg.Peer, err)
+
+		if err == nil {
+			return
+		}
+
+		log.Errorf("Could not forward onion message to: %v: %v",
+			msg.Peer, err)
+
+		// Failures that are due to our own limits, configuration or
+		// shutdown aren't a fault of the peer that sent us the
+		// message, so we only record onward send failures.
+		if errors.Is(err, ErrForwardRateLimited) ||
+			errors.Is(err, ErrCustomMessageOverride) ||
+			ctx.Err() != nil {
+
+			return
+		}
+
+		m.breaker.record(fwd.from, newProcessingError(
+			FailureForward, fwd.from, err,
+		))
+	}
+
+	for {
+		select {
+		case fwd := <-m.forwardQueue:
+			forward(fwd)
+
+		// Once our handler workers have exited, no more messages
+		// will be queued for forwarding, so we forward any messages
+		// remaining in our queue and exit.
+		case <-m.handlersDrained:
+			for {
+				select {
+				case fwd := <-m.forwardQueue:
+					forward(fwd)
+
+				case <-m.quit:
+					return
+
+				default:
+					return
+				}
+			}
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// validateRelayData validates the payload and decrypted data for a hop that
+// we are relaying an onion message for. Final hop payloads are checked
+// before we decrypt our data, so they are not checked here.
+func validateRelayData(payload *lnwire.OnionMessagePayload,
+	data *lnwire.BlindedRouteData) error {
+
+	// Relaying hops may only contain encrypted data.
+	if payload.ReplyPath != nil {
+		return ErrRelayReplyPath
+	}
+
+	// A path ID is only included in data for the final hop, so data that
+	// includes one for a relay was not created by the sender of the path
+	// (or is probing it).
+	if data.PathID != nil {
+		return ErrRelayPathID
+	}
+
+	if data.NextNodeID == nil && data.NextSCID == nil {
+		return ErrNoNextNodeID
 	}
 
 	return nil
 }
 
+// nextNodeID returns the next node in a blinded route. If the route's data
+// identifies the next node by short channel id rather than node id, we look
+// up the channel in lnd's graph and return our peer in the channel.
+func (m *Messenger) nextNodeID(data *lnwire.BlindedRouteData) (
+	*btcec.PublicKey, error) {
+
+	if data.NextNodeID != nil {
+		return data.NextNodeID, nil
+	}
+
+	if data.NextSCID == nil {
+		return nil, ErrNoNextNodeID
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), resolveSCIDTimeout,
+	)
+	defer cancel()
+
+	edge, err := m.graph.GetChanInfo(ctx, data.NextSCID.ToUint64())
+	if err != nil {
+		return nil, fmt.Errorf("could not lookup channel: %v: %w",
+			data.NextSCID, err)
+	}
+
+	ourNode := route.NewVertex(m.nodeKeyECDH.PubKey())
+
+	var peer route.Vertex
+	switch ourNode {
+	case edge.Node1:
+		peer = edge.Node2
+
+	case edge.Node2:
+		peer = edge.Node1
+
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrSCIDNotOurs, data.NextSCID)
+	}
+
+	nextNode, err := btcec.ParsePubKey(peer[:])
+	if err != nil {
+		return nil, fmt.Errorf("could not parse channel peer: %v: %w",
+			peer, err)
+	}
+
+	return nextNode, nil
+}
+
 // onionMessageKit contains the dependencies required to process onion messages.
 type onionMessageKit struct {
 	// processOnion provides the ability to process incoming onion messages.
@@ -807,38 +2766,93 @@ type onionMessageKit struct {
 		error)
 
 	// handlers is a set of handler functions for onion messages that are
-	// addressed to our node. It registers one handler per final hop payload
-	// tlv namespace that will be executed when we receive an onion message
-	// with that payload polulated.
-	handlers map[tlv.Type]OnionMessageHandler
+	// addressed to our node. It registers a list of handlers per final hop
+	// payload tlv namespace that will each be executed when we receive an
+	// onion message with that payload polulated.
+	handlers map[tlv.Type][]OnionMessageHandler
+
+	// wildcard is an optional catch-all handler that is executed for
+	// every final hop payload addressed to our node, before the handler
+	// registered for the payload's tlv type (if any).
+	wildcard WildcardHandler
+
+	// unhandled is an optional function that is called for each final
+	// hop payload addressed to our node that we have no handler for.
+	unhandled func(tlvType tlv.Type)
 
 	// forwardMessage forwards an onion message to the next peer in the
 	// route.
 	forwardMessage func(data *lnwire.BlindedRouteData,
 		blindingPoint *btcec.PublicKey,
 		nextPacket *sphinx.OnionPacket) error
+
+	// handleReply is an optional function that consumes messages that
+	// are replies to messages we sent, identified by the data in our
+	// final hop's encrypted data. It returns a boolean indicating whether
+	// the message was consumed as a reply.
+	handleReply func(data *lnwire.BlindedRouteData,
+		payload *lnwire.OnionMessagePayload) bool
+
+	// verifyPathID is an optional function that checks that the path ID
+	// in our final hop's encrypted data was created by our node, failing
+	// if it was not.
+	verifyPathID func(pathID []byte) error
+
+	// incomingInterceptors is an optional chain of interceptors that
+	// each message is passed through before it is decrypted.
+	incomingInterceptors []IncomingInterceptor
+
+	// dispatchInterceptors is an optional chain of interceptors that
+	// messages addressed to our node are passed through before they are
+	// delivered as replies or to our handlers.
+	dispatchInterceptors []DispatchInterceptor
 }
 
 // handleOnionMessage extracts onion messages from custom messages received from
 // lnd. An onion message kit containing the processing functions and handlers
-// required is passed in to facilitate easy unit testing.
+// required is passed in to facilitate easy unit testing. All errors returned
+// are classified as a *ProcessingError.
 func handleOnionMessage(msg lndclient.CustomMessage,
 	kit *onionMessageKit) error {
 
 	log.Infof("Received onion message from peer: %v", msg.Peer)
 
-	blinding, processedPacket, err := kit.processOnion(msg.Data)
+	err := interceptIncoming(kit.incomingInterceptors, msg)
 	if err != nil {
-		return fmt.Errorf("%w: could not process onion packet: %v",
-			ErrBadOnionBlob, err)
+		return newProcessingError(FailurePolicyDrop, msg.Peer, err)
+	}
+
+	blinding, processedPacket, err := kit.processOnion(msg.Data)
+	switch {
+	// Replayed messages are dropped so that they are not re-delivered to
+	// our handlers or re-forwarded.
+	case errors.Is(err, ErrReplayedMessage):
+		return newProcessingError(FailureReplay, msg.Peer, err)
+
+	// If we couldn't decode the message or its onion, it's malformed.
+	case errors.Is(err, ErrBadMessage), errors.Is(err, ErrBadOnionBlob):
+		return newProcessingError(FailureDecode, msg.Peer, fmt.Errorf(
+			"%w: could not decode onion packet: %v",
+			ErrBadOnionBlob, err,
+		))
+
+	// Otherwise, we couldn't decrypt the onion with the blinding point
+	// provided.
+	case err != nil:
+		return newProcessingError(FailureBlinding, msg.Peer, fmt.Errorf(
+			"%w: could not process onion packet: %v",
+			ErrBadOnionBlob, err,
+		))
 	}
 
 	// Decode the TLV stream in our payload.
 	payloadBytes := processedPacket.Payload.Payload
 	payload, err := kit.decodePayload(payloadBytes)
 	if err != nil {
-		return fmt.Errorf("%w: could not process payload: %v",
-			ErrBadOnionBlob, err)
+		return newProcessingError(FailurePayload, msg.Peer, fmt.Errorf(
+			"%w: could not process payload: %v", ErrBadOnionBlob,
+			err,
+		))
 	}
 
 	switch processedPacket.Action {
@@ -847,40 +2861,129 @@ func handleOnionMessage(msg lndclient.CustomMessage,
 		log.Infof("Onion message %v from: %v is for us!", payload,
 			msg.Peer)
 
+		err := interceptDispatch(
+			kit.dispatchInterceptors, msg.Peer, payload,
+		)
+		if err != nil {
+			return newProcessingError(
+				FailurePolicyDrop, msg.Peer, err,
+			)
+		}
+
+		// If the message was sent over a blinded path, we check the
+		// path ID that we included in the path (if any) and whether
+		// it is a reply to a message that we sent.
+		checkData := kit.handleReply != nil || kit.verifyPathID != nil
+		if checkData && len(payload.EncryptedData) != 0 {
+			data, err := kit.decryptDataBlob(blinding, payload)
+			if err != nil {
+				return newProcessingError(
+					FailureBlinding, msg.Peer, fmt.Errorf(
+						"could not decrypt data "+
+							"blob: %w", err,
+					),
+				)
+			}
+
+			// Reject messages that claim to use one of our paths
+			// but carry a path ID that we did not create.
+			hasPathID := len(data.PathID) != 0
+			if kit.verifyPathID != nil && hasPathID {
+				err := kit.verifyPathID(data.PathID)
+				if err != nil {
+					return newProcessingError(
+						FailurePolicyDrop, msg.Peer,
+						err,
+					)
+				}
+			}
+
+			// Replies are delivered to the sender that is waiting
+			// for them, rather than our handlers.
+			isReply := kit.handleReply != nil &&
+				kit.handleReply(data, payload)
+
+			if isReply {
+				log.Infof("Onion message from: %v delivered "+
+					"as reply", msg.Peer)
+
+				return nil
+			}
+		}
+
 		// If we have no handlers registered, then we can't do anything
 		// else with this message.
-		if kit.handlers == nil {
+		if kit.handlers == nil && kit.wildcard == nil {
 			log.Info("No handlers registered, skipping %v final "+
 				"hop payloads", len(payload.FinalHopPayloads))
 
+			if kit.unhandled != nil {
+				for _, final := range payload.FinalHopPayloads {
+					kit.unhandled(final.TLVType)
+				}
+			}
+
 			return nil
 		}
 
-		// For each of our final hop payloads, identify a handling
-		// function (if any) and handoff the payload.
+		// For each of our final hop payloads, identify our handling
+		// functions (if any) and hand off the payload to each of them.
+		// Handlers are isolated from each other's failures, so we
+		// deliver the payload to all of them before reporting errors.
+		var handlerErrs []error
 		for _, extraData := range payload.FinalHopPayloads {
-			handler, ok := kit.handlers[extraData.TLVType]
-			if !ok {
+			// handlerErr records a handler failure for this
+			// payload's tlv type.
+			tlvType := extraData.TLVType
+			handlerErr := func(err error) {
+				handlerErrs = append(handlerErrs,
+					newTLVProcessingError(
+						FailureHandler, msg.Peer,
+						tlvType, err,
+					))
+			}
+
+			if kit.wildcard != nil {
+				if err := kit.wildcard(
+					extraData.TLVType, payload.ReplyPath,
+					payload.EncryptedData, extraData.Value,
+				); err != nil {
+					handlerErr(fmt.Errorf("wildcard "+
+						"handler: %w", err))
+				}
+			}
+
+			handlers := kit.handlers[extraData.TLVType]
+			if len(handlers) == 0 {
 				log.Debugf("Final tlv: %v / %x unhandled",
 					extraData.TLVType, extraData.Value)
 
+				// Payloads that our wildcard handler has
+				// received are not dropped.
+				if kit.wildcard == nil && kit.unhandled != nil {
+					kit.unhandled(extraData.TLVType)
+				}
+
 				continue
 			}
 
-			log.Debugf("Handing off TLV: %v / %w to handler",
-				extraData.TLVType, extraData.Value)
-
-			if err := handler(
-				payload.ReplyPath, payload.EncryptedData,
-				extraData.Value,
-			); err != nil {
-				return fmt.Errorf("handler for: %v/%x "+
-					"failed: %w", extraData.TLVType,
-					extraData.Value, err)
+			log.Debugf("Handing off TLV: %v / %x to %v handlers",
+				extraData.TLVType, extraData.Value,
+				len(handlers))
+
+			for i, handler := range handlers {
+				if err := handler(
+					payload.ReplyPath,
+					payload.EncryptedData,
+					extraData.Value,
+				); err != nil {
+					handlerErr(fmt.Errorf("handler %v: "+
+						"%w", i, err))
+				}
 			}
 		}
 
-		return nil
+		return errors.Join(handlerErrs...)
 
 	// We don't support forwarding at present, so we fail if an onion with
 	// more hops is received.
@@ -892,29 +2995,55 @@ func handleOnionMessage(msg lndclient.CustomMessage,
 		// much of an issue when we're not forwarding messages anyway,
 		// but may need to be removed in future.
 		if len(payload.FinalHopPayloads) != 0 {
-			return fmt.Errorf("%w: %v unexpected final hop "+
-				"payloads", ErrFinalPayload,
-				len(payload.FinalHopPayloads))
+			return newTLVProcessingError(
+				FailurePolicyDrop, msg.Peer,
+				payload.FinalHopPayloads[0].TLVType,
+				fmt.Errorf("%w: %v unexpected final hop "+
+					"payloads", ErrFinalPayload,
+					len(payload.FinalHopPayloads)),
+			)
 		}
 
 		if processedPacket.NextPacket == nil {
-			return ErrNoForwardingOnion
+			return newProcessingError(
+				FailureForward, msg.Peer, ErrNoForwardingOnion,
+			)
 		}
 
 		data, err := kit.decryptDataBlob(blinding, payload)
 		if err != nil {
-			return fmt.Errorf("could not decrypt data blob: %w",
-				err)
+			return newProcessingError(FailureBlinding, msg.Peer,
+				fmt.Errorf("could not decrypt data blob: %w",
+					err))
 		}
 
-		return kit.forwardMessage(
+		if err := validateRelayData(payload, data); err != nil {
+			return newProcessingError(
+				FailurePolicyDrop, msg.Peer, err,
+			)
+		}
+
+		err = kit.forwardMessage(
 			data, blinding, processedPacket.NextPacket,
 		)
+		switch {
+		// If we've declined to relay the message, it's a matter of our
+		// own policy rather than a failure to forward.
+		case errors.Is(err, ErrForwardingDisabled):
+			return newProcessingError(
+				FailurePolicyDrop, msg.Peer, err,
+			)
+
+		case err != nil:
+			return newProcessingError(FailureForward, msg.Peer, err)
+		}
+
+		return nil
 
 	// If we encounter a sphinx failure, just log the error and ignore the
 	// packet.
 	case sphinx.Failure:
-		return ErrBadMessage
+		return newProcessingError(FailureDecode, msg.Peer, ErrBadMessage)
 	}
 
 	return nil
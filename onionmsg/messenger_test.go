@@ -1,20 +1,27 @@
 package onionmsg
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/routes"
 	"github.com/gijswijs/boltnd/testutils"
 	"github.com/lightninglabs/lndclient"
 	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/lightningnetwork/lnd/tlv"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type sendMessageTest struct {
@@ -27,6 +34,10 @@ type sendMessageTest struct {
 	// send the message.
 	directConnect bool
 
+	// disconnectAfterSend indicates whether we should disconnect from
+	// the peer if we had to connect to it to send the message.
+	disconnectAfterSend bool
+
 	// peerLookups is the number of times that we lookup our peer after
 	// connecting.
 	peerLookups int
@@ -38,6 +49,14 @@ type sendMessageTest struct {
 	setMock func(*mock.Mock)
 }
 
+// mockPollPeers primes our mock to fail subscribing to peer events, so that
+// we fall back to polling for peers that we connect to.
+func mockPollPeers(m *mock.Mock) {
+	testutils.MockSubscribePeerEvents(
+		m, nil, nil, errors.New("peer events unavailable"),
+	)
+}
+
 // TestSendMessage tests sending of onion messages using lnd's custom message
 // api.
 func TestSendMessage(t *testing.T) {
@@ -60,6 +79,17 @@ func TestSendMessage(t *testing.T) {
 		}
 
 		nodeInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Addresses: []string{
+					nodeAddr,
+				},
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+
+		noOnionNodeInfo = &lndclient.NodeInfo{
 			Node: &lndclient.Node{
 				Addresses: []string{
 					nodeAddr,
@@ -70,6 +100,37 @@ func TestSendMessage(t *testing.T) {
 		listPeersErr = errors.New("listpeers failed")
 		getNodeErr   = errors.New("get node failed")
 		connectErr   = errors.New("connect failed")
+		sendErr      = errors.New("send failed")
+
+		disconnectErr = errors.New("disconnect failed")
+
+		multiHopResp = &lndclient.QueryRoutesResponse{
+			Hops: []*lndclient.Hop{
+				{
+					PubKey: &node1,
+				},
+				{
+					PubKey: &node2,
+				},
+			},
+		}
+
+		// relayInfo is the node announcement for our intermediate
+		// hop, advertising onion message support.
+		relayInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+
+		mockNoRoute = func(m *mock.Mock) {
+			testutils.MockQueryRoutes(
+				m, queryRoutesRequest(pubkeys[0]),
+				&lndclient.QueryRoutesResponse{}, nil,
+			)
+		}
 	)
 
 	tests := []sendMessageTest{
@@ -80,6 +141,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   5,
 			expectedErr:   nil,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We are already connected to the peer.
 				testutils.MockListPeers(m, peerList, nil)
 
@@ -94,6 +158,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   5,
 			expectedErr:   listPeersErr,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				testutils.MockListPeers(m, nil, listPeersErr)
 			},
 		},
@@ -104,6 +171,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   5,
 			expectedErr:   getNodeErr,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We have no peers at present.
 				testutils.MockListPeers(m, nil, nil)
 
@@ -121,6 +191,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   5,
 			expectedErr:   ErrNoAddresses,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We have no peers at present.
 				testutils.MockListPeers(m, nil, nil)
 
@@ -131,13 +204,35 @@ func TestSendMessage(t *testing.T) {
 				)
 			},
 		},
+		{
+			name:          "failure - peer has no onion support",
+			peer:          pubkeys[0],
+			directConnect: true,
+			peerLookups:   5,
+			expectedErr:   ErrPeerNoOnionSupport,
+			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
 
+				// We have no peers at present.
+				testutils.MockListPeers(m, nil, nil)
+
+				// Peer lookup succeeds, but the peer does not
+				// advertise onion message support.
+				testutils.MockGetNodeInfo(
+					m, pubkey, false, noOnionNodeInfo, nil,
+				)
+			},
+		},
 		{
 			name:          "failure - could not connect to peer",
 			peer:          pubkeys[0],
 			directConnect: true,
 			expectedErr:   connectErr,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We have no peers at present.
 				testutils.MockListPeers(m, nil, nil)
 
@@ -147,6 +242,7 @@ func TestSendMessage(t *testing.T) {
 				)
 
 				// Try to connect to the address provided, fail.
+				mockPollPeers(m)
 				testutils.MockConnect(
 					m, pubkey, nodeAddr, true, connectErr,
 				)
@@ -159,6 +255,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   5,
 			expectedErr:   nil,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We have no peers at present.
 				testutils.MockListPeers(m, nil, nil)
 
@@ -169,6 +268,7 @@ func TestSendMessage(t *testing.T) {
 
 				// Succeed in connecting to the address
 				// provided.
+				mockPollPeers(m)
 				testutils.MockConnect(
 					m, pubkey, nodeAddr, true, nil,
 				)
@@ -181,6 +281,59 @@ func TestSendMessage(t *testing.T) {
 				testutils.MockSendAnyCustomMessage(m, nil)
 			},
 		},
+		{
+			name:                "success - transient connection",
+			peer:                pubkeys[0],
+			directConnect:       true,
+			disconnectAfterSend: true,
+			peerLookups:         5,
+			expectedErr:         nil,
+			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
+				// We have no peers at present.
+				testutils.MockListPeers(m, nil, nil)
+
+				// Find the peer in the graph.
+				testutils.MockGetNodeInfo(
+					m, pubkey, false, nodeInfo, nil,
+				)
+
+				// Make a non-permanent connection to the
+				// peer, which is immediately found.
+				mockPollPeers(m)
+				testutils.MockConnect(
+					m, pubkey, nodeAddr, false, nil,
+				)
+				testutils.MockListPeers(m, peerList, nil)
+
+				// Send the message to the peer, then
+				// disconnect. Failing to disconnect does not
+				// fail the send.
+				testutils.MockSendAnyCustomMessage(m, nil)
+				testutils.MockDisconnect(
+					m, pubkey, disconnectErr,
+				)
+			},
+		},
+		{
+			name:                "success - existing peer kept",
+			peer:                pubkeys[0],
+			directConnect:       true,
+			disconnectAfterSend: true,
+			peerLookups:         5,
+			expectedErr:         nil,
+			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
+				// We are already connected to the peer, so
+				// we don't disconnect after sending.
+				testutils.MockListPeers(m, peerList, nil)
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
 		{
 			name:          "success - peer found after retry",
 			peer:          pubkeys[0],
@@ -188,6 +341,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   5,
 			expectedErr:   nil,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We have no peers at present.
 				testutils.MockListPeers(m, nil, nil)
 
@@ -198,6 +354,7 @@ func TestSendMessage(t *testing.T) {
 
 				// Succeed in connecting to the address
 				// provided.
+				mockPollPeers(m)
 				testutils.MockConnect(
 					m, pubkey, nodeAddr, true, nil,
 				)
@@ -216,6 +373,98 @@ func TestSendMessage(t *testing.T) {
 				testutils.MockSendAnyCustomMessage(m, nil)
 			},
 		},
+		{
+			name:          "success - peer online event",
+			peer:          pubkeys[0],
+			directConnect: true,
+			expectedErr:   nil,
+			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
+				// We have no peers at present.
+				testutils.MockListPeers(m, nil, nil)
+
+				// Find the peer in the graph.
+				testutils.MockGetNodeInfo(
+					m, pubkey, false, nodeInfo, nil,
+				)
+
+				// Subscribe to peer events, which will
+				// deliver events for other peers before our
+				// peer comes online.
+				events := make(chan *lnrpc.PeerEvent, 3)
+				events <- &lnrpc.PeerEvent{
+					PubKey: node1.String(),
+					Type:   lnrpc.PeerEvent_PEER_ONLINE,
+				}
+				events <- &lnrpc.PeerEvent{
+					PubKey: pubkey.String(),
+					Type:   lnrpc.PeerEvent_PEER_OFFLINE,
+				}
+				events <- &lnrpc.PeerEvent{
+					PubKey: pubkey.String(),
+					Type:   lnrpc.PeerEvent_PEER_ONLINE,
+				}
+
+				testutils.MockSubscribePeerEvents(
+					m, events, make(chan error), nil,
+				)
+
+				testutils.MockConnect(
+					m, pubkey, nodeAddr, true, nil,
+				)
+
+				// We check our peers once after connecting,
+				// and then wait for our peer's online event.
+				testutils.MockListPeers(m, nil, nil)
+
+				// Send the message to the peer.
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
+		{
+			name:          "success - peer events fail",
+			peer:          pubkeys[0],
+			directConnect: true,
+			peerLookups:   5,
+			expectedErr:   nil,
+			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
+				// We have no peers at present.
+				testutils.MockListPeers(m, nil, nil)
+
+				// Find the peer in the graph.
+				testutils.MockGetNodeInfo(
+					m, pubkey, false, nodeInfo, nil,
+				)
+
+				// Subscribe to peer events, but fail our
+				// subscription.
+				errs := make(chan error, 1)
+				errs <- errors.New("subscription failed")
+
+				testutils.MockSubscribePeerEvents(
+					m, make(chan *lnrpc.PeerEvent), errs,
+					nil,
+				)
+
+				testutils.MockConnect(
+					m, pubkey, nodeAddr, true, nil,
+				)
+
+				// We check our peers once after connecting,
+				// and then fall back to polling when our
+				// subscription fails.
+				testutils.MockListPeers(m, nil, nil)
+				testutils.MockListPeers(m, peerList, nil)
+
+				// Send the message to the peer.
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
 		{
 			name:          "failure - peer not found after retry",
 			peer:          pubkeys[0],
@@ -223,6 +472,9 @@ func TestSendMessage(t *testing.T) {
 			peerLookups:   2,
 			expectedErr:   ErrNoConnection,
 			setMock: func(m *mock.Mock) {
+				// No multi-hop path is found to the peer.
+				mockNoRoute(m)
+
 				// We have no peers at present.
 				testutils.MockListPeers(m, nil, nil)
 
@@ -233,6 +485,7 @@ func TestSendMessage(t *testing.T) {
 
 				// Succeed in connecting to the address
 				// provided.
+				mockPollPeers(m)
 				testutils.MockConnect(
 					m, pubkey, nodeAddr, true, nil,
 				)
@@ -248,33 +501,122 @@ func TestSendMessage(t *testing.T) {
 			peer:          pubkeys[0],
 			directConnect: false,
 			expectedErr:   ErrNoPath,
+			setMock: func(m *mock.Mock) {
+				mockNoRoute(m)
+
+				// We're not connected to the peer, so we have
+				// no fallback.
+				testutils.MockListPeers(m, nil, nil)
+			},
+		},
+		{
+			name:          "multi-hop no path, connected peer",
+			peer:          pubkeys[0],
+			directConnect: false,
+			expectedErr:   nil,
+			setMock: func(m *mock.Mock) {
+				mockNoRoute(m)
+
+				// We're already connected to the peer, so we
+				// can send to them directly.
+				testutils.MockListPeers(m, peerList, nil)
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
+		{
+			name:          "multi-hop send fails, no fallback",
+			peer:          pubkeys[0],
+			directConnect: false,
+			expectedErr:   sendErr,
 			setMock: func(m *mock.Mock) {
 				req := queryRoutesRequest(pubkeys[0])
-				resp := &lndclient.QueryRoutesResponse{}
 				testutils.MockQueryRoutes(
-					m, req, resp, nil,
+					m, req, multiHopResp, nil,
+				)
+				testutils.MockGetNodeInfo(
+					m, node1, false, relayInfo, nil,
+				)
+
+				// Fail sending along our multi-hop path.
+				testutils.MockSendAnyCustomMessage(m, sendErr)
+
+				// Retry with our first hop avoided, which
+				// discards the only route we have.
+				testutils.MockQueryRoutes(
+					m, req, multiHopResp, nil,
 				)
+
+				// We're not connected to the peer, and can't
+				// make a connection.
+				testutils.MockListPeers(m, nil, nil)
 			},
 		},
 		{
-			name:          "multi-hop finds path",
+			name:          "multi-hop send fails, direct connect",
+			peer:          pubkeys[0],
+			directConnect: true,
+			peerLookups:   1,
+			expectedErr:   nil,
+			setMock: func(m *mock.Mock) {
+				req := queryRoutesRequest(pubkeys[0])
+				testutils.MockQueryRoutes(
+					m, req, multiHopResp, nil,
+				)
+				testutils.MockGetNodeInfo(
+					m, node1, false, relayInfo, nil,
+				)
+
+				// Fail sending along our multi-hop path.
+				testutils.MockSendAnyCustomMessage(m, sendErr)
+
+				// Retry with our first hop avoided, which
+				// discards the only route we have.
+				testutils.MockQueryRoutes(
+					m, req, multiHopResp, nil,
+				)
+
+				// Fall back to connecting to our peer and
+				// sending the message directly.
+				testutils.MockListPeers(m, nil, nil)
+				testutils.MockGetNodeInfo(
+					m, pubkey, false, nodeInfo, nil,
+				)
+				mockPollPeers(m)
+				testutils.MockConnect(
+					m, pubkey, nodeAddr, true, nil,
+				)
+				testutils.MockListPeers(m, peerList, nil)
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
+		{
+			name:          "query routes fails, connected peer",
 			peer:          pubkeys[0],
 			directConnect: false,
 			expectedErr:   nil,
 			setMock: func(m *mock.Mock) {
 				req := queryRoutesRequest(pubkeys[0])
-				resp := &lndclient.QueryRoutesResponse{
-					Hops: []*lndclient.Hop{
-						{
-							PubKey: &node1,
-						},
-						{
-							PubKey: &node2,
-						},
-					},
-				}
+				testutils.MockQueryRoutes(
+					m, req, nil, errors.New("mock"),
+				)
 
-				testutils.MockQueryRoutes(m, req, resp, nil)
+				testutils.MockListPeers(m, peerList, nil)
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
+		{
+			name:          "multi-hop finds path",
+			peer:          pubkeys[0],
+			directConnect: false,
+			expectedErr:   nil,
+			setMock: func(m *mock.Mock) {
+				req := queryRoutesRequest(pubkeys[0])
+				testutils.MockQueryRoutes(
+					m, req, multiHopResp, nil,
+				)
+				testutils.MockGetNodeInfo(
+					m, node1, false, relayInfo, nil,
+				)
 
 				// Send the message to the peer.
 				testutils.MockSendAnyCustomMessage(m, nil)
@@ -306,9 +648,10 @@ func testSendMessage(t *testing.T, testCase sendMessageTest) {
 
 	// We don't expect the messenger's shutdown function to be used, so
 	// we can provide nil (knowing that our tests will panic if it's used).
-	messenger := NewOnionMessenger(
+	messenger, err := NewOnionMessenger(
 		lnd, nodeKeyECDH, nil,
 	)
+	require.NoError(t, err, "new messenger")
 
 	// Overwrite our peer lookup defaults so that we don't have sleeps in
 	// our tests.
@@ -319,64 +662,349 @@ func testSendMessage(t *testing.T, testCase sendMessageTest) {
 	req := NewSendMessageRequest(
 		testCase.peer, nil, nil, nil, testCase.directConnect,
 	)
+	req.DisconnectAfterSend = testCase.disconnectAfterSend
 
-	err := messenger.SendMessage(ctxb, req)
+	err = messenger.SendMessage(ctxb, req)
 
 	// All of our errors are wrapped, so we can just check err.Is the
 	// error we expect (also works for nil).
 	require.True(t, errors.Is(err, testCase.expectedErr))
 }
 
-// handleOnionMesageMock is a mock that handled all mocked calls for testing
-// onion messaging.
-type handleOnionMesageMock struct {
-	*mock.Mock
-}
-
-func (h *handleOnionMesageMock) processOnion(d []byte) (*btcec.PublicKey,
-	*sphinx.ProcessedPacket, error) {
+// TestTransportMessenger tests that a messenger created with separate
+// transport and graph dependencies uses each for the calls it is responsible
+// for.
+func TestTransportMessenger(t *testing.T) {
+	var (
+		privkeys    = testutils.GetPrivkeys(t, 1)
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
 
-	args := h.Mock.MethodCalled("processOnion", d)
+		peer = testutils.GetPubkeys(t, 1)[0]
+	)
 
-	return args.Get(0).(*btcec.PublicKey),
-		args.Get(1).(*sphinx.ProcessedPacket), args.Error(2)
-}
+	transport := testutils.NewMockLnd()
+	defer transport.Mock.AssertExpectations(t)
 
-// mockProcessOnion primes the mock to handle a call to decode an onion message.
-func mockProcessOnion(m *mock.Mock, blinding *btcec.PublicKey,
-	packet *sphinx.ProcessedPacket, err error) {
+	graph := testutils.NewMockLnd()
+	defer graph.Mock.AssertExpectations(t)
 
-	m.On(
-		"processOnion", mock.Anything,
-	).Once().Return(
-		blinding, packet, err,
+	// We look for a multi-hop path in our graph, and fall back to sending
+	// to our directly connected peer over our transport.
+	testutils.MockQueryRoutes(
+		graph.Mock, queryRoutesRequest(peer),
+		&lndclient.QueryRoutesResponse{}, lndclient.ErrNoRouteFound,
 	)
-}
 
-func (h *handleOnionMesageMock) DecodePayload(o []byte) (
-	*lnwire.OnionMessagePayload, error) {
+	testutils.MockListPeers(transport.Mock, []lndclient.Peer{
+		{
+			Pubkey: route.NewVertex(peer),
+		},
+	}, nil)
+	testutils.MockSendAnyCustomMessage(transport.Mock, nil)
 
-	args := h.Mock.MethodCalled("DecodePayload", o)
+	messenger, err := NewTransportMessenger(
+		transport, graph, nodeKeyECDH, nil,
+	)
+	require.NoError(t, err, "new messenger")
 
-	return args.Get(0).(*lnwire.OnionMessagePayload), args.Error(1)
+	req := NewSendMessageRequest(peer, nil, nil, nil, true)
+	require.NoError(t, messenger.SendMessage(context.Background(), req))
 }
 
-// mockPayloadDecode primes the mock to handle a call to decode payload and
-// return the payload and error provided. Note that this call does not assert
-// that this function is called with a specific value.
-func mockPayloadDecode(m *mock.Mock, payload *lnwire.OnionMessagePayload,
-	err error) {
-
-	m.On(
-		"DecodePayload", mock.Anything,
-	).Once().Return(
-		payload, err,
-	)
-}
+// TestConnectionPermanence tests that re-dialing a peer that we previously
+// connected to permanently restores the permanent connection, even if the
+// message being sent only requires a transient connection.
+func TestConnectionPermanence(t *testing.T) {
+	var (
+		privkeys    = testutils.GetPrivkeys(t, 1)
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
 
-// DecryptBlob mocks decrypting of our onion message's encrypted blob.
-func (h *handleOnionMesageMock) DecryptBlob(blindingPoint *btcec.PublicKey,
-	payload *lnwire.OnionMessagePayload) (*lnwire.BlindedRouteData, error) {
+		peer     = testutils.GetPubkeys(t, 1)[0]
+		vertex   = route.NewVertex(peer)
+		nodeAddr = "host:port"
+		peerList = []lndclient.Peer{
+			{
+				Pubkey: vertex,
+			},
+		}
+
+		nodeInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Addresses: []string{
+					nodeAddr,
+				},
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+
+		ctxb = context.Background()
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, nil)
+	require.NoError(t, err, "new messenger")
+
+	mockConnect := func(permanent bool) {
+		testutils.MockQueryRoutes(
+			lnd.Mock, queryRoutesRequest(peer),
+			&lndclient.QueryRoutesResponse{}, nil,
+		)
+		testutils.MockListPeers(lnd.Mock, nil, nil)
+		testutils.MockGetNodeInfo(lnd.Mock, vertex, false, nodeInfo, nil)
+		mockPollPeers(lnd.Mock)
+		testutils.MockConnect(lnd.Mock, vertex, nodeAddr, permanent, nil)
+		testutils.MockListPeers(lnd.Mock, peerList, nil)
+		testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	}
+
+	// Send a message that connects to our peer permanently.
+	mockConnect(true)
+	req := NewSendMessageRequest(peer, nil, nil, nil, true)
+	require.NoError(t, messenger.SendMessage(ctxb, req))
+
+	// Now, send a message that only requires a transient connection once
+	// our peer has gone offline. We expect the permanent connection to be
+	// restored, and no disconnect after sending.
+	mockConnect(true)
+	req.DisconnectAfterSend = true
+	require.NoError(t, messenger.SendMessage(ctxb, req))
+}
+
+// blindedDestination creates a blinded route along the path provided, where
+// each hop's data points to the next hop in the path.
+func blindedDestination(t *testing.T,
+	path []*btcec.PublicKey) *lnwire.ReplyPath {
+
+	hops := make([]*sphinx.HopInfo, len(path))
+	for i, node := range path {
+		data := &lnwire.BlindedRouteData{}
+		if i < len(path)-1 {
+			data.NextNodeID = path[i+1]
+		}
+
+		plaintext, err := lnwire.EncodeBlindedRouteData(data)
+		require.NoError(t, err, "encode data")
+
+		hops[i] = &sphinx.HopInfo{
+			NodePub:   node,
+			PlainText: plaintext,
+		}
+	}
+
+	sessionKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "session key")
+
+	blindedPath, err := sphinx.BuildBlindedPath(sessionKey, hops)
+	require.NoError(t, err, "blinded path")
+
+	replyPath := &lnwire.ReplyPath{
+		FirstNodeID:   blindedPath.IntroductionPoint,
+		BlindingPoint: blindedPath.BlindingPoint,
+	}
+
+	for _, hop := range blindedPath.BlindedHops {
+		replyPath.Hops = append(replyPath.Hops, &lnwire.BlindedHop{
+			BlindedNodeID: hop.BlindedNodePub,
+			EncryptedData: hop.CipherText,
+		})
+	}
+
+	return replyPath
+}
+
+// TestSendToBlindedDestination tests sending onion messages to blinded
+// destinations.
+func TestSendToBlindedDestination(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 3)
+
+	destKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "destination key")
+
+	var (
+		ourKey = privkeys[0]
+		node1  = privkeys[1].PubKey()
+		intro  = privkeys[2].PubKey()
+		dest   = destKey.PubKey()
+
+		node1Vertex = route.NewVertex(node1)
+		introVertex = route.NewVertex(intro)
+
+		relayInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+	)
+
+	tests := []struct {
+		name string
+
+		// path is the path of the blinded destination.
+		path []*btcec.PublicKey
+
+		// setMock primes our lnd mock for the test.
+		setMock func(*mock.Mock)
+
+		// firstHop is the peer we expect to send our message to.
+		firstHop route.Vertex
+
+		err error
+	}{
+		{
+			name: "multi-hop path to introduction node",
+			path: []*btcec.PublicKey{intro, dest},
+			setMock: func(m *mock.Mock) {
+				testutils.MockQueryRoutes(
+					m, queryRoutesRequest(intro),
+					&lndclient.QueryRoutesResponse{
+						Hops: []*lndclient.Hop{
+							{PubKey: &node1Vertex},
+							{PubKey: &introVertex},
+						},
+					}, nil,
+				)
+
+				testutils.MockGetNodeInfo(
+					m, node1Vertex, false, relayInfo, nil,
+				)
+
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+			firstHop: node1Vertex,
+		},
+		{
+			name: "we are the introduction node",
+			path: []*btcec.PublicKey{
+				ourKey.PubKey(), intro, dest,
+			},
+			setMock: func(m *mock.Mock) {
+				// We expect to find a path to the next node in
+				// the blinded route.
+				testutils.MockQueryRoutes(
+					m, queryRoutesRequest(intro),
+					&lndclient.QueryRoutesResponse{
+						Hops: []*lndclient.Hop{
+							{PubKey: &introVertex},
+						},
+					}, nil,
+				)
+
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+			firstHop: introVertex,
+		},
+		{
+			name: "blinded route terminates at our node",
+			path: []*btcec.PublicKey{ourKey.PubKey()},
+			err:  ErrSelfDestination,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			lnd := testutils.NewMockLnd()
+			defer lnd.Mock.AssertExpectations(t)
+
+			if testCase.setMock != nil {
+				testCase.setMock(lnd.Mock)
+			}
+
+			messenger, err := NewOnionMessenger(
+				lnd, &sphinx.PrivKeyECDH{PrivKey: ourKey}, nil,
+			)
+			require.NoError(t, err, "new messenger")
+
+			req := NewSendMessageRequest(
+				nil, blindedDestination(t, testCase.path), nil,
+				nil, false,
+			)
+
+			err = messenger.SendMessage(context.Background(), req)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			if testCase.err != nil {
+				return
+			}
+
+			// Assert that our message was sent to the first hop
+			// that we expect.
+			var sent lndclient.CustomMessage
+			for _, call := range lnd.Mock.Calls {
+				if call.Method != "SendCustomMessage" {
+					continue
+				}
+
+				msg := call.Arguments.Get(1)
+				sent = msg.(lndclient.CustomMessage)
+			}
+
+			require.Equal(t, testCase.firstHop, sent.Peer)
+		})
+	}
+}
+
+// handleOnionMesageMock is a mock that handled all mocked calls for testing
+// onion messaging.
+type handleOnionMesageMock struct {
+	*mock.Mock
+}
+
+func (h *handleOnionMesageMock) processOnion(d []byte) (*btcec.PublicKey,
+	*sphinx.ProcessedPacket, error) {
+
+	args := h.Mock.MethodCalled("processOnion", d)
+
+	return args.Get(0).(*btcec.PublicKey),
+		args.Get(1).(*sphinx.ProcessedPacket), args.Error(2)
+}
+
+// mockProcessOnion primes the mock to handle a call to decode an onion message.
+func mockProcessOnion(m *mock.Mock, blinding *btcec.PublicKey,
+	packet *sphinx.ProcessedPacket, err error) {
+
+	m.On(
+		"processOnion", mock.Anything,
+	).Once().Return(
+		blinding, packet, err,
+	)
+}
+
+func (h *handleOnionMesageMock) DecodePayload(o []byte) (
+	*lnwire.OnionMessagePayload, error) {
+
+	args := h.Mock.MethodCalled("DecodePayload", o)
+
+	return args.Get(0).(*lnwire.OnionMessagePayload), args.Error(1)
+}
+
+// mockPayloadDecode primes the mock to handle a call to decode payload and
+// return the payload and error provided. Note that this call does not assert
+// that this function is called with a specific value.
+func mockPayloadDecode(m *mock.Mock, payload *lnwire.OnionMessagePayload,
+	err error) {
+
+	m.On(
+		"DecodePayload", mock.Anything,
+	).Once().Return(
+		payload, err,
+	)
+}
+
+// DecryptBlob mocks decrypting of our onion message's encrypted blob.
+func (h *handleOnionMesageMock) DecryptBlob(blindingPoint *btcec.PublicKey,
+	payload *lnwire.OnionMessagePayload) (*lnwire.BlindedRouteData, error) {
 
 	args := h.Mock.MethodCalled("decryptBlob", blindingPoint, payload)
 
@@ -415,6 +1043,18 @@ func mockForwardMessage(m *mock.Mock, data *lnwire.BlindedRouteData,
 	)
 }
 
+// VerifyPathID mocks verification of a path ID.
+func (h *handleOnionMesageMock) VerifyPathID(pathID []byte) error {
+	args := h.Mock.MethodCalled("verifyPathID", pathID)
+
+	return args.Error(0)
+}
+
+// mockVerifyPathID primes the mock for a call to verify path ID.
+func mockVerifyPathID(m *mock.Mock, pathID []byte, err error) {
+	m.On("verifyPathID", pathID).Once().Return(err)
+}
+
 // OnionMessageHandler mocks a call to handle an onion message.
 func (h *handleOnionMesageMock) OnionMessageHandler(path *lnwire.ReplyPath,
 	encrypted []byte, payload []byte) error {
@@ -438,6 +1078,29 @@ func mockMessageHandled(m *mock.Mock, path *lnwire.ReplyPath, data,
 	)
 }
 
+// WildcardHandler mocks handling of final hop payloads by a catch-all handler.
+func (h *handleOnionMesageMock) WildcardHandler(tlvType tlv.Type,
+	path *lnwire.ReplyPath, encrypted []byte, payload []byte) error {
+
+	args := h.Mock.MethodCalled(
+		"WildcardHandler", tlvType, path, encrypted, payload,
+	)
+
+	return args.Error(0)
+}
+
+// mockWildcardHandled primes the mock to handle a call to a wildcard handler
+// with the payload provided. The mock will return the error supplied.
+func mockWildcardHandled(m *mock.Mock, tlvType tlv.Type,
+	path *lnwire.ReplyPath, data, payload []byte, err error) {
+
+	m.On(
+		"WildcardHandler", tlvType, path, data, payload,
+	).Once().Return(
+		err,
+	)
+}
+
 // TestHandleOnionMessage tests different handling cases for onion messages.
 func TestHandleOnionMessage(t *testing.T) {
 	pubkeys := testutils.GetPubkeys(t, 4)
@@ -447,10 +1110,14 @@ func TestHandleOnionMessage(t *testing.T) {
 	// Create a single valid message that we can use across test cases.
 	onionMsg := &lnwire.OnionMessage{
 		BlindingPoint: blinding,
-		OnionBlob:     []byte{1, 2, 3},
+		OnionBlob: make(
+			[]byte, lnwire.StandardOnionBlobSize,
+		),
 	}
 
-	msg, err := customOnionMessage(nodeKey, onionMsg)
+	msg, err := customOnionMessage(
+		nodeKey, lnwire.OnionMessageType, onionMsg,
+	)
 	require.NoError(t, err, "custom message")
 
 	mockErr := errors.New("mock err")
@@ -473,6 +1140,12 @@ func TestHandleOnionMessage(t *testing.T) {
 		EncryptedData: []byte{9, 8, 7},
 	}
 
+	// Create a payload for a relaying hop, which only contains encrypted
+	// data.
+	relayPayload := &lnwire.OnionMessagePayload{
+		EncryptedData: []byte{9, 8, 7},
+	}
+
 	// Create another payload with extra data for the final hop that will
 	// need to be handled.
 	finalHopPayload := &lnwire.FinalHopPayload{
@@ -499,13 +1172,48 @@ func TestHandleOnionMessage(t *testing.T) {
 		},
 	}
 
+	// Create blinded route data for our node that includes a path ID.
+	pathIDData := &lnwire.BlindedRouteData{
+		PathID: []byte{1, 2, 3},
+	}
+
 	tests := []struct {
 		name        string
 		msg         lndclient.CustomMessage
 		setupMock   func(*mock.Mock)
+		checkPathID bool
+		wildcard    bool
 		expectedErr error
+
+		// handlerCount is the number of handlers registered for
+		// our final hop payload, defaulting to 1 if not set.
+		handlerCount int
+
+		incoming []IncomingInterceptor
+		dispatch []DispatchInterceptor
+
+		// kind is the failure kind that we expect our error to be
+		// classified as.
+		kind FailureKind
+
+		// unhandled is the number of final hop payloads that we
+		// expect to be dropped because we have no handler for them.
+		unhandled int
 	}{
 		// TODO: add coverage for decoding errors
+		{
+			name: "unparseable payload",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, nil, mockErr)
+			},
+			expectedErr: ErrBadOnionBlob,
+			kind:        FailurePayload,
+		},
 		{
 			name: "message for our node",
 			msg:  *msg,
@@ -536,6 +1244,7 @@ func TestHandleOnionMessage(t *testing.T) {
 				mockPayloadDecode(m, payloadNoFinalHops, nil)
 			},
 			expectedErr: ErrNoForwardingOnion,
+			kind:        FailureForward,
 		},
 		{
 			name: "message for forwarding",
@@ -549,7 +1258,7 @@ func TestHandleOnionMessage(t *testing.T) {
 				}
 
 				mockProcessOnion(m, blinding, packet, nil)
-				mockPayloadDecode(m, payloadNoFinalHops, nil)
+				mockPayloadDecode(m, relayPayload, nil)
 
 				data := &lnwire.BlindedRouteData{
 					NextNodeID: pubkeys[0],
@@ -557,7 +1266,7 @@ func TestHandleOnionMessage(t *testing.T) {
 
 				mockDecryptBlob(
 					m, blinding,
-					payloadNoFinalHops, data, nil,
+					relayPayload, data, nil,
 				)
 
 				// Fail our message forward.
@@ -567,57 +1276,200 @@ func TestHandleOnionMessage(t *testing.T) {
 				)
 			},
 			expectedErr: mockErr,
+			kind:        FailureForward,
 		},
 		{
-			name: "message for forwarding with final payload",
+			name: "message for forwarding - forwarding disabled",
 			msg:  *msg,
 			setupMock: func(m *mock.Mock) {
-				// Return a packet indicating that there are
-				// more hops which also (incorrectly) contains
-				// tlvs in the final node range.
 				packet := &sphinx.ProcessedPacket{
-					Action: sphinx.MoreHops,
+					Action:     sphinx.MoreHops,
+					NextPacket: &sphinx.OnionPacket{},
 				}
 
 				mockProcessOnion(m, blinding, packet, nil)
-				mockPayloadDecode(m, payloadWithFinal, nil)
+				mockPayloadDecode(m, relayPayload, nil)
+
+				data := &lnwire.BlindedRouteData{
+					NextNodeID: pubkeys[0],
+				}
+
+				mockDecryptBlob(
+					m, blinding,
+					relayPayload, data, nil,
+				)
+
+				// Decline to forward our message because we
+				// are running in endpoint-only mode.
+				mockForwardMessage(
+					m, data, blinding,
+					&sphinx.OnionPacket{},
+					ErrForwardingDisabled,
+				)
 			},
-			expectedErr: ErrFinalPayload,
+			expectedErr: ErrForwardingDisabled,
+			kind:        FailurePolicyDrop,
 		},
 		{
-			name: "invalid message",
+			name: "message for forwarding - reply path",
 			msg:  *msg,
 			setupMock: func(m *mock.Mock) {
-				// Return a packet indicating that there are
-				// more hops.
 				packet := &sphinx.ProcessedPacket{
-					Action: sphinx.Failure,
+					Action:     sphinx.MoreHops,
+					NextPacket: &sphinx.OnionPacket{},
 				}
+
 				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadNoFinalHops, nil)
 
-				// We'll decode the payload before  we check
-				// the next action for the packet (and fail).
-				mockPayloadDecode(m, payloadWithFinal, nil)
-			},
-			expectedErr: ErrBadMessage,
-		},
-		{
-			name: "processing failed",
-			msg:  *msg,
-			setupMock: func(m *mock.Mock) {
-				// Fail onion processing.
-				mockProcessOnion(
-					m, blinding, &sphinx.ProcessedPacket{},
-					mockErr,
+				data := &lnwire.BlindedRouteData{
+					NextNodeID: pubkeys[0],
+				}
+
+				mockDecryptBlob(
+					m, blinding,
+					payloadNoFinalHops, data, nil,
 				)
 			},
-			expectedErr: ErrBadOnionBlob,
+			expectedErr: ErrRelayReplyPath,
+			kind:        FailurePolicyDrop,
 		},
 		{
-			name: "final payload handled",
+			name: "message for forwarding - path id",
 			msg:  *msg,
 			setupMock: func(m *mock.Mock) {
-				// Setup our mock to return a final payload
+				packet := &sphinx.ProcessedPacket{
+					Action:     sphinx.MoreHops,
+					NextPacket: &sphinx.OnionPacket{},
+				}
+
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, relayPayload, nil)
+
+				data := &lnwire.BlindedRouteData{
+					NextNodeID: pubkeys[0],
+					PathID:     []byte{1},
+				}
+
+				mockDecryptBlob(
+					m, blinding, relayPayload, data, nil,
+				)
+			},
+			expectedErr: ErrRelayPathID,
+			kind:        FailurePolicyDrop,
+		},
+		{
+			name: "message for forwarding - no next node",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action:     sphinx.MoreHops,
+					NextPacket: &sphinx.OnionPacket{},
+				}
+
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, relayPayload, nil)
+
+				mockDecryptBlob(
+					m, blinding, relayPayload,
+					&lnwire.BlindedRouteData{}, nil,
+				)
+			},
+			expectedErr: ErrNoNextNodeID,
+			kind:        FailurePolicyDrop,
+		},
+		{
+			name: "message for forwarding - short channel id",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action:     sphinx.MoreHops,
+					NextPacket: &sphinx.OnionPacket{},
+				}
+
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, relayPayload, nil)
+
+				scid := lndwire.NewShortChanIDFromInt(123)
+				data := &lnwire.BlindedRouteData{
+					NextSCID: &scid,
+				}
+
+				mockDecryptBlob(
+					m, blinding, relayPayload, data, nil,
+				)
+
+				mockForwardMessage(
+					m, data, blinding,
+					&sphinx.OnionPacket{}, nil,
+				)
+			},
+		},
+		{
+			name: "message for forwarding with final payload",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				// Return a packet indicating that there are
+				// more hops which also (incorrectly) contains
+				// tlvs in the final node range.
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.MoreHops,
+				}
+
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+			},
+			expectedErr: ErrFinalPayload,
+			kind:        FailurePolicyDrop,
+		},
+		{
+			name: "invalid message",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				// Return a packet indicating that there are
+				// more hops.
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.Failure,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+
+				// We'll decode the payload before  we check
+				// the next action for the packet (and fail).
+				mockPayloadDecode(m, payloadWithFinal, nil)
+			},
+			expectedErr: ErrBadMessage,
+			kind:        FailureDecode,
+		},
+		{
+			name: "processing failed",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				// Fail onion processing.
+				mockProcessOnion(
+					m, blinding, &sphinx.ProcessedPacket{},
+					mockErr,
+				)
+			},
+			expectedErr: ErrBadOnionBlob,
+			kind:        FailureBlinding,
+		},
+		{
+			name: "replayed message",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				mockProcessOnion(
+					m, blinding, &sphinx.ProcessedPacket{},
+					ErrReplayedMessage,
+				)
+			},
+			expectedErr: ErrReplayedMessage,
+			kind:        FailureReplay,
+		},
+		{
+			name: "final payload handled",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				// Setup our mock to return a final payload
 				// for our node.
 				packet := &sphinx.ProcessedPacket{
 					Action: sphinx.ExitNode,
@@ -657,6 +1509,60 @@ func TestHandleOnionMessage(t *testing.T) {
 				)
 			},
 			expectedErr: mockErr,
+			kind:        FailureHandler,
+		},
+		{
+			name: "final payload valid path id",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+
+				// Decrypt our data to find a path ID that
+				// we created.
+				mockDecryptBlob(
+					m, blinding, payloadWithFinal,
+					pathIDData, nil,
+				)
+				mockVerifyPathID(m, pathIDData.PathID, nil)
+
+				mockMessageHandled(
+					m,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value,
+					nil,
+				)
+			},
+			checkPathID: true,
+		},
+		{
+			name: "final payload invalid path id",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+
+				// Decrypt our data to find a path ID that
+				// we did not create, which should fail before
+				// our handler is called.
+				mockDecryptBlob(
+					m, blinding, payloadWithFinal,
+					pathIDData, nil,
+				)
+				mockVerifyPathID(
+					m, pathIDData.PathID, ErrInvalidPathID,
+				)
+			},
+			checkPathID: true,
+			expectedErr: ErrInvalidPathID,
+			kind:        FailurePolicyDrop,
 		},
 		{
 			name: "final payload no handler",
@@ -671,114 +1577,327 @@ func TestHandleOnionMessage(t *testing.T) {
 				mockProcessOnion(m, blinding, packet, nil)
 				mockPayloadDecode(m, unhandledPayload, nil)
 			},
+			unhandled: 1,
 		},
-	}
-
-	for _, testCase := range tests {
-		t.Run(testCase.name, func(t *testing.T) {
-			// Setup a mock and prime it with the test's
-			// requirements.
-			mock := &handleOnionMesageMock{
-				Mock: &mock.Mock{},
-			}
-
-			testCase.setupMock(mock.Mock)
-			defer mock.AssertExpectations(t)
-
-			handlers := map[tlv.Type]OnionMessageHandler{
-				finalHopPayload.TLVType: mock.OnionMessageHandler,
-			}
-
-			kit := &onionMessageKit{
-				processOnion:    mock.processOnion,
-				decodePayload:   mock.DecodePayload,
-				decryptDataBlob: mock.DecryptBlob,
-				forwardMessage:  mock.ForwardMessage,
-				handlers:        handlers,
-			}
-
-			err := handleOnionMessage(testCase.msg, kit)
-			require.True(t, errors.Is(err, testCase.expectedErr))
-		})
-	}
-}
-
-// receiveMessageHandler is the function signature for handlers that drive
-// tests for our receive message loop.
-type receiveMessageHandler func(*testing.T, chan<- lndclient.CustomMessage,
-	chan<- error)
-
-// sendMsg is a helped that sends a custom message into the channel provided,
-// failing the test if it is not delivered on time.
-func sendMsg(t *testing.T, msgChan chan<- lndclient.CustomMessage,
-	msg lndclient.CustomMessage) {
-
-	select {
-	case msgChan <- msg:
-	case <-time.After(defaultTimeout):
-		t.Fatalf("could not send message: %v", msg)
-	}
-}
-
-// sendErr is a helper that sends an error into the channel provided, failing
-// the test if it is not delivered in time.
-func sendErr(t *testing.T, errChan chan<- error, err error) {
-	select {
-	case errChan <- err:
-	case <-time.After(defaultTimeout):
-		t.Fatalf("could not send error: %v", err)
-	}
-}
-
-// TestReceiveOnionMessages tests the messenger's receive loop for messages.
-func TestReceiveOnionMessages(t *testing.T) {
-	privkeys := testutils.GetPrivkeys(t, 2)
-
-	// Create an onion message that is *to our node* that we can use
-	// across tests. The message itself can be junk, because we're not
-	// reading it in this test.
-	nodePubkey := privkeys[0].PubKey()
-	onionMsg := lnwire.NewOnionMessage(nodePubkey, []byte{1, 2, 3})
-
-	msg, err := customOnionMessage(
-		nodePubkey, onionMsg,
-	)
-	require.NoError(t, err, "custom message")
-
-	mockErr := errors.New("mock")
-
-	tests := []struct {
-		name          string
-		handler       receiveMessageHandler
-		expectedError error
-	}{
 		{
-			name: "message sent",
-			handler: func(t *testing.T,
-				msgChan chan<- lndclient.CustomMessage,
-				errChan chan<- error) {
-
-				sendMsg(t, msgChan, *msg)
-			},
-		}, {
-			name: "non-onion message",
-			handler: func(t *testing.T,
-				msgChan chan<- lndclient.CustomMessage,
-				errChan chan<- error) {
-
-				msg := lndclient.CustomMessage{
-					MsgType: 1001,
+			name: "final payload wildcard and handler",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
 				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
 
-				sendMsg(t, msgChan, msg)
+				// Both our wildcard and the handler for the
+				// payload's type should be called.
+				mockWildcardHandled(
+					m, finalHopPayload.TLVType,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value, nil,
+				)
+				mockMessageHandled(
+					m,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value,
+					nil,
+				)
 			},
-			expectedError: nil,
+			wildcard: true,
 		},
 		{
-			name: "lnd shutdown - messages",
-			handler: func(t *testing.T,
-				msgChan chan<- lndclient.CustomMessage,
-				errChan chan<- error) {
+			name: "final payload wildcard no handler",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, unhandledPayload, nil)
+
+				// Payloads that have no handler for their
+				// type are still delivered to our wildcard.
+				unhandled := unhandledPayload.FinalHopPayloads[0]
+				mockWildcardHandled(
+					m, unhandled.TLVType,
+					unhandledPayload.ReplyPath,
+					unhandledPayload.EncryptedData,
+					unhandled.Value, nil,
+				)
+			},
+			wildcard: true,
+		},
+		{
+			name: "final payload wildcard error",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+
+				// Fail our wildcard handler, which should not
+				// prevent delivery to the type's handler.
+				mockWildcardHandled(
+					m, finalHopPayload.TLVType,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value, mockErr,
+				)
+				mockMessageHandled(
+					m,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value,
+					nil,
+				)
+			},
+			wildcard:    true,
+			expectedErr: mockErr,
+			kind:        FailureHandler,
+		},
+		{
+			name: "final payload multiple handlers",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+
+				// Fail our first handler, and assert that the
+				// payload is still delivered to our second
+				// handler.
+				mockMessageHandled(
+					m,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value,
+					mockErr,
+				)
+				mockMessageHandled(
+					m,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value,
+					nil,
+				)
+			},
+			handlerCount: 2,
+			expectedErr:  mockErr,
+			kind:         FailureHandler,
+		},
+		{
+			name:      "incoming interceptor rejects",
+			msg:       *msg,
+			setupMock: func(m *mock.Mock) {},
+			incoming: []IncomingInterceptor{
+				func(lndclient.CustomMessage) error {
+					return nil
+				},
+				func(lndclient.CustomMessage) error {
+					return mockErr
+				},
+			},
+			expectedErr: ErrIntercepted,
+			kind:        FailurePolicyDrop,
+		},
+		{
+			name: "dispatch interceptor rejects",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+			},
+			dispatch: []DispatchInterceptor{
+				func(route.Vertex,
+					*lnwire.OnionMessagePayload) error {
+
+					return mockErr
+				},
+			},
+			expectedErr: ErrIntercepted,
+			kind:        FailurePolicyDrop,
+		},
+		{
+			name: "interceptors allow message",
+			msg:  *msg,
+			setupMock: func(m *mock.Mock) {
+				packet := &sphinx.ProcessedPacket{
+					Action: sphinx.ExitNode,
+				}
+				mockProcessOnion(m, blinding, packet, nil)
+				mockPayloadDecode(m, payloadWithFinal, nil)
+
+				mockMessageHandled(
+					m,
+					payloadWithFinal.ReplyPath,
+					payloadWithFinal.EncryptedData,
+					finalHopPayload.Value,
+					nil,
+				)
+			},
+			incoming: []IncomingInterceptor{
+				func(lndclient.CustomMessage) error {
+					return nil
+				},
+			},
+			dispatch: []DispatchInterceptor{
+				func(route.Vertex,
+					*lnwire.OnionMessagePayload) error {
+
+					return nil
+				},
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Setup a mock and prime it with the test's
+			// requirements.
+			mock := &handleOnionMesageMock{
+				Mock: &mock.Mock{},
+			}
+
+			testCase.setupMock(mock.Mock)
+			defer mock.AssertExpectations(t)
+
+			// Register our mocked handler the number of times
+			// required by the test (defaulting to once).
+			handlerCount := testCase.handlerCount
+			if handlerCount == 0 {
+				handlerCount = 1
+			}
+
+			typeHandlers := make(
+				[]OnionMessageHandler, handlerCount,
+			)
+			for i := range typeHandlers {
+				typeHandlers[i] = mock.OnionMessageHandler
+			}
+
+			handlers := map[tlv.Type][]OnionMessageHandler{
+				finalHopPayload.TLVType: typeHandlers,
+			}
+
+			kit := &onionMessageKit{
+				processOnion:    mock.processOnion,
+				decodePayload:   mock.DecodePayload,
+				decryptDataBlob: mock.DecryptBlob,
+				forwardMessage:  mock.ForwardMessage,
+				handlers:        handlers,
+
+				incomingInterceptors: testCase.incoming,
+				dispatchInterceptors: testCase.dispatch,
+			}
+
+			unhandled := 0
+			kit.unhandled = func(tlv.Type) {
+				unhandled++
+			}
+
+			if testCase.checkPathID {
+				kit.verifyPathID = mock.VerifyPathID
+			}
+
+			if testCase.wildcard {
+				kit.wildcard = mock.WildcardHandler
+			}
+
+			err := handleOnionMessage(testCase.msg, kit)
+			require.True(t, errors.Is(err, testCase.expectedErr))
+			require.Equal(t, testCase.kind, FailureKindOf(err))
+			require.Equal(t, testCase.unhandled, unhandled)
+		})
+	}
+}
+
+// receiveMessageHandler is the function signature for handlers that drive
+// tests for our receive message loop.
+type receiveMessageHandler func(*testing.T, chan<- lndclient.CustomMessage,
+	chan<- error)
+
+// sendMsg is a helped that sends a custom message into the channel provided,
+// failing the test if it is not delivered on time.
+func sendMsg(t *testing.T, msgChan chan<- lndclient.CustomMessage,
+	msg lndclient.CustomMessage) {
+
+	select {
+	case msgChan <- msg:
+	case <-time.After(defaultTimeout):
+		t.Fatalf("could not send message: %v", msg)
+	}
+}
+
+// sendErr is a helper that sends an error into the channel provided, failing
+// the test if it is not delivered in time.
+func sendErr(t *testing.T, errChan chan<- error, err error) {
+	select {
+	case errChan <- err:
+	case <-time.After(defaultTimeout):
+		t.Fatalf("could not send error: %v", err)
+	}
+}
+
+// TestReceiveOnionMessages tests the messenger's receive loop for messages.
+func TestReceiveOnionMessages(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 2)
+
+	// Create an onion message that is *to our node* that we can use
+	// across tests. The message itself can be junk, because we're not
+	// reading it in this test.
+	nodePubkey := privkeys[0].PubKey()
+	onionMsg := lnwire.NewOnionMessage(
+		nodePubkey, make([]byte, lnwire.StandardOnionBlobSize),
+	)
+
+	msg, err := customOnionMessage(
+		nodePubkey, lnwire.OnionMessageType, onionMsg,
+	)
+	require.NoError(t, err, "custom message")
+
+	mockErr := errors.New("mock")
+
+	tests := []struct {
+		name          string
+		handler       receiveMessageHandler
+		expectedError error
+	}{
+		{
+			name: "message sent",
+			handler: func(t *testing.T,
+				msgChan chan<- lndclient.CustomMessage,
+				errChan chan<- error) {
+
+				sendMsg(t, msgChan, *msg)
+			},
+		}, {
+			name: "non-onion message",
+			handler: func(t *testing.T,
+				msgChan chan<- lndclient.CustomMessage,
+				errChan chan<- error) {
+
+				msg := lndclient.CustomMessage{
+					MsgType: 1001,
+				}
+
+				sendMsg(t, msgChan, msg)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "lnd shutdown - messages",
+			handler: func(t *testing.T,
+				msgChan chan<- lndclient.CustomMessage,
+				errChan chan<- error) {
 
 				close(msgChan)
 			},
@@ -802,7 +1921,836 @@ func TestReceiveOnionMessages(t *testing.T) {
 
 				sendErr(t, errChan, mockErr)
 			},
-			expectedError: mockErr,
+			expectedError: mockErr,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			testReceiveOnionMessages(
+				t, privkeys[0], testCase.handler,
+				testCase.expectedError,
+			)
+		})
+	}
+}
+
+func testReceiveOnionMessages(t *testing.T, privkey *btcec.PrivateKey,
+	handler receiveMessageHandler, expectedErr error) {
+
+	// Create a simple node key ecdh impl for our messenger.
+	nodeKeyECDH := &sphinx.PrivKeyECDH{
+		PrivKey: privkey,
+	}
+
+	// Setup a mocked lnd and prime it to have SubscribeCustomMessages
+	// called.
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	// Create channels to deliver messages and fail if they block for too
+	// long.
+	var (
+		msgChan = make(chan lndclient.CustomMessage)
+		errChan = make(chan error)
+
+		shutdownChan    = make(chan error)
+		requestShutdown = func(err error) {
+			select {
+			case shutdownChan <- err:
+			case <-time.After(defaultTimeout):
+				t.Fatalf("did not shutdown with: %v", err)
+			}
+		}
+	)
+
+	// Set up our mock to return our message channels when we subscribe to
+	// custom lnd messages.
+	// Note: might be wrong types?
+	testutils.MockSubscribeCustomMessages(
+		lnd.Mock, msgChan, errChan, nil,
+	)
+
+	messenger, err := NewOnionMessenger(
+		lnd, nodeKeyECDH,
+		requestShutdown,
+	)
+	require.NoError(t, err, "new messenger")
+
+	// Disable resubscription so that stream failures are surfaced
+	// immediately.
+	messenger.resubscribeAttempts = 0
+
+	err = messenger.Start()
+	require.NoError(t, err, "start messenger")
+
+	// Shutdown our messenger at the end of the test.
+	defer func() {
+		err := messenger.Stop()
+		require.NoError(t, err, "stop messenger")
+	}()
+
+	// Run the specific test's handler.
+	handler(t, msgChan, errChan)
+
+	// If we expect to exit with an error, expect it to be surfaced through
+	// requesting a graceful shutdown.
+	if expectedErr != nil {
+		select {
+		case err := <-shutdownChan:
+			require.True(t, errors.Is(err, expectedErr), "shutdown")
+
+		case <-time.After(defaultTimeout):
+			t.Fatal("no shutdown error recieved")
+		}
+	}
+}
+
+// TestInboundQueue tests applying our drop policy when our inbound queue of
+// onion messages is full.
+func TestInboundQueue(t *testing.T) {
+	msgs := []lndclient.CustomMessage{
+		{Data: []byte{1}},
+		{Data: []byte{2}},
+		{Data: []byte{3}},
+	}
+
+	tests := []struct {
+		name     string
+		policy   DropPolicy
+		expected []lndclient.CustomMessage
+	}{
+		{
+			name:     "drop newest",
+			policy:   DropNewest,
+			expected: msgs[:2],
+		},
+		{
+			name:     "drop oldest",
+			policy:   DropOldest,
+			expected: msgs[1:],
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			// Create a messenger with a queue that only has space
+			// for two messages, and no workers consuming from it.
+			messenger, err := NewOnionMessenger(nil, nil, nil)
+			require.NoError(t, err, "new messenger")
+			messenger.dropPolicy = testCase.policy
+			messenger.incoming = make(
+				chan lndclient.CustomMessage, 2,
+			)
+
+			for _, msg := range msgs {
+				messenger.enqueueIncoming(msg)
+			}
+
+			require.EqualValues(t, 1, messenger.DroppedMessages())
+
+			stats := messenger.PeerStats()[msgs[0].Peer]
+			require.EqualValues(t, 1, stats.QueueDropped)
+
+			close(messenger.incoming)
+
+			var queued []lndclient.CustomMessage
+			for msg := range messenger.incoming {
+				queued = append(queued, msg)
+			}
+
+			require.Equal(t, testCase.expected, queued)
+		})
+	}
+}
+
+// TestResubscribe tests resubscription to lnd's custom message stream when
+// our subscription fails.
+func TestResubscribe(t *testing.T) {
+	nodeKeyECDH := &sphinx.PrivKeyECDH{
+		PrivKey: testutils.GetPrivkeys(t, 1)[0],
+	}
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	// Prime our mock with three subscriptions, each with their own set
+	// of channels.
+	var (
+		msgChans = make([]chan lndclient.CustomMessage, 3)
+		errChans = make([]chan error, 3)
+	)
+
+	for i := range msgChans {
+		msgChans[i] = make(chan lndclient.CustomMessage)
+		errChans[i] = make(chan error)
+
+		testutils.MockSubscribeCustomMessages(
+			lnd.Mock, msgChans[i], errChans[i], nil,
+		)
+	}
+
+	var (
+		mockErr      = errors.New("mock")
+		shutdownChan = make(chan error, 1)
+	)
+
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, func(err error) {
+		shutdownChan <- err
+	})
+	require.NoError(t, err, "new messenger")
+
+	// Allow a single resubscription after consecutive failures.
+	messenger.resubscribeAttempts = 1
+	messenger.resubscribeBackoff = time.Millisecond
+
+	require.NoError(t, messenger.Start(), "start messenger")
+	defer func() {
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	}()
+
+	// Fail our first subscription, then deliver a message on our second
+	// subscription to show that we've resubscribed. This resets our
+	// count of consecutive failures.
+	nonOnion := lndclient.CustomMessage{
+		MsgType: 1001,
+	}
+
+	sendErr(t, errChans[0], mockErr)
+	sendMsg(t, msgChans[1], nonOnion)
+
+	// Fail our second subscription, which should be followed by our final
+	// resubscription. When this subscription fails without delivering
+	// any messages, we have failed twice in a row so we expect to exit
+	// with an error.
+	close(msgChans[1])
+	sendErr(t, errChans[2], mockErr)
+
+	select {
+	case err := <-shutdownChan:
+		require.True(t, errors.Is(err, mockErr), "shutdown: %v", err)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("no shutdown error received")
+	}
+}
+
+// TestResubscribeHealthy tests that our count of consecutive subscription
+// failures is reset once a resubscribed stream has stayed up for our healthy
+// interval, even if it does not deliver any messages.
+func TestResubscribeHealthy(t *testing.T) {
+	nodeKeyECDH := &sphinx.PrivKeyECDH{
+		PrivKey: testutils.GetPrivkeys(t, 1)[0],
+	}
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	var (
+		msgChans = make([]chan lndclient.CustomMessage, 3)
+		errChans = make([]chan error, 3)
+	)
+
+	for i := range msgChans {
+		msgChans[i] = make(chan lndclient.CustomMessage)
+		errChans[i] = make(chan error)
+
+		testutils.MockSubscribeCustomMessages(
+			lnd.Mock, msgChans[i], errChans[i], nil,
+		)
+	}
+
+	var (
+		mockErr      = errors.New("mock")
+		shutdownChan = make(chan error, 1)
+	)
+
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, func(err error) {
+		shutdownChan <- err
+	})
+	require.NoError(t, err, "new messenger")
+
+	// Allow a single resubscription after consecutive failures, and
+	// consider a resubscribed stream healthy almost immediately.
+	messenger.resubscribeAttempts = 1
+	messenger.resubscribeBackoff = time.Millisecond
+	messenger.resubscribeHealthy = time.Millisecond
+
+	require.NoError(t, messenger.Start(), "start messenger")
+	defer func() {
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	}()
+
+	// Fail our first subscription, and wait for our second subscription
+	// to stay up for our healthy interval without delivering messages.
+	sendErr(t, errChans[0], mockErr)
+	time.Sleep(messenger.resubscribeHealthy * 50)
+
+	// Failing our second subscription should not exceed our attempts,
+	// because our failure count was reset, so we expect to resubscribe
+	// again rather than shutting down.
+	sendErr(t, errChans[1], mockErr)
+
+	select {
+	case err := <-shutdownChan:
+		t.Fatalf("unexpected shutdown: %v", err)
+
+	case errChans[2] <- mockErr:
+	case <-time.After(defaultTimeout):
+		t.Fatal("no resubscription")
+	}
+
+	// Our third subscription failed before it was healthy, so we have
+	// now failed twice in a row and expect to exit with an error.
+	select {
+	case err := <-shutdownChan:
+		require.True(t, errors.Is(err, mockErr), "shutdown: %v", err)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("no shutdown error received")
+	}
+}
+
+// TestHandleRegistration tests registration of handlers for tlv payloads.
+func TestHandleRegistration(t *testing.T) {
+	var (
+		invalidTlv tlv.Type = 10
+		validTlv   tlv.Type = 100
+
+		handler = func(*lnwire.ReplyPath, []byte, []byte) error {
+			return nil
+		}
+
+		wildcard = func(tlv.Type, *lnwire.ReplyPath, []byte,
+			[]byte) error {
+
+			return nil
+		}
+
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: testutils.GetPrivkeys(t, 1)[0],
+		}
+	)
+
+	// Assert that our test tlv values have the validity we expect.
+	require.Nil(t, lnwire.ValidateFinalPayload(validTlv))
+
+	err := lnwire.ValidateFinalPayload(invalidTlv)
+	require.True(t, errors.Is(err, lnwire.ErrNotFinalPayload))
+
+	// Setups a mock lnd. We need this to subscribe to incoming messages,
+	// even though we're not testing message handling in this test. W
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	// Prime our mock for our startup call, using nil channels because we
+	// won't actually deliver messages.
+	testutils.MockSubscribeCustomMessages(
+		lnd.Mock, nil, nil, nil,
+	)
+
+	// Create a messenger, but don't start it yet.
+	messenger, err := NewOnionMessenger(
+		lnd, nodeKeyECDH, nil,
+	)
+	require.NoError(t, err, "new messenger")
+
+	// We can register and deregister handlers before we're started.
+	id0, err := messenger.RegisterHandler(validTlv, handler)
+	require.NoError(t, err, "register before start")
+
+	_, err = messenger.RegisterHandler(invalidTlv, handler)
+	require.True(t, errors.Is(err, lnwire.ErrNotFinalPayload))
+
+	messenger.handlerLock.RLock()
+	require.Len(t, messenger.onionMsgHandlers[validTlv], 1)
+	messenger.handlerLock.RUnlock()
+
+	require.NoError(t, messenger.DeregisterHandler(validTlv, id0))
+
+	err = messenger.DeregisterHandler(validTlv, id0)
+	require.True(t, errors.Is(err, ErrHandlerNotFound))
+
+	// Start our messenger. We'll shut it down manually later, so we don't
+	// defer stop here.
+	require.NoError(t, messenger.Start(), "start messenger")
+
+	// Now that we're started, we should be able to register with no issue.
+	id1, err := messenger.RegisterHandler(validTlv, handler)
+	require.NoError(t, err, "valid tlv register")
+
+	// Register a second handler with the same type, which should succeed
+	// with a different ID.
+	id2, err := messenger.RegisterHandler(validTlv, handler)
+	require.NoError(t, err, "second register")
+	require.NotEqual(t, id1, id2)
+
+	messenger.handlerLock.RLock()
+	require.Len(t, messenger.onionMsgHandlers[validTlv], 2)
+	messenger.handlerLock.RUnlock()
+
+	// Try to register a handler for an out-of-range tlv type, expect
+	// failure.
+	_, err = messenger.RegisterHandler(invalidTlv, handler)
+	require.True(t, errors.Is(err, lnwire.ErrNotFinalPayload))
+
+	// Try to de-register our existing handlers, we should succeed.
+	require.NoError(t, messenger.DeregisterHandler(validTlv, id1))
+
+	messenger.handlerLock.RLock()
+	require.Len(t, messenger.onionMsgHandlers[validTlv], 1)
+	messenger.handlerLock.RUnlock()
+
+	require.NoError(t, messenger.DeregisterHandler(validTlv, id2))
+
+	// Try to de-register a handler that's no longer registered, we should
+	// get an error.
+	err = messenger.DeregisterHandler(validTlv, id1)
+	require.True(t, errors.Is(err, ErrHandlerNotFound))
+
+	// Register a wildcard handler, which should not conflict with any
+	// type-specific handlers.
+	err = messenger.RegisterWildcardHandler(wildcard)
+	require.NoError(t, err, "wildcard register")
+
+	_, err = messenger.RegisterHandler(validTlv, handler)
+	require.NoError(t, err, "register with wildcard")
+
+	// We only allow a single wildcard handler.
+	err = messenger.RegisterWildcardHandler(wildcard)
+	require.True(t, errors.Is(err, ErrHandlerRegistered))
+
+	err = messenger.DeregisterWildcardHandler()
+	require.NoError(t, err, "wildcard deregister")
+
+	err = messenger.DeregisterWildcardHandler()
+	require.True(t, errors.Is(err, ErrHandlerNotFound))
+
+	// Shut down our messenger to test registration requests during
+	// shutdown.
+	require.NoError(t, messenger.Stop(), "stop messenger")
+
+	_, err = messenger.RegisterHandler(validTlv, handler)
+	require.True(t, errors.Is(err, ErrShuttingDown))
+}
+
+// TestHandlerLifecycle tests deregistration of handlers that are bound to a
+// context, and deregistration of all handlers.
+func TestHandlerLifecycle(t *testing.T) {
+	var (
+		tlvType tlv.Type = 100
+
+		handler = func(*lnwire.ReplyPath, []byte, []byte) error {
+			return nil
+		}
+
+		wildcard = func(tlv.Type, *lnwire.ReplyPath, []byte,
+			[]byte) error {
+
+			return nil
+		}
+
+		ctxb = context.Background()
+	)
+
+	messenger := newMemoryMessenger(
+		t, NewMemoryNetwork(), testutils.GetPrivkeys(t, 1)[0],
+		OptionProbeResponder(),
+	)
+
+	noHandlers := func(tlvType tlv.Type) func() bool {
+		return func() bool {
+			messenger.handlerLock.RLock()
+			defer messenger.handlerLock.RUnlock()
+
+			return len(messenger.onionMsgHandlers[tlvType]) == 0
+		}
+	}
+
+	// Register a handler bound to a context, and assert that it is
+	// removed once we cancel the context.
+	ctx, cancel := context.WithCancel(ctxb)
+	_, err := messenger.RegisterHandlerContext(ctx, tlvType, handler)
+	require.NoError(t, err, "register with context")
+	require.False(t, noHandlers(tlvType)())
+
+	cancel()
+	require.Eventually(
+		t, noHandlers(tlvType), defaultTimeout, time.Millisecond*10,
+	)
+
+	// Handlers bound to a context may also be removed manually, in which
+	// case cancelling the context has no further effect.
+	ctx, cancel = context.WithCancel(ctxb)
+	id, err := messenger.RegisterHandlerContext(ctx, tlvType, handler)
+	require.NoError(t, err, "register with context")
+	require.NoError(t, messenger.DeregisterHandler(tlvType, id))
+	cancel()
+
+	// Register a mix of handlers, and assert that they are all removed
+	// apart from our internal probe responder.
+	_, err = messenger.RegisterHandler(tlvType, handler)
+	require.NoError(t, err, "register")
+
+	_, err = messenger.RegisterHandler(lnwire.ProbeType, handler)
+	require.NoError(t, err, "register probe type")
+
+	require.NoError(t, messenger.RegisterWildcardHandler(wildcard))
+	require.NoError(t, messenger.DeregisterAll())
+
+	messenger.handlerLock.RLock()
+	require.Len(t, messenger.onionMsgHandlers, 1)
+	probeHandlers := messenger.onionMsgHandlers[lnwire.ProbeType]
+	require.Len(t, probeHandlers, 1)
+	require.True(t, probeHandlers[0].internal)
+	require.Nil(t, messenger.wildcardHandler)
+	messenger.handlerLock.RUnlock()
+}
+
+// onionToSelf creates a custom message containing an onion message that is
+// addressed to the node key provided, with a single final hop payload. Fresh
+// session and blinding keys are used for each message so that they are not
+// rejected as replays.
+func onionToSelf(t *testing.T, nodeKey *btcec.PrivateKey, tlvType tlv.Type,
+	value []byte) lndclient.CustomMessage {
+
+	return onionToSelfHops(t, nodeKey, 1, tlvType, value)
+}
+
+// onionToSelfHops creates an onion message that passes through our node the
+// number of times provided, with all but the last hop acting as dummy hops.
+func onionToSelfHops(t *testing.T, nodeKey *btcec.PrivateKey, hops int,
+	tlvType tlv.Type, value []byte) lndclient.CustomMessage {
+
+	path := make([]*btcec.PublicKey, hops)
+	for i := range path {
+		path[i] = nodeKey.PubKey()
+	}
+
+	sessionKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "session key")
+
+	blindingKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "blinding key")
+
+	req := routes.NewBlindedRouteRequest(
+		sessionKey, blindingKey, path, nil, nil,
+		[]*lnwire.FinalHopPayload{
+			{
+				TLVType: tlvType,
+				Value:   value,
+			},
+		},
+	)
+
+	resp, err := routes.CreateBlindedRoute(req)
+	require.NoError(t, err, "blinded route")
+
+	msg, err := customOnionMessage(
+		resp.FirstNode, lnwire.OnionMessageType, resp.OnionMessage,
+	)
+	require.NoError(t, err, "custom message")
+
+	return *msg
+}
+
+// TestHandlerWorkers tests that a slow handler for one incoming onion message
+// does not prevent us from handling other incoming messages.
+func TestHandlerWorkers(t *testing.T) {
+	var (
+		privkey              = testutils.GetPrivkeys(t, 1)[0]
+		tlvType     tlv.Type = 100
+		slowPayload          = []byte{1}
+		fastPayload          = []byte{2}
+
+		release     = make(chan struct{})
+		releaseOnce sync.Once
+		handled     = make(chan []byte, 2)
+
+		msgChan = make(chan lndclient.CustomMessage)
+		errChan = make(chan error)
+	)
+
+	// Our handler will block on the slow payload until we release it.
+	handler := func(_ *lnwire.ReplyPath, _, value []byte) error {
+		if bytes.Equal(value, slowPayload) {
+			<-release
+		}
+
+		handled <- value
+
+		return nil
+	}
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	testutils.MockSubscribeCustomMessages(
+		lnd.Mock, msgChan, errChan, nil,
+	)
+
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkey}, nil,
+	)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, messenger.Start(), "start messenger")
+
+	// Make sure that our slow handler is released before we stop, so that
+	// we don't block shutdown if the test fails.
+	releaseHandler := func() {
+		releaseOnce.Do(func() {
+			close(release)
+		})
+	}
+
+	defer func() {
+		releaseHandler()
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	}()
+
+	_, err = messenger.RegisterHandler(tlvType, handler)
+	require.NoError(t, err, "register handler")
+
+	// Send our slow message, followed by our fast message. We expect the
+	// fast message to be handled while the slow one is still blocked.
+	sendMsg(t, msgChan, onionToSelf(t, privkey, tlvType, slowPayload))
+	sendMsg(t, msgChan, onionToSelf(t, privkey, tlvType, fastPayload))
+
+	select {
+	case value := <-handled:
+		require.Equal(t, fastPayload, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("fast message not handled")
+	}
+
+	// Release our slow handler and assert that it completes.
+	releaseHandler()
+
+	select {
+	case value := <-handled:
+		require.Equal(t, slowPayload, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("slow message not handled")
+	}
+}
+
+// TestDummyHops tests that we process onion messages that pass through our own
+// node as dummy hops before reaching us as the final hop.
+func TestDummyHops(t *testing.T) {
+	var (
+		privkey          = testutils.GetPrivkeys(t, 1)[0]
+		tlvType tlv.Type = 100
+		payload          = []byte{1, 2, 3}
+		handled          = make(chan []byte, 1)
+
+		msgChan = make(chan lndclient.CustomMessage)
+		errChan = make(chan error)
+	)
+
+	handler := func(_ *lnwire.ReplyPath, _, value []byte) error {
+		handled <- value
+		return nil
+	}
+
+	// We don't prime our mock to send any custom messages, because we
+	// expect our dummy hops to be handled without sending to lnd.
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	testutils.MockSubscribeCustomMessages(
+		lnd.Mock, msgChan, errChan, nil,
+	)
+
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkey}, nil,
+	)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, messenger.Start(), "start messenger")
+	defer func() {
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	}()
+
+	_, err = messenger.RegisterHandler(tlvType, handler)
+	require.NoError(t, err, "register handler")
+
+	sendMsg(t, msgChan, onionToSelfHops(t, privkey, 3, tlvType, payload))
+
+	select {
+	case value := <-handled:
+		require.Equal(t, payload, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("message with dummy hops not handled")
+	}
+}
+
+// TestEndpointOnly tests that we refuse to relay onion messages when running
+// in endpoint-only mode.
+func TestEndpointOnly(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 2)
+
+	var (
+		peer        = route.Vertex{1}
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
+		nextNode = privkeys[1].PubKey()
+
+		data = &lnwire.BlindedRouteData{
+			NextNodeID: nextNode,
+		}
+		packet = &sphinx.OnionPacket{
+			EphemeralKey: nextNode,
+		}
+	)
+
+	messenger, err := NewOnionMessenger(
+		nil, nodeKeyECDH, nil, OptionEndpointOnly(),
+	)
+	require.NoError(t, err, "new messenger")
+
+	blinding := privkeys[0].PubKey()
+	err = messenger.forwardMessage(peer, data, blinding, packet)
+	require.True(t, errors.Is(err, ErrForwardingDisabled))
+	require.Len(t, messenger.forwardQueue, 0)
+}
+
+// TestForwardBlindingOverride tests that we switch to the next blinding
+// point provided in a hop's encrypted data when forwarding, so that we can
+// relay messages across concatenated blinded paths.
+func TestForwardBlindingOverride(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 3)
+
+	var (
+		peer        = route.Vertex{1}
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
+		nextNode = privkeys[1].PubKey()
+		override = privkeys[2].PubKey()
+		blinding = privkeys[0].PubKey()
+
+		packet = &sphinx.OnionPacket{
+			EphemeralKey: nextNode,
+		}
+	)
+
+	nextEphemeral, err := sphinx.NextEphemeral(nodeKeyECDH, blinding)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		override *btcec.PublicKey
+		expected *btcec.PublicKey
+	}{
+		{
+			name:     "no override",
+			expected: nextEphemeral,
+		},
+		{
+			name:     "blinding override",
+			override: override,
+			expected: override,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			messenger, err := NewOnionMessenger(
+				nil, nodeKeyECDH, nil,
+			)
+			require.NoError(t, err, "new messenger")
+
+			data := &lnwire.BlindedRouteData{
+				NextNodeID:           nextNode,
+				NextBlindingOverride: testCase.override,
+			}
+			require.NoError(t, messenger.forwardMessage(
+				peer, data, blinding, packet,
+			))
+
+			customMsg := (<-messenger.forwardQueue).msg
+			require.Equal(
+				t, route.NewVertex(nextNode), customMsg.Peer,
+			)
+
+			msg := &lnwire.OnionMessage{}
+			require.NoError(t, msg.Decode(
+				bytes.NewReader(customMsg.Data), 0,
+			))
+			require.True(
+				t, testCase.expected.IsEqual(msg.BlindingPoint),
+			)
+		})
+	}
+}
+
+// TestForwardSCID tests forwarding of onion messages that identify the next
+// node by the short channel id of our channel with it.
+func TestForwardSCID(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 3)
+
+	var (
+		peer        = route.Vertex{1}
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
+		ourNode   = route.NewVertex(privkeys[0].PubKey())
+		nextNode  = route.NewVertex(privkeys[1].PubKey())
+		otherNode = route.NewVertex(privkeys[2].PubKey())
+		blinding  = privkeys[0].PubKey()
+
+		scid = lndwire.NewShortChanIDFromInt(1234)
+		data = &lnwire.BlindedRouteData{
+			NextSCID: &scid,
+		}
+		packet = &sphinx.OnionPacket{
+			EphemeralKey: privkeys[1].PubKey(),
+		}
+
+		mockErr = errors.New("mock")
+	)
+
+	tests := []struct {
+		name    string
+		edge    *lndclient.ChannelEdge
+		edgeErr error
+		err     error
+	}{
+		{
+			name: "we are node 1",
+			edge: &lndclient.ChannelEdge{
+				Node1: ourNode,
+				Node2: nextNode,
+			},
+		},
+		{
+			name: "we are node 2",
+			edge: &lndclient.ChannelEdge{
+				Node1: nextNode,
+				Node2: ourNode,
+			},
+		},
+		{
+			name: "not our channel",
+			edge: &lndclient.ChannelEdge{
+				Node1: nextNode,
+				Node2: otherNode,
+			},
+			err: ErrSCIDNotOurs,
+		},
+		{
+			name:    "lookup failed",
+			edgeErr: mockErr,
+			err:     mockErr,
 		},
 	}
 
@@ -810,152 +2758,132 @@ func TestReceiveOnionMessages(t *testing.T) {
 		testCase := testCase
 
 		t.Run(testCase.name, func(t *testing.T) {
-			testReceiveOnionMessages(
-				t, privkeys[0], testCase.handler,
-				testCase.expectedError,
+			lnd := testutils.NewMockLnd()
+			defer lnd.Mock.AssertExpectations(t)
+
+			testutils.MockGetChanInfo(
+				lnd.Mock, scid.ToUint64(), testCase.edge,
+				testCase.edgeErr,
+			)
+
+			messenger, err := NewOnionMessenger(
+				lnd, nodeKeyECDH, nil,
+			)
+			require.NoError(t, err, "new messenger")
+
+			err = messenger.forwardMessage(
+				peer, data, blinding, packet,
 			)
+			require.True(t, errors.Is(err, testCase.err))
+
+			if testCase.err != nil {
+				require.Len(t, messenger.forwardQueue, 0)
+				return
+			}
+
+			customMsg := (<-messenger.forwardQueue).msg
+			require.Equal(t, nextNode, customMsg.Peer)
 		})
 	}
 }
 
-func testReceiveOnionMessages(t *testing.T, privkey *btcec.PrivateKey,
-	handler receiveMessageHandler, expectedErr error) {
+// TestForwardQueue tests queuing of onion messages for forwarding.
+func TestForwardQueue(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 2)
 
-	// Create a simple node key ecdh impl for our messenger.
-	nodeKeyECDH := &sphinx.PrivKeyECDH{
-		PrivKey: privkey,
-	}
+	var (
+		peer        = route.Vertex{1}
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
+		nextNode = privkeys[1].PubKey()
+
+		data = &lnwire.BlindedRouteData{
+			NextNodeID: nextNode,
+		}
+		packet = &sphinx.OnionPacket{
+			EphemeralKey: nextNode,
+		}
+	)
 
-	// Setup a mocked lnd and prime it to have SubscribeCustomMessages
-	// called.
 	lnd := testutils.NewMockLnd()
 	defer lnd.Mock.AssertExpectations(t)
 
-	// Create channels to deliver messages and fail if they block for too
-	// long.
-	var (
-		msgChan = make(chan lndclient.CustomMessage)
-		errChan = make(chan error)
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, nil)
+	require.NoError(t, err, "new messenger")
 
-		shutdownChan    = make(chan error)
-		requestShutdown = func(err error) {
-			select {
-			case shutdownChan <- err:
-			case <-time.After(defaultTimeout):
-				t.Fatalf("did not shutdown with: %v", err)
-			}
-		}
-	)
+	// Shrink our queue so that we can fill it up, then queue a message
+	// for forwarding.
+	messenger.forwardQueue = make(chan forwardRequest, 1)
 
-	// Set up our mock to return our message channels when we subscribe to
-	// custom lnd messages.
-	// Note: might be wrong types?
-	testutils.MockSubscribeCustomMessages(
-		lnd.Mock, msgChan, errChan, nil,
-	)
+	blinding := privkeys[0].PubKey()
+	require.NoError(t, messenger.forwardMessage(
+		peer, data, blinding, packet,
+	))
 
-	messenger := NewOnionMessenger(
-		lnd, nodeKeyECDH,
-		requestShutdown,
-	)
-	err := messenger.Start()
-	require.NoError(t, err, "start messenger")
+	// Now that our queue is full, we expect further messages to be
+	// dropped rather than blocking.
+	err = messenger.forwardMessage(peer, data, blinding, packet)
+	require.True(t, errors.Is(err, ErrForwardQueueFull))
 
-	// Shutdown our messenger at the end of the test.
-	defer func() {
-		err := messenger.Stop()
-		require.NoError(t, err, "stop messenger")
-	}()
+	// Start our messenger and assert that our queued message is sent to
+	// the next node.
+	testutils.MockSubscribeCustomMessages(lnd.Mock, nil, nil, nil)
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
 
-	// Run the specific test's handler.
-	handler(t, msgChan, errChan)
+	require.NoError(t, messenger.Start(), "start messenger")
 
-	// If we expect to exit with an error, expect it to be surfaced through
-	// requesting a graceful shutdown.
-	if expectedErr != nil {
-		select {
-		case err := <-shutdownChan:
-			require.True(t, errors.Is(err, expectedErr), "shutdown")
+	require.Eventually(t, func() bool {
+		return len(messenger.forwardQueue) == 0
+	}, defaultTimeout, time.Millisecond*10)
 
-		case <-time.After(defaultTimeout):
-			t.Fatal("no shutdown error recieved")
-		}
-	}
+	// Once we've stopped, our message will have been delivered to lnd.
+	require.NoError(t, messenger.Stop(), "stop messenger")
 }
 
-// TestHandleRegistration tests registration of handlers for tlv payloads.
-func TestHandleRegistration(t *testing.T) {
-	var (
-		invalidTlv tlv.Type = 10
-		validTlv   tlv.Type = 100
+// TestForwardFailureBreaker tests that failures to send forwarded messages
+// onward count towards the breaker of the peer that sent us the message.
+func TestForwardFailureBreaker(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 2)
 
-		handler = func(*lnwire.ReplyPath, []byte, []byte) error {
-			return nil
+	var (
+		peer        = route.Vertex{1}
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
 		}
+		nextNode = privkeys[1].PubKey()
 
-		nodeKeyECDH = &sphinx.PrivKeyECDH{
-			PrivKey: testutils.GetPrivkeys(t, 1)[0],
+		data = &lnwire.BlindedRouteData{
+			NextNodeID: nextNode,
+		}
+		packet = &sphinx.OnionPacket{
+			EphemeralKey: nextNode,
 		}
 	)
 
-	// Assert that our test tlv values have the validity we expect.
-	require.Nil(t, lnwire.ValidateFinalPayload(validTlv))
-
-	err := lnwire.ValidateFinalPayload(invalidTlv)
-	require.True(t, errors.Is(err, lnwire.ErrNotFinalPayload))
-
-	// Setups a mock lnd. We need this to subscribe to incoming messages,
-	// even though we're not testing message handling in this test. W
 	lnd := testutils.NewMockLnd()
 	defer lnd.Mock.AssertExpectations(t)
 
-	// Prime our mock for our startup call, using nil channels because we
-	// won't actually deliver messages.
-	testutils.MockSubscribeCustomMessages(
-		lnd.Mock, nil, nil, nil,
-	)
-
-	// Create a messenger, but don't start it yet.
-	messenger := NewOnionMessenger(
-		lnd, nodeKeyECDH, nil,
-	)
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, nil)
+	require.NoError(t, err, "new messenger")
+	messenger.breaker = newPeerBreaker(1, time.Hour, time.Now)
 
-	// Assert the registration fails if we're not started.
-	err = messenger.RegisterHandler(validTlv, handler)
-	require.True(t, errors.Is(err, ErrNotStarted), "err: %v", err.Error())
+	testutils.MockSubscribeCustomMessages(lnd.Mock, nil, nil, nil)
+	testutils.MockSendAnyCustomMessage(lnd.Mock, errors.New("offline"))
 
-	// Start our messenger. We'll shut it down manually later, so we don't
-	// defer stop here.
 	require.NoError(t, messenger.Start(), "start messenger")
+	defer func() {
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	}()
 
-	// Now that we're started, we should be able to register with no issue.
-	err = messenger.RegisterHandler(validTlv, handler)
-	require.NoError(t, err, "valid tlv register")
-
-	// Try to re-register with the same type, we should fail.
-	err = messenger.RegisterHandler(validTlv, handler)
-	require.True(t, errors.Is(err, ErrHandlerRegistered))
-
-	// Try to register a handler for an out-of-range tlv type, expect
-	// failure.
-	err = messenger.RegisterHandler(invalidTlv, handler)
-	require.True(t, errors.Is(err, lnwire.ErrNotFinalPayload))
-
-	// Try to de-register our existing handler, we should succeed.
-	err = messenger.DeregisterHandler(validTlv)
-	require.NoError(t, err)
-
-	// Try to de-register a handler that's no longer registered, we should
-	// get an error.
-	err = messenger.DeregisterHandler(validTlv)
-	require.True(t, errors.Is(err, ErrHandlerNotFound))
-
-	// Shut down our messenger to test registration requests during
-	// shutdown.
-	require.NoError(t, messenger.Stop(), "stop messenger")
+	blinding := privkeys[0].PubKey()
+	require.NoError(t, messenger.forwardMessage(
+		peer, data, blinding, packet,
+	))
 
-	err = messenger.RegisterHandler(validTlv, handler)
-	require.True(t, errors.Is(err, ErrShuttingDown))
+	require.Eventually(t, func() bool {
+		return !messenger.breaker.allow(peer)
+	}, defaultTimeout, time.Millisecond*10)
 }
 
 // TestMultiHopPath tests selection of multi-hop onion message paths.
@@ -966,12 +2894,36 @@ func TestMultiHopPath(t *testing.T) {
 		node1   = route.NewVertex(pubkeys[1])
 		node2   = route.NewVertex(pubkeys[2])
 		mockErr = errors.New("mock err")
+
+		twoHops = &lndclient.QueryRoutesResponse{
+			Hops: []*lndclient.Hop{
+				{
+					ChannelID: 1,
+					PubKey:    &node1,
+				},
+				{
+					ChannelID: 2,
+					PubKey:    &node2,
+				},
+			},
+		}
+
+		relayInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
 	)
 	tests := []struct {
 		name            string
 		peer            *btcec.PublicKey
 		queryRoutesResp *lndclient.QueryRoutesResponse
 		queryRoutesErr  error
+		avoid           []*btcec.PublicKey
+		relayInfo       *lndclient.NodeInfo
+		relayErr        error
 		path            []*btcec.PublicKey
 		err             error
 	}{
@@ -1024,11 +2976,80 @@ func TestMultiHopPath(t *testing.T) {
 					},
 				},
 			},
+			relayInfo: relayInfo,
+			path: []*btcec.PublicKey{
+				pubkeys[1],
+				pubkeys[2],
+			},
+		},
+		{
+			name: "path via avoided node",
+			peer: peer,
+			queryRoutesResp: &lndclient.QueryRoutesResponse{
+				Hops: []*lndclient.Hop{
+					{
+						ChannelID: 1,
+						PubKey:    &node1,
+					},
+					{
+						ChannelID: 2,
+						PubKey:    &node2,
+					},
+				},
+			},
+			avoid: []*btcec.PublicKey{
+				pubkeys[1],
+			},
+			path: nil,
+		},
+		{
+			name: "avoided node is destination",
+			peer: peer,
+			queryRoutesResp: &lndclient.QueryRoutesResponse{
+				Hops: []*lndclient.Hop{
+					{
+						ChannelID: 1,
+						PubKey:    &node1,
+					},
+					{
+						ChannelID: 2,
+						PubKey:    &node2,
+					},
+				},
+			},
+			avoid: []*btcec.PublicKey{
+				pubkeys[2],
+			},
+			relayInfo: relayInfo,
 			path: []*btcec.PublicKey{
 				pubkeys[1],
 				pubkeys[2],
 			},
 		},
+		{
+			name:            "hop does not support onion messages",
+			peer:            peer,
+			queryRoutesResp: twoHops,
+			relayInfo: &lndclient.NodeInfo{
+				Node: &lndclient.Node{},
+			},
+			path: nil,
+		},
+		{
+			name:            "hop not found in graph",
+			peer:            peer,
+			queryRoutesResp: twoHops,
+			relayErr:        status.Error(codes.NotFound, "not found"),
+			path:            nil,
+		},
+		{
+			name:            "hop lookup fails",
+			peer:            peer,
+			queryRoutesResp: twoHops,
+			relayErr:        mockErr,
+			path:            nil,
+			err:             mockErr,
+		},
 	}
 
 	for _, testCase := range tests {
@@ -1046,8 +3067,19 @@ func TestMultiHopPath(t *testing.T) {
 				testCase.queryRoutesErr,
 			)
 
+			// If we expect to check our intermediate hop's
+			// features, prime our mock to look up the node.
+			if testCase.relayInfo != nil || testCase.relayErr != nil {
+				testutils.MockGetNodeInfo(
+					lnd.Mock, node1, false,
+					testCase.relayInfo, testCase.relayErr,
+				)
+			}
+
 			ctxb := context.Background()
-			path, err := multiHopPath(ctxb, lnd, testCase.peer)
+			path, err := multiHopPath(
+				ctxb, lnd, testCase.peer, testCase.avoid,
+			)
 			require.True(t, errors.Is(err, testCase.err))
 			require.Equal(t, testCase.path, path)
 		})
@@ -1056,7 +3088,7 @@ func TestMultiHopPath(t *testing.T) {
 
 // TestValidateSendMessageRequest tests validation of send message requests.
 func TestValidateSendMessageRequest(t *testing.T) {
-	pubkeys := testutils.GetPubkeys(t, 1)
+	pubkeys := testutils.GetPubkeys(t, 2)
 
 	tests := []struct {
 		name string
@@ -1083,6 +3115,43 @@ func TestValidateSendMessageRequest(t *testing.T) {
 			},
 			err: ErrNoBlindedHops,
 		},
+		{
+			name: "duplicate final payload",
+			req: &SendMessageRequest{
+				Peer: pubkeys[0],
+				FinalPayloads: []*lnwire.FinalHopPayload{
+					{TLVType: 101},
+					{TLVType: 101},
+				},
+			},
+			err: ErrDuplicatePayload,
+		},
+		{
+			name: "route does not end at target",
+			req: &SendMessageRequest{
+				Peer:  pubkeys[0],
+				Route: pubkeys[1:],
+			},
+			err: ErrInvalidRoute,
+		},
+		{
+			name: "route with avoid nodes",
+			req: &SendMessageRequest{
+				Peer:       pubkeys[0],
+				Route:      pubkeys[:1],
+				AvoidNodes: pubkeys[1:],
+			},
+			err: ErrInvalidRoute,
+		},
+		{
+			name: "valid - explicit route",
+			req: &SendMessageRequest{
+				Peer: pubkeys[0],
+				Route: []*btcec.PublicKey{
+					pubkeys[1], pubkeys[0],
+				},
+			},
+		},
 		{
 			name: "valid - cleartext peer",
 			req: &SendMessageRequest{
@@ -1110,3 +3179,105 @@ func TestValidateSendMessageRequest(t *testing.T) {
 		})
 	}
 }
+
+// TestMaxHops tests that we fail sends that exceed our maximum route length
+// before constructing an onion.
+func TestMaxHops(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 1)
+		pubkeys  = testutils.GetPubkeys(t, 3)
+		ctxb     = context.Background()
+	)
+
+	messenger, err := NewOnionMessenger(
+		nil, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionMaxHops(2),
+	)
+	require.NoError(t, err, "new messenger")
+
+	req := NewSendMessageRequest(pubkeys[2], nil, nil, nil, false)
+	err = messenger.sendAlongPath(ctxb, req, pubkeys)
+	require.True(t, errors.Is(err, routes.ErrTooManyHops))
+
+	// When we send to a blinded destination, our path's introduction node
+	// is the first hop in the blinded route, so our single hop path to
+	// it is combined with the three hops in the route.
+	req = NewSendMessageRequest(nil, &lnwire.ReplyPath{
+		FirstNodeID: pubkeys[0],
+		Hops: []*lnwire.BlindedHop{
+			{}, {}, {},
+		},
+	}, nil, nil, false)
+	err = messenger.sendAlongPath(ctxb, req, pubkeys[:1])
+	require.True(t, errors.Is(err, routes.ErrTooManyHops))
+}
+
+// TestHandlerTypeRegistry tests that handlers claim their tlv types in a
+// type registry shared by messengers.
+func TestHandlerTypeRegistry(t *testing.T) {
+	var (
+		tlvType tlv.Type = 101
+
+		registry = lnwire.NewTypeRegistry()
+
+		handler = func(*lnwire.ReplyPath, []byte, []byte) error {
+			return nil
+		}
+	)
+
+	newMessenger := func(t *testing.T) *Messenger {
+		messenger, err := NewOnionMessenger(
+			testutils.NewMockLnd(), &sphinx.PrivKeyECDH{
+				PrivKey: testutils.GetPrivkeys(t, 1)[0],
+			}, nil, OptionTypeRegistry(registry),
+		)
+		require.NoError(t, err, "new messenger")
+
+		return messenger
+	}
+
+	messenger1, messenger2 := newMessenger(t), newMessenger(t)
+
+	// Once our first messenger has a handler for our type, neither
+	// messenger can register another handler for it.
+	id, err := messenger1.RegisterHandler(tlvType, handler)
+	require.NoError(t, err, "register handler")
+
+	_, err = messenger1.RegisterHandler(tlvType, handler)
+	require.True(t, errors.Is(err, lnwire.ErrTypeClaimed))
+
+	_, err = messenger2.RegisterHandler(tlvType, handler)
+	require.True(t, errors.Is(err, lnwire.ErrTypeClaimed))
+
+	// We also can't register handlers for our built in types.
+	_, err = messenger2.RegisterHandler(lnwire.ProbeType, handler)
+	require.True(t, errors.Is(err, lnwire.ErrTypeClaimed))
+
+	// The bolt12 namespaces are not built in, so they can be claimed by a
+	// single handler.
+	invoiceID, err := messenger2.RegisterHandler(
+		lnwire.InvoiceNamespaceType, handler,
+	)
+	require.NoError(t, err, "register invoice handler")
+
+	_, err = messenger1.RegisterHandler(
+		lnwire.InvoiceNamespaceType, handler,
+	)
+	require.True(t, errors.Is(err, lnwire.ErrTypeClaimed))
+	require.NoError(t, messenger2.DeregisterHandler(
+		lnwire.InvoiceNamespaceType, invoiceID,
+	))
+
+	// Once the handler is removed, the type can be claimed by our other
+	// messenger.
+	require.NoError(t, messenger1.DeregisterHandler(tlvType, id))
+
+	_, err = messenger2.RegisterHandler(tlvType, handler)
+	require.NoError(t, err, "register after deregister")
+
+	// Removing all of our handlers releases their claims.
+	require.NoError(t, messenger2.DeregisterAll())
+
+	_, ok := registry.Owner(tlvType)
+	require.False(t, ok)
+}
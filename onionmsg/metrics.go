@@ -0,0 +1,110 @@
+package onionmsg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// DropReason describes the reason that we dropped an incoming onion message.
+type DropReason uint8
+
+const (
+	// DropReasonQueueFull indicates that a message was dropped because
+	// our inbound queue was full.
+	DropReasonQueueFull DropReason = iota
+
+	// DropReasonBreaker indicates that a message was dropped because the
+	// peer that sent it had tripped our breaker.
+	DropReasonBreaker
+
+	// DropReasonFailed indicates that we failed to process a message.
+	DropReasonFailed
+
+	// DropReasonNoHandler indicates that a final hop payload addressed to
+	// our node was dropped because we have no handler for its tlv type.
+	DropReasonNoHandler
+)
+
+// String returns the string representation of a drop reason.
+func (d DropReason) String() string {
+	switch d {
+	case DropReasonQueueFull:
+		return "queue full"
+
+	case DropReasonBreaker:
+		return "breaker tripped"
+
+	case DropReasonFailed:
+		return "processing failed"
+
+	case DropReasonNoHandler:
+		return "no handler"
+
+	default:
+		return fmt.Sprintf("unknown drop reason: %d", d)
+	}
+}
+
+// noopMetrics is the metrics collector that we use when none is provided.
+type noopMetrics struct{}
+
+// Compile time check that noopMetrics implements MetricsCollector.
+var _ MetricsCollector = (*noopMetrics)(nil)
+
+// MessageSent is a no-op.
+func (n *noopMetrics) MessageSent(route.Vertex, error) {}
+
+// MessageReceived is a no-op.
+func (n *noopMetrics) MessageReceived(route.Vertex) {}
+
+// MessageForwarded is a no-op.
+func (n *noopMetrics) MessageForwarded(route.Vertex, error) {}
+
+// MessageDropped is a no-op.
+func (n *noopMetrics) MessageDropped(route.Vertex, DropReason) {}
+
+// HandlerLatency is a no-op.
+func (n *noopMetrics) HandlerLatency(tlv.Type, time.Duration, error) {}
+
+// QueueDepth is a no-op.
+func (n *noopMetrics) QueueDepth(int, int) {}
+
+// timedHandlers wraps a set of handlers so that the time each invocation
+// takes is recorded in the stats for its tlv type (if any) and reported to
+// the metrics collector provided.
+func timedHandlers(handlers map[tlv.Type][]OnionMessageHandler,
+	stats map[tlv.Type]*handlerStats,
+	metrics MetricsCollector) map[tlv.Type][]OnionMessageHandler {
+
+	timed := make(map[tlv.Type][]OnionMessageHandler, len(handlers))
+	for tlvType, typeHandlers := range handlers {
+		tlvType := tlvType
+		typeStats := stats[tlvType]
+
+		for _, handler := range typeHandlers {
+			handler := handler
+
+			timed[tlvType] = append(timed[tlvType], func(
+				replyPath *lnwire.ReplyPath, encrypted,
+				value []byte) error {
+
+				start := time.Now()
+				err := handler(replyPath, encrypted, value)
+				latency := time.Since(start)
+
+				if typeStats != nil {
+					typeStats.record(latency, err)
+				}
+				metrics.HandlerLatency(tlvType, latency, err)
+
+				return err
+			})
+		}
+	}
+
+	return timed
+}
@@ -0,0 +1,153 @@
+package onionmsg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics is a metrics collector that records the events that it
+// is called with.
+type recordingMetrics struct {
+	sent      []route.Vertex
+	received  []route.Vertex
+	forwarded []route.Vertex
+	dropped   []DropReason
+	handled   []tlv.Type
+	lock      sync.Mutex
+}
+
+func (r *recordingMetrics) MessageSent(peer route.Vertex, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err == nil {
+		r.sent = append(r.sent, peer)
+	}
+}
+
+func (r *recordingMetrics) MessageReceived(peer route.Vertex) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.received = append(r.received, peer)
+}
+
+func (r *recordingMetrics) MessageForwarded(peer route.Vertex, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err == nil {
+		r.forwarded = append(r.forwarded, peer)
+	}
+}
+
+func (r *recordingMetrics) MessageDropped(_ route.Vertex,
+	reason DropReason) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.dropped = append(r.dropped, reason)
+}
+
+func (r *recordingMetrics) HandlerLatency(tlvType tlv.Type, _ time.Duration,
+	_ error) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.handled = append(r.handled, tlvType)
+}
+
+func (r *recordingMetrics) QueueDepth(int, int) {}
+
+// TestMetricsCollector tests that sending a message along a multi-hop path
+// reports the sends, receives, forwards and handler invocations for each of
+// the nodes involved.
+func TestMetricsCollector(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+		tlvType  = tlv.Type(101)
+
+		aliceMetrics = &recordingMetrics{}
+		bobMetrics   = &recordingMetrics{}
+		carolMetrics = &recordingMetrics{}
+	)
+
+	network := NewMemoryNetwork()
+	aliceMessenger := newMemoryMessenger(
+		t, network, privkeys[0], OptionMetricsCollector(aliceMetrics),
+	)
+	newMemoryMessenger(
+		t, network, privkeys[1], OptionMetricsCollector(bobMetrics),
+	)
+	carolMessenger := newMemoryMessenger(
+		t, network, privkeys[2], OptionMetricsCollector(carolMetrics),
+	)
+
+	_, err := network.AddChannel(alice, bob)
+	require.NoError(t, err)
+
+	_, err = network.AddChannel(bob, carol)
+	require.NoError(t, err)
+
+	handled := make(chan struct{}, 1)
+	_, err = carolMessenger.RegisterHandler(tlvType,
+		func(*lnwire.ReplyPath, []byte, []byte) error {
+			handled <- struct{}{}
+			return nil
+		},
+	)
+	require.NoError(t, err, "register handler")
+
+	req := NewSendMessageRequest(
+		privkeys[2].PubKey(), nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: tlvType,
+				Value:   []byte{1},
+			},
+		}, false,
+	)
+	require.NoError(t, aliceMessenger.SendMessage(
+		context.Background(), req,
+	))
+
+	select {
+	case <-handled:
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not delivered")
+	}
+
+	// Our handler's latency is reported after it returns, so we wait for
+	// it to be recorded.
+	require.Eventually(t, func() bool {
+		carolMetrics.lock.Lock()
+		defer carolMetrics.lock.Unlock()
+
+		return len(carolMetrics.handled) == 1
+	}, defaultTimeout, time.Millisecond*10)
+
+	require.Equal(t, []route.Vertex{bob}, aliceMetrics.sent)
+
+	bobMetrics.lock.Lock()
+	require.Equal(t, []route.Vertex{alice}, bobMetrics.received)
+	require.Equal(t, []route.Vertex{carol}, bobMetrics.forwarded)
+	bobMetrics.lock.Unlock()
+
+	carolMetrics.lock.Lock()
+	require.Equal(t, []route.Vertex{bob}, carolMetrics.received)
+	require.Equal(t, []tlv.Type{tlvType}, carolMetrics.handled)
+	require.Empty(t, carolMetrics.dropped)
+	carolMetrics.lock.Unlock()
+}
@@ -24,8 +24,9 @@ var (
 )
 
 // customOnionMessage encodes the onion message provided and wraps it in a
-// lnd custom message so that it can be sent to peers via external apis.
-func customOnionMessage(peer *btcec.PublicKey,
+// lnd custom message of the type provided so that it can be sent to peers via
+// external apis.
+func customOnionMessage(peer *btcec.PublicKey, msgType uint32,
 	msg *lnwire.OnionMessage) (*lndclient.CustomMessage, error) {
 
 	buf := new(bytes.Buffer)
@@ -35,7 +36,7 @@ func customOnionMessage(peer *btcec.PublicKey,
 
 	return &lndclient.CustomMessage{
 		Peer:    route.NewVertex(peer),
-		MsgType: lnwire.OnionMessageType,
+		MsgType: msgType,
 		Data:    buf.Bytes(),
 	}, nil
 }
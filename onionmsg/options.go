@@ -0,0 +1,465 @@
+package onionmsg
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/routes"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"golang.org/x/time/rate"
+)
+
+// ErrInvalidOption is returned when a messenger is created with an invalid
+// functional option.
+var ErrInvalidOption = errors.New("invalid messenger option")
+
+// MessengerOption is the function signature used for functional options that
+// update the messenger's configuration.
+type MessengerOption func(*Messenger) error
+
+// OptionOnionMessageType sets the custom message type that the messenger uses
+// to send onion messages. Incoming onion messages are accepted with this type
+// and with any of the additional types provided, so that we can interoperate
+// with implementations that use a different type. Note that lnd must be
+// configured to deliver each of these types as custom messages. If the send
+// type is below lnd's custom message range, CheckCustomMessageOverride sends
+// a real, empty onion message to one of our peers to verify lnd's config.
+func OptionOnionMessageType(msgType uint32,
+	accept ...uint32) MessengerOption {
+
+	return func(m *Messenger) error {
+		m.onionMsgType = msgType
+		m.acceptMsgTypes = accept
+
+		return nil
+	}
+}
+
+// OptionLookupPeerAttempts sets the maximum number of times that we lookup a
+// peer after connecting to it for a direct send.
+func OptionLookupPeerAttempts(attempts int) MessengerOption {
+	return func(m *Messenger) error {
+		if attempts < 1 {
+			return fmt.Errorf("%w: lookup attempts %v must be "+
+				"positive", ErrInvalidOption, attempts)
+		}
+
+		m.lookupPeerAttempts = attempts
+		return nil
+	}
+}
+
+// OptionLookupPeerBackoff configures the backoff that we use when waiting for
+// a peer to connect after we have dialed it for a direct send. The first
+// lookup backs off for the initial duration (with jitter), doubling for each
+// attempt up to the maximum provided. If the peer has not connected within
+// the timeout provided, the send will fail.
+func OptionLookupPeerBackoff(initial, maxBackoff,
+	timeout time.Duration) MessengerOption {
+
+	return func(m *Messenger) error {
+		if initial <= 0 || maxBackoff < initial {
+			return fmt.Errorf("%w: initial backoff: %v, max: %v",
+				ErrInvalidOption, initial, maxBackoff)
+		}
+
+		if timeout <= 0 {
+			return fmt.Errorf("%w: lookup timeout %v must be "+
+				"positive", ErrInvalidOption, timeout)
+		}
+
+		m.lookupPeerBackoff = initial
+		m.lookupPeerMaxBackoff = maxBackoff
+		m.lookupPeerTimeout = timeout
+
+		return nil
+	}
+}
+
+// OptionResubscribe configures the initial backoff and number of consecutive
+// attempts that we use to resubscribe to lnd's custom message stream after
+// a failure.
+func OptionResubscribe(backoff time.Duration, attempts int) MessengerOption {
+	return func(m *Messenger) error {
+		if backoff <= 0 || attempts < 0 {
+			return fmt.Errorf("%w: resubscribe backoff: %v, "+
+				"attempts: %v", ErrInvalidOption, backoff,
+				attempts)
+		}
+
+		m.resubscribeBackoff = backoff
+		m.resubscribeAttempts = attempts
+
+		return nil
+	}
+}
+
+// OptionHandlerWorkers sets the number of goroutines that process incoming
+// onion messages concurrently.
+func OptionHandlerWorkers(workers int) MessengerOption {
+	return func(m *Messenger) error {
+		if workers < 1 {
+			return fmt.Errorf("%w: handler workers %v must be "+
+				"positive", ErrInvalidOption, workers)
+		}
+
+		m.handlerWorkers = workers
+		return nil
+	}
+}
+
+// OptionInboundQueue sets the number of incoming onion messages that we
+// queue for our handler workers, and the policy used to drop messages when
+// that queue is full.
+func OptionInboundQueue(size int, policy DropPolicy) MessengerOption {
+	return func(m *Messenger) error {
+		if size < 1 {
+			return fmt.Errorf("%w: inbound queue size %v must be "+
+				"positive", ErrInvalidOption, size)
+		}
+
+		if policy != DropNewest && policy != DropOldest {
+			return fmt.Errorf("%w: unknown drop policy: %v",
+				ErrInvalidOption, policy)
+		}
+
+		m.inboundQueueSize = size
+		m.dropPolicy = policy
+
+		return nil
+	}
+}
+
+// OptionForwardQueueSize sets the number of onion messages that we buffer for
+// forwarding to the next node in their route.
+func OptionForwardQueueSize(size int) MessengerOption {
+	return func(m *Messenger) error {
+		if size < 1 {
+			return fmt.Errorf("%w: forward queue size %v must be "+
+				"positive", ErrInvalidOption, size)
+		}
+
+		m.forwardQueueSize = size
+		return nil
+	}
+}
+
+// OptionReplayCache sets the amount of time and the number of onion packets
+// that we remember to detect replayed onion messages.
+func OptionReplayCache(window time.Duration, size int) MessengerOption {
+	return func(m *Messenger) error {
+		if window <= 0 || size < 1 {
+			return fmt.Errorf("%w: replay window: %v, size: %v",
+				ErrInvalidOption, window, size)
+		}
+
+		m.replayWindow = window
+		m.replayCacheSize = size
+
+		return nil
+	}
+}
+
+// OptionOutbox enables store-and-forward delivery of onion messages using the
+// store and ttl provided. See EnableOutbox for details.
+func OptionOutbox(store OutboxStore, ttl time.Duration) MessengerOption {
+	return func(m *Messenger) error {
+		if store == nil {
+			return fmt.Errorf("%w: outbox store required",
+				ErrInvalidOption)
+		}
+
+		return m.EnableOutbox(store, ttl)
+	}
+}
+
+// OptionOutboxRetryInterval sets the interval at which we retry delivery of
+// the messages in our outbox, if enabled.
+func OptionOutboxRetryInterval(interval time.Duration) MessengerOption {
+	return func(m *Messenger) error {
+		if interval <= 0 {
+			return fmt.Errorf("%w: outbox retry interval %v must "+
+				"be positive", ErrInvalidOption, interval)
+		}
+
+		m.outboxRetryInterval = interval
+		return nil
+	}
+}
+
+// OptionDrainTimeout sets the maximum amount of time that we wait for
+// in-flight sends, forwards and handler invocations to complete when the
+// messenger is stopped before tearing down. A zero timeout tears down
+// immediately.
+func OptionDrainTimeout(timeout time.Duration) MessengerOption {
+	return func(m *Messenger) error {
+		if timeout < 0 {
+			return fmt.Errorf("%w: drain timeout %v must not be "+
+				"negative", ErrInvalidOption, timeout)
+		}
+
+		m.drainTimeout = timeout
+		return nil
+	}
+}
+
+// OptionGlobalSendLimit limits the rate at which we send onion messages
+// (including forwards) to all of our peers, allowing bursts of up to the
+// number of messages provided. Sends block until they are permitted by the
+// limit, while forwards that exceed the limit are dropped.
+func OptionGlobalSendLimit(limit rate.Limit, burst int) MessengerOption {
+	return func(m *Messenger) error {
+		if err := validateSendLimit(limit, burst); err != nil {
+			return fmt.Errorf("global send limit: %w", err)
+		}
+
+		m.globalSendLimit = limit
+		m.globalSendBurst = burst
+
+		return nil
+	}
+}
+
+// OptionPeerSendLimit limits the rate at which we send onion messages
+// (including forwards) to each individual peer, allowing bursts of up to the
+// number of messages provided. Sends block until they are permitted by the
+// limit, while forwards that exceed the limit are dropped.
+func OptionPeerSendLimit(limit rate.Limit, burst int) MessengerOption {
+	return func(m *Messenger) error {
+		if err := validateSendLimit(limit, burst); err != nil {
+			return fmt.Errorf("peer send limit: %w", err)
+		}
+
+		m.peerSendLimit = limit
+		m.peerSendBurst = burst
+
+		return nil
+	}
+}
+
+// validateSendLimit checks that a rate limit will allow messages to be sent.
+func validateSendLimit(limit rate.Limit, burst int) error {
+	if limit <= 0 || burst < 1 {
+		return fmt.Errorf("%w: limit: %v, burst: %v", ErrInvalidOption,
+			limit, burst)
+	}
+
+	return nil
+}
+
+// OptionPeerBreaker configures the breaker that stops accepting onion messages
+// from peers that repeatedly send us malformed messages or messages that we
+// fail to forward. Once a peer has sent us the threshold of consecutive
+// failures provided, we drop its messages for the cool down period. A zero
+// threshold disables the breaker.
+func OptionPeerBreaker(threshold int,
+	coolDown time.Duration) MessengerOption {
+
+	return func(m *Messenger) error {
+		if threshold < 0 || coolDown <= 0 {
+			return fmt.Errorf("%w: breaker threshold: %v, cool "+
+				"down: %v", ErrInvalidOption, threshold,
+				coolDown)
+		}
+
+		m.breakerThreshold = threshold
+		m.breakerCoolDown = coolDown
+
+		return nil
+	}
+}
+
+// OptionEndpointOnly configures the messenger to only process onion messages
+// that are addressed to our node, and to drop messages that should be relayed
+// to another node. This is useful for mobile or resource-constrained
+// deployments that do not want to act as a relay.
+func OptionEndpointOnly() MessengerOption {
+	return func(m *Messenger) error {
+		m.endpointOnly = true
+		return nil
+	}
+}
+
+// OptionProbeResponder configures the messenger to reply to the probes that
+// it receives, so that other nodes can test whether our node is reachable by
+// onion message.
+func OptionProbeResponder() MessengerOption {
+	return func(m *Messenger) error {
+		m.probeResponder = true
+		return nil
+	}
+}
+
+// OptionPathFinder replaces the strategy that we use to find paths for the
+// onion messages that we send. By default, paths are found using lnd's query
+// routes.
+func OptionPathFinder(finder PathFinder) MessengerOption {
+	return func(m *Messenger) error {
+		if finder == nil {
+			return fmt.Errorf("%w: nil path finder",
+				ErrInvalidOption)
+		}
+
+		m.pathFinder = finder
+		return nil
+	}
+}
+
+// OptionReplies enables sending of onion messages that expect replies, using
+// the generator provided to create reply paths to our node. See EnableReplies
+// for details.
+func OptionReplies(generator routes.Generator) MessengerOption {
+	return func(m *Messenger) error {
+		if generator == nil {
+			return fmt.Errorf("%w: nil reply path generator",
+				ErrInvalidOption)
+		}
+
+		return m.EnableReplies(generator)
+	}
+}
+
+// OptionRandomizedPaths configures the messenger to find up to the number of
+// candidate paths provided for each message that it sends and to select one
+// at random, so that repeated messages to the same destination do not always
+// use the same path.
+func OptionRandomizedPaths(candidates int) MessengerOption {
+	return func(m *Messenger) error {
+		if candidates < 1 {
+			return fmt.Errorf("%w: path candidates %v must be "+
+				"positive", ErrInvalidOption, candidates)
+		}
+
+		m.pathCandidates = candidates
+		return nil
+	}
+}
+
+// OptionMaxHops sets the maximum number of hops that we allow in the routes
+// for the messages that we send and in the reply paths that we generate,
+// including the destination. Messages that would exceed this length fail
+// before we construct an onion. The number of hops can't exceed the number
+// that fit in an onion packet.
+func OptionMaxHops(hops int) MessengerOption {
+	return func(m *Messenger) error {
+		if hops < 1 || hops > sphinx.NumMaxHops {
+			return fmt.Errorf("%w: max hops %v must be in [1, %v]",
+				ErrInvalidOption, hops, sphinx.NumMaxHops)
+		}
+
+		m.maxHops = hops
+		return nil
+	}
+}
+
+// OptionMetricsCollector sets a collector that the messenger reports
+// telemetry to.
+func OptionMetricsCollector(collector MetricsCollector) MessengerOption {
+	return func(m *Messenger) error {
+		if collector == nil {
+			return fmt.Errorf("%w: nil metrics collector",
+				ErrInvalidOption)
+		}
+
+		m.metrics = collector
+		return nil
+	}
+}
+
+// OptionSessionKeySource sets the source of the session and blinding keys
+// that we use to construct onions. By default, new random keys are generated
+// for every onion.
+func OptionSessionKeySource(source SessionKeySource) MessengerOption {
+	return func(m *Messenger) error {
+		if source == nil {
+			return fmt.Errorf("%w: nil session key source",
+				ErrInvalidOption)
+		}
+
+		m.sessionKeys = source
+		return nil
+	}
+}
+
+// OptionPayloadSigner configures the messenger to authenticate the final hop
+// payloads of the messages that it sends by adding a record containing our
+// public key and a signature over the payloads, reply path and, for unblinded
+// destinations, the recipient's node key, which recipients can verify with
+// AuthenticatedSender or SenderAuthInterceptor. Messages without final hop
+// payloads are not signed.
+func OptionPayloadSigner(signer PayloadSigner) MessengerOption {
+	return func(m *Messenger) error {
+		if signer == nil {
+			return fmt.Errorf("%w: nil payload signer",
+				ErrInvalidOption)
+		}
+
+		m.payloadSigner = signer
+		return nil
+	}
+}
+
+// OptionGraphCache caches the results of graph lookups and route queries for
+// the ttl provided, so that sending many messages to the same destination
+// does not repeatedly query lnd's graph. Cached entries for a destination are
+// invalidated when we fail to send to it along a multi-hop path, and may be
+// invalidated manually with InvalidateGraphCache.
+func OptionGraphCache(ttl time.Duration) MessengerOption {
+	return func(m *Messenger) error {
+		if ttl <= 0 {
+			return fmt.Errorf("%w: graph cache ttl %v must be "+
+				"positive", ErrInvalidOption, ttl)
+		}
+
+		m.graphCacheTTL = ttl
+		return nil
+	}
+}
+
+// OptionTypeRegistry configures the messenger to claim the tlv types of the
+// handlers that are registered with it in the registry provided, so that
+// each type may only have a single handler. Registrations for types that are
+// claimed by another handler (or by our built in functionality) fail with
+// lnwire.ErrTypeClaimed. A registry may be shared between messengers so that
+// their handlers don't claim the same payloads.
+func OptionTypeRegistry(registry *lnwire.TypeRegistry) MessengerOption {
+	return func(m *Messenger) error {
+		if registry == nil {
+			return fmt.Errorf("%w: nil type registry",
+				ErrInvalidOption)
+		}
+
+		m.typeRegistry = registry
+		return nil
+	}
+}
+
+// OptionConnectionManager configures the messenger to keep connections open
+// to the peers that it frequently sends direct-connect messages to, rather
+// than disconnecting after each send. Once a peer has been sent threshold
+// messages within the window provided, connections that would otherwise be
+// closed after sending are held open until we have not sent to the peer for
+// a full window. At most maxPeers connections are held open at a time.
+func OptionConnectionManager(window time.Duration, threshold,
+	maxPeers int) MessengerOption {
+
+	return func(m *Messenger) error {
+		if window <= 0 {
+			return fmt.Errorf("%w: connection manager window %v "+
+				"must be positive", ErrInvalidOption, window)
+		}
+
+		if threshold <= 0 || maxPeers <= 0 {
+			return fmt.Errorf("%w: connection manager threshold "+
+				"%v and max peers %v must be positive",
+				ErrInvalidOption, threshold, maxPeers)
+		}
+
+		m.connManager = newConnManager(
+			window, threshold, maxPeers, time.Now,
+		)
+		return nil
+	}
+}
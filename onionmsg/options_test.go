@@ -0,0 +1,395 @@
+package onionmsg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMessengerOptions tests validation and application of our messenger's
+// functional options.
+func TestMessengerOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		option MessengerOption
+		err    error
+		check  func(*testing.T, *Messenger)
+	}{
+		{
+			name:   "message type",
+			option: OptionOnionMessageType(645),
+			check: func(t *testing.T, m *Messenger) {
+				require.EqualValues(t, 645, m.onionMsgType)
+				require.True(t, m.acceptsMsgType(645))
+				require.False(t, m.acceptsMsgType(513))
+			},
+		},
+		{
+			name:   "multiple message types",
+			option: OptionOnionMessageType(513, 645),
+			check: func(t *testing.T, m *Messenger) {
+				require.EqualValues(t, 513, m.onionMsgType)
+				require.True(t, m.acceptsMsgType(513))
+				require.True(t, m.acceptsMsgType(645))
+				require.False(t, m.acceptsMsgType(1))
+			},
+		},
+		{
+			name:   "invalid lookup attempts",
+			option: OptionLookupPeerAttempts(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "lookup attempts",
+			option: OptionLookupPeerAttempts(3),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 3, m.lookupPeerAttempts)
+			},
+		},
+		{
+			name: "zero initial backoff",
+			option: OptionLookupPeerBackoff(
+				0, time.Second, time.Second,
+			),
+			err: ErrInvalidOption,
+		},
+		{
+			name: "max backoff below initial",
+			option: OptionLookupPeerBackoff(
+				time.Second, time.Millisecond, time.Second,
+			),
+			err: ErrInvalidOption,
+		},
+		{
+			name: "zero lookup timeout",
+			option: OptionLookupPeerBackoff(
+				time.Second, time.Second, 0,
+			),
+			err: ErrInvalidOption,
+		},
+		{
+			name: "lookup backoff",
+			option: OptionLookupPeerBackoff(
+				time.Second, time.Minute, time.Hour,
+			),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(
+					t, time.Second, m.lookupPeerBackoff,
+				)
+				require.Equal(
+					t, time.Minute, m.lookupPeerMaxBackoff,
+				)
+				require.Equal(t, time.Hour, m.lookupPeerTimeout)
+			},
+		},
+		{
+			name:   "invalid resubscribe",
+			option: OptionResubscribe(0, 1),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "resubscribe",
+			option: OptionResubscribe(time.Minute, 0),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(
+					t, time.Minute, m.resubscribeBackoff,
+				)
+				require.Zero(t, m.resubscribeAttempts)
+			},
+		},
+		{
+			name:   "invalid handler workers",
+			option: OptionHandlerWorkers(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "handler workers",
+			option: OptionHandlerWorkers(10),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 10, m.handlerWorkers)
+			},
+		},
+		{
+			name:   "invalid inbound queue size",
+			option: OptionInboundQueue(0, DropNewest),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "invalid drop policy",
+			option: OptionInboundQueue(10, DropPolicy(100)),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "inbound queue",
+			option: OptionInboundQueue(10, DropOldest),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 10, m.inboundQueueSize)
+				require.Equal(t, DropOldest, m.dropPolicy)
+			},
+		},
+		{
+			name:   "invalid forward queue size",
+			option: OptionForwardQueueSize(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "forward queue size",
+			option: OptionForwardQueueSize(3),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 3, cap(m.forwardQueue))
+			},
+		},
+		{
+			name:   "invalid replay cache",
+			option: OptionReplayCache(time.Minute, 0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "replay cache",
+			option: OptionReplayCache(time.Minute, 10),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, time.Minute, m.replayWindow)
+				require.Equal(t, 10, m.replayCacheSize)
+			},
+		},
+		{
+			name:   "nil outbox store",
+			option: OptionOutbox(nil, time.Hour),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "invalid outbox ttl",
+			option: OptionOutbox(NewMemoryOutboxStore(), 0),
+			err:    ErrInvalidTTL,
+		},
+		{
+			name:   "outbox",
+			option: OptionOutbox(NewMemoryOutboxStore(), time.Hour),
+			check: func(t *testing.T, m *Messenger) {
+				require.NotNil(t, m.outbox)
+				require.Equal(t, time.Hour, m.outboxTTL)
+			},
+		},
+		{
+			name:   "invalid outbox retry interval",
+			option: OptionOutboxRetryInterval(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "outbox retry interval",
+			option: OptionOutboxRetryInterval(time.Hour),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(
+					t, time.Hour, m.outboxRetryInterval,
+				)
+			},
+		},
+		{
+			name:   "nil incoming interceptor",
+			option: OptionIncomingInterceptors(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name: "incoming interceptors",
+			option: OptionIncomingInterceptors(
+				func(lndclient.CustomMessage) error {
+					return nil
+				},
+			),
+			check: func(t *testing.T, m *Messenger) {
+				require.Len(t, m.incomingInterceptors, 1)
+			},
+		},
+		{
+			name:   "invalid drain timeout",
+			option: OptionDrainTimeout(-1),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "drain timeout",
+			option: OptionDrainTimeout(0),
+			check: func(t *testing.T, m *Messenger) {
+				require.Zero(t, m.drainTimeout)
+			},
+		},
+		{
+			name:   "invalid global send limit",
+			option: OptionGlobalSendLimit(0, 1),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "invalid peer send burst",
+			option: OptionPeerSendLimit(10, 0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "peer send limit",
+			option: OptionPeerSendLimit(10, 5),
+			check: func(t *testing.T, m *Messenger) {
+				limiter := m.sendLimiter
+				require.EqualValues(t, 10, limiter.peerLimit)
+				require.Equal(t, 5, limiter.peerBurst)
+				require.Nil(t, limiter.global)
+			},
+		},
+		{
+			name:   "nil dispatch interceptor",
+			option: OptionDispatchInterceptors(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "nil metrics collector",
+			option: OptionMetricsCollector(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "nil session key source",
+			option: OptionSessionKeySource(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "nil payload signer",
+			option: OptionPayloadSigner(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "nil path finder",
+			option: OptionPathFinder(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "nil reply path generator",
+			option: OptionReplies(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "invalid path candidates",
+			option: OptionRandomizedPaths(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "randomized paths",
+			option: OptionRandomizedPaths(3),
+			check: func(t *testing.T, m *Messenger) {
+				require.IsType(
+					t, &RandomizedPathFinder{}, m.pathFinder,
+				)
+
+				finder := m.pathFinder.(*RandomizedPathFinder)
+				require.Equal(t, 3, finder.candidates)
+			},
+		},
+		{
+			name:   "max hops too low",
+			option: OptionMaxHops(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "max hops too high",
+			option: OptionMaxHops(sphinx.NumMaxHops + 1),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "max hops",
+			option: OptionMaxHops(5),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 5, m.maxHops)
+			},
+		},
+		{
+			name:   "invalid graph cache ttl",
+			option: OptionGraphCache(0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "graph cache",
+			option: OptionGraphCache(time.Minute),
+			check: func(t *testing.T, m *Messenger) {
+				require.NotNil(t, m.graphCache)
+				require.Equal(t, m.graphCache, m.graph)
+			},
+		},
+		{
+			name:   "invalid connection manager window",
+			option: OptionConnectionManager(0, 2, 1),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "invalid connection manager threshold",
+			option: OptionConnectionManager(time.Minute, 0, 1),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "connection manager",
+			option: OptionConnectionManager(time.Minute, 2, 1),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 2, m.connManager.threshold)
+				require.Equal(t, 1, m.connManager.maxPeers)
+			},
+		},
+		{
+			name:   "nil type registry",
+			option: OptionTypeRegistry(nil),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "type registry",
+			option: OptionTypeRegistry(lnwire.NewTypeRegistry()),
+			check: func(t *testing.T, m *Messenger) {
+				require.NotNil(t, m.typeRegistry)
+			},
+		},
+		{
+			name:   "endpoint only",
+			option: OptionEndpointOnly(),
+			check: func(t *testing.T, m *Messenger) {
+				require.True(t, m.endpointOnly)
+			},
+		},
+		{
+			name:   "probe responder",
+			option: OptionProbeResponder(),
+			check: func(t *testing.T, m *Messenger) {
+				require.True(t, m.probeResponder)
+			},
+		},
+		{
+			name:   "invalid breaker threshold",
+			option: OptionPeerBreaker(-1, time.Minute),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "invalid breaker cool down",
+			option: OptionPeerBreaker(5, 0),
+			err:    ErrInvalidOption,
+		},
+		{
+			name:   "peer breaker",
+			option: OptionPeerBreaker(5, time.Minute),
+			check: func(t *testing.T, m *Messenger) {
+				require.Equal(t, 5, m.breaker.threshold)
+				require.Equal(t, time.Minute, m.breaker.coolDown)
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			m, err := NewOnionMessenger(
+				nil, nil, nil, testCase.option,
+			)
+			require.True(t, errors.Is(err, testCase.err))
+
+			if testCase.check != nil {
+				testCase.check(t, m)
+			}
+		})
+	}
+}
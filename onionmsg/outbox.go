@@ -0,0 +1,546 @@
+package onionmsg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+const (
+	// outboxRetryIntervalDefault is the default interval at which we
+	// retry delivery of the messages in our outbox.
+	outboxRetryIntervalDefault = time.Minute
+
+	// The following record types are used to persist queued messages.
+	outboxExpiryType        tlv.Type = 0
+	outboxPeerType          tlv.Type = 2
+	outboxBlindedDestType   tlv.Type = 4
+	outboxPayloadType       tlv.Type = 6
+	outboxDirectConnectType tlv.Type = 8
+	outboxAvoidNodesType    tlv.Type = 10
+	outboxDisconnectType    tlv.Type = 12
+	outboxMsgExpiryType     tlv.Type = 14
+	outboxRouteType         tlv.Type = 16
+)
+
+var (
+	// ErrMessengerStarted is returned when we try to change the
+	// messenger's configuration after it has been started.
+	ErrMessengerStarted = errors.New("messenger already started")
+
+	// ErrInvalidTTL is returned when an outbox is enabled with a
+	// non-positive message TTL.
+	ErrInvalidTTL = errors.New("outbox ttl must be positive")
+
+	// ErrInvalidAvoidNodes is returned when the avoided nodes for a
+	// persisted message are not a list of compressed pubkeys.
+	ErrInvalidAvoidNodes = errors.New("avoid nodes must be a list of " +
+		"33 byte pubkeys")
+)
+
+// OutboxStore persists onion messages that could not be delivered, so that
+// delivery can be retried after a restart. Implementations must be safe for
+// concurrent use.
+type OutboxStore interface {
+	// PutMessage persists the serialized message with the id provided,
+	// replacing any existing message with that id.
+	PutMessage(id uint64, msg []byte) error
+
+	// DeleteMessage removes the message with the id provided. Deleting a
+	// message that does not exist is not an error.
+	DeleteMessage(id uint64) error
+
+	// ListMessages returns all of the persisted messages, keyed by id.
+	ListMessages() (map[uint64][]byte, error)
+}
+
+// MemoryOutboxStore is an in-memory implementation of OutboxStore. Messages
+// stored in it do not survive restarts.
+type MemoryOutboxStore struct {
+	messages map[uint64][]byte
+	sync.Mutex
+}
+
+// Compile time check that MemoryOutboxStore implements OutboxStore.
+var _ OutboxStore = (*MemoryOutboxStore)(nil)
+
+// NewMemoryOutboxStore creates an empty in-memory outbox store.
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{
+		messages: make(map[uint64][]byte),
+	}
+}
+
+// PutMessage stores a message in memory.
+func (s *MemoryOutboxStore) PutMessage(id uint64, msg []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.messages[id] = append([]byte(nil), msg...)
+
+	return nil
+}
+
+// DeleteMessage removes a message from memory.
+func (s *MemoryOutboxStore) DeleteMessage(id uint64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.messages, id)
+
+	return nil
+}
+
+// ListMessages returns a copy of all the messages in memory.
+func (s *MemoryOutboxStore) ListMessages() (map[uint64][]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	messages := make(map[uint64][]byte, len(s.messages))
+	for id, msg := range s.messages {
+		messages[id] = append([]byte(nil), msg...)
+	}
+
+	return messages, nil
+}
+
+// EnableOutbox enables store-and-forward delivery of onion messages. When
+// enabled, messages that can't be sent because their destination is
+// unreachable are persisted in the store provided and retried on a schedule,
+// and whenever the peer that they are sent via comes online, until they are
+// delivered or expire after the ttl provided. This function must be called
+// before the messenger is started.
+func (m *Messenger) EnableOutbox(store OutboxStore, ttl time.Duration) error {
+	if m.hasStarted() {
+		return ErrMessengerStarted
+	}
+
+	if ttl <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidTTL, ttl)
+	}
+
+	m.outbox = store
+	m.outboxTTL = ttl
+
+	return nil
+}
+
+// unreachable returns a boolean indicating whether a send error indicates
+// that the destination is currently unreachable, in which case it is worth
+// retrying the message later.
+func unreachable(err error) bool {
+	return errors.Is(err, ErrNoPath) || errors.Is(err, ErrNoConnection)
+}
+
+// queueMessage persists a message in our outbox for later delivery. Messages
+// are held in our outbox until our outbox ttl, or their own expiry if it is
+// sooner.
+func (m *Messenger) queueMessage(req *SendMessageRequest) error {
+	expiry := time.Now().Add(m.outboxTTL)
+	if !req.Expiry.IsZero() && req.Expiry.Before(expiry) {
+		expiry = req.Expiry
+	}
+
+	msg, err := encodeOutboxMessage(req, expiry)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+
+	id := atomic.AddUint64(&m.outboxID, 1)
+	if err := m.outbox.PutMessage(id, msg); err != nil {
+		return fmt.Errorf("store message: %w", err)
+	}
+
+	log.Infof("Onion message to: %x queued in outbox: %v",
+		req.targetPeer().SerializeCompressed(), id)
+
+	return nil
+}
+
+// startOutbox loads the messages that are already persisted in our outbox so
+// that queued messages are assigned unused ids, and starts retrying delivery.
+func (m *Messenger) startOutbox() error {
+	messages, err := m.outbox.ListMessages()
+	if err != nil {
+		return fmt.Errorf("list outbox: %w", err)
+	}
+
+	for id := range messages {
+		if id > m.outboxID {
+			m.outboxID = id
+		}
+	}
+
+	log.Infof("Starting onion message outbox with %v queued messages",
+		len(messages))
+
+	m.wg.Add(1)
+	go m.retryOutbox()
+
+	return nil
+}
+
+// retryOutbox retries delivery of the messages in our outbox on a schedule,
+// and retries delivery of the messages for a peer as soon as it comes online.
+func (m *Messenger) retryOutbox() {
+	defer m.wg.Done()
+
+	// Cancel any in-flight sends and our peer event subscription when we
+	// shut down.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// If we can't subscribe to peer events, we fall back to only retrying
+	// delivery on our schedule.
+	events, errs, err := m.transport.SubscribePeerEvents(ctx)
+	if err != nil {
+		log.Warnf("Could not subscribe to peer events, outbox will "+
+			"only be retried every %v: %v", m.outboxRetryInterval,
+			err)
+
+		events, errs = nil, nil
+	}
+
+	ticker := time.NewTicker(m.outboxRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.deliverOutbox(ctx, nil); err != nil {
+				log.Errorf("Outbox delivery failed: %v", err)
+			}
+
+		case event, ok := <-events:
+			// If our events channel is closed, our error channel
+			// will deliver the reason.
+			if !ok {
+				events = nil
+				continue
+			}
+
+			if event.Type != lnrpc.PeerEvent_PEER_ONLINE {
+				continue
+			}
+
+			peer, err := route.NewVertexFromStr(event.PubKey)
+			if err != nil {
+				log.Errorf("Invalid peer event pubkey: %v: %v",
+					event.PubKey, err)
+
+				continue
+			}
+
+			if err := m.deliverOutbox(ctx, &peer); err != nil {
+				log.Errorf("Outbox delivery to: %v failed: %v",
+					peer, err)
+			}
+
+		case err := <-errs:
+			log.Warnf("Outbox peer event subscription failed, "+
+				"outbox will only be retried every %v: %v",
+				m.outboxRetryInterval, err)
+
+			events, errs = nil, nil
+
+		// We don't start new delivery attempts once we're draining,
+		// and our messages remain in the outbox for our next start.
+		case <-m.draining:
+			return
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// deliverOutbox makes a single attempt to deliver the messages in our outbox.
+// If a peer is provided, only messages that are sent via that peer are
+// attempted. Messages are removed from the outbox once they are delivered,
+// have expired, or fail with an error that won't be resolved by retrying.
+func (m *Messenger) deliverOutbox(ctx context.Context,
+	peer *route.Vertex) error {
+
+	messages, err := m.outbox.ListMessages()
+	if err != nil {
+		return fmt.Errorf("list outbox: %w", err)
+	}
+
+	now := time.Now()
+	for id, msg := range messages {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		req, expiry, err := decodeOutboxMessage(msg)
+
+		// Messages that are not sent via the peer provided are left
+		// for their own peer's online event, or our schedule.
+		if err == nil && peer != nil &&
+			route.NewVertex(req.targetPeer()) != *peer {
+
+			continue
+		}
+
+		switch {
+		case err != nil:
+			log.Errorf("Outbox message: %v could not be decoded, "+
+				"removing: %v", id, err)
+
+		case now.After(expiry):
+			log.Infof("Outbox message: %v to: %x expired",
+				id, req.targetPeer().SerializeCompressed())
+
+		default:
+			err := m.sendMessage(ctx, req, m.pathFinder)
+
+			// If we're shutting down, leave the message in our
+			// outbox so that it's retried on our next start.
+			if errors.Is(err, ErrShuttingDown) {
+				return err
+			}
+
+			if err != nil && unreachable(err) {
+				log.Debugf("Outbox message: %v still "+
+					"undeliverable: %v", id, err)
+
+				continue
+			}
+
+			if err != nil {
+				log.Errorf("Outbox message: %v failed, "+
+					"removing: %v", id, err)
+			} else {
+				log.Infof("Outbox message: %v delivered", id)
+			}
+		}
+
+		if err := m.outbox.DeleteMessage(id); err != nil {
+			return fmt.Errorf("delete message %v: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// encodeOutboxMessage serializes a send request and its expiry into a tlv
+// stream for persistence.
+func encodeOutboxMessage(req *SendMessageRequest, expiry time.Time) ([]byte,
+	error) {
+
+	var (
+		expiryUnix = uint64(expiry.Unix())
+		records    = []tlv.Record{
+			tlv.MakePrimitiveRecord(outboxExpiryType, &expiryUnix),
+		}
+	)
+
+	if req.Peer != nil {
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxPeerType, &req.Peer,
+		))
+	}
+
+	// We re-use our onion message payload encoding to serialize reply
+	// paths and final hop payloads.
+	if req.BlindedDestination != nil {
+		dest, err := lnwire.EncodeOnionMessagePayload(
+			&lnwire.OnionMessagePayload{
+				ReplyPath: req.BlindedDestination,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("blinded destination: %w", err)
+		}
+
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxBlindedDestType, &dest,
+		))
+	}
+
+	payload, err := lnwire.EncodeOnionMessagePayload(
+		&lnwire.OnionMessagePayload{
+			ReplyPath:        req.ReplyPath,
+			FinalHopPayloads: req.FinalPayloads,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("payload: %w", err)
+	}
+
+	records = append(records, tlv.MakePrimitiveRecord(
+		outboxPayloadType, &payload,
+	))
+
+	if req.DirectConnect {
+		var directConnect uint8 = 1
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxDirectConnectType, &directConnect,
+		))
+	}
+
+	if len(req.AvoidNodes) != 0 {
+		avoidNodes := encodePubkeys(req.AvoidNodes)
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxAvoidNodesType, &avoidNodes,
+		))
+	}
+
+	if len(req.Route) != 0 {
+		route := encodePubkeys(req.Route)
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxRouteType, &route,
+		))
+	}
+
+	if req.DisconnectAfterSend {
+		var disconnect uint8 = 1
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxDisconnectType, &disconnect,
+		))
+	}
+
+	if !req.Expiry.IsZero() {
+		msgExpiry := uint64(req.Expiry.Unix())
+		records = append(records, tlv.MakePrimitiveRecord(
+			outboxMsgExpiryType, &msgExpiry,
+		))
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, fmt.Errorf("new stream: %w", err)
+	}
+
+	b := new(bytes.Buffer)
+	if err := stream.Encode(b); err != nil {
+		return nil, fmt.Errorf("encode stream: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// decodeOutboxMessage decodes a persisted send request and its expiry.
+func decodeOutboxMessage(msg []byte) (*SendMessageRequest, time.Time,
+	error) {
+
+	var (
+		req = &SendMessageRequest{}
+
+		expiryUnix, msgExpiry           uint64
+		directConnect, disconnect       uint8
+		blindedDest, payload, avoidList []byte
+		routeList                       []byte
+	)
+
+	records := []tlv.Record{
+		tlv.MakePrimitiveRecord(outboxExpiryType, &expiryUnix),
+		tlv.MakePrimitiveRecord(outboxPeerType, &req.Peer),
+		tlv.MakePrimitiveRecord(outboxBlindedDestType, &blindedDest),
+		tlv.MakePrimitiveRecord(outboxPayloadType, &payload),
+		tlv.MakePrimitiveRecord(
+			outboxDirectConnectType, &directConnect,
+		),
+		tlv.MakePrimitiveRecord(outboxAvoidNodesType, &avoidList),
+		tlv.MakePrimitiveRecord(outboxDisconnectType, &disconnect),
+		tlv.MakePrimitiveRecord(outboxMsgExpiryType, &msgExpiry),
+		tlv.MakePrimitiveRecord(outboxRouteType, &routeList),
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("new stream: %w", err)
+	}
+
+	tlvMap, err := stream.DecodeWithParsedTypes(bytes.NewReader(msg))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("decode stream: %w", err)
+	}
+
+	if _, ok := tlvMap[outboxBlindedDestType]; ok {
+		dest, err := lnwire.DecodeOnionMessagePayload(blindedDest)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("blinded "+
+				"destination: %w", err)
+		}
+
+		req.BlindedDestination = dest.ReplyPath
+	}
+
+	decoded, err := lnwire.DecodeOnionMessagePayload(payload)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("payload: %w", err)
+	}
+
+	req.ReplyPath = decoded.ReplyPath
+	req.FinalPayloads = decoded.FinalHopPayloads
+	req.DirectConnect = directConnect == 1
+	req.DisconnectAfterSend = disconnect == 1
+
+	if _, ok := tlvMap[outboxMsgExpiryType]; ok {
+		req.Expiry = time.Unix(int64(msgExpiry), 0)
+	}
+
+	req.AvoidNodes, err = decodePubkeys(avoidList)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: %v",
+			ErrInvalidAvoidNodes, err)
+	}
+
+	req.Route, err = decodePubkeys(routeList)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: %v", ErrInvalidRoute,
+			err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return req, time.Unix(int64(expiryUnix), 0), nil
+}
+
+// encodePubkeys serializes a list of pubkeys as their concatenated compressed
+// encodings.
+func encodePubkeys(pubkeys []*btcec.PublicKey) []byte {
+	encoded := make(
+		[]byte, 0, len(pubkeys)*btcec.PubKeyBytesLenCompressed,
+	)
+	for _, pubkey := range pubkeys {
+		encoded = append(encoded, pubkey.SerializeCompressed()...)
+	}
+
+	return encoded
+}
+
+// decodePubkeys parses a list of pubkeys serialized by encodePubkeys.
+func decodePubkeys(encoded []byte) ([]*btcec.PublicKey, error) {
+	if len(encoded)%btcec.PubKeyBytesLenCompressed != 0 {
+		return nil, fmt.Errorf("%v bytes is not a list of 33 byte "+
+			"pubkeys", len(encoded))
+	}
+
+	var pubkeys []*btcec.PublicKey
+	for i := 0; i < len(encoded); i += btcec.PubKeyBytesLenCompressed {
+		pubkey, err := btcec.ParsePubKey(
+			encoded[i : i+btcec.PubKeyBytesLenCompressed],
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	return pubkeys, nil
+}
@@ -0,0 +1,124 @@
+package onionmsg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout is the amount of time we wait to obtain a lock on our
+// outbox database file, which may be held by another process.
+const boltOpenTimeout = time.Second * 10
+
+// outboxBucket is the bucket that our outbox messages are stored in, keyed
+// by their big endian encoded id.
+var outboxBucket = []byte("outbox")
+
+// ErrOutboxBucket is returned when our outbox bucket is not found in the
+// database.
+var ErrOutboxBucket = errors.New("outbox bucket not found")
+
+// BoltOutboxStore is an implementation of OutboxStore that persists messages
+// in a bolt database, so that queued messages survive restarts.
+type BoltOutboxStore struct {
+	db *bbolt.DB
+}
+
+// Compile time check that BoltOutboxStore implements OutboxStore.
+var _ OutboxStore = (*BoltOutboxStore)(nil)
+
+// NewBoltOutboxStore opens, or creates, the bolt database at the path
+// provided for use as an outbox. The store must be closed by the caller once
+// it is no longer used.
+func NewBoltOutboxStore(path string) (*BoltOutboxStore, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{
+		Timeout: boltOpenTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open outbox db: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(outboxBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create outbox bucket: %w", err)
+	}
+
+	return &BoltOutboxStore{
+		db: db,
+	}, nil
+}
+
+// PutMessage persists a message in our bolt database.
+func (s *BoltOutboxStore) PutMessage(id uint64, msg []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(outboxBucket)
+		if bucket == nil {
+			return ErrOutboxBucket
+		}
+
+		return bucket.Put(outboxKey(id), msg)
+	})
+}
+
+// DeleteMessage removes a message from our bolt database.
+func (s *BoltOutboxStore) DeleteMessage(id uint64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(outboxBucket)
+		if bucket == nil {
+			return ErrOutboxBucket
+		}
+
+		return bucket.Delete(outboxKey(id))
+	})
+}
+
+// ListMessages returns all of the messages in our bolt database.
+func (s *BoltOutboxStore) ListMessages() (map[uint64][]byte, error) {
+	messages := make(map[uint64][]byte)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(outboxBucket)
+		if bucket == nil {
+			return ErrOutboxBucket
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			if len(k) != 8 {
+				return fmt.Errorf("invalid outbox key: %x", k)
+			}
+
+			// Values are only valid for the lifetime of the
+			// transaction, so we copy them out.
+			messages[binary.BigEndian.Uint64(k)] = append(
+				[]byte(nil), v...,
+			)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Close closes our bolt database.
+func (s *BoltOutboxStore) Close() error {
+	return s.db.Close()
+}
+
+// outboxKey returns the database key for the message id provided.
+func outboxKey(id uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], id)
+
+	return key[:]
+}
@@ -0,0 +1,379 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutboxEncoding tests encoding and decoding of persisted outbox
+// messages.
+func TestOutboxEncoding(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 4)
+
+	replyPath := &lnwire.ReplyPath{
+		FirstNodeID:   pubkeys[0],
+		BlindingPoint: pubkeys[1],
+		Hops: []*lnwire.BlindedHop{
+			{
+				BlindedNodeID: pubkeys[2],
+				EncryptedData: []byte{1, 2, 3},
+			},
+		},
+	}
+
+	finalPayloads := []*lnwire.FinalHopPayload{
+		{
+			TLVType: 101,
+			Value:   []byte{4, 5, 6},
+		},
+	}
+
+	expiry := time.Unix(1000, 0)
+
+	tests := []struct {
+		name string
+		req  *SendMessageRequest
+	}{
+		{
+			name: "peer destination",
+			req: &SendMessageRequest{
+				Peer:          pubkeys[3],
+				ReplyPath:     replyPath,
+				FinalPayloads: finalPayloads,
+				DirectConnect: true,
+				AvoidNodes:    pubkeys[:2],
+
+				DisconnectAfterSend: true,
+			},
+		},
+		{
+			name: "blinded destination",
+			req: &SendMessageRequest{
+				BlindedDestination: replyPath,
+			},
+		},
+		{
+			name: "message expiry",
+			req: &SendMessageRequest{
+				Peer:   pubkeys[3],
+				Expiry: time.Unix(500, 0),
+			},
+		},
+		{
+			name: "explicit route",
+			req: &SendMessageRequest{
+				Peer:  pubkeys[3],
+				Route: pubkeys[1:],
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			msg, err := encodeOutboxMessage(testCase.req, expiry)
+			require.NoError(t, err, "encode")
+
+			req, decodedExpiry, err := decodeOutboxMessage(msg)
+			require.NoError(t, err, "decode")
+			require.Equal(t, testCase.req, req)
+			require.Equal(t, expiry, decodedExpiry)
+		})
+	}
+
+	// A message with a truncated list of avoided nodes should fail.
+	msg, err := encodeOutboxMessage(&SendMessageRequest{
+		Peer: pubkeys[0],
+	}, expiry)
+	require.NoError(t, err, "encode")
+
+	msg = append(msg, byte(outboxAvoidNodesType), 1, 0)
+	_, _, err = decodeOutboxMessage(msg)
+	require.True(t, errors.Is(err, ErrInvalidAvoidNodes))
+}
+
+// TestOutbox tests queuing of undeliverable messages and their delivery once
+// the destination becomes reachable.
+func TestOutbox(t *testing.T) {
+	var (
+		privkeys    = testutils.GetPrivkeys(t, 1)
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkeys[0],
+		}
+
+		peer     = testutils.GetPubkeys(t, 1)[0]
+		peerList = []lndclient.Peer{
+			{
+				Pubkey: route.NewVertex(peer),
+			},
+		}
+
+		ctxb = context.Background()
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	mockUnreachable := func(peers []lndclient.Peer) {
+		testutils.MockQueryRoutes(
+			lnd.Mock, queryRoutesRequest(peer),
+			&lndclient.QueryRoutesResponse{}, nil,
+		)
+		testutils.MockListPeers(lnd.Mock, peers, nil)
+	}
+
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, nil)
+	require.NoError(t, err, "new messenger")
+
+	// Enabling an outbox without a ttl should fail.
+	store := NewMemoryOutboxStore()
+	err = messenger.EnableOutbox(store, 0)
+	require.True(t, errors.Is(err, ErrInvalidTTL))
+
+	require.NoError(t, messenger.EnableOutbox(store, time.Hour))
+
+	// When our peer is unreachable, we expect our message to be queued
+	// rather than failing.
+	mockUnreachable(nil)
+	req := NewSendMessageRequest(peer, nil, nil, nil, false)
+	require.NoError(t, messenger.SendMessage(ctxb, req))
+
+	messages, err := store.ListMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	// While our peer is still unreachable, the message should stay in our
+	// outbox.
+	mockUnreachable(nil)
+	require.NoError(t, messenger.deliverOutbox(ctxb, nil))
+
+	messages, err = store.ListMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	// Once our peer is connected, the message should be delivered and
+	// removed from our outbox.
+	mockUnreachable(peerList)
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	require.NoError(t, messenger.deliverOutbox(ctxb, nil))
+
+	messages, err = store.ListMessages()
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	// Expired messages and messages that can't be decoded should be
+	// removed without any attempt to deliver them.
+	expired, err := encodeOutboxMessage(
+		req, time.Now().Add(time.Hour*-1),
+	)
+	require.NoError(t, err)
+	require.NoError(t, store.PutMessage(10, expired))
+	require.NoError(t, store.PutMessage(11, []byte{1}))
+
+	require.NoError(t, messenger.deliverOutbox(ctxb, nil))
+
+	messages, err = store.ListMessages()
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	// Messages with an expiry sooner than our outbox ttl should only be
+	// held until they expire.
+	req.Expiry = time.Now().Add(time.Minute).Truncate(time.Second)
+
+	mockUnreachable(nil)
+	require.NoError(t, messenger.SendMessage(ctxb, req))
+
+	messages, err = store.ListMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	for _, msg := range messages {
+		_, expiry, err := decodeOutboxMessage(msg)
+		require.NoError(t, err)
+		require.Equal(t, req.Expiry, expiry)
+	}
+
+	// Once a message has expired, we should not attempt to send or queue
+	// it.
+	req.Expiry = time.Now().Add(time.Hour * -1)
+	err = messenger.SendMessage(ctxb, req)
+	require.True(t, errors.Is(err, ErrMessageExpired))
+}
+
+// TestOutboxStart tests that messages queued after we start our outbox do not
+// overwrite messages that were persisted before startup, and that our outbox
+// can't be changed once the messenger has started.
+func TestOutboxStart(t *testing.T) {
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	privkeys := testutils.GetPrivkeys(t, 1)
+	messenger, err := NewOnionMessenger(lnd, &sphinx.PrivKeyECDH{
+		PrivKey: privkeys[0],
+	}, nil)
+	require.NoError(t, err, "new messenger")
+
+	store := NewMemoryOutboxStore()
+	require.NoError(t, store.PutMessage(5, []byte{1}))
+	require.NoError(t, messenger.EnableOutbox(store, time.Hour))
+
+	testutils.MockSubscribeCustomMessages(lnd.Mock, nil, nil, nil)
+	testutils.MockSubscribePeerEvents(lnd.Mock, nil, nil, nil)
+	require.NoError(t, messenger.Start(), "start messenger")
+
+	err = messenger.EnableOutbox(store, time.Hour)
+	require.True(t, errors.Is(err, ErrMessengerStarted))
+
+	peer := testutils.GetPubkeys(t, 1)[0]
+	require.NoError(t, messenger.queueMessage(
+		&SendMessageRequest{Peer: peer},
+	))
+
+	messages, err := store.ListMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, []byte{1}, messages[5])
+	require.Contains(t, messages, uint64(6))
+
+	require.NoError(t, messenger.Stop(), "stop messenger")
+}
+
+// TestOutboxPeerOnline tests that messages in our outbox are delivered as soon
+// as the peer that they are sent via comes online, rather than waiting for our
+// retry schedule.
+func TestOutboxPeerOnline(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+		tlvType  = tlv.Type(101)
+		network  = NewMemoryNetwork()
+		store    = NewMemoryOutboxStore()
+	)
+
+	aliceMsgr := newMemoryMessenger(
+		t, network, privkeys[0], OptionOutbox(store, time.Hour),
+	)
+	bobMsgr := newMemoryMessenger(t, network, privkeys[1])
+	newMemoryMessenger(t, network, privkeys[2])
+
+	// Wait for our outbox to subscribe to peer events so that we can't
+	// miss bob's online event.
+	require.Eventually(t, func() bool {
+		network.lock.Lock()
+		defer network.lock.Unlock()
+
+		return len(network.nodes[alice].peerSubs) == 1
+	}, defaultTimeout, time.Millisecond*10)
+
+	handled := make(chan []byte, 1)
+	_, err := bobMsgr.RegisterHandler(tlvType, func(_ *lnwire.ReplyPath,
+		_, value []byte) error {
+
+		handled <- value
+		return nil
+	})
+	require.NoError(t, err, "register handler")
+
+	// Bob is not reachable yet, so our message should be queued.
+	req := NewSendMessageRequest(
+		privkeys[1].PubKey(), nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: tlvType,
+				Value:   []byte{1},
+			},
+		}, false,
+	)
+	require.NoError(t, aliceMsgr.SendMessage(context.Background(), req))
+
+	messages, err := store.ListMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	// Another peer coming online should not trigger delivery of our
+	// message to bob.
+	_, err = network.AddChannel(alice, carol)
+	require.NoError(t, err)
+
+	select {
+	case <-handled:
+		t.Fatal("message delivered before bob online")
+
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// Once bob comes online, our message should be delivered well before
+	// our retry interval and removed from our outbox.
+	_, err = network.AddChannel(alice, bob)
+	require.NoError(t, err)
+
+	select {
+	case value := <-handled:
+		require.Equal(t, []byte{1}, value)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not delivered")
+	}
+
+	require.Eventually(t, func() bool {
+		messages, err := store.ListMessages()
+		require.NoError(t, err)
+
+		return len(messages) == 0
+	}, defaultTimeout, time.Millisecond*10)
+}
+
+// TestBoltOutboxStore tests persisting messages in a bolt outbox store
+// across restarts.
+func TestBoltOutboxStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+
+	store, err := NewBoltOutboxStore(path)
+	require.NoError(t, err)
+
+	messages, err := store.ListMessages()
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	require.NoError(t, store.PutMessage(1, []byte{1}))
+	require.NoError(t, store.PutMessage(2, []byte{2}))
+	require.NoError(t, store.PutMessage(2, []byte{3}))
+
+	// Deleting a message that does not exist is not an error.
+	require.NoError(t, store.DeleteMessage(3))
+	require.NoError(t, store.Close())
+
+	// Our messages should survive re-opening the store.
+	store, err = NewBoltOutboxStore(path)
+	require.NoError(t, err)
+
+	messages, err = store.ListMessages()
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]byte{
+		1: {1},
+		2: {3},
+	}, messages)
+
+	require.NoError(t, store.DeleteMessage(1))
+
+	messages, err = store.ListMessages()
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]byte{
+		2: {3},
+	}, messages)
+
+	require.NoError(t, store.Close())
+}
@@ -0,0 +1,101 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// CustomTypeStart is the first message type in lnd's custom message range.
+// Message types below this value can only be sent and received as custom
+// messages if lnd is started with a protocol.custom-message override for
+// them.
+const CustomTypeStart uint32 = 32768
+
+// customRangeErr is the error text that lnd returns when it is asked to send
+// a custom message with a type that is outside of the custom message range
+// and is not overridden.
+const customRangeErr = "not in custom range"
+
+// ErrCustomMessageOverride is returned when lnd is not configured to send and
+// receive our onion message type as a custom message.
+var ErrCustomMessageOverride = errors.New("lnd custom message override " +
+	"required")
+
+// OverrideError returns an error that names the lnd flag required to deliver
+// the message type provided as a custom message.
+func OverrideError(msgType uint32) error {
+	return fmt.Errorf("%w: start lnd with --protocol.custom-message=%v",
+		ErrCustomMessageOverride, msgType)
+}
+
+// wrapOverrideError wraps errors returned by lnd when it refuses to send a
+// message type that it is not configured to override as a custom message, so
+// that callers can identify the misconfiguration.
+func wrapOverrideError(err error, msgType uint32) error {
+	if err == nil || !strings.Contains(err.Error(), customRangeErr) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %v", OverrideError(msgType), err)
+}
+
+// CheckCustomMessageOverride checks that lnd is configured to send our onion
+// message type as a custom message, so that a misconfiguration is surfaced at
+// startup rather than silently dropping our traffic. lnd only checks message
+// types when sending a message to a connected peer, so we send a real onion
+// message without any payloads to one of our peers. This probe is not counted
+// in our metrics or rate limits. If we have no peers, the check can't be
+// performed and is skipped. Types in lnd's custom message range do not need
+// an override and are not checked.
+func (m *Messenger) CheckCustomMessageOverride(ctx context.Context) error {
+	if m.onionMsgType >= CustomTypeStart {
+		return nil
+	}
+
+	peers, err := m.transport.ListPeers(ctx)
+	if err != nil {
+		return fmt.Errorf("list peers: %w", err)
+	}
+
+	if len(peers) == 0 {
+		log.Warnf("No peers to check lnd custom message override for "+
+			"type: %v, lnd must be started with "+
+			"--protocol.custom-message=%v", m.onionMsgType,
+			m.onionMsgType)
+
+		return nil
+	}
+
+	peer, err := btcec.ParsePubKey(peers[0].Pubkey[:])
+	if err != nil {
+		return fmt.Errorf("peer pubkey: %w", err)
+	}
+
+	// We send our probe directly rather than with sendAlongPath so that
+	// it is not counted in our metrics or our rate limits.
+	req := NewSendMessageRequest(peer, nil, nil, nil, false)
+	msg, err := m.pathMessage(req, []*btcec.PublicKey{peer})
+	if err != nil {
+		return fmt.Errorf("probe message: %w", err)
+	}
+
+	err = wrapOverrideError(
+		m.transport.SendCustomMessage(ctx, *msg), msg.MsgType,
+	)
+	switch {
+	case errors.Is(err, ErrCustomMessageOverride):
+		return err
+
+	// Other failures to reach our peer don't tell us anything about our
+	// configuration, so we just log them.
+	case err != nil:
+		log.Warnf("Could not check lnd custom message override with "+
+			"peer: %v: %v", peers[0].Pubkey, err)
+	}
+
+	return nil
+}
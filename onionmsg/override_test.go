@@ -0,0 +1,124 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheckCustomMessageOverride tests checking that lnd is configured to
+// send our onion message type as a custom message.
+func TestCheckCustomMessageOverride(t *testing.T) {
+	var (
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: testutils.GetPrivkeys(t, 1)[0],
+		}
+
+		peers = []lndclient.Peer{
+			{
+				Pubkey: route.NewVertex(
+					testutils.GetPubkeys(t, 1)[0],
+				),
+			},
+		}
+
+		errListPeers = errors.New("list peers failed")
+
+		// errNotOverridden mimics the error that lnd returns when it
+		// is not configured to override our message type.
+		errNotOverridden = errors.New("rpc error: code = Unknown " +
+			"desc = msg type: 513 not in custom range: 32768 and " +
+			"not overridden")
+	)
+
+	tests := []struct {
+		name    string
+		msgType uint32
+		setup   func(*mock.Mock)
+		err     error
+	}{
+		{
+			name:    "custom range type",
+			msgType: CustomTypeStart,
+			setup:   func(*mock.Mock) {},
+		},
+		{
+			name:    "no peers",
+			msgType: 513,
+			setup: func(m *mock.Mock) {
+				testutils.MockListPeers(m, nil, nil)
+			},
+		},
+		{
+			name:    "list peers fails",
+			msgType: 513,
+			setup: func(m *mock.Mock) {
+				testutils.MockListPeers(m, nil, errListPeers)
+			},
+			err: errListPeers,
+		},
+		{
+			name:    "type overridden",
+			msgType: 513,
+			setup: func(m *mock.Mock) {
+				testutils.MockListPeers(m, peers, nil)
+				testutils.MockSendAnyCustomMessage(m, nil)
+			},
+		},
+		{
+			name:    "type not overridden",
+			msgType: 513,
+			setup: func(m *mock.Mock) {
+				testutils.MockListPeers(m, peers, nil)
+				testutils.MockSendAnyCustomMessage(
+					m, errNotOverridden,
+				)
+			},
+			err: ErrCustomMessageOverride,
+		},
+		{
+			name:    "unrelated send failure",
+			msgType: 513,
+			setup: func(m *mock.Mock) {
+				testutils.MockListPeers(m, peers, nil)
+				testutils.MockSendAnyCustomMessage(
+					m, errors.New("peer offline"),
+				)
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			lnd := testutils.NewMockLnd()
+			defer lnd.Mock.AssertExpectations(t)
+
+			metrics := &recordingMetrics{}
+			messenger, err := NewOnionMessenger(
+				lnd, nodeKeyECDH, nil,
+				OptionOnionMessageType(testCase.msgType),
+				OptionMetricsCollector(metrics),
+			)
+			require.NoError(t, err, "new messenger")
+
+			testCase.setup(lnd.Mock)
+
+			err = messenger.CheckCustomMessageOverride(
+				context.Background(),
+			)
+			require.True(t, errors.Is(err, testCase.err))
+
+			// Our probe should not be counted as a sent message.
+			require.Empty(t, metrics.sent)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package onionmsg
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// QueryRoutesPathFinder finds onion message paths using lnd's payment
+// pathfinding, discarding any paths that relay via nodes that do not support
+// onion messages.
+type QueryRoutesPathFinder struct {
+	graph Graph
+}
+
+// Compile time check that QueryRoutesPathFinder implements PathFinder.
+var _ PathFinder = (*QueryRoutesPathFinder)(nil)
+
+// NewQueryRoutesPathFinder creates a path finder backed by lnd's query routes.
+func NewQueryRoutesPathFinder(graph Graph) *QueryRoutesPathFinder {
+	return &QueryRoutesPathFinder{
+		graph: graph,
+	}
+}
+
+// FindPath finds a path to the target using lnd's query routes.
+func (q *QueryRoutesPathFinder) FindPath(ctx context.Context,
+	target *btcec.PublicKey, avoid []*btcec.PublicKey) ([]*btcec.PublicKey,
+	error) {
+
+	return multiHopPath(ctx, q.graph, target, avoid)
+}
+
+// RandomizedPathFinder finds a set of candidate paths to a target and picks
+// one at random, so that repeated messages to the same destination do not
+// always traverse the same path. Since query routes only returns a single
+// route, alternative candidates are found by restricting the last hop before
+// the target to each of its other channel peers. This costs an additional
+// graph lookup and query routes call per candidate.
+type RandomizedPathFinder struct {
+	graph Graph
+
+	// candidates is the maximum number of paths that we select from.
+	candidates int
+
+	// intn returns a random number in [0, n).
+	intn func(n int) int
+}
+
+// Compile time check that RandomizedPathFinder implements PathFinder.
+var _ PathFinder = (*RandomizedPathFinder)(nil)
+
+// NewRandomizedPathFinder creates a path finder that randomly selects from
+// up to the number of candidate paths provided.
+func NewRandomizedPathFinder(graph Graph,
+	candidates int) *RandomizedPathFinder {
+
+	return &RandomizedPathFinder{
+		graph:      graph,
+		candidates: candidates,
+		intn:       rand.Intn,
+	}
+}
+
+// FindPath finds up to our number of candidate paths to the target, and
+// randomly returns one of them. If we fail to find alternatives to query
+// routes' default path, the default path is returned.
+func (r *RandomizedPathFinder) FindPath(ctx context.Context,
+	target *btcec.PublicKey, avoid []*btcec.PublicKey) ([]*btcec.PublicKey,
+	error) {
+
+	path, err := multiHopPath(ctx, r.graph, target, avoid)
+	if err != nil {
+		return nil, err
+	}
+
+	// If we have no path, or the target is our direct peer, there are no
+	// alternatives worth trying.
+	if r.candidates <= 1 || len(path) <= 1 {
+		return path, nil
+	}
+
+	targetVertex := route.NewVertex(target)
+	info, err := r.graph.GetNodeInfo(ctx, targetVertex, true)
+	if err != nil {
+		log.Debugf("Could not get channels for: %v, using default "+
+			"path: %v", targetVertex, err)
+
+		return path, nil
+	}
+
+	// Collect the target's channel peers, other than the last hop in our
+	// default path, and shuffle them so that we try a different set of
+	// alternatives each time.
+	var (
+		defaultHop = route.NewVertex(path[len(path)-2])
+		seen       = map[route.Vertex]struct{}{
+			targetVertex: {},
+			defaultHop:   {},
+		}
+		lastHops []route.Vertex
+	)
+
+	for _, channel := range info.Channels {
+		peer := channel.Node1
+		if peer == targetVertex {
+			peer = channel.Node2
+		}
+
+		if _, ok := seen[peer]; ok {
+			continue
+		}
+
+		seen[peer] = struct{}{}
+		lastHops = append(lastHops, peer)
+	}
+
+	rand.Shuffle(len(lastHops), func(i, j int) {
+		lastHops[i], lastHops[j] = lastHops[j], lastHops[i]
+	})
+
+	candidates := [][]*btcec.PublicKey{path}
+	for i := 0; i < len(lastHops) && len(candidates) < r.candidates; i++ {
+		lastHop := lastHops[i]
+
+		path, err := queryPath(ctx, r.graph, target, &lastHop, avoid)
+		if err != nil {
+			log.Debugf("Could not find path to: %v via: %v: %v",
+				targetVertex, lastHop, err)
+
+			continue
+		}
+
+		if path != nil {
+			candidates = append(candidates, path)
+		}
+	}
+
+	log.Debugf("Selecting path to: %v from %v candidates", targetVertex,
+		len(candidates))
+
+	return candidates[r.intn(len(candidates))], nil
+}
@@ -0,0 +1,221 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// mockPathFinder is a path finder that returns a static path, recording the
+// requests that it receives.
+type mockPathFinder struct {
+	path []*btcec.PublicKey
+
+	targets []*btcec.PublicKey
+	avoided [][]*btcec.PublicKey
+}
+
+// FindPath returns our static path.
+func (m *mockPathFinder) FindPath(_ context.Context, target *btcec.PublicKey,
+	avoid []*btcec.PublicKey) ([]*btcec.PublicKey, error) {
+
+	m.targets = append(m.targets, target)
+	m.avoided = append(m.avoided, avoid)
+
+	return m.path, nil
+}
+
+// TestPathFinder tests that we use the path finder provided to find paths
+// for the messages that we send.
+func TestPathFinder(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 1)
+		pubkeys  = testutils.GetPubkeys(t, 2)
+		target   = pubkeys[0]
+		avoid    = pubkeys[1:]
+
+		finder = &mockPathFinder{
+			path: []*btcec.PublicKey{target},
+		}
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionPathFinder(finder),
+	)
+	require.NoError(t, err, "new messenger")
+
+	// We expect our message to be sent along the path returned by our
+	// path finder, without falling back to a direct send.
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+
+	req := NewSendMessageRequest(target, nil, nil, nil, false)
+	req.AvoidNodes = avoid
+
+	require.NoError(t, messenger.SendMessage(context.Background(), req))
+	require.Equal(t, []*btcec.PublicKey{target}, finder.targets)
+	require.Equal(t, [][]*btcec.PublicKey{avoid}, finder.avoided)
+}
+
+// sequencePathFinder is a path finder that returns a different path for each
+// lookup, recording the nodes that each lookup avoids.
+type sequencePathFinder struct {
+	paths [][]*btcec.PublicKey
+
+	avoided [][]*btcec.PublicKey
+}
+
+// FindPath returns the next path in our sequence, or no path if we have run
+// out of paths.
+func (s *sequencePathFinder) FindPath(_ context.Context, _ *btcec.PublicKey,
+	avoid []*btcec.PublicKey) ([]*btcec.PublicKey, error) {
+
+	s.avoided = append(s.avoided, avoid)
+
+	if len(s.paths) == 0 {
+		return nil, nil
+	}
+
+	path := s.paths[0]
+	s.paths = s.paths[1:]
+
+	return path, nil
+}
+
+// TestPathFailover tests that we try another candidate path, avoiding the
+// first hop of our failed path, when we can't send along our first path.
+func TestPathFailover(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 1)
+		pubkeys  = testutils.GetPubkeys(t, 4)
+		target   = pubkeys[0]
+		hop1     = pubkeys[1]
+		hop2     = pubkeys[2]
+		avoid    = pubkeys[3:]
+
+		finder = &sequencePathFinder{
+			paths: [][]*btcec.PublicKey{
+				{hop1, target},
+				{hop2, target},
+			},
+		}
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionPathFinder(finder),
+	)
+	require.NoError(t, err, "new messenger")
+
+	// Our send along the first path fails and our send along the second
+	// path succeeds, so we don't fall back to a direct send.
+	testutils.MockSendAnyCustomMessage(lnd.Mock, errors.New("send failed"))
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+
+	req := NewSendMessageRequest(target, nil, nil, nil, false)
+	req.AvoidNodes = avoid
+
+	require.NoError(t, messenger.SendMessage(context.Background(), req))
+	require.Equal(t, [][]*btcec.PublicKey{
+		avoid, {avoid[0], hop1},
+	}, finder.avoided)
+}
+
+// TestRandomizedPathFinder tests selection of a random path from a set of
+// candidates found by restricting the last hop to each of the target's peers.
+func TestRandomizedPathFinder(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 3)
+		target  = pubkeys[0]
+		node1   = route.NewVertex(pubkeys[1])
+		node2   = route.NewVertex(pubkeys[2])
+
+		targetVertex = route.NewVertex(target)
+
+		relayInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				Features: []lndwire.FeatureBit{
+					lnwire.OnionMessagesOptional,
+				},
+			},
+		}
+
+		// Our target has channels with both of our nodes, and a
+		// duplicate channel with node 2.
+		targetInfo = &lndclient.NodeInfo{
+			Channels: []lndclient.ChannelEdge{
+				{
+					Node1: targetVertex,
+					Node2: node1,
+				},
+				{
+					Node1: node2,
+					Node2: targetVertex,
+				},
+				{
+					Node1: targetVertex,
+					Node2: node2,
+				},
+			},
+		}
+	)
+
+	routeVia := func(node route.Vertex) *lndclient.QueryRoutesResponse {
+		return &lndclient.QueryRoutesResponse{
+			Hops: []*lndclient.Hop{
+				{
+					PubKey: &node,
+				},
+				{
+					PubKey: &targetVertex,
+				},
+			},
+		}
+	}
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	// Our default path is via node 1, and we expect to only query for an
+	// alternative via node 2.
+	testutils.MockQueryRoutes(
+		lnd.Mock, queryRoutesRequest(target), routeVia(node1), nil,
+	)
+	testutils.MockGetNodeInfo(lnd.Mock, node1, false, relayInfo, nil)
+	testutils.MockGetNodeInfo(
+		lnd.Mock, targetVertex, true, targetInfo, nil,
+	)
+
+	viaNode2 := queryRoutesRequest(target)
+	viaNode2.LastHop = &node2
+	testutils.MockQueryRoutes(lnd.Mock, viaNode2, routeVia(node2), nil)
+	testutils.MockGetNodeInfo(lnd.Mock, node2, false, relayInfo, nil)
+
+	finder := NewRandomizedPathFinder(lnd, 3)
+
+	var candidates int
+	finder.intn = func(n int) int {
+		candidates = n
+		return n - 1
+	}
+
+	path, err := finder.FindPath(context.Background(), target, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, candidates)
+	require.Equal(t, []*btcec.PublicKey{pubkeys[2], target}, path)
+}
@@ -0,0 +1,100 @@
+package onionmsg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const (
+	// pathIDNonceLength is the length of the random nonce that prefixes
+	// the path IDs that we create.
+	pathIDNonceLength = 16
+
+	// pathIDLength is the total length of the path IDs that we include
+	// in the blinded paths that we create: a random nonce followed by a
+	// truncated mac over that nonce.
+	pathIDLength = 32
+)
+
+// pathIDKeyTag is a domain separation tag used when deriving our path ID
+// secret from our node key.
+var pathIDKeyTag = []byte("boltnd/onionmsg/path_id")
+
+// ErrInvalidPathID is returned when we receive a message over a blinded path
+// that carries a path ID that was not created by our node.
+var ErrInvalidPathID = errors.New("invalid path id")
+
+// NewPathID creates a path ID for inclusion in the final hop of a blinded
+// path to our node. Path IDs are authenticated with a secret derived from
+// our node key, so that we can verify that messages claiming to use one of
+// our paths were sent over a path that we created.
+func (m *Messenger) NewPathID() ([]byte, error) {
+	key, err := m.getPathIDKey()
+	if err != nil {
+		return nil, err
+	}
+
+	pathID := make([]byte, pathIDLength)
+	if _, err := rand.Read(pathID[:pathIDNonceLength]); err != nil {
+		return nil, fmt.Errorf("path id nonce: %w", err)
+	}
+
+	copy(pathID[pathIDNonceLength:], pathIDMac(key, pathID))
+
+	return pathID, nil
+}
+
+// verifyPathID checks that a path ID was created by our node.
+func (m *Messenger) verifyPathID(pathID []byte) error {
+	if len(pathID) != pathIDLength {
+		return fmt.Errorf("%w: length %v", ErrInvalidPathID,
+			len(pathID))
+	}
+
+	key, err := m.getPathIDKey()
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(pathID[pathIDNonceLength:], pathIDMac(key, pathID)) {
+		return ErrInvalidPathID
+	}
+
+	return nil
+}
+
+// getPathIDKey returns the secret used to authenticate our path IDs,
+// deriving it from our node key if we have not already done so. The secret
+// is derived with an ECDH operation with our own public key, so that it is
+// stable across restarts without needing to be stored.
+func (m *Messenger) getPathIDKey() ([]byte, error) {
+	m.pathIDLock.Lock()
+	defer m.pathIDLock.Unlock()
+
+	if m.pathIDKey != nil {
+		return m.pathIDKey, nil
+	}
+
+	shared, err := m.nodeKeyECDH.ECDH(m.nodeKeyECDH.PubKey())
+	if err != nil {
+		return nil, fmt.Errorf("path id key: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, pathIDKeyTag)
+	mac.Write(shared[:])
+	m.pathIDKey = mac.Sum(nil)
+
+	return m.pathIDKey, nil
+}
+
+// pathIDMac computes the truncated mac for the nonce at the start of the
+// path ID provided.
+func pathIDMac(key, pathID []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(pathID[:pathIDNonceLength])
+
+	return mac.Sum(nil)[:pathIDLength-pathIDNonceLength]
+}
@@ -0,0 +1,60 @@
+package onionmsg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gijswijs/boltnd/testutils"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPathID tests creation and verification of the path IDs that we include
+// in our blinded paths.
+func TestPathID(t *testing.T) {
+	privkeys := testutils.GetPrivkeys(t, 2)
+
+	messenger, err := NewOnionMessenger(nil, &sphinx.PrivKeyECDH{
+		PrivKey: privkeys[0],
+	}, nil)
+	require.NoError(t, err, "new messenger")
+
+	pathID, err := messenger.NewPathID()
+	require.NoError(t, err, "new path id")
+	require.Len(t, pathID, pathIDLength)
+	require.NoError(t, messenger.verifyPathID(pathID))
+
+	// Path IDs should be unique.
+	pathID2, err := messenger.NewPathID()
+	require.NoError(t, err, "new path id")
+	require.NotEqual(t, pathID, pathID2)
+
+	// A messenger with the same node key should accept our path ID, since
+	// our secret is derived from our node key.
+	restarted, err := NewOnionMessenger(nil, &sphinx.PrivKeyECDH{
+		PrivKey: privkeys[0],
+	}, nil)
+	require.NoError(t, err, "new messenger")
+	require.NoError(t, restarted.verifyPathID(pathID))
+
+	// A messenger with a different node key should not.
+	other, err := NewOnionMessenger(nil, &sphinx.PrivKeyECDH{
+		PrivKey: privkeys[1],
+	}, nil)
+	require.NoError(t, err, "new messenger")
+	err = other.verifyPathID(pathID)
+	require.True(t, errors.Is(err, ErrInvalidPathID))
+
+	// Tampering with our nonce or mac should fail verification.
+	for _, i := range []int{0, pathIDLength - 1} {
+		tampered := append([]byte{}, pathID...)
+		tampered[i] ^= 1
+
+		err = messenger.verifyPathID(tampered)
+		require.True(t, errors.Is(err, ErrInvalidPathID))
+	}
+
+	// Path IDs of the wrong length should fail verification.
+	err = messenger.verifyPathID(pathID[1:])
+	require.True(t, errors.Is(err, ErrInvalidPathID))
+}
@@ -0,0 +1,86 @@
+package onionmsg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/routes"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+)
+
+// SelfPingRequest contains a request to send an onion message to our own
+// node.
+type SelfPingRequest struct {
+	// Loop is the set of nodes that our ping is routed through before it
+	// is delivered back to our node, starting with the node that we send
+	// it to. If empty, our ping is sent over a reply path produced by our
+	// reply path generator.
+	Loop []*btcec.PublicKey
+
+	// Hops and DummyHops are the number of hops and dummy hops in the
+	// reply path that we generate when no loop is provided.
+	Hops      uint8
+	DummyHops uint8
+
+	// DirectConnect indicates whether we should make a direct connection
+	// to the first node that our ping is routed through if we're not
+	// already connected to it.
+	DirectConnect bool
+}
+
+// SelfPing sends an onion message to our own node around the loop requested,
+// or over a freshly generated reply path if no loop is provided, and blocks
+// until the message is received or the context provided is cancelled. The
+// path carries a unique path ID that we use to confirm receipt, and the
+// round trip time of the ping is returned. This can be used as a probe to
+// check that the nodes that our ping passes through are online and able to
+// relay onion messages back to us.
+func (m *Messenger) SelfPing(ctx context.Context,
+	req *SelfPingRequest) (time.Duration, error) {
+
+	if len(req.Loop) == 0 && m.replyPaths == nil {
+		return 0, ErrRepliesDisabled
+	}
+
+	pathLength := len(req.Loop) + 1
+	if len(req.Loop) == 0 {
+		pathLength = routes.ReplyPathLength(req.Hops, req.DummyHops)
+	}
+
+	if err := m.validateHopCount(pathLength); err != nil {
+		return 0, fmt.Errorf("ping path: %w", err)
+	}
+
+	pathID, err := m.NewPathID()
+	if err != nil {
+		return 0, err
+	}
+
+	var blindedPath *sphinx.BlindedPath
+	if len(req.Loop) == 0 {
+		blindedPath, err = m.replyPaths.ReplyPath(
+			ctx, nil, req.Hops, req.DummyHops, pathID,
+		)
+	} else {
+		blindedPath, err = routes.LoopPath(
+			req.Loop, m.nodeKeyECDH.PubKey(), pathID,
+		)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ping path: %w", err)
+	}
+
+	sendReq := NewSendMessageRequest(
+		nil, blindedToReplyPath(blindedPath), nil, nil,
+		req.DirectConnect,
+	)
+
+	start := time.Now()
+	if _, err := m.sendAndAwait(ctx, sendReq, pathID); err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
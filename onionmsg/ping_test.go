@@ -0,0 +1,124 @@
+package onionmsg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/routes"
+	"github.com/gijswijs/boltnd/testutils"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// loopReplyPaths is a reply path generator that produces reply paths to our
+// node around a fixed loop.
+type loopReplyPaths struct {
+	loop    []*btcec.PublicKey
+	nodeKey *btcec.PublicKey
+}
+
+// ReplyPath produces a blinded path to our node around our loop that includes
+// the path ID provided.
+func (l *loopReplyPaths) ReplyPath(_ context.Context, _ []lndwire.FeatureBit,
+	_, _ uint8, pathID []byte) (*sphinx.BlindedPath, error) {
+
+	return routes.LoopPath(l.loop, l.nodeKey, pathID)
+}
+
+// TestSelfPing tests sending onion messages to our own node around a loop of
+// nodes in an in-memory network.
+func TestSelfPing(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+
+		// loop is the set of nodes that we route our pings through
+		// before they return to alice.
+		loop = []*btcec.PublicKey{
+			privkeys[1].PubKey(), privkeys[2].PubKey(),
+		}
+
+		// tooLong is a loop that exceeds our maximum route length.
+		tooLong = make([]*btcec.PublicKey, sphinx.NumMaxHops)
+	)
+
+	tests := []struct {
+		name       string
+		replyPaths routes.Generator
+		req        *SelfPingRequest
+		err        error
+	}{
+		{
+			name: "replies not enabled",
+			req:  &SelfPingRequest{},
+			err:  ErrRepliesDisabled,
+		},
+		{
+			name: "loop too long",
+			req: &SelfPingRequest{
+				Loop: tooLong,
+			},
+			err: routes.ErrTooManyHops,
+		},
+		{
+			name: "chosen loop",
+			req: &SelfPingRequest{
+				Loop: loop,
+			},
+		},
+		{
+			name: "reply path",
+			replyPaths: &loopReplyPaths{
+				loop:    loop,
+				nodeKey: privkeys[0].PubKey(),
+			},
+			req: &SelfPingRequest{
+				Hops: 2,
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			var opts []MessengerOption
+			if testCase.replyPaths != nil {
+				opts = append(
+					opts, OptionReplies(testCase.replyPaths),
+				)
+			}
+
+			network := NewMemoryNetwork()
+			messenger := newMemoryMessenger(
+				t, network, privkeys[0], opts...,
+			)
+			newMemoryMessenger(t, network, privkeys[1])
+			newMemoryMessenger(t, network, privkeys[2])
+
+			// Connect our nodes in a loop.
+			_, err := network.AddChannel(alice, bob)
+			require.NoError(t, err)
+
+			_, err = network.AddChannel(bob, carol)
+			require.NoError(t, err)
+
+			_, err = network.AddChannel(carol, alice)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(
+				context.Background(), defaultTimeout,
+			)
+			defer cancel()
+
+			_, err = messenger.SelfPing(ctx, testCase.req)
+			require.ErrorIs(t, err, testCase.err)
+			require.False(t, messenger.awaitingReplies())
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package onionmsg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gijswijs/boltnd/lnwire"
+)
+
+const (
+	// probeNonceLength is the length of the random nonce that we include
+	// in our probes.
+	probeNonceLength = 16
+
+	// probeReplyTimeout is the amount of time that we allow for replying
+	// to a probe.
+	probeReplyTimeout = time.Second * 30
+)
+
+var (
+	// ErrProbeMismatch is returned when the reply to a probe does not
+	// echo the nonce that we sent.
+	ErrProbeMismatch = errors.New("probe reply does not match probe")
+
+	// ErrNoProbeReplyPath is returned when we receive a probe that does
+	// not include a reply path, so we can't respond to it.
+	ErrNoProbeReplyPath = errors.New("probe has no reply path")
+)
+
+// Probe tests whether the destination of the request provided is reachable
+// by onion message, returning the round trip time for a reply to arrive. A
+// probe payload carrying a random nonce is added to the request, which is
+// sent with a freshly generated reply path containing the number of hops and
+// dummy hops provided. We block until the destination echoes our nonce over
+// the reply path or the context provided is cancelled. Only destinations
+// that respond to probes (see OptionProbeResponder) will reply, so a probe
+// that times out indicates that the destination is either unreachable or
+// does not support probes.
+func (m *Messenger) Probe(ctx context.Context, req *SendMessageRequest,
+	hops, dummyHops uint8) (time.Duration, error) {
+
+	nonce := make([]byte, probeNonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, fmt.Errorf("probe nonce: %w", err)
+	}
+
+	// Copy our request and its payloads so that we don't modify the
+	// caller's request.
+	reqCopy := *req
+	reqCopy.FinalPayloads = append(
+		[]*lnwire.FinalHopPayload{
+			{
+				TLVType: lnwire.ProbeType,
+				Value:   nonce,
+			},
+		}, req.FinalPayloads...,
+	)
+
+	if err := reqCopy.Validate(); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	reply, err := m.SendMessageWithReply(ctx, &reqCopy, hops, dummyHops)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+
+	for _, payload := range reply.FinalPayloads {
+		if payload.TLVType == lnwire.ProbeType &&
+			bytes.Equal(payload.Value, nonce) {
+
+			return rtt, nil
+		}
+	}
+
+	return 0, ErrProbeMismatch
+}
+
+// respondToProbe is an onion message handler that echoes the value of the
+// probes that we receive back over their reply path.
+func (m *Messenger) respondToProbe(replyPath *lnwire.ReplyPath, _,
+	value []byte) error {
+
+	if replyPath == nil {
+		return ErrNoProbeReplyPath
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), probeReplyTimeout,
+	)
+	defer cancel()
+
+	req := NewSendMessageRequest(
+		nil, replyPath, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: lnwire.ProbeType,
+				Value:   value,
+			},
+		}, false,
+	)
+
+	// We don't queue probe replies in our outbox, because a late reply
+	// is of no use to the sender.
+	if err := m.sendMessage(ctx, req, m.pathFinder); err != nil {
+		return fmt.Errorf("probe reply: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package onionmsg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProbe tests probing the reachability of destinations in an in-memory
+// network.
+func TestProbe(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+	)
+
+	tests := []struct {
+		name      string
+		responder bool
+		payloads  []*lnwire.FinalHopPayload
+		err       error
+	}{
+		{
+			name:      "destination responds",
+			responder: true,
+		},
+		{
+			name:      "probe with payloads",
+			responder: true,
+			payloads: []*lnwire.FinalHopPayload{
+				{
+					TLVType: 101,
+					Value:   []byte{1, 2, 3},
+				},
+			},
+		},
+		{
+			name:      "duplicate probe payload",
+			responder: true,
+			payloads: []*lnwire.FinalHopPayload{
+				{
+					TLVType: lnwire.ProbeType,
+				},
+			},
+			err: ErrDuplicatePayload,
+		},
+		{
+			name: "destination does not respond",
+			err:  context.DeadlineExceeded,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			network := NewMemoryNetwork()
+
+			// Alice's reply paths go via bob.
+			messenger := newMemoryMessenger(
+				t, network, privkeys[0], OptionReplies(
+					&loopReplyPaths{
+						loop: []*btcec.PublicKey{
+							privkeys[1].PubKey(),
+						},
+						nodeKey: privkeys[0].PubKey(),
+					},
+				),
+			)
+			newMemoryMessenger(t, network, privkeys[1])
+
+			var opts []MessengerOption
+			if testCase.responder {
+				opts = append(opts, OptionProbeResponder())
+			}
+			newMemoryMessenger(t, network, privkeys[2], opts...)
+
+			_, err := network.AddChannel(alice, bob)
+			require.NoError(t, err)
+
+			_, err = network.AddChannel(bob, carol)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(
+				context.Background(), time.Millisecond*500,
+			)
+			defer cancel()
+
+			req := NewSendMessageRequest(
+				privkeys[2].PubKey(), nil, nil,
+				testCase.payloads, false,
+			)
+			rtt, err := messenger.Probe(ctx, req, 1, 0)
+			require.ErrorIs(t, err, testCase.err)
+
+			if testCase.err == nil {
+				require.Positive(t, rtt)
+			}
+
+			// We should not modify the caller's request.
+			require.Equal(t, testCase.payloads, req.FinalPayloads)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lightninglabs/lndclient"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"golang.org/x/time/rate"
+)
+
+// peerLimiterPruneSize is the number of per-peer rate limiters that we hold
+// before we prune the limiters of idle peers.
+const peerLimiterPruneSize = 1000
+
+// ErrForwardRateLimited is returned when we drop an onion message that we
+// were asked to forward because our send rate limits do not allow it to be
+// sent immediately.
+var ErrForwardRateLimited = errors.New("forward rate limited")
+
+// sendLimiter paces the custom messages that we send to lnd, both across all
+// peers and for each individual peer, so that bulk sends don't overflow lnd's
+// low priority custom message queue or trip flood protection at our peers.
+type sendLimiter struct {
+	// global limits the rate at which we send messages to all peers. If
+	// nil, we don't apply a global limit.
+	global *rate.Limiter
+
+	// peerLimit and peerBurst are the rate and burst applied to messages
+	// sent to each peer. If peerLimit is rate.Inf, we don't apply a per
+	// peer limit.
+	peerLimit rate.Limit
+	peerBurst int
+
+	// peers holds the rate limiter for each peer that we have sent to
+	// recently, and must be accessed under lock.
+	peers map[route.Vertex]*rate.Limiter
+	lock  sync.Mutex
+
+	now func() time.Time
+}
+
+// newSendLimiter creates a send limiter with the global and per-peer limits
+// provided. Limits of rate.Inf disable rate limiting.
+func newSendLimiter(globalLimit rate.Limit, globalBurst int,
+	peerLimit rate.Limit, peerBurst int) *sendLimiter {
+
+	s := &sendLimiter{
+		peerLimit: peerLimit,
+		peerBurst: peerBurst,
+		peers:     make(map[route.Vertex]*rate.Limiter),
+		now:       time.Now,
+	}
+
+	if globalLimit != rate.Inf {
+		s.global = rate.NewLimiter(globalLimit, globalBurst)
+	}
+
+	return s
+}
+
+// wait blocks until we may send a message to the peer provided, or until the
+// context provided is cancelled.
+func (s *sendLimiter) wait(ctx context.Context, peer route.Vertex) error {
+	if limiter := s.peerLimiter(peer); limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("peer: %v rate limit: %w", peer, err)
+		}
+	}
+
+	if s.global != nil {
+		if err := s.global.Wait(ctx); err != nil {
+			return fmt.Errorf("global rate limit: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// allow returns a boolean indicating whether we may send a message to the
+// peer provided immediately. If the message is allowed, it is counted against
+// our limits. If it is not allowed, our limits are left unchanged so that a
+// message that is dropped does not use up the peer's quota.
+func (s *sendLimiter) allow(peer route.Vertex) bool {
+	now := s.now()
+
+	var peerReservation *rate.Reservation
+	if limiter := s.peerLimiter(peer); limiter != nil {
+		peerReservation = limiter.ReserveN(now, 1)
+		if !peerReservation.OK() || peerReservation.DelayFrom(now) > 0 {
+			peerReservation.CancelAt(now)
+			return false
+		}
+	}
+
+	if s.global != nil {
+		globalReservation := s.global.ReserveN(now, 1)
+		if !globalReservation.OK() ||
+			globalReservation.DelayFrom(now) > 0 {
+
+			globalReservation.CancelAt(now)
+			if peerReservation != nil {
+				peerReservation.CancelAt(now)
+			}
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// peerLimiter returns the rate limiter for a peer, creating one if required.
+// If we do not apply per-peer limits, nil is returned.
+func (s *sendLimiter) peerLimiter(peer route.Vertex) *rate.Limiter {
+	if s.peerLimit == rate.Inf {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if limiter, ok := s.peers[peer]; ok {
+		return limiter
+	}
+
+	if len(s.peers) >= peerLimiterPruneSize {
+		s.prune()
+	}
+
+	limiter := rate.NewLimiter(s.peerLimit, s.peerBurst)
+	s.peers[peer] = limiter
+
+	return limiter
+}
+
+// prune removes the limiters of peers that have not been sent to recently
+// enough to have any effect, since a limiter that has refilled its full burst
+// is equivalent to a new one. This function must be called under lock.
+func (s *sendLimiter) prune() {
+	now := s.now()
+
+	for peer, limiter := range s.peers {
+		if limiter.TokensAt(now) >= float64(s.peerBurst) {
+			delete(s.peers, peer)
+		}
+	}
+}
+
+// sendCustomMessage sends a custom message to lnd, waiting until our rate
+// limits allow the message to be sent. If lnd refuses to send the message
+// because it is not configured to override its type, the error returned is
+// wrapped with ErrCustomMessageOverride.
+func (m *Messenger) sendCustomMessage(ctx context.Context,
+	msg lndclient.CustomMessage) error {
+
+	if err := m.sendLimiter.wait(ctx, msg.Peer); err != nil {
+		return err
+	}
+
+	err := m.transport.SendCustomMessage(ctx, msg)
+
+	return wrapOverrideError(err, msg.MsgType)
+}
+
+// forwardCustomMessage sends a custom message that we are forwarding to lnd.
+// Unlike our own sends, forwards do not wait for our rate limits because all
+// forwards are sent from a single goroutine, so waiting for a single peer's
+// limit would stall forwarding to all of our other peers. Instead, forwards
+// that exceed our limits are dropped with ErrForwardRateLimited.
+func (m *Messenger) forwardCustomMessage(ctx context.Context,
+	msg lndclient.CustomMessage) error {
+
+	if !m.sendLimiter.allow(msg.Peer) {
+		return fmt.Errorf("%w: dropping message for: %v",
+			ErrForwardRateLimited, msg.Peer)
+	}
+
+	err := m.transport.SendCustomMessage(ctx, msg)
+
+	return wrapOverrideError(err, msg.MsgType)
+}
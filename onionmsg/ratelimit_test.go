@@ -0,0 +1,172 @@
+package onionmsg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// TestSendLimiter tests pacing of sends globally and per peer.
+func TestSendLimiter(t *testing.T) {
+	var (
+		peer1 = route.Vertex{1}
+		peer2 = route.Vertex{2}
+
+		// slow is a limit that won't replenish within our test.
+		slow = rate.Every(time.Hour)
+	)
+
+	tests := []struct {
+		name    string
+		limiter *sendLimiter
+
+		// sends is the set of peers that we send to, in order.
+		sends []route.Vertex
+
+		// limited is the index in sends of the first send that we
+		// expect to be rate limited, or -1 if we expect none to be.
+		limited int
+	}{
+		{
+			name:    "no limits",
+			limiter: newSendLimiter(rate.Inf, 0, rate.Inf, 0),
+			sends:   []route.Vertex{peer1, peer1, peer2, peer1},
+			limited: -1,
+		},
+		{
+			name:    "peer limit",
+			limiter: newSendLimiter(rate.Inf, 0, slow, 2),
+			sends:   []route.Vertex{peer1, peer2, peer1, peer1},
+			limited: 3,
+		},
+		{
+			name:    "global limit",
+			limiter: newSendLimiter(slow, 2, rate.Inf, 0),
+			sends:   []route.Vertex{peer1, peer2, peer1},
+			limited: 2,
+		},
+		{
+			name:    "peer and global limit",
+			limiter: newSendLimiter(slow, 3, slow, 2),
+			sends:   []route.Vertex{peer1, peer1, peer2, peer2},
+			limited: 3,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			for i, peer := range testCase.sends {
+				ctx, cancel := context.WithTimeout(
+					context.Background(),
+					time.Millisecond*20,
+				)
+				err := testCase.limiter.wait(ctx, peer)
+				cancel()
+
+				if i != testCase.limited {
+					require.NoError(t, err, "send: %v", i)
+					continue
+				}
+
+				// The rate limiter fails immediately if the
+				// wait would exceed our context's deadline.
+				require.Error(t, err, "send: %v", i)
+
+				return
+			}
+		})
+	}
+}
+
+// TestSendLimiterPrune tests that we prune the limiters of peers that have
+// fully replenished their burst.
+func TestSendLimiterPrune(t *testing.T) {
+	var (
+		peer1 = route.Vertex{1}
+		peer2 = route.Vertex{2}
+		ctx   = context.Background()
+	)
+
+	limiter := newSendLimiter(rate.Inf, 0, rate.Every(time.Hour), 1)
+
+	// Send to our first peer so that it has used its burst, and create a
+	// limiter for our second peer without sending.
+	require.NoError(t, limiter.wait(ctx, peer1))
+	limiter.peerLimiter(peer2)
+
+	limiter.lock.Lock()
+	limiter.prune()
+	limiter.lock.Unlock()
+
+	require.Len(t, limiter.peers, 1)
+	require.Contains(t, limiter.peers, peer1)
+}
+
+// TestSendLimiterAllow tests non-blocking checks of our limits, and that
+// messages which are not allowed don't use up any of our quota.
+func TestSendLimiterAllow(t *testing.T) {
+	var (
+		peer1 = route.Vertex{1}
+		peer2 = route.Vertex{2}
+		slow  = rate.Every(time.Hour)
+	)
+
+	limiter := newSendLimiter(rate.Inf, 0, slow, 1)
+	require.True(t, limiter.allow(peer1))
+	require.False(t, limiter.allow(peer1))
+	require.True(t, limiter.allow(peer2))
+
+	// When our global limit is exhausted, we expect the token reserved
+	// from our peer's limit to be returned.
+	limiter = newSendLimiter(slow, 1, slow, 2)
+	require.True(t, limiter.allow(peer1))
+	require.False(t, limiter.allow(peer2))
+
+	tokens := limiter.peers[peer2].TokensAt(limiter.now())
+	require.InDelta(t, 2, tokens, 0.01)
+}
+
+// TestForwardRateLimit tests that forwards that exceed our rate limits are
+// dropped rather than waiting for the limit.
+func TestForwardRateLimit(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 1)
+		peer1    = route.Vertex{1}
+		peer2    = route.Vertex{2}
+		ctx      = context.Background()
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionPeerSendLimit(rate.Every(time.Hour), 1),
+	)
+	require.NoError(t, err, "new messenger")
+
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	require.NoError(t, messenger.forwardCustomMessage(
+		ctx, lndclient.CustomMessage{Peer: peer1},
+	))
+
+	err = messenger.forwardCustomMessage(
+		ctx, lndclient.CustomMessage{Peer: peer1},
+	)
+	require.ErrorIs(t, err, ErrForwardRateLimited)
+
+	// Our limited peer should not prevent forwards to other peers.
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	require.NoError(t, messenger.forwardCustomMessage(
+		ctx, lndclient.CustomMessage{Peer: peer2},
+	))
+}
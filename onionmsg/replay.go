@@ -0,0 +1,184 @@
+package onionmsg
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	sphinx "github.com/lightningnetwork/lightning-onion"
+)
+
+const (
+	// replayWindowDefault is the default amount of time that we remember
+	// onion packets for to detect replays.
+	replayWindowDefault = time.Minute * 10
+
+	// replayCacheSizeDefault is the default maximum number of onion
+	// packets that we remember to detect replays.
+	replayCacheSizeDefault = 50000
+)
+
+// ErrReplayedMessage is returned when we receive an onion message that we
+// have already processed.
+var ErrReplayedMessage = errors.New("replayed onion message")
+
+// replayEntry is an entry in our replay cache.
+type replayEntry struct {
+	hash  sphinx.HashPrefix
+	added time.Time
+}
+
+// replayCache is a bounded, time-windowed in-memory implementation of
+// sphinx's replay log. It remembers the shared secret hashes of the onion
+// packets that we've processed for a limited amount of time, evicting the
+// oldest entries once it is full. Onion messages do not have an expiry, so
+// unlike htlcs we can't rely on a cltv to bound the size of our log.
+type replayCache struct {
+	window     time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	// entries holds the elements of our queue keyed by hash prefix, and
+	// queue holds our entries in the order that they were added, so that
+	// we can evict the oldest entries first. Both must be accessed under
+	// lock.
+	entries map[sphinx.HashPrefix]*list.Element
+	queue   *list.List
+	lock    sync.Mutex
+}
+
+// Compile time check that replayCache implements sphinx's replay log.
+var _ sphinx.ReplayLog = (*replayCache)(nil)
+
+// newReplayCache creates a replay cache that holds at most maxEntries onion
+// packets for the window provided.
+func newReplayCache(window time.Duration, maxEntries int,
+	now func() time.Time) *replayCache {
+
+	return &replayCache{
+		window:     window,
+		maxEntries: maxEntries,
+		now:        now,
+		entries:    make(map[sphinx.HashPrefix]*list.Element),
+		queue:      list.New(),
+	}
+}
+
+// Start starts up the replay cache.
+func (r *replayCache) Start() error {
+	return nil
+}
+
+// Stop stops the replay cache.
+func (r *replayCache) Stop() error {
+	return nil
+}
+
+// Get returns sphinx.ErrLogEntryNotFound if the hash prefix provided is not
+// in our cache.
+func (r *replayCache) Get(hash *sphinx.HashPrefix) (uint32, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.evictExpired()
+
+	if _, ok := r.entries[*hash]; !ok {
+		return 0, sphinx.ErrLogEntryNotFound
+	}
+
+	return 0, nil
+}
+
+// Put adds a hash prefix to our cache, returning sphinx.ErrReplayedPacket if
+// it is already present. The cltv provided is ignored, because onion
+// messages are expired by our window instead.
+func (r *replayCache) Put(hash *sphinx.HashPrefix, _ uint32) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.evictExpired()
+
+	if _, ok := r.entries[*hash]; ok {
+		return sphinx.ErrReplayedPacket
+	}
+
+	// If we're full, make space for our new entry by evicting our oldest
+	// entry.
+	if r.queue.Len() >= r.maxEntries {
+		r.remove(r.queue.Front())
+	}
+
+	r.entries[*hash] = r.queue.PushBack(&replayEntry{
+		hash:  *hash,
+		added: r.now(),
+	})
+
+	return nil
+}
+
+// Delete removes a hash prefix from our cache.
+func (r *replayCache) Delete(hash *sphinx.HashPrefix) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if elem, ok := r.entries[*hash]; ok {
+		r.remove(elem)
+	}
+
+	return nil
+}
+
+// PutBatch adds a batch of hash prefixes to our cache, returning the set of
+// entries that were replays.
+func (r *replayCache) PutBatch(batch *sphinx.Batch) (*sphinx.ReplaySet,
+	error) {
+
+	replays := sphinx.NewReplaySet()
+	err := batch.ForEach(func(seqNum uint16, hash *sphinx.HashPrefix,
+		cltv uint32) error {
+
+		err := r.Put(hash, cltv)
+		if errors.Is(err, sphinx.ErrReplayedPacket) {
+			replays.Add(seqNum)
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replays, nil
+}
+
+// len returns the number of entries in our cache.
+func (r *replayCache) len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.queue.Len()
+}
+
+// evictExpired removes all entries that were added before our window. Entries
+// are held in the order that they were added, so we can stop at the first
+// entry that has not expired. This function must be called under lock.
+func (r *replayCache) evictExpired() {
+	cutoff := r.now().Add(-r.window)
+
+	for elem := r.queue.Front(); elem != nil; elem = r.queue.Front() {
+		if elem.Value.(*replayEntry).added.After(cutoff) {
+			return
+		}
+
+		r.remove(elem)
+	}
+}
+
+// remove removes an element from our cache. This function must be called
+// under lock.
+func (r *replayCache) remove(elem *list.Element) {
+	entry := r.queue.Remove(elem).(*replayEntry)
+	delete(r.entries, entry.hash)
+}
@@ -0,0 +1,117 @@
+package onionmsg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/routes"
+	"github.com/gijswijs/boltnd/testutils"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReplayCache tests expiry and eviction of entries in our replay cache.
+func TestReplayCache(t *testing.T) {
+	var (
+		now    = time.Unix(1000, 0)
+		window = time.Minute
+
+		hash1 = &sphinx.HashPrefix{1}
+		hash2 = &sphinx.HashPrefix{2}
+		hash3 = &sphinx.HashPrefix{3}
+	)
+
+	cache := newReplayCache(window, 2, func() time.Time {
+		return now
+	})
+
+	// Add an entry and assert that it's detected as a replay.
+	require.NoError(t, cache.Put(hash1, 0))
+	_, err := cache.Get(hash1)
+	require.NoError(t, err)
+
+	err = cache.Put(hash1, 0)
+	require.True(t, errors.Is(err, sphinx.ErrReplayedPacket))
+
+	// Add a second entry a little later, then fill our cache with a
+	// third, which should evict our oldest entry.
+	now = now.Add(time.Second)
+	require.NoError(t, cache.Put(hash2, 0))
+	require.NoError(t, cache.Put(hash3, 0))
+	require.Equal(t, 2, cache.len())
+
+	_, err = cache.Get(hash1)
+	require.True(t, errors.Is(err, sphinx.ErrLogEntryNotFound))
+
+	// Deleting an entry should allow it to be added again.
+	require.NoError(t, cache.Delete(hash3))
+	require.Equal(t, 1, cache.len())
+	require.NoError(t, cache.Put(hash3, 0))
+
+	// Once our window has passed, all of our entries should expire.
+	now = now.Add(window)
+	_, err = cache.Get(hash2)
+	require.True(t, errors.Is(err, sphinx.ErrLogEntryNotFound))
+	require.Zero(t, cache.len())
+
+	require.NoError(t, cache.Put(hash2, 0))
+	require.Equal(t, 1, cache.len())
+
+	// Batches should report the replays that they contain.
+	batch := sphinx.NewBatch([]byte{1})
+	require.NoError(t, batch.Put(0, hash1, 0))
+	require.NoError(t, batch.Put(1, hash2, 0))
+
+	replays, err := cache.PutBatch(batch)
+	require.NoError(t, err)
+	require.False(t, replays.Contains(0))
+	require.True(t, replays.Contains(1))
+}
+
+// TestProcessReplayedOnion tests that replayed onion messages are rejected
+// by our messenger.
+func TestProcessReplayedOnion(t *testing.T) {
+	privkey := testutils.GetPrivkeys(t, 1)[0]
+
+	messenger, err := NewOnionMessenger(nil, &sphinx.PrivKeyECDH{
+		PrivKey: privkey,
+	}, nil)
+	require.NoError(t, err, "new messenger")
+
+	messenger.router = messenger.newRouter()
+	require.NoError(t, messenger.router.Start())
+	defer messenger.router.Stop()
+
+	sessionKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "session key")
+
+	blindingKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "blinding key")
+
+	resp, err := routes.CreateBlindedRoute(routes.NewBlindedRouteRequest(
+		sessionKey, blindingKey, []*btcec.PublicKey{
+			privkey.PubKey(),
+		}, nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: 100,
+				Value:   []byte{1, 2, 3},
+			},
+		},
+	))
+	require.NoError(t, err, "blinded route")
+
+	msg, err := customOnionMessage(
+		resp.FirstNode, lnwire.OnionMessageType, resp.OnionMessage,
+	)
+	require.NoError(t, err, "custom message")
+
+	_, packet, err := messenger.processOnion(msg.Data)
+	require.NoError(t, err, "first message")
+	require.EqualValues(t, sphinx.ExitNode, packet.Action)
+
+	_, _, err = messenger.processOnion(msg.Data)
+	require.True(t, errors.Is(err, ErrReplayedMessage))
+}
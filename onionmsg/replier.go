@@ -0,0 +1,81 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// ErrNoReplyPath is returned when we try to reply to an onion message that
+// did not include a reply path.
+var ErrNoReplyPath = errors.New("onion message has no reply path")
+
+// IncomingMessage contains a final hop payload that was delivered to our
+// node, along with a helper to reply to the message that carried it.
+type IncomingMessage struct {
+	// ReplyPath is the reply path included in the onion message, which
+	// may be nil if the sender did not provide one.
+	ReplyPath *lnwire.ReplyPath
+
+	// EncryptedData is the encrypted data for our hop.
+	EncryptedData []byte
+
+	// Value is the value of the final hop payload.
+	Value []byte
+
+	// sendMessage sends the onion messages that we reply with.
+	sendMessage func(context.Context, *SendMessageRequest) error
+}
+
+// Reply sends an onion message containing the final hop payloads provided
+// over the message's reply path, failing with ErrNoReplyPath if the sender
+// did not provide one.
+func (i *IncomingMessage) Reply(ctx context.Context,
+	payloads ...*lnwire.FinalHopPayload) error {
+
+	if i.ReplyPath == nil {
+		return ErrNoReplyPath
+	}
+
+	req := NewSendMessageRequest(nil, i.ReplyPath, nil, payloads, false)
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	return i.sendMessage(ctx, req)
+}
+
+// ReplyingHandler is the function signature for handlers that receive final
+// hop payloads with a helper to reply to the onion message that carried them,
+// so that they don't need to send replies with the messenger themselves.
+type ReplyingHandler func(*IncomingMessage) error
+
+// RegisterReplyingHandler adds a handler for onion message payloads of the
+// tlv type provided that are delivered to our node, passing each payload to
+// the handler with a reply helper that is bound to the message's reply path.
+// Replies are sent as with SendMessage. The ID returned identifies the
+// handler for deregistration with DeregisterHandler.
+func (m *Messenger) RegisterReplyingHandler(tlvType tlv.Type,
+	handler ReplyingHandler) (HandlerID, error) {
+
+	return m.RegisterHandler(tlvType, m.replyingHandler(handler))
+}
+
+// replyingHandler wraps a replying handler as a regular onion message
+// handler.
+func (m *Messenger) replyingHandler(
+	handler ReplyingHandler) OnionMessageHandler {
+
+	return func(replyPath *lnwire.ReplyPath, encrypted,
+		value []byte) error {
+
+		return handler(&IncomingMessage{
+			ReplyPath:     replyPath,
+			EncryptedData: encrypted,
+			Value:         value,
+			sendMessage:   m.SendMessage,
+		})
+	}
+}
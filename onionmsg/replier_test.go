@@ -0,0 +1,100 @@
+package onionmsg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReplyingHandler tests replying to onion messages using the reply
+// helper passed to replying handlers.
+func TestReplyingHandler(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 3)
+		alice    = route.NewVertex(privkeys[0].PubKey())
+		bob      = route.NewVertex(privkeys[1].PubKey())
+		carol    = route.NewVertex(privkeys[2].PubKey())
+
+		requestType = tlv.Type(101)
+		replyType   = tlv.Type(103)
+		network     = NewMemoryNetwork()
+	)
+
+	// Alice's reply paths go via bob.
+	sender := newMemoryMessenger(
+		t, network, privkeys[0], OptionReplies(&loopReplyPaths{
+			loop: []*btcec.PublicKey{
+				privkeys[1].PubKey(),
+			},
+			nodeKey: privkeys[0].PubKey(),
+		}),
+	)
+	newMemoryMessenger(t, network, privkeys[1])
+	receiver := newMemoryMessenger(t, network, privkeys[2])
+
+	// Carol echoes the value of each request back to the sender, under
+	// our reply type.
+	replyErrs := make(chan error, 2)
+	_, err := receiver.RegisterReplyingHandler(requestType,
+		func(msg *IncomingMessage) error {
+			err := msg.Reply(
+				context.Background(), &lnwire.FinalHopPayload{
+					TLVType: replyType,
+					Value:   msg.Value,
+				},
+			)
+			replyErrs <- err
+
+			return err
+		},
+	)
+	require.NoError(t, err, "register handler")
+
+	_, err = network.AddChannel(alice, bob)
+	require.NoError(t, err)
+
+	_, err = network.AddChannel(bob, carol)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	value := []byte{1, 2, 3}
+	req := NewSendMessageRequest(
+		privkeys[2].PubKey(), nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: requestType,
+				Value:   value,
+			},
+		}, false,
+	)
+
+	reply, err := sender.SendMessageWithReply(ctx, req, 1, 0)
+	require.NoError(t, err, "send with reply")
+	require.Equal(t, []*lnwire.FinalHopPayload{
+		{
+			TLVType: replyType,
+			Value:   value,
+		},
+	}, reply.FinalPayloads)
+	require.NoError(t, <-replyErrs)
+
+	// A message without a reply path can't be replied to.
+	req.ReplyPath = nil
+	require.NoError(t, sender.SendMessage(ctx, req))
+
+	select {
+	case err := <-replyErrs:
+		require.ErrorIs(t, err, ErrNoReplyPath)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not delivered")
+	}
+}
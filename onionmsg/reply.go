@@ -0,0 +1,185 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/routes"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+)
+
+var (
+	// ErrRepliesDisabled is returned when we try to send a message that
+	// expects a reply without a reply path generator.
+	ErrRepliesDisabled = errors.New("replies not enabled")
+
+	// ErrReplyPathSet is returned when a message that expects a reply
+	// already has a reply path set.
+	ErrReplyPathSet = errors.New("reply path is generated for messages " +
+		"that expect a reply")
+)
+
+// Reply is a reply to an onion message that we sent, delivered to our node
+// over the reply path that we included in the message.
+type Reply struct {
+	// ReplyPath is an optional reply path provided by the sender of the
+	// reply.
+	ReplyPath *lnwire.ReplyPath
+
+	// FinalPayloads is the set of tlv types / values included in the
+	// payload for our node.
+	FinalPayloads []*lnwire.FinalHopPayload
+}
+
+// EnableReplies enables sending of messages that expect a reply, using the
+// generator provided to create reply paths. This function must be called
+// before the messenger is started.
+func (m *Messenger) EnableReplies(generator routes.Generator) error {
+	if m.hasStarted() {
+		return ErrMessengerStarted
+	}
+
+	m.replyPaths = generator
+
+	return nil
+}
+
+// SendMessageWithReply sends an onion message that includes a freshly
+// generated reply path to our node, and blocks until a reply is received over
+// that path or the context provided is cancelled. The reply path contains the
+// number of hops and dummy hops provided, and carries a unique path ID that is
+// used to correlate the reply with our message.
+func (m *Messenger) SendMessageWithReply(ctx context.Context,
+	req *SendMessageRequest, hops, dummyHops uint8) (*Reply, error) {
+
+	if m.replyPaths == nil {
+		return nil, ErrRepliesDisabled
+	}
+
+	if req.ReplyPath != nil {
+		return nil, ErrReplyPathSet
+	}
+
+	err := m.validateHopCount(routes.ReplyPathLength(hops, dummyHops))
+	if err != nil {
+		return nil, fmt.Errorf("reply path: %w", err)
+	}
+
+	pathID, err := m.NewPathID()
+	if err != nil {
+		return nil, err
+	}
+
+	blindedPath, err := m.replyPaths.ReplyPath(
+		ctx, nil, hops, dummyHops, pathID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reply path: %w", err)
+	}
+
+	reqCopy := *req
+	reqCopy.ReplyPath = blindedToReplyPath(blindedPath)
+
+	return m.sendAndAwait(ctx, &reqCopy, pathID)
+}
+
+// sendAndAwait sends an onion message and blocks until a message is received
+// over the blinded path to our node that carries the path ID provided, or the
+// context provided is cancelled.
+func (m *Messenger) sendAndAwait(ctx context.Context, req *SendMessageRequest,
+	pathID []byte) (*Reply, error) {
+
+	// Register for our reply before we send the message so that we can't
+	// miss a fast reply.
+	replies := make(chan *Reply, 1)
+
+	m.repliesLock.Lock()
+	m.pendingReplies[string(pathID)] = replies
+	m.repliesLock.Unlock()
+
+	defer func() {
+		m.repliesLock.Lock()
+		delete(m.pendingReplies, string(pathID))
+		m.repliesLock.Unlock()
+	}()
+
+	// We don't queue messages that expect a reply in our outbox, because
+	// we won't be waiting for the reply when they're delivered.
+	if err := m.sendMessage(ctx, req, m.pathFinder); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case <-m.quit:
+		return nil, ErrShuttingDown
+	}
+}
+
+// awaitingReplies returns a boolean indicating whether we have any messages
+// that are waiting for a reply.
+func (m *Messenger) awaitingReplies() bool {
+	m.repliesLock.Lock()
+	defer m.repliesLock.Unlock()
+
+	return len(m.pendingReplies) != 0
+}
+
+// deliverReply delivers a message to the sender that is waiting for a reply
+// on the path that it was sent over, identified by the path ID in our blinded
+// route data. A boolean is returned to indicate whether the message was
+// consumed as a reply.
+func (m *Messenger) deliverReply(data *lnwire.BlindedRouteData,
+	payload *lnwire.OnionMessagePayload) bool {
+
+	if len(data.PathID) == 0 {
+		return false
+	}
+
+	// Remove the pending reply so that we only deliver a single reply to
+	// the sender.
+	m.repliesLock.Lock()
+	replies, ok := m.pendingReplies[string(data.PathID)]
+	delete(m.pendingReplies, string(data.PathID))
+	m.repliesLock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	// Our channel is buffered, and we only ever send a single reply into
+	// it, so this will not block.
+	replies <- &Reply{
+		ReplyPath:     payload.ReplyPath,
+		FinalPayloads: payload.FinalHopPayloads,
+	}
+
+	return true
+}
+
+// blindedToReplyPath converts a sphinx blinded path to a reply path.
+func blindedToReplyPath(path *sphinx.BlindedPath) *lnwire.ReplyPath {
+	replyPath := &lnwire.ReplyPath{
+		FirstNodeID:   path.IntroductionPoint,
+		BlindingPoint: path.BlindingPoint,
+		Hops: make(
+			[]*lnwire.BlindedHop, len(path.BlindedHops),
+		),
+	}
+
+	for i, hop := range path.BlindedHops {
+		replyPath.Hops[i] = &lnwire.BlindedHop{
+			BlindedNodeID: hop.BlindedNodePub,
+			EncryptedData: hop.CipherText,
+		}
+	}
+
+	return replyPath
+}
@@ -0,0 +1,225 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/routes"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// selfReplyPaths is a reply path generator that produces reply paths where
+// our own node is the introduction node, so that replies can be delivered
+// to us in tests without any relaying nodes. Each path that is generated is
+// sent into the paths channel, which must have sufficient buffer.
+type selfReplyPaths struct {
+	nodeKey *btcec.PublicKey
+	paths   chan *sphinx.BlindedPath
+}
+
+// ReplyPath produces a single hop blinded path to our node that includes the
+// path ID provided.
+func (s *selfReplyPaths) ReplyPath(_ context.Context, _ []lndwire.FeatureBit,
+	_, _ uint8, pathID []byte) (*sphinx.BlindedPath, error) {
+
+	data, err := lnwire.EncodeBlindedRouteData(&lnwire.BlindedRouteData{
+		PathID: pathID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := sphinx.BuildBlindedPath(sessionKey, []*sphinx.HopInfo{
+		{
+			NodePub:   s.nodeKey,
+			PlainText: data,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.paths <- path
+
+	return path, nil
+}
+
+// replyOverPath creates an onion message that replies over the reply path
+// provided.
+func replyOverPath(t *testing.T, replyPath *lnwire.ReplyPath,
+	finalPayloads []*lnwire.FinalHopPayload) lndclient.CustomMessage {
+
+	sessionKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "session key")
+
+	blindingKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err, "blinding key")
+
+	req := routes.NewBlindedRouteRequest(
+		sessionKey, blindingKey, []*btcec.PublicKey{
+			replyPath.FirstNodeID,
+		}, nil, replyPath, finalPayloads,
+	)
+
+	resp, err := routes.CreateBlindedRoute(req)
+	require.NoError(t, err, "blinded route")
+
+	msg, err := customOnionMessage(
+		resp.FirstNode, lnwire.OnionMessageType, resp.OnionMessage,
+	)
+	require.NoError(t, err, "custom message")
+
+	return *msg
+}
+
+// TestSendMessageWithReply tests sending of messages that block until a reply
+// is received over the reply path that we generate for them.
+func TestSendMessageWithReply(t *testing.T) {
+	var (
+		privkey     = testutils.GetPrivkeys(t, 1)[0]
+		nodeKeyECDH = &sphinx.PrivKeyECDH{
+			PrivKey: privkey,
+		}
+
+		peer     = testutils.GetPubkeys(t, 1)[0]
+		peerList = []lndclient.Peer{
+			{
+				Pubkey: route.NewVertex(peer),
+			},
+		}
+
+		finalPayloads = []*lnwire.FinalHopPayload{
+			{
+				TLVType: 100,
+				Value:   []byte{1, 2, 3},
+			},
+		}
+
+		msgChan = make(chan lndclient.CustomMessage)
+		errChan = make(chan error)
+
+		ctxb = context.Background()
+	)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	mockSend := func() {
+		testutils.MockQueryRoutes(
+			lnd.Mock, queryRoutesRequest(peer),
+			&lndclient.QueryRoutesResponse{}, nil,
+		)
+		testutils.MockListPeers(lnd.Mock, peerList, nil)
+		testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	}
+
+	messenger, err := NewOnionMessenger(lnd, nodeKeyECDH, nil)
+	require.NoError(t, err, "new messenger")
+
+	// We can't send messages that expect a reply until replies are
+	// enabled.
+	req := NewSendMessageRequest(peer, nil, nil, nil, false)
+	_, err = messenger.SendMessageWithReply(ctxb, req, 1, 0)
+	require.True(t, errors.Is(err, ErrRepliesDisabled))
+
+	replyPaths := &selfReplyPaths{
+		nodeKey: privkey.PubKey(),
+		paths:   make(chan *sphinx.BlindedPath, 2),
+	}
+	require.NoError(t, messenger.EnableReplies(replyPaths))
+
+	testutils.MockSubscribeCustomMessages(
+		lnd.Mock, msgChan, errChan, nil,
+	)
+	require.NoError(t, messenger.Start(), "start messenger")
+	defer func() {
+		require.NoError(t, messenger.Stop(), "stop messenger")
+	}()
+
+	// Messages that already have a reply path can't be sent with a
+	// generated one.
+	_, err = messenger.SendMessageWithReply(
+		ctxb, &SendMessageRequest{
+			Peer:      peer,
+			ReplyPath: &lnwire.ReplyPath{},
+		}, 1, 0,
+	)
+	require.True(t, errors.Is(err, ErrReplyPathSet))
+
+	// Reply paths that exceed our maximum route length should fail
+	// before we try to generate them.
+	_, err = messenger.SendMessageWithReply(
+		ctxb, req, sphinx.NumMaxHops, 0,
+	)
+	require.True(t, errors.Is(err, routes.ErrTooManyHops))
+
+	// If no reply arrives before our context is cancelled, we should
+	// fail.
+	mockSend()
+	ctxt, cancel := context.WithTimeout(ctxb, time.Millisecond*50)
+	_, err = messenger.SendMessageWithReply(ctxt, req, 1, 0)
+	cancel()
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.False(t, messenger.awaitingReplies())
+
+	// Send a message that expects a reply in the background, since it
+	// will block until we reply.
+	type sendResult struct {
+		reply *Reply
+		err   error
+	}
+
+	result := make(chan sendResult, 1)
+
+	mockSend()
+	go func() {
+		reply, err := messenger.SendMessageWithReply(ctxb, req, 1, 0)
+		result <- sendResult{
+			reply: reply,
+			err:   err,
+		}
+	}()
+
+	require.Eventually(t, messenger.awaitingReplies, defaultTimeout,
+		time.Millisecond*10)
+
+	messenger.repliesLock.Lock()
+	require.Len(t, messenger.pendingReplies, 1)
+	messenger.repliesLock.Unlock()
+
+	// Reply over the path that was generated for our message, and assert
+	// that the reply is delivered to the sender. We first drain the path
+	// generated for our timed out message, which should not receive the
+	// reply.
+	<-replyPaths.paths
+	replyPath := blindedToReplyPath(<-replyPaths.paths)
+	sendMsg(t, msgChan, replyOverPath(t, replyPath, finalPayloads))
+
+	select {
+	case res := <-result:
+		require.NoError(t, res.err)
+		require.Equal(t, finalPayloads, res.reply.FinalPayloads)
+
+	case <-time.After(defaultTimeout):
+		t.Fatal("reply not received")
+	}
+
+	require.False(t, messenger.awaitingReplies())
+
+	// Our request should not have been mutated.
+	require.Nil(t, req.ReplyPath)
+}
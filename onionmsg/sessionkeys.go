@@ -0,0 +1,137 @@
+package onionmsg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+)
+
+var (
+	// ErrShortSeed is returned when a derived session key source is
+	// created with a seed that is too short to derive keys from securely.
+	ErrShortSeed = errors.New("session key seed must be at least 32 bytes")
+
+	// sessionKeyTag and blindingKeyTag domain separate the keys that we
+	// derive for each message.
+	sessionKeyTag  = []byte("onionmsg session key")
+	blindingKeyTag = []byte("onionmsg blinding key")
+)
+
+// randomKeySource is the session key source that we use by default, which
+// generates new random keys for every onion.
+type randomKeySource struct{}
+
+// Compile time check that randomKeySource implements SessionKeySource.
+var _ SessionKeySource = (*randomKeySource)(nil)
+
+// SessionKeys generates a random session key and blinding key.
+func (r *randomKeySource) SessionKeys(_ *SendMessageRequest,
+	_ []*btcec.PublicKey) (*btcec.PrivateKey, *btcec.PrivateKey, error) {
+
+	sessionKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("session key: %w", err)
+	}
+
+	blindingKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("blinding key: %w", err)
+	}
+
+	return sessionKey, blindingKey, nil
+}
+
+// DerivedKeySource derives the keys for each onion from a secret seed and the
+// contents of the message, so that sending the same message along the same
+// path always produces the same onion. This allows onions to be reproduced
+// for debugging, and retries to resend the exact same packet without storing
+// it. Since messages with identical contents and paths share keys, the seed
+// must be kept secret and should not be shared between nodes.
+type DerivedKeySource struct {
+	seed []byte
+}
+
+// Compile time check that DerivedKeySource implements SessionKeySource.
+var _ SessionKeySource = (*DerivedKeySource)(nil)
+
+// NewDerivedKeySource creates a session key source that derives keys from the
+// seed provided, which may itself be derived from lnd's keychain so that it
+// is stable across restarts.
+func NewDerivedKeySource(seed []byte) (*DerivedKeySource, error) {
+	if len(seed) < sha256.Size {
+		return nil, fmt.Errorf("%w: %v bytes", ErrShortSeed, len(seed))
+	}
+
+	return &DerivedKeySource{
+		seed: append([]byte(nil), seed...),
+	}, nil
+}
+
+// SessionKeys derives a session key and blinding key from our seed, the path
+// provided and the destination, reply path and payloads of the request.
+func (d *DerivedKeySource) SessionKeys(req *SendMessageRequest,
+	path []*btcec.PublicKey) (*btcec.PrivateKey, *btcec.PrivateKey, error) {
+
+	commitment, err := messageCommitment(req, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.deriveKey(sessionKeyTag, commitment),
+		d.deriveKey(blindingKeyTag, commitment), nil
+}
+
+// deriveKey derives a private key from our seed for the tag and message
+// commitment provided.
+func (d *DerivedKeySource) deriveKey(tag, commitment []byte) *btcec.PrivateKey {
+	mac := hmac.New(sha256.New, d.seed)
+	mac.Write(tag)
+	mac.Write(commitment)
+
+	key, _ := btcec.PrivKeyFromBytes(mac.Sum(nil))
+
+	return key
+}
+
+// messageCommitment returns a hash that commits to the path that a message is
+// sent along and the contents of the message.
+func messageCommitment(req *SendMessageRequest,
+	path []*btcec.PublicKey) ([]byte, error) {
+
+	hash := sha256.New()
+	for _, hop := range path {
+		hash.Write(hop.SerializeCompressed())
+	}
+
+	// We encode our reply path and payloads as they'll appear in the
+	// final hop's payload, and our blinded destination as a separate
+	// reply path.
+	contents, err := lnwire.EncodeOnionMessagePayload(
+		&lnwire.OnionMessagePayload{
+			ReplyPath:        req.ReplyPath,
+			FinalHopPayloads: req.FinalPayloads,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("encode contents: %w", err)
+	}
+	hash.Write(contents)
+
+	if req.BlindedDestination != nil {
+		dest, err := lnwire.EncodeOnionMessagePayload(
+			&lnwire.OnionMessagePayload{
+				ReplyPath: req.BlindedDestination,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("encode destination: %w", err)
+		}
+		hash.Write(dest)
+	}
+
+	return hash.Sum(nil), nil
+}
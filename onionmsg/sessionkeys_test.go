@@ -0,0 +1,115 @@
+package onionmsg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDerivedKeySource tests derivation of session keys from a seed and the
+// contents of a message.
+func TestDerivedKeySource(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 3)
+		seed1   = make([]byte, 32)
+		seed2   = append(make([]byte, 31), 1)
+
+		path      = []*btcec.PublicKey{pubkeys[0], pubkeys[1]}
+		otherPath = []*btcec.PublicKey{pubkeys[0]}
+	)
+
+	_, err := NewDerivedKeySource(seed1[:31])
+	require.True(t, errors.Is(err, ErrShortSeed))
+
+	request := func(value []byte) *SendMessageRequest {
+		return NewSendMessageRequest(
+			pubkeys[1], nil, nil, []*lnwire.FinalHopPayload{
+				{
+					TLVType: 101,
+					Value:   value,
+				},
+			}, false,
+		)
+	}
+
+	source1, err := NewDerivedKeySource(seed1)
+	require.NoError(t, err)
+
+	source2, err := NewDerivedKeySource(seed2)
+	require.NoError(t, err)
+
+	keys := func(source *DerivedKeySource, req *SendMessageRequest,
+		path []*btcec.PublicKey) [2][]byte {
+
+		sessionKey, blindingKey, err := source.SessionKeys(req, path)
+		require.NoError(t, err)
+
+		return [2][]byte{
+			sessionKey.Serialize(), blindingKey.Serialize(),
+		}
+	}
+
+	// The same message along the same path uses the same keys, and our
+	// session and blinding keys differ.
+	expected := keys(source1, request([]byte{1}), path)
+	require.Equal(t, expected, keys(source1, request([]byte{1}), path))
+	require.NotEqual(t, expected[0], expected[1])
+
+	// Changing the message's contents, its path or our seed changes our
+	// keys.
+	require.NotEqual(t, expected, keys(source1, request([]byte{2}), path))
+	require.NotEqual(t, expected, keys(
+		source1, request([]byte{1}), otherPath,
+	))
+	require.NotEqual(t, expected, keys(source2, request([]byte{1}), path))
+}
+
+// TestDeterministicOnions tests that sending the same message along the same
+// path with a derived key source produces the same onion.
+func TestDeterministicOnions(t *testing.T) {
+	var (
+		privkeys = testutils.GetPrivkeys(t, 1)
+		pubkeys  = testutils.GetPubkeys(t, 2)
+		path     = []*btcec.PublicKey{pubkeys[0], pubkeys[1]}
+		ctx      = context.Background()
+	)
+
+	source, err := NewDerivedKeySource(make([]byte, 32))
+	require.NoError(t, err)
+
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+	testutils.MockSendAnyCustomMessage(lnd.Mock, nil)
+
+	messenger, err := NewOnionMessenger(
+		lnd, &sphinx.PrivKeyECDH{PrivKey: privkeys[0]}, nil,
+		OptionSessionKeySource(source),
+	)
+	require.NoError(t, err)
+
+	req := NewSendMessageRequest(
+		pubkeys[1], nil, nil, []*lnwire.FinalHopPayload{
+			{
+				TLVType: 101,
+				Value:   []byte{1, 2, 3},
+			},
+		}, false,
+	)
+
+	require.NoError(t, messenger.sendAlongPath(ctx, req, path))
+	require.NoError(t, messenger.sendAlongPath(ctx, req, path))
+
+	require.Len(t, lnd.Mock.Calls, 2)
+	first := lnd.Mock.Calls[0].Arguments.Get(1).(lndclient.CustomMessage)
+	second := lnd.Mock.Calls[1].Arguments.Get(1).(lndclient.CustomMessage)
+	require.Equal(t, first.Data, second.Data)
+}
@@ -0,0 +1,281 @@
+package onionmsg
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// PeerStats contains counters for the onion messages that we have relayed
+// for a single peer.
+type PeerStats struct {
+	// Received is the number of onion messages that the peer has sent us.
+	Received uint64
+
+	// Processed is the number of onion messages from the peer that we
+	// have successfully handled, either by delivering them to our
+	// handlers or by queueing them for forwarding.
+	Processed uint64
+
+	// Forwarded is the number of onion messages that we have relayed to
+	// the peer on behalf of others.
+	Forwarded uint64
+
+	// ForwardFailed is the number of onion messages that we failed to
+	// relay to the peer.
+	ForwardFailed uint64
+
+	// QueueDropped is the number of onion messages from the peer that we
+	// dropped because our inbound queue was full.
+	QueueDropped uint64
+
+	// BreakerDropped is the number of onion messages from the peer that
+	// we dropped because the peer had tripped our breaker.
+	BreakerDropped uint64
+
+	// Failed is the number of onion messages from the peer that we failed
+	// to process, keyed by the kind of failure.
+	Failed map[FailureKind]uint64
+
+	// Unhandled is the number of final hop payloads addressed to our node
+	// in onion messages from the peer that we dropped because we had no
+	// handler for their tlv type.
+	Unhandled uint64
+}
+
+// copy returns a deep copy of a peer's stats.
+func (p *PeerStats) copy() PeerStats {
+	stats := *p
+	stats.Failed = make(map[FailureKind]uint64, len(p.Failed))
+
+	for kind, count := range p.Failed {
+		stats.Failed[kind] = count
+	}
+
+	return stats
+}
+
+// peerStats tracks relay activity for each of our peers.
+type peerStats struct {
+	peers map[route.Vertex]*PeerStats
+	lock  sync.Mutex
+}
+
+// newPeerStats creates an empty set of peer stats.
+func newPeerStats() *peerStats {
+	return &peerStats{
+		peers: make(map[route.Vertex]*PeerStats),
+	}
+}
+
+// update applies the update function provided to a peer's stats under lock,
+// creating an entry for the peer if required.
+func (p *peerStats) update(peer route.Vertex, update func(*PeerStats)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats, ok := p.peers[peer]
+	if !ok {
+		stats = &PeerStats{
+			Failed: make(map[FailureKind]uint64),
+		}
+		p.peers[peer] = stats
+	}
+
+	update(stats)
+}
+
+// received records that a peer has sent us an onion message.
+func (p *peerStats) received(peer route.Vertex) {
+	p.update(peer, func(stats *PeerStats) {
+		stats.Received++
+	})
+}
+
+// queueDropped records that we dropped an onion message from a peer because
+// our inbound queue was full.
+func (p *peerStats) queueDropped(peer route.Vertex) {
+	p.update(peer, func(stats *PeerStats) {
+		stats.QueueDropped++
+	})
+}
+
+// breakerDropped records that we dropped an onion message from a peer because
+// it had tripped our breaker.
+func (p *peerStats) breakerDropped(peer route.Vertex) {
+	p.update(peer, func(stats *PeerStats) {
+		stats.BreakerDropped++
+	})
+}
+
+// unhandled records that we had no handler for a final hop payload in an
+// onion message from a peer.
+func (p *peerStats) unhandled(peer route.Vertex) {
+	p.update(peer, func(stats *PeerStats) {
+		stats.Unhandled++
+	})
+}
+
+// processed records the outcome of processing an onion message from a peer.
+func (p *peerStats) processed(peer route.Vertex, err error) {
+	p.update(peer, func(stats *PeerStats) {
+		if err == nil {
+			stats.Processed++
+			return
+		}
+
+		stats.Failed[FailureKindOf(err)]++
+	})
+}
+
+// forwarded records the outcome of relaying an onion message to a peer.
+func (p *peerStats) forwarded(peer route.Vertex, err error) {
+	p.update(peer, func(stats *PeerStats) {
+		if err == nil {
+			stats.Forwarded++
+			return
+		}
+
+		stats.ForwardFailed++
+	})
+}
+
+// snapshot returns a copy of the stats for all of our peers.
+func (p *peerStats) snapshot() map[route.Vertex]PeerStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := make(map[route.Vertex]PeerStats, len(p.peers))
+	for peer, peerStats := range p.peers {
+		stats[peer] = peerStats.copy()
+	}
+
+	return stats
+}
+
+// PeerStats returns counters for the onion messages that we have received
+// from, forwarded to and dropped for each of our peers.
+func (m *Messenger) PeerStats() map[route.Vertex]PeerStats {
+	return m.stats.snapshot()
+}
+
+// DropStats contains counters for the incoming onion messages that we have
+// discarded, totalled across all of our peers.
+type DropStats struct {
+	// QueueFull is the number of onion messages that we dropped because
+	// our inbound queue was full.
+	QueueFull uint64
+
+	// Breaker is the number of onion messages that we dropped because the
+	// peer that sent them had tripped our breaker.
+	Breaker uint64
+
+	// Failed is the number of onion messages that we failed to process,
+	// keyed by the kind of failure (eg, bad onions, replays, policy drops
+	// or unparseable payloads).
+	Failed map[FailureKind]uint64
+
+	// Unhandled is the number of final hop payloads addressed to our node
+	// that we dropped because we had no handler for their tlv type.
+	Unhandled uint64
+}
+
+// DropStats returns counters for each of the reasons that we have discarded
+// incoming onion messages, so that these failures can be monitored without
+// debug logging.
+func (m *Messenger) DropStats() DropStats {
+	drops := DropStats{
+		Failed: make(map[FailureKind]uint64),
+	}
+
+	for _, stats := range m.stats.snapshot() {
+		drops.QueueFull += stats.QueueDropped
+		drops.Breaker += stats.BreakerDropped
+		drops.Unhandled += stats.Unhandled
+
+		for kind, count := range stats.Failed {
+			drops.Failed[kind] += count
+		}
+	}
+
+	return drops
+}
+
+// HandlerStats contains counters for the final hop payloads that we have
+// delivered to the handlers registered for a single tlv type.
+type HandlerStats struct {
+	// Deliveries is the number of times that a handler for the type has
+	// been invoked.
+	Deliveries uint64
+
+	// Errors is the number of handler invocations that returned an
+	// error.
+	Errors uint64
+
+	// TotalLatency is the total amount of time spent in handlers for the
+	// type.
+	TotalLatency time.Duration
+
+	// MaxLatency is the longest time taken by a single handler
+	// invocation.
+	MaxLatency time.Duration
+}
+
+// AverageLatency returns the average time taken by a handler invocation, or
+// zero if the type's handlers have not been invoked.
+func (h HandlerStats) AverageLatency() time.Duration {
+	if h.Deliveries == 0 {
+		return 0
+	}
+
+	return h.TotalLatency / time.Duration(h.Deliveries)
+}
+
+// handlerStats tracks handler activity for a single tlv type. It is updated
+// concurrently by our handler workers.
+type handlerStats struct {
+	stats HandlerStats
+	lock  sync.Mutex
+}
+
+// record records the outcome of a single handler invocation.
+func (h *handlerStats) record(latency time.Duration, err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.stats.Deliveries++
+	h.stats.TotalLatency += latency
+
+	if err != nil {
+		h.stats.Errors++
+	}
+
+	if latency > h.stats.MaxLatency {
+		h.stats.MaxLatency = latency
+	}
+}
+
+// snapshot returns a copy of our current stats.
+func (h *handlerStats) snapshot() HandlerStats {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.stats
+}
+
+// HandlerStats returns delivery counts, error counts and latency for the
+// handlers registered for each tlv type. Stats are kept while a type has
+// handlers registered, and are reset once all of its handlers are removed.
+func (m *Messenger) HandlerStats() map[tlv.Type]HandlerStats {
+	m.handlerLock.RLock()
+	defer m.handlerLock.RUnlock()
+
+	stats := make(map[tlv.Type]HandlerStats, len(m.handlerStats))
+	for tlvType, typeStats := range m.handlerStats {
+		stats[tlvType] = typeStats.snapshot()
+	}
+
+	return stats
+}
@@ -0,0 +1,118 @@
+package onionmsg
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPeerStats tests recording of per-peer relay activity, and that the
+// snapshots we return are not modified by later updates.
+func TestPeerStats(t *testing.T) {
+	var (
+		peer1 = route.Vertex{1}
+		peer2 = route.Vertex{2}
+
+		errDecode = newProcessingError(
+			FailureDecode, peer1, errors.New("decode"),
+		)
+	)
+
+	stats := newPeerStats()
+
+	stats.received(peer1)
+	stats.received(peer1)
+	stats.received(peer1)
+	stats.received(peer1)
+	stats.processed(peer1, nil)
+	stats.processed(peer1, errDecode)
+	stats.queueDropped(peer1)
+	stats.breakerDropped(peer1)
+	stats.unhandled(peer1)
+
+	stats.forwarded(peer2, nil)
+	stats.forwarded(peer2, errors.New("send failed"))
+
+	snapshot := stats.snapshot()
+	require.Equal(t, PeerStats{
+		Received:       4,
+		Processed:      1,
+		QueueDropped:   1,
+		BreakerDropped: 1,
+		Failed: map[FailureKind]uint64{
+			FailureDecode: 1,
+		},
+		Unhandled: 1,
+	}, snapshot[peer1])
+
+	require.Equal(t, PeerStats{
+		Forwarded:     1,
+		ForwardFailed: 1,
+		Failed:        map[FailureKind]uint64{},
+	}, snapshot[peer2])
+
+	// Further updates should not affect our earlier snapshot.
+	stats.processed(peer1, errDecode)
+	require.EqualValues(t, 1, snapshot[peer1].Failed[FailureDecode])
+}
+
+// TestDropStats tests totalling of the reasons that we dropped incoming
+// onion messages across all of our peers.
+func TestDropStats(t *testing.T) {
+	var (
+		peer1 = route.Vertex{1}
+		peer2 = route.Vertex{2}
+
+		errReplay = newProcessingError(
+			FailureReplay, peer1, ErrReplayedMessage,
+		)
+		errPayload = newProcessingError(
+			FailurePayload, peer2, ErrBadOnionBlob,
+		)
+	)
+
+	messenger := &Messenger{
+		stats: newPeerStats(),
+	}
+
+	messenger.stats.processed(peer1, errReplay)
+	messenger.stats.processed(peer2, errReplay)
+	messenger.stats.processed(peer2, errPayload)
+	messenger.stats.processed(peer2, nil)
+	messenger.stats.queueDropped(peer1)
+	messenger.stats.breakerDropped(peer2)
+	messenger.stats.unhandled(peer1)
+	messenger.stats.unhandled(peer2)
+
+	require.Equal(t, DropStats{
+		QueueFull: 1,
+		Breaker:   1,
+		Failed: map[FailureKind]uint64{
+			FailureReplay:  2,
+			FailurePayload: 1,
+		},
+		Unhandled: 2,
+	}, messenger.DropStats())
+}
+
+// TestHandlerStats tests recording of handler invocations for a tlv type.
+func TestHandlerStats(t *testing.T) {
+	stats := &handlerStats{}
+	require.Zero(t, stats.snapshot().AverageLatency())
+
+	stats.record(time.Second, nil)
+	stats.record(time.Second*3, errors.New("handler failed"))
+	stats.record(time.Second*2, nil)
+
+	snapshot := stats.snapshot()
+	require.Equal(t, HandlerStats{
+		Deliveries:   3,
+		Errors:       1,
+		TotalLatency: time.Second * 6,
+		MaxLatency:   time.Second * 3,
+	}, snapshot)
+	require.Equal(t, time.Second*2, snapshot.AverageLatency())
+}
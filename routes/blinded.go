@@ -5,16 +5,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/gijswijs/boltnd/lnwire"
 	"github.com/lightninglabs/lndclient"
 	sphinx "github.com/lightningnetwork/lightning-onion"
 	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// maxRelayCandidates is the maximum number of nodes that we'll look up when
+// selecting each additional hop for a reply path.
+const maxRelayCandidates = 20
+
 var (
 	// ErrNoChannels is returned when we don't have any open channels, so
 	// won't be reachable by onion message.
@@ -33,10 +39,20 @@ var (
 	// we need for onion relay.
 	ErrFeatureMismatch = errors.New("insufficient node features")
 
+	// ErrNoOnionSupport is returned when a peer does not advertise support
+	// for onion messages, so it can't be used to relay them.
+	ErrNoOnionSupport = errors.New("node does not support onion messages")
+
 	// ErrNoRelayingPeers is returned when we have no peers that are
 	// eligible for inclusion in a route with the feature set we require.
 	ErrNoRelayingPeers = errors.New("no relaying peers")
 
+	// ErrInsufficientRelays is returned when we can't find enough nodes
+	// that can relay onion messages to create a reply path with the number
+	// of hops requested.
+	ErrInsufficientRelays = errors.New("insufficient relaying nodes for " +
+		"reply path")
+
 	// ErrNoPath is returned when a request for a blinded route doesn't
 	// have sufficient hops.
 	ErrNoPath = errors.New("at least one hop required in route request")
@@ -49,12 +65,48 @@ var (
 	// from a routes request.
 	ErrBlindingKeyRequired = errors.New("blinding key required")
 
+	// ErrPayloadTooLarge is returned when the payloads for the hops in an
+	// onion message don't fit in an onion packet.
+	ErrPayloadTooLarge = errors.New("onion message payloads too large")
+
+	// ErrTooManyHops is returned when a route has more hops than can be
+	// included in an onion packet.
+	ErrTooManyHops = errors.New("route exceeds maximum hop count")
+
 	// ErrNoIntroductionNode is returned when our introduction node is not
 	// the final hop in a path provided for a send to a blinded route.
 	ErrNoIntroductionNode = errors.New("introduction node should be " +
 		"final hop when sending to a blinded path")
 )
 
+// PayloadSizeError is returned when the payloads for the hops in an onion
+// message exceed the space available in an onion packet. It wraps
+// ErrPayloadTooLarge, and reports the size of the payloads so that callers can
+// determine how much they need to trim.
+type PayloadSizeError struct {
+	// Size is the total size of the hop payloads, in bytes.
+	Size int
+
+	// Limit is the maximum total size of the hop payloads, in bytes.
+	Limit int
+}
+
+// Overflow returns the number of bytes that the payloads exceed the limit by.
+func (p *PayloadSizeError) Overflow() int {
+	return p.Size - p.Limit
+}
+
+// Error returns the error string for a payload size error.
+func (p *PayloadSizeError) Error() string {
+	return fmt.Sprintf("%v: %v bytes exceeds %v byte limit by %v bytes",
+		ErrPayloadTooLarge, p.Size, p.Limit, p.Overflow())
+}
+
+// Unwrap returns the sentinel error wrapped by a payload size error.
+func (p *PayloadSizeError) Unwrap() error {
+	return ErrPayloadTooLarge
+}
+
 // BlindedRouteGenerator produces blinded routes.
 type BlindedRouteGenerator struct {
 	// lnd provides access to our lnd node.
@@ -79,9 +131,20 @@ func NewBlindedRouteGenerator(lnd Lnd,
 }
 
 // ReplyPath produces a blinded route to our node with the set of features
-// requested.
+// requested. The route will contain the number of hops requested before it
+// reaches our node, with a minimum of one hop, followed by the number of dummy
+// hops requested. The path ID provided, if any, is included in the encrypted
+// data for our final hop so that we can identify messages sent over the route.
 func (b *BlindedRouteGenerator) ReplyPath(ctx context.Context,
-	features []lndwire.FeatureBit) (*sphinx.BlindedPath, error) {
+	features []lndwire.FeatureBit, hops, dummyHops uint8, pathID []byte) (
+	*sphinx.BlindedPath, error) {
+
+	// Check that our path will fit in an onion before we do any work to
+	// select relays.
+	err := validateHopCount(ReplyPathLength(hops, dummyHops))
+	if err != nil {
+		return nil, err
+	}
 
 	canRelay := createRelayCheck(features)
 	peers, err := getRelayingPeers(ctx, b.lnd, canRelay)
@@ -89,71 +152,364 @@ func (b *BlindedRouteGenerator) ReplyPath(ctx context.Context,
 		return nil, fmt.Errorf("get relaying peers: %w", err)
 	}
 
-	hops, err := buildBlindedRoute(peers, b.pubkey)
+	relays, err := selectRelays(
+		ctx, b.lnd, peers, canRelay, b.pubkey, hops,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select relays: %w", err)
+	}
+
+	path, err := buildBlindedRoute(relays, b.pubkey, dummyHops, pathID)
 	if err != nil {
 		return nil, fmt.Errorf("blinded route: %w", err)
 	}
 
+	return blindPath(path)
+}
+
+// LoopPath produces a blinded route to our node that passes through the nodes
+// provided, in order, before it reaches our node. The path ID provided, if
+// any, is included in the encrypted data for our final hop. This allows us to
+// send onion messages to ourselves around a loop of our choosing, for example
+// to check that the nodes in the loop are able to relay messages to us.
+func LoopPath(loop []*btcec.PublicKey, ourPubkey *btcec.PublicKey,
+	pathID []byte) (*sphinx.BlindedPath, error) {
+
+	if len(loop) == 0 {
+		return nil, ErrNoPath
+	}
+
+	if err := validateHopCount(len(loop) + 1); err != nil {
+		return nil, err
+	}
+
+	path, err := buildPathToUs(loop, ourPubkey, 0, pathID)
+	if err != nil {
+		return nil, fmt.Errorf("blinded route: %w", err)
+	}
+
+	return blindPath(path)
+}
+
+// blindPath blinds a set of hops with a freshly generated session key.
+func blindPath(path []*sphinx.HopInfo) (*sphinx.BlindedPath, error) {
 	sessionKey, err := btcec.NewPrivateKey()
 	if err != nil {
 		return nil, fmt.Errorf("session key: %w", err)
 	}
 
-	route, err := sphinx.BuildBlindedPath(sessionKey, hops)
+	blindedPath, err := sphinx.BuildBlindedPath(sessionKey, path)
 	if err != nil {
 		return nil, fmt.Errorf("sphinx blinded route: %w", err)
 	}
 
-	return route, nil
+	return blindedPath, nil
 }
 
-// buildBlindedRoute produces a blinded route from a set of peers that can relay
-// onion messages to our node.
-//
-// TODO - this has terrible privacy, fill in more nodes (or dummies) between
-// us and the intro node.
-func buildBlindedRoute(relayingPeers []*lndclient.NodeInfo,
-	ourPubkey *btcec.PublicKey) ([]*sphinx.HopInfo, error) {
+// ReplyPathLength returns the total number of hops in a reply path with the
+// number of hops and dummy hops provided, including our own node. Reply paths
+// always have at least one hop before our node.
+func ReplyPathLength(hops, dummyHops uint8) int {
+	if hops == 0 {
+		hops = 1
+	}
+
+	return int(hops) + 1 + int(dummyHops)
+}
+
+// mostChannels returns the node with the most channels from the set provided,
+// or nil if no nodes are provided.
+func mostChannels(nodes []*lndclient.NodeInfo) *lndclient.NodeInfo {
+	var mostPeers *lndclient.NodeInfo
+	for _, node := range nodes {
+		if mostPeers == nil {
+			mostPeers = node
+		}
+
+		if len(node.Channels) > len(mostPeers.Channels) {
+			mostPeers = node
+		}
+	}
+
+	return mostPeers
+}
+
+// selectRelays selects the set of relaying nodes to be used in a blinded route
+// to our node, ordered from the introduction node to the peer that will relay
+// messages to us. The peer with the most channels is used as the last hop in
+// the route, and the route is extended backwards through the graph until it
+// has the number of hops requested. At each step, the candidate with the most
+// channels is selected so that our introduction node is well connected.
+func selectRelays(ctx context.Context, lnd Lnd,
+	relayingPeers []*lndclient.NodeInfo, canRelay canRelayFunc,
+	ourPubkey *btcec.PublicKey, hops uint8) ([]*lndclient.NodeInfo, error) {
 
 	if len(relayingPeers) == 0 {
 		return nil, ErrNoRelayingPeers
 	}
 
-	var mostPeers *lndclient.NodeInfo
-	for _, peer := range relayingPeers {
-		if mostPeers == nil {
-			mostPeers = peer
+	if hops == 0 {
+		hops = 1
+	}
+
+	lastHop := mostChannels(relayingPeers)
+
+	// Track the nodes that are already in our route (including our own
+	// node) so that we don't create loops.
+	exclude := map[route.Vertex]bool{
+		route.NewVertex(ourPubkey): true,
+		lastHop.PubKey:             true,
+	}
+
+	relays := []*lndclient.NodeInfo{lastHop}
+	for len(relays) < int(hops) {
+		next, err := nextRelay(ctx, lnd, relays[0], exclude, canRelay)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(peer.Channels) > len(mostPeers.Channels) {
-			mostPeers = peer
+		if next == nil {
+			return nil, fmt.Errorf("%w: found %v/%v hops",
+				ErrInsufficientRelays, len(relays), hops)
 		}
+
+		exclude[next.PubKey] = true
+		relays = append([]*lndclient.NodeInfo{next}, relays...)
 	}
 
-	introNode, err := btcec.ParsePubKey(mostPeers.PubKey[:])
-	if err != nil {
-		return nil, fmt.Errorf("intro pubkey: %w", err)
+	return relays, nil
+}
+
+// nextRelay looks up the channel peers of the node provided and returns the
+// peer with the most channels that is able to relay onion messages and is not
+// in our exclusion set. A nil node is returned if no suitable peer is found.
+// The number of peers that are looked up is limited to maxRelayCandidates.
+// Peers that are looked up but can't relay are added to the exclusion set so
+// that they are not looked up again.
+func nextRelay(ctx context.Context, lnd Lnd, node *lndclient.NodeInfo,
+	exclude map[route.Vertex]bool, canRelay canRelayFunc) (
+	*lndclient.NodeInfo, error) {
+
+	var (
+		candidates []*lndclient.NodeInfo
+		lookups    int
+	)
+
+	for _, channel := range node.Channels {
+		if lookups >= maxRelayCandidates {
+			break
+		}
+
+		peer := channel.Node1
+		if peer == node.PubKey {
+			peer = channel.Node2
+		}
+
+		if exclude[peer] {
+			continue
+		}
+
+		// Mark the peer as seen so that we don't look up nodes with
+		// multiple channels more than once.
+		exclude[peer] = true
+		lookups++
+
+		nodeInfo, err := lnd.GetNodeInfo(ctx, peer, true)
+		if err != nil {
+			status, ok := status.FromError(err)
+			if !ok || status.Code() != codes.NotFound {
+				return nil, fmt.Errorf("get node: %w", err)
+			}
+
+			log.Debugf("Node: %x not found in graph", peer)
+
+			continue
+		}
+
+		if err := canRelay(nodeInfo); err != nil {
+			log.Debugf("Node: %x can't relay onion messages: %v",
+				peer, err)
+
+			continue
+		}
+
+		candidates = append(candidates, nodeInfo)
+	}
+
+	// Unmark the candidates that we did not select so that they can be
+	// considered in the next step of our route.
+	next := mostChannels(candidates)
+	for _, candidate := range candidates {
+		if candidate != next {
+			delete(exclude, candidate.PubKey)
+		}
 	}
 
-	introPayload := &lnwire.BlindedRouteData{
-		NextNodeID: ourPubkey,
+	return next, nil
+}
+
+// buildBlindedRoute produces a blinded route to our node from a set of relaying
+// nodes, ordered from the introduction node to the peer that will relay the
+// message to our node. The number of dummy hops requested are added to the end
+// of the route by repeating our own node, and the data for each hop is padded
+// to the same length so that the position of our node in the route is not
+// revealed by the length of the encrypted data. The path ID provided is
+// included in the data for our final hop.
+func buildBlindedRoute(relays []*lndclient.NodeInfo,
+	ourPubkey *btcec.PublicKey, dummyHops uint8, pathID []byte) (
+	[]*sphinx.HopInfo, error) {
+
+	if len(relays) == 0 {
+		return nil, ErrNoRelayingPeers
 	}
 
-	introPayloadBytes, err := lnwire.EncodeBlindedRouteData(introPayload)
+	pubkeys := make([]*btcec.PublicKey, 0, len(relays))
+	for i, relay := range relays {
+		pubkey, err := btcec.ParsePubKey(relay.PubKey[:])
+		if err != nil {
+			return nil, fmt.Errorf("relay %v pubkey: %w", i, err)
+		}
+
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	return buildPathToUs(pubkeys, ourPubkey, dummyHops, pathID)
+}
+
+// buildPathToUs produces the hops for a blinded route to our node that
+// passes through the relays provided, which are ordered from the introduction
+// node to the node that will relay the message to our node, as described in
+// buildBlindedRoute.
+func buildPathToUs(relays []*btcec.PublicKey, ourPubkey *btcec.PublicKey,
+	dummyHops uint8, pathID []byte) ([]*sphinx.HopInfo, error) {
+
+	path := make([]*btcec.PublicKey, 0, len(relays)+int(dummyHops)+1)
+	path = append(path, relays...)
+
+	// Our dummy hops are just our node repeated, each one pointing to
+	// our own node as the next hop. We add one more instance of our
+	// node as the final hop in the route.
+	for i := 0; i <= int(dummyHops); i++ {
+		path = append(path, ourPubkey)
+	}
+
+	// Each hop points to the next hop in the route, apart from the final
+	// hop which is our node and does not need to forward. Our final hop
+	// instead carries our path ID.
+	data := make([]*lnwire.BlindedRouteData, len(path))
+	for i := range path {
+		data[i] = &lnwire.BlindedRouteData{}
+
+		if i < len(path)-1 {
+			data[i].NextNodeID = path[i+1]
+		} else {
+			data[i].PathID = pathID
+		}
+	}
+
+	plaintext, err := padBlindedData(data)
 	if err != nil {
-		return nil, fmt.Errorf("intro payload: %w", err)
+		return nil, err
 	}
 
-	return []*sphinx.HopInfo{
-		{
-			NodePub:   introNode,
-			PlainText: introPayloadBytes,
-		},
-		{
-			NodePub:   ourPubkey,
-			PlainText: nil,
-		},
-	}, nil
+	hops := make([]*sphinx.HopInfo, len(path))
+	for i, pubkey := range path {
+		hops[i] = &sphinx.HopInfo{
+			NodePub:   pubkey,
+			PlainText: plaintext[i],
+		}
+	}
+
+	return hops, nil
+}
+
+// padBlindedData encodes a set of blinded route data, adding padding so that
+// all of the encoded blobs are the same length. Any padding already set in the
+// data is replaced, and data that is already the maximum length is not padded.
+func padBlindedData(data []*lnwire.BlindedRouteData) ([][]byte, error) {
+	var (
+		unpadded = make([]lnwire.BlindedRouteData, len(data))
+		encoded  = make([][]byte, len(data))
+		target   int
+	)
+
+	for i, hopData := range data {
+		unpadded[i] = *hopData
+		unpadded[i].Padding = nil
+
+		var err error
+		encoded[i], err = lnwire.EncodeBlindedRouteData(&unpadded[i])
+		if err != nil {
+			return nil, fmt.Errorf("hop %v data: %w", i, err)
+		}
+
+		if len(encoded[i]) > target {
+			target = len(encoded[i])
+		}
+	}
+
+	// Some differences in length can't be made up with a padding record,
+	// for example when a blob is a single byte shorter than our target,
+	// since a padding record needs at least two bytes. In this case, we
+	// bump our target until all blobs can be padded to it.
+	for !canPadTo(encoded, target) {
+		target++
+	}
+
+	for i := range data {
+		if len(encoded[i]) == target {
+			continue
+		}
+
+		length, _ := paddingLength(target - len(encoded[i]))
+
+		padded := unpadded[i]
+		padded.Padding = make([]byte, length)
+
+		var err error
+		encoded[i], err = lnwire.EncodeBlindedRouteData(&padded)
+		if err != nil {
+			return nil, fmt.Errorf("hop %v padded data: %w", i,
+				err)
+		}
+	}
+
+	return encoded, nil
+}
+
+// canPadTo returns a boolean indicating whether all of the blobs provided can
+// be padded to the target length.
+func canPadTo(blobs [][]byte, target int) bool {
+	for _, blob := range blobs {
+		if len(blob) == target {
+			continue
+		}
+
+		if _, ok := paddingLength(target - len(blob)); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// paddingLength returns the length of the padding value required for a
+// padding record to take up the total number of bytes provided, accounting
+// for the record's type and varint length. The boolean returned is false if
+// no padding record has exactly this encoded size.
+func paddingLength(total int) (int, bool) {
+	// Padding records with values shorter than 253 bytes have a single
+	// byte type and a single byte length.
+	if length := total - 2; length >= 0 && length < 253 {
+		return length, true
+	}
+
+	// Longer padding records have a three byte varint length.
+	if length := total - 4; length >= 253 && length <= math.MaxUint16 {
+		return length, true
+	}
+
+	return 0, false
 }
 
 // canRelayFunc is the function signature of closures used to check whether a
@@ -164,9 +520,12 @@ type canRelayFunc func(*lndclient.NodeInfo) error
 // onion messages:
 //  1. We have a channel with the peer: assuming that onion messages will be
 //     predominantly relayed on channel-lines.
-//  2. The channel is active: an active channel indicates that the peer is
-//     online and will likely be able to relay messages.
-//  3. The node satisfies the canRelay closure passed in (provided as a param
+//  2. The channel is active: an active channel indicates that we are
+//     connected to the peer, so we know that it is online and will be able
+//     to relay messages to us.
+//  3. The peer is only included once, even if we have multiple channels
+//     with it.
+//  4. The node satisfies the canRelay closure passed in (provided as a param
 //     for easy testing).
 func getRelayingPeers(ctx context.Context, lnd Lnd,
 	canRelay canRelayFunc) ([]*lndclient.NodeInfo, error) {
@@ -184,8 +543,16 @@ func getRelayingPeers(ctx context.Context, lnd Lnd,
 		return nil, ErrNoChannels
 	}
 
-	var activePeers []*lndclient.NodeInfo
+	var (
+		activePeers []*lndclient.NodeInfo
+		seen        = make(map[route.Vertex]bool)
+	)
 	for _, channel := range channels {
+		if seen[channel.PubKeyBytes] {
+			continue
+		}
+		seen[channel.PubKeyBytes] = true
+
 		// Lookup the peer in our graph. Skip over any peers that
 		// aren't found (gossip sync is imperfect), but fail if we
 		// error out otherwise.
@@ -220,6 +587,8 @@ func getRelayingPeers(ctx context.Context, lnd Lnd,
 
 // createRelayCheck returns a function that can be used to check a node's
 // channels and features to determine whether we can use it to relay onions.
+// Nodes are always required to advertise support for onion messages, in
+// addition to the set of features provided.
 func createRelayCheck(features []lndwire.FeatureBit) canRelayFunc {
 	return func(nodeInfo *lndclient.NodeInfo) error {
 		// If the node has no public channels, it likely won't be
@@ -234,6 +603,10 @@ func createRelayCheck(features []lndwire.FeatureBit) canRelayFunc {
 			return ErrNoNodeInfo
 		}
 
+		if !lnwire.SupportsOnionMessages(nodeInfo.Features) {
+			return ErrNoOnionSupport
+		}
+
 		featureVec := lndwire.NewRawFeatureVector(nodeInfo.Features...)
 		for _, feature := range features {
 			// We don't need to check our optional features.
@@ -281,8 +654,9 @@ type BlindedRouteRequest struct {
 	blindPath func(*btcec.PrivateKey, []*sphinx.HopInfo) (
 		*sphinx.BlindedPath, error)
 
-	// encodeBlindedData encodes data for blinded route blobs.
-	encodeBlindedData func(*lnwire.BlindedRouteData) ([]byte, error)
+	// encodeBlindedData encodes data for blinded route blobs, padding
+	// them to a uniform length.
+	encodeBlindedData encodeBlindedPayloads
 
 	// directToBlinded returns a response for the edge case where we are
 	// directly connected to the introduction node in our blinded
@@ -344,7 +718,7 @@ func NewBlindedRouteRequest(sessionKey, blindingKey *btcec.PrivateKey,
 		finalPayloads:      finalPayloads,
 		// Fill in functions that we need for non-test path building.
 		blindPath:         sphinx.BuildBlindedPath,
-		encodeBlindedData: encodeBlindedData,
+		encodeBlindedData: padBlindedData,
 		directToBlinded:   directToBlinded,
 	}
 }
@@ -458,9 +832,9 @@ func CreateBlindedRoute(req *BlindedRouteRequest) (*BlindedRouteResponse,
 	}, nil
 }
 
-// encodeBlindedPayload is the function signature used to encode a TLV stream
-// of blinded route data for onion messages.
-type encodeBlindedPayload func(*lnwire.BlindedRouteData) ([]byte, error)
+// encodeBlindedPayloads is the function signature used to encode the TLV
+// streams of blinded route data for a set of hops in an onion message route.
+type encodeBlindedPayloads func([]*lnwire.BlindedRouteData) ([][]byte, error)
 
 type blindedStart struct {
 	unblindedID   *btcec.PublicKey
@@ -492,44 +866,26 @@ type blindedStart struct {
 //
 //	Payload: TLV( next_node_id: intro , override: blinding_point )
 //
-// An encodePayload function is passed in as a parameter for easy mocking in
-// tests.
+// The payloads for all hops are encoded together by the encodePayloads
+// function passed in, so that they can be padded to a uniform length (and
+// easily mocked in tests).
 //
 // Note that this function currently sends empty onion messages to peers (no
 // TLVs in the final hop).
 func createPathToBlind(path []*btcec.PublicKey, blindedStart *blindedStart,
-	encodePayload encodeBlindedPayload) ([]*sphinx.HopInfo, error) {
+	encodePayloads encodeBlindedPayloads) ([]*sphinx.HopInfo, error) {
 
 	hopCount := len(path)
 
-	// Create a set of blinded hops for our path.
-	hopsToBlind := make([]*sphinx.HopInfo, len(path))
-
-	// Create our first hop, which it the introduction node.
-	hopsToBlind[0] = &sphinx.HopInfo{
-		NodePub: path[0],
-	}
-
-	// Run through all paths and add the cleartext node ID to the
-	// previous hop's payload. We need each hop to have the next node's ID
-	// in its payload so that it can unblind the route.
-	for i := 1; i < hopCount; i++ {
-		// Add this node's cleartext pubkey to the previous node's
-		// data.
-		data := &lnwire.BlindedRouteData{
-			NextNodeID: path[i],
-		}
-
-		var err error
-		hopsToBlind[i-1].PlainText, err = encodePayload(data)
-		if err != nil {
-			return nil, fmt.Errorf("intermediate node: %v "+
-				"encoding failed: %w", i, err)
-		}
+	// Run through all paths and add the cleartext node ID of the next
+	// node to each hop's payload. We need each hop to have the next
+	// node's ID in its payload so that it can unblind the route.
+	data := make([]*lnwire.BlindedRouteData, hopCount)
+	for i := 0; i < hopCount; i++ {
+		data[i] = &lnwire.BlindedRouteData{}
 
-		// Add our hop to the set of blinded hops.
-		hopsToBlind[i] = &sphinx.HopInfo{
-			NodePub: path[i],
+		if i < hopCount-1 {
+			data[i].NextNodeID = path[i+1]
 		}
 	}
 
@@ -537,16 +893,23 @@ func createPathToBlind(path []*btcec.PublicKey, blindedStart *blindedStart,
 	// for the last hop in our path pointing it to the introduction node
 	// and providing the ephemeral key to switch out.
 	if blindedStart != nil {
-		data := &lnwire.BlindedRouteData{
+		data[hopCount-1] = &lnwire.BlindedRouteData{
 			NextNodeID:           blindedStart.unblindedID,
 			NextBlindingOverride: blindedStart.blindingPoint,
 		}
+	}
 
-		var err error
-		hopsToBlind[hopCount-1].PlainText, err = encodePayload(data)
-		if err != nil {
-			return nil, fmt.Errorf("ephemeral switch out node: %v",
-				err)
+	payloads, err := encodePayloads(data)
+	if err != nil {
+		return nil, fmt.Errorf("encode payloads: %w", err)
+	}
+
+	// Create a set of blinded hops for our path.
+	hopsToBlind := make([]*sphinx.HopInfo, hopCount)
+	for i, pubkey := range path {
+		hopsToBlind[i] = &sphinx.HopInfo{
+			NodePub:   pubkey,
+			PlainText: payloads[i],
 		}
 	}
 
@@ -568,34 +931,32 @@ func blindedToSphinx(blindedRoute *sphinx.BlindedPath,
 		extraHopCount = len(extraHops)
 	)
 
+	if err := validateHopCount(ourHopCount + extraHopCount); err != nil {
+		return nil, err
+	}
+
 	// Fill in the blinded node id and encrypted data for all hops. This
 	// requirement differs from blinded hops used for payments, where we
 	// don't use the blinded introduction node id. However, since onion
 	// messages are fully blinded by default, we use the blinded
 	// introduction node id.
 	for i := 0; i < ourHopCount; i++ {
-		// Create an onion message payload with the encrypted data for
-		// this hop.
-		payload := &lnwire.OnionMessagePayload{
-			EncryptedData: blindedRoute.BlindedHops[i].CipherText,
-		}
-
-		// If we're on the final hop and there are no extra hops to add
-		// onto our path, include the tlvs intended for the final hop
-		// and the reply path (if provided).
-		if i == ourHopCount-1 && extraHopCount == 0 {
-			payload.FinalHopPayloads = finalPayloads
-			payload.ReplyPath = replyPath
+		// Encode an onion message payload with the encrypted data for
+		// this hop. If we're on the final hop and there are no extra
+		// hops to add onto our path, include the tlvs intended for the
+		// final hop and the reply path (if provided).
+		payload, err := encodeHopPayload(
+			blindedRoute.BlindedHops[i].CipherText,
+			i == ourHopCount-1 && extraHopCount == 0, replyPath,
+			finalPayloads,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("hop %v payload: %w", i, err)
 		}
 
-		// Encode the tlv stream for inclusion in our message.
-		hop, err := createSphinxHop(
+		sphinxPath[i] = *createSphinxHop(
 			*blindedRoute.BlindedHops[i].BlindedNodePub, payload,
 		)
-		if err != nil {
-			return nil, fmt.Errorf("sphinx hop %v: %w", i, err)
-		}
-		sphinxPath[i] = *hop
 	}
 
 	// If we don't have any more hops to append to our path, just return
@@ -605,24 +966,19 @@ func blindedToSphinx(blindedRoute *sphinx.BlindedPath,
 	}
 
 	for i := 0; i < extraHopCount; i++ {
-		payload := &lnwire.OnionMessagePayload{
-			EncryptedData: extraHops[i].EncryptedData,
-		}
-
 		// If we're on the last hop, add our optional final payload
 		// and reply path.
-		if i == extraHopCount-1 {
-			payload.FinalHopPayloads = finalPayloads
-			payload.ReplyPath = replyPath
-		}
-
-		hop, err := createSphinxHop(
-			*extraHops[i].BlindedNodeID, payload,
+		payload, err := encodeHopPayload(
+			extraHops[i].EncryptedData, i == extraHopCount-1,
+			replyPath, finalPayloads,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("sphinx hop %v: %w", i, err)
+			return nil, fmt.Errorf("extra hop %v payload: %w", i,
+				err)
 		}
 
+		hop := createSphinxHop(*extraHops[i].BlindedNodeID, payload)
+
 		// We need to offset our index in the sphinx path by the
 		// number of hops that we added in the loop above.
 		sphinxIndex := i + ourHopCount
@@ -632,38 +988,36 @@ func blindedToSphinx(blindedRoute *sphinx.BlindedPath,
 	return &sphinxPath, nil
 }
 
-// createSphinxHop encodes an onion message payload and produces a sphinx
-// onion hop for it.
-func createSphinxHop(nodeID btcec.PublicKey,
-	payload *lnwire.OnionMessagePayload) (*sphinx.OnionHop, error) {
+// encodeHopPayload encodes the onion message payload for a hop in our path.
+// The final hop's payload includes our reply path and final hop payloads,
+// while intermediate hops only carry their encrypted data.
+func encodeHopPayload(encryptedData []byte, final bool,
+	replyPath *lnwire.ReplyPath,
+	finalPayloads []*lnwire.FinalHopPayload) ([]byte, error) {
 
-	payloadTLVs, err := lnwire.EncodeOnionMessagePayload(payload)
-	if err != nil {
-		return nil, fmt.Errorf("payload: encode: %v", err)
+	if !final {
+		return lnwire.EncodeIntermediatePayload(encryptedData)
 	}
 
+	return lnwire.EncodeOnionMessagePayload(&lnwire.OnionMessagePayload{
+		EncryptedData:    encryptedData,
+		ReplyPath:        replyPath,
+		FinalHopPayloads: finalPayloads,
+	})
+}
+
+// createSphinxHop produces a sphinx onion hop for an encoded onion message
+// payload.
+func createSphinxHop(nodeID btcec.PublicKey,
+	payloadTLVs []byte) *sphinx.OnionHop {
+
 	return &sphinx.OnionHop{
 		NodePub: nodeID,
 		HopPayload: sphinx.HopPayload{
 			Type:    sphinx.PayloadTLV,
 			Payload: payloadTLVs,
 		},
-	}, nil
-}
-
-// encodeBlindedData encodes a TLV stream for an intermediate hop in a
-// blinded route, including only a next_node_id TLV for onion messaging.
-func encodeBlindedData(data *lnwire.BlindedRouteData) ([]byte, error) {
-	if data.NextNodeID == nil {
-		return nil, fmt.Errorf("expected non-nil next hop")
-	}
-
-	bytes, err := lnwire.EncodeBlindedRouteData(data)
-	if err != nil {
-		return nil, fmt.Errorf("encode blinded: %w", err)
 	}
-
-	return bytes, nil
 }
 
 // createOnionMessage creates an onion message from the sphinx path provided.
@@ -671,6 +1025,10 @@ func createOnionMessage(sphinxPath *sphinx.PaymentPath,
 	sessionKey *btcec.PrivateKey,
 	blindingPoint *btcec.PublicKey) (*lnwire.OnionMessage, error) {
 
+	if err := validatePayloadSize(sphinxPath); err != nil {
+		return nil, err
+	}
+
 	// Create an onion packet with no associated data (not required by the
 	// spec).
 	onionPacket, err := sphinx.NewOnionPacket(
@@ -690,6 +1048,32 @@ func createOnionMessage(sphinxPath *sphinx.PaymentPath,
 	), nil
 }
 
+// validateHopCount checks that a route with the number of hops provided fits
+// in an onion packet.
+func validateHopCount(hops int) error {
+	if hops > sphinx.NumMaxHops {
+		return fmt.Errorf("%w: %v hops, maximum %v", ErrTooManyHops,
+			hops, sphinx.NumMaxHops)
+	}
+
+	return nil
+}
+
+// validatePayloadSize checks that the payloads for a sphinx path fit in an
+// onion packet, so that we can fail with an informative error rather than
+// sphinx's generic size error.
+func validatePayloadSize(sphinxPath *sphinx.PaymentPath) error {
+	size := sphinxPath.TotalPayloadSize()
+	if size > sphinx.MaxPayloadSize {
+		return &PayloadSizeError{
+			Size:  size,
+			Limit: sphinx.MaxPayloadSize,
+		}
+	}
+
+	return nil
+}
+
 // directToBlinded returns a route response when we are just sending directly
 // to the blinded destination we have been provided. This covers the edge case
 // where we happen to be connected to the introduction node selected by the
@@ -698,19 +1082,23 @@ func createOnionMessage(sphinxPath *sphinx.PaymentPath,
 func directToBlinded(req *BlindedRouteRequest) (*BlindedRouteResponse, error) {
 	var sphinxPath sphinx.PaymentPath
 
+	hopCount := len(req.blindedDestination.Hops)
+	if err := validateHopCount(hopCount); err != nil {
+		return nil, err
+	}
+
 	for i, hop := range req.blindedDestination.Hops {
-		sphinxHop, err := createSphinxHop(
-			*hop.BlindedNodeID,
-			&lnwire.OnionMessagePayload{
-				EncryptedData: hop.EncryptedData,
-			},
+		// The final hop in the blinded destination is the recipient,
+		// so we include our final payloads and reply path.
+		payload, err := encodeHopPayload(
+			hop.EncryptedData, i == hopCount-1, req.replyPath,
+			req.finalPayloads,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("sphinx hop "+
-				"%v: %w", i, err)
+			return nil, fmt.Errorf("hop %v payload: %w", i, err)
 		}
 
-		sphinxPath[i] = *sphinxHop
+		sphinxPath[i] = *createSphinxHop(*hop.BlindedNodeID, payload)
 	}
 
 	// Note: we still use our session key for the onion
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"testing"
@@ -19,6 +20,23 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// TestReplyPathLength tests that we fail reply paths that won't fit in an
+// onion before we select relays for them.
+func TestReplyPathLength(t *testing.T) {
+	require.Equal(t, 2, ReplyPathLength(0, 0))
+	require.Equal(t, 5, ReplyPathLength(2, 2))
+
+	// We don't expect any calls to lnd, because our path is too long.
+	lnd := testutils.NewMockLnd()
+	defer lnd.Mock.AssertExpectations(t)
+
+	generator := NewBlindedRouteGenerator(lnd, nil)
+	_, err := generator.ReplyPath(
+		context.Background(), nil, sphinx.NumMaxHops, 0, nil,
+	)
+	require.True(t, errors.Is(err, ErrTooManyHops))
+}
+
 // TestGetRelayingPeers tests filtering of peers into a set that can relay
 // onion messages to us.
 func TestGetRelayingPeers(t *testing.T) {
@@ -124,6 +142,35 @@ func TestGetRelayingPeers(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			name: "multiple channels with peer",
+			setupMock: func(m *mock.Mock) {
+				// Return two channels with the same peer.
+				channel1b := channel1
+				channel1b.ChannelID = 3
+
+				testutils.MockListChannels(
+					m, true, false,
+					[]lndclient.ChannelInfo{
+						channel1, channel1b,
+					}, nil,
+				)
+
+				// We only expect our peer to be looked up
+				// once.
+				testutils.MockGetNodeInfo(
+					m, channel1.PubKeyBytes, true,
+					channel1NodeInfo, nil,
+				)
+			},
+			canRelay: func(*lndclient.NodeInfo) error {
+				return nil
+			},
+			peers: []*lndclient.NodeInfo{
+				channel1NodeInfo,
+			},
+			err: nil,
+		},
 		{
 			name: "channel's node not found",
 			setupMock: func(m *mock.Mock) {
@@ -239,6 +286,18 @@ func TestCreateRelayCheck(t *testing.T) {
 			},
 			err: ErrNoNodeInfo,
 		},
+		{
+			name: "no onion message support",
+			nodeInfo: &lndclient.NodeInfo{
+				Channels: nodeChannels,
+				Node: &lndclient.Node{
+					Features: []lndwire.FeatureBit{
+						lndwire.AnchorsRequired,
+					},
+				},
+			},
+			err: ErrNoOnionSupport,
+		},
 		{
 			features: []lndwire.FeatureBit{
 				lndwire.AnchorsRequired,
@@ -249,6 +308,7 @@ func TestCreateRelayCheck(t *testing.T) {
 				Node: &lndclient.Node{
 					Features: []lndwire.FeatureBit{
 						lndwire.AnchorsRequired,
+						lnwire.OnionMessagesOptional,
 					},
 				},
 			},
@@ -268,6 +328,7 @@ func TestCreateRelayCheck(t *testing.T) {
 				Node: &lndclient.Node{
 					Features: []lndwire.FeatureBit{
 						lndwire.AnchorsRequired,
+						lnwire.OnionMessagesOptional,
 					},
 				},
 			},
@@ -286,58 +347,333 @@ func TestCreateRelayCheck(t *testing.T) {
 	}
 }
 
+// TestSelectRelays tests selection of the relaying nodes in a blinded route
+// to our node.
+func TestSelectRelays(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 4)
+		us      = route.NewVertex(pubkeys[0])
+		peerA   = route.NewVertex(pubkeys[1])
+		peerB   = route.NewVertex(pubkeys[2])
+		remote  = route.NewVertex(pubkeys[3])
+
+		onionFeatures = []lndwire.FeatureBit{
+			lnwire.OnionMessagesOptional,
+		}
+
+		peerAInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				PubKey:   peerA,
+				Features: onionFeatures,
+			},
+			Channels: []lndclient.ChannelEdge{
+				{Node1: peerA, Node2: us},
+				{Node1: peerA, Node2: peerB},
+			},
+		}
+
+		// peerBInfo has the most channels of our peers, and has a
+		// channel with a remote node and our other peer.
+		peerBInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				PubKey:   peerB,
+				Features: onionFeatures,
+			},
+			Channels: []lndclient.ChannelEdge{
+				{Node1: us, Node2: peerB},
+				{Node1: remote, Node2: peerB},
+				{Node1: peerA, Node2: peerB},
+			},
+		}
+
+		// remoteInfo has more channels than our other peer, but is
+		// only connected to nodes that are already in our route.
+		remoteInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				PubKey:   remote,
+				Features: onionFeatures,
+			},
+			Channels: []lndclient.ChannelEdge{
+				{Node1: remote, Node2: peerB},
+				{Node1: remote, Node2: peerB},
+				{Node1: us, Node2: remote},
+				{Node1: us, Node2: remote},
+			},
+		}
+
+		// noChannelsInfo is a node that can't relay because it has no
+		// channels.
+		noChannelsInfo = &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				PubKey: peerA,
+			},
+		}
+
+		notFound = status.Error(codes.NotFound, "not found")
+		mockErr  = errors.New("mock")
+		peers    = []*lndclient.NodeInfo{peerAInfo, peerBInfo}
+	)
+
+	tests := []struct {
+		name      string
+		peers     []*lndclient.NodeInfo
+		hops      uint8
+		setupMock func(m *mock.Mock)
+		relays    []*lndclient.NodeInfo
+		err       error
+	}{
+		{
+			name: "no relaying peers",
+			hops: 1,
+			err:  ErrNoRelayingPeers,
+		},
+		{
+			name:   "default to single hop",
+			peers:  peers,
+			hops:   0,
+			relays: []*lndclient.NodeInfo{peerBInfo},
+		},
+		{
+			name:  "two hops",
+			peers: peers,
+			hops:  2,
+			setupMock: func(m *mock.Mock) {
+				testutils.MockGetNodeInfo(
+					m, remote, true, remoteInfo, nil,
+				)
+				testutils.MockGetNodeInfo(
+					m, peerA, true, peerAInfo, nil,
+				)
+			},
+			relays: []*lndclient.NodeInfo{
+				remoteInfo, peerBInfo,
+			},
+		},
+		{
+			// Our remote node is only connected to nodes that are
+			// already in our route, so we can't extend it further.
+			name:  "insufficient relays",
+			peers: peers,
+			hops:  3,
+			setupMock: func(m *mock.Mock) {
+				testutils.MockGetNodeInfo(
+					m, remote, true, remoteInfo, nil,
+				)
+				testutils.MockGetNodeInfo(
+					m, peerA, true, peerAInfo, nil,
+				)
+			},
+			err: ErrInsufficientRelays,
+		},
+		{
+			name:  "candidates not found or can't relay",
+			peers: peers,
+			hops:  2,
+			setupMock: func(m *mock.Mock) {
+				testutils.MockGetNodeInfo(
+					m, remote, true, &lndclient.NodeInfo{},
+					notFound,
+				)
+				testutils.MockGetNodeInfo(
+					m, peerA, true, noChannelsInfo, nil,
+				)
+			},
+			err: ErrInsufficientRelays,
+		},
+		{
+			name:  "lookup failure",
+			peers: peers,
+			hops:  2,
+			setupMock: func(m *mock.Mock) {
+				testutils.MockGetNodeInfo(
+					m, remote, true, &lndclient.NodeInfo{},
+					mockErr,
+				)
+			},
+			err: mockErr,
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			lnd := testutils.NewMockLnd()
+			defer lnd.AssertExpectations(t)
+
+			if testCase.setupMock != nil {
+				testCase.setupMock(lnd.Mock)
+			}
+
+			relays, err := selectRelays(
+				context.Background(), lnd, testCase.peers,
+				createRelayCheck(nil), pubkeys[0],
+				testCase.hops,
+			)
+			require.True(t, errors.Is(err, testCase.err), err)
+			require.Equal(t, testCase.relays, relays)
+		})
+	}
+}
+
+// TestLoopPath tests construction of blinded routes to our node around a
+// loop of nodes.
+func TestLoopPath(t *testing.T) {
+	var (
+		pubkeys = testutils.GetPubkeys(t, 3)
+		pathID  = []byte{1, 2, 3}
+	)
+
+	_, err := LoopPath(nil, pubkeys[0], pathID)
+	require.True(t, errors.Is(err, ErrNoPath))
+
+	_, err = LoopPath(
+		make([]*btcec.PublicKey, sphinx.NumMaxHops), pubkeys[0], pathID,
+	)
+	require.True(t, errors.Is(err, ErrTooManyHops))
+
+	path, err := LoopPath(pubkeys[1:], pubkeys[0], pathID)
+	require.NoError(t, err)
+
+	// Our route should start at the first node in our loop, and contain
+	// a hop for each node in the loop followed by our own node.
+	require.True(t, pubkeys[1].IsEqual(path.IntroductionPoint))
+	require.Len(t, path.BlindedHops, 3)
+}
+
 // TestBuildBlindedRoute tests construction of a blinded route to our node.
 func TestBuildBlindedRoute(t *testing.T) {
 	pubkeys := testutils.GetPubkeys(t, 3)
-	introPayload := &lnwire.BlindedRouteData{
-		NextNodeID: pubkeys[0],
+
+	encode := func(data *lnwire.BlindedRouteData) []byte {
+		encoded, err := lnwire.EncodeBlindedRouteData(data)
+		require.NoError(t, err)
+
+		return encoded
 	}
 
-	introData, err := lnwire.EncodeBlindedRouteData(introPayload)
-	require.NoError(t, err)
+	// Data pointing to the next node in the route is the longest hop
+	// data in our route, so our final hop is padded to match it. Our
+	// padding record has a two byte overhead.
+	var (
+		nextNode = func(next *btcec.PublicKey) []byte {
+			return encode(&lnwire.BlindedRouteData{
+				NextNodeID: next,
+			})
+		}
+
+		finalHop = encode(&lnwire.BlindedRouteData{
+			Padding: make([]byte, len(nextNode(pubkeys[0]))-2),
+		})
+
+		pathID    = []byte{1, 2, 3}
+		pathIDLen = len(encode(&lnwire.BlindedRouteData{
+			PathID: pathID,
+		}))
+		finalHopPathID = encode(&lnwire.BlindedRouteData{
+			Padding: make(
+				[]byte, len(nextNode(pubkeys[0]))-pathIDLen-2,
+			),
+			PathID: pathID,
+		})
+	)
+
+	relay := func(pubkey *btcec.PublicKey) *lndclient.NodeInfo {
+		return &lndclient.NodeInfo{
+			Node: &lndclient.Node{
+				PubKey: route.NewVertex(pubkey),
+			},
+		}
+	}
 
 	tests := []struct {
-		name          string
-		relayingPeers []*lndclient.NodeInfo
-		path          []*sphinx.HopInfo
-		err           error
+		name      string
+		relays    []*lndclient.NodeInfo
+		dummyHops uint8
+		pathID    []byte
+		path      []*sphinx.HopInfo
+		err       error
 	}{
 		{
 			name: "no relaying peers",
 			err:  ErrNoRelayingPeers,
 		},
 		{
-			name: "route with most channels peer",
-			relayingPeers: []*lndclient.NodeInfo{
+			name: "single hop",
+			relays: []*lndclient.NodeInfo{
+				relay(pubkeys[1]),
+			},
+			path: []*sphinx.HopInfo{
 				{
-					Channels: []lndclient.ChannelEdge{
-						{}, {},
-					},
-					Node: &lndclient.Node{
-						PubKey: route.NewVertex(
-							pubkeys[1],
-						),
-					},
+					NodePub:   pubkeys[1],
+					PlainText: nextNode(pubkeys[0]),
 				},
 				{
-					Channels: []lndclient.ChannelEdge{
-						{}, {}, {},
-					},
-					Node: &lndclient.Node{
-						PubKey: route.NewVertex(
-							pubkeys[2],
-						),
-					},
+					NodePub:   pubkeys[0],
+					PlainText: finalHop,
 				},
 			},
+		},
+		{
+			name: "multiple hops",
+			relays: []*lndclient.NodeInfo{
+				relay(pubkeys[2]),
+				relay(pubkeys[1]),
+			},
 			path: []*sphinx.HopInfo{
 				{
 					NodePub:   pubkeys[2],
-					PlainText: introData,
+					PlainText: nextNode(pubkeys[1]),
+				},
+				{
+					NodePub:   pubkeys[1],
+					PlainText: nextNode(pubkeys[0]),
 				},
 				{
 					NodePub:   pubkeys[0],
-					PlainText: nil,
+					PlainText: finalHop,
+				},
+			},
+		},
+		{
+			name: "path id",
+			relays: []*lndclient.NodeInfo{
+				relay(pubkeys[1]),
+			},
+			pathID: pathID,
+			path: []*sphinx.HopInfo{
+				{
+					NodePub:   pubkeys[1],
+					PlainText: nextNode(pubkeys[0]),
+				},
+				{
+					NodePub:   pubkeys[0],
+					PlainText: finalHopPathID,
+				},
+			},
+		},
+		{
+			name: "dummy hops",
+			relays: []*lndclient.NodeInfo{
+				relay(pubkeys[1]),
+			},
+			dummyHops: 2,
+			path: []*sphinx.HopInfo{
+				{
+					NodePub:   pubkeys[1],
+					PlainText: nextNode(pubkeys[0]),
+				},
+				{
+					NodePub:   pubkeys[0],
+					PlainText: nextNode(pubkeys[0]),
+				},
+				{
+					NodePub:   pubkeys[0],
+					PlainText: nextNode(pubkeys[0]),
+				},
+				{
+					NodePub:   pubkeys[0],
+					PlainText: finalHop,
 				},
 			},
 		},
@@ -348,27 +684,119 @@ func TestBuildBlindedRoute(t *testing.T) {
 
 		t.Run(testCase.name, func(t *testing.T) {
 			route, err := buildBlindedRoute(
-				testCase.relayingPeers, pubkeys[0],
+				testCase.relays, pubkeys[0],
+				testCase.dummyHops, testCase.pathID,
 			)
 
 			require.True(t, errors.Is(err, testCase.err))
-			require.Equal(t, route, testCase.path)
+			require.Equal(t, testCase.path, route)
+		})
+	}
+}
+
+// TestPadBlindedData tests padding of blinded route data to a uniform length.
+func TestPadBlindedData(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 2)
+
+	tests := []struct {
+		name string
+		data []*lnwire.BlindedRouteData
+	}{
+		{
+			name: "same length",
+			data: []*lnwire.BlindedRouteData{
+				{NextNodeID: pubkeys[0]},
+				{NextNodeID: pubkeys[1]},
+			},
+		},
+		{
+			name: "empty data",
+			data: []*lnwire.BlindedRouteData{
+				{NextNodeID: pubkeys[0]},
+				{},
+			},
+		},
+		{
+			// Data that is a single byte shorter than our longest
+			// data can't be padded with a two byte padding record,
+			// so all data must be padded.
+			name: "one byte shorter",
+			data: []*lnwire.BlindedRouteData{
+				{Padding: make([]byte, 32)},
+				{NextNodeID: pubkeys[1]},
+			},
+		},
+		{
+			// Padding of 253 bytes or more has a three byte
+			// length.
+			name: "long padding",
+			data: []*lnwire.BlindedRouteData{
+				{PathID: make([]byte, 300)},
+				{NextNodeID: pubkeys[1]},
+			},
+		},
+		{
+			// Data that is 255 bytes shorter than our longest data
+			// can't be padded with a single padding record, since
+			// a record with a 253 byte value takes 257 bytes, so
+			// all data must be padded.
+			name: "varint boundary",
+			data: []*lnwire.BlindedRouteData{
+				{PathID: make([]byte, 286)},
+				{NextNodeID: pubkeys[1]},
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			encoded, err := padBlindedData(testCase.data)
+			require.NoError(t, err)
+			require.Len(t, encoded, len(testCase.data))
+
+			for i, blob := range encoded {
+				require.Len(t, blob, len(encoded[0]))
+
+				// Padding should not change the contents of
+				// our data.
+				decoded, err := lnwire.DecodeBlindedRouteData(
+					blob,
+				)
+				require.NoError(t, err)
+				require.Equal(
+					t, testCase.data[i].NextNodeID,
+					decoded.NextNodeID,
+				)
+			}
 		})
 	}
 }
 
 // mockedPayloadEncode is a mocked encode function for blinded hop paylaods
 // which just returns the compressed serialization of the public key provided,
-// appending the blinding override if it is set.
-func mockedPayloadEncode(data *lnwire.BlindedRouteData) ([]byte, error) {
-	if data.NextBlindingOverride == nil {
-		return data.NextNodeID.SerializeCompressed(), nil
+// appending the blinding override if it is set. Hops without a next node are
+// encoded as nil.
+func mockedPayloadEncode(data []*lnwire.BlindedRouteData) ([][]byte, error) {
+	encoded := make([][]byte, len(data))
+	for i, hop := range data {
+		switch {
+		case hop.NextNodeID == nil:
+
+		case hop.NextBlindingOverride == nil:
+			encoded[i] = hop.NextNodeID.SerializeCompressed()
+
+		default:
+			override := hop.NextBlindingOverride
+			encoded[i] = append(
+				hop.NextNodeID.SerializeCompressed(),
+				override.SerializeCompressed()...,
+			)
+		}
 	}
 
-	return append(
-		data.NextNodeID.SerializeCompressed(),
-		data.NextBlindingOverride.SerializeCompressed()...,
-	), nil
+	return encoded, nil
 }
 
 // TestCreatePathToBlind tests formation of blinded route paths from a set of
@@ -458,6 +886,55 @@ func TestCreatePathToBlind(t *testing.T) {
 	}
 }
 
+// TestCreatePathToBlindPadding tests that the payloads for all hops in a path
+// are padded to the same length.
+func TestCreatePathToBlindPadding(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 4)
+
+	tests := []struct {
+		name         string
+		blindedStart *blindedStart
+	}{
+		{
+			name: "final hop without data",
+		},
+		{
+			name: "final hop with blinding override",
+			blindedStart: &blindedStart{
+				unblindedID:   pubkeys[2],
+				blindingPoint: pubkeys[3],
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			path, err := createPathToBlind(
+				pubkeys[:3], testCase.blindedStart,
+				padBlindedData,
+			)
+			require.NoError(t, err, "create path")
+
+			length := len(path[0].PlainText)
+			for _, hop := range path {
+				require.Len(t, hop.PlainText, length)
+			}
+
+			// Our hops should still point to the next node in
+			// the path.
+			for i, hop := range path[:len(path)-1] {
+				data, err := lnwire.DecodeBlindedRouteData(
+					hop.PlainText,
+				)
+				require.NoError(t, err, "decode")
+				require.Equal(t, pubkeys[i+1], data.NextNodeID)
+			}
+		})
+	}
+}
+
 // TestBlindedToSphinx tests conversion of a blinded path to a sphinx path.
 func TestBlindedToSphinx(t *testing.T) {
 	pubkeys := testutils.GetPubkeys(t, 4)
@@ -551,6 +1028,7 @@ func TestBlindedToSphinx(t *testing.T) {
 		replyPath    *lnwire.ReplyPath
 		finalPayload []*lnwire.FinalHopPayload
 		expectedPath *sphinx.PaymentPath
+		err          error
 	}{
 		{
 			// We should use the blinded pubkey for our introduction
@@ -741,6 +1219,28 @@ func TestBlindedToSphinx(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "intermediate hop without encrypted data",
+			blindedPath: &sphinx.BlindedPath{
+				IntroductionPoint: pubkeys[0],
+				BlindedHops: []*sphinx.BlindedHopInfo{
+					{
+						BlindedNodePub: pubkeys[1],
+						CipherText:     encryptedData0,
+					},
+				},
+			},
+			extraHops: []*lnwire.BlindedHop{
+				{
+					BlindedNodeID: pubkeys[2],
+				},
+				{
+					BlindedNodeID: pubkeys[3],
+					EncryptedData: encryptedData1,
+				},
+			},
+			err: lnwire.ErrNoEncryptedData,
+		},
 	}
 
 	for _, testCase := range tests {
@@ -749,7 +1249,7 @@ func TestBlindedToSphinx(t *testing.T) {
 				testCase.blindedPath, testCase.extraHops,
 				testCase.replyPath, testCase.finalPayload,
 			)
-			require.NoError(t, err)
+			require.True(t, errors.Is(err, testCase.err), err)
 			require.Equal(t, testCase.expectedPath, actualPath)
 		})
 	}
@@ -846,22 +1346,49 @@ func TestValidateRoutesRequest(t *testing.T) {
 func TestDirectToBlinded(t *testing.T) {
 	privkeys := testutils.GetPrivkeys(t, 2)
 	pubkeys := testutils.GetPubkeys(t, 2)
+
+	// blindedDest creates a blinded destination with the number of hops
+	// provided, each with the encrypted data provided.
+	blindedDest := func(hops int, data []byte) *lnwire.ReplyPath {
+		dest := &lnwire.ReplyPath{
+			FirstNodeID:   pubkeys[0],
+			BlindingPoint: pubkeys[1],
+		}
+
+		for i := 0; i < hops; i++ {
+			dest.Hops = append(dest.Hops, &lnwire.BlindedHop{
+				BlindedNodeID: pubkeys[0],
+				EncryptedData: data,
+			})
+		}
+
+		return dest
+	}
+
 	tests := []struct {
-		name        string
-		blindedDest *lnwire.ReplyPath
+		name         string
+		blindedDest  *lnwire.ReplyPath
+		err          error
+		payloadLimit int
 	}{
 		{
-			name: "valid path",
-			blindedDest: &lnwire.ReplyPath{
-				FirstNodeID:   pubkeys[0],
-				BlindingPoint: pubkeys[1],
-				Hops: []*lnwire.BlindedHop{
-					{
-						BlindedNodeID: pubkeys[0],
-						EncryptedData: []byte{1, 2, 3},
-					},
-				},
-			},
+			name:        "valid path",
+			blindedDest: blindedDest(1, []byte{1, 2, 3}),
+		},
+		{
+			name: "too many hops",
+			blindedDest: blindedDest(
+				sphinx.NumMaxHops+1, []byte{1, 2, 3},
+			),
+			err: ErrTooManyHops,
+		},
+		{
+			name: "payload too large",
+			blindedDest: blindedDest(
+				1, make([]byte, sphinx.MaxPayloadSize),
+			),
+			err:          ErrPayloadTooLarge,
+			payloadLimit: sphinx.MaxPayloadSize,
 		},
 	}
 
@@ -879,7 +1406,26 @@ func TestDirectToBlinded(t *testing.T) {
 			)
 
 			resp, err := directToBlinded(req)
-			require.NoError(t, err)
+			require.True(t, errors.Is(err, testCase.err), err)
+
+			// If we exceeded our payload size, check that the
+			// size information is surfaced.
+			if testCase.payloadLimit != 0 {
+				var sizeErr *PayloadSizeError
+				require.True(t, errors.As(err, &sizeErr))
+				require.Equal(
+					t, testCase.payloadLimit, sizeErr.Limit,
+				)
+				require.Equal(
+					t, sizeErr.Size-sizeErr.Limit,
+					sizeErr.Overflow(),
+				)
+				require.Positive(t, sizeErr.Overflow())
+			}
+
+			if testCase.err != nil {
+				return
+			}
 
 			require.Equal(
 				t, resp.OnionMessage.BlindingPoint,
@@ -888,4 +1434,57 @@ func TestDirectToBlinded(t *testing.T) {
 			)
 		})
 	}
+	// Our final payloads and reply path should be included in the payload
+	// for the recipient, which is the final hop in the blinded
+	// destination.
+	var (
+		recipient = privkeys[1]
+		replyPath = blindedDest(1, []byte{4, 5, 6})
+		dest      = &lnwire.ReplyPath{
+			FirstNodeID:   pubkeys[0],
+			BlindingPoint: pubkeys[1],
+			Hops: []*lnwire.BlindedHop{
+				{
+					BlindedNodeID: recipient.PubKey(),
+					EncryptedData: []byte{1, 2, 3},
+				},
+			},
+		}
+
+		finalPayloads = []*lnwire.FinalHopPayload{
+			{
+				TLVType: 101,
+				Value:   []byte{7},
+			},
+		}
+	)
+
+	req := NewBlindedRouteRequest(
+		privkeys[0], privkeys[1], []*btcec.PublicKey{pubkeys[0]},
+		replyPath, dest, finalPayloads,
+	)
+
+	resp, err := directToBlinded(req)
+	require.NoError(t, err)
+
+	onionPkt := &sphinx.OnionPacket{}
+	err = onionPkt.Decode(bytes.NewReader(resp.OnionMessage.OnionBlob))
+	require.NoError(t, err, "decode onion")
+
+	router := sphinx.NewRouter(
+		&sphinx.PrivKeyECDH{PrivKey: recipient},
+		sphinx.NewMemoryReplayLog(),
+	)
+	require.NoError(t, router.Start())
+	defer router.Stop()
+
+	processed, err := router.ProcessOnionPacket(onionPkt, nil, 0)
+	require.NoError(t, err, "process onion")
+
+	payload, err := lnwire.DecodeOnionMessagePayload(
+		processed.Payload.Payload,
+	)
+	require.NoError(t, err, "decode payload")
+	require.Equal(t, finalPayloads, payload.FinalHopPayloads)
+	require.Equal(t, replyPath, payload.ReplyPath)
 }
@@ -23,7 +23,10 @@ type Lnd interface {
 // Generator is an interface implemented by blinded route producers.
 type Generator interface {
 	// ReplyPath produces a blinded route to our node with the set of
-	// features requested.
-	ReplyPath(ctx context.Context,
-		features []lndwire.FeatureBit) (*sphinx.BlindedPath, error)
+	// features requested, containing the number of hops requested before
+	// our node and the number of dummy hops requested after it. If a
+	// path ID is provided, it is included in the data for our final hop.
+	ReplyPath(ctx context.Context, features []lndwire.FeatureBit,
+		hops, dummyHops uint8, pathID []byte) (*sphinx.BlindedPath,
+		error)
 }
@@ -0,0 +1,188 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+)
+
+// replyPathCacheMaxSize is the number of reply paths that we hold before we
+// prune expired entries. If our cache is still full after pruning, we don't
+// cache new paths until entries expire.
+const replyPathCacheMaxSize = 100
+
+// ErrInvalidRotation is returned when a reply path manager is created with an
+// invalid rotation policy.
+var ErrInvalidRotation = errors.New("invalid reply path rotation policy")
+
+// replyPathKey identifies the parameters that a reply path was generated
+// with, so that we only reuse paths for requests with the same parameters.
+type replyPathKey struct {
+	features  string
+	hops      uint8
+	dummyHops uint8
+	pathID    string
+}
+
+// cachedReplyPath is a reply path that we have generated and may reuse.
+type cachedReplyPath struct {
+	path    *sphinx.BlindedPath
+	expires time.Time
+	uses    int
+}
+
+// ReplyPathManager wraps a generator and reuses the reply paths that it
+// creates for repeated requests with the same parameters, rotating each path
+// once it has reached its lifetime or maximum number of uses. This allows us
+// to include reply paths in many outgoing messages without creating a new
+// blinded path for each message, while ensuring that no path is reused
+// indefinitely. Requests with different path IDs never share a path, so
+// paths that carry a unique path ID for correlating replies are not reused.
+// Cached paths are shared between callers, and must not be modified.
+type ReplyPathManager struct {
+	generator Generator
+
+	// lifetime is the amount of time that we reuse a path for.
+	lifetime time.Duration
+
+	// maxUses is the maximum number of times that a path is returned
+	// before we rotate it. If zero, paths are only rotated once their
+	// lifetime has passed.
+	maxUses int
+
+	now func() time.Time
+
+	// paths holds our cached paths, and must be accessed under lock.
+	paths map[replyPathKey]*cachedReplyPath
+	lock  sync.Mutex
+}
+
+// Compile time check that the reply path manager implements the generator
+// interface.
+var _ Generator = (*ReplyPathManager)(nil)
+
+// NewReplyPathManager creates a manager that reuses paths created by the
+// generator provided for the lifetime provided, rotating them sooner if they
+// reach the maximum number of uses provided. A maximum of zero uses allows
+// unlimited reuse until a path's lifetime passes.
+func NewReplyPathManager(generator Generator, lifetime time.Duration,
+	maxUses int) (*ReplyPathManager, error) {
+
+	if lifetime <= 0 {
+		return nil, fmt.Errorf("%w: lifetime %v must be positive",
+			ErrInvalidRotation, lifetime)
+	}
+
+	if maxUses < 0 {
+		return nil, fmt.Errorf("%w: max uses %v must not be negative",
+			ErrInvalidRotation, maxUses)
+	}
+
+	return &ReplyPathManager{
+		generator: generator,
+		lifetime:  lifetime,
+		maxUses:   maxUses,
+		now:       time.Now,
+		paths:     make(map[replyPathKey]*cachedReplyPath),
+	}, nil
+}
+
+// ReplyPath returns a reply path to our node with the parameters requested,
+// reusing a path that we have previously generated with the same parameters
+// if it has not yet reached its lifetime or maximum number of uses.
+func (r *ReplyPathManager) ReplyPath(ctx context.Context,
+	features []lndwire.FeatureBit, hops, dummyHops uint8, pathID []byte) (
+	*sphinx.BlindedPath, error) {
+
+	key := newReplyPathKey(features, hops, dummyHops, pathID)
+
+	r.lock.Lock()
+	cached, ok := r.paths[key]
+	if ok && r.usable(cached) {
+		cached.uses++
+		r.lock.Unlock()
+
+		return cached.path, nil
+	}
+	r.lock.Unlock()
+
+	path, err := r.generator.ReplyPath(
+		ctx, features, hops, dummyHops, pathID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.paths) >= replyPathCacheMaxSize {
+		r.prune()
+	}
+
+	// If our existing path was rotated out, we replace it even if our
+	// cache is full.
+	_, replace := r.paths[key]
+	if replace || len(r.paths) < replyPathCacheMaxSize {
+		r.paths[key] = &cachedReplyPath{
+			path:    path,
+			expires: r.now().Add(r.lifetime),
+			uses:    1,
+		}
+	}
+
+	return path, nil
+}
+
+// Rotate discards all of our cached paths, so that new paths are generated
+// for all subsequent requests.
+func (r *ReplyPathManager) Rotate() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.paths = make(map[replyPathKey]*cachedReplyPath)
+}
+
+// usable returns a boolean indicating whether a cached path may be reused.
+// This function must be called under lock.
+func (r *ReplyPathManager) usable(cached *cachedReplyPath) bool {
+	if !r.now().Before(cached.expires) {
+		return false
+	}
+
+	return r.maxUses == 0 || cached.uses < r.maxUses
+}
+
+// prune removes paths that may no longer be used from our cache. This
+// function must be called under lock.
+func (r *ReplyPathManager) prune() {
+	for key, cached := range r.paths {
+		if !r.usable(cached) {
+			delete(r.paths, key)
+		}
+	}
+}
+
+// newReplyPathKey creates a key for a reply path request. Features are
+// sorted so that requests for the same set of features share paths.
+func newReplyPathKey(features []lndwire.FeatureBit, hops, dummyHops uint8,
+	pathID []byte) replyPathKey {
+
+	sorted := append([]lndwire.FeatureBit(nil), features...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	return replyPathKey{
+		features:  fmt.Sprint(sorted),
+		hops:      hops,
+		dummyHops: dummyHops,
+		pathID:    string(pathID),
+	}
+}
@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	lndwire "github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// countingGenerator is a generator that returns a new path for each call.
+type countingGenerator struct {
+	calls int
+}
+
+// ReplyPath returns a new, empty blinded path.
+func (c *countingGenerator) ReplyPath(context.Context, []lndwire.FeatureBit,
+	uint8, uint8, []byte) (*sphinx.BlindedPath, error) {
+
+	c.calls++
+	return &sphinx.BlindedPath{}, nil
+}
+
+// TestReplyPathManager tests reuse and rotation of reply paths.
+func TestReplyPathManager(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		lifetime = time.Minute
+		now      = time.Unix(1000, 0)
+
+		features = []lndwire.FeatureBit{1, 3}
+		reversed = []lndwire.FeatureBit{3, 1}
+	)
+
+	_, err := NewReplyPathManager(&countingGenerator{}, 0, 0)
+	require.True(t, errors.Is(err, ErrInvalidRotation))
+
+	_, err = NewReplyPathManager(&countingGenerator{}, lifetime, -1)
+	require.True(t, errors.Is(err, ErrInvalidRotation))
+
+	generator := &countingGenerator{}
+	manager, err := NewReplyPathManager(generator, lifetime, 2)
+	require.NoError(t, err)
+
+	manager.now = func() time.Time {
+		return now
+	}
+
+	// Our first request creates a path, which is reused for a request
+	// with the same features in a different order.
+	path1, err := manager.ReplyPath(ctx, features, 1, 0, nil)
+	require.NoError(t, err)
+
+	path2, err := manager.ReplyPath(ctx, reversed, 1, 0, nil)
+	require.NoError(t, err)
+	require.Same(t, path1, path2)
+	require.Equal(t, 1, generator.calls)
+
+	// Once the path has reached its maximum uses, it is rotated.
+	path3, err := manager.ReplyPath(ctx, features, 1, 0, nil)
+	require.NoError(t, err)
+	require.NotSame(t, path1, path3)
+	require.Equal(t, 2, generator.calls)
+
+	// Requests with different parameters don't share paths.
+	_, err = manager.ReplyPath(ctx, features, 2, 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, generator.calls)
+
+	_, err = manager.ReplyPath(ctx, features, 1, 0, []byte{1})
+	require.NoError(t, err)
+	require.Equal(t, 4, generator.calls)
+
+	// Once our path's lifetime passes, it is rotated even though it has
+	// uses remaining.
+	now = now.Add(lifetime)
+
+	path4, err := manager.ReplyPath(ctx, features, 1, 0, nil)
+	require.NoError(t, err)
+	require.NotSame(t, path3, path4)
+	require.Equal(t, 5, generator.calls)
+
+	// Rotating all paths forces a new path to be created.
+	manager.Rotate()
+
+	path5, err := manager.ReplyPath(ctx, features, 1, 0, nil)
+	require.NoError(t, err)
+	require.NotSame(t, path4, path5)
+	require.Equal(t, 6, generator.calls)
+}
@@ -30,7 +30,7 @@ func (s *Server) DecodeOffer(ctx context.Context,
 		return nil, err
 	}
 
-	offer, err := offers.DecodeOfferStr(offerStr)
+	offer, err := offerDecoder(req.CompatMode)(offerStr)
 	if err != nil {
 		return nil, err
 	}
@@ -53,11 +53,33 @@ func parseDecodeOfferRequest(req *offersrpc.DecodeOfferRequest) (string,
 	return req.Offer, nil
 }
 
+// offerDecoder returns the function used to decode offer strings, using the
+// encoding of other bolt 12 implementations if compat is set.
+func offerDecoder(compat bool) func(string) (*lnwire.Offer, error) {
+	if compat {
+		return offers.DecodeOfferStrCompat
+	}
+
+	return offers.DecodeOfferStr
+}
+
 // composeDecodeOfferResponse creates a DecodeOfferResponse from the internal
 // offer type.
 func composeDecodeOfferResponse(offer *lnwire.Offer) (
 	*offersrpc.DecodeOfferResponse, error) {
 
+	rpcOffer, err := composeOffer(offer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offersrpc.DecodeOfferResponse{
+		Offer: rpcOffer,
+	}, nil
+}
+
+// composeOffer converts the internal offer type to a rpc offer.
+func composeOffer(offer *lnwire.Offer) (*offersrpc.Offer, error) {
 	rpcOffer := &offersrpc.Offer{
 		MinAmountMsat: uint64(offer.MinimumAmount),
 		Description:   offer.Description,
@@ -66,6 +88,13 @@ func composeDecodeOfferResponse(offer *lnwire.Offer) (
 		MaxQuantity:   offer.QuantityMax,
 	}
 
+	// Our rpc expresses currency amounts in the minor unit of the
+	// currency in the min amount field.
+	if offer.CurrencyAmount != nil {
+		rpcOffer.MinAmountMsat = offer.CurrencyAmount.Minor
+		rpcOffer.Currency = offer.CurrencyAmount.Code
+	}
+
 	if offer.Features != nil {
 		buf := new(bytes.Buffer)
 
@@ -93,9 +122,8 @@ func composeDecodeOfferResponse(offer *lnwire.Offer) (
 
 	if offer.Signature != nil {
 		rpcOffer.Signature = hex.EncodeToString(offer.Signature[:])
+		rpcOffer.SignatureValid = offer.VerifySignature() == nil
 	}
 
-	return &offersrpc.DecodeOfferResponse{
-		Offer: rpcOffer,
-	}, nil
+	return rpcOffer, nil
 }
@@ -0,0 +1,95 @@
+package rpcserver
+
+import (
+	"context"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/offersrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxDecodeOffers is the maximum number of offer strings that we'll decode in
+// a single DecodeOffers request.
+const maxDecodeOffers = 10000
+
+// DecodeOffers decodes and validates a batch of offer strings, returning a
+// result for each offer provided.
+func (s *Server) DecodeOffers(ctx context.Context,
+	req *offersrpc.DecodeOffersRequest) (*offersrpc.DecodeOffersResponse,
+	error) {
+
+	log.Debugf("DecodeOffers: %v offers", len(req.Offers))
+
+	if err := s.waitForReady(ctx); err != nil {
+		return nil, err
+	}
+
+	offerStrs, err := parseDecodeOffersRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return composeDecodeOffersResponse(
+		offerStrs, offerDecoder(req.CompatMode),
+	)
+}
+
+// parseDecodeOffersRequest parses and validates the parameters provided by
+// DecodeOffersRequest. All errors returned *must* include a grpc status code.
+func parseDecodeOffersRequest(req *offersrpc.DecodeOffersRequest) ([]string,
+	error) {
+
+	if len(req.Offers) == 0 {
+		return nil, status.Error(
+			codes.InvalidArgument, "at least one offer required",
+		)
+	}
+
+	if len(req.Offers) > maxDecodeOffers {
+		return nil, status.Errorf(
+			codes.InvalidArgument, "%v offers exceeds maximum: %v",
+			len(req.Offers), maxDecodeOffers,
+		)
+	}
+
+	return req.Offers, nil
+}
+
+// composeDecodeOffersResponse decodes each of the offer strings provided using
+// the decode function provided and creates a DecodeOffersResponse. Failure to
+// decode an individual offer is reported in its result rather than failing the
+// whole request.
+func composeDecodeOffersResponse(offerStrs []string,
+	decode func(string) (*lnwire.Offer, error)) (
+	*offersrpc.DecodeOffersResponse, error) {
+
+	resp := &offersrpc.DecodeOffersResponse{
+		Results: make([]*offersrpc.DecodeOfferResult, len(offerStrs)),
+	}
+
+	for i, offerStr := range offerStrs {
+		result := &offersrpc.DecodeOfferResult{}
+		resp.Results[i] = result
+
+		if offerStr == "" {
+			result.Error = "offer string required"
+			continue
+		}
+
+		offer, err := decode(offerStr)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		// Failing to compose our rpc offer is an internal error, so
+		// we fail the whole request.
+		result.Offer, err = composeOffer(offer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
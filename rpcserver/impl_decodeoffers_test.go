@@ -0,0 +1,108 @@
+package rpcserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gijswijs/boltnd/offersrpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validOfferStr is an encoded, valid offer.
+const validOfferStr = "lno1pqqnyzsmx5cx6umpwssx6atvw35j6ut4v9h8g6t50ysx7en" +
+	"xv4epgrmjw4ehgcm0wfczucm0d5hxzagkqyq3ugztng063cqx783exlm97ekyprnd4rs" +
+	"u5u5w5sez9fecrhcuc3ykq5"
+
+// compatOfferStr is a valid offer that uses the encoding of other bolt 12
+// implementations.
+const compatOfferStr = "lno1pqps7sjqpgtyzm3qv4uxzmtsd3jjqer9wd3hy6tsw35k7" +
+	"msjzfpy7nz5yqcnygrfdej82um5wf5k2uckyypwa3eyt44h6txtxquqh7lz5djge4af" +
+	"gfjn7k4rgrkuag0jsd5xvxg"
+
+// TestDecodeOffers tests the rpc mechanics of batch decoding offers.
+func TestDecodeOffers(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *offersrpc.DecodeOffersRequest
+		errCode codes.Code
+
+		// results contains the expected success of each offer decode.
+		results []bool
+	}{
+		{
+			name:    "no offers supplied",
+			request: &offersrpc.DecodeOffersRequest{},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "too many offers",
+			request: &offersrpc.DecodeOffersRequest{
+				Offers: make([]string, maxDecodeOffers+1),
+			},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "mixed results",
+			request: &offersrpc.DecodeOffersRequest{
+				Offers: []string{
+					validOfferStr,
+					"",
+					"lno1invalid",
+					validOfferStr,
+				},
+			},
+			results: []bool{true, false, false, true},
+		},
+		{
+			name: "compat offers require compat mode",
+			request: &offersrpc.DecodeOffersRequest{
+				Offers: []string{compatOfferStr},
+			},
+			results: []bool{false},
+		},
+		{
+			name: "compat mode",
+			request: &offersrpc.DecodeOffersRequest{
+				Offers:     []string{compatOfferStr},
+				CompatMode: true,
+			},
+			results: []bool{true},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			s := newServerTest(t)
+			s.start()
+			defer s.stop()
+
+			resp, err := s.server.DecodeOffers(
+				context.Background(), testCase.request,
+			)
+
+			if testCase.errCode != codes.OK {
+				status, ok := status.FromError(err)
+				require.True(t, ok, "expected coded error")
+				require.Equal(t, testCase.errCode, status.Code())
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, resp.Results, len(testCase.results))
+
+			for i, success := range testCase.results {
+				result := resp.Results[i]
+
+				require.Equal(t, success, result.Offer != nil,
+					"result: %v", i)
+				require.Equal(t, success, result.Error == "",
+					"result: %v", i)
+			}
+		})
+	}
+}
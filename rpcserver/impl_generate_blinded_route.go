@@ -12,8 +12,14 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// ErrFeatureOverflow is returned if a feature will overflow uint16.
-var ErrFeatureOverflow = errors.New("feature exceeds maximum value")
+var (
+	// ErrFeatureOverflow is returned if a feature will overflow uint16.
+	ErrFeatureOverflow = errors.New("feature exceeds maximum value")
+
+	// ErrHopsOverflow is returned if the number of hops requested for a
+	// blinded route will overflow uint8.
+	ErrHopsOverflow = errors.New("hop count exceeds maximum value")
+)
 
 // GenerateBlindedRoute generates a blinded route to our node.
 func (s *Server) GenerateBlindedRoute(ctx context.Context,
@@ -26,12 +32,21 @@ func (s *Server) GenerateBlindedRoute(ctx context.Context,
 		return nil, err
 	}
 
-	features, err := parseGenerateBlindedRouteRequest(req)
+	features, hops, dummyHops, err := parseGenerateBlindedRouteRequest(req)
 	if err != nil {
 		return nil, err
 	}
 
-	route, err := s.routeGenerator.ReplyPath(ctx, features)
+	// Include a path ID in our route so that we can verify that messages
+	// sent over it were sent over a route that we created.
+	pathID, err := s.onionMsgr.NewPathID()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	route, err := s.routeGenerator.ReplyPath(
+		ctx, features, hops, dummyHops, pathID,
+	)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -46,17 +61,27 @@ func (s *Server) GenerateBlindedRoute(ctx context.Context,
 //
 // All errors returned *must* include a grpc status code.
 func parseGenerateBlindedRouteRequest(req *offersrpc.GenerateBlindedRouteRequest) (
-	[]lndwire.FeatureBit, error) {
+	[]lndwire.FeatureBit, uint8, uint8, error) {
 
 	features := make([]lndwire.FeatureBit, len(req.Features))
 	for i, feature := range req.Features {
 		if feature > math.MaxUint16 {
-			return nil, status.Errorf(codes.InvalidArgument,
+			return nil, 0, 0, status.Errorf(codes.InvalidArgument,
 				"%v: %v", ErrFeatureOverflow, feature)
 		}
 
 		features[i] = lnwire.FeatureBit(feature)
 	}
 
-	return features, nil
+	if req.NumHops > math.MaxUint8 {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument,
+			"%v: %v", ErrHopsOverflow, req.NumHops)
+	}
+
+	if req.NumDummyHops > math.MaxUint8 {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument,
+			"dummy %v: %v", ErrHopsOverflow, req.NumDummyHops)
+	}
+
+	return features, uint8(req.NumHops), uint8(req.NumDummyHops), nil
 }
@@ -2,6 +2,7 @@ package rpcserver
 
 import (
 	"context"
+	"errors"
 	"math"
 	"testing"
 
@@ -24,9 +25,12 @@ func TestGenerateBlindedRoute(t *testing.T) {
 		BlindingPoint:     pubkeys[1],
 	}
 
+	pathID := []byte{1, 2, 3}
+	pathIDErr := errors.New("path id failed")
+
 	tests := []struct {
 		name      string
-		setupMock func(m *mock.Mock)
+		setupMock func(offers, routes *mock.Mock)
 		request   *offersrpc.GenerateBlindedRouteRequest
 		errCode   codes.Code
 	}{
@@ -43,9 +47,11 @@ func TestGenerateBlindedRoute(t *testing.T) {
 		{
 			name:    "no features",
 			request: &offersrpc.GenerateBlindedRouteRequest{},
-			setupMock: func(m *mock.Mock) {
+			setupMock: func(offers, routes *mock.Mock) {
+				mockNewPathID(offers, pathID, nil)
 				testutils.MockBlindedRoute(
-					m, []lndwire.FeatureBit{}, path, nil,
+					routes, []lndwire.FeatureBit{}, 0, 0,
+					path, nil,
 				)
 			},
 		},
@@ -56,11 +62,61 @@ func TestGenerateBlindedRoute(t *testing.T) {
 					uint64(lndwire.AMPOptional),
 				},
 			},
-			setupMock: func(m *mock.Mock) {
+			setupMock: func(offers, routes *mock.Mock) {
+				mockNewPathID(offers, pathID, nil)
 				testutils.MockBlindedRoute(
-					m, []lndwire.FeatureBit{
+					routes, []lndwire.FeatureBit{
 						lndwire.AMPOptional,
-					}, path, nil,
+					}, 0, 0, path, nil,
+				)
+			},
+		},
+		{
+			name:    "path id failure",
+			request: &offersrpc.GenerateBlindedRouteRequest{},
+			setupMock: func(offers, routes *mock.Mock) {
+				mockNewPathID(offers, nil, pathIDErr)
+			},
+			errCode: codes.Internal,
+		},
+		{
+			name: "bad hop count",
+			request: &offersrpc.GenerateBlindedRouteRequest{
+				NumHops: math.MaxUint8 + 1,
+			},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "multiple hops",
+			request: &offersrpc.GenerateBlindedRouteRequest{
+				NumHops: 3,
+			},
+			setupMock: func(offers, routes *mock.Mock) {
+				mockNewPathID(offers, pathID, nil)
+				testutils.MockBlindedRoute(
+					routes, []lndwire.FeatureBit{}, 3, 0,
+					path, nil,
+				)
+			},
+		},
+		{
+			name: "bad dummy hop count",
+			request: &offersrpc.GenerateBlindedRouteRequest{
+				NumDummyHops: math.MaxUint8 + 1,
+			},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "dummy hops",
+			request: &offersrpc.GenerateBlindedRouteRequest{
+				NumHops:      2,
+				NumDummyHops: 2,
+			},
+			setupMock: func(offers, routes *mock.Mock) {
+				mockNewPathID(offers, pathID, nil)
+				testutils.MockBlindedRoute(
+					routes, []lndwire.FeatureBit{}, 2, 2,
+					path, nil,
 				)
 			},
 		},
@@ -74,7 +130,9 @@ func TestGenerateBlindedRoute(t *testing.T) {
 			defer s.stop()
 
 			if testCase.setupMock != nil {
-				testCase.setupMock(s.routeMock.Mock)
+				testCase.setupMock(
+					s.offerMock.Mock, s.routeMock.Mock,
+				)
 			}
 
 			_, err := s.server.GenerateBlindedRoute(
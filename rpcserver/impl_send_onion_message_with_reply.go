@@ -0,0 +1,100 @@
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/gijswijs/boltnd/offersrpc"
+	"github.com/gijswijs/boltnd/onionmsg"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SendOnionMessageWithReply sends an onion message including a reply path to
+// our node, and waits for a reply to be received over that path.
+func (s *Server) SendOnionMessageWithReply(ctx context.Context,
+	req *offersrpc.SendOnionMessageWithReplyRequest) (
+	*offersrpc.SendOnionMessageWithReplyResponse, error) {
+
+	log.Debugf("SendOnionMessageWithReply: %+v", req)
+
+	if err := s.waitForReady(ctx); err != nil {
+		return nil, err
+	}
+
+	onionReq, hops, dummyHops, err := parseSendOnionMessageWithReplyRequest(
+		req,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.onionMsgr.SendMessageWithReply(
+		ctx, onionReq, hops, dummyHops,
+	)
+	switch {
+	// If the client's context expired before we got a reply, let them
+	// know that they may want to wait longer.
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, status.Errorf(
+			codes.DeadlineExceeded, "no reply received: %v", err,
+		)
+
+	case errors.Is(err, context.Canceled):
+		return nil, status.Error(codes.Canceled, "client cancel")
+
+	case err != nil:
+		return nil, sendOnionMessageErr(err)
+	}
+
+	resp := &offersrpc.SendOnionMessageWithReplyResponse{
+		FinalPayloads: make(
+			map[uint64][]byte, len(reply.FinalPayloads),
+		),
+		ReplyPath: composeReplyPath(reply.ReplyPath),
+	}
+
+	for _, payload := range reply.FinalPayloads {
+		resp.FinalPayloads[uint64(payload.TLVType)] = payload.Value
+	}
+
+	return resp, nil
+}
+
+// parseSendOnionMessageWithReplyRequest parses and validates the parameters
+// provided by SendOnionMessageWithReply. All errors returned *must* include a
+// grpc status code.
+func parseSendOnionMessageWithReplyRequest(
+	req *offersrpc.SendOnionMessageWithReplyRequest) (
+	*onionmsg.SendMessageRequest, uint8, uint8, error) {
+
+	if req.Message == nil {
+		return nil, 0, 0, status.Error(
+			codes.InvalidArgument, "message required",
+		)
+	}
+
+	if req.Message.ReplyPath != nil {
+		return nil, 0, 0, status.Errorf(
+			codes.InvalidArgument, "%v", onionmsg.ErrReplyPathSet,
+		)
+	}
+
+	if req.NumHops > math.MaxUint8 {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument,
+			"%v: %v", ErrHopsOverflow, req.NumHops)
+	}
+
+	if req.NumDummyHops > math.MaxUint8 {
+		return nil, 0, 0, status.Errorf(codes.InvalidArgument,
+			"dummy %v: %v", ErrHopsOverflow, req.NumDummyHops)
+	}
+
+	onionReq, err := parseSendOnionMessageRequest(req.Message)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	return onionReq, uint8(req.NumHops), uint8(req.NumDummyHops), nil
+}
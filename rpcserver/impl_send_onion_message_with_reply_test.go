@@ -0,0 +1,166 @@
+package rpcserver
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/gijswijs/boltnd/offersrpc"
+	"github.com/gijswijs/boltnd/onionmsg"
+	"github.com/gijswijs/boltnd/testutils"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestRPCSendOnionMessageWithReply tests the rpc mechanics around sending an
+// onion message that expects a reply. The messenger is mocked, so this test
+// is primarily concerned with parsing, error handling and response creation.
+func TestRPCSendOnionMessageWithReply(t *testing.T) {
+	pubkeys := testutils.GetPubkeys(t, 3)
+	pubkeyBytes := pubkeys[0].SerializeCompressed()
+
+	var (
+		onionReq = onionmsg.NewSendMessageRequest(
+			pubkeys[0], nil, nil, []*lnwire.FinalHopPayload{},
+			false,
+		)
+
+		replyPath = &lnwire.ReplyPath{
+			FirstNodeID:   pubkeys[1],
+			BlindingPoint: pubkeys[2],
+			Hops: []*lnwire.BlindedHop{
+				{
+					BlindedNodeID: pubkeys[2],
+					EncryptedData: []byte{1, 2, 3},
+				},
+			},
+		}
+
+		reply = &onionmsg.Reply{
+			ReplyPath: replyPath,
+			FinalPayloads: []*lnwire.FinalHopPayload{
+				{
+					TLVType: 100,
+					Value:   []byte{4, 5, 6},
+				},
+			},
+		}
+	)
+
+	tests := []struct {
+		name      string
+		setupMock func(*mock.Mock)
+		request   *offersrpc.SendOnionMessageWithReplyRequest
+		response  *offersrpc.SendOnionMessageWithReplyResponse
+		errCode   codes.Code
+	}{
+		{
+			name:    "no message",
+			request: &offersrpc.SendOnionMessageWithReplyRequest{},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "reply path set",
+			request: &offersrpc.SendOnionMessageWithReplyRequest{
+				Message: &offersrpc.SendOnionMessageRequest{
+					Pubkey:    pubkeyBytes,
+					ReplyPath: composeReplyPath(replyPath),
+				},
+			},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "bad hop count",
+			request: &offersrpc.SendOnionMessageWithReplyRequest{
+				Message: &offersrpc.SendOnionMessageRequest{
+					Pubkey: pubkeyBytes,
+				},
+				NumHops: math.MaxUint8 + 1,
+			},
+			errCode: codes.InvalidArgument,
+		},
+		{
+			name: "no reply received",
+			setupMock: func(m *mock.Mock) {
+				mockSendMessageWithReply(
+					m, onionReq, 2, 1, nil,
+					context.DeadlineExceeded,
+				)
+			},
+			request: &offersrpc.SendOnionMessageWithReplyRequest{
+				Message: &offersrpc.SendOnionMessageRequest{
+					Pubkey: pubkeyBytes,
+				},
+				NumHops:      2,
+				NumDummyHops: 1,
+			},
+			errCode: codes.DeadlineExceeded,
+		},
+		{
+			name: "no path",
+			setupMock: func(m *mock.Mock) {
+				mockSendMessageWithReply(
+					m, onionReq, 0, 0, nil,
+					onionmsg.ErrNoPath,
+				)
+			},
+			request: &offersrpc.SendOnionMessageWithReplyRequest{
+				Message: &offersrpc.SendOnionMessageRequest{
+					Pubkey: pubkeyBytes,
+				},
+			},
+			errCode: codes.NotFound,
+		},
+		{
+			name: "reply received",
+			setupMock: func(m *mock.Mock) {
+				mockSendMessageWithReply(
+					m, onionReq, 1, 0, reply, nil,
+				)
+			},
+			request: &offersrpc.SendOnionMessageWithReplyRequest{
+				Message: &offersrpc.SendOnionMessageRequest{
+					Pubkey: pubkeyBytes,
+				},
+				NumHops: 1,
+			},
+			response: &offersrpc.SendOnionMessageWithReplyResponse{
+				FinalPayloads: map[uint64][]byte{
+					100: {4, 5, 6},
+				},
+				ReplyPath: composeReplyPath(replyPath),
+			},
+		},
+	}
+
+	for _, testCase := range tests {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			s := newServerTest(t)
+			s.start()
+			defer s.stop()
+
+			if testCase.setupMock != nil {
+				testCase.setupMock(s.offerMock.Mock)
+			}
+
+			resp, err := s.server.SendOnionMessageWithReply(
+				context.Background(), testCase.request,
+			)
+			require.Equal(t, testCase.response, resp)
+
+			if testCase.response != nil {
+				require.NoError(t, err)
+				return
+			}
+
+			status, ok := status.FromError(err)
+			require.True(t, ok, "expected coded error")
+			require.Equal(t, testCase.errCode, status.Code())
+		})
+	}
+}
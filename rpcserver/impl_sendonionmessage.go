@@ -8,6 +8,7 @@ import (
 	"github.com/gijswijs/boltnd/lnwire"
 	"github.com/gijswijs/boltnd/offersrpc"
 	"github.com/gijswijs/boltnd/onionmsg"
+	"github.com/gijswijs/boltnd/routes"
 	"github.com/lightningnetwork/lnd/tlv"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -30,24 +31,46 @@ func (s *Server) SendOnionMessage(ctx context.Context,
 	}
 
 	err = s.onionMsgr.SendMessage(ctx, onionReq)
+	if err != nil {
+		return nil, sendOnionMessageErr(err)
+	}
+
+	return &offersrpc.SendOnionMessageResponse{}, nil
+}
+
+// sendOnionMessageErr converts an error returned by the onion messenger when
+// sending a message to a grpc status error.
+func sendOnionMessageErr(err error) error {
 	switch {
 	// If we got a no path error, prompt user to try direct connect if
 	// they want to.
 	case errors.Is(err, onionmsg.ErrNoPath):
-		return nil, status.Errorf(
+		return status.Errorf(
 			codes.NotFound, "could not find path to destination "+
 				"try using direct connect to deliver to peer "+
 				"(! exposes IP !)",
 		)
 
-	// Otherwise fail generically.
-	case err != nil:
-		return nil, status.Errorf(
-			codes.Internal, "send message failed: %v", err,
+	// If our target doesn't support onion messages, there's no point in
+	// retrying.
+	case errors.Is(err, onionmsg.ErrPeerNoOnionSupport):
+		return status.Errorf(
+			codes.FailedPrecondition, "destination does not "+
+				"support onion messages: %v", err,
+		)
+
+	// If the message is too large to fit in an onion, the caller needs
+	// to reduce the size of their payloads.
+	case errors.Is(err, routes.ErrPayloadTooLarge):
+		return status.Errorf(
+			codes.InvalidArgument, "message too large: %v", err,
 		)
 
+	// Otherwise fail generically.
 	default:
-		return &offersrpc.SendOnionMessageResponse{}, nil
+		return status.Errorf(
+			codes.Internal, "send message failed: %v", err,
+		)
 	}
 }
 
@@ -124,6 +147,7 @@ func parseSendOnionMessageRequest(req *offersrpc.SendOnionMessageRequest) (
 		pubkey, blindedDest, replyPath, finalHopPayloads,
 		req.DirectConnect,
 	)
+	onionReq.DisconnectAfterSend = req.DisconnectAfterSend
 
 	// Validate the request so that we can send a specific error code for
 	// invalid requests.
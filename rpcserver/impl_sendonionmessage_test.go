@@ -8,6 +8,7 @@ import (
 	"github.com/gijswijs/boltnd/lnwire"
 	"github.com/gijswijs/boltnd/offersrpc"
 	"github.com/gijswijs/boltnd/onionmsg"
+	"github.com/gijswijs/boltnd/routes"
 	"github.com/gijswijs/boltnd/testutils"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -60,6 +61,43 @@ func TestRPCSendOnionMessage(t *testing.T) {
 			success: false,
 			errCode: codes.Internal,
 		},
+		{
+			name: "peer has no onion support",
+			setupMock: func(m *mock.Mock) {
+				req := onionmsg.NewSendMessageRequest(
+					pubkey, nil, nil, []*lnwire.FinalHopPayload{}, true,
+				)
+
+				mockSendMessage(
+					m, req, onionmsg.ErrPeerNoOnionSupport,
+				)
+			},
+			request: &offersrpc.SendOnionMessageRequest{
+				Pubkey:        pubkeyBytes,
+				DirectConnect: true,
+			},
+			success: false,
+			errCode: codes.FailedPrecondition,
+		},
+		{
+			name: "message too large",
+			setupMock: func(m *mock.Mock) {
+				req := onionmsg.NewSendMessageRequest(
+					pubkey, nil, nil, []*lnwire.FinalHopPayload{}, true,
+				)
+
+				mockSendMessage(m, req, &routes.PayloadSizeError{
+					Size:  1400,
+					Limit: 1300,
+				})
+			},
+			request: &offersrpc.SendOnionMessageRequest{
+				Pubkey:        pubkeyBytes,
+				DirectConnect: true,
+			},
+			success: false,
+			errCode: codes.InvalidArgument,
+		},
 		{
 			name: "send message succeeds",
 			// Setup our mock to successfully send the message.
@@ -98,6 +136,23 @@ func TestRPCSendOnionMessage(t *testing.T) {
 			},
 			success: true,
 		},
+		{
+			name: "send message succeeds with disconnect",
+			setupMock: func(m *mock.Mock) {
+				req := onionmsg.NewSendMessageRequest(
+					pubkey, nil, nil, []*lnwire.FinalHopPayload{}, true,
+				)
+				req.DisconnectAfterSend = true
+
+				mockSendMessage(m, req, nil)
+			},
+			request: &offersrpc.SendOnionMessageRequest{
+				Pubkey:              pubkeyBytes,
+				DirectConnect:       true,
+				DisconnectAfterSend: true,
+			},
+			success: true,
+		},
 		{
 			name: "invalid final payload",
 			// We expect our test to fail when parsing the request,
@@ -18,10 +18,9 @@ func (s *Server) SubscribeOnionPayload(
 
 	log.Debugf("SubscribeOnionPayload: %+v", req)
 
-	if err := s.waitForReady(stream.Context()); err != nil {
-		return err
-	}
-
+	// We don't wait for our server to be ready, because handlers can be
+	// registered with our messenger before it is started. This allows
+	// clients to subscribe without racing our startup.
 	tlvType, err := parseSubscribeOnionPayloadRequest(req)
 	if err != nil {
 		return err
@@ -86,22 +85,21 @@ func handleSubscribeOnionPayload(ctx context.Context, tlvType tlv.Type,
 		}
 	}
 
-	// Register our handler with the messenger, and deregister it on
-	// exit.
-	if err := messenger.RegisterHandler(tlvType, handler); err != nil {
+	// Register our handler with the messenger, bound to a context that we
+	// cancel on exit so that the handler is deregistered however our
+	// stream ends. Other subscribers may be registered for the same tlv
+	// type, so the messenger only removes our own subscription.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	_, err := messenger.RegisterHandlerContext(ctx, tlvType, handler)
+	if err != nil {
 		return status.Errorf(
 			codes.Unavailable, "could not register "+
 				"subscription: %v", err,
 		)
 	}
 
-	defer func() {
-		if err := messenger.DeregisterHandler(tlvType); err != nil {
-			log.Errorf("Deregister handler: %v failed: %v",
-				tlvType, err)
-		}
-	}()
-
 	// Consume incoming messages until the client cancels the subscription
 	// or our stream fails.
 	for {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gijswijs/boltnd/offersrpc"
+	"github.com/gijswijs/boltnd/onionmsg"
 	"github.com/lightningnetwork/lnd/tlv"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -25,8 +26,9 @@ type subscribeOnionPayloadTestCase struct {
 // TestSubscribeOnionPayload tests management of onion payload subscriptions.
 func TestSubscribeOnionPayload(t *testing.T) {
 	var (
-		tlvType tlv.Type = 100
-		mockErr          = errors.New("mock err")
+		tlvType   tlv.Type           = 100
+		handlerID onionmsg.HandlerID = 3
+		mockErr                      = errors.New("mock err")
 
 		req = &offersrpc.SubscribeOnionPayloadRequest{
 			TlvType: uint64(tlvType),
@@ -47,7 +49,9 @@ func TestSubscribeOnionPayload(t *testing.T) {
 			name: "register handler fails",
 			setupMock: func(m *mock.Mock) {
 				mockContext(m, context.Background())
-				mockRegisterHandler(m, tlvType, mockErr)
+				mockRegisterHandlerContext(
+					m, tlvType, 0, mockErr,
+				)
 			},
 			request: req,
 			errCode: codes.Unavailable,
@@ -56,9 +60,9 @@ func TestSubscribeOnionPayload(t *testing.T) {
 			name: "server shutdown",
 			setupMock: func(m *mock.Mock) {
 				mockContext(m, context.Background())
-				mockRegisterHandler(m, tlvType, nil)
-				mockDeregisterHandler(m, tlvType, nil)
-
+				mockRegisterHandlerContext(
+					m, tlvType, handlerID, nil,
+				)
 			},
 			request: req,
 			testFunc: func(s *serverTest) {
@@ -74,10 +78,9 @@ func TestSubscribeOnionPayload(t *testing.T) {
 				mockContext(m, ctxc)
 
 				// Assert that we register a handler.
-				mockRegisterHandler(m, tlvType, nil)
-
-				// Assert that we deregister on exit.
-				mockDeregisterHandler(m, tlvType, nil)
+				mockRegisterHandlerContext(
+					m, tlvType, handlerID, nil,
+				)
 			},
 			request: req,
 			testFunc: func(s *serverTest) {
@@ -101,17 +104,11 @@ func TestSubscribeOnionPayload(t *testing.T) {
 func testSubscribeOnionPayload(t *testing.T,
 	testCase *subscribeOnionPayloadTestCase) {
 
+	// We don't start our server, because subscriptions may be made
+	// before it is ready.
 	s := newServerTest(t)
-	s.start()
 	defer s.stop()
 
-	// All of our tests begin with a call to Context (in waitForReady).
-	// We always want this to pass, so we just use a background context,
-	// so that there are no race conditions where we're testing context
-	// cancellation at a later stage and the test exits earlier than we
-	// want.
-	mockContext(s.offerMock.Mock, context.Background())
-
 	if testCase.setupMock != nil {
 		testCase.setupMock(s.offerMock.Mock)
 	}
@@ -157,6 +154,8 @@ func TestHandleSubscribOnionPayload(t *testing.T) {
 		ctx, cancel          = context.WithCancel(context.Background())
 		tlvType     tlv.Type = 100
 
+		handlerID onionmsg.HandlerID = 3
+
 		quit     = make(chan struct{})
 		incoming = make(chan onionPayloadResponse)
 
@@ -166,10 +165,10 @@ func TestHandleSubscribOnionPayload(t *testing.T) {
 	s.start()
 	defer s.stop()
 
-	// Setup our mock to register our handler, and de-register it when
-	// we're done.
-	mockRegisterHandler(s.offerMock.Mock, tlvType, nil)
-	mockDeregisterHandler(s.offerMock.Mock, tlvType, nil)
+	// Setup our mock to register our handler.
+	mockRegisterHandlerContext(
+		s.offerMock.Mock, tlvType, handlerID, nil,
+	)
 
 	// Our function blocks, so we run it in a goroutine.
 	errChan := make(chan error)
@@ -0,0 +1,198 @@
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/gijswijs/boltnd/onionmsg"
+	"github.com/lightninglabs/lndclient"
+	sphinx "github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+// errLndNotConnected is returned when our onion messenger tries to use lnd
+// before our server has been started.
+var errLndNotConnected = errors.New("lnd not connected")
+
+// lndConn provides our onion messenger with its lnd dependencies. We only
+// receive our connection to lnd on Start(), but create our messenger when the
+// server is created so that handlers can be registered with it before we
+// start. Our messenger does not use its dependencies until it is started, by
+// which point lnd has been connected.
+type lndConn struct {
+	lnd         onionmsg.LndOnionMsg
+	nodeKeyECDH sphinx.SingleKeyECDH
+	lock        sync.RWMutex
+}
+
+// Compile time checks that lndConn implements our messenger's dependencies.
+var (
+	_ onionmsg.LndOnionMsg = (*lndConn)(nil)
+	_ sphinx.SingleKeyECDH = (*lndConn)(nil)
+)
+
+// connect sets the lnd client and node key used by our messenger.
+func (l *lndConn) connect(lnd onionmsg.LndOnionMsg,
+	nodeKeyECDH sphinx.SingleKeyECDH) {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lnd = lnd
+	l.nodeKeyECDH = nodeKeyECDH
+}
+
+// client returns our lnd client, failing if we are not yet connected.
+func (l *lndConn) client() (onionmsg.LndOnionMsg, error) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if l.lnd == nil {
+		return nil, errLndNotConnected
+	}
+
+	return l.lnd, nil
+}
+
+// PubKey returns our node's public key, or nil if we are not yet connected.
+func (l *lndConn) PubKey() *btcec.PublicKey {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	if l.nodeKeyECDH == nil {
+		return nil
+	}
+
+	return l.nodeKeyECDH.PubKey()
+}
+
+// ECDH performs ECDH operations with our node's key.
+func (l *lndConn) ECDH(pubkey *btcec.PublicKey) ([32]byte, error) {
+	l.lock.RLock()
+	nodeKeyECDH := l.nodeKeyECDH
+	l.lock.RUnlock()
+
+	if nodeKeyECDH == nil {
+		return [32]byte{}, errLndNotConnected
+	}
+
+	return nodeKeyECDH.ECDH(pubkey)
+}
+
+// SendCustomMessage sends a custom message to a peer.
+func (l *lndConn) SendCustomMessage(ctx context.Context,
+	msg lndclient.CustomMessage) error {
+
+	lnd, err := l.client()
+	if err != nil {
+		return err
+	}
+
+	return lnd.SendCustomMessage(ctx, msg)
+}
+
+// SubscribeCustomMessages subscribes to custom messages from our peers.
+func (l *lndConn) SubscribeCustomMessages(ctx context.Context) (
+	<-chan lndclient.CustomMessage, <-chan error, error) {
+
+	lnd, err := l.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lnd.SubscribeCustomMessages(ctx)
+}
+
+// ListPeers returns our current set of peers.
+func (l *lndConn) ListPeers(ctx context.Context) ([]lndclient.Peer, error) {
+	lnd, err := l.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return lnd.ListPeers(ctx)
+}
+
+// Connect makes a connection to the peer provided.
+func (l *lndConn) Connect(ctx context.Context, peer route.Vertex, host string,
+	permanent bool) error {
+
+	lnd, err := l.client()
+	if err != nil {
+		return err
+	}
+
+	return lnd.Connect(ctx, peer, host, permanent)
+}
+
+// Disconnect disconnects from the peer provided.
+func (l *lndConn) Disconnect(ctx context.Context, peer route.Vertex) error {
+	lnd, err := l.client()
+	if err != nil {
+		return err
+	}
+
+	return lnd.Disconnect(ctx, peer)
+}
+
+// SubscribePeerEvents subscribes to peer online and offline events.
+func (l *lndConn) SubscribePeerEvents(ctx context.Context) (
+	<-chan *lnrpc.PeerEvent, <-chan error, error) {
+
+	lnd, err := l.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lnd.SubscribePeerEvents(ctx)
+}
+
+// GetNodeInfo looks up a node in the public ln graph.
+func (l *lndConn) GetNodeInfo(ctx context.Context, pubkey route.Vertex,
+	includeChannels bool) (*lndclient.NodeInfo, error) {
+
+	lnd, err := l.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return lnd.GetNodeInfo(ctx, pubkey, includeChannels)
+}
+
+// GetChanInfo looks up a channel in the public ln graph.
+func (l *lndConn) GetChanInfo(ctx context.Context, chanID uint64) (
+	*lndclient.ChannelEdge, error) {
+
+	lnd, err := l.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return lnd.GetChanInfo(ctx, chanID)
+}
+
+// QueryRoutes queries for a route to a destination peer.
+func (l *lndConn) QueryRoutes(ctx context.Context,
+	req lndclient.QueryRoutesRequest) (*lndclient.QueryRoutesResponse,
+	error) {
+
+	lnd, err := l.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return lnd.QueryRoutes(ctx, req)
+}
+
+// GetInfo returns information about the lnd node.
+func (l *lndConn) GetInfo(ctx context.Context) (*lndclient.Info, error) {
+	lnd, err := l.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return lnd.GetInfo(ctx)
+}
@@ -13,6 +13,10 @@ var RPCServerPermissions = map[string][]bakery.Op{
 		Entity: "offchain",
 		Action: "read",
 	}},
+	"/offersrpc.Offers/DecodeOffers": {{
+		Entity: "offchain",
+		Action: "read",
+	}},
 	"/offersrpc.Offers/SubscribeOnionPayload": {{
 		Entity: "offchain",
 		Action: "read",
@@ -21,4 +25,8 @@ var RPCServerPermissions = map[string][]bakery.Op{
 		Entity: "offchain",
 		Action: "read",
 	}},
+	"/offersrpc.Offers/SendOnionMessageWithReply": {{
+		Entity: "peers",
+		Action: "write",
+	}},
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/gijswijs/boltnd/offersrpc"
 	"github.com/gijswijs/boltnd/onionmsg"
@@ -17,6 +18,10 @@ import (
 // Compile time check that this server implements our grpc server.
 var _ offersrpc.OffersServer = (*Server)(nil)
 
+// overrideCheckTimeout is the amount of time we allow for checking that lnd
+// is configured to send our onion message type on startup.
+const overrideCheckTimeout = time.Second * 30
+
 var (
 	// ErrShuttingDown is returned when an operation is aborted because
 	// the server is shutting down.
@@ -34,9 +39,17 @@ type Server struct {
 	// only be non-nil once Start() has been called.
 	lnd *lndclient.LndServices
 
+	// lndConn provides our messenger with its lnd dependencies, and is
+	// connected to lnd once Start() has been called.
+	lndConn *lndConn
+
+	// messenger is our onion messenger. It is created along with our
+	// server so that handlers can be registered with it before we have
+	// started, but it is only started once Start() has been called.
+	messenger *onionmsg.Messenger
+
 	// onionMsgr manages sending and receipt of onion messages with peers.
-	// As with the lnd instance above, the messenger is only created once
-	// Start() has been called.
+	// It is backed by our messenger.
 	onionMsgr onionmsg.OnionMessenger
 
 	// routeGenerator produces blinded paths to our node.
@@ -58,9 +71,24 @@ type Server struct {
 	offersrpc.UnimplementedOffersServer
 }
 
-// NewServer creates an offers server.
-func NewServer(shutdown func(error)) (*Server, error) {
+// NewServer creates an offers server, along with an onion messenger that is
+// created with the options provided.
+func NewServer(shutdown func(error),
+	messengerOpts ...onionmsg.MessengerOption) (*Server, error) {
+
+	conn := &lndConn{}
+	messenger, err := onionmsg.NewOnionMessenger(
+		conn, conn, shutdown, messengerOpts...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create onion messenger: %w",
+			err)
+	}
+
 	return &Server{
+		lndConn:         conn,
+		messenger:       messenger,
+		onionMsgr:       messenger,
 		ready:           make(chan struct{}),
 		quit:            make(chan struct{}),
 		requestShutdown: shutdown,
@@ -76,26 +104,41 @@ func (s *Server) Start(lnd *lndclient.LndServices) error {
 	log.Info("Starting rpc server")
 	s.lnd = lnd
 
-	// Setup a router that our onion messenger can use, utilizing a
-	// signer that calls lnd's apis for cyrptographic operations.
-	nodeKeyECDH, err := onionmsg.NewNodeECDH(lnd.Client, lnd.Signer)
+	// Connect our onion messenger to lnd, utilizing a signer that calls
+	// lnd's apis for cyrptographic operations.
+	onionLnd := onionmsg.NewLndClient(lnd.Client)
+	nodeKeyECDH, err := onionmsg.NewNodeECDH(onionLnd, lnd.Signer)
 	if err != nil {
 		return fmt.Errorf("could not create router signer: %w", err)
 	}
+	s.lndConn.connect(onionLnd, nodeKeyECDH)
 
 	s.routeGenerator = routes.NewBlindedRouteGenerator(
 		lnd.Client, nodeKeyECDH.PubKey(),
 	)
 
-	// Finally setup an onion messenger using the onion router.
-	s.onionMsgr = onionmsg.NewOnionMessenger(
-		lnd.Client, nodeKeyECDH, s.requestShutdown,
-	)
+	// Use our route generator to create reply paths for messages that
+	// expect a reply.
+	if err := s.messenger.EnableReplies(s.routeGenerator); err != nil {
+		return fmt.Errorf("could not enable replies: %w", err)
+	}
 
-	if err := s.onionMsgr.Start(); err != nil {
+	if err := s.messenger.Start(); err != nil {
 		return fmt.Errorf("could not start onion messenger: %w", err)
 	}
 
+	// Fail fast if lnd is not configured to deliver our onion messages,
+	// rather than silently dropping all of our traffic.
+	ctx, cancel := context.WithTimeout(
+		context.Background(), overrideCheckTimeout,
+	)
+	defer cancel()
+
+	// Our messenger has already been started, so it is shut down by Stop.
+	if err := s.messenger.CheckCustomMessageOverride(ctx); err != nil {
+		return fmt.Errorf("onion message type check: %w", err)
+	}
+
 	close(s.ready)
 
 	return nil
@@ -113,13 +156,12 @@ func (s *Server) Stop() error {
 	// Signal to goroutines to shut down.
 	close(s.quit)
 
-	// Shut down onion messenger if non-nil.
-	if s.onionMsgr != nil {
-		if err := s.onionMsgr.Stop(); err != nil {
-			return fmt.Errorf("could not stop onion messenger: %w",
-				err)
-		}
+	// Shut down our onion messenger, which is safe to do even if it was
+	// never started.
+	if err := s.messenger.Stop(); err != nil {
+		return fmt.Errorf("could not stop onion messenger: %w", err)
 	}
+
 	return nil
 }
 
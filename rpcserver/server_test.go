@@ -0,0 +1,32 @@
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gijswijs/boltnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestServerPreStart tests that handlers can be registered with our server's
+// messenger before the server is started, and that a server that was never
+// started can be stopped.
+func TestServerPreStart(t *testing.T) {
+	server, err := NewServer(nil)
+	require.NoError(t, err, "new server")
+
+	_, err = server.onionMsgr.RegisterHandler(
+		100, func(*lnwire.ReplyPath, []byte, []byte) error {
+			return nil
+		},
+	)
+	require.NoError(t, err, "register handler")
+
+	// Until we're started, our messenger can't reach lnd.
+	_, err = server.lndConn.ListPeers(context.Background())
+	require.True(t, errors.Is(err, errLndNotConnected))
+	require.Nil(t, server.lndConn.PubKey())
+
+	require.NoError(t, server.Stop(), "stop server")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gijswijs/boltnd/offersrpc"
 	"github.com/gijswijs/boltnd/onionmsg"
 	"github.com/gijswijs/boltnd/testutils"
+	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/lightningnetwork/lnd/tlv"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -102,40 +103,111 @@ func mockSendMessage(m *mock.Mock, req *onionmsg.SendMessageRequest,
 	)
 }
 
-// RegisterHandler mocks registering a handler.
-func (o *offersMock) RegisterHandler(tlvType tlv.Type,
-	handler onionmsg.OnionMessageHandler) error {
+// SendMessageWithReply mocks sending a message that expects a reply.
+func (o *offersMock) SendMessageWithReply(ctx context.Context,
+	req *onionmsg.SendMessageRequest, hops, dummyHops uint8) (
+	*onionmsg.Reply, error) {
 
-	args := o.Mock.MethodCalled("RegisterHandler", tlvType, handler)
-	return args.Error(0)
+	args := o.Mock.MethodCalled(
+		"SendMessageWithReply", ctx, req, hops, dummyHops,
+	)
+
+	return args.Get(0).(*onionmsg.Reply), args.Error(1)
 }
 
-// mockRegisterHandler primes our mock to return the error provided when a call
-// to register handler with tlvType (and any handler function) is called.
-func mockRegisterHandler(m *mock.Mock, tlvType tlv.Type, err error) {
+// mockSendMessageWithReply primes our mock to return the reply and error
+// provided when we send a message that expects a reply.
+func mockSendMessageWithReply(m *mock.Mock, req *onionmsg.SendMessageRequest,
+	hops, dummyHops uint8, reply *onionmsg.Reply, err error) {
+
 	m.On(
-		"RegisterHandler", tlvType, mock.Anything,
+		"SendMessageWithReply", mock.Anything, req, hops, dummyHops,
 	).Once().Return(
-		err,
+		reply, err,
 	)
 }
 
-// DeregisterHandler mocks deregistering a handler.
-func (o *offersMock) DeregisterHandler(tlvType tlv.Type) error {
-	args := o.Mock.MethodCalled("DeregisterHandler", tlvType)
-	return args.Error(0)
+// NewPathID mocks creation of a path ID.
+func (o *offersMock) NewPathID() ([]byte, error) {
+	args := o.Mock.MethodCalled("NewPathID")
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+// mockNewPathID primes our mock to return the path ID and error provided when
+// a path ID is created.
+func mockNewPathID(m *mock.Mock, pathID []byte, err error) {
+	m.On("NewPathID").Once().Return(pathID, err)
+}
+
+// RegisterHandlerContext mocks registering a handler that is bound to a
+// context.
+func (o *offersMock) RegisterHandlerContext(ctx context.Context,
+	tlvType tlv.Type, handler onionmsg.OnionMessageHandler) (
+	onionmsg.HandlerID, error) {
+
+	args := o.Mock.MethodCalled(
+		"RegisterHandlerContext", ctx, tlvType, handler,
+	)
+	return args.Get(0).(onionmsg.HandlerID), args.Error(1)
+}
+
+// RegisterReplyingHandler mocks registration of a handler with a reply
+// helper.
+func (o *offersMock) RegisterReplyingHandler(tlvType tlv.Type,
+	handler onionmsg.ReplyingHandler) (onionmsg.HandlerID, error) {
+
+	args := o.Mock.MethodCalled(
+		"RegisterReplyingHandler", tlvType, handler,
+	)
+	return args.Get(0).(onionmsg.HandlerID), args.Error(1)
 }
 
-// mockDeregisterHandler primes our mock to return the error provided when a
-// call to dereigster handler with tlvType is made.
-func mockDeregisterHandler(m *mock.Mock, tlvType tlv.Type, err error) {
+// mockRegisterHandlerContext primes our mock to return the handler ID and
+// error provided when a call to register a context-bound handler with
+// tlvType (and any context and handler function) is made.
+func mockRegisterHandlerContext(m *mock.Mock, tlvType tlv.Type,
+	id onionmsg.HandlerID, err error) {
+
 	m.On(
-		"DeregisterHandler", tlvType,
+		"RegisterHandlerContext", mock.Anything, tlvType,
+		mock.Anything,
 	).Once().Return(
-		err,
+		id, err,
 	)
 }
 
+// RegisterWildcardHandler mocks registering a catch-all handler.
+func (o *offersMock) RegisterWildcardHandler(
+	handler onionmsg.WildcardHandler) error {
+
+	args := o.Mock.MethodCalled("RegisterWildcardHandler", handler)
+	return args.Error(0)
+}
+
+// DeregisterWildcardHandler mocks deregistering a catch-all handler.
+func (o *offersMock) DeregisterWildcardHandler() error {
+	args := o.Mock.MethodCalled("DeregisterWildcardHandler")
+	return args.Error(0)
+}
+
+// PeerStats mocks querying per-peer relay statistics.
+func (o *offersMock) PeerStats() map[route.Vertex]onionmsg.PeerStats {
+	args := o.Mock.MethodCalled("PeerStats")
+	return args.Get(0).(map[route.Vertex]onionmsg.PeerStats)
+}
+
+// DropStats mocks querying counters for dropped onion messages.
+func (o *offersMock) DropStats() onionmsg.DropStats {
+	args := o.Mock.MethodCalled("DropStats")
+	return args.Get(0).(onionmsg.DropStats)
+}
+
+// HandlerStats mocks querying per-type handler statistics.
+func (o *offersMock) HandlerStats() map[tlv.Type]onionmsg.HandlerStats {
+	args := o.Mock.MethodCalled("HandlerStats")
+	return args.Get(0).(map[tlv.Type]onionmsg.HandlerStats)
+}
+
 // Context mocks querying a grpc stream for its context.
 func (o *offersMock) Context() context.Context {
 	args := o.Mock.MethodCalled("Context")
@@ -23,19 +23,24 @@ func NewMockRouteGenerator() *MockRouteGenerator {
 
 // ReplyPath mocks creation of a blinded route.
 func (m *MockRouteGenerator) ReplyPath(ctx context.Context,
-	features []lndwire.FeatureBit) (*sphinx.BlindedPath, error) {
+	features []lndwire.FeatureBit, hops, dummyHops uint8, pathID []byte) (
+	*sphinx.BlindedPath, error) {
 
-	args := m.Mock.MethodCalled("BlindedRoute", ctx, features)
+	args := m.Mock.MethodCalled(
+		"BlindedRoute", ctx, features, hops, dummyHops, pathID,
+	)
 	return args.Get(0).(*sphinx.BlindedPath), args.Error(1)
 }
 
 // MockBlindedRoute primes our mock to return the error provided when
-// send custom message is called with any CustomMessage.
+// send custom message is called with any CustomMessage. Path IDs are usually
+// randomly generated, so any path ID is accepted.
 func MockBlindedRoute(m *mock.Mock, features []lndwire.FeatureBit,
-	path *sphinx.BlindedPath, err error) {
+	hops, dummyHops uint8, path *sphinx.BlindedPath, err error) {
 
 	m.On(
-		"BlindedRoute", mock.Anything, features,
+		"BlindedRoute", mock.Anything, features, hops, dummyHops,
+		mock.Anything,
 	).Once().Return(
 		path, err,
 	)
@@ -6,6 +6,7 @@ import (
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/lightninglabs/lndclient"
 	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/stretchr/testify/mock"
 )
@@ -78,6 +79,28 @@ func (m *MockLND) GetNodeInfo(ctx context.Context, pubkey route.Vertex,
 	return node.(*lndclient.NodeInfo), args.Error(1)
 }
 
+// GetChanInfo mocks looking up a channel in the public ln graph.
+func (m *MockLND) GetChanInfo(ctx context.Context, chanID uint64) (
+	*lndclient.ChannelEdge, error) {
+
+	args := m.Mock.MethodCalled("GetChanInfo", ctx, chanID)
+
+	edge := args.Get(0)
+	return edge.(*lndclient.ChannelEdge), args.Error(1)
+}
+
+// MockGetChanInfo primes our mock to return the edge and error provided when
+// a call to get channel info for the channel id provided is made.
+func MockGetChanInfo(m *mock.Mock, chanID uint64, edge *lndclient.ChannelEdge,
+	err error) {
+
+	m.On(
+		"GetChanInfo", mock.Anything, chanID,
+	).Once().Return(
+		edge, err,
+	)
+}
+
 // MockGetNodeInfo primes our mock to return the info and error provided when
 // a call to get node info with the peer/include channels params is made.
 func MockGetNodeInfo(m *mock.Mock, pubkey route.Vertex, includeChannels bool,
@@ -130,6 +153,47 @@ func MockConnect(m *mock.Mock, peer route.Vertex, host string, perm bool,
 	)
 }
 
+// Disconnect mocks disconnecting from the peer provided.
+func (m *MockLND) Disconnect(ctx context.Context, peer route.Vertex) error {
+	args := m.Mock.MethodCalled("Disconnect", ctx, peer)
+
+	return args.Error(0)
+}
+
+// MockDisconnect primes our mock to return the error specified on a call to
+// Disconnect.
+func MockDisconnect(m *mock.Mock, peer route.Vertex, err error) {
+	m.On(
+		"Disconnect", mock.Anything, peer,
+	).Once().Return(
+		err,
+	)
+}
+
+// SubscribePeerEvents mocks subscribing to lnd's peer events.
+func (m *MockLND) SubscribePeerEvents(ctx context.Context) (
+	<-chan *lnrpc.PeerEvent, <-chan error, error) {
+
+	args := m.Mock.MethodCalled("SubscribePeerEvents", ctx)
+
+	eventChan := args.Get(0).(<-chan *lnrpc.PeerEvent)
+	errChan := args.Get(1).(<-chan error)
+
+	return eventChan, errChan, args.Error(2)
+}
+
+// MockSubscribePeerEvents primes our mock to return the channels and error
+// provided when we subscribe to peer events.
+func MockSubscribePeerEvents(m *mock.Mock, eventChan <-chan *lnrpc.PeerEvent,
+	errChan <-chan error, err error) {
+
+	m.On(
+		"SubscribePeerEvents", mock.Anything,
+	).Once().Return(
+		eventChan, errChan, err,
+	)
+}
+
 // GetInfo mocks a call to lnd's getinfo.
 func (m *MockLND) GetInfo(ctx context.Context) (*lndclient.Info, error) {
 	args := m.Mock.MethodCalled("GetInfo", ctx)
@@ -221,3 +285,27 @@ func MockQueryRoutes(m *mock.Mock, req lndclient.QueryRoutesRequest,
 		resp, err,
 	)
 }
+
+// SignMessage mocks signing a message with lnd's signer.
+func (m *MockLND) SignMessage(ctx context.Context, msg []byte,
+	locator keychain.KeyLocator, opts ...lndclient.SignMessageOption) (
+	[]byte, error) {
+
+	args := m.Mock.MethodCalled("SignMessage", ctx, msg, locator)
+
+	sig, _ := args.Get(0).([]byte)
+
+	return sig, args.Error(1)
+}
+
+// MockSignMessage primes our mock to return the signature and error provided
+// when sign message is called.
+func MockSignMessage(m *mock.Mock, msg []byte, locator keychain.KeyLocator,
+	sig []byte, err error) {
+
+	m.On(
+		"SignMessage", mock.Anything, msg, locator,
+	).Once().Return(
+		sig, err,
+	)
+}